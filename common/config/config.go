@@ -35,6 +35,7 @@ func defaultConfig() {
 	viper.SetDefault("log_dir", "./logs")
 	viper.SetDefault("sqlite_path", "one-api.db")
 	viper.SetDefault("sqlite_busy_timeout", 3000)
+	viper.SetDefault("storage.local.path", "./data/files")
 	viper.SetDefault("sync_frequency", 600)
 	viper.SetDefault("batch_update_interval", 5)
 	viper.SetDefault("global.api_rate_limit", 300)
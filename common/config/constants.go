@@ -125,6 +125,50 @@ var SafeKeyWords = []string{
 	"methamphetamine",
 }
 
+// 正则审查规则，JSON 数组，如 [{"name":"手机号","pattern":"1[3-9]\\d{9}","risk_level":3}]
+var SafeRegexRules = ""
+
+// 检测到个人信息时的处理方式：redact 脱敏后放行，block 拦截
+var SafePIIAction = "redact"
+
+// LLM 审查通过网关自身的 /v1/moderations 接口调用审核模型，使用配置的令牌计费
+var SafeLLMJudgeModel = "omni-moderation-latest"
+var SafeLLMJudgeToken = ""
+
+// LLM 审查的分类得分达到该值视为违规，0 则使用审核模型的 flagged 结果
+var SafeLLMJudgeThreshold = 0.0
+
+// 串联审查（SafeToolName 以逗号分隔多个工具）时的风险阈值，命中工具的风险等级之和达到该值才拦截，0 则任一工具命中即拦截
+var SafeRiskThreshold = 0.0
+
+// 是否开启对话响应缓存
+var ChatCacheEnabled = false
+
+// 对话缓存默认有效期（秒），令牌和分组未单独设置时使用
+var ChatCacheExpireSeconds = 300
+
+// 命中缓存时的计费倍率，0 表示命中缓存不计费
+var ChatCacheHitRatio = 0.1
+
+// 批处理每个任务同时执行的请求数
+var BatchConcurrency = 5
+
+// 批处理计费倍率，如 0.5 表示批处理请求按五折计费
+var BatchDiscountRatio = 0.5
+
+// 每个用户可保存的文件总大小（MB），0 表示不限制
+var FileUserStorageLimit = 1024
+
+// 每个用户可保存的文件数量，0 表示不限制
+var FileUserCountLimit = 1000
+
+// Responses API 响应对象的保存天数，0 表示不保存，previous_response_id 只能透传给上游。
+// 保存对话内容需要管理员主动开启，开启后与 OpenAI 一致，请求未指定 store 时默认保存
+var ResponseStoreRetentionDays = 0
+
+// 会话粘滞路由的绑定有效期（秒）
+var RoutingStickyTTL = 3600
+
 // mj
 var MjNotifyEnabled = false
 
@@ -188,8 +232,23 @@ var RetryTimes = 0
 var RetryTimeOut = 10
 
 var DefaultChannelWeight = uint(1)
+
+// 渠道全部熔断或达到上限时的排队设置
+var ChannelQueueTimeout = 3000 // 排队等待的最长时间（毫秒），0 则不排队
+var ChannelQueueMaxSize = 100  // 每个分组下每个模型最多排队的请求数，0 则不限制
+
+// 首次熔断的时长（秒），之后按连续熔断次数指数增长
 var RetryCooldownSeconds = 5
 
+// 熔断设置
+var CircuitBreakerEnabled = true
+var CircuitBreakerFailureThreshold = 5 // 连续失败次数达到该值时熔断
+var CircuitBreakerErrorRate = 0.5      // 统计窗口内错误率达到该值时熔断
+var CircuitBreakerMinRequests = 20     // 统计窗口内请求数达到该值才计算错误率
+var CircuitBreakerWindowSeconds = 60   // 错误率统计窗口（秒）
+var CircuitBreakerMaxOpenSeconds = 300 // 最长熔断时长（秒）
+var CircuitBreakerHalfOpenProbes = 1   // 半开状态允许的探测请求数，全部成功后恢复
+
 var CFWorkerImageUrl = ""
 var CFWorkerImageKey = ""
 
@@ -306,6 +365,7 @@ const (
 	ChannelTypeAzureDatabricks = 54
 	ChannelTypeAzureV1         = 55
 	ChannelTypeXAI             = 56
+	ChannelTypeCompatible      = 57
 )
 
 const (
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// 默认的模型前缀与分类的对应关系
+var defaultVertexAIModelCategory = map[string]string{
+	"gemini":    "gemini",
+	"claude":    "claude",
+	"meta/":     "openai",
+	"llama":     "openai",
+	"deepseek":  "openai",
+	"qwen":      "openai",
+	"openai/":   "openai",
+	"mistral":   "mistral",
+	"codestral": "mistral",
+}
+
+type VertexAISettings struct {
+	// 模型前缀对应的分类，优先于默认配置
+	ModelCategory map[string]string
+}
+
+var VertexAISettingsInstance = VertexAISettings{
+	ModelCategory: map[string]string{},
+}
+
+func init() {
+	GlobalOption.RegisterCustom("VertexAIModelCategory", func() string {
+		return VertexAISettingsInstance.GetModelCategoryJSONString()
+	}, func(value string) error {
+		return VertexAISettingsInstance.SetModelCategory(value)
+	}, "")
+}
+
+// SetModelCategory 配置无效时返回错误，并保留原有配置
+func (c *VertexAISettings) SetModelCategory(data string) error {
+	modelCategory, err := parseModelCategory(data)
+	if err != nil {
+		return err
+	}
+	c.ModelCategory = modelCategory
+
+	return nil
+}
+
+// ValidateVertexAIModelCategory 校验模型分类配置，用于保存前检查
+func ValidateVertexAIModelCategory(data string) error {
+	_, err := parseModelCategory(data)
+	return err
+}
+
+func parseModelCategory(data string) (map[string]string, error) {
+	modelCategory := map[string]string{}
+	if data == "" {
+		return modelCategory, nil
+	}
+
+	if err := json.Unmarshal([]byte(data), &modelCategory); err != nil {
+		return nil, err
+	}
+
+	for prefix, category := range modelCategory {
+		if prefix == "" || category == "" {
+			return nil, errors.New("model prefix and category must not be empty")
+		}
+	}
+
+	return modelCategory, nil
+}
+
+// GetModelCategory 按最长前缀匹配模型所属的分类，先匹配配置项，再匹配默认配置
+func (c *VertexAISettings) GetModelCategory(model string) string {
+	if category := matchModelCategory(c.ModelCategory, model); category != "" {
+		return category
+	}
+
+	return matchModelCategory(defaultVertexAIModelCategory, model)
+}
+
+func (c *VertexAISettings) GetModelCategoryJSONString() string {
+	str, err := json.Marshal(c.ModelCategory)
+	if err != nil {
+		return ""
+	}
+	return string(str)
+}
+
+func matchModelCategory(modelCategory map[string]string, model string) string {
+	category := ""
+	matchLen := 0
+	for prefix, value := range modelCategory {
+		if strings.HasPrefix(model, prefix) && len(prefix) > matchLen {
+			category = value
+			matchLen = len(prefix)
+		}
+	}
+
+	return category
+}
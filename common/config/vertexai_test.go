@@ -0,0 +1,55 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetModelCategory(t *testing.T) {
+	settings := &VertexAISettings{ModelCategory: map[string]string{}}
+
+	require.NoError(t, settings.SetModelCategory(`{"gemini-exp":"openai"}`))
+	assert.Equal(t, map[string]string{"gemini-exp": "openai"}, settings.ModelCategory)
+
+	// 无效配置返回错误，保留原有配置
+	tests := []string{
+		`{"gemini-exp":`,
+		`["gemini"]`,
+		`{"":"openai"}`,
+		`{"gemini-exp":""}`,
+	}
+	for _, data := range tests {
+		assert.Error(t, settings.SetModelCategory(data), data)
+		assert.Error(t, ValidateVertexAIModelCategory(data), data)
+		assert.Equal(t, map[string]string{"gemini-exp": "openai"}, settings.ModelCategory)
+	}
+
+	require.NoError(t, settings.SetModelCategory(""))
+	assert.Empty(t, settings.ModelCategory)
+}
+
+func TestGetModelCategory(t *testing.T) {
+	settings := &VertexAISettings{ModelCategory: map[string]string{
+		"gemini-exp": "openai",
+		"my-":        "claude",
+	}}
+
+	tests := []struct {
+		model string
+		want  string
+	}{
+		// 配置项优先于默认配置
+		{model: "gemini-exp-1206", want: "openai"},
+		{model: "gemini-2.5-pro", want: "gemini"},
+		{model: "my-model", want: "claude"},
+		// 默认配置按最长前缀匹配
+		{model: "meta/llama-3.1-405b-instruct-maas", want: "openai"},
+		{model: "codestral-2501", want: "mistral"},
+		{model: "unknown-model", want: ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, settings.GetModelCategory(tt.model), tt.model)
+	}
+}
@@ -0,0 +1,138 @@
+package limit
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"one-api/common/config"
+	"one-api/common/redis"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	concurrencyFormat = "{%s}:concurrency"
+	// 每次占用时刷新过期时间，节点异常退出时未释放的计数最多保留这么久
+	concurrencyExpiration = 10 * time.Minute
+)
+
+var (
+	//go:embed concurrency.lua
+	concurrencyLuaScript string
+	concurrencyScript    = redis.NewScript(concurrencyLuaScript)
+
+	//go:embed concurrencyrelease.lua
+	concurrencyReleaseLuaScript string
+	concurrencyReleaseScript    = redis.NewScript(concurrencyReleaseLuaScript)
+)
+
+// ConcurrencyLimiter 限制同时进行中的请求数，未开启 Redis 时只在当前节点内计数
+type ConcurrencyLimiter struct {
+	max int
+}
+
+func NewConcurrencyLimiter(max int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{max: max}
+}
+
+// Acquire 占用一个并发名额，返回是否成功
+func (l *ConcurrencyLimiter) Acquire(keyPrefix string) (bool, error) {
+	key := fmt.Sprintf(concurrencyFormat, keyPrefix)
+	if !config.RedisEnabled {
+		return memoryConcurrencyStore.acquire(key, l.max), nil
+	}
+
+	result, err := redis.ScriptRunCtx(
+		context.Background(),
+		concurrencyScript,
+		[]string{key},
+		l.max,                                // ARGV[1]: 并发上限
+		int(concurrencyExpiration.Seconds()), // ARGV[2]: 过期时间（秒）
+	)
+	if err != nil {
+		return false, err
+	}
+
+	resultArray, ok := result.([]interface{})
+	if !ok || len(resultArray) < 2 {
+		return false, errors.New("无法转换并发限制结果")
+	}
+
+	allowed, _ := resultArray[0].(int64)
+	return allowed == 1, nil
+}
+
+// Release 释放 Acquire 占用的名额
+func (l *ConcurrencyLimiter) Release(keyPrefix string) error {
+	key := fmt.Sprintf(concurrencyFormat, keyPrefix)
+	if !config.RedisEnabled {
+		memoryConcurrencyStore.release(key)
+		return nil
+	}
+
+	_, err := redis.ScriptRunCtx(context.Background(), concurrencyReleaseScript, []string{key})
+	return err
+}
+
+// GetCurrent 获取进行中的请求数
+func (l *ConcurrencyLimiter) GetCurrent(keyPrefix string) (int, error) {
+	key := fmt.Sprintf(concurrencyFormat, keyPrefix)
+	if !config.RedisEnabled {
+		return memoryConcurrencyStore.get(key), nil
+	}
+
+	count, err := redis.RedisGet(key)
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	return strconv.Atoi(count)
+}
+
+func (l *ConcurrencyLimiter) GetLimit() int {
+	return l.max
+}
+
+type concurrencyMemoryStore struct {
+	sync.Mutex
+	counts map[string]int
+}
+
+var memoryConcurrencyStore = &concurrencyMemoryStore{
+	counts: make(map[string]int),
+}
+
+func (s *concurrencyMemoryStore) acquire(key string, max int) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.counts[key] >= max {
+		return false
+	}
+	s.counts[key]++
+
+	return true
+}
+
+func (s *concurrencyMemoryStore) release(key string) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.counts[key] <= 1 {
+		delete(s.counts, key)
+		return
+	}
+	s.counts[key]--
+}
+
+func (s *concurrencyMemoryStore) get(key string) int {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.counts[key]
+}
@@ -0,0 +1,13 @@
+-- KEYS[1] 作为并发计数的key
+-- ARGV[1] 作为并发上限
+-- ARGV[2] 作为过期时间(秒)，防止节点异常退出后计数无法释放
+
+local count = tonumber(redis.call('GET', KEYS[1]) or '0')
+if count >= tonumber(ARGV[1]) then
+  return {0, count}
+end
+
+count = redis.call('INCR', KEYS[1])
+redis.call('EXPIRE', KEYS[1], ARGV[2])
+
+return {1, count}
@@ -0,0 +1,9 @@
+-- KEYS[1] 作为并发计数的key
+
+local count = redis.call('DECR', KEYS[1])
+if count <= 0 then
+  redis.call('DEL', KEYS[1])
+  return 0
+end
+
+return count
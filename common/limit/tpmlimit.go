@@ -0,0 +1,294 @@
+package limit
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"one-api/common/config"
+	"one-api/common/redis"
+	"one-api/common/utils"
+	"sync"
+	"time"
+)
+
+const (
+	tpmWindowFormat = "{%s}:tpm"
+)
+
+var (
+	//go:embed tpmwindow.lua
+	tpmWindowLuaScript string
+	tpmWindowScript    = redis.NewScript(tpmWindowLuaScript)
+
+	//go:embed tpmwindowadjust.lua
+	tpmWindowAdjustLuaScript string
+	tpmWindowAdjustScript    = redis.NewScript(tpmWindowAdjustLuaScript)
+
+	//go:embed tpmwindowget.lua
+	tpmWindowGetLuaScript string
+	tpmWindowGetScript    = redis.NewScript(tpmWindowGetLuaScript)
+)
+
+// TPMResult 一次 TPM 预占的结果
+type TPMResult struct {
+	Allowed bool
+	Limit   int
+	Used    int           // 窗口内已使用的 token 数，允许时包含本次预占
+	Reset   time.Duration // 窗口内最早的用量过期所需的时间
+}
+
+func (r *TPMResult) Remaining() int {
+	return max(r.Limit-r.Used, 0)
+}
+
+// TPMReservation 预占的 token，请求完成后按实际用量修正
+type TPMReservation struct {
+	key    string
+	id     string
+	tokens int
+}
+
+func (r *TPMReservation) member() string {
+	return fmt.Sprintf("%s:%d", r.id, r.tokens)
+}
+
+// TPMLimiter 按滑动窗口统计 token 用量的限流器，先按预估的 token 数预占，完成后修正为实际用量
+type TPMLimiter struct {
+	tpm    int
+	window time.Duration
+}
+
+func NewTPMLimiter(tpm int) *TPMLimiter {
+	return &TPMLimiter{
+		tpm:    tpm,
+		window: window,
+	}
+}
+
+// Reserve 预占 n 个 token，超出限制时不会记录用量
+func (l *TPMLimiter) Reserve(keyPrefix string, n int) (*TPMReservation, *TPMResult, error) {
+	reservation := &TPMReservation{
+		key:    fmt.Sprintf(tpmWindowFormat, keyPrefix),
+		id:     utils.GetUUID(),
+		tokens: n,
+	}
+
+	var result *TPMResult
+	var err error
+	if config.RedisEnabled {
+		result, err = l.reserveRedis(reservation)
+	} else {
+		result = memoryTPMStore.reserve(reservation, l.tpm, l.window)
+	}
+	if err != nil || !result.Allowed {
+		return nil, result, err
+	}
+
+	return reservation, result, nil
+}
+
+func (l *TPMLimiter) reserveRedis(reservation *TPMReservation) (*TPMResult, error) {
+	now := time.Now().UnixMilli()
+	result, err := redis.ScriptRunCtx(
+		context.Background(),
+		tpmWindowScript,
+		[]string{reservation.key},
+		l.tpm,                   // ARGV[1]: TPM限制
+		l.window.Milliseconds(), // ARGV[2]: 窗口大小（毫秒）
+		now,                     // ARGV[3]: 当前时间戳（毫秒）
+		reservation.id,          // ARGV[4]: 预占id
+		reservation.tokens,      // ARGV[5]: 预占的token数
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	resultArray, ok := result.([]interface{})
+	if !ok || len(resultArray) < 3 {
+		return nil, fmt.Errorf("无法转换TPM限流结果")
+	}
+
+	allowed, _ := resultArray[0].(int64)
+	used, _ := resultArray[1].(int64)
+	oldest, _ := resultArray[2].(int64)
+
+	return &TPMResult{
+		Allowed: allowed == 1,
+		Limit:   l.tpm,
+		Used:    int(used),
+		Reset:   time.Duration(oldest+l.window.Milliseconds()-now) * time.Millisecond,
+	}, nil
+}
+
+// Adjust 将预占的 token 数修正为实际用量，传入 0 时退回预占
+func (l *TPMLimiter) Adjust(reservation *TPMReservation, tokens int) error {
+	if reservation == nil || reservation.tokens == tokens {
+		return nil
+	}
+
+	oldMember := reservation.member()
+	reservation.tokens = tokens
+	newMember := ""
+	if tokens > 0 {
+		newMember = reservation.member()
+	}
+
+	if !config.RedisEnabled {
+		memoryTPMStore.adjust(reservation.key, reservation.id, tokens)
+		return nil
+	}
+
+	_, err := redis.ScriptRunCtx(
+		context.Background(),
+		tpmWindowAdjustScript,
+		[]string{reservation.key},
+		oldMember, // ARGV[1]: 预占时的成员
+		newMember, // ARGV[2]: 修正后的成员
+	)
+
+	return err
+}
+
+// GetCurrentRate 获取窗口内已使用的 token 数
+func (l *TPMLimiter) GetCurrentRate(keyPrefix string) (int, error) {
+	key := fmt.Sprintf(tpmWindowFormat, keyPrefix)
+	if !config.RedisEnabled {
+		return memoryTPMStore.used(key, l.window), nil
+	}
+
+	result, err := redis.ScriptRunCtx(
+		context.Background(),
+		tpmWindowGetScript,
+		[]string{key},
+		l.window.Milliseconds(), // ARGV[1]: 窗口大小（毫秒）
+		time.Now().UnixMilli(),  // ARGV[2]: 当前时间戳（毫秒）
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	used, ok := result.(int64)
+	if !ok {
+		return 0, fmt.Errorf("无法转换TPM计数结果")
+	}
+
+	return int(used), nil
+}
+
+func (l *TPMLimiter) GetLimit() int {
+	return l.tpm
+}
+
+type tpmEntry struct {
+	id     string
+	tokens int
+	at     time.Time
+}
+
+// tpmMemoryStore 未开启 Redis 时在内存中记录 token 用量
+type tpmMemoryStore struct {
+	sync.Mutex
+	entries map[string][]*tpmEntry
+}
+
+var memoryTPMStore = newTPMMemoryStore()
+
+func newTPMMemoryStore() *tpmMemoryStore {
+	store := &tpmMemoryStore{
+		entries: make(map[string][]*tpmEntry),
+	}
+
+	go func() {
+		ticker := time.NewTicker(5 * time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			store.cleanup()
+		}
+	}()
+
+	return store
+}
+
+// prune 移除窗口外的记录，调用方需持有锁
+func (s *tpmMemoryStore) prune(key string, window time.Duration, now time.Time) []*tpmEntry {
+	entries := s.entries[key]
+	start := 0
+	for start < len(entries) && now.Sub(entries[start].at) >= window {
+		start++
+	}
+	entries = entries[start:]
+	s.entries[key] = entries
+
+	return entries
+}
+
+func (s *tpmMemoryStore) reserve(reservation *TPMReservation, tpm int, window time.Duration) *TPMResult {
+	s.Lock()
+	defer s.Unlock()
+
+	now := time.Now()
+	entries := s.prune(reservation.key, window, now)
+
+	used := 0
+	for _, entry := range entries {
+		used += entry.tokens
+	}
+
+	result := &TPMResult{
+		Limit: tpm,
+		Used:  used,
+	}
+	if len(entries) > 0 {
+		result.Reset = window - now.Sub(entries[0].at)
+	}
+
+	if used+reservation.tokens > tpm {
+		return result
+	}
+
+	s.entries[reservation.key] = append(entries, &tpmEntry{
+		id:     reservation.id,
+		tokens: reservation.tokens,
+		at:     now,
+	})
+	result.Allowed = true
+	result.Used += reservation.tokens
+
+	return result
+}
+
+func (s *tpmMemoryStore) adjust(key, id string, tokens int) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, entry := range s.entries[key] {
+		if entry.id == id {
+			entry.tokens = tokens
+			return
+		}
+	}
+}
+
+func (s *tpmMemoryStore) used(key string, window time.Duration) int {
+	s.Lock()
+	defer s.Unlock()
+
+	used := 0
+	for _, entry := range s.prune(key, window, time.Now()) {
+		used += entry.tokens
+	}
+
+	return used
+}
+
+func (s *tpmMemoryStore) cleanup() {
+	s.Lock()
+	defer s.Unlock()
+
+	now := time.Now()
+	for key := range s.entries {
+		if len(s.prune(key, window, now)) == 0 {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package limit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTPMLimiterReserve(t *testing.T) {
+	tests := []struct {
+		name        string
+		tpm         int
+		reserved    []int
+		tokens      int
+		wantAllowed bool
+		wantUsed    int
+	}{
+		{
+			name:        "reserves within the limit",
+			tpm:         1000,
+			tokens:      400,
+			wantAllowed: true,
+			wantUsed:    400,
+		},
+		{
+			name:        "reserves up to the limit",
+			tpm:         1000,
+			reserved:    []int{600},
+			tokens:      400,
+			wantAllowed: true,
+			wantUsed:    1000,
+		},
+		{
+			name:        "rejects over the limit without recording usage",
+			tpm:         1000,
+			reserved:    []int{600, 300},
+			tokens:      200,
+			wantAllowed: false,
+			wantUsed:    900,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewTPMLimiter(tt.tpm)
+			key := "test:" + t.Name()
+
+			for _, tokens := range tt.reserved {
+				_, result, err := limiter.Reserve(key, tokens)
+				require.NoError(t, err)
+				require.True(t, result.Allowed)
+			}
+
+			reservation, result, err := limiter.Reserve(key, tt.tokens)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAllowed, result.Allowed)
+			assert.Equal(t, tt.wantUsed, result.Used)
+			assert.Equal(t, tt.wantAllowed, reservation != nil)
+
+			used, err := limiter.GetCurrentRate(key)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantUsed, used)
+		})
+	}
+}
+
+func TestTPMLimiterAdjust(t *testing.T) {
+	tests := []struct {
+		name     string
+		reserved int
+		actual   int
+		wantUsed int
+	}{
+		{name: "actual usage above the estimate", reserved: 300, actual: 500, wantUsed: 500},
+		{name: "actual usage below the estimate", reserved: 300, actual: 100, wantUsed: 100},
+		{name: "release returns the reservation", reserved: 300, actual: 0, wantUsed: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewTPMLimiter(1000)
+			key := "test:" + t.Name()
+
+			reservation, result, err := limiter.Reserve(key, tt.reserved)
+			require.NoError(t, err)
+			require.True(t, result.Allowed)
+
+			require.NoError(t, limiter.Adjust(reservation, tt.actual))
+			used, err := limiter.GetCurrentRate(key)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantUsed, used)
+
+			// 修正后的额度对之后的预占生效
+			_, result, err = limiter.Reserve(key, 1000-tt.wantUsed)
+			require.NoError(t, err)
+			assert.True(t, result.Allowed)
+		})
+	}
+}
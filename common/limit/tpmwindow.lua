@@ -0,0 +1,35 @@
+-- KEYS[1] 作为存储 token 用量的有序集合key，成员为 "预占id:token数"，分数为时间戳(毫秒)
+-- ARGV[1] 作为TPM限制
+-- ARGV[2] 作为窗口大小(毫秒)
+-- ARGV[3] 作为当前时间戳(毫秒)
+-- ARGV[4] 作为预占id
+-- ARGV[5] 作为预占的token数
+
+local now = tonumber(ARGV[3])
+local window = tonumber(ARGV[2])
+local tokens = tonumber(ARGV[5])
+
+-- 1. 移除窗口外的记录
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+
+-- 2. 统计窗口内已使用的token数，以及最早一条记录的时间
+local used = 0
+local oldest = now
+local members = redis.call('ZRANGE', KEYS[1], 0, -1, 'WITHSCORES')
+for i = 1, #members, 2 do
+  used = used + tonumber(string.match(members[i], ':(%d+)$'))
+  if i == 1 then
+    oldest = tonumber(members[i + 1])
+  end
+end
+
+-- 3. 判断是否允许请求
+if used + tokens > tonumber(ARGV[1]) then
+  return {0, used, oldest}
+end
+
+-- 4. 记录本次预占，并设置过期时间（窗口大小的2倍，确保不会提前删除）
+redis.call('ZADD', KEYS[1], now, ARGV[4] .. ':' .. tokens)
+redis.call('PEXPIRE', KEYS[1], window * 2)
+
+return {1, used + tokens, oldest}
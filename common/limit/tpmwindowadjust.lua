@@ -0,0 +1,16 @@
+-- KEYS[1] 作为存储 token 用量的有序集合key
+-- ARGV[1] 作为预占时的成员
+-- ARGV[2] 作为修正后的成员，为空时删除预占
+
+-- 保留原来的时间戳，只修正token数
+local score = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if score == false then
+  return 0
+end
+
+redis.call('ZREM', KEYS[1], ARGV[1])
+if ARGV[2] ~= '' then
+  redis.call('ZADD', KEYS[1], score, ARGV[2])
+end
+
+return 1
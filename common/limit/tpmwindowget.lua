@@ -0,0 +1,17 @@
+-- KEYS[1] 作为存储 token 用量的有序集合key
+-- ARGV[1] 作为窗口大小(毫秒)
+-- ARGV[2] 作为当前时间戳(毫秒)
+
+local now = tonumber(ARGV[2])
+
+-- 1. 移除窗口外的记录
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - tonumber(ARGV[1]))
+
+-- 2. 返回窗口内已使用的token数
+local used = 0
+local members = redis.call('ZRANGE', KEYS[1], 0, -1)
+for i = 1, #members do
+  used = used + tonumber(string.match(members[i], ':(%d+)$'))
+end
+
+return used
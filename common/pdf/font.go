@@ -0,0 +1,342 @@
+package pdf
+
+import (
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// 文泉驿微米黑（Apache License 2.0 / GPLv3 双许可），集合中的第二个字体为等宽版本，
+// 拉丁字符半角、中文全角，账单中按空格对齐的列不会错位
+//
+//go:embed fonts/wqy-microhei.ttc
+var fontCollection []byte
+
+const (
+	fontIndex = 1
+	fontName  = "WenQuanYiMicroHeiMono"
+)
+
+var (
+	loadFontOnce sync.Once
+	embedFont    *trueTypeFont
+	loadFontErr  error
+)
+
+func getFont() (*trueTypeFont, error) {
+	loadFontOnce.Do(func() {
+		embedFont, loadFontErr = parseTrueTypeCollection(fontCollection, fontIndex)
+	})
+	return embedFont, loadFontErr
+}
+
+var errInvalidFont = errors.New("invalid TrueType font")
+
+type cmapSegment struct {
+	start, end rune
+	delta      int
+	glyphs     []uint16 // format 4 使用 idRangeOffset 时的字形表，format 12 为空
+}
+
+type trueTypeFont struct {
+	tables      map[string][]byte
+	unitsPerEm  int
+	longLoca    bool
+	numGlyphs   int
+	numHMetrics int
+	segments    []cmapSegment
+}
+
+func u16(b []byte, offset int) int {
+	return int(binary.BigEndian.Uint16(b[offset:]))
+}
+
+func u32(b []byte, offset int) int {
+	return int(binary.BigEndian.Uint32(b[offset:]))
+}
+
+func parseTrueTypeCollection(data []byte, index int) (font *trueTypeFont, err error) {
+	// 字体数据是内嵌的，越界只会出现在文件损坏时
+	defer func() {
+		if recover() != nil {
+			font, err = nil, errInvalidFont
+		}
+	}()
+
+	offset := 0
+	if string(data[:4]) == "ttcf" {
+		if index >= u32(data, 8) {
+			return nil, errInvalidFont
+		}
+		offset = u32(data, 12+4*index)
+	}
+
+	font = &trueTypeFont{tables: make(map[string][]byte)}
+	numTables := u16(data, offset+4)
+	for i := 0; i < numTables; i++ {
+		record := offset + 12 + 16*i
+		tableOffset := u32(data, record+8)
+		font.tables[string(data[record:record+4])] = data[tableOffset : tableOffset+u32(data, record+12)]
+	}
+
+	for _, tag := range []string{"head", "hhea", "maxp", "hmtx", "loca", "glyf", "cmap", "post"} {
+		if _, ok := font.tables[tag]; !ok {
+			return nil, fmt.Errorf("%w: missing %s table", errInvalidFont, tag)
+		}
+	}
+
+	font.unitsPerEm = u16(font.tables["head"], 18)
+	font.longLoca = u16(font.tables["head"], 50) == 1
+	font.numGlyphs = u16(font.tables["maxp"], 4)
+	font.numHMetrics = u16(font.tables["hhea"], 34)
+	if err := font.parseCmap(); err != nil {
+		return nil, err
+	}
+
+	return font, nil
+}
+
+// parseCmap 读取 Unicode 字符映射，优先使用支持完整 Unicode 的 format 12
+func (f *trueTypeFont) parseCmap() error {
+	cmap := f.tables["cmap"]
+	format4, format12 := -1, -1
+	for i := 0; i < u16(cmap, 2); i++ {
+		record := 4 + 8*i
+		platform, encoding, offset := u16(cmap, record), u16(cmap, record+2), u32(cmap, record+4)
+		if platform != 0 && platform != 3 {
+			continue
+		}
+		switch u16(cmap, offset) {
+		case 4:
+			if encoding <= 1 || platform == 0 {
+				format4 = offset
+			}
+		case 12:
+			format12 = offset
+		}
+	}
+
+	switch {
+	case format12 >= 0:
+		for i := 0; i < u32(cmap, format12+12); i++ {
+			group := format12 + 16 + 12*i
+			start := u32(cmap, group)
+			f.segments = append(f.segments, cmapSegment{
+				start: rune(start),
+				end:   rune(u32(cmap, group+4)),
+				delta: u32(cmap, group+8) - start,
+			})
+		}
+	case format4 >= 0:
+		segCount := u16(cmap, format4+6) / 2
+		endCodes := format4 + 14
+		startCodes := endCodes + 2*segCount + 2
+		deltas := startCodes + 2*segCount
+		rangeOffsets := deltas + 2*segCount
+		for i := 0; i < segCount; i++ {
+			segment := cmapSegment{
+				start: rune(u16(cmap, startCodes+2*i)),
+				end:   rune(u16(cmap, endCodes+2*i)),
+				delta: int(int16(u16(cmap, deltas+2*i))),
+			}
+			if rangeOffset := u16(cmap, rangeOffsets+2*i); rangeOffset != 0 {
+				glyphs := rangeOffsets + 2*i + rangeOffset
+				for c := segment.start; c <= segment.end; c++ {
+					glyph := u16(cmap, glyphs+2*int(c-segment.start))
+					if glyph != 0 {
+						glyph = (glyph + segment.delta) & 0xFFFF
+					}
+					segment.glyphs = append(segment.glyphs, uint16(glyph))
+				}
+			}
+			f.segments = append(f.segments, segment)
+		}
+	default:
+		return fmt.Errorf("%w: no unicode cmap", errInvalidFont)
+	}
+
+	sort.Slice(f.segments, func(i, j int) bool { return f.segments[i].start < f.segments[j].start })
+	return nil
+}
+
+// glyphIndex 字体中没有的字符返回 0，即 .notdef
+func (f *trueTypeFont) glyphIndex(r rune) uint16 {
+	i := sort.Search(len(f.segments), func(i int) bool { return f.segments[i].end >= r })
+	if i == len(f.segments) || f.segments[i].start > r {
+		return 0
+	}
+
+	segment := f.segments[i]
+	if segment.glyphs != nil {
+		return segment.glyphs[r-segment.start]
+	}
+	glyph := int(r) + segment.delta
+	if segment.end <= 0xFFFF {
+		glyph &= 0xFFFF
+	}
+	if glyph < 0 || glyph >= f.numGlyphs {
+		return 0
+	}
+	return uint16(glyph)
+}
+
+// advance 字形宽度，单位为 1/1000 字号
+func (f *trueTypeFont) advance(glyph uint16) int {
+	hmtx := f.tables["hmtx"]
+	index := int(glyph)
+	if index >= f.numHMetrics {
+		index = f.numHMetrics - 1
+	}
+	return u16(hmtx, 4*index) * 1000 / f.unitsPerEm
+}
+
+func (f *trueTypeFont) scale(value int16) int {
+	return int(value) * 1000 / f.unitsPerEm
+}
+
+func (f *trueTypeFont) glyphData(glyph uint16) []byte {
+	loca := f.tables["loca"]
+	var start, end int
+	if f.longLoca {
+		start, end = u32(loca, 4*int(glyph)), u32(loca, 4*int(glyph)+4)
+	} else {
+		start, end = 2*u16(loca, 2*int(glyph)), 2*u16(loca, 2*int(glyph)+2)
+	}
+	return f.tables["glyf"][start:end]
+}
+
+// compositeComponents 复合字形引用的其他字形
+func compositeComponents(data []byte) []uint16 {
+	if len(data) < 10 || int16(u16(data, 0)) >= 0 {
+		return nil
+	}
+
+	var components []uint16
+	for offset := 10; offset+4 <= len(data); {
+		flags := u16(data, offset)
+		components = append(components, uint16(u16(data, offset+2)))
+		offset += 4
+		if flags&0x0001 != 0 {
+			offset += 4
+		} else {
+			offset += 2
+		}
+		switch {
+		case flags&0x0008 != 0:
+			offset += 2
+		case flags&0x0040 != 0:
+			offset += 4
+		case flags&0x0080 != 0:
+			offset += 8
+		}
+		if flags&0x0020 == 0 {
+			break
+		}
+	}
+	return components
+}
+
+// subset 生成只保留使用到的字形的字体，字形编号保持不变，PDF 中可以直接使用字形编号作为 CID
+func (f *trueTypeFont) subset(glyphs []uint16) []byte {
+	used := make(map[uint16]bool, len(glyphs)+1)
+	pending := append([]uint16{0}, glyphs...)
+	for len(pending) > 0 {
+		glyph := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		used[glyph] = true
+		for _, component := range compositeComponents(f.glyphData(glyph)) {
+			if !used[component] && int(component) < f.numGlyphs {
+				pending = append(pending, component)
+			}
+		}
+	}
+
+	var glyf []byte
+	loca := make([]byte, 4*(f.numGlyphs+1))
+	for glyph := 0; glyph < f.numGlyphs; glyph++ {
+		binary.BigEndian.PutUint32(loca[4*glyph:], uint32(len(glyf)))
+		if used[uint16(glyph)] {
+			glyf = append(glyf, f.glyphData(uint16(glyph))...)
+			for len(glyf)%4 != 0 {
+				glyf = append(glyf, 0)
+			}
+		}
+	}
+	binary.BigEndian.PutUint32(loca[4*f.numGlyphs:], uint32(len(glyf)))
+
+	// 子集统一使用长格式的 loca
+	head := append([]byte(nil), f.tables["head"]...)
+	binary.BigEndian.PutUint32(head[8:], 0)
+	binary.BigEndian.PutUint16(head[50:], 1)
+
+	// 不需要字形名称，使用 3.0 版本的 post 表
+	post := append([]byte(nil), f.tables["post"][:32]...)
+	binary.BigEndian.PutUint32(post, 0x00030000)
+
+	tables := map[string][]byte{
+		"head": head,
+		"post": post,
+		"hhea": f.tables["hhea"],
+		"maxp": f.tables["maxp"],
+		"hmtx": f.tables["hmtx"],
+		"loca": loca,
+		"glyf": glyf,
+	}
+	for _, tag := range []string{"cmap", "OS/2", "name", "cvt ", "fpgm", "prep"} {
+		if table, ok := f.tables[tag]; ok {
+			tables[tag] = table
+		}
+	}
+
+	return writeTrueType(tables)
+}
+
+func writeTrueType(tables map[string][]byte) []byte {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	searchRange, entrySelector := 1, 0
+	for searchRange*2 <= len(tags) {
+		searchRange *= 2
+		entrySelector++
+	}
+
+	header := make([]byte, 12+16*len(tags))
+	binary.BigEndian.PutUint32(header, 0x00010000)
+	binary.BigEndian.PutUint16(header[4:], uint16(len(tags)))
+	binary.BigEndian.PutUint16(header[6:], uint16(searchRange*16))
+	binary.BigEndian.PutUint16(header[8:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(header[10:], uint16(len(tags)*16-searchRange*16))
+
+	body := make([]byte, 0)
+	for i, tag := range tags {
+		table := tables[tag]
+		record := header[12+16*i:]
+		copy(record, tag)
+		binary.BigEndian.PutUint32(record[4:], tableChecksum(table))
+		binary.BigEndian.PutUint32(record[8:], uint32(len(header)+len(body)))
+		binary.BigEndian.PutUint32(record[12:], uint32(len(table)))
+		body = append(body, table...)
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+	}
+
+	return append(header, body...)
+}
+
+func tableChecksum(table []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(table); i += 4 {
+		var word [4]byte
+		copy(word[:], table[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
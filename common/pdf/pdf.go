@@ -0,0 +1,183 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// 生成只包含文本的 A4 文档，内嵌文泉驿等宽微米黑的子集，支持中文等非 ASCII 字符
+const (
+	pageWidth    = 595
+	pageHeight   = 842
+	margin       = 40
+	fontSize     = 9
+	lineHeight   = 12
+	linesPerPage = (pageHeight - 2*margin) / lineHeight
+)
+
+// RenderText 按行渲染文本，超出一页时自动分页
+func RenderText(lines []string) ([]byte, error) {
+	font, err := getFont()
+	if err != nil {
+		return nil, err
+	}
+
+	var pages [][]string
+	for start := 0; start < len(lines); start += linesPerPage {
+		end := start + linesPerPage
+		if end > len(lines) {
+			end = len(lines)
+		}
+		pages = append(pages, lines[start:end])
+	}
+	if len(pages) == 0 {
+		pages = append(pages, nil)
+	}
+
+	// 记录用到的字形，用于生成字体子集、字宽和 ToUnicode 映射
+	glyphRunes := make(map[uint16]rune)
+	for _, line := range lines {
+		for _, r := range normalizeLine(line) {
+			glyph := font.glyphIndex(r)
+			if _, ok := glyphRunes[glyph]; !ok {
+				glyphRunes[glyph] = r
+			}
+		}
+	}
+	delete(glyphRunes, 0)
+	glyphs := make([]uint16, 0, len(glyphRunes))
+	for glyph := range glyphRunes {
+		glyphs = append(glyphs, glyph)
+	}
+	sort.Slice(glyphs, func(i, j int) bool { return glyphs[i] < glyphs[j] })
+
+	var buf bytes.Buffer
+	var offsets []int
+	writeObject := func(content string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), content)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+
+	// 1 目录，2 页面树，3-7 字体，之后每页依次为页面和内容流
+	kids := make([]string, 0, len(pages))
+	for i := range pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 8+i*2))
+	}
+	writeObject("<< /Type /Catalog /Pages 2 0 R >>")
+	writeObject(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+
+	baseFont := subsetTag(glyphs) + "+" + fontName
+	writeObject(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [4 0 R] /ToUnicode 7 0 R >>", baseFont))
+	writeObject(fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor 5 0 R /DW 1000 /W [%s] /CIDToGIDMap /Identity >>", baseFont, glyphWidths(font, glyphs)))
+
+	head, hhea := font.tables["head"], font.tables["hhea"]
+	ascent, descent := font.scale(int16(u16(hhea, 4))), font.scale(int16(u16(hhea, 6)))
+	writeObject(fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 4 /FontBBox [%d %d %d %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 6 0 R >>",
+		baseFont, font.scale(int16(u16(head, 36))), font.scale(int16(u16(head, 38))), font.scale(int16(u16(head, 40))), font.scale(int16(u16(head, 42))), ascent, descent, ascent))
+
+	fontFile := font.subset(glyphs)
+	var compressed bytes.Buffer
+	writer := zlib.NewWriter(&compressed)
+	writer.Write(fontFile)
+	writer.Close()
+	writeObject(fmt.Sprintf("<< /Length %d /Length1 %d /Filter /FlateDecode >>\nstream\n%s\nendstream", compressed.Len(), len(fontFile), compressed.String()))
+
+	toUnicode := toUnicodeCMap(glyphs, glyphRunes)
+	writeObject(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(toUnicode), toUnicode))
+
+	for i, pageLines := range pages {
+		writeObject(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", pageWidth, pageHeight, 9+i*2))
+
+		var stream strings.Builder
+		fmt.Fprintf(&stream, "BT\n/F1 %d Tf\n%d TL\n%d %d Td\n", fontSize, lineHeight, margin, pageHeight-margin)
+		for _, line := range pageLines {
+			stream.WriteByte('<')
+			for _, r := range normalizeLine(line) {
+				fmt.Fprintf(&stream, "%04X", font.glyphIndex(r))
+			}
+			stream.WriteString("> Tj T*\n")
+		}
+		stream.WriteString("ET")
+		writeObject(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", stream.Len(), stream.String()))
+	}
+
+	xrefOffset := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xrefOffset)
+
+	return buf.Bytes(), nil
+}
+
+// normalizeLine 控制字符显示为空格
+func normalizeLine(line string) []rune {
+	runes := []rune(line)
+	for i, r := range runes {
+		if r < 32 || r == 127 {
+			runes[i] = ' '
+		}
+	}
+	return runes
+}
+
+// subsetTag 字体子集名称的前缀，由 6 个大写字母组成，不同子集使用不同的前缀
+func subsetTag(glyphs []uint16) string {
+	hash := fnv.New32a()
+	for _, glyph := range glyphs {
+		hash.Write([]byte{byte(glyph >> 8), byte(glyph)})
+	}
+	sum := hash.Sum32()
+
+	tag := make([]byte, 6)
+	for i := range tag {
+		tag[i] = 'A' + byte(sum%26)
+		sum /= 26
+	}
+	return string(tag)
+}
+
+func glyphWidths(font *trueTypeFont, glyphs []uint16) string {
+	widths := make([]string, 0, len(glyphs))
+	for _, glyph := range glyphs {
+		widths = append(widths, fmt.Sprintf("%d [%d]", glyph, font.advance(glyph)))
+	}
+	return strings.Join(widths, " ")
+}
+
+// toUnicodeCMap 字形到 Unicode 的映射，用于复制和搜索文本
+func toUnicodeCMap(glyphs []uint16, glyphRunes map[uint16]rune) string {
+	var sb strings.Builder
+	sb.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	sb.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	sb.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+	sb.WriteString("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+
+	// 每段最多 100 项
+	for start := 0; start < len(glyphs); start += 100 {
+		end := start + 100
+		if end > len(glyphs) {
+			end = len(glyphs)
+		}
+		fmt.Fprintf(&sb, "%d beginbfchar\n", end-start)
+		for _, glyph := range glyphs[start:end] {
+			fmt.Fprintf(&sb, "<%04X> <", glyph)
+			for _, unit := range utf16.Encode([]rune{glyphRunes[glyph]}) {
+				fmt.Fprintf(&sb, "%04X", unit)
+			}
+			sb.WriteString(">\n")
+		}
+		sb.WriteString("endbfchar\n")
+	}
+
+	sb.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend")
+	return sb.String()
+}
@@ -0,0 +1,73 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderTextEmbedsCJKFont(t *testing.T) {
+	data, err := RenderText([]string{"Account:      张三", "Model        账单 (a\\b)"})
+	require.NoError(t, err)
+
+	font, err := getFont()
+	require.NoError(t, err)
+	glyph := font.glyphIndex('张')
+	require.NotZero(t, glyph, "embedded font must cover CJK characters")
+
+	assert.Contains(t, string(data), "/Encoding /Identity-H")
+	assert.Contains(t, string(data), "/FontFile2 6 0 R")
+	// 文本按字形编号输出，ToUnicode 映射回原字符
+	assert.Contains(t, string(data), fmt.Sprintf("%04X", glyph))
+	assert.Contains(t, string(data), fmt.Sprintf("<%04X> <5F20>", glyph))
+
+	// 等宽字体中拉丁字符为半角，中文为全角
+	assert.Equal(t, 600, font.advance(font.glyphIndex('A')))
+	assert.Equal(t, 1000, font.advance(glyph))
+
+	// 内嵌的子集只保留使用到的字形
+	match := regexp.MustCompile(`/Length (\d+) /Length1 (\d+) /Filter /FlateDecode >>\nstream\n`).FindSubmatchIndex(data)
+	require.NotNil(t, match)
+	length, _ := strconv.Atoi(string(data[match[2]:match[3]]))
+	reader, err := zlib.NewReader(bytes.NewReader(data[match[1] : match[1]+length]))
+	require.NoError(t, err)
+	fontFile, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	length1, _ := strconv.Atoi(string(data[match[4]:match[5]]))
+	assert.Len(t, fontFile, length1)
+
+	subset, err := parseTrueTypeCollection(fontFile, 0)
+	require.NoError(t, err)
+	assert.Equal(t, glyph, subset.glyphIndex('张'))
+	assert.NotEmpty(t, subset.glyphData(glyph))
+	assert.Empty(t, subset.glyphData(font.glyphIndex('李')))
+}
+
+func TestRenderTextLayout(t *testing.T) {
+	lines := make([]string, linesPerPage*2+1)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d", i)
+	}
+
+	data, err := RenderText(lines)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "/Count 3")
+
+	// 交叉引用表中的偏移指向对应的对象
+	startxref := regexp.MustCompile(`startxref\n(\d+)`).FindSubmatch(data)
+	require.NotNil(t, startxref)
+	xrefOffset, _ := strconv.Atoi(string(startxref[1]))
+	offsets := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(data[xrefOffset:], -1)
+	require.Len(t, offsets, 7+3*2)
+	for i, offset := range offsets {
+		position, _ := strconv.Atoi(string(offset[1]))
+		assert.True(t, bytes.HasPrefix(data[position:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))), "object %d", i+1)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"one-api/common/config"
 	"one-api/common/utils"
 	"strings"
+	"time"
 
 	"github.com/wneessen/go-mail"
 )
@@ -177,6 +178,35 @@ func SendQuotaWarningCodeEmail(userName, email string, quota int, noMoreQuota bo
 	return stmp.Render(email, subject, content)
 }
 
+func SendSubscriptionRenewalEmail(userName, email, planName string, expireTime int64) error {
+	stmp, err := GetSystemStmp()
+
+	if err != nil {
+		return err
+	}
+
+	contentTemp := `<p style="font-size: 30px">Hi <strong>%s,</strong></p>
+		<p>
+			您订阅的套餐 %s 将于 %s 到期，为了不影响您的使用，请及时续费。
+		</p>
+		
+		<p style="text-align: center; font-size: 13px;">
+			<a target="__blank" href="%s" class="button" style="color: #ffffff;">点击续费</a>
+		</p>
+		
+		<p style="color: #858585; padding-top: 15px;">
+			如果链接无法点击，请尝试点击下面的链接或将其复制到浏览器中打开<br> %s
+		</p>`
+
+	subject := "您的订阅即将到期"
+	renewLink := fmt.Sprintf("%s/topup", config.ServerAddress)
+	expireAt := time.Unix(expireTime, 0).Format("2006-01-02 15:04:05")
+
+	content := fmt.Sprintf(contentTemp, userName, planName, expireAt, renewLink, renewLink)
+
+	return stmp.Render(email, subject, content)
+}
+
 func DialAndSend(c *mail.Client, messages ...*mail.Msg) error {
 	ctx := context.Background()
 	if err := c.DialWithContext(ctx); err != nil {
@@ -0,0 +1,77 @@
+package drives
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalDrive 本地文件系统存储，仅用于保存网关自身管理的文件，不提供公网访问地址
+type LocalDrive struct {
+	Path string
+}
+
+func NewLocalDrive(path string) *LocalDrive {
+	return &LocalDrive{
+		Path: path,
+	}
+}
+
+func (l *LocalDrive) Name() string {
+	return "Local"
+}
+
+func (l *LocalDrive) filePath(key string) (string, error) {
+	cleaned := filepath.Clean("/" + key)
+	if cleaned == "/" || strings.Contains(key, "..") {
+		return "", fmt.Errorf("invalid file key: %s", key)
+	}
+
+	return filepath.Join(l.Path, cleaned), nil
+}
+
+func (l *LocalDrive) Put(key string, data []byte) error {
+	path, err := l.filePath(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating directory: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("writing file: %w", err)
+	}
+
+	return nil
+}
+
+func (l *LocalDrive) Get(key string) ([]byte, error) {
+	path, err := l.filePath(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading file: %w", err)
+	}
+
+	return data, nil
+}
+
+func (l *LocalDrive) Delete(key string) error {
+	path, err := l.filePath(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("deleting file: %w", err)
+	}
+
+	return nil
+}
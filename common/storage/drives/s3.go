@@ -3,6 +3,7 @@ package drives
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -39,6 +40,78 @@ func (a *S3Upload) Name() string {
 	return "S3"
 }
 
+func (a *S3Upload) newClient() (*s3.S3, error) {
+	sess, err := session.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentials(
+			a.AccessKeyId,
+			a.AccessKeySecret,
+			"",
+		),
+		Endpoint:         aws.String(a.EndPoint),
+		Region:           aws.String("auto"),
+		S3ForcePathStyle: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %v", err)
+	}
+
+	return s3.New(sess), nil
+}
+
+// Put 按原始 key 保存文件，不添加日期前缀，也不设置过期时间
+func (a *S3Upload) Put(key string, data []byte) error {
+	svc, err := a.newClient()
+	if err != nil {
+		return err
+	}
+
+	_, err = svc.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(a.BucketName),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload file to S3: %v", err)
+	}
+
+	return nil
+}
+
+func (a *S3Upload) Get(key string) ([]byte, error) {
+	svc, err := a.newClient()
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(a.BucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file from S3: %v", err)
+	}
+	defer output.Body.Close()
+
+	return io.ReadAll(output.Body)
+}
+
+func (a *S3Upload) Delete(key string) error {
+	svc, err := a.newClient()
+	if err != nil {
+		return err
+	}
+
+	_, err = svc.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(a.BucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete file from S3: %v", err)
+	}
+
+	return nil
+}
+
 func (a *S3Upload) Upload(data []byte, s3Key string) (string, error) {
 
 	// 创建 S3 会话
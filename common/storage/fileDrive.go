@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+var ErrFileDriveNotFound = errors.New("no file storage drive configured")
+
+// FileDrive 可读写的存储驱动，用于保存网关自身管理的文件（批处理输入输出等）
+type FileDrive interface {
+	Name() string
+	Put(key string, data []byte) error
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+}
+
+type fileStorage struct {
+	sync.RWMutex
+	drives  map[string]FileDrive
+	current string
+}
+
+var fileDrives = &fileStorage{
+	drives: make(map[string]FileDrive),
+}
+
+func AddFileDrive(drives ...FileDrive) {
+	fileDrives.Lock()
+	defer fileDrives.Unlock()
+
+	for _, drive := range drives {
+		if drive == nil {
+			continue
+		}
+		driveName := drive.Name()
+		if _, ok := fileDrives.drives[driveName]; ok {
+			continue
+		}
+		fileDrives.drives[driveName] = drive
+
+		// 默认使用第一个注册的驱动，可通过 storage.file_drive 指定
+		if fileDrives.current == "" || driveName == viper.GetString("storage.file_drive") {
+			fileDrives.current = driveName
+		}
+	}
+}
+
+func getFileDrive(driveName string) (FileDrive, error) {
+	fileDrives.RLock()
+	defer fileDrives.RUnlock()
+
+	if driveName == "" {
+		driveName = fileDrives.current
+	}
+
+	drive, ok := fileDrives.drives[driveName]
+	if !ok {
+		if driveName == "" {
+			return nil, ErrFileDriveNotFound
+		}
+		return nil, fmt.Errorf("file storage drive %s not found", driveName)
+	}
+
+	return drive, nil
+}
+
+// SaveFile 使用当前驱动保存文件，返回驱动名称，读取和删除时需要使用同一驱动
+func SaveFile(key string, data []byte) (string, error) {
+	drive, err := getFileDrive("")
+	if err != nil {
+		return "", err
+	}
+
+	if err := drive.Put(key, data); err != nil {
+		return "", err
+	}
+
+	return drive.Name(), nil
+}
+
+func ReadFile(driveName, key string) ([]byte, error) {
+	drive, err := getFileDrive(driveName)
+	if err != nil {
+		return nil, err
+	}
+
+	return drive.Get(key)
+}
+
+func RemoveFile(driveName, key string) error {
+	drive, err := getFileDrive(driveName)
+	if err != nil {
+		return err
+	}
+
+	return drive.Delete(key)
+}
@@ -15,6 +15,17 @@ func InitStorage() {
 	InitSMStorage()
 	InitALIOSSStorage()
 	InitS3Storage()
+	InitLocalStorage()
+}
+
+// InitLocalStorage 本地存储始终可用，未配置其他驱动时作为文件存储的默认驱动
+func InitLocalStorage() {
+	path := viper.GetString("storage.local.path")
+	if path == "" {
+		return
+	}
+
+	AddFileDrive(drives.NewLocalDrive(path))
 }
 
 func InitALIOSSStorage() {
@@ -38,6 +49,7 @@ func InitALIOSSStorage() {
 
 	aliUpload := drives.NewAliOSSUpload(endpoint, accessKeyId, accessKeySecret, bucketName)
 	AddStorageDrive(aliUpload)
+	AddFileDrive(aliUpload)
 }
 
 func InitSMStorage() {
@@ -86,4 +98,5 @@ func InitS3Storage() {
 
 	s3Upload := drives.NewS3Upload(endpoint, accessKeyId, accessKeySecret, bucketName, cdnurl, expirationDays)
 	AddStorageDrive(s3Upload)
+	AddFileDrive(s3Upload)
 }
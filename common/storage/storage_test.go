@@ -71,3 +71,24 @@ func TestImgurUpload(t *testing.T) {
 	fmt.Println(err)
 	assert.Nil(t, err)
 }
+
+func TestLocalDrive(t *testing.T) {
+	localDrive := drives.NewLocalDrive(t.TempDir())
+
+	data := []byte(`{"custom_id":"request-1"}`)
+	err := localDrive.Put("files/1/file-test", data)
+	assert.Nil(t, err)
+
+	content, err := localDrive.Get("files/1/file-test")
+	assert.Nil(t, err)
+	assert.Equal(t, data, content)
+
+	err = localDrive.Put("../escape", data)
+	assert.NotNil(t, err)
+
+	err = localDrive.Delete("files/1/file-test")
+	assert.Nil(t, err)
+
+	_, err = localDrive.Get("files/1/file-test")
+	assert.NotNil(t, err)
+}
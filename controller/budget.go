@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BudgetResponse struct {
+	Object string                `json:"object"`
+	Data   []*model.BudgetStatus `json:"data"`
+}
+
+// GetBudget 使用令牌查询当前令牌和所属用户各周期的剩余预算
+func GetBudget(c *gin.Context) {
+	userId := c.GetInt("id")
+	token, err := model.GetTokenById(c.GetInt("token_id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, fmt.Errorf("获取信息失败: %v", err))
+		return
+	}
+
+	userBudget, err := model.CacheGetUserBudget(userId)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, fmt.Errorf("获取信息失败: %v", err))
+		return
+	}
+
+	tokenBudget := token.Setting.Data().Limits.BudgetSetting
+	rules := model.GetBudgetRules(userId, userBudget, token.Id, &tokenBudget, "")
+
+	c.JSON(http.StatusOK, BudgetResponse{
+		Object: "list",
+		Data:   model.Budgets.GetStatus(rules),
+	})
+}
+
+// GetUserBudget 获取当前用户各周期的剩余预算
+func GetUserBudget(c *gin.Context) {
+	userId := c.GetInt("id")
+	userBudget, err := model.GetUserBudget(userId)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	rules := model.GetBudgetRules(userId, userBudget, 0, nil, "")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    model.Budgets.GetStatus(rules),
+	})
+}
+
+// GetTokenBudget 获取令牌各周期的剩余预算
+func GetTokenBudget(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	token, err := model.GetTokenByIds(id, c.GetInt("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	tokenBudget := token.Setting.Data().Limits.BudgetSetting
+	rules := model.GetBudgetRules(0, nil, token.Id, &tokenBudget, "")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    model.Budgets.GetStatus(rules),
+	})
+}
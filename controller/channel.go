@@ -24,6 +24,9 @@ func GetChannelsList(c *gin.Context) {
 		common.APIRespondWithError(c, http.StatusOK, err)
 		return
 	}
+	for _, channel := range *channels.Data {
+		channel.CircuitBreakers = model.CircuitBreakers.GetChannelStatus(channel.Id)
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -48,6 +51,7 @@ func GetChannel(c *gin.Context) {
 		})
 		return
 	}
+	channel.CircuitBreakers = model.CircuitBreakers.GetChannelStatus(channel.Id)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -138,6 +142,23 @@ func DeleteChannelTag(c *gin.Context) {
 	})
 }
 
+// ResetChannelCircuitBreaker 手动关闭渠道所有模型的熔断
+func ResetChannelCircuitBreaker(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	err := model.CircuitBreakers.Reset(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
 func DeleteDisabledChannel(c *gin.Context) {
 	rows, err := model.DeleteDisabledChannel()
 	if err != nil {
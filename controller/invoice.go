@@ -1,11 +1,19 @@
 package controller
 
 import (
+	"bytes"
+	"encoding/csv"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"io"
 	"net/http"
 	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/pdf"
 	"one-api/model"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -108,3 +116,245 @@ func GetUserInvoiceDetail(c *gin.Context) {
 		"data":    invoices,
 	})
 }
+
+// DownloadUserInvoice 下载用户指定月份的账单，format 为 csv 或 pdf
+func DownloadUserInvoice(c *gin.Context) {
+	invoice, err := model.GetUserInvoiceByDate(c.GetInt("id"), c.Query("date"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	renderInvoiceFile(c, invoice, c.DefaultQuery("format", "csv"))
+}
+
+// GetInvoiceList 管理员查看所有账单
+func GetInvoiceList(c *gin.Context) {
+	var params model.SearchInvoiceParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	invoices, err := model.GetInvoicesList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    invoices,
+	})
+}
+
+type settleInvoiceRequest struct {
+	Remark string `json:"remark"`
+}
+
+// SettleInvoice 管理员确认收款后结算后付费账单
+func SettleInvoice(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var req settleInvoiceRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	invoice, err := model.SettleInvoice(id, req.Remark)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    invoice,
+	})
+}
+
+// DownloadInvoice 管理员下载账单
+func DownloadInvoice(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	invoice, err := model.GetInvoiceById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	renderInvoiceFile(c, invoice, c.DefaultQuery("format", "csv"))
+}
+
+// GetOrganizationInvoices 组织的月度账单，只有管理员和所有者可以查看
+func GetOrganizationInvoices(c *gin.Context) {
+	member, err := getOrganizationMember(c)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if !member.CanManage() {
+		common.APIRespondWithError(c, http.StatusOK, model.ErrOrganizationPermission)
+		return
+	}
+
+	var params model.PaginationParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	invoices, err := model.GetOrganizationInvoices(member.OrganizationId, &params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	respondSuccess(c, invoices)
+}
+
+// DownloadOrganizationInvoice 下载组织的账单
+func DownloadOrganizationInvoice(c *gin.Context) {
+	member, err := getOrganizationMember(c)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if !member.CanManage() {
+		common.APIRespondWithError(c, http.StatusOK, model.ErrOrganizationPermission)
+		return
+	}
+
+	invoiceId, _ := strconv.Atoi(c.Param("invoice_id"))
+	invoice, err := model.GetInvoiceById(invoiceId)
+	if err != nil || invoice.OrganizationId != member.OrganizationId {
+		common.APIRespondWithError(c, http.StatusOK, model.ErrInvoiceNotFound)
+		return
+	}
+
+	renderInvoiceFile(c, invoice, c.DefaultQuery("format", "csv"))
+}
+
+func renderInvoiceFile(c *gin.Context, invoice *model.Invoice, format string) {
+	items, err := model.GetInvoiceItems(invoice)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	owner := invoiceOwnerName(invoice)
+	filename := fmt.Sprintf("invoice_%s_%d.%s", invoice.Date[:7], invoice.Id, format)
+
+	var (
+		data        []byte
+		contentType string
+	)
+	switch format {
+	case "csv":
+		data, err = invoiceCSV(invoice, owner, items)
+		if err != nil {
+			common.APIRespondWithError(c, http.StatusOK, fmt.Errorf("failed to write CSV: %v", err))
+			return
+		}
+		contentType = "text/csv"
+	case "pdf":
+		data, err = pdf.RenderText(invoiceLines(invoice, owner, items))
+		if err != nil {
+			common.APIRespondWithError(c, http.StatusOK, fmt.Errorf("failed to render PDF: %v", err))
+			return
+		}
+		contentType = "application/pdf"
+	default:
+		common.APIRespondWithError(c, http.StatusOK, errors.New("format must be csv or pdf"))
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+func invoiceOwnerName(invoice *model.Invoice) string {
+	if invoice.OrganizationId > 0 {
+		organization, err := model.GetOrganizationById(invoice.OrganizationId)
+		if err != nil {
+			return fmt.Sprintf("organization #%d", invoice.OrganizationId)
+		}
+		return organization.Name
+	}
+	return model.GetUsernameById(invoice.UserId)
+}
+
+func quotaToAmount(quota int) string {
+	return fmt.Sprintf("%.4f", float64(quota)/config.QuotaPerUnit)
+}
+
+func invoiceCSV(invoice *model.Invoice, owner string, items []*model.StatisticsMonthModel) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	rows := [][]string{
+		{"Invoice ID", strconv.Itoa(invoice.Id)},
+		{"Month", invoice.Date[:7]},
+		{"Account", owner},
+		{"Billing Mode", invoice.BillingMode},
+		{"Status", string(invoice.Status)},
+		{},
+		{"Model", "Request Count", "Prompt Tokens", "Completion Tokens", "Quota", "Amount (USD)"},
+	}
+	for _, item := range items {
+		rows = append(rows, []string{
+			item.ModelName,
+			strconv.Itoa(item.RequestCount),
+			strconv.Itoa(item.PromptTokens),
+			strconv.Itoa(item.CompletionTokens),
+			strconv.Itoa(item.Quota),
+			quotaToAmount(item.Quota),
+		})
+	}
+	rows = append(rows, []string{
+		"Total",
+		strconv.Itoa(invoice.RequestCount),
+		strconv.Itoa(invoice.PromptTokens),
+		strconv.Itoa(invoice.CompletionTokens),
+		strconv.Itoa(invoice.Quota),
+		fmt.Sprintf("%.4f", invoice.Amount),
+	})
+
+	if err := writer.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func invoiceLines(invoice *model.Invoice, owner string, items []*model.StatisticsMonthModel) []string {
+	rowFormat := "%-32.32s %10s %14s %14s %12s"
+	separator := strings.Repeat("-", 86)
+
+	lines := []string{
+		fmt.Sprintf("%s Invoice", config.SystemName),
+		"",
+		fmt.Sprintf("Invoice ID:   %d", invoice.Id),
+		fmt.Sprintf("Month:        %s", invoice.Date[:7]),
+		fmt.Sprintf("Account:      %s", owner),
+		fmt.Sprintf("Billing Mode: %s", invoice.BillingMode),
+		fmt.Sprintf("Status:       %s", invoice.Status),
+		"",
+		fmt.Sprintf(rowFormat, "Model", "Requests", "Prompt", "Completion", "Amount(USD)"),
+		separator,
+	}
+	for _, item := range items {
+		lines = append(lines, fmt.Sprintf(rowFormat, item.ModelName, strconv.Itoa(item.RequestCount), strconv.Itoa(item.PromptTokens), strconv.Itoa(item.CompletionTokens), quotaToAmount(item.Quota)))
+	}
+	lines = append(lines,
+		separator,
+		fmt.Sprintf(rowFormat, "Total", strconv.Itoa(invoice.RequestCount), strconv.Itoa(invoice.PromptTokens), strconv.Itoa(invoice.CompletionTokens), fmt.Sprintf("%.4f", invoice.Amount)),
+	)
+
+	if invoice.SettledTime > 0 {
+		lines = append(lines, "", fmt.Sprintf("Settled at %s", time.Unix(invoice.SettledTime, 0).Format("2006-01-02 15:04:05")))
+	}
+
+	return lines
+}
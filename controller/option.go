@@ -7,6 +7,7 @@ import (
 	"one-api/common/utils"
 	"one-api/model"
 	"one-api/safty"
+	"one-api/safty/providers/regex"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -83,6 +84,22 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "SafeRegexRules":
+		if err := regex.ValidateRules(option.Value); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "正则审查规则无效：" + err.Error(),
+			})
+			return
+		}
+	case "VertexAIModelCategory":
+		if err := config.ValidateVertexAIModelCategory(option.Value); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "Vertex AI 模型分类配置无效：" + err.Error(),
+			})
+			return
+		}
 	case "TurnstileCheckEnabled":
 		if option.Value == "true" && config.TurnstileSiteKey == "" {
 			c.JSON(http.StatusOK, gin.H{
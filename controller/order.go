@@ -136,7 +136,17 @@ func PaymentCallback(c *gin.Context) {
 	}
 
 	payNotify, err := paymentService.HandleCallback(c, paymentService.Payment.Config)
-	if err != nil {
+	if err != nil || payNotify == nil {
+		return
+	}
+
+	if payNotify.Event == types.PayNotifyEventRefunded || payNotify.Event == types.PayNotifyEventRefundFailed {
+		handleRefundNotify(payNotify)
+		return
+	}
+
+	if payNotify.Event != types.PayNotifyEventPaid {
+		handleSubscriptionNotify(paymentService.Payment, payNotify)
 		return
 	}
 
@@ -162,6 +172,14 @@ func PaymentCallback(c *gin.Context) {
 		return
 	}
 
+	if order.PlanId > 0 {
+		_, err = model.ActivateSubscription(order.UserId, order.PlanId, order.GatewayId, payNotify.SubscriptionId)
+		if err != nil {
+			logger.SysError(fmt.Sprintf("gateway callback failed to activate subscription, trade_no: %s, error: %s", payNotify.TradeNo, err.Error()))
+		}
+		return
+	}
+
 	err = model.IncreaseUserQuota(order.UserId, order.Quota)
 	if err != nil {
 		logger.SysError(fmt.Sprintf("gateway callback failed to increase user quota, trade_no: %s,", payNotify.TradeNo))
@@ -0,0 +1,587 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getOrganizationMember 获取当前用户在路径中组织的成员信息
+func getOrganizationMember(c *gin.Context) (*model.OrganizationMember, error) {
+	organizationId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, model.ErrOrganizationNotFound
+	}
+
+	return model.GetOrganizationMember(organizationId, c.GetInt("id"))
+}
+
+// getManageableMember 获取当前用户可以管理的目标成员
+func getManageableMember(c *gin.Context) (operator *model.OrganizationMember, target *model.OrganizationMember, err error) {
+	operator, err = getOrganizationMember(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	userId, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		return nil, nil, model.ErrOrganizationNotMember
+	}
+
+	target, err = model.GetOrganizationMember(operator.OrganizationId, userId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !operator.CanManage() || !operator.Outranks(target) {
+		return nil, nil, model.ErrOrganizationPermission
+	}
+
+	return operator, target, nil
+}
+
+func respondSuccess(c *gin.Context, data any) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    data,
+	})
+}
+
+// GetUserOrganizations 获取当前用户加入的组织
+func GetUserOrganizations(c *gin.Context) {
+	organizations, err := model.GetUserOrganizations(c.GetInt("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	respondSuccess(c, organizations)
+}
+
+type organizationRequest struct {
+	Name string `json:"name"`
+}
+
+func CreateOrganization(c *gin.Context) {
+	var req organizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if len(req.Name) > 64 {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("组织名称过长"))
+		return
+	}
+
+	organization, err := model.CreateOrganization(req.Name, c.GetInt("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	respondSuccess(c, organization)
+}
+
+func GetOrganization(c *gin.Context) {
+	member, err := getOrganizationMember(c)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	organization, err := model.GetOrganizationById(member.OrganizationId)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	respondSuccess(c, &model.OrganizationDetail{
+		Organization: *organization,
+		Role:         member.Role,
+		QuotaLimit:   member.QuotaLimit,
+		MemberUsed:   member.UsedQuota,
+	})
+}
+
+func UpdateOrganization(c *gin.Context) {
+	member, err := getOrganizationMember(c)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if !member.CanManage() {
+		common.APIRespondWithError(c, http.StatusOK, model.ErrOrganizationPermission)
+		return
+	}
+
+	var req organizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if len(req.Name) > 64 {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("组织名称过长"))
+		return
+	}
+
+	if err := model.UpdateOrganizationName(member.OrganizationId, req.Name); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	respondSuccess(c, nil)
+}
+
+// DeleteOrganization 所有者解散组织
+func DeleteOrganization(c *gin.Context) {
+	member, err := getOrganizationMember(c)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if member.Role != model.OrganizationRoleOwner {
+		common.APIRespondWithError(c, http.StatusOK, model.ErrOrganizationPermission)
+		return
+	}
+
+	if err := model.DeleteOrganization(member.OrganizationId); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	respondSuccess(c, nil)
+}
+
+type organizationQuotaRequest struct {
+	Quota int `json:"quota"`
+}
+
+// TransferQuotaToOrganization 成员将个人额度转入组织额度池
+func TransferQuotaToOrganization(c *gin.Context) {
+	member, err := getOrganizationMember(c)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if !member.CanSpend() {
+		common.APIRespondWithError(c, http.StatusOK, model.ErrOrganizationPermission)
+		return
+	}
+
+	var req organizationQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := model.TransferQuotaToOrganization(member.OrganizationId, member.UserId, req.Quota); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	respondSuccess(c, nil)
+}
+
+type organizationOwnerRequest struct {
+	UserId int `json:"user_id"`
+}
+
+// TransferOrganizationOwnership 所有者将组织转让给其他成员
+func TransferOrganizationOwnership(c *gin.Context) {
+	member, err := getOrganizationMember(c)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if member.Role != model.OrganizationRoleOwner {
+		common.APIRespondWithError(c, http.StatusOK, model.ErrOrganizationPermission)
+		return
+	}
+
+	var req organizationOwnerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if req.UserId == member.UserId {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("不能转让给自己"))
+		return
+	}
+
+	if err := model.TransferOrganizationOwnership(member.OrganizationId, member.UserId, req.UserId); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	respondSuccess(c, nil)
+}
+
+func GetOrganizationMembers(c *gin.Context) {
+	member, err := getOrganizationMember(c)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	members, err := model.GetOrganizationMembers(member.OrganizationId)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	respondSuccess(c, members)
+}
+
+type organizationMemberRequest struct {
+	Role       *string `json:"role"`
+	QuotaLimit *int    `json:"quota_limit"`
+}
+
+// UpdateOrganizationMember 修改成员角色和额度上限，只能管理角色低于自己的成员
+func UpdateOrganizationMember(c *gin.Context) {
+	operator, target, err := getManageableMember(c)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	var req organizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if req.Role != nil && *req.Role != target.Role {
+		// 管理员只能设置比自己低的角色
+		if !operator.Outranks(&model.OrganizationMember{Role: *req.Role}) {
+			common.APIRespondWithError(c, http.StatusOK, model.ErrOrganizationPermission)
+			return
+		}
+		if err := model.UpdateOrganizationMemberRole(target.OrganizationId, target.UserId, *req.Role); err != nil {
+			common.APIRespondWithError(c, http.StatusOK, err)
+			return
+		}
+	}
+
+	if req.QuotaLimit != nil {
+		if err := model.UpdateOrganizationMemberQuotaLimit(target.OrganizationId, target.UserId, *req.QuotaLimit); err != nil {
+			common.APIRespondWithError(c, http.StatusOK, err)
+			return
+		}
+	}
+
+	respondSuccess(c, nil)
+}
+
+// RemoveOrganizationMember 移除成员，成员也可以移除自己以离开组织
+func RemoveOrganizationMember(c *gin.Context) {
+	var target *model.OrganizationMember
+	userId, _ := strconv.Atoi(c.Param("user_id"))
+	if userId == c.GetInt("id") {
+		member, err := getOrganizationMember(c)
+		if err != nil {
+			common.APIRespondWithError(c, http.StatusOK, err)
+			return
+		}
+		target = member
+	} else {
+		_, member, err := getManageableMember(c)
+		if err != nil {
+			common.APIRespondWithError(c, http.StatusOK, err)
+			return
+		}
+		target = member
+	}
+
+	if err := model.RemoveOrganizationMember(target.OrganizationId, target.UserId); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	respondSuccess(c, nil)
+}
+
+func GetOrganizationInvitations(c *gin.Context) {
+	member, err := getOrganizationMember(c)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if !member.CanManage() {
+		common.APIRespondWithError(c, http.StatusOK, model.ErrOrganizationPermission)
+		return
+	}
+
+	invitations, err := model.GetOrganizationInvitations(member.OrganizationId)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	respondSuccess(c, invitations)
+}
+
+type organizationInvitationRequest struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+// CreateOrganizationInvitation 创建邀请码，邀请的角色必须低于自己的角色
+func CreateOrganizationInvitation(c *gin.Context) {
+	member, err := getOrganizationMember(c)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	var req organizationInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if req.Role == "" {
+		req.Role = model.OrganizationRoleMember
+	}
+
+	if !member.CanManage() || !member.Outranks(&model.OrganizationMember{Role: req.Role}) {
+		common.APIRespondWithError(c, http.StatusOK, model.ErrOrganizationPermission)
+		return
+	}
+
+	invitation, err := model.CreateOrganizationInvitation(member.OrganizationId, member.UserId, req.Username, req.Role)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	respondSuccess(c, invitation)
+}
+
+func RevokeOrganizationInvitation(c *gin.Context) {
+	member, err := getOrganizationMember(c)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if !member.CanManage() {
+		common.APIRespondWithError(c, http.StatusOK, model.ErrOrganizationPermission)
+		return
+	}
+
+	invitationId, _ := strconv.Atoi(c.Param("invitation_id"))
+	if err := model.RevokeOrganizationInvitation(member.OrganizationId, invitationId); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	respondSuccess(c, nil)
+}
+
+type organizationAcceptRequest struct {
+	Code string `json:"code"`
+}
+
+func AcceptOrganizationInvitation(c *gin.Context) {
+	var req organizationAcceptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	member, err := model.AcceptOrganizationInvitation(req.Code, c.GetInt("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	respondSuccess(c, member)
+}
+
+// GetOrganizationTokens 组织管理员查看所有组织令牌
+func GetOrganizationTokens(c *gin.Context) {
+	member, err := getOrganizationMember(c)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if !member.CanManage() {
+		common.APIRespondWithError(c, http.StatusOK, model.ErrOrganizationPermission)
+		return
+	}
+
+	var params model.GenericParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	tokens, err := model.GetOrganizationTokensList(member.OrganizationId, &params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	for _, token := range *tokens.Data {
+		setting := token.Setting.Data()
+		setting.BillingTag = nil
+		token.Setting.Set(setting)
+		// 管理员可以看到令牌，但不能看到其他成员的密钥
+		if token.UserId != member.UserId {
+			token.Key = ""
+		}
+	}
+
+	respondSuccess(c, tokens)
+}
+
+func DeleteOrganizationToken(c *gin.Context) {
+	member, err := getOrganizationMember(c)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if !member.CanManage() {
+		common.APIRespondWithError(c, http.StatusOK, model.ErrOrganizationPermission)
+		return
+	}
+
+	tokenId, _ := strconv.Atoi(c.Param("token_id"))
+	if err := model.DeleteOrganizationToken(member.OrganizationId, tokenId); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	respondSuccess(c, nil)
+}
+
+// GetOrganizationLogs 组织成员查看组织令牌的消费日志
+func GetOrganizationLogs(c *gin.Context) {
+	member, err := getOrganizationMember(c)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	var params model.LogsListParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	logs, err := model.GetOrganizationLogsList(member.OrganizationId, &params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	respondSuccess(c, logs)
+}
+
+// GetOrganizationStatistics 组织按日期、成员和模型汇总的用量，默认最近 7 天
+func GetOrganizationStatistics(c *gin.Context) {
+	member, err := getOrganizationMember(c)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	now := time.Now()
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	if endTimestamp == 0 {
+		endTimestamp = now.Unix()
+	}
+	if startTimestamp == 0 {
+		startTimestamp = now.AddDate(0, 0, -7).Unix()
+	}
+
+	startDate := time.Unix(startTimestamp, 0).Format("2006-01-02")
+	endDate := time.Unix(endTimestamp, 0).Format("2006-01-02")
+	statistics, err := model.GetOrganizationStatisticsByPeriod(member.OrganizationId, startDate, endDate)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	respondSuccess(c, statistics)
+}
+
+// GetOrganizationsList 管理员查看所有组织
+func GetOrganizationsList(c *gin.Context) {
+	var params model.GenericParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	organizations, err := model.GetOrganizationsList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	respondSuccess(c, organizations)
+}
+
+type manageOrganizationRequest struct {
+	Quota       *int    `json:"quota"`
+	Status      *int    `json:"status"`
+	BillingMode *string `json:"billing_mode"`
+	CreditLimit *int    `json:"credit_limit"`
+}
+
+// ManageOrganization 管理员修改组织额度、状态和计费模式
+func ManageOrganization(c *gin.Context) {
+	organizationId, _ := strconv.Atoi(c.Param("id"))
+	organization, err := model.GetOrganizationById(organizationId)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	var req manageOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if req.Quota != nil {
+		if err := model.SetOrganizationQuota(organization.Id, *req.Quota); err != nil {
+			common.APIRespondWithError(c, http.StatusOK, err)
+			return
+		}
+	}
+
+	if req.Status != nil {
+		if *req.Status != model.OrganizationStatusEnabled && *req.Status != model.OrganizationStatusDisabled {
+			common.APIRespondWithError(c, http.StatusOK, errors.New("无效的状态"))
+			return
+		}
+		if err := model.UpdateOrganizationStatus(organization.Id, *req.Status); err != nil {
+			common.APIRespondWithError(c, http.StatusOK, err)
+			return
+		}
+	}
+
+	if req.BillingMode != nil || req.CreditLimit != nil {
+		billingMode, creditLimit := organization.BillingMode, organization.CreditLimit
+		if req.BillingMode != nil {
+			billingMode = *req.BillingMode
+		}
+		if req.CreditLimit != nil {
+			creditLimit = *req.CreditLimit
+		}
+		if err := model.SetOrganizationBilling(organization.Id, billingMode, creditLimit); err != nil {
+			common.APIRespondWithError(c, http.StatusOK, err)
+			return
+		}
+	}
+
+	respondSuccess(c, nil)
+}
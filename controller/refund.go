@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"one-api/common"
+	"one-api/common/logger"
+	"one-api/model"
+	"one-api/payment"
+	"one-api/payment/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RefundRequest struct {
+	Amount      float64                 `json:"amount"` // 订单支付币种的金额，为 0 时退还剩余全部金额
+	Reason      string                  `json:"reason"`
+	QuotaPolicy model.RefundQuotaPolicy `json:"quota_policy"`
+}
+
+// RefundOrder 原路退款，按退款金额占订单金额的比例扣回用户额度
+func RefundOrder(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var req RefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("invalid request"))
+		return
+	}
+
+	order, err := model.GetOrderById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("订单不存在"))
+		return
+	}
+
+	gateway, err := model.GetPaymentByID(order.GatewayId)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("payment not found"))
+		return
+	}
+
+	paymentService, err := payment.NewPaymentService(gateway.UUID)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if !paymentService.SupportRefund() {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("该支付网关不支持退款"))
+		return
+	}
+
+	LockOrder(order.TradeNo)
+	defer UnlockOrder(order.TradeNo)
+
+	refund, err := model.CreateOrderRefund(order.ID, req.Amount, req.Reason, req.QuotaPolicy)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	result, err := paymentService.Refund(order, refund)
+	if err != nil {
+		// 网关可能已受理退款，保持处理中等待回调确认，不能返还额度
+		logger.SysError(fmt.Sprintf("refund result unknown, trade_no: %s, refund_no: %s, error: %s", order.TradeNo, refund.RefundNo, err.Error()))
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "退款结果未确认，请稍后查看退款状态",
+			"data":    refund,
+		})
+		return
+	}
+
+	switch result.Status {
+	case model.RefundStatusSuccess:
+		err = refund.Succeed(result.GatewayRefundNo)
+		if err == nil {
+			endRefundedSubscription(order, refund)
+		}
+	case model.RefundStatusFailed:
+		err = refund.Fail(result.Message)
+	default:
+		err = refund.UpdateGatewayRefundNo(result.GatewayRefundNo)
+	}
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to update refund, refund_no: %s, error: %s", refund.RefundNo, err.Error()))
+	}
+
+	if refund.Status == model.RefundStatusFailed {
+		common.APIRespondWithError(c, http.StatusOK, fmt.Errorf("退款失败：%s", refund.Message))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    refund,
+	})
+}
+
+func GetOrderRefunds(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	refunds, err := model.GetOrderRefunds(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    refunds,
+	})
+}
+
+// handleRefundNotify 处理网关推送的退款结果
+func handleRefundNotify(payNotify *types.PayNotify) {
+	refund, err := model.GetOrderRefundByRefundNo(payNotify.RefundNo)
+	if err != nil {
+		logger.SysError(fmt.Sprintf("gateway callback failed to find refund, refund_no: %s", payNotify.RefundNo))
+		return
+	}
+
+	LockOrder(refund.TradeNo)
+	defer UnlockOrder(refund.TradeNo)
+
+	// 同步退款结果和回调可能同时到达，加锁后重新读取状态
+	refund, err = model.GetOrderRefundByRefundNo(payNotify.RefundNo)
+	if err != nil || refund.Status != model.RefundStatusPending {
+		return
+	}
+
+	if payNotify.Event == types.PayNotifyEventRefunded {
+		err = refund.Succeed(payNotify.GatewayNo)
+		if err == nil {
+			if order, orderErr := model.GetOrderById(refund.OrderId); orderErr == nil {
+				endRefundedSubscription(order, refund)
+			}
+		}
+	} else {
+		err = refund.Fail("gateway refund failed")
+	}
+
+	if err != nil {
+		logger.SysError(fmt.Sprintf("gateway callback failed to handle %s, refund_no: %s, error: %s", payNotify.Event, payNotify.RefundNo, err.Error()))
+	}
+}
+
+// endRefundedSubscription 订阅订单退款成功后结束订阅、恢复用户分组，并停止网关侧的自动续费
+func endRefundedSubscription(order *model.Order, refund *model.OrderRefund) {
+	if order.PlanId == 0 {
+		return
+	}
+
+	subscription, err := model.EndSubscriptionByRefund(order.UserId, order.PlanId, refund.Quota)
+	if err != nil {
+		if !errors.Is(err, model.ErrSubscriptionNotFound) {
+			logger.SysError(fmt.Sprintf("failed to end refunded subscription, trade_no: %s, error: %s", order.TradeNo, err.Error()))
+		}
+		return
+	}
+
+	if subscription.GatewaySubscriptionId == "" {
+		return
+	}
+
+	gateway, err := model.GetPaymentByID(subscription.GatewayId)
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to find subscription payment, subscription_id: %d", subscription.Id))
+		return
+	}
+
+	paymentService, err := payment.NewPaymentService(gateway.UUID)
+	if err == nil {
+		err = paymentService.CancelSubscription(subscription.GatewaySubscriptionId)
+	}
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to cancel refunded gateway subscription, subscription_id: %d, error: %s", subscription.Id, err.Error()))
+	}
+}
@@ -0,0 +1,385 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/utils"
+	"one-api/model"
+	"one-api/payment"
+	"one-api/payment/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SubscribeRequest struct {
+	UUID   string `json:"uuid" binding:"required"`
+	PlanId int    `json:"plan_id" binding:"required"`
+}
+
+// GetSubscriptionPlans 用户可以订阅的套餐
+func GetSubscriptionPlans(c *gin.Context) {
+	plans, err := model.GetEnabledSubscriptionPlans()
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    plans,
+	})
+}
+
+// GetSelfSubscription 当前用户生效中的订阅
+func GetSelfSubscription(c *gin.Context) {
+	subscription, err := model.GetUserSubscription(c.GetInt("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "",
+			"data":    nil,
+		})
+		return
+	}
+
+	plan, _ := model.GetSubscriptionPlanById(subscription.PlanId)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"subscription": subscription,
+			"plan":         plan,
+		},
+	})
+}
+
+// Subscribe 订阅或续费套餐，支持自动续费的网关创建网关侧的订阅，其余网关按周期手动续费
+func Subscribe(c *gin.Context) {
+	var req SubscribeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("invalid request"))
+		return
+	}
+
+	plan, err := model.GetSubscriptionPlanById(req.PlanId)
+	if err != nil || plan.DeletedAt.Valid || plan.Enable == nil || !*plan.Enable {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("套餐不存在或已下架"))
+		return
+	}
+
+	userId := c.GetInt("id")
+	if current, err := model.GetUserSubscription(userId); err == nil && current.IsAutoRenew() {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("当前订阅已开启自动续费，如需更换套餐请先取消订阅"))
+		return
+	}
+
+	user, err := model.GetUserById(userId, false)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("用户不存在"))
+		return
+	}
+
+	// 关闭用户未完成的订单
+	go model.CloseUnfinishedOrder()
+
+	paymentService, err := payment.NewPaymentService(req.UUID)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	fee, payMoney := calculateSubscriptionAmount(paymentService.Payment, plan.Price)
+	tradeNo := utils.GenerateTradeNo()
+	payRequest, err := paymentService.Subscribe(tradeNo, payMoney, user, plan)
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to create subscription payment, plan_id: %d, error: %s", plan.Id, err.Error()))
+		common.APIRespondWithError(c, http.StatusOK, errors.New("创建支付失败，请稍后再试"))
+		return
+	}
+
+	order := &model.Order{
+		UserId:        userId,
+		GatewayId:     paymentService.Payment.ID,
+		TradeNo:       tradeNo,
+		Amount:        int(math.Ceil(plan.Price)),
+		OrderAmount:   payMoney,
+		OrderCurrency: paymentService.Payment.Currency,
+		Fee:           fee,
+		Status:        model.OrderStatusPending,
+		Quota:         plan.Quota,
+		PlanId:        plan.Id,
+	}
+
+	err = order.Insert()
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("创建订单失败，请稍后再试"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"trade_no":   tradeNo,
+			"type":       payRequest.Type,
+			"data":       payRequest.Data,
+			"auto_renew": paymentService.SupportSubscription(),
+		},
+	})
+}
+
+// CancelSelfSubscription 取消订阅，当前周期结束后不再续费
+func CancelSelfSubscription(c *gin.Context) {
+	subscription, err := model.GetUserSubscription(c.GetInt("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if subscription.IsAutoRenew() {
+		if err := cancelGatewaySubscription(subscription); err != nil {
+			logger.SysError(fmt.Sprintf("failed to cancel gateway subscription %s: %s", subscription.GatewaySubscriptionId, err.Error()))
+			common.APIRespondWithError(c, http.StatusOK, errors.New("取消自动续费失败，请稍后再试"))
+			return
+		}
+	}
+
+	if err := subscription.Cancel(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    subscription,
+	})
+}
+
+func cancelGatewaySubscription(subscription *model.Subscription) error {
+	gateway, err := model.GetPaymentByID(subscription.GatewayId)
+	if err != nil {
+		return err
+	}
+
+	paymentService, err := payment.NewPaymentService(gateway.UUID)
+	if err != nil {
+		return err
+	}
+
+	return paymentService.CancelSubscription(subscription.GatewaySubscriptionId)
+}
+
+// handleSubscriptionNotify 处理网关推送的自动续费事件
+func handleSubscriptionNotify(gateway *model.Payment, payNotify *types.PayNotify) {
+	LockOrder(payNotify.GatewayNo)
+	defer UnlockOrder(payNotify.GatewayNo)
+
+	var err error
+	switch payNotify.Event {
+	case types.PayNotifyEventSubscriptionRenewed:
+		err = renewGatewaySubscription(gateway, payNotify)
+	case types.PayNotifyEventSubscriptionPaymentFailed:
+		err = model.MarkSubscriptionPastDue(payNotify.SubscriptionId)
+	case types.PayNotifyEventSubscriptionCanceled:
+		err = model.CancelSubscriptionByGateway(payNotify.SubscriptionId)
+	}
+
+	if err != nil {
+		logger.SysError(fmt.Sprintf("gateway callback failed to handle %s, subscription_id: %s, error: %s", payNotify.Event, payNotify.SubscriptionId, err.Error()))
+	}
+}
+
+func renewGatewaySubscription(gateway *model.Payment, payNotify *types.PayNotify) error {
+	// 网关可能重复推送同一账单
+	if _, err := model.GetOrderByGatewayNo(gateway.ID, payNotify.GatewayNo); err == nil {
+		return nil
+	}
+
+	subscription, err := model.RenewSubscriptionByGateway(payNotify.SubscriptionId)
+	if err != nil {
+		return err
+	}
+
+	plan, err := model.GetSubscriptionPlanById(subscription.PlanId)
+	if err != nil {
+		return err
+	}
+
+	// 以网关实际扣款的金额为准，套餐价格、汇率或手续费在订阅后调整时与计算的金额不同
+	fee, payMoney := calculateSubscriptionAmount(gateway, plan.Price)
+	currency := gateway.Currency
+	if payNotify.Currency != "" {
+		payMoney = payNotify.Money
+		currency = payNotify.Currency
+	}
+
+	order := &model.Order{
+		UserId:        subscription.UserId,
+		GatewayId:     gateway.ID,
+		TradeNo:       utils.GenerateTradeNo(),
+		GatewayNo:     payNotify.GatewayNo,
+		Amount:        int(math.Ceil(plan.Price)),
+		OrderAmount:   payMoney,
+		OrderCurrency: currency,
+		Fee:           fee,
+		Status:        model.OrderStatusSuccess,
+		Quota:         plan.Quota,
+		PlanId:        plan.Id,
+	}
+
+	return order.Insert()
+}
+
+// calculateSubscriptionAmount 套餐价格不参与充值折扣，只计算手续费和汇率
+func calculateSubscriptionAmount(payment *model.Payment, price float64) (fee, payMoney float64) {
+	if payment.PercentFee > 0 {
+		fee = utils.Decimal(price*payment.PercentFee, 2)
+	} else if payment.FixedFee > 0 {
+		fee = payment.FixedFee
+	}
+
+	payMoney = utils.Decimal(price+fee, 2)
+	if payment.Currency != model.CurrencyTypeUSD {
+		payMoney = utils.Decimal(payMoney*config.PaymentUSDRate, 2)
+	}
+	return
+}
+
+func GetSubscriptionPlanList(c *gin.Context) {
+	var params model.GenericParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	plans, err := model.GetSubscriptionPlansList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    plans,
+	})
+}
+
+func GetSubscriptionPlan(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	plan, err := model.GetSubscriptionPlanById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    plan,
+	})
+}
+
+func AddSubscriptionPlan(c *gin.Context) {
+	plan := model.SubscriptionPlan{}
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := plan.Validate(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := plan.Insert(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    plan,
+	})
+}
+
+func UpdateSubscriptionPlan(c *gin.Context) {
+	plan := model.SubscriptionPlan{}
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if plan.Id == 0 {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("id 为空"))
+		return
+	}
+
+	if err := plan.Validate(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := plan.Update(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// DeleteSubscriptionPlan 删除后不能再订阅和手动续费，已开启自动续费的订阅不受影响
+func DeleteSubscriptionPlan(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	plan, err := model.GetSubscriptionPlanById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := plan.Delete(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func GetSubscriptionList(c *gin.Context) {
+	var params model.SearchSubscriptionParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	subscriptions, err := model.GetSubscriptionsList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    subscriptions,
+	})
+}
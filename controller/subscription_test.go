@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/utils"
+	"one-api/model"
+	"one-api/payment/types"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func setupSubscriptionTest(t *testing.T) {
+	t.Helper()
+	logger.Logger = zap.NewNop()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "one-api.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.User{}, &model.Log{}, &model.Payment{}, &model.Order{}, &model.SubscriptionPlan{}, &model.Subscription{}))
+
+	savedDB, savedSQLite, savedRate := model.DB, common.UsingSQLite, config.PaymentUSDRate
+	model.DB, common.UsingSQLite, config.PaymentUSDRate = db, true, 7.3
+	t.Cleanup(func() {
+		model.DB, common.UsingSQLite, config.PaymentUSDRate = savedDB, savedSQLite, savedRate
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+func TestRenewGatewaySubscription(t *testing.T) {
+	tests := []struct {
+		name         string
+		payNotify    types.PayNotify
+		wantAmount   float64
+		wantCurrency model.CurrencyType
+	}{
+		{
+			name:         "records the amount charged by the gateway",
+			payNotify:    types.PayNotify{Money: 69.5, Currency: model.CurrencyTypeCNY},
+			wantAmount:   69.5,
+			wantCurrency: model.CurrencyTypeCNY,
+		},
+		{
+			name:         "falls back to the plan price when the gateway sends no amount",
+			wantAmount:   76.65,
+			wantCurrency: model.CurrencyTypeCNY,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupSubscriptionTest(t)
+
+			gateway := &model.Payment{Type: "stripe", UUID: "gateway", Name: "stripe", Currency: model.CurrencyTypeCNY, PercentFee: 0.05}
+			require.NoError(t, model.DB.Create(gateway).Error)
+			plan := &model.SubscriptionPlan{Name: "pro", Period: model.SubscriptionPeriodMonthly, Price: 10, Quota: 500, GraceDays: 3}
+			require.NoError(t, model.DB.Create(plan).Error)
+			user := &model.User{Username: "subscriber", Quota: 1000}
+			require.NoError(t, model.DB.Create(user).Error)
+
+			now := utils.GetTimestamp()
+			require.NoError(t, model.DB.Create(&model.Subscription{
+				UserId:                user.Id,
+				PlanId:                plan.Id,
+				Status:                model.SubscriptionStatusActive,
+				GatewayId:             gateway.ID,
+				GatewaySubscriptionId: "sub_1",
+				CurrentPeriodStart:    now - 30*24*3600,
+				CurrentPeriodEnd:      now + 3600,
+				CycleQuota:            500,
+			}).Error)
+
+			payNotify := tt.payNotify
+			payNotify.Event = types.PayNotifyEventSubscriptionRenewed
+			payNotify.GatewayNo = "in_1"
+			payNotify.SubscriptionId = "sub_1"
+			require.NoError(t, renewGatewaySubscription(gateway, &payNotify))
+
+			order, err := model.GetOrderByGatewayNo(gateway.ID, "in_1")
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAmount, order.OrderAmount)
+			assert.Equal(t, tt.wantCurrency, order.OrderCurrency)
+			assert.Equal(t, plan.Id, order.PlanId)
+
+			// 网关重复推送同一账单时不重复续费
+			require.NoError(t, renewGatewaySubscription(gateway, &payNotify))
+			quota, err := model.GetUserQuota(user.Id)
+			require.NoError(t, err)
+			assert.Equal(t, 1500, quota)
+		})
+	}
+}
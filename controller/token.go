@@ -151,6 +151,19 @@ func AddToken(c *gin.Context) {
 		}
 	}
 
+	// 组织令牌只有可以使用组织额度的成员才能创建
+	if token.OrganizationId != 0 {
+		member, err := model.GetOrganizationMember(token.OrganizationId, userId)
+		if err != nil {
+			common.APIRespondWithError(c, http.StatusOK, err)
+			return
+		}
+		if !member.CanSpend() {
+			common.APIRespondWithError(c, http.StatusOK, model.ErrOrganizationPermission)
+			return
+		}
+	}
+
 	setting := token.Setting.Data()
 	err = validateTokenSetting(&setting)
 	if err != nil {
@@ -164,8 +177,9 @@ func AddToken(c *gin.Context) {
 	}
 
 	cleanToken := model.Token{
-		UserId: userId,
-		Name:   token.Name,
+		UserId:         userId,
+		OrganizationId: token.OrganizationId,
+		Name:           token.Name,
 		// Key:            utils.GenerateKey(),
 		CreatedTime:    utils.GetTimestamp(),
 		AccessedTime:   utils.GetTimestamp(),
@@ -354,5 +368,21 @@ func validateTokenSetting(setting *model.TokenSetting) error {
 		}
 	}
 
+	if setting.Limits.TPM < 0 {
+		return errors.New("tpm must not be negative")
+	}
+
+	if !model.IsValidQueueClass(setting.Limits.QueueClass) {
+		return errors.New("invalid queue class")
+	}
+
+	if err := setting.Limits.BudgetSetting.Validate(); err != nil {
+		return err
+	}
+
+	if err := setting.Scopes.Validate(); err != nil {
+		return err
+	}
+
 	return nil
 }
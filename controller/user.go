@@ -378,6 +378,10 @@ func GetSelf(c *gin.Context) {
 		})
 		return
 	}
+	// 只读管理令牌不能拿到可以读写的 access token
+	if c.GetBool("management_read_only") {
+		user.AccessToken = ""
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -428,6 +432,25 @@ func UpdateUser(c *gin.Context) {
 		})
 		return
 	}
+	if updatedUser.Budget != nil {
+		budget := updatedUser.Budget.Data()
+		if err := budget.Validate(); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+	if updatedUser.BillingMode != "" {
+		if err := model.ValidateBilling(updatedUser.BillingMode, updatedUser.CreditLimit); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
 	if updatedUser.Password == "$I_LOVE_U" {
 		updatedUser.Password = "" // rollback to what it should be
 	}
@@ -1,11 +1,14 @@
 package cron
 
 import (
+	"fmt"
 	"github.com/spf13/viper"
 	"one-api/common/config"
 	"one-api/common/logger"
 	"one-api/common/scheduler"
+	"one-api/common/utils"
 	"one-api/model"
+	"one-api/relay/files"
 	"time"
 
 	"github.com/go-co-op/gocron/v2"
@@ -59,6 +62,50 @@ func InitCron() {
 		}),
 	)
 
+	// 每天零点清理已结束周期的预算计数，新周期的预算从零开始计算
+	err = scheduler.Manager.AddJob(
+		"clean_expired_budgets",
+		gocron.DailyJob(1, gocron.NewAtTimes(gocron.NewAtTime(0, 0, 0))),
+		gocron.NewTask(func() {
+			model.Budgets.CleanExpired()
+		}),
+	)
+
+	// 每小时清理过期文件
+	err = scheduler.Manager.AddJob(
+		"clean_expired_files",
+		gocron.DurationJob(time.Hour),
+		gocron.NewTask(func() {
+			files.CleanExpiredFiles()
+		}),
+	)
+
+	// 每小时清理过期的 Responses API 响应
+	err = scheduler.Manager.AddJob(
+		"clean_expired_responses",
+		gocron.DurationJob(time.Hour),
+		gocron.NewTask(func() {
+			count, err := model.DeleteExpiredResponses(utils.GetTimestamp())
+			if err != nil {
+				logger.SysError("Clean expired responses error: " + err.Error())
+				return
+			}
+			if count > 0 {
+				logger.SysLog(fmt.Sprintf("清理过期响应 %d 条", count))
+			}
+		}),
+	)
+
+	// 每小时处理到期的订阅：进入宽限期、宽限期结束后降级，并提醒需要手动续费的用户
+	err = scheduler.Manager.AddJob(
+		"process_subscriptions",
+		gocron.DurationJob(time.Hour),
+		gocron.NewTask(func() {
+			model.ProcessSubscriptions()
+			remindSubscriptionRenewal()
+		}),
+	)
+
 	// 开启自动更新 并且设置了有效自动更新时间 同时自动更新模式不是system 则会从服务器拉取最新价格表
 	autoPriceUpdatesInterval := viper.GetInt("auto_price_updates_interval")
 	autoPriceUpdates := viper.GetBool("auto_price_updates")
@@ -0,0 +1,40 @@
+package cron
+
+import (
+	"fmt"
+	"one-api/common/logger"
+	"one-api/common/stmp"
+	"one-api/model"
+)
+
+// remindSubscriptionRenewal 邮件提醒需要手动续费的用户
+func remindSubscriptionRenewal() {
+	subscriptions, err := model.GetSubscriptionsToRemind()
+	if err != nil {
+		logger.SysError("failed to get subscriptions to remind: " + err.Error())
+		return
+	}
+
+	for _, subscription := range subscriptions {
+		user, err := model.GetUserById(subscription.UserId, false)
+		if err != nil {
+			continue
+		}
+
+		if user.Email != "" {
+			plan, err := model.GetSubscriptionPlanById(subscription.PlanId)
+			if err != nil {
+				continue
+			}
+
+			if err := stmp.SendSubscriptionRenewalEmail(user.DisplayName, user.Email, plan.Name, subscription.CurrentPeriodEnd); err != nil {
+				logger.SysError(fmt.Sprintf("failed to send subscription renewal email to user %d: %s", user.Id, err.Error()))
+				continue
+			}
+		}
+
+		if err := model.MarkSubscriptionReminded(subscription.Id); err != nil {
+			logger.SysError(fmt.Sprintf("failed to mark subscription %d reminded: %s", subscription.Id, err.Error()))
+		}
+	}
+}
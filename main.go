@@ -21,6 +21,7 @@ import (
 	"one-api/cron"
 	"one-api/middleware"
 	"one-api/model"
+	"one-api/relay/batch"
 	"one-api/relay/task"
 	"one-api/router"
 	"one-api/safty"
@@ -82,6 +83,7 @@ func main() {
 	notify.InitNotifier()
 	cron.InitCron()
 	storage.InitStorage()
+	batch.InitBatch()
 	search.InitSearcher()
 	// 初始化安全检查器
 	safty.InitSaftyTools()
@@ -14,8 +14,22 @@ var (
 	httpRequestDuration *prometheus.HistogramVec
 	providerCounter     *prometheus.CounterVec
 	panicCounter        *prometheus.CounterVec
+
+	circuitBreakerState       *prometheus.GaugeVec
+	circuitBreakerTransitions *prometheus.CounterVec
+
+	hedgedRequests *prometheus.CounterVec
+
+	channelQueueDepth *prometheus.GaugeVec
+	channelQueueWait  *prometheus.HistogramVec
 )
 
+var circuitBreakerStateValues = map[string]float64{
+	"closed":    0,
+	"half_open": 1,
+	"open":      2,
+}
+
 func init() {
 	// 1. 监控请求
 	httpRequestsTotal = promauto.NewCounterVec(
@@ -52,6 +66,47 @@ func init() {
 		[]string{"type"},
 	)
 
+	// 4. 监控熔断
+	circuitBreakerState = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "channel_circuit_breaker_state",
+			Help: "Circuit breaker state of channel and model, 0 closed, 1 half open, 2 open.",
+		},
+		[]string{"channel_id", "model"},
+	)
+	circuitBreakerTransitions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "channel_circuit_breaker_transitions_total",
+			Help: "Total number of circuit breaker state transitions.",
+		},
+		[]string{"channel_id", "model", "from", "to"},
+	)
+
+	// 5. 监控对冲请求
+	hedgedRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "relay_hedged_requests_total",
+			Help: "Total number of hedged requests started after first token timeout, by winner.",
+		},
+		[]string{"model", "winner"},
+	)
+
+	// 6. 监控渠道排队
+	channelQueueDepth = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "relay_channel_queue_depth",
+			Help: "Number of requests waiting for a free channel.",
+		},
+		[]string{"model", "class"},
+	)
+	channelQueueWait = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "relay_channel_queue_wait_seconds",
+			Help:    "Time requests spent waiting for a free channel, by result.",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		},
+		[]string{"model", "class", "result"},
+	)
 }
 
 // 记录 HTTP 请求
@@ -94,6 +149,36 @@ func RecordProvider(c *gin.Context, statusCode int) {
 	})
 }
 
+// 记录熔断状态变化
+func RecordCircuitBreakerTransition(channelId int, model, from, to string) {
+	go SafelyRecordMetric(func() {
+		channel := strconv.Itoa(channelId)
+		circuitBreakerState.WithLabelValues(channel, model).Set(circuitBreakerStateValues[to])
+		circuitBreakerTransitions.WithLabelValues(channel, model, from, to).Inc()
+	})
+}
+
+// 记录对冲请求结果，winner 为 primary、hedge 或 none
+func RecordHedgedRequest(model, winner string) {
+	go SafelyRecordMetric(func() {
+		hedgedRequests.WithLabelValues(model, winner).Inc()
+	})
+}
+
+// 记录排队中的请求数变化
+func AddChannelQueueDepth(model, class string, delta int) {
+	go SafelyRecordMetric(func() {
+		channelQueueDepth.WithLabelValues(model, class).Add(float64(delta))
+	})
+}
+
+// 记录排队等待时间，result 为 acquired、timeout、cancelled 或 rejected
+func RecordChannelQueueWait(model, class, result string, wait time.Duration) {
+	go SafelyRecordMetric(func() {
+		channelQueueWait.WithLabelValues(model, class, result).Observe(wait.Seconds())
+	})
+}
+
 // 记录 panic
 func RecordPanic(panicType string) {
 	panicCounter.WithLabelValues(panicType).Inc()
@@ -3,7 +3,9 @@ package middleware
 import (
 	"fmt"
 	"net/http"
+	"one-api/common/limit"
 	"one-api/model"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -30,10 +32,28 @@ func DynamicRedisRateLimiter() gin.HandlerFunc {
 		key := fmt.Sprintf(LIMIT_KEY, userID)
 
 		if !limiter.Allow(key) {
+			setRequestRateLimitHeaders(c, limiter, key)
 			abortWithMessage(c, http.StatusTooManyRequests, RATE_LIMIT_EXCEEDED_MSG)
 			return
 		}
+		setRequestRateLimitHeaders(c, limiter, key)
 
 		c.Next()
 	}
 }
+
+// setRequestRateLimitHeaders 参照 OpenAI 返回请求数的限流信息
+func setRequestRateLimitHeaders(c *gin.Context, limiter limit.RateLimiter, key string) {
+	maxRate := limit.GetMaxRate(limiter)
+	if maxRate <= 0 {
+		return
+	}
+
+	used, err := limiter.GetCurrentRate(key)
+	if err != nil {
+		return
+	}
+
+	c.Header("x-ratelimit-limit-requests", strconv.Itoa(maxRate))
+	c.Header("x-ratelimit-remaining-requests", strconv.Itoa(max(maxRate-used, 0)))
+}
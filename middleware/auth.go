@@ -34,6 +34,19 @@ func authHelper(c *gin.Context, minRole int) {
 			accessToken = fmt.Sprintf("Bearer %s", token)
 		}
 		user := model.ValidateAccessToken(accessToken)
+		if user == nil && minRole <= config.RoleCommonUser {
+			var readOnlyErr string
+			user, readOnlyErr = managementTokenUser(c, accessToken)
+			if readOnlyErr != "" {
+				c.JSON(http.StatusOK, gin.H{
+					"success": false,
+					"message": readOnlyErr,
+					"code":    model.TokenScopeErrReadOnly,
+				})
+				c.Abort()
+				return
+			}
+		}
 		if user != nil && user.Username != "" {
 			// Token is valid
 			username = user.Username
@@ -71,6 +84,60 @@ func authHelper(c *gin.Context, minRole int) {
 	c.Next()
 }
 
+// 只读管理令牌可以调用的查询接口，按路由模板匹配
+// 这些接口不会修改数据，返回内容中也不包含 access token 和令牌密钥，新增接口需要逐个确认后再加入
+var managementTokenAllowedRoutes = map[string]bool{
+	"/api/user/dashboard":                                 true,
+	"/api/user/dashboard/rate":                            true,
+	"/api/user/budget":                                    true,
+	"/api/user/invoice":                                   true,
+	"/api/user/invoice/detail":                            true,
+	"/api/user/invoice/download":                          true,
+	"/api/user/self":                                      true, // GetSelf 会隐藏 access token
+	"/api/user/payment":                                   true,
+	"/api/user/order/status":                              true,
+	"/api/user/subscription":                              true,
+	"/api/user/subscription/plans":                        true,
+	"/api/log/self":                                       true,
+	"/api/log/self/stat":                                  true,
+	"/api/mj/self":                                        true,
+	"/api/task/self":                                      true,
+	"/api/organization/":                                  true,
+	"/api/organization/:id":                               true,
+	"/api/organization/:id/members":                       true,
+	"/api/organization/:id/logs":                          true,
+	"/api/organization/:id/statistics":                    true,
+	"/api/organization/:id/invoices":                      true,
+	"/api/organization/:id/invoices/:invoice_id/download": true,
+}
+
+// managementTokenUser 拥有只读管理范围的令牌可以调用白名单内的查询接口，不能修改数据
+func managementTokenUser(c *gin.Context, accessToken string) (*model.User, string) {
+	key := strings.TrimPrefix(accessToken, "Bearer ")
+	key = strings.TrimPrefix(key, "sk-")
+	key = strings.Split(key, "#")[0]
+	if len(key) < 48 {
+		return nil, ""
+	}
+
+	token, err := model.ValidateUserToken(key)
+	if err != nil || !token.Setting.Data().Scopes.ManagementReadOnly {
+		return nil, ""
+	}
+
+	if c.Request.Method != http.MethodGet || !managementTokenAllowedRoutes[c.FullPath()] {
+		return nil, "无权进行此操作，令牌仅有只读管理权限"
+	}
+
+	user, err := model.GetUserById(token.UserId, false)
+	if err != nil {
+		return nil, ""
+	}
+
+	c.Set("management_read_only", true)
+	return user, ""
+}
+
 func TrySetUserBySession() func(c *gin.Context) {
 	return func(c *gin.Context) {
 		session := sessions.Default(c)
@@ -130,17 +197,15 @@ func tokenAuth(c *gin.Context, key string) {
 		return
 	}
 
-	c.Set("id", token.UserId)
-	c.Set("token_id", token.Id)
-	c.Set("token_name", token.Name)
-	c.Set("token_group", token.Group)
-	c.Set("token_backup_group", token.BackupGroup)
-	c.Set("token_unlimited_quota", token.UnlimitedQuota)
-	c.Set("token_setting", utils.GetPointer(token.Setting.Data()))
+	setting := SetTokenContext(c, token)
 	if err := checkLimitIP(c); err != nil {
 		abortWithMessage(c, http.StatusForbidden, err.Error())
 		return
 	}
+	if err := setting.Scopes.CheckEndpoint(c.Request.Method, c.Request.URL.Path); err != nil {
+		abortWithCode(c, err.StatusCode, err.Code, err.Message)
+		return
+	}
 	if len(parts) > 1 {
 		if model.IsAdmin(token.UserId) {
 			if strings.HasPrefix(parts[1], "!") {
@@ -165,6 +230,39 @@ func tokenAuth(c *gin.Context, key string) {
 	c.Next()
 }
 
+// InternalTokenAuth 批处理等网关内部构造的请求按令牌 key 认证，与在线请求一样检查令牌状态、额度和接口范围
+// 请求不是由客户端直接发出，不检查 IP 白名单
+func InternalTokenAuth(key string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		token, err := model.ValidateUserToken(key)
+		if err != nil {
+			abortWithMessage(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		setting := SetTokenContext(c, token)
+		if err := setting.Scopes.CheckEndpoint(c.Request.Method, c.Request.URL.Path); err != nil {
+			abortWithCode(c, err.StatusCode, err.Code, err.Message)
+			return
+		}
+		c.Next()
+	}
+}
+
+// SetTokenContext 写入令牌相关的上下文，批处理等内部构造的请求也通过它设置，保证计费和限制与在线请求一致
+func SetTokenContext(c *gin.Context, token *model.Token) *model.TokenSetting {
+	c.Set("id", token.UserId)
+	c.Set("token_id", token.Id)
+	c.Set("organization_id", token.OrganizationId)
+	c.Set("token_name", token.Name)
+	c.Set("token_group", token.Group)
+	c.Set("token_backup_group", token.BackupGroup)
+	c.Set("token_unlimited_quota", token.UnlimitedQuota)
+	setting := utils.GetPointer(token.Setting.Data())
+	c.Set("token_setting", setting)
+	return setting
+}
+
 // 检测是否IP白名单
 func checkLimitIP(c *gin.Context) (error error) {
 	// 从context中获取token设置
@@ -19,6 +19,19 @@ func abortWithMessage(c *gin.Context, statusCode int, message string) {
 	logger.LogError(c.Request.Context(), message)
 }
 
+// abortWithCode 返回带错误码的错误，便于客户端区分拒绝原因
+func abortWithCode(c *gin.Context, statusCode int, code string, message string) {
+	c.JSON(statusCode, gin.H{
+		"error": gin.H{
+			"message": utils.MessageWithRequestId(message, c.GetString(logger.RequestIdKey)),
+			"type":    "one_hub_error",
+			"code":    code,
+		},
+	})
+	c.Abort()
+	logger.LogError(c.Request.Context(), message)
+}
+
 func midjourneyAbortWithMessage(c *gin.Context, code int, description string) {
 	c.JSON(http.StatusBadRequest, gin.H{
 		"description": description,
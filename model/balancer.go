@@ -1,30 +1,29 @@
 package model
 
 import (
+	"encoding/json"
 	"errors"
-	"fmt"
-	"math/rand"
 	"one-api/common/config"
 	"one-api/common/logger"
 	"one-api/common/utils"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
-	"time"
 )
 
 type ChannelChoice struct {
 	Channel       *Channel
 	CooldownsTime int64
 	Disable       bool
+	ModelMap      map[string]string // 渠道模型映射，用于计算实际价格
 }
 
 type ChannelsChooser struct {
 	sync.RWMutex
-	Channels  map[int]*ChannelChoice
-	Rule      map[string]map[string][][]int // group -> model -> priority -> channelIds
-	Match     []string
-	Cooldowns sync.Map
+	Channels map[int]*ChannelChoice
+	Rule     map[string]map[string][][]int // group -> model -> priority -> channelIds
+	Match    []string
 
 	ModelGroup map[string]map[string]bool
 }
@@ -55,54 +54,6 @@ func FilterDisabledStream(modelName string) ChannelsFilterFunc {
 	}
 }
 
-func init() {
-	// 每小时清理一次过期的冷却时间
-	go func() {
-		ticker := time.NewTicker(1 * time.Hour)
-		for range ticker.C {
-			ChannelGroup.CleanupExpiredCooldowns()
-		}
-	}()
-}
-
-func (cc *ChannelsChooser) SetCooldowns(channelId int, modelName string) bool {
-	if channelId == 0 || modelName == "" || config.RetryCooldownSeconds == 0 {
-		return false
-	}
-
-	key := fmt.Sprintf("%d:%s", channelId, modelName)
-	nowTime := time.Now().Unix()
-
-	cooldownTime, exists := cc.Cooldowns.Load(key)
-	if exists && nowTime < cooldownTime.(int64) {
-		return true
-	}
-
-	cc.Cooldowns.LoadOrStore(key, nowTime+int64(config.RetryCooldownSeconds))
-	return true
-}
-
-func (cc *ChannelsChooser) IsInCooldown(channelId int, modelName string) bool {
-	key := fmt.Sprintf("%d:%s", channelId, modelName)
-
-	cooldownTime, exists := cc.Cooldowns.Load(key)
-	if !exists {
-		return false
-	}
-
-	return time.Now().Unix() < cooldownTime.(int64)
-}
-
-func (cc *ChannelsChooser) CleanupExpiredCooldowns() {
-	now := time.Now().Unix()
-	cc.Cooldowns.Range(func(key, value interface{}) bool {
-		if now >= value.(int64) {
-			cc.Cooldowns.Delete(key)
-		}
-		return true
-	})
-}
-
 func (cc *ChannelsChooser) Disable(channelId int) {
 	cc.Lock()
 	defer cc.Unlock()
@@ -131,9 +82,8 @@ func (cc *ChannelsChooser) ChangeStatus(channelId int, status bool) {
 	}
 }
 
-func (cc *ChannelsChooser) balancer(channelIds []int, filters []ChannelsFilterFunc, modelName string) *Channel {
-	totalWeight := 0
-
+// balancer 在同一优先级的渠道中选择一个，busy 表示有渠道因熔断或达到 RPM、TPM、并发上限而被跳过
+func (cc *ChannelsChooser) balancer(channelIds []int, filters []ChannelsFilterFunc, routing *RoutingContext, strategy RoutingStrategy) (channel *Channel, busy bool) {
 	validChannels := make([]*ChannelChoice, 0, len(channelIds))
 	for _, channelId := range channelIds {
 		choice, ok := cc.Channels[channelId]
@@ -141,10 +91,6 @@ func (cc *ChannelsChooser) balancer(channelIds []int, filters []ChannelsFilterFu
 			continue
 		}
 
-		if cc.IsInCooldown(channelId, modelName) {
-			continue
-		}
-
 		isSkip := false
 		for _, filter := range filters {
 			if filter(channelId, choice) {
@@ -156,34 +102,47 @@ func (cc *ChannelsChooser) balancer(channelIds []int, filters []ChannelsFilterFu
 			continue
 		}
 
-		weight := int(*choice.Channel.Weight)
-		totalWeight += weight
-		validChannels = append(validChannels, choice)
-	}
+		if !CircuitBreakers.Available(channelId, routing.ModelName) || ChannelCapacity.Saturated(choice.Channel) {
+			busy = true
+			continue
+		}
 
-	if len(validChannels) == 0 {
-		return nil
+		validChannels = append(validChannels, choice)
 	}
 
-	if len(validChannels) == 1 {
-		return validChannels[0].Channel
-	}
+	// 半开状态的渠道只允许有限的探测请求，名额被其他请求占用时换一个渠道
+	for len(validChannels) > 0 {
+		choice := validChannels[0]
+		if len(validChannels) > 1 {
+			choice = strategy.Select(validChannels, routing)
+		}
+		if choice == nil {
+			return nil, busy
+		}
 
-	choiceWeight := rand.Intn(totalWeight)
-	for _, choice := range validChannels {
-		weight := int(*choice.Channel.Weight)
-		choiceWeight -= weight
-		if choiceWeight < 0 {
-			return choice.Channel
+		if CircuitBreakers.Acquire(choice.Channel.Id, routing.ModelName) {
+			return choice.Channel, false
 		}
+		busy = true
+
+		validChannels = slices.DeleteFunc(validChannels, func(item *ChannelChoice) bool {
+			return item == choice
+		})
 	}
 
-	return nil
+	return nil, busy
 }
 
 func (cc *ChannelsChooser) Next(group, modelName string, filters ...ChannelsFilterFunc) (*Channel, error) {
+	return cc.NextByRouting(&RoutingContext{Group: group, ModelName: modelName}, filters...)
+}
+
+// NextByRouting 按分组配置的路由策略选择渠道，优先级仍然优先于策略
+func (cc *ChannelsChooser) NextByRouting(routing *RoutingContext, filters ...ChannelsFilterFunc) (*Channel, error) {
 	cc.RLock()
 	defer cc.RUnlock()
+
+	group, modelName := routing.Group, routing.ModelName
 	if _, ok := cc.Rule[group]; !ok {
 		return nil, errors.New("group not found")
 	}
@@ -201,11 +160,18 @@ func (cc *ChannelsChooser) Next(group, modelName string, filters ...ChannelsFilt
 		return nil, errors.New("channel not found")
 	}
 
+	strategy := GetRoutingStrategy(GlobalUserGroupRatio.GetRoutingStrategy(group, modelName))
+	anyBusy := false
 	for _, priority := range channelsPriority {
-		channel := cc.balancer(priority, filters, modelName)
+		channel, busy := cc.balancer(priority, filters, routing, strategy)
 		if channel != nil {
 			return channel, nil
 		}
+		anyBusy = anyBusy || busy
+	}
+
+	if anyBusy {
+		return nil, ErrChannelBusy
 	}
 
 	return nil, errors.New("channel not found")
@@ -272,6 +238,7 @@ func (cc *ChannelsChooser) Load() {
 			Channel:       channel,
 			CooldownsTime: 0,
 			Disable:       false,
+			ModelMap:      parseModelMap(channel.GetModelMapping()),
 		}
 
 		// 处理groups和models
@@ -353,3 +320,16 @@ func (cc *ChannelsChooser) Load() {
 	cc.Unlock()
 	logger.SysLog("channels Load success")
 }
+
+func parseModelMap(modelMapping string) map[string]string {
+	modelMap := make(map[string]string)
+	if modelMapping == "" || modelMapping == "{}" {
+		return modelMap
+	}
+
+	if err := json.Unmarshal([]byte(modelMapping), &modelMap); err != nil {
+		logger.SysError("parse model mapping error: " + err.Error())
+	}
+
+	return modelMap
+}
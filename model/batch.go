@@ -0,0 +1,270 @@
+package model
+
+import (
+	"errors"
+	"one-api/common/utils"
+	"one-api/types"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Batch 网关自身执行的批处理任务，兼容 OpenAI Batch API
+type Batch struct {
+	Id               int    `json:"id"`
+	BatchId          string `json:"batch_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId           int    `json:"user_id" gorm:"index"`
+	TokenId          int    `json:"token_id" gorm:"index"`
+	Endpoint         string `json:"endpoint" gorm:"type:varchar(64)"`
+	InputFileId      string `json:"input_file_id" gorm:"type:varchar(64)"`
+	OutputFileId     string `json:"output_file_id" gorm:"type:varchar(64);default:''"`
+	ErrorFileId      string `json:"error_file_id" gorm:"type:varchar(64);default:''"`
+	CompletionWindow string `json:"completion_window" gorm:"type:varchar(16)"`
+	Status           string `json:"status" gorm:"type:varchar(20);index"`
+	Total            int    `json:"total" gorm:"default:0"`
+	Completed        int    `json:"completed" gorm:"default:0"`
+	Failed           int    `json:"failed" gorm:"default:0"`
+	CreatedAt        int64  `json:"created_at" gorm:"bigint;index"`
+	InProgressAt     int64  `json:"in_progress_at" gorm:"bigint;default:0"`
+	ExpiresAt        int64  `json:"expires_at" gorm:"bigint;default:0"`
+	FinalizingAt     int64  `json:"finalizing_at" gorm:"bigint;default:0"`
+	CompletedAt      int64  `json:"completed_at" gorm:"bigint;default:0"`
+	FailedAt         int64  `json:"failed_at" gorm:"bigint;default:0"`
+	ExpiredAt        int64  `json:"expired_at" gorm:"bigint;default:0"`
+	CancellingAt     int64  `json:"cancelling_at" gorm:"bigint;default:0"`
+	CancelledAt      int64  `json:"cancelled_at" gorm:"bigint;default:0"`
+
+	Errors   datatypes.JSONType[[]types.BatchError] `json:"errors" gorm:"type:json"`
+	Metadata datatypes.JSONType[map[string]string]  `json:"metadata" gorm:"type:json"`
+}
+
+const (
+	BatchLineStatusPending   = "pending"
+	BatchLineStatusRunning   = "running"
+	BatchLineStatusCompleted = "completed"
+	BatchLineStatusFailed    = "failed"
+)
+
+// BatchLine 批处理单行请求的执行进度和结果，服务重启后据此继续执行，完成后删除
+type BatchLine struct {
+	Id        int            `json:"id"`
+	BatchId   int            `json:"batch_id" gorm:"uniqueIndex:idx_batch_line"`
+	LineIndex int            `json:"line_index" gorm:"uniqueIndex:idx_batch_line"` // 在有效请求行中的序号
+	CustomId  string         `json:"custom_id" gorm:"type:varchar(255)"`
+	Status    string         `json:"status" gorm:"type:varchar(16);index"`
+	Result    datatypes.JSON `json:"result" gorm:"type:json"` // 写入输出文件的结果行
+	UpdatedAt int64          `json:"updated_at" gorm:"bigint"`
+}
+
+func NewBatchId() string {
+	return "batch_" + utils.GetRandomString(24)
+}
+
+func (b *Batch) Insert() error {
+	return DB.Create(b).Error
+}
+
+func (b *Batch) Update() error {
+	return DB.Save(b).Error
+}
+
+// UpdateStatus 仅在状态未被其他流程修改时更新，返回是否更新成功
+func (b *Batch) UpdateStatus(fromStatus string, updates map[string]any) (bool, error) {
+	result := DB.Model(&Batch{}).Where("id = ? AND status = ?", b.Id, fromStatus).Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	return true, DB.First(b, b.Id).Error
+}
+
+func GetUserBatchByBatchId(userId int, batchId string) (*Batch, error) {
+	var batch Batch
+	err := DB.Where("user_id = ? AND batch_id = ?", userId, batchId).First(&batch).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("No such Batch object: " + batchId)
+	}
+
+	return &batch, err
+}
+
+func GetBatchByBatchId(batchId string) (*Batch, error) {
+	var batch Batch
+	err := DB.Where("batch_id = ?", batchId).First(&batch).Error
+	return &batch, err
+}
+
+// GetUserBatches 按创建时间倒序分页，after 为上一页最后一个 batch id
+func GetUserBatches(userId int, after string, limit int) ([]*Batch, error) {
+	var batches []*Batch
+	db := DB.Where("user_id = ?", userId)
+
+	if after != "" {
+		afterBatch, err := GetUserBatchByBatchId(userId, after)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where("id < ?", afterBatch.Id)
+	}
+
+	err := db.Order("id desc").Limit(limit).Find(&batches).Error
+	return batches, err
+}
+
+func GetBatchesByStatus(status string) ([]*Batch, error) {
+	var batches []*Batch
+	err := DB.Where("status = ?", status).Order("id asc").Find(&batches).Error
+	return batches, err
+}
+
+// GetInterruptedBatches 获取执行过程中被中断的批处理（如服务重启）
+func GetInterruptedBatches() ([]*Batch, error) {
+	var batches []*Batch
+	err := DB.Where("status IN ?", []string{
+		types.BatchStatusInProgress,
+		types.BatchStatusFinalizing,
+		types.BatchStatusCancelling,
+	}).Find(&batches).Error
+	return batches, err
+}
+
+// InitBatchLines 首次执行时为每个有效请求行创建进度记录，已存在时说明是重启后继续执行，不重复创建
+func InitBatchLines(batch *Batch, customIds []string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&BatchLine{}).Where("batch_id = ?", batch.Id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+
+		now := utils.GetTimestamp()
+		lines := make([]*BatchLine, 0, len(customIds))
+		for i, customId := range customIds {
+			lines = append(lines, &BatchLine{
+				BatchId:   batch.Id,
+				LineIndex: i,
+				CustomId:  customId,
+				Status:    BatchLineStatusPending,
+				UpdatedAt: now,
+			})
+		}
+		if err := tx.CreateInBatches(lines, 500).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&Batch{}).Where("id = ?", batch.Id).Update("total", len(customIds)).Error
+	})
+}
+
+// GetPendingBatchLineIndexes 获取尚未执行的请求行序号
+func GetPendingBatchLineIndexes(batchId int) ([]int, error) {
+	var indexes []int
+	err := DB.Model(&BatchLine{}).Where("batch_id = ? AND status = ?", batchId, BatchLineStatusPending).
+		Order("line_index asc").Pluck("line_index", &indexes).Error
+	return indexes, err
+}
+
+// ClaimBatchLine 发出请求前将请求行标记为执行中，返回 false 时该行已被执行过
+func ClaimBatchLine(batchId, lineIndex int) (bool, error) {
+	result := DB.Model(&BatchLine{}).
+		Where("batch_id = ? AND line_index = ? AND status = ?", batchId, lineIndex, BatchLineStatusPending).
+		Updates(map[string]any{
+			"status":     BatchLineStatusRunning,
+			"updated_at": utils.GetTimestamp(),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// SaveBatchLineResult 保存请求行的结果，并累加批处理的完成或失败数量
+func SaveBatchLineResult(batchId, lineIndex int, success bool, result any) error {
+	status, counter := BatchLineStatusFailed, "failed"
+	if success {
+		status, counter = BatchLineStatusCompleted, "completed"
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&BatchLine{}).Where("batch_id = ? AND line_index = ?", batchId, lineIndex).Updates(map[string]any{
+			"status":     status,
+			"result":     datatypes.JSON(utils.Marshal(result)),
+			"updated_at": utils.GetTimestamp(),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&Batch{}).Where("id = ?", batchId).Update(counter, gorm.Expr(counter+" + 1")).Error
+	})
+}
+
+// GetRunningBatchLines 获取服务重启前已发出但未记录结果的请求行
+func GetRunningBatchLines(batchId int) ([]*BatchLine, error) {
+	var lines []*BatchLine
+	err := DB.Where("batch_id = ? AND status = ?", batchId, BatchLineStatusRunning).Order("line_index asc").Find(&lines).Error
+	return lines, err
+}
+
+// FindBatchLines 分批读取请求行，避免一次加载全部结果，请求行按序号顺序创建，主键顺序即为序号顺序
+func FindBatchLines(batchId int, fc func(lines []*BatchLine) error) error {
+	var lines []*BatchLine
+	return DB.Where("batch_id = ?", batchId).FindInBatches(&lines, 500, func(tx *gorm.DB, batch int) error {
+		return fc(lines)
+	}).Error
+}
+
+func DeleteBatchLines(batchId int) error {
+	return DB.Where("batch_id = ?", batchId).Delete(&BatchLine{}).Error
+}
+
+func (b *Batch) ToOpenAI() *types.Batch {
+	batch := &types.Batch{
+		Id:               b.BatchId,
+		Object:           "batch",
+		Endpoint:         b.Endpoint,
+		InputFileId:      b.InputFileId,
+		CompletionWindow: b.CompletionWindow,
+		Status:           b.Status,
+		CreatedAt:        b.CreatedAt,
+		InProgressAt:     timestampPointer(b.InProgressAt),
+		ExpiresAt:        timestampPointer(b.ExpiresAt),
+		FinalizingAt:     timestampPointer(b.FinalizingAt),
+		CompletedAt:      timestampPointer(b.CompletedAt),
+		FailedAt:         timestampPointer(b.FailedAt),
+		ExpiredAt:        timestampPointer(b.ExpiredAt),
+		CancellingAt:     timestampPointer(b.CancellingAt),
+		CancelledAt:      timestampPointer(b.CancelledAt),
+		RequestCounts: types.BatchRequestCounts{
+			Total:     b.Total,
+			Completed: b.Completed,
+			Failed:    b.Failed,
+		},
+		Metadata: b.Metadata.Data(),
+	}
+
+	if b.OutputFileId != "" {
+		batch.OutputFileId = utils.GetPointer(b.OutputFileId)
+	}
+	if b.ErrorFileId != "" {
+		batch.ErrorFileId = utils.GetPointer(b.ErrorFileId)
+	}
+	if errs := b.Errors.Data(); len(errs) > 0 {
+		batch.Errors = &types.BatchErrors{
+			Object: "list",
+			Data:   errs,
+		}
+	}
+
+	return batch
+}
+
+func timestampPointer(timestamp int64) *int64 {
+	if timestamp == 0 {
+		return nil
+	}
+	return utils.GetPointer(timestamp)
+}
@@ -0,0 +1,144 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common/utils"
+
+	"gorm.io/gorm"
+)
+
+// 计费模式：预付费用户额度用尽后拒绝请求，后付费用户可以在授信额度内透支，按月出账结算
+const (
+	BillingModePrepaid  = "prepaid"
+	BillingModePostpaid = "postpaid"
+)
+
+// BillingModeChange 计费模式的变更记录，生成账单时据此还原账单月份内的计费模式
+// 组织的变更记录 UserId 为 0，与账单一致
+type BillingModeChange struct {
+	Id             int    `json:"id"`
+	UserId         int    `json:"user_id" gorm:"index"`
+	OrganizationId int    `json:"organization_id" gorm:"index"`
+	PreviousMode   string `json:"previous_mode" gorm:"type:varchar(16)"`
+	Mode           string `json:"mode" gorm:"type:varchar(16)"`
+	CreatedTime    int64  `json:"created_time" gorm:"bigint;index"`
+}
+
+func IsValidBillingMode(mode string) bool {
+	return mode == BillingModePrepaid || mode == BillingModePostpaid
+}
+
+// ValidateBilling 检查计费模式和授信额度
+func ValidateBilling(mode string, creditLimit int) error {
+	if !IsValidBillingMode(mode) {
+		return fmt.Errorf("无效的计费模式: %s", mode)
+	}
+	if creditLimit < 0 {
+		return errors.New("授信额度不能小于 0")
+	}
+	return nil
+}
+
+// GetCreditLimit 允许透支的额度，预付费为 0
+func GetCreditLimit(mode string, creditLimit int) int {
+	if mode != BillingModePostpaid {
+		return 0
+	}
+	return creditLimit
+}
+
+func GetUserCreditLimit(id int) (int, error) {
+	var user User
+	err := DB.Model(&User{}).Where("id = ?", id).Select("billing_mode", "credit_limit").First(&user).Error
+	if err != nil {
+		return 0, err
+	}
+	return GetCreditLimit(user.BillingMode, user.CreditLimit), nil
+}
+
+func SetOrganizationBilling(organizationId int, mode string, creditLimit int) error {
+	if err := ValidateBilling(mode, creditLimit); err != nil {
+		return err
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var previousMode string
+		if err := tx.Model(&Organization{}).Where("id = ?", organizationId).Select("billing_mode").Scan(&previousMode).Error; err != nil {
+			return err
+		}
+
+		err := tx.Model(&Organization{}).Where("id = ?", organizationId).Updates(map[string]interface{}{
+			"billing_mode": mode,
+			"credit_limit": creditLimit,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		return recordBillingModeChange(tx, 0, organizationId, previousMode, mode)
+	})
+}
+
+func recordBillingModeChange(tx *gorm.DB, userId, organizationId int, previousMode, mode string) error {
+	if previousMode == "" {
+		previousMode = BillingModePrepaid
+	}
+	if previousMode == mode {
+		return nil
+	}
+
+	return tx.Create(&BillingModeChange{
+		UserId:         userId,
+		OrganizationId: organizationId,
+		PreviousMode:   previousMode,
+		Mode:           mode,
+		CreatedTime:    utils.GetTimestamp(),
+	}).Error
+}
+
+// getBillingModes 还原账单月份 [start, end) 内的计费模式，modes 为当前的计费模式
+// 月内任一时刻为后付费即按后付费出账，避免月中切换计费模式时透支的额度漏出账单
+func getBillingModes(tx *gorm.DB, organization bool, modes map[int]string, start, end int64) (map[int]string, error) {
+	ids := make([]int, 0, len(modes))
+	for id := range modes {
+		ids = append(ids, id)
+	}
+
+	var changes []*BillingModeChange
+	if len(ids) > 0 {
+		query := tx.Where("created_time >= ?", start)
+		if organization {
+			query = query.Where("organization_id IN ?", ids)
+		} else {
+			query = query.Where("user_id IN ? AND organization_id = 0", ids)
+		}
+		if err := query.Order("created_time asc, id asc").Find(&changes).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	billingModes := make(map[int]string, len(modes))
+	for _, change := range changes {
+		id := change.UserId
+		if organization {
+			id = change.OrganizationId
+		}
+		// 月初之后的第一条变更记录的原计费模式即为月初的计费模式
+		if _, ok := billingModes[id]; !ok {
+			billingModes[id] = change.PreviousMode
+		}
+		if change.CreatedTime < end && change.Mode == BillingModePostpaid {
+			billingModes[id] = BillingModePostpaid
+		}
+	}
+
+	// 月初之后没有变更过的，当前的计费模式即为账单月份的计费模式
+	for id, mode := range modes {
+		if _, ok := billingModes[id]; !ok {
+			billingModes[id] = mode
+		}
+	}
+
+	return billingModes, nil
+}
@@ -0,0 +1,149 @@
+package model
+
+import (
+	"one-api/common/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestGetCreditLimit(t *testing.T) {
+	tests := []struct {
+		mode        string
+		creditLimit int
+		want        int
+	}{
+		{BillingModePrepaid, 0, 0},
+		{BillingModePrepaid, 500, 0},
+		{BillingModePostpaid, 500, 500},
+		{"", 500, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			assert.Equal(t, tt.want, GetCreditLimit(tt.mode, tt.creditLimit))
+		})
+	}
+}
+
+func TestCheckOrganizationQuotaCreditLimit(t *testing.T) {
+	tests := []struct {
+		name         string
+		organization Organization
+		quota        int
+		wantErr      bool
+	}{
+		{
+			name:         "prepaid organization cannot overdraw",
+			organization: Organization{Quota: 100, BillingMode: BillingModePrepaid, CreditLimit: 500},
+			quota:        150,
+			wantErr:      true,
+		},
+		{
+			name:         "postpaid organization overdraws within the credit limit",
+			organization: Organization{Quota: 100, BillingMode: BillingModePostpaid, CreditLimit: 500},
+			quota:        600,
+		},
+		{
+			name:         "postpaid organization over the credit limit",
+			organization: Organization{Quota: 100, BillingMode: BillingModePostpaid, CreditLimit: 500},
+			quota:        601,
+			wantErr:      true,
+		},
+		{
+			name:         "postpaid organization already overdrawn",
+			organization: Organization{Quota: -400, BillingMode: BillingModePostpaid, CreditLimit: 500},
+			quota:        100,
+		},
+		{
+			name:         "postpaid organization with the credit limit used up",
+			organization: Organization{Quota: -500, BillingMode: BillingModePostpaid, CreditLimit: 500},
+			quota:        0,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			organization := tt.organization
+			organization.Status = OrganizationStatusEnabled
+			setupOrganizationTest(t, &organization, &OrganizationMember{UserId: 1, Role: OrganizationRoleMember})
+
+			_, err := CheckOrganizationQuota(organization.Id, 1, tt.quota)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrOrganizationQuotaNotEnough)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPreConsumeTokenQuotaCreditLimit(t *testing.T) {
+	savedThreshold := config.QuotaRemindThreshold
+	config.QuotaRemindThreshold = 0
+	t.Cleanup(func() {
+		config.QuotaRemindThreshold = savedThreshold
+	})
+
+	tests := []struct {
+		name      string
+		user      User
+		quota     int
+		wantErr   bool
+		wantQuota int
+	}{
+		{
+			name:      "prepaid user within quota",
+			user:      User{Quota: 100, BillingMode: BillingModePrepaid},
+			quota:     50,
+			wantQuota: 50,
+		},
+		{
+			name:      "prepaid user cannot overdraw",
+			user:      User{Quota: 100, BillingMode: BillingModePrepaid, CreditLimit: 500},
+			quota:     150,
+			wantErr:   true,
+			wantQuota: 100,
+		},
+		{
+			name:      "postpaid user overdraws within the credit limit",
+			user:      User{Quota: 100, BillingMode: BillingModePostpaid, CreditLimit: 500},
+			quota:     150,
+			wantQuota: -50,
+		},
+		{
+			name:      "postpaid user over the credit limit",
+			user:      User{Quota: 100, BillingMode: BillingModePostpaid, CreditLimit: 500},
+			quota:     700,
+			wantErr:   true,
+			wantQuota: 100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t, &Token{})
+
+			user := tt.user
+			user.Username = "billing"
+			require.NoError(t, DB.Create(&user).Error)
+			token := &Token{UserId: user.Id, Name: "test", Key: "sk-test", UnlimitedQuota: true}
+			// 跳过生成令牌 key 的钩子
+			require.NoError(t, DB.Session(&gorm.Session{SkipHooks: true}).Create(token).Error)
+
+			err := PreConsumeTokenQuota(token.Id, tt.quota)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			quota, err := GetUserQuota(user.Id)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantQuota, quota)
+		})
+	}
+}
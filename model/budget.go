@@ -0,0 +1,516 @@
+package model
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"one-api/common/cache"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/redis"
+	"one-api/common/utils"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	BudgetWindowDaily   = "daily"
+	BudgetWindowWeekly  = "weekly"
+	BudgetWindowMonthly = "monthly"
+
+	BudgetSubjectUser  = "user"
+	BudgetSubjectToken = "token"
+
+	// subject:subjectId:model:window:period
+	BudgetCacheKey = "budget:%s:%d:%s:%s:%s"
+
+	// 计数键在周期结束后多保留一段时间，避免结算时键已过期
+	budgetKeyGracePeriod = time.Hour
+	// 数据库中的预算用量保留天数
+	budgetUsageRetentionDays = 100
+)
+
+var budgetWindows = []string{BudgetWindowDaily, BudgetWindowWeekly, BudgetWindowMonthly}
+
+var (
+	//go:embed budgetreserve.lua
+	budgetReserveLuaScript string
+	budgetReserveScript    = redis.NewScript(budgetReserveLuaScript)
+
+	//go:embed budgetsettle.lua
+	budgetSettleLuaScript string
+	budgetSettleScript    = redis.NewScript(budgetSettleLuaScript)
+)
+
+// BudgetLimits 各周期的额度上限，0 为不限制
+type BudgetLimits struct {
+	Daily   int `json:"daily"`
+	Weekly  int `json:"weekly"`
+	Monthly int `json:"monthly"`
+}
+
+func (l BudgetLimits) get(window string) int {
+	switch window {
+	case BudgetWindowDaily:
+		return l.Daily
+	case BudgetWindowWeekly:
+		return l.Weekly
+	case BudgetWindowMonthly:
+		return l.Monthly
+	}
+	return 0
+}
+
+func (l BudgetLimits) validate() error {
+	if l.Daily < 0 || l.Weekly < 0 || l.Monthly < 0 {
+		return errors.New("预算上限不能小于 0")
+	}
+	return nil
+}
+
+// BudgetSetting 令牌或用户的预算，总额度上限对所有模型生效，模型上限只统计该模型的消耗
+type BudgetSetting struct {
+	Enabled bool `json:"enabled"`
+	BudgetLimits
+	Models map[string]BudgetLimits `json:"models,omitempty"`
+}
+
+func (s *BudgetSetting) Validate() error {
+	if s == nil {
+		return nil
+	}
+
+	if err := s.BudgetLimits.validate(); err != nil {
+		return err
+	}
+
+	for modelName, limits := range s.Models {
+		if err := limits.validate(); err != nil {
+			return fmt.Errorf("模型 %s 的%w", modelName, err)
+		}
+	}
+
+	return nil
+}
+
+// BudgetUsage 各周期已结算的预算用量，用于 Redis 或内存计数丢失后恢复
+type BudgetUsage struct {
+	Id        int    `json:"id"`
+	Subject   string `json:"subject" gorm:"type:varchar(16);uniqueIndex:idx_budget_usage,priority:1"`
+	SubjectId int    `json:"subject_id" gorm:"uniqueIndex:idx_budget_usage,priority:2"`
+	ModelName string `json:"model_name" gorm:"type:varchar(100);default:'';uniqueIndex:idx_budget_usage,priority:3"`
+	Window    string `json:"window" gorm:"column:budget_window;type:varchar(16);uniqueIndex:idx_budget_usage,priority:4"`
+	Period    string `json:"period" gorm:"type:varchar(16);uniqueIndex:idx_budget_usage,priority:5;index"`
+	Used      int    `json:"used" gorm:"default:0"`
+	UpdatedAt int64  `json:"updated_at" gorm:"bigint"`
+}
+
+// BudgetRule 一次请求需要检查的一个预算周期
+type BudgetRule struct {
+	Subject   string
+	SubjectId int
+	ModelName string // 为空表示统计所有模型
+	Window    string
+	Limit     int
+	Period    string
+	ResetAt   time.Time
+}
+
+func (r *BudgetRule) key() string {
+	return fmt.Sprintf(BudgetCacheKey, r.Subject, r.SubjectId, r.ModelName, r.Window, r.Period)
+}
+
+func (r *BudgetRule) Error(used int) error {
+	target := "token"
+	if r.Subject == BudgetSubjectUser {
+		target = "user"
+	}
+	if r.ModelName != "" {
+		target += fmt.Sprintf(" %s model", r.ModelName)
+	}
+
+	return fmt.Errorf("%s %s budget exceeded: used %d of %d, resets at %s", target, r.Window, used, r.Limit, r.ResetAt.Format(time.RFC3339))
+}
+
+// BudgetStatus 预算周期的使用情况
+type BudgetStatus struct {
+	Subject   string `json:"subject"`
+	SubjectId int    `json:"subject_id"`
+	ModelName string `json:"model_name"`
+	Window    string `json:"window"`
+	Limit     int    `json:"limit"`
+	Used      int    `json:"used"`
+	Remaining int    `json:"remaining"`
+	ResetAt   int64  `json:"reset_at"`
+}
+
+// budgetPeriod 计算周期标识和结束时间，周从周一开始，按服务器时区计算
+func budgetPeriod(window string, now time.Time) (string, time.Time) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch window {
+	case BudgetWindowWeekly:
+		start := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		return "W" + start.Format("20060102"), start.AddDate(0, 0, 7)
+	case BudgetWindowMonthly:
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		return start.Format("200601"), start.AddDate(0, 1, 0)
+	default:
+		return today.Format("20060102"), today.AddDate(0, 0, 1)
+	}
+}
+
+func appendBudgetRules(rules []*BudgetRule, subject string, subjectId int, modelName string, limits BudgetLimits, now time.Time) []*BudgetRule {
+	for _, window := range budgetWindows {
+		limit := limits.get(window)
+		if limit <= 0 {
+			continue
+		}
+
+		period, resetAt := budgetPeriod(window, now)
+		rules = append(rules, &BudgetRule{
+			Subject:   subject,
+			SubjectId: subjectId,
+			ModelName: modelName,
+			Window:    window,
+			Limit:     limit,
+			Period:    period,
+			ResetAt:   resetAt,
+		})
+	}
+
+	return rules
+}
+
+// budgetSettingRules 生成预算设置下所有的规则，modelName 为空时包含所有模型的规则
+func budgetSettingRules(rules []*BudgetRule, subject string, subjectId int, setting *BudgetSetting, modelName string, now time.Time) []*BudgetRule {
+	if setting == nil || !setting.Enabled || subjectId == 0 {
+		return rules
+	}
+
+	rules = appendBudgetRules(rules, subject, subjectId, "", setting.BudgetLimits, now)
+
+	if modelName != "" {
+		if limits, ok := setting.Models[modelName]; ok {
+			rules = appendBudgetRules(rules, subject, subjectId, modelName, limits, now)
+		}
+		return rules
+	}
+
+	modelNames := make([]string, 0, len(setting.Models))
+	for name := range setting.Models {
+		modelNames = append(modelNames, name)
+	}
+	sort.Strings(modelNames)
+	for _, name := range modelNames {
+		rules = appendBudgetRules(rules, subject, subjectId, name, setting.Models[name], now)
+	}
+
+	return rules
+}
+
+// GetBudgetRules 获取一次请求需要检查的预算规则
+func GetBudgetRules(userId int, userBudget *BudgetSetting, tokenId int, tokenBudget *BudgetSetting, modelName string) []*BudgetRule {
+	now := time.Now()
+	rules := budgetSettingRules(nil, BudgetSubjectUser, userId, userBudget, modelName, now)
+	return budgetSettingRules(rules, BudgetSubjectToken, tokenId, tokenBudget, modelName, now)
+}
+
+type budgetCounter struct {
+	used     int
+	expireAt time.Time
+}
+
+// BudgetManager 预算计数，开启 Redis 时多节点共享计数，否则保存在内存中
+type BudgetManager struct {
+	sync.Mutex
+	counters map[string]*budgetCounter
+}
+
+var Budgets = &BudgetManager{
+	counters: make(map[string]*budgetCounter),
+}
+
+// Reserve 原子地检查并预扣所有规则的额度，超出时返回对应规则和已用额度
+func (m *BudgetManager) Reserve(rules []*BudgetRule, amount int) (*BudgetRule, int, error) {
+	if len(rules) == 0 {
+		return nil, 0, nil
+	}
+
+	if config.RedisEnabled {
+		return m.reserveRedis(rules, amount)
+	}
+
+	m.loadCounters(rules)
+
+	m.Lock()
+	defer m.Unlock()
+
+	for _, rule := range rules {
+		used := m.counters[rule.key()].used
+		if used >= rule.Limit || used+amount > rule.Limit {
+			return rule, used, nil
+		}
+	}
+
+	for _, rule := range rules {
+		m.counters[rule.key()].used += amount
+	}
+
+	return nil, 0, nil
+}
+
+func (m *BudgetManager) reserveRedis(rules []*BudgetRule, amount int) (*BudgetRule, int, error) {
+	keys := make([]string, 0, len(rules))
+	args := make([]interface{}, 0, len(rules)+1)
+	args = append(args, amount)
+
+	for _, rule := range rules {
+		if err := m.initRedisKey(rule); err != nil {
+			return nil, 0, err
+		}
+		keys = append(keys, rule.key())
+		args = append(args, rule.Limit)
+	}
+
+	result, err := redis.ScriptRunCtx(context.Background(), budgetReserveScript, keys, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	index, ok := result.(int64)
+	if !ok || index <= 0 || int(index) > len(rules) {
+		return nil, 0, nil
+	}
+
+	rule := rules[index-1]
+	used, _ := redis.RedisGet(rule.key())
+	return rule, utils.String2Int(used), nil
+}
+
+// initRedisKey 计数键不存在时从数据库恢复已结算的用量
+func (m *BudgetManager) initRedisKey(rule *BudgetRule) error {
+	key := rule.key()
+	exists, err := redis.RedisExists(key)
+	if err != nil || exists {
+		return err
+	}
+
+	used, err := GetBudgetUsed(rule)
+	if err != nil {
+		return err
+	}
+
+	expiration := time.Until(rule.ResetAt) + budgetKeyGracePeriod
+	return redis.GetRedisClient().SetNX(context.Background(), key, used, expiration).Err()
+}
+
+// loadCounters 内存模式下从数据库恢复不存在的计数
+func (m *BudgetManager) loadCounters(rules []*BudgetRule) {
+	for _, rule := range rules {
+		key := rule.key()
+
+		m.Lock()
+		_, ok := m.counters[key]
+		m.Unlock()
+		if ok {
+			continue
+		}
+
+		used, err := GetBudgetUsed(rule)
+		if err != nil {
+			logger.SysError("get budget usage error: " + err.Error())
+		}
+
+		m.Lock()
+		if _, ok := m.counters[key]; !ok {
+			m.counters[key] = &budgetCounter{
+				used:     used,
+				expireAt: rule.ResetAt.Add(budgetKeyGracePeriod),
+			}
+		}
+		m.Unlock()
+	}
+}
+
+// Adjust 调整预扣的额度，请求失败时传入负数退回预扣
+func (m *BudgetManager) Adjust(rules []*BudgetRule, delta int) {
+	if len(rules) == 0 || delta == 0 {
+		return
+	}
+
+	if config.RedisEnabled {
+		keys := make([]string, 0, len(rules))
+		for _, rule := range rules {
+			keys = append(keys, rule.key())
+		}
+		if _, err := redis.ScriptRunCtx(context.Background(), budgetSettleScript, keys, delta); err != nil {
+			logger.SysError("adjust budget error: " + err.Error())
+		}
+		return
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	for _, rule := range rules {
+		if counter, ok := m.counters[rule.key()]; ok {
+			counter.used += delta
+		}
+	}
+}
+
+// Settle 结算实际消耗，修正预扣的差额并写入数据库
+func (m *BudgetManager) Settle(rules []*BudgetRule, reserved, quota int) {
+	if len(rules) == 0 {
+		return
+	}
+
+	m.Adjust(rules, quota-reserved)
+	if quota == 0 {
+		return
+	}
+
+	for _, rule := range rules {
+		if err := IncreaseBudgetUsage(rule, quota); err != nil {
+			logger.SysError("record budget usage error: " + err.Error())
+		}
+	}
+}
+
+// GetStatus 获取各规则当前周期的使用情况，包含尚未结算的预扣额度
+func (m *BudgetManager) GetStatus(rules []*BudgetRule) []*BudgetStatus {
+	statuses := make([]*BudgetStatus, 0, len(rules))
+
+	for _, rule := range rules {
+		used, err := m.getUsed(rule)
+		if err != nil {
+			logger.SysError("get budget usage error: " + err.Error())
+		}
+
+		statuses = append(statuses, &BudgetStatus{
+			Subject:   rule.Subject,
+			SubjectId: rule.SubjectId,
+			ModelName: rule.ModelName,
+			Window:    rule.Window,
+			Limit:     rule.Limit,
+			Used:      used,
+			Remaining: max(rule.Limit-used, 0),
+			ResetAt:   rule.ResetAt.Unix(),
+		})
+	}
+
+	return statuses
+}
+
+func (m *BudgetManager) getUsed(rule *BudgetRule) (int, error) {
+	if config.RedisEnabled {
+		value, err := redis.RedisGet(rule.key())
+		if err == nil {
+			return strconv.Atoi(value)
+		}
+		return GetBudgetUsed(rule)
+	}
+
+	m.Lock()
+	counter, ok := m.counters[rule.key()]
+	m.Unlock()
+	if ok {
+		return counter.used, nil
+	}
+
+	return GetBudgetUsed(rule)
+}
+
+// CleanExpired 清理已结束周期的内存计数和过期的用量记录，Redis 中的计数会自动过期
+func (m *BudgetManager) CleanExpired() {
+	now := time.Now()
+
+	m.Lock()
+	for key, counter := range m.counters {
+		if now.After(counter.expireAt) {
+			delete(m.counters, key)
+		}
+	}
+	m.Unlock()
+
+	if err := DeleteBudgetUsagesBefore(now.AddDate(0, 0, -budgetUsageRetentionDays).Unix()); err != nil {
+		logger.SysError("clean budget usage error: " + err.Error())
+	}
+}
+
+func GetBudgetUsed(rule *BudgetRule) (int, error) {
+	var usage BudgetUsage
+	err := DB.Where("subject = ? AND subject_id = ? AND model_name = ? AND budget_window = ? AND period = ?",
+		rule.Subject, rule.SubjectId, rule.ModelName, rule.Window, rule.Period).First(&usage).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+
+	return usage.Used, err
+}
+
+func IncreaseBudgetUsage(rule *BudgetRule, quota int) error {
+	usage := &BudgetUsage{
+		Subject:   rule.Subject,
+		SubjectId: rule.SubjectId,
+		ModelName: rule.ModelName,
+		Window:    rule.Window,
+		Period:    rule.Period,
+		Used:      quota,
+		UpdatedAt: utils.GetTimestamp(),
+	}
+
+	return DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "subject"}, {Name: "subject_id"}, {Name: "model_name"}, {Name: "budget_window"}, {Name: "period"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"used":       gorm.Expr("used + ?", quota),
+			"updated_at": usage.UpdatedAt,
+		}),
+	}).Create(usage).Error
+}
+
+func DeleteBudgetUsagesBefore(timestamp int64) error {
+	return DB.Where("updated_at < ?", timestamp).Delete(&BudgetUsage{}).Error
+}
+
+// CacheGetUserBudget 获取用户的预算设置，未设置时返回 nil
+func CacheGetUserBudget(userId int) (*BudgetSetting, error) {
+	if !config.RedisEnabled {
+		return GetUserBudget(userId)
+	}
+
+	budget, err := cache.GetOrSetCache(
+		fmt.Sprintf(UserBudgetCacheKey, userId),
+		time.Duration(TokenCacheSeconds)*time.Second,
+		func() (BudgetSetting, error) {
+			budget, err := GetUserBudget(userId)
+			if err != nil || budget == nil {
+				return BudgetSetting{}, err
+			}
+			return *budget, nil
+		},
+		cache.CacheTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return &budget, nil
+}
+
+func GetUserBudget(userId int) (*BudgetSetting, error) {
+	var user User
+	err := DB.Select("id", "budget").Where("id = ?", userId).First(&user).Error
+	if err != nil || user.Budget == nil {
+		return nil, err
+	}
+
+	budget := user.Budget.Data()
+	return &budget, nil
+}
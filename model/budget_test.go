@@ -0,0 +1,166 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBudgetManager(t *testing.T) *BudgetManager {
+	t.Helper()
+	setupTestDB(t, &BudgetUsage{})
+
+	return &BudgetManager{counters: make(map[string]*budgetCounter)}
+}
+
+func TestBudgetPeriod(t *testing.T) {
+	// 2025-09-17 是周三
+	now := time.Date(2025, 9, 17, 15, 30, 0, 0, time.Local)
+
+	tests := []struct {
+		window      string
+		wantPeriod  string
+		wantResetAt time.Time
+	}{
+		{BudgetWindowDaily, "20250917", time.Date(2025, 9, 18, 0, 0, 0, 0, time.Local)},
+		{BudgetWindowWeekly, "W20250915", time.Date(2025, 9, 22, 0, 0, 0, 0, time.Local)},
+		{BudgetWindowMonthly, "202509", time.Date(2025, 10, 1, 0, 0, 0, 0, time.Local)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.window, func(t *testing.T) {
+			period, resetAt := budgetPeriod(tt.window, now)
+			assert.Equal(t, tt.wantPeriod, period)
+			assert.Equal(t, tt.wantResetAt, resetAt)
+		})
+	}
+}
+
+func TestGetBudgetRules(t *testing.T) {
+	userBudget := &BudgetSetting{
+		Enabled:      true,
+		BudgetLimits: BudgetLimits{Daily: 100, Monthly: 1000},
+		Models:       map[string]BudgetLimits{"gpt-4o": {Daily: 50}},
+	}
+	tokenBudget := &BudgetSetting{Enabled: false, BudgetLimits: BudgetLimits{Daily: 10}}
+
+	rules := GetBudgetRules(1, userBudget, 2, tokenBudget, "gpt-4o")
+	require.Len(t, rules, 3)
+	assert.Equal(t, []string{"", "", "gpt-4o"}, []string{rules[0].ModelName, rules[1].ModelName, rules[2].ModelName})
+	assert.Equal(t, []string{BudgetWindowDaily, BudgetWindowMonthly, BudgetWindowDaily}, []string{rules[0].Window, rules[1].Window, rules[2].Window})
+
+	rules = GetBudgetRules(1, userBudget, 2, tokenBudget, "claude")
+	assert.Len(t, rules, 2)
+}
+
+func TestBudgetManagerReserve(t *testing.T) {
+	tests := []struct {
+		name      string
+		limits    []int
+		reserved  []int
+		amount    int
+		wantRule  int // 超出的规则下标，-1 表示预扣成功
+		wantUsed  int
+		wantAfter []int
+	}{
+		{
+			name:      "reserves every rule within limits",
+			limits:    []int{100, 1000},
+			amount:    60,
+			wantRule:  -1,
+			wantAfter: []int{60, 60},
+		},
+		{
+			name:      "rejects when any rule would be exceeded",
+			limits:    []int{100, 1000},
+			reserved:  []int{60},
+			amount:    50,
+			wantRule:  0,
+			wantUsed:  60,
+			wantAfter: []int{60, 60},
+		},
+		{
+			name:      "reserving up to the limit is allowed",
+			limits:    []int{100, 1000},
+			reserved:  []int{60},
+			amount:    40,
+			wantRule:  -1,
+			wantAfter: []int{100, 100},
+		},
+		{
+			name:      "rejects once the limit is used up",
+			limits:    []int{100, 100},
+			reserved:  []int{100},
+			amount:    0,
+			wantRule:  0,
+			wantUsed:  100,
+			wantAfter: []int{100, 100},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := newTestBudgetManager(t)
+
+			rules := make([]*BudgetRule, 0, len(tt.limits))
+			for i, limit := range tt.limits {
+				window := budgetWindows[i]
+				period, resetAt := budgetPeriod(window, time.Now())
+				rules = append(rules, &BudgetRule{Subject: BudgetSubjectToken, SubjectId: 1, Window: window, Limit: limit, Period: period, ResetAt: resetAt})
+			}
+
+			for _, amount := range tt.reserved {
+				rule, _, err := manager.Reserve(rules, amount)
+				require.NoError(t, err)
+				require.Nil(t, rule)
+			}
+
+			rule, used, err := manager.Reserve(rules, tt.amount)
+			require.NoError(t, err)
+			if tt.wantRule < 0 {
+				assert.Nil(t, rule)
+			} else {
+				assert.Same(t, rules[tt.wantRule], rule)
+				assert.Equal(t, tt.wantUsed, used)
+			}
+
+			for i, status := range manager.GetStatus(rules) {
+				assert.Equal(t, tt.wantAfter[i], status.Used)
+			}
+		})
+	}
+}
+
+func TestBudgetManagerSettle(t *testing.T) {
+	manager := newTestBudgetManager(t)
+
+	period, resetAt := budgetPeriod(BudgetWindowDaily, time.Now())
+	rules := []*BudgetRule{{Subject: BudgetSubjectUser, SubjectId: 1, Window: BudgetWindowDaily, Limit: 100, Period: period, ResetAt: resetAt}}
+
+	// 失败的请求退回预扣
+	rule, _, err := manager.Reserve(rules, 30)
+	require.NoError(t, err)
+	require.Nil(t, rule)
+	manager.Adjust(rules, -30)
+	assert.Equal(t, 0, manager.GetStatus(rules)[0].Used)
+
+	// 成功的请求按实际消耗结算
+	rule, _, err = manager.Reserve(rules, 30)
+	require.NoError(t, err)
+	require.Nil(t, rule)
+	manager.Settle(rules, 30, 45)
+	assert.Equal(t, 45, manager.GetStatus(rules)[0].Used)
+
+	used, err := GetBudgetUsed(rules[0])
+	require.NoError(t, err)
+	assert.Equal(t, 45, used)
+
+	// 计数丢失后从数据库恢复已结算的用量
+	restored := &BudgetManager{counters: make(map[string]*budgetCounter)}
+	rule, used, err = restored.Reserve(rules, 60)
+	require.NoError(t, err)
+	assert.Same(t, rules[0], rule)
+	assert.Equal(t, 45, used)
+}
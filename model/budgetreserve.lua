@@ -0,0 +1,21 @@
+-- 预扣预算，任一周期超出上限时返回其序号（从 1 开始），全部通过时增加计数并返回 0
+-- KEYS: 各周期的计数键
+-- ARGV[1]: 预扣额度
+-- ARGV[2..]: 各周期的额度上限
+local amount = tonumber(ARGV[1])
+
+for i, key in ipairs(KEYS) do
+    local used = tonumber(redis.call("GET", key) or "0")
+    local limit = tonumber(ARGV[i + 1])
+    if used >= limit or used + amount > limit then
+        return i
+    end
+end
+
+if amount ~= 0 then
+    for _, key in ipairs(KEYS) do
+        redis.call("INCRBY", key, amount)
+    end
+end
+
+return 0
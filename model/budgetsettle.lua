@@ -0,0 +1,12 @@
+-- 调整预算计数，只更新仍在当前周期内的键，避免创建没有过期时间的键
+-- KEYS: 各周期的计数键
+-- ARGV[1]: 调整的额度，可以为负数
+local delta = tonumber(ARGV[1])
+
+for _, key in ipairs(KEYS) do
+    if redis.call("EXISTS", key) == 1 then
+        redis.call("INCRBY", key, delta)
+    end
+end
+
+return 0
@@ -19,6 +19,8 @@ var (
 	UserQuotaCacheKey           = "user_quota:%d"
 	UserEnabledCacheKey         = "user_enabled:%d"
 	UserRealtimeQuotaKey        = "user_realtime_quota:%d"
+	UserBudgetCacheKey          = "user_budget:%d"
+	UserCreditLimitCacheKey     = "user_credit_limit:%d"
 	UserRealtimeQuotaExpiration = 24 * time.Hour
 
 	OldUserTokensCacheKey = "old_user_tokens_cache"
@@ -100,6 +102,21 @@ func CacheDecreaseUserQuota(id int, quota int) error {
 	return err
 }
 
+// CacheGetUserCreditLimit 获取后付费用户允许透支的额度
+func CacheGetUserCreditLimit(id int) (int, error) {
+	if !config.RedisEnabled {
+		return GetUserCreditLimit(id)
+	}
+
+	return cache.GetOrSetCache(
+		fmt.Sprintf(UserCreditLimitCacheKey, id),
+		time.Duration(TokenCacheSeconds)*time.Second,
+		func() (int, error) {
+			return GetUserCreditLimit(id)
+		},
+		cache.CacheTimeout)
+}
+
 func CacheIsUserEnabled(userId int) (bool, error) {
 	if !config.RedisEnabled {
 		return IsUserEnabled(userId)
@@ -40,11 +40,16 @@ type Channel struct {
 	OnlyChat           bool    `json:"only_chat" form:"only_chat" gorm:"default:false"`
 	PreCost            int     `json:"pre_cost" form:"pre_cost" gorm:"default:1"`
 	CompatibleResponse bool    `json:"compatible_response" gorm:"default:false"`
+	MaxRPM             int     `json:"max_rpm" gorm:"default:0"`         // 渠道每分钟允许的请求数，0 则不限制
+	MaxTPM             int     `json:"max_tpm" gorm:"default:0"`         // 渠道每分钟允许的 token 数，0 则不限制
+	MaxConcurrency     int     `json:"max_concurrency" gorm:"default:0"` // 渠道允许同时进行的请求数，0 则不限制
 
 	DisabledStream *datatypes.JSONSlice[string] `json:"disabled_stream,omitempty" gorm:"type:json"`
 
 	Plugin    *datatypes.JSONType[PluginType] `json:"plugin" form:"plugin" gorm:"type:json"`
 	DeletedAt gorm.DeletedAt                  `json:"-" gorm:"index"`
+
+	CircuitBreakers []*CircuitBreakerStatus `json:"circuit_breakers,omitempty" gorm:"-"`
 }
 
 func (c *Channel) AllowStream(modelName string) bool {
@@ -0,0 +1,130 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common/limit"
+	"one-api/common/logger"
+	"sync"
+)
+
+const (
+	channelRPMKey         = "channel-rpm:%d"
+	channelTPMKey         = "tpm-limiter:channel:%d"
+	channelConcurrencyKey = "channel-concurrency:%d"
+)
+
+// ErrChannelBusy 有可用渠道，但都处于熔断中或已达到 RPM、TPM、并发上限，稍后可能恢复
+var ErrChannelBusy = errors.New("all channels are busy")
+
+type channelRPMLimiter struct {
+	rpm     int
+	limiter limit.RateLimiter
+}
+
+// ChannelCapacityManager 渠道的 RPM、TPM 和并发上限，对应上游 key 的限额
+type ChannelCapacityManager struct {
+	sync.Mutex
+	rpmLimiters map[int]*channelRPMLimiter
+}
+
+var ChannelCapacity = &ChannelCapacityManager{
+	rpmLimiters: make(map[int]*channelRPMLimiter),
+}
+
+func ChannelTPMKey(channelId int) string {
+	return fmt.Sprintf(channelTPMKey, channelId)
+}
+
+// rpmLimiter 获取渠道的 RPM 限流器，渠道的 RPM 修改后重新创建
+func (m *ChannelCapacityManager) rpmLimiter(channel *Channel) limit.RateLimiter {
+	m.Lock()
+	defer m.Unlock()
+
+	item, ok := m.rpmLimiters[channel.Id]
+	if ok && item.rpm == channel.MaxRPM {
+		return item.limiter
+	}
+
+	if ok {
+		if memoryLimiter, isMemory := item.limiter.(*limit.MemoryLimiter); isMemory {
+			memoryLimiter.Stop()
+		}
+	}
+
+	item = &channelRPMLimiter{
+		rpm:     channel.MaxRPM,
+		limiter: limit.NewAPILimiter(channel.MaxRPM),
+	}
+	m.rpmLimiters[channel.Id] = item
+
+	return item.limiter
+}
+
+// Saturated 渠道是否已达到上限，只读取当前用量，不占用名额
+func (m *ChannelCapacityManager) Saturated(channel *Channel) bool {
+	if channel.MaxConcurrency > 0 {
+		current, err := limit.NewConcurrencyLimiter(channel.MaxConcurrency).GetCurrent(fmt.Sprintf(channelConcurrencyKey, channel.Id))
+		if err == nil && current >= channel.MaxConcurrency {
+			return true
+		}
+	}
+
+	if channel.MaxTPM > 0 {
+		used, err := limit.NewTPMLimiter(channel.MaxTPM).GetCurrentRate(ChannelTPMKey(channel.Id))
+		if err == nil && used >= channel.MaxTPM {
+			return true
+		}
+	}
+
+	if channel.MaxRPM > 0 {
+		used, err := m.rpmLimiter(channel).GetCurrentRate(fmt.Sprintf(channelRPMKey, channel.Id))
+		if err == nil && used >= channel.MaxRPM {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ChannelSlot Acquire 占用的渠道名额，限流器故障放行时没有占用并发名额，Release 不做任何操作
+type ChannelSlot struct {
+	channel *Channel
+	held    bool
+}
+
+// Acquire 占用渠道的并发名额并计入 RPM，返回 false 时不需要调用 Release
+func (m *ChannelCapacityManager) Acquire(channel *Channel) (*ChannelSlot, bool) {
+	slot := &ChannelSlot{channel: channel}
+	if channel.MaxConcurrency > 0 {
+		ok, err := limit.NewConcurrencyLimiter(channel.MaxConcurrency).Acquire(fmt.Sprintf(channelConcurrencyKey, channel.Id))
+		if err != nil {
+			// 限流器故障时放行请求
+			logger.SysError(fmt.Sprintf("channel #%d concurrency limiter error: %s", channel.Id, err.Error()))
+		} else if !ok {
+			return nil, false
+		}
+		slot.held = ok
+	}
+
+	if channel.MaxRPM > 0 && !m.rpmLimiter(channel).Allow(fmt.Sprintf(channelRPMKey, channel.Id)) {
+		slot.Release()
+		return nil, false
+	}
+
+	return slot, true
+}
+
+// Release 释放 Acquire 占用的并发名额，重复调用时只释放一次
+func (s *ChannelSlot) Release() {
+	if s == nil || !s.held {
+		return
+	}
+	s.held = false
+
+	err := limit.NewConcurrencyLimiter(s.channel.MaxConcurrency).Release(fmt.Sprintf(channelConcurrencyKey, s.channel.Id))
+	if err != nil {
+		logger.SysError(fmt.Sprintf("channel #%d concurrency release error: %s", s.channel.Id, err.Error()))
+	}
+	ChannelQueue.Notify()
+}
@@ -0,0 +1,88 @@
+package model
+
+import (
+	"fmt"
+	"one-api/common/config"
+	"one-api/common/limit"
+	"one-api/common/logger"
+	"one-api/common/redis"
+	"testing"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func channelConcurrency(t *testing.T, channel *Channel) int {
+	t.Helper()
+	current, err := limit.NewConcurrencyLimiter(channel.MaxConcurrency).GetCurrent(fmt.Sprintf(channelConcurrencyKey, channel.Id))
+	require.NoError(t, err)
+	return current
+}
+
+func TestChannelCapacityAcquireRelease(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	manager := &ChannelCapacityManager{rpmLimiters: make(map[int]*channelRPMLimiter)}
+	channel := &Channel{Id: 9101, MaxConcurrency: 2}
+
+	first, ok := manager.Acquire(channel)
+	require.True(t, ok)
+	second, ok := manager.Acquire(channel)
+	require.True(t, ok)
+	_, ok = manager.Acquire(channel)
+	assert.False(t, ok)
+	assert.True(t, manager.Saturated(channel))
+
+	first.Release()
+	// 重复释放只归还一次名额
+	first.Release()
+	assert.Equal(t, 1, channelConcurrency(t, channel))
+
+	second.Release()
+	assert.Equal(t, 0, channelConcurrency(t, channel))
+
+	var empty *ChannelSlot
+	assert.NotPanics(t, empty.Release)
+}
+
+func TestChannelCapacityRPMRejectReleasesConcurrency(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	manager := &ChannelCapacityManager{rpmLimiters: make(map[int]*channelRPMLimiter)}
+	channel := &Channel{Id: 9102, MaxConcurrency: 5, MaxRPM: 1}
+
+	slot, ok := manager.Acquire(channel)
+	require.True(t, ok)
+	_, ok = manager.Acquire(channel)
+	assert.False(t, ok)
+	assert.Equal(t, 1, channelConcurrency(t, channel))
+
+	slot.Release()
+	assert.Equal(t, 0, channelConcurrency(t, channel))
+}
+
+// 限流器故障时放行请求，但没有占用名额，释放时不能归还其他请求的名额
+func TestChannelCapacityAcquireFailOpen(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	manager := &ChannelCapacityManager{rpmLimiters: make(map[int]*channelRPMLimiter)}
+	channel := &Channel{Id: 9103, MaxConcurrency: 2}
+
+	held, ok := manager.Acquire(channel)
+	require.True(t, ok)
+
+	savedRDB, savedEnabled := redis.RDB, config.RedisEnabled
+	t.Cleanup(func() { redis.RDB, config.RedisEnabled = savedRDB, savedEnabled })
+	client := goredis.NewClient(&goredis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	redis.RDB, config.RedisEnabled = client, true
+
+	failOpen, ok := manager.Acquire(channel)
+	require.True(t, ok)
+
+	redis.RDB, config.RedisEnabled = savedRDB, savedEnabled
+	failOpen.Release()
+	assert.Equal(t, 1, channelConcurrency(t, channel))
+
+	held.Release()
+	assert.Equal(t, 0, channelConcurrency(t, channel))
+}
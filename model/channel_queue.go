@@ -0,0 +1,249 @@
+package model
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"one-api/common/config"
+	"one-api/metrics"
+	"sync"
+	"time"
+)
+
+const (
+	QueueClassHigh   = "high"
+	QueueClassNormal = "normal"
+	QueueClassLow    = "low"
+
+	// 渠道熔断恢复和 RPM、TPM 窗口滑动没有通知，队首的请求按该间隔重新尝试
+	channelQueuePollInterval = 200 * time.Millisecond
+)
+
+var queueClassPriority = map[string]int{
+	QueueClassHigh:   2,
+	QueueClassNormal: 1,
+	QueueClassLow:    0,
+}
+
+// ErrChannelQueueFull 排队的请求数已达到上限
+var ErrChannelQueueFull = errors.New("channel queue is full")
+
+func IsValidQueueClass(class string) bool {
+	if class == "" {
+		return true
+	}
+	_, ok := queueClassPriority[class]
+	return ok
+}
+
+// GetQueueClass 获取请求的排队优先级，令牌只能选择不高于用户分组的优先级
+func GetQueueClass(groupClass, tokenClass string) string {
+	if groupClass == "" {
+		groupClass = QueueClassNormal
+	}
+
+	if tokenClass != "" && queueClassPriority[tokenClass] < queueClassPriority[groupClass] {
+		return tokenClass
+	}
+
+	return groupClass
+}
+
+type channelWaiter struct {
+	priority int
+	seq      uint64
+	ready    chan struct{}
+	index    int
+}
+
+// channelWaiterHeap 优先级高的在前，同一优先级先到先得
+type channelWaiterHeap []*channelWaiter
+
+func (h channelWaiterHeap) Len() int { return len(h) }
+
+func (h channelWaiterHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h channelWaiterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *channelWaiterHeap) Push(x any) {
+	waiter := x.(*channelWaiter)
+	waiter.index = len(*h)
+	*h = append(*h, waiter)
+}
+
+func (h *channelWaiterHeap) Pop() any {
+	old := *h
+	n := len(old)
+	waiter := old[n-1]
+	old[n-1] = nil
+	waiter.index = -1
+	*h = old[:n-1]
+	return waiter
+}
+
+// ChannelQueueManager 渠道全部繁忙时按分组和模型排队，只有队首的请求会尝试选择渠道
+type ChannelQueueManager struct {
+	sync.Mutex
+	queues map[string]*channelWaiterHeap
+	seq    uint64
+}
+
+var ChannelQueue = &ChannelQueueManager{
+	queues: make(map[string]*channelWaiterHeap),
+}
+
+// Next 使用 next 选择渠道，渠道全部繁忙时排队等待，直到选到渠道、超时或客户端断开。
+// 队列中有不低于当前优先级的请求时直接排到其后，避免新请求插队
+func (m *ChannelQueueManager) Next(ctx context.Context, group, modelName, class string, next func() (*Channel, error)) (*Channel, error) {
+	key := fmt.Sprintf("%s:%s", group, modelName)
+	priority := queueClassPriority[class]
+
+	if config.ChannelQueueTimeout <= 0 {
+		return next()
+	}
+
+	err := ErrChannelBusy
+	if !m.hasWaitersAhead(key, priority) {
+		channel, nextErr := next()
+		if !errors.Is(nextErr, ErrChannelBusy) {
+			return channel, nextErr
+		}
+		err = nextErr
+	}
+
+	waiter := m.enter(key, priority)
+	if waiter == nil {
+		metrics.RecordChannelQueueWait(modelName, class, "rejected", 0)
+		return nil, ErrChannelQueueFull
+	}
+	metrics.AddChannelQueueDepth(modelName, class, 1)
+
+	startTime := time.Now()
+	result := "acquired"
+	defer func() {
+		m.leave(key, waiter)
+		metrics.AddChannelQueueDepth(modelName, class, -1)
+		metrics.RecordChannelQueueWait(modelName, class, result, time.Since(startTime))
+	}()
+
+	deadline := time.NewTimer(time.Duration(config.ChannelQueueTimeout) * time.Millisecond)
+	defer deadline.Stop()
+
+	for {
+		isHead := m.isHead(key, waiter)
+		if isHead {
+			channel, nextErr := next()
+			if !errors.Is(nextErr, ErrChannelBusy) {
+				return channel, nextErr
+			}
+			err = nextErr
+		}
+
+		var poll <-chan time.Time
+		if isHead {
+			poll = time.After(channelQueuePollInterval)
+		}
+
+		select {
+		case <-waiter.ready:
+		case <-poll:
+		case <-deadline.C:
+			result = "timeout"
+			return nil, err
+		case <-ctx.Done():
+			result = "cancelled"
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (m *ChannelQueueManager) hasWaitersAhead(key string, priority int) bool {
+	m.Lock()
+	defer m.Unlock()
+
+	queue, ok := m.queues[key]
+	return ok && queue.Len() > 0 && (*queue)[0].priority >= priority
+}
+
+func (m *ChannelQueueManager) enter(key string, priority int) *channelWaiter {
+	m.Lock()
+	defer m.Unlock()
+
+	queue, ok := m.queues[key]
+	if !ok {
+		queue = &channelWaiterHeap{}
+		m.queues[key] = queue
+	}
+
+	if config.ChannelQueueMaxSize > 0 && queue.Len() >= config.ChannelQueueMaxSize {
+		return nil
+	}
+
+	m.seq++
+	waiter := &channelWaiter{
+		priority: priority,
+		seq:      m.seq,
+		ready:    make(chan struct{}, 1),
+	}
+	heap.Push(queue, waiter)
+
+	return waiter
+}
+
+// leave 移出队列并唤醒新的队首，离开的请求可能刚选到渠道，其他渠道也可能有空闲
+func (m *ChannelQueueManager) leave(key string, waiter *channelWaiter) {
+	m.Lock()
+	defer m.Unlock()
+
+	queue, ok := m.queues[key]
+	if !ok {
+		return
+	}
+
+	if waiter.index >= 0 {
+		heap.Remove(queue, waiter.index)
+	}
+
+	if queue.Len() == 0 {
+		delete(m.queues, key)
+		return
+	}
+	wakeWaiter((*queue)[0])
+}
+
+func (m *ChannelQueueManager) isHead(key string, waiter *channelWaiter) bool {
+	m.Lock()
+	defer m.Unlock()
+
+	queue, ok := m.queues[key]
+	return ok && queue.Len() > 0 && (*queue)[0] == waiter
+}
+
+// Notify 渠道释放名额时唤醒所有队列的队首
+func (m *ChannelQueueManager) Notify() {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, queue := range m.queues {
+		if queue.Len() > 0 {
+			wakeWaiter((*queue)[0])
+		}
+	}
+}
+
+func wakeWaiter(waiter *channelWaiter) {
+	select {
+	case waiter.ready <- struct{}{}:
+	default:
+	}
+}
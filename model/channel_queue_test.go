@@ -0,0 +1,181 @@
+package model
+
+import (
+	"context"
+	"one-api/common/config"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestChannelQueue(t *testing.T, timeout, maxSize int) *ChannelQueueManager {
+	t.Helper()
+
+	savedTimeout, savedMaxSize := config.ChannelQueueTimeout, config.ChannelQueueMaxSize
+	config.ChannelQueueTimeout, config.ChannelQueueMaxSize = timeout, maxSize
+	t.Cleanup(func() {
+		config.ChannelQueueTimeout, config.ChannelQueueMaxSize = savedTimeout, savedMaxSize
+	})
+
+	return &ChannelQueueManager{queues: make(map[string]*channelWaiterHeap)}
+}
+
+func TestGetQueueClass(t *testing.T) {
+	tests := []struct {
+		groupClass string
+		tokenClass string
+		want       string
+	}{
+		{"", "", QueueClassNormal},
+		{QueueClassHigh, "", QueueClassHigh},
+		{QueueClassHigh, QueueClassLow, QueueClassLow},
+		{QueueClassNormal, QueueClassHigh, QueueClassNormal},
+		{QueueClassLow, QueueClassNormal, QueueClassLow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.groupClass+"/"+tt.tokenClass, func(t *testing.T) {
+			assert.Equal(t, tt.want, GetQueueClass(tt.groupClass, tt.tokenClass))
+		})
+	}
+}
+
+func TestChannelQueueOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		classes []string
+		want    []int // 按出队顺序排列的入队下标
+	}{
+		{
+			name:    "same class is first come first served",
+			classes: []string{QueueClassNormal, QueueClassNormal, QueueClassNormal},
+			want:    []int{0, 1, 2},
+		},
+		{
+			name:    "higher class is served first",
+			classes: []string{QueueClassLow, QueueClassNormal, QueueClassHigh},
+			want:    []int{2, 1, 0},
+		},
+		{
+			name:    "mixed classes keep arrival order within a class",
+			classes: []string{QueueClassNormal, QueueClassHigh, QueueClassLow, QueueClassHigh, QueueClassNormal},
+			want:    []int{1, 3, 0, 4, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := newTestChannelQueue(t, 1000, 0)
+
+			waiters := make([]*channelWaiter, 0, len(tt.classes))
+			for _, class := range tt.classes {
+				waiter := queue.enter("default:gpt-4o", queueClassPriority[class])
+				require.NotNil(t, waiter)
+				waiters = append(waiters, waiter)
+			}
+
+			for _, index := range tt.want {
+				assert.True(t, queue.isHead("default:gpt-4o", waiters[index]))
+				queue.leave("default:gpt-4o", waiters[index])
+			}
+			assert.Empty(t, queue.queues)
+		})
+	}
+}
+
+func TestChannelQueueNext(t *testing.T) {
+	channel := &Channel{Id: 1}
+
+	tests := []struct {
+		name      string
+		maxSize   int
+		waiting   []string // 已在队列中等待的请求
+		class     string
+		busyCalls int // next 返回繁忙的次数，-1 表示一直繁忙
+		wantErr   error
+		wantCalls int // -1 表示不检查，超时前的尝试次数取决于轮询间隔
+	}{
+		{
+			name:      "selects a channel without queueing",
+			class:     QueueClassNormal,
+			wantCalls: 1,
+		},
+		{
+			name:      "waits at the head until a channel frees up",
+			class:     QueueClassNormal,
+			busyCalls: 2,
+			wantCalls: 3,
+		},
+		{
+			name:      "times out while channels stay busy",
+			class:     QueueClassNormal,
+			busyCalls: -1,
+			wantErr:   ErrChannelBusy,
+			wantCalls: -1,
+		},
+		{
+			name:    "rejects when the queue is full",
+			maxSize: 1,
+			waiting: []string{QueueClassNormal},
+			class:   QueueClassHigh,
+			// 高优先级请求先尝试一次，繁忙后无法入队
+			busyCalls: -1,
+			wantErr:   ErrChannelQueueFull,
+			wantCalls: 1,
+		},
+		{
+			name:      "does not jump ahead of waiters with the same class",
+			waiting:   []string{QueueClassNormal},
+			class:     QueueClassNormal,
+			busyCalls: -1,
+			wantErr:   ErrChannelBusy,
+			wantCalls: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := newTestChannelQueue(t, 300, tt.maxSize)
+			for _, class := range tt.waiting {
+				require.NotNil(t, queue.enter("default:gpt-4o", queueClassPriority[class]))
+			}
+
+			calls := 0
+			got, err := queue.Next(context.Background(), "default", "gpt-4o", tt.class, func() (*Channel, error) {
+				calls++
+				if tt.busyCalls < 0 || calls <= tt.busyCalls {
+					return nil, ErrChannelBusy
+				}
+				return channel, nil
+			})
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, got)
+			} else {
+				require.NoError(t, err)
+				assert.Same(t, channel, got)
+			}
+			if tt.wantCalls >= 0 {
+				assert.Equal(t, tt.wantCalls, calls)
+			}
+		})
+	}
+}
+
+func TestChannelQueueNextCancelled(t *testing.T) {
+	queue := newTestChannelQueue(t, 5000, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	startTime := time.Now()
+	_, err := queue.Next(ctx, "default", "gpt-4o", QueueClassNormal, func() (*Channel, error) {
+		return nil, ErrChannelBusy
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(startTime), time.Second)
+	assert.Empty(t, queue.queues)
+}
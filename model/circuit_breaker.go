@@ -0,0 +1,507 @@
+package model
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/redis"
+	"one-api/metrics"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	CircuitStateClosed   = "closed"
+	CircuitStateOpen     = "open"
+	CircuitStateHalfOpen = "half_open"
+
+	CircuitBreakerCacheKey = "circuit_breaker:%d:%s"
+	CircuitBreakerIndexKey = "circuit_breaker:keys"
+
+	circuitBreakerRedisExpiration = 24 * time.Hour
+	// Redis 模式下本地缓存状态的时间，减少每次选择渠道时的 Redis 访问
+	circuitBreakerLocalTTL = time.Second
+	// 半开状态下探测请求超过该时间未返回结果，视为丢失并释放名额
+	circuitBreakerProbeTimeout = 60
+
+	circuitBreakerOpAcquire = "acquire"
+	circuitBreakerOpRelease = "release"
+	circuitBreakerOpSuccess = "success"
+	circuitBreakerOpFailure = "failure"
+)
+
+var (
+	//go:embed circuitbreaker.lua
+	circuitBreakerLuaScript string
+	circuitBreakerScript    = redis.NewScript(circuitBreakerLuaScript)
+)
+
+// CircuitBreakerState 渠道+模型的熔断状态
+type CircuitBreakerState struct {
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	WindowStart         int64  `json:"window_start"`
+	WindowRequests      int    `json:"window_requests"`
+	WindowFailures      int    `json:"window_failures"`
+	OpenUntil           int64  `json:"open_until"`
+	Backoff             int    `json:"backoff"` // 连续熔断次数，用于指数退避
+	Probes              int    `json:"probes"`
+	ProbeSuccesses      int    `json:"probe_successes"`
+	UpdatedAt           int64  `json:"updated_at"`
+}
+
+// CircuitBreakerStatus 对外展示的熔断状态
+type CircuitBreakerStatus struct {
+	ChannelId int    `json:"channel_id"`
+	Model     string `json:"model"`
+	*CircuitBreakerState
+}
+
+func newCircuitBreakerState() *CircuitBreakerState {
+	return &CircuitBreakerState{State: CircuitStateClosed}
+}
+
+// available 是否允许请求进入，不改变状态
+func (s *CircuitBreakerState) available(now int64) bool {
+	switch s.State {
+	case CircuitStateOpen:
+		return now >= s.OpenUntil && config.CircuitBreakerHalfOpenProbes > 0
+	case CircuitStateHalfOpen:
+		return s.Probes < config.CircuitBreakerHalfOpenProbes || now-s.UpdatedAt > circuitBreakerProbeTimeout
+	default:
+		return true
+	}
+}
+
+// acquire 占用半开状态的探测名额，返回是否可以发送请求
+func (s *CircuitBreakerState) acquire(now int64) bool {
+	if s.State == CircuitStateOpen {
+		if now < s.OpenUntil {
+			return false
+		}
+		s.State = CircuitStateHalfOpen
+		s.Probes = 0
+		s.ProbeSuccesses = 0
+	}
+
+	if s.State != CircuitStateHalfOpen {
+		return true
+	}
+
+	if s.Probes >= config.CircuitBreakerHalfOpenProbes {
+		if now-s.UpdatedAt <= circuitBreakerProbeTimeout {
+			return false
+		}
+		s.Probes = 0
+	}
+
+	s.Probes++
+	s.UpdatedAt = now
+	return true
+}
+
+func (s *CircuitBreakerState) rollWindow(now int64) {
+	if now-s.WindowStart >= int64(config.CircuitBreakerWindowSeconds) {
+		s.WindowStart = now
+		s.WindowRequests = 0
+		s.WindowFailures = 0
+	}
+}
+
+func (s *CircuitBreakerState) onSuccess(now int64) {
+	switch s.State {
+	case CircuitStateHalfOpen:
+		s.UpdatedAt = now
+		if s.Probes > 0 {
+			s.Probes--
+		}
+		s.ProbeSuccesses++
+		if s.ProbeSuccesses >= config.CircuitBreakerHalfOpenProbes {
+			*s = CircuitBreakerState{State: CircuitStateClosed, WindowStart: now, UpdatedAt: now}
+		}
+	case CircuitStateClosed:
+		// 每次成功都计入窗口请求数，否则错误率只按失败之后的请求计算
+		s.UpdatedAt = now
+		s.rollWindow(now)
+		s.WindowRequests++
+		s.ConsecutiveFailures = 0
+	}
+}
+
+// release 归还占用但没有发出请求的探测名额
+func (s *CircuitBreakerState) release() {
+	if s.State == CircuitStateHalfOpen && s.Probes > 0 {
+		s.Probes--
+	}
+}
+
+// onFailure 记录失败，trip 为 true 时直接熔断（如上游限流）
+func (s *CircuitBreakerState) onFailure(now int64, trip bool) {
+	s.UpdatedAt = now
+
+	switch s.State {
+	case CircuitStateHalfOpen:
+		s.open(now)
+	case CircuitStateClosed:
+		s.rollWindow(now)
+		s.WindowRequests++
+		s.WindowFailures++
+		s.ConsecutiveFailures++
+
+		overErrorRate := s.WindowRequests >= config.CircuitBreakerMinRequests &&
+			float64(s.WindowFailures)/float64(s.WindowRequests) >= config.CircuitBreakerErrorRate
+		overFailures := config.CircuitBreakerFailureThreshold > 0 && s.ConsecutiveFailures >= config.CircuitBreakerFailureThreshold
+
+		if trip || overErrorRate || overFailures {
+			s.open(now)
+		}
+	}
+}
+
+// open 熔断时长按连续熔断次数指数增长，最长不超过 CircuitBreakerMaxOpenSeconds
+func (s *CircuitBreakerState) open(now int64) {
+	duration := int64(config.RetryCooldownSeconds)
+	if duration <= 0 {
+		duration = 1
+	}
+	for i := 0; i < s.Backoff && duration < int64(config.CircuitBreakerMaxOpenSeconds); i++ {
+		duration *= 2
+	}
+	if config.CircuitBreakerMaxOpenSeconds > 0 && duration > int64(config.CircuitBreakerMaxOpenSeconds) {
+		duration = int64(config.CircuitBreakerMaxOpenSeconds)
+	}
+
+	s.State = CircuitStateOpen
+	s.OpenUntil = now + duration
+	s.Backoff++
+	s.ConsecutiveFailures = 0
+	s.WindowStart = now
+	s.WindowRequests = 0
+	s.WindowFailures = 0
+	s.Probes = 0
+	s.ProbeSuccesses = 0
+}
+
+type circuitBreakerCache struct {
+	state    *CircuitBreakerState
+	expireAt time.Time
+}
+
+// CircuitBreakerManager 熔断状态存储，开启 Redis 时多节点共享
+type CircuitBreakerManager struct {
+	mu     sync.Mutex
+	states map[string]*CircuitBreakerState
+	cache  sync.Map // key -> *circuitBreakerCache，仅 Redis 模式使用
+}
+
+var CircuitBreakers = &CircuitBreakerManager{
+	states: make(map[string]*CircuitBreakerState),
+}
+
+func circuitBreakerKey(channelId int, modelName string) string {
+	return fmt.Sprintf(CircuitBreakerCacheKey, channelId, modelName)
+}
+
+func (m *CircuitBreakerManager) enabled(channelId int, modelName string) bool {
+	return config.CircuitBreakerEnabled && channelId > 0 && modelName != ""
+}
+
+// Available 渠道是否可以接收请求，用于选择渠道时过滤
+func (m *CircuitBreakerManager) Available(channelId int, modelName string) bool {
+	if !m.enabled(channelId, modelName) {
+		return true
+	}
+
+	state := m.load(circuitBreakerKey(channelId, modelName))
+	return state.available(time.Now().Unix())
+}
+
+// Acquire 选中渠道后调用，半开状态下占用探测名额
+func (m *CircuitBreakerManager) Acquire(channelId int, modelName string) bool {
+	if !m.enabled(channelId, modelName) {
+		return true
+	}
+
+	// 关闭状态无需修改，避免每次请求都写入
+	if state := m.load(circuitBreakerKey(channelId, modelName)); state.State == CircuitStateClosed {
+		return true
+	}
+
+	return m.update(channelId, modelName, circuitBreakerOpAcquire, false)
+}
+
+// Release 选中渠道后没有发出请求（命中缓存、限流等）时调用，归还半开状态的探测名额
+func (m *CircuitBreakerManager) Release(channelId int, modelName string) {
+	if !m.enabled(channelId, modelName) {
+		return
+	}
+
+	if state := m.load(circuitBreakerKey(channelId, modelName)); state.State != CircuitStateHalfOpen {
+		return
+	}
+
+	m.update(channelId, modelName, circuitBreakerOpRelease, false)
+}
+
+func (m *CircuitBreakerManager) RecordSuccess(channelId int, modelName string) {
+	if !m.enabled(channelId, modelName) {
+		return
+	}
+
+	m.update(channelId, modelName, circuitBreakerOpSuccess, false)
+}
+
+func (m *CircuitBreakerManager) RecordFailure(channelId int, modelName string, trip bool) {
+	if !m.enabled(channelId, modelName) {
+		return
+	}
+
+	m.update(channelId, modelName, circuitBreakerOpFailure, trip)
+}
+
+// Reset 手动恢复渠道下所有模型的熔断状态
+func (m *CircuitBreakerManager) Reset(channelId int) error {
+	prefix := fmt.Sprintf(CircuitBreakerCacheKey, channelId, "")
+
+	if !config.RedisEnabled {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for key := range m.states {
+			if strings.HasPrefix(key, prefix) {
+				delete(m.states, key)
+			}
+		}
+		return nil
+	}
+
+	keys, err := redis.GetRedisClient().SMembers(context.Background(), CircuitBreakerIndexKey).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		redis.RedisDel(key)
+		redis.GetRedisClient().SRem(context.Background(), CircuitBreakerIndexKey, key)
+		m.cache.Delete(key)
+	}
+
+	return nil
+}
+
+// GetChannelStatus 获取渠道下各模型的熔断状态
+func (m *CircuitBreakerManager) GetChannelStatus(channelId int) []*CircuitBreakerStatus {
+	prefix := fmt.Sprintf(CircuitBreakerCacheKey, channelId, "")
+	statuses := make([]*CircuitBreakerStatus, 0)
+
+	for key, state := range m.all() {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		statuses = append(statuses, &CircuitBreakerStatus{
+			ChannelId:           channelId,
+			Model:               strings.TrimPrefix(key, prefix),
+			CircuitBreakerState: state,
+		})
+	}
+
+	return statuses
+}
+
+func (m *CircuitBreakerManager) all() map[string]*CircuitBreakerState {
+	states := make(map[string]*CircuitBreakerState)
+
+	if !config.RedisEnabled {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for key, state := range m.states {
+			copied := *state
+			states[key] = &copied
+		}
+		return states
+	}
+
+	keys, err := redis.GetRedisClient().SMembers(context.Background(), CircuitBreakerIndexKey).Result()
+	if err != nil {
+		logger.SysError("get circuit breaker keys error: " + err.Error())
+		return states
+	}
+
+	for _, key := range keys {
+		exists, err := redis.RedisExists(key)
+		if err != nil {
+			continue
+		}
+		// 状态已过期，清理索引
+		if !exists {
+			redis.GetRedisClient().SRem(context.Background(), CircuitBreakerIndexKey, key)
+			continue
+		}
+
+		state, err := m.loadRedis(key)
+		if err != nil {
+			continue
+		}
+		states[key] = state
+	}
+
+	return states
+}
+
+func (m *CircuitBreakerManager) load(key string) *CircuitBreakerState {
+	if !config.RedisEnabled {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if state, ok := m.states[key]; ok {
+			copied := *state
+			return &copied
+		}
+		return newCircuitBreakerState()
+	}
+
+	if value, ok := m.cache.Load(key); ok {
+		cached := value.(*circuitBreakerCache)
+		if time.Now().Before(cached.expireAt) {
+			return cached.state
+		}
+	}
+
+	state, err := m.loadRedis(key)
+	if err != nil {
+		// Redis 异常时不影响请求
+		return newCircuitBreakerState()
+	}
+
+	m.setLocalCache(key, state)
+	return state
+}
+
+func (m *CircuitBreakerManager) loadRedis(key string) (*CircuitBreakerState, error) {
+	data, err := redis.RedisGet(key)
+	if errors.Is(err, redis.Nil) {
+		return newCircuitBreakerState(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := newCircuitBreakerState()
+	if err := json.Unmarshal([]byte(data), state); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+func (m *CircuitBreakerManager) setLocalCache(key string, state *CircuitBreakerState) {
+	m.cache.Store(key, &circuitBreakerCache{
+		state:    state,
+		expireAt: time.Now().Add(circuitBreakerLocalTTL),
+	})
+}
+
+// apply 执行一次状态变更，返回是否允许请求
+func (s *CircuitBreakerState) apply(op string, now int64, trip bool) bool {
+	switch op {
+	case circuitBreakerOpAcquire:
+		return s.acquire(now)
+	case circuitBreakerOpRelease:
+		s.release()
+	case circuitBreakerOpSuccess:
+		s.onSuccess(now)
+	case circuitBreakerOpFailure:
+		s.onFailure(now, trip)
+	}
+	return true
+}
+
+// update 原子更新状态，返回是否允许请求，Redis 模式下由 Lua 脚本完成同样的状态变更
+func (m *CircuitBreakerManager) update(channelId int, modelName, op string, trip bool) bool {
+	key := circuitBreakerKey(channelId, modelName)
+	now := time.Now().Unix()
+	var before, after string
+	acquired := true
+
+	if !config.RedisEnabled {
+		m.mu.Lock()
+		state, ok := m.states[key]
+		if !ok {
+			state = newCircuitBreakerState()
+			m.states[key] = state
+		}
+		before = state.State
+		acquired = state.apply(op, now, trip)
+		after = state.State
+		m.mu.Unlock()
+	} else {
+		var err error
+		before, after, acquired, err = m.updateRedis(key, op, now, trip)
+		if err != nil {
+			// Redis 异常时不影响请求
+			logger.SysError(fmt.Sprintf("update circuit breaker %s error: %s", key, err.Error()))
+			return true
+		}
+	}
+
+	if before != after {
+		logger.SysLog(fmt.Sprintf("channel #%d model %s circuit breaker %s -> %s", channelId, modelName, before, after))
+		metrics.RecordCircuitBreakerTransition(channelId, modelName, before, after)
+	}
+
+	return acquired
+}
+
+func (m *CircuitBreakerManager) updateRedis(key, op string, now int64, trip bool) (before, after string, acquired bool, err error) {
+	tripArg := 0
+	if trip {
+		tripArg = 1
+	}
+
+	result, err := redis.ScriptRunCtx(
+		context.Background(),
+		circuitBreakerScript,
+		[]string{key, CircuitBreakerIndexKey},
+		op,
+		now,
+		tripArg,
+		config.CircuitBreakerHalfOpenProbes,
+		circuitBreakerProbeTimeout,
+		config.CircuitBreakerWindowSeconds,
+		config.CircuitBreakerMinRequests,
+		config.CircuitBreakerErrorRate,
+		config.CircuitBreakerFailureThreshold,
+		config.RetryCooldownSeconds,
+		config.CircuitBreakerMaxOpenSeconds,
+		int64(circuitBreakerRedisExpiration.Seconds()),
+	)
+	if err != nil {
+		return
+	}
+
+	values, ok := result.([]interface{})
+	if !ok || len(values) < 4 {
+		err = errors.New("无法转换熔断状态更新结果")
+		return
+	}
+
+	before, _ = values[0].(string)
+	after, _ = values[1].(string)
+	data, _ := values[2].(string)
+	flag, _ := values[3].(int64)
+	acquired = flag == 1
+
+	state := newCircuitBreakerState()
+	if err = json.Unmarshal([]byte(data), state); err != nil {
+		return
+	}
+	m.setLocalCache(key, state)
+
+	return
+}
@@ -0,0 +1,254 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/redis"
+	"os"
+	"testing"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func setupCircuitBreakerConfig(t *testing.T) {
+	t.Helper()
+	logger.Logger = zap.NewNop()
+
+	for _, value := range []*int{&config.RetryCooldownSeconds, &config.CircuitBreakerFailureThreshold, &config.CircuitBreakerMinRequests,
+		&config.CircuitBreakerWindowSeconds, &config.CircuitBreakerMaxOpenSeconds, &config.CircuitBreakerHalfOpenProbes} {
+		saved := *value
+		t.Cleanup(func() { *value = saved })
+	}
+	savedRate := config.CircuitBreakerErrorRate
+	t.Cleanup(func() { config.CircuitBreakerErrorRate = savedRate })
+
+	config.RetryCooldownSeconds = 5
+	config.CircuitBreakerFailureThreshold = 3
+	config.CircuitBreakerErrorRate = 0.5
+	config.CircuitBreakerMinRequests = 4
+	config.CircuitBreakerWindowSeconds = 60
+	config.CircuitBreakerMaxOpenSeconds = 300
+	config.CircuitBreakerHalfOpenProbes = 1
+}
+
+type circuitBreakerStep struct {
+	op       string
+	now      int64
+	trip     bool
+	acquired bool
+	state    string
+}
+
+// circuitBreakerTransitionTests 同时用于 Go 状态机和 Redis Lua 脚本，保证两者的状态变更一致
+var circuitBreakerTransitionTests = []struct {
+	name             string
+	failureThreshold int
+	steps            []circuitBreakerStep
+}{
+	{
+		name:             "consecutive failures open the breaker and a successful probe closes it",
+		failureThreshold: 3,
+		steps: []circuitBreakerStep{
+			{op: circuitBreakerOpFailure, now: 0, acquired: true, state: CircuitStateClosed},
+			{op: circuitBreakerOpFailure, now: 1, acquired: true, state: CircuitStateClosed},
+			{op: circuitBreakerOpFailure, now: 2, acquired: true, state: CircuitStateOpen},
+			{op: circuitBreakerOpAcquire, now: 3, acquired: false, state: CircuitStateOpen},
+			{op: circuitBreakerOpAcquire, now: 7, acquired: true, state: CircuitStateHalfOpen},
+			{op: circuitBreakerOpAcquire, now: 8, acquired: false, state: CircuitStateHalfOpen},
+			{op: circuitBreakerOpSuccess, now: 9, acquired: true, state: CircuitStateClosed},
+			{op: circuitBreakerOpAcquire, now: 10, acquired: true, state: CircuitStateClosed},
+		},
+	},
+	{
+		name:             "upstream rate limit trips immediately",
+		failureThreshold: 3,
+		steps: []circuitBreakerStep{
+			{op: circuitBreakerOpFailure, now: 0, trip: true, acquired: true, state: CircuitStateOpen},
+			{op: circuitBreakerOpAcquire, now: 4, acquired: false, state: CircuitStateOpen},
+		},
+	},
+	{
+		name:             "failed probe reopens with doubled duration",
+		failureThreshold: 3,
+		steps: []circuitBreakerStep{
+			{op: circuitBreakerOpFailure, now: 0, trip: true, acquired: true, state: CircuitStateOpen},
+			{op: circuitBreakerOpAcquire, now: 5, acquired: true, state: CircuitStateHalfOpen},
+			{op: circuitBreakerOpFailure, now: 6, acquired: true, state: CircuitStateOpen},
+			{op: circuitBreakerOpAcquire, now: 15, acquired: false, state: CircuitStateOpen},
+			{op: circuitBreakerOpAcquire, now: 16, acquired: true, state: CircuitStateHalfOpen},
+		},
+	},
+	{
+		name:             "lost probe is released after timeout",
+		failureThreshold: 3,
+		steps: []circuitBreakerStep{
+			{op: circuitBreakerOpFailure, now: 0, trip: true, acquired: true, state: CircuitStateOpen},
+			{op: circuitBreakerOpAcquire, now: 5, acquired: true, state: CircuitStateHalfOpen},
+			{op: circuitBreakerOpAcquire, now: 5 + circuitBreakerProbeTimeout, acquired: false, state: CircuitStateHalfOpen},
+			{op: circuitBreakerOpAcquire, now: 6 + circuitBreakerProbeTimeout, acquired: true, state: CircuitStateHalfOpen},
+		},
+	},
+	{
+		name:             "error rate within the window opens the breaker",
+		failureThreshold: 0,
+		steps: []circuitBreakerStep{
+			{op: circuitBreakerOpFailure, now: 0, acquired: true, state: CircuitStateClosed},
+			{op: circuitBreakerOpSuccess, now: 1, acquired: true, state: CircuitStateClosed},
+			{op: circuitBreakerOpSuccess, now: 2, acquired: true, state: CircuitStateClosed},
+			{op: circuitBreakerOpFailure, now: 3, acquired: true, state: CircuitStateOpen},
+		},
+	},
+	{
+		name:             "window expiry resets the error rate",
+		failureThreshold: 0,
+		steps: []circuitBreakerStep{
+			{op: circuitBreakerOpFailure, now: 0, acquired: true, state: CircuitStateClosed},
+			{op: circuitBreakerOpFailure, now: 1, acquired: true, state: CircuitStateClosed},
+			{op: circuitBreakerOpSuccess, now: 2, acquired: true, state: CircuitStateClosed},
+			{op: circuitBreakerOpFailure, now: 61, acquired: true, state: CircuitStateClosed},
+		},
+	},
+	{
+		name:             "released probe can be acquired again",
+		failureThreshold: 3,
+		steps: []circuitBreakerStep{
+			{op: circuitBreakerOpFailure, now: 0, trip: true, acquired: true, state: CircuitStateOpen},
+			{op: circuitBreakerOpAcquire, now: 5, acquired: true, state: CircuitStateHalfOpen},
+			{op: circuitBreakerOpAcquire, now: 6, acquired: false, state: CircuitStateHalfOpen},
+			{op: circuitBreakerOpRelease, now: 6, acquired: true, state: CircuitStateHalfOpen},
+			{op: circuitBreakerOpRelease, now: 6, acquired: true, state: CircuitStateHalfOpen},
+			{op: circuitBreakerOpAcquire, now: 7, acquired: true, state: CircuitStateHalfOpen},
+			{op: circuitBreakerOpSuccess, now: 8, acquired: true, state: CircuitStateClosed},
+			{op: circuitBreakerOpRelease, now: 9, acquired: true, state: CircuitStateClosed},
+		},
+	},
+	{
+		name:             "successes before the first failure count towards the error rate",
+		failureThreshold: 0,
+		steps: []circuitBreakerStep{
+			{op: circuitBreakerOpSuccess, now: 0, acquired: true, state: CircuitStateClosed},
+			{op: circuitBreakerOpSuccess, now: 1, acquired: true, state: CircuitStateClosed},
+			{op: circuitBreakerOpSuccess, now: 2, acquired: true, state: CircuitStateClosed},
+			{op: circuitBreakerOpFailure, now: 3, acquired: true, state: CircuitStateClosed},
+			{op: circuitBreakerOpSuccess, now: 4, acquired: true, state: CircuitStateClosed},
+			{op: circuitBreakerOpFailure, now: 5, acquired: true, state: CircuitStateClosed},
+			{op: circuitBreakerOpFailure, now: 6, acquired: true, state: CircuitStateClosed},
+			{op: circuitBreakerOpFailure, now: 7, acquired: true, state: CircuitStateOpen},
+		},
+	},
+}
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	for _, tt := range circuitBreakerTransitionTests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupCircuitBreakerConfig(t)
+			config.CircuitBreakerFailureThreshold = tt.failureThreshold
+
+			state := newCircuitBreakerState()
+			for i, s := range tt.steps {
+				acquired := state.apply(s.op, s.now, s.trip)
+				assert.Equal(t, s.acquired, acquired, "step %d acquired", i)
+				assert.Equal(t, s.state, state.State, "step %d state", i)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerCountsEverySuccess(t *testing.T) {
+	setupCircuitBreakerConfig(t)
+
+	state := newCircuitBreakerState()
+	state.apply(circuitBreakerOpSuccess, 100, false)
+	state.apply(circuitBreakerOpSuccess, 101, false)
+	assert.Equal(t, CircuitStateClosed, state.State)
+	assert.Equal(t, 2, state.WindowRequests)
+	assert.Equal(t, int64(100), state.WindowStart)
+}
+
+func TestCircuitBreakerManager(t *testing.T) {
+	setupCircuitBreakerConfig(t)
+
+	manager := &CircuitBreakerManager{states: make(map[string]*CircuitBreakerState)}
+	assert.True(t, manager.Available(1, "gpt-4o"))
+
+	manager.RecordFailure(1, "gpt-4o", true)
+	assert.False(t, manager.Available(1, "gpt-4o"))
+	assert.False(t, manager.Acquire(1, "gpt-4o"))
+	assert.True(t, manager.Available(1, "gpt-4o-mini"))
+	assert.True(t, manager.Available(2, "gpt-4o"))
+	assert.Len(t, manager.GetChannelStatus(1), 1)
+
+	assert.NoError(t, manager.Reset(1))
+	assert.True(t, manager.Available(1, "gpt-4o"))
+	assert.Empty(t, manager.GetChannelStatus(1))
+}
+
+func TestCircuitBreakerManagerRelease(t *testing.T) {
+	setupCircuitBreakerConfig(t)
+
+	manager := &CircuitBreakerManager{states: make(map[string]*CircuitBreakerState)}
+	manager.RecordFailure(1, "gpt-4o", true)
+	// 跳过熔断时长，进入半开状态
+	manager.states[circuitBreakerKey(1, "gpt-4o")].OpenUntil = 0
+
+	assert.True(t, manager.Acquire(1, "gpt-4o"))
+	assert.False(t, manager.Acquire(1, "gpt-4o"))
+
+	manager.Release(1, "gpt-4o")
+	assert.True(t, manager.Available(1, "gpt-4o"))
+	assert.True(t, manager.Acquire(1, "gpt-4o"))
+}
+
+// TestCircuitBreakerLuaParity 在真实 Redis 上执行 Lua 脚本，与 Go 状态机逐步比较，需要设置 TEST_REDIS_CONN_STRING
+func TestCircuitBreakerLuaParity(t *testing.T) {
+	connString := os.Getenv("TEST_REDIS_CONN_STRING")
+	if connString == "" {
+		t.Skip("TEST_REDIS_CONN_STRING not set")
+	}
+
+	opt, err := goredis.ParseURL(connString)
+	require.NoError(t, err)
+	client := goredis.NewClient(opt)
+	require.NoError(t, client.Ping(context.Background()).Err())
+
+	savedRDB, savedEnabled := redis.RDB, config.RedisEnabled
+	redis.RDB, config.RedisEnabled = client, true
+	t.Cleanup(func() {
+		redis.RDB, config.RedisEnabled = savedRDB, savedEnabled
+		client.Close()
+	})
+
+	manager := &CircuitBreakerManager{states: make(map[string]*CircuitBreakerState)}
+	for i, tt := range circuitBreakerTransitionTests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupCircuitBreakerConfig(t)
+			config.CircuitBreakerFailureThreshold = tt.failureThreshold
+
+			key := fmt.Sprintf("circuit_breaker_test:%d:%d", time.Now().UnixNano(), i)
+			t.Cleanup(func() {
+				client.Del(context.Background(), key)
+				client.SRem(context.Background(), CircuitBreakerIndexKey, key)
+			})
+
+			state := newCircuitBreakerState()
+			for j, s := range tt.steps {
+				acquired := state.apply(s.op, s.now, s.trip)
+				_, after, luaAcquired, err := manager.updateRedis(key, s.op, s.now, s.trip)
+				require.NoError(t, err)
+
+				assert.Equal(t, acquired, luaAcquired, "step %d acquired", j)
+				assert.Equal(t, state.State, after, "step %d state", j)
+
+				cached, ok := manager.cache.Load(key)
+				require.True(t, ok)
+				assert.Equal(t, state, cached.(*circuitBreakerCache).state, "step %d full state", j)
+			}
+		})
+	}
+}
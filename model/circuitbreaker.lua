@@ -0,0 +1,166 @@
+-- 更新渠道+模型的熔断状态，状态机与 CircuitBreakerState 的方法保持一致
+-- KEYS[1]: 熔断状态键
+-- KEYS[2]: 熔断状态索引键
+-- ARGV[1]: 操作 acquire / release / success / failure
+-- ARGV[2]: 当前时间戳(秒)
+-- ARGV[3]: 失败时是否直接熔断(1/0)
+-- ARGV[4]: 半开状态的探测请求数
+-- ARGV[5]: 探测请求超时时间(秒)
+-- ARGV[6]: 统计窗口(秒)
+-- ARGV[7]: 计算错误率的最小请求数
+-- ARGV[8]: 错误率阈值
+-- ARGV[9]: 连续失败阈值
+-- ARGV[10]: 初始熔断时长(秒)
+-- ARGV[11]: 最长熔断时长(秒)
+-- ARGV[12]: 状态过期时间(秒)
+-- 返回 {更新前状态, 更新后状态, 状态JSON, 是否允许请求}
+local op = ARGV[1]
+local now = tonumber(ARGV[2])
+local trip = ARGV[3] == "1"
+local halfOpenProbes = tonumber(ARGV[4])
+local probeTimeout = tonumber(ARGV[5])
+local windowSeconds = tonumber(ARGV[6])
+local minRequests = tonumber(ARGV[7])
+local errorRate = tonumber(ARGV[8])
+local failureThreshold = tonumber(ARGV[9])
+local cooldown = tonumber(ARGV[10])
+local maxOpen = tonumber(ARGV[11])
+
+local s = {
+    state = "closed",
+    consecutive_failures = 0,
+    window_start = 0,
+    window_requests = 0,
+    window_failures = 0,
+    open_until = 0,
+    backoff = 0,
+    probes = 0,
+    probe_successes = 0,
+    updated_at = 0
+}
+local data = redis.call("GET", KEYS[1])
+if data then
+    for k, v in pairs(cjson.decode(data)) do
+        s[k] = v
+    end
+end
+local before = s.state
+
+local function rollWindow()
+    if now - s.window_start >= windowSeconds then
+        s.window_start = now
+        s.window_requests = 0
+        s.window_failures = 0
+    end
+end
+
+-- 熔断时长按连续熔断次数指数增长
+local function open()
+    local duration = cooldown
+    if duration <= 0 then
+        duration = 1
+    end
+    local i = 0
+    while i < s.backoff and duration < maxOpen do
+        duration = duration * 2
+        i = i + 1
+    end
+    if maxOpen > 0 and duration > maxOpen then
+        duration = maxOpen
+    end
+
+    s.state = "open"
+    s.open_until = now + duration
+    s.backoff = s.backoff + 1
+    s.consecutive_failures = 0
+    s.window_start = now
+    s.window_requests = 0
+    s.window_failures = 0
+    s.probes = 0
+    s.probe_successes = 0
+end
+
+local changed = true
+local acquired = 1
+
+if op == "acquire" then
+    if s.state == "closed" then
+        changed = false
+    elseif s.state == "open" and now < s.open_until then
+        changed = false
+        acquired = 0
+    else
+        if s.state == "open" then
+            s.state = "half_open"
+            s.probes = 0
+            s.probe_successes = 0
+        end
+        if s.probes >= halfOpenProbes and now - s.updated_at <= probeTimeout then
+            changed = false
+            acquired = 0
+            s.state = before
+        else
+            if s.probes >= halfOpenProbes then
+                s.probes = 0
+            end
+            s.probes = s.probes + 1
+            s.updated_at = now
+        end
+    end
+elseif op == "release" then
+    if s.state == "half_open" and s.probes > 0 then
+        s.probes = s.probes - 1
+    else
+        changed = false
+    end
+elseif op == "success" then
+    if s.state == "half_open" then
+        s.updated_at = now
+        if s.probes > 0 then
+            s.probes = s.probes - 1
+        end
+        s.probe_successes = s.probe_successes + 1
+        if s.probe_successes >= halfOpenProbes then
+            s.state = "closed"
+            s.consecutive_failures = 0
+            s.window_start = now
+            s.window_requests = 0
+            s.window_failures = 0
+            s.open_until = 0
+            s.backoff = 0
+            s.probes = 0
+            s.probe_successes = 0
+        end
+    elseif s.state == "closed" then
+        s.updated_at = now
+        rollWindow()
+        s.window_requests = s.window_requests + 1
+        s.consecutive_failures = 0
+    else
+        changed = false
+    end
+else
+    s.updated_at = now
+    if s.state == "half_open" then
+        open()
+    elseif s.state == "closed" then
+        rollWindow()
+        s.window_requests = s.window_requests + 1
+        s.window_failures = s.window_failures + 1
+        s.consecutive_failures = s.consecutive_failures + 1
+
+        local overErrorRate = s.window_requests >= minRequests and s.window_failures / s.window_requests >= errorRate
+        local overFailures = failureThreshold > 0 and s.consecutive_failures >= failureThreshold
+        if trip or overErrorRate or overFailures then
+            open()
+        end
+    end
+end
+
+local value = cjson.encode(s)
+if changed then
+    redis.call("SET", KEYS[1], value, "EX", ARGV[12])
+    redis.call("SADD", KEYS[2], KEYS[1])
+end
+
+return {before, s.state, value, acquired}
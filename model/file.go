@@ -0,0 +1,131 @@
+package model
+
+import (
+	"errors"
+	"one-api/common/utils"
+	"one-api/types"
+
+	"gorm.io/gorm"
+)
+
+const FileStatusProcessed = "processed"
+
+// File 网关自身保存的文件，内容存放在 storage 的文件驱动中
+type File struct {
+	Id         int    `json:"id"`
+	FileId     string `json:"file_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId     int    `json:"user_id" gorm:"index"`
+	TokenId    int    `json:"token_id" gorm:"index;default:0"`
+	Purpose    string `json:"purpose" gorm:"type:varchar(32);index"`
+	Filename   string `json:"filename" gorm:"type:varchar(255)"`
+	Bytes      int64  `json:"bytes" gorm:"default:0"`
+	Drive      string `json:"drive" gorm:"type:varchar(32)"`
+	StorageKey string `json:"storage_key" gorm:"type:varchar(255)"`
+	Status     string `json:"status" gorm:"type:varchar(20)"`
+	CreatedAt  int64  `json:"created_at" gorm:"bigint;index"`
+	ExpiresAt  int64  `json:"expires_at" gorm:"bigint;default:0"`
+}
+
+func NewFileId() string {
+	return "file-" + utils.GetRandomString(24)
+}
+
+func (f *File) Insert() error {
+	return DB.Create(f).Error
+}
+
+func (f *File) Delete() error {
+	return DB.Delete(f).Error
+}
+
+func GetUserFileByFileId(userId int, fileId string) (*File, error) {
+	if fileId == "" {
+		return nil, errors.New("file id is empty")
+	}
+
+	var file File
+	err := DB.Where("user_id = ? AND file_id = ?", userId, fileId).First(&file).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("No such File object: " + fileId)
+	}
+
+	return &file, err
+}
+
+// GetTokenFileByFileId 文件按用户和令牌隔离，只能访问当前令牌上传或生成的文件
+func GetTokenFileByFileId(userId, tokenId int, fileId string) (*File, error) {
+	if fileId == "" {
+		return nil, errors.New("file id is empty")
+	}
+
+	var file File
+	err := DB.Where("user_id = ? AND token_id = ? AND file_id = ?", userId, tokenId, fileId).First(&file).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("No such File object: " + fileId)
+	}
+
+	return &file, err
+}
+
+// GetTokenFiles 分页获取令牌下的文件，after 为上一页最后一个文件 id
+func GetTokenFiles(userId, tokenId int, purpose, after, order string, limit int) ([]*File, error) {
+	var files []*File
+	db := DB.Where("user_id = ? AND token_id = ?", userId, tokenId)
+
+	if purpose != "" {
+		db = db.Where("purpose = ?", purpose)
+	}
+
+	if order != "asc" {
+		order = "desc"
+	}
+
+	if after != "" {
+		afterFile, err := GetTokenFileByFileId(userId, tokenId, after)
+		if err != nil {
+			return nil, err
+		}
+		if order == "asc" {
+			db = db.Where("id > ?", afterFile.Id)
+		} else {
+			db = db.Where("id < ?", afterFile.Id)
+		}
+	}
+
+	err := db.Order("id " + order).Limit(limit).Find(&files).Error
+	return files, err
+}
+
+// GetUserFileUsage 获取用户已保存的文件数量和总大小
+func GetUserFileUsage(userId int) (count int64, bytes int64, err error) {
+	var usage struct {
+		Count int64
+		Bytes int64
+	}
+	err = DB.Model(&File{}).Select("COUNT(*) AS count, COALESCE(SUM(bytes), 0) AS bytes").Where("user_id = ?", userId).Scan(&usage).Error
+	return usage.Count, usage.Bytes, err
+}
+
+func GetExpiredFiles(timestamp int64, limit int) ([]*File, error) {
+	var files []*File
+	err := DB.Where("expires_at > 0 AND expires_at <= ?", timestamp).Order("id asc").Limit(limit).Find(&files).Error
+	return files, err
+}
+
+func (f *File) ToOpenAI() *types.OpenAIFile {
+	file := &types.OpenAIFile{
+		Id:        f.FileId,
+		Object:    "file",
+		Bytes:     f.Bytes,
+		CreatedAt: f.CreatedAt,
+		Filename:  f.Filename,
+		Purpose:   f.Purpose,
+		Status:    f.Status,
+	}
+
+	if f.ExpiresAt > 0 {
+		file.ExpiresAt = utils.GetPointer(f.ExpiresAt)
+	}
+
+	return file
+}
@@ -0,0 +1,456 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common/config"
+	"one-api/common/utils"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type InvoiceStatus string
+
+const (
+	InvoiceStatusUnsettled InvoiceStatus = "unsettled"
+	InvoiceStatusSettled   InvoiceStatus = "settled"
+)
+
+var ErrInvoiceNotFound = errors.New("账单不存在")
+
+// Invoice 月度账单，由 StatisticsMonth 生成
+// 用户账单不包含组织令牌的用量，组织令牌的用量计入组织账单（OrganizationId 不为 0，UserId 为 0）
+// 预付费的账单生成时即为已结算，后付费的账单需要管理员确认收款后结算
+type Invoice struct {
+	Id               int           `json:"id"`
+	Date             string        `json:"date" gorm:"type:varchar(10);uniqueIndex:idx_invoice_owner"` // 账单月份的第一天，格式为 YYYY-MM-DD
+	UserId           int           `json:"user_id" gorm:"uniqueIndex:idx_invoice_owner"`
+	OrganizationId   int           `json:"organization_id" gorm:"uniqueIndex:idx_invoice_owner"`
+	BillingMode      string        `json:"billing_mode" gorm:"type:varchar(16)"`
+	RequestCount     int           `json:"request_count"`
+	Quota            int           `json:"quota"`
+	PromptTokens     int           `json:"prompt_tokens"`
+	CompletionTokens int           `json:"completion_tokens"`
+	RequestTime      int           `json:"request_time"`
+	Amount           float64       `json:"amount" gorm:"type:decimal(12,4);default:0"` // 按 QuotaPerUnit 换算的金额（美元）
+	Status           InvoiceStatus `json:"status" gorm:"type:varchar(16);index"`
+	SettledTime      int64         `json:"settled_time" gorm:"bigint;default:0"`
+	Remark           string        `json:"remark" gorm:"type:varchar(255)"`
+	CreatedTime      int64         `json:"created_time" gorm:"bigint"`
+	UpdatedTime      int64         `json:"updated_time" gorm:"bigint"`
+}
+
+type SearchInvoiceParams struct {
+	UserId         int    `form:"user_id"`
+	OrganizationId int    `form:"organization_id"`
+	Status         string `form:"status"`
+	Date           string `form:"date"`
+	PaginationParams
+}
+
+var allowedInvoiceOrderFields = map[string]bool{
+	"id":      true,
+	"date":    true,
+	"user_id": true,
+	"quota":   true,
+	"status":  true,
+}
+
+func GetInvoicesList(params *SearchInvoiceParams) (*DataResult[Invoice], error) {
+	var invoices []*Invoice
+	db := DB
+	if params.UserId != 0 {
+		db = db.Where("user_id = ?", params.UserId)
+	}
+	if params.OrganizationId != 0 {
+		db = db.Where("organization_id = ?", params.OrganizationId)
+	}
+	if params.Status != "" {
+		db = db.Where("status = ?", params.Status)
+	}
+	if params.Date != "" {
+		db = db.Where("date = ?", params.Date)
+	}
+
+	return PaginateAndOrder(db, &params.PaginationParams, &invoices, allowedInvoiceOrderFields)
+}
+
+func GetInvoiceById(id int) (*Invoice, error) {
+	var invoice Invoice
+	err := DB.Where("id = ?", id).First(&invoice).Error
+	if err != nil {
+		return nil, ErrInvoiceNotFound
+	}
+	return &invoice, nil
+}
+
+// GetUserInvoiceByDate 获取用户指定月份的账单，date 格式为 YYYY-MM-DD
+func GetUserInvoiceByDate(userId int, date string) (*Invoice, error) {
+	var invoice Invoice
+	err := DB.Where("user_id = ? AND organization_id = 0 AND date = ?", userId, date).First(&invoice).Error
+	if err != nil {
+		return nil, ErrInvoiceNotFound
+	}
+	return &invoice, nil
+}
+
+// getUserInvoicesByDates 按月份获取用户的账单
+func getUserInvoicesByDates(userId int, dates []string) (map[string]*Invoice, error) {
+	invoiceMap := make(map[string]*Invoice)
+	if len(dates) == 0 {
+		return invoiceMap, nil
+	}
+
+	var invoices []*Invoice
+	err := DB.Where("user_id = ? AND organization_id = 0 AND date IN ?", userId, dates).Find(&invoices).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, invoice := range invoices {
+		invoiceMap[invoice.Date] = invoice
+	}
+	return invoiceMap, nil
+}
+
+func GetOrganizationInvoices(organizationId int, params *PaginationParams) (*DataResult[Invoice], error) {
+	var invoices []*Invoice
+	db := DB.Where("organization_id = ?", organizationId)
+	return PaginateAndOrder(db, params, &invoices, allowedInvoiceOrderFields)
+}
+
+// SettleInvoice 结算后付费账单，账单金额退回到用户或组织的额度中抵消透支
+func SettleInvoice(id int, remark string) (*Invoice, error) {
+	invoice, err := GetInvoiceById(id)
+	if err != nil {
+		return nil, err
+	}
+	if invoice.Status == InvoiceStatusSettled {
+		return nil, errors.New("账单已结算")
+	}
+
+	postpaid := invoice.BillingMode == BillingModePostpaid && invoice.Quota > 0
+	now := utils.GetTimestamp()
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Invoice{}).Where("id = ? AND status = ?", id, InvoiceStatusUnsettled).Updates(map[string]interface{}{
+			"status":       InvoiceStatusSettled,
+			"settled_time": now,
+			"remark":       remark,
+			"updated_time": now,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("账单已结算")
+		}
+
+		if !postpaid {
+			return nil
+		}
+		if invoice.OrganizationId > 0 {
+			return tx.Model(&Organization{}).Where("id = ?", invoice.OrganizationId).Update("quota", gorm.Expr("quota + ?", invoice.Quota)).Error
+		}
+		return tx.Model(&User{}).Where("id = ?", invoice.UserId).Update("quota", gorm.Expr("quota + ?", invoice.Quota)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	invoice.Status = InvoiceStatusSettled
+	invoice.SettledTime = now
+	invoice.Remark = remark
+
+	if !postpaid {
+		return invoice, nil
+	}
+
+	content := fmt.Sprintf("%s 月度账单已结算，恢复额度: %d，金额：%.2f USD", invoice.Date[:7], invoice.Quota, invoice.Amount)
+	if invoice.OrganizationId > 0 {
+		organization, err := GetOrganizationById(invoice.OrganizationId)
+		if err == nil {
+			RecordQuotaLog(organization.OwnerId, LogTypeTopup, invoice.Quota, "", fmt.Sprintf("组织 %s ", organization.Name)+content)
+		}
+		return invoice, nil
+	}
+
+	CacheUpdateUserQuota(invoice.UserId)
+	RecordQuotaLog(invoice.UserId, LogTypeTopup, invoice.Quota, "", content)
+
+	return invoice, nil
+}
+
+// GetInvoiceItems 账单按模型汇总的明细
+func GetInvoiceItems(invoice *Invoice) ([]*StatisticsMonthModel, error) {
+	var (
+		items map[int]map[string]*StatisticsMonthModel
+		err   error
+	)
+
+	if invoice.OrganizationId > 0 {
+		items, err = getOrganizationInvoiceItems(DB, invoice.Date, invoice.OrganizationId)
+		if err != nil {
+			return nil, err
+		}
+		return sortInvoiceItems(items[invoice.OrganizationId]), nil
+	}
+
+	items, err = getUserInvoiceItems(DB, invoice.Date, invoice.UserId)
+	if err != nil {
+		return nil, err
+	}
+	return sortInvoiceItems(items[invoice.UserId]), nil
+}
+
+type invoiceItemRow struct {
+	OwnerId          int    `gorm:"column:owner_id"`
+	ModelName        string `gorm:"column:model_name"`
+	RequestCount     int    `gorm:"column:request_count"`
+	Quota            int    `gorm:"column:quota"`
+	PromptTokens     int    `gorm:"column:prompt_tokens"`
+	CompletionTokens int    `gorm:"column:completion_tokens"`
+	RequestTime      int    `gorm:"column:request_time"`
+}
+
+const invoiceItemSums = "model_name, sum(request_count) as request_count, sum(quota) as quota, sum(prompt_tokens) as prompt_tokens, sum(completion_tokens) as completion_tokens, sum(request_time) as request_time"
+
+func invoiceMonthRange(date string) (string, string) {
+	firstDay, _ := time.ParseInLocation("2006-01-02", date, time.Local)
+	lastDay := firstDay.AddDate(0, 1, -1)
+	return date, lastDay.Format("2006-01-02")
+}
+
+// getUserInvoiceItems 用户账单明细：月度统计减去组织令牌的用量，userId 为 0 时查询所有用户
+func getUserInvoiceItems(db *gorm.DB, date string, userId int) (map[int]map[string]*StatisticsMonthModel, error) {
+	firstDay, lastDay := invoiceMonthRange(date)
+
+	var rows []*invoiceItemRow
+	query := db.Table("statistics_months").Select("user_id as owner_id, "+invoiceItemSums).Where("date = ?", firstDay)
+	if userId > 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	if err := query.Group("user_id, model_name").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	var organizationRows []*invoiceItemRow
+	query = db.Table("organization_statistics").Select("user_id as owner_id, "+invoiceItemSums).Where("date BETWEEN ? AND ?", firstDay, lastDay)
+	if userId > 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	if err := query.Group("user_id, model_name").Scan(&organizationRows).Error; err != nil {
+		return nil, err
+	}
+
+	items := mergeInvoiceItems(firstDay, rows, 1)
+	for ownerId, models := range mergeInvoiceItems(firstDay, organizationRows, -1) {
+		for modelName, item := range models {
+			if items[ownerId] == nil {
+				continue
+			}
+			if userItem, ok := items[ownerId][modelName]; ok {
+				userItem.RequestCount += item.RequestCount
+				userItem.Quota += item.Quota
+				userItem.PromptTokens += item.PromptTokens
+				userItem.CompletionTokens += item.CompletionTokens
+				userItem.RequestTime += item.RequestTime
+				if userItem.RequestCount <= 0 && userItem.Quota <= 0 {
+					delete(items[ownerId], modelName)
+				}
+			}
+		}
+	}
+
+	return items, nil
+}
+
+// getOrganizationInvoiceItems 组织账单明细，organizationId 为 0 时查询所有组织
+func getOrganizationInvoiceItems(db *gorm.DB, date string, organizationId int) (map[int]map[string]*StatisticsMonthModel, error) {
+	firstDay, lastDay := invoiceMonthRange(date)
+
+	var rows []*invoiceItemRow
+	query := db.Table("organization_statistics").Select("organization_id as owner_id, "+invoiceItemSums).Where("date BETWEEN ? AND ?", firstDay, lastDay)
+	if organizationId > 0 {
+		query = query.Where("organization_id = ?", organizationId)
+	}
+	if err := query.Group("organization_id, model_name").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	return mergeInvoiceItems(firstDay, rows, 1), nil
+}
+
+func mergeInvoiceItems(date string, rows []*invoiceItemRow, sign int) map[int]map[string]*StatisticsMonthModel {
+	items := make(map[int]map[string]*StatisticsMonthModel)
+	for _, row := range rows {
+		if items[row.OwnerId] == nil {
+			items[row.OwnerId] = make(map[string]*StatisticsMonthModel)
+		}
+		items[row.OwnerId][row.ModelName] = &StatisticsMonthModel{
+			Date:             date,
+			ModelName:        row.ModelName,
+			RequestCount:     sign * row.RequestCount,
+			Quota:            sign * row.Quota,
+			PromptTokens:     sign * row.PromptTokens,
+			CompletionTokens: sign * row.CompletionTokens,
+			RequestTime:      sign * row.RequestTime,
+		}
+	}
+	return items
+}
+
+func sortInvoiceItems(models map[string]*StatisticsMonthModel) []*StatisticsMonthModel {
+	items := make([]*StatisticsMonthModel, 0, len(models))
+	for _, item := range models {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ModelName < items[j].ModelName
+	})
+	return items
+}
+
+// generateInvoices 根据月度统计生成用户和组织的账单，已结算的账单不会被覆盖
+// 计费模式按账单月份内的变更记录还原，不受出账前修改计费模式的影响
+func generateInvoices(tx *gorm.DB, month time.Time) error {
+	date := month.Format("2006-01-02")
+	userItems, err := getUserInvoiceItems(tx, date, 0)
+	if err != nil {
+		return err
+	}
+
+	userIds := make([]int, 0, len(userItems))
+	for userId := range userItems {
+		userIds = append(userIds, userId)
+	}
+	start, end := month.Unix(), month.AddDate(0, 1, 0).Unix()
+
+	var users []*User
+	if len(userIds) > 0 {
+		if err := tx.Select("id", "billing_mode").Where("id IN ?", userIds).Find(&users).Error; err != nil {
+			return err
+		}
+	}
+	userModes := make(map[int]string, len(users))
+	for _, user := range users {
+		userModes[user.Id] = user.BillingMode
+	}
+	userModes, err = getBillingModes(tx, false, userModes, start, end)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		invoice := newInvoice(date, userModes[user.Id], userItems[user.Id])
+		invoice.UserId = user.Id
+		if err := saveInvoice(tx, invoice); err != nil {
+			return err
+		}
+	}
+
+	organizationItems, err := getOrganizationInvoiceItems(tx, date, 0)
+	if err != nil {
+		return err
+	}
+
+	organizationIds := make([]int, 0, len(organizationItems))
+	for organizationId := range organizationItems {
+		organizationIds = append(organizationIds, organizationId)
+	}
+	var organizations []*Organization
+	if len(organizationIds) > 0 {
+		if err := tx.Unscoped().Select("id", "billing_mode").Where("id IN ?", organizationIds).Find(&organizations).Error; err != nil {
+			return err
+		}
+	}
+	organizationModes := make(map[int]string, len(organizations))
+	for _, organization := range organizations {
+		organizationModes[organization.Id] = organization.BillingMode
+	}
+	organizationModes, err = getBillingModes(tx, true, organizationModes, start, end)
+	if err != nil {
+		return err
+	}
+	for _, organization := range organizations {
+		invoice := newInvoice(date, organizationModes[organization.Id], organizationItems[organization.Id])
+		invoice.OrganizationId = organization.Id
+		if err := saveInvoice(tx, invoice); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newInvoice(date string, billingMode string, items map[string]*StatisticsMonthModel) *Invoice {
+	if billingMode == "" {
+		billingMode = BillingModePrepaid
+	}
+
+	now := utils.GetTimestamp()
+	invoice := &Invoice{
+		Date:        date,
+		BillingMode: billingMode,
+		Status:      InvoiceStatusSettled,
+		CreatedTime: now,
+		UpdatedTime: now,
+	}
+	if billingMode == BillingModePostpaid {
+		invoice.Status = InvoiceStatusUnsettled
+	} else {
+		invoice.SettledTime = now
+	}
+
+	for _, item := range items {
+		invoice.RequestCount += item.RequestCount
+		invoice.Quota += item.Quota
+		invoice.PromptTokens += item.PromptTokens
+		invoice.CompletionTokens += item.CompletionTokens
+		invoice.RequestTime += item.RequestTime
+	}
+	invoice.Amount = utils.Decimal(float64(invoice.Quota)/config.QuotaPerUnit, 4)
+
+	return invoice
+}
+
+func saveInvoice(tx *gorm.DB, invoice *Invoice) error {
+	if invoice.RequestCount <= 0 && invoice.Quota <= 0 {
+		return nil
+	}
+
+	var existing Invoice
+	err := tx.Where("date = ? AND user_id = ? AND organization_id = ?", invoice.Date, invoice.UserId, invoice.OrganizationId).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return tx.Create(invoice).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	// 重新生成时只更新未结算的账单
+	if existing.Status == InvoiceStatusSettled {
+		return nil
+	}
+
+	return tx.Model(&existing).Updates(map[string]interface{}{
+		"billing_mode":      invoice.BillingMode,
+		"request_count":     invoice.RequestCount,
+		"quota":             invoice.Quota,
+		"prompt_tokens":     invoice.PromptTokens,
+		"completion_tokens": invoice.CompletionTokens,
+		"request_time":      invoice.RequestTime,
+		"amount":            invoice.Amount,
+		"status":            invoice.Status,
+		"settled_time":      invoice.SettledTime,
+		"updated_time":      invoice.UpdatedTime,
+	}).Error
+}
+
+// normalizeInvoiceDate MySQL 返回的日期可能带有时间部分
+func normalizeInvoiceDate(date string) string {
+	if len(date) > 10 {
+		return date[:10]
+	}
+	return date
+}
@@ -0,0 +1,230 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var invoiceTestMonth = time.Date(2025, 9, 1, 0, 0, 0, 0, time.Local)
+
+func setupInvoiceTest(t *testing.T) {
+	t.Helper()
+	setupTestDB(t, &Statistics{}, &OrganizationStatistics{}, &StatisticsMonth{}, &StatisticsMonthGeneratedHistory{},
+		&Organization{}, &Invoice{}, &BillingModeChange{})
+}
+
+func insertUserStatistics(t *testing.T, date string, userId int, modelName string, requestCount, quota int) {
+	t.Helper()
+	err := DB.Exec("INSERT INTO statistics (date, user_id, channel_id, model_name, request_count, quota, prompt_tokens, completion_tokens, request_time) VALUES (?, ?, 1, ?, ?, ?, ?, ?, 0)",
+		date, userId, modelName, requestCount, quota, quota, quota).Error
+	require.NoError(t, err)
+}
+
+func insertOrganizationStatistics(t *testing.T, date string, organizationId, userId int, modelName string, requestCount, quota int) {
+	t.Helper()
+	err := DB.Exec("INSERT INTO organization_statistics (date, organization_id, user_id, model_name, request_count, quota, prompt_tokens, completion_tokens, request_time) VALUES (?, ?, ?, ?, ?, ?, ?, ?, 0)",
+		date, organizationId, userId, modelName, requestCount, quota, quota, quota).Error
+	require.NoError(t, err)
+}
+
+func TestGenerateInvoiceItems(t *testing.T) {
+	setupInvoiceTest(t)
+
+	user := &User{Username: "member", BillingMode: BillingModePrepaid}
+	require.NoError(t, DB.Create(user).Error)
+	organization := &Organization{Name: "org", OwnerId: user.Id, BillingMode: BillingModePostpaid}
+	require.NoError(t, DB.Create(organization).Error)
+
+	// 用户统计包含组织令牌的用量，出账时从用户账单中扣除
+	insertUserStatistics(t, "2025-09-03", user.Id, "gpt-4o", 10, 1000)
+	insertUserStatistics(t, "2025-09-20", user.Id, "gpt-4o", 5, 500)
+	insertUserStatistics(t, "2025-09-20", user.Id, "claude", 2, 200)
+	insertUserStatistics(t, "2025-10-01", user.Id, "gpt-4o", 1, 100)
+	insertOrganizationStatistics(t, "2025-09-03", organization.Id, user.Id, "gpt-4o", 4, 400)
+	insertOrganizationStatistics(t, "2025-09-20", organization.Id, user.Id, "claude", 2, 200)
+	insertOrganizationStatistics(t, "2025-10-01", organization.Id, user.Id, "claude", 1, 100)
+
+	require.NoError(t, InsertStatisticsMonthForDate(invoiceTestMonth))
+
+	tests := []struct {
+		name      string
+		invoice   func() (*Invoice, error)
+		wantMode  string
+		wantItems []*StatisticsMonthModel
+	}{
+		{
+			name:     "user invoice excludes organization token usage",
+			invoice:  func() (*Invoice, error) { return GetUserInvoiceByDate(user.Id, "2025-09-01") },
+			wantMode: BillingModePrepaid,
+			wantItems: []*StatisticsMonthModel{
+				{Date: "2025-09-01", ModelName: "gpt-4o", RequestCount: 11, Quota: 1100, PromptTokens: 1100, CompletionTokens: 1100},
+			},
+		},
+		{
+			name: "organization invoice sums members usage within the month",
+			invoice: func() (*Invoice, error) {
+				invoices, err := GetOrganizationInvoices(organization.Id, &PaginationParams{Page: 1, Size: 10})
+				if err != nil || len(*invoices.Data) != 1 {
+					return nil, ErrInvoiceNotFound
+				}
+				return (*invoices.Data)[0], nil
+			},
+			wantMode: BillingModePostpaid,
+			wantItems: []*StatisticsMonthModel{
+				{Date: "2025-09-01", ModelName: "claude", RequestCount: 2, Quota: 200, PromptTokens: 200, CompletionTokens: 200},
+				{Date: "2025-09-01", ModelName: "gpt-4o", RequestCount: 4, Quota: 400, PromptTokens: 400, CompletionTokens: 400},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invoice, err := tt.invoice()
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantMode, invoice.BillingMode)
+
+			items, err := GetInvoiceItems(invoice)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantItems, items)
+
+			quota := 0
+			for _, item := range tt.wantItems {
+				quota += item.Quota
+			}
+			assert.Equal(t, quota, invoice.Quota)
+		})
+	}
+}
+
+func TestGenerateInvoiceBillingMode(t *testing.T) {
+	start := invoiceTestMonth.Unix()
+	end := invoiceTestMonth.AddDate(0, 1, 0).Unix()
+
+	tests := []struct {
+		name       string
+		current    string
+		changes    []*BillingModeChange
+		wantMode   string
+		wantStatus InvoiceStatus
+	}{
+		{
+			name:       "no changes uses the current mode",
+			current:    BillingModePostpaid,
+			wantMode:   BillingModePostpaid,
+			wantStatus: InvoiceStatusUnsettled,
+		},
+		{
+			name:    "switched to prepaid after the month is billed as postpaid",
+			current: BillingModePrepaid,
+			changes: []*BillingModeChange{
+				{PreviousMode: BillingModePostpaid, Mode: BillingModePrepaid, CreatedTime: end + 3600},
+			},
+			wantMode:   BillingModePostpaid,
+			wantStatus: InvoiceStatusUnsettled,
+		},
+		{
+			name:    "switched to postpaid after the month is billed as prepaid",
+			current: BillingModePostpaid,
+			changes: []*BillingModeChange{
+				{PreviousMode: BillingModePrepaid, Mode: BillingModePostpaid, CreatedTime: end + 3600},
+			},
+			wantMode:   BillingModePrepaid,
+			wantStatus: InvoiceStatusSettled,
+		},
+		{
+			name:    "postpaid for part of the month is billed as postpaid",
+			current: BillingModePrepaid,
+			changes: []*BillingModeChange{
+				{PreviousMode: BillingModePrepaid, Mode: BillingModePostpaid, CreatedTime: start + 86400},
+				{PreviousMode: BillingModePostpaid, Mode: BillingModePrepaid, CreatedTime: start + 2*86400},
+			},
+			wantMode:   BillingModePostpaid,
+			wantStatus: InvoiceStatusUnsettled,
+		},
+		{
+			name:    "changes before the month are ignored",
+			current: BillingModePrepaid,
+			changes: []*BillingModeChange{
+				{PreviousMode: BillingModePrepaid, Mode: BillingModePostpaid, CreatedTime: start - 2*86400},
+				{PreviousMode: BillingModePostpaid, Mode: BillingModePrepaid, CreatedTime: start - 86400},
+			},
+			wantMode:   BillingModePrepaid,
+			wantStatus: InvoiceStatusSettled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupInvoiceTest(t)
+
+			user := &User{Username: "billing", BillingMode: tt.current}
+			require.NoError(t, DB.Create(user).Error)
+			for _, change := range tt.changes {
+				change.UserId = user.Id
+				require.NoError(t, DB.Create(change).Error)
+			}
+			insertUserStatistics(t, "2025-09-10", user.Id, "gpt-4o", 1, 100)
+
+			require.NoError(t, InsertStatisticsMonthForDate(invoiceTestMonth))
+
+			invoice, err := GetUserInvoiceByDate(user.Id, "2025-09-01")
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantMode, invoice.BillingMode)
+			assert.Equal(t, tt.wantStatus, invoice.Status)
+		})
+	}
+}
+
+func TestSettleInvoice(t *testing.T) {
+	setupInvoiceTest(t)
+
+	user := &User{Username: "postpaid", Quota: -300, BillingMode: BillingModePostpaid}
+	require.NoError(t, DB.Create(user).Error)
+	organization := &Organization{Name: "org", OwnerId: user.Id, Quota: -200, BillingMode: BillingModePostpaid}
+	require.NoError(t, DB.Create(organization).Error)
+
+	userInvoice := &Invoice{Date: "2025-09-01", UserId: user.Id, BillingMode: BillingModePostpaid, Quota: 300, Status: InvoiceStatusUnsettled}
+	organizationInvoice := &Invoice{Date: "2025-09-01", OrganizationId: organization.Id, BillingMode: BillingModePostpaid, Quota: 200, Status: InvoiceStatusUnsettled}
+	prepaidInvoice := &Invoice{Date: "2025-08-01", UserId: user.Id, BillingMode: BillingModePrepaid, Quota: 100, Status: InvoiceStatusSettled}
+	require.NoError(t, DB.Create([]*Invoice{userInvoice, organizationInvoice, prepaidInvoice}).Error)
+
+	settled, err := SettleInvoice(userInvoice.Id, "paid")
+	require.NoError(t, err)
+	assert.Equal(t, InvoiceStatusSettled, settled.Status)
+	assert.Equal(t, "paid", settled.Remark)
+	quota, _ := GetUserQuota(user.Id)
+	assert.Equal(t, 0, quota)
+
+	_, err = SettleInvoice(userInvoice.Id, "paid again")
+	assert.Error(t, err)
+	quota, _ = GetUserQuota(user.Id)
+	assert.Equal(t, 0, quota)
+
+	_, err = SettleInvoice(organizationInvoice.Id, "")
+	require.NoError(t, err)
+	organization, err = GetOrganizationById(organization.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 0, organization.Quota)
+
+	_, err = SettleInvoice(prepaidInvoice.Id, "")
+	assert.Error(t, err)
+}
+
+func TestSettleInvoiceRollback(t *testing.T) {
+	setupInvoiceTest(t)
+
+	invoice := &Invoice{Date: "2025-09-01", UserId: 1, BillingMode: BillingModePostpaid, Quota: 300, Status: InvoiceStatusUnsettled}
+	require.NoError(t, DB.Create(invoice).Error)
+	// 额度无法恢复时账单保持未结算
+	require.NoError(t, DB.Migrator().DropTable(&User{}))
+
+	_, err := SettleInvoice(invoice.Id, "paid")
+	assert.Error(t, err)
+
+	saved, err := GetInvoiceById(invoice.Id)
+	require.NoError(t, err)
+	assert.Equal(t, InvoiceStatusUnsettled, saved.Status)
+}
@@ -14,6 +14,7 @@ import (
 type Log struct {
 	Id               int                                `json:"id"`
 	UserId           int                                `json:"user_id" gorm:"index"`
+	OrganizationId   int                                `json:"organization_id" gorm:"index;default:0"`
 	CreatedAt        int64                              `json:"created_at" gorm:"bigint;index:idx_created_at_type"`
 	Type             int                                `json:"type" gorm:"index:idx_created_at_type"`
 	Content          string                             `json:"content"`
@@ -82,6 +83,7 @@ func RecordLog(userId int, logType int, content string) {
 func RecordConsumeLog(
 	ctx context.Context,
 	userId int,
+	organizationId int,
 	channelId int,
 	promptTokens int,
 	completionTokens int,
@@ -102,6 +104,7 @@ func RecordConsumeLog(
 
 	log := &Log{
 		UserId:           userId,
+		OrganizationId:   organizationId,
 		Username:         username,
 		CreatedAt:        utils.GetTimestamp(),
 		Type:             LogTypeConsume,
@@ -141,6 +144,7 @@ type LogsListParams struct {
 	TokenName      string `form:"token_name"`
 	ChannelId      int    `form:"channel_id"`
 	SourceIp       string `form:"source_ip"`
+	OrganizationId int    `form:"organization_id"`
 }
 
 var allowedLogsOrderFields = map[string]bool{
@@ -185,6 +189,34 @@ func GetLogsList(params *LogsListParams) (*DataResult[Log], error) {
 	if params.SourceIp != "" {
 		tx = tx.Where("source_ip = ?", params.SourceIp)
 	}
+	if params.OrganizationId != 0 {
+		tx = tx.Where("organization_id = ?", params.OrganizationId)
+	}
+
+	return PaginateAndOrder[Log](tx, &params.PaginationParams, &logs, allowedLogsOrderFields)
+}
+
+// GetOrganizationLogsList 获取组织令牌产生的消费日志
+func GetOrganizationLogsList(organizationId int, params *LogsListParams) (*DataResult[Log], error) {
+	var logs []*Log
+
+	tx := DB.Where("organization_id = ?", organizationId).Omit("id")
+
+	if params.ModelName != "" {
+		tx = tx.Where("model_name = ?", params.ModelName)
+	}
+	if params.Username != "" {
+		tx = tx.Where("username = ?", params.Username)
+	}
+	if params.TokenName != "" {
+		tx = tx.Where("token_name = ?", params.TokenName)
+	}
+	if params.StartTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", params.StartTimestamp)
+	}
+	if params.EndTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", params.EndTimestamp)
+	}
 
 	return PaginateAndOrder[Log](tx, &params.PaginationParams, &logs, allowedLogsOrderFields)
 }
@@ -165,6 +165,10 @@ func InitDB() (err error) {
 		if err != nil {
 			return err
 		}
+		err = db.AutoMigrate(&OrderRefund{})
+		if err != nil {
+			return err
+		}
 		err = db.AutoMigrate(&Task{})
 		if err != nil {
 			return err
@@ -194,6 +198,61 @@ func InitDB() (err error) {
 			return err
 		}
 
+		err = db.AutoMigrate(&File{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&Batch{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&BatchLine{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&StoredResponse{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&BudgetUsage{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&Organization{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&OrganizationMember{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&OrganizationInvitation{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&OrganizationStatistics{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&SubscriptionPlan{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&Subscription{})
+		if err != nil {
+			return err
+		}
+
 		if config.UserInvoiceMonth {
 			err = db.AutoMigrate(&StatisticsMonthGeneratedHistory{})
 			if err != nil {
@@ -204,6 +263,16 @@ func InitDB() (err error) {
 			if err != nil {
 				return err
 			}
+
+			err = db.AutoMigrate(&Invoice{})
+			if err != nil {
+				return err
+			}
+
+			err = db.AutoMigrate(&BillingModeChange{})
+			if err != nil {
+				return err
+			}
 		}
 
 		migrationAfter(DB)
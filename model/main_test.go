@@ -0,0 +1,38 @@
+package model
+
+import (
+	"one-api/common"
+	"one-api/common/logger"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// setupTestDB 使用临时的 SQLite 数据库替换 DB，测试结束后恢复
+func setupTestDB(t *testing.T, models ...interface{}) {
+	t.Helper()
+	logger.Logger = zap.NewNop()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "one-api.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(append([]interface{}{&User{}, &Log{}}, models...)...); err != nil {
+		t.Fatal(err)
+	}
+
+	savedDB, savedSQLite := DB, common.UsingSQLite
+	DB, common.UsingSQLite = db, true
+	t.Cleanup(func() {
+		DB, common.UsingSQLite = savedDB, savedSQLite
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
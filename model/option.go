@@ -89,6 +89,15 @@ func InitOptionMap() {
 	config.GlobalOption.RegisterFloat("QuotaPerUnit", &config.QuotaPerUnit)
 	config.GlobalOption.RegisterInt("RetryTimes", &config.RetryTimes)
 	config.GlobalOption.RegisterInt("RetryCooldownSeconds", &config.RetryCooldownSeconds)
+	config.GlobalOption.RegisterInt("ChannelQueueTimeout", &config.ChannelQueueTimeout)
+	config.GlobalOption.RegisterInt("ChannelQueueMaxSize", &config.ChannelQueueMaxSize)
+	config.GlobalOption.RegisterBool("CircuitBreakerEnabled", &config.CircuitBreakerEnabled)
+	config.GlobalOption.RegisterInt("CircuitBreakerFailureThreshold", &config.CircuitBreakerFailureThreshold)
+	config.GlobalOption.RegisterFloat("CircuitBreakerErrorRate", &config.CircuitBreakerErrorRate)
+	config.GlobalOption.RegisterInt("CircuitBreakerMinRequests", &config.CircuitBreakerMinRequests)
+	config.GlobalOption.RegisterInt("CircuitBreakerWindowSeconds", &config.CircuitBreakerWindowSeconds)
+	config.GlobalOption.RegisterInt("CircuitBreakerMaxOpenSeconds", &config.CircuitBreakerMaxOpenSeconds)
+	config.GlobalOption.RegisterInt("CircuitBreakerHalfOpenProbes", &config.CircuitBreakerHalfOpenProbes)
 
 	config.GlobalOption.RegisterBool("MjNotifyEnabled", &config.MjNotifyEnabled)
 	config.GlobalOption.RegisterString("ChatImageRequestProxy", &config.ChatImageRequestProxy)
@@ -120,6 +129,24 @@ func InitOptionMap() {
 
 	config.GlobalOption.RegisterInt("RetryTimeOut", &config.RetryTimeOut)
 
+	config.GlobalOption.RegisterBool("ChatCacheEnabled", &config.ChatCacheEnabled)
+	config.GlobalOption.RegisterInt("ChatCacheExpireSeconds", &config.ChatCacheExpireSeconds)
+	config.GlobalOption.RegisterFloat("ChatCacheHitRatio", &config.ChatCacheHitRatio)
+
+	config.GlobalOption.RegisterInt("BatchConcurrency", &config.BatchConcurrency)
+	config.GlobalOption.RegisterFloat("BatchDiscountRatio", &config.BatchDiscountRatio)
+
+	config.GlobalOption.RegisterInt("FileUserStorageLimit", &config.FileUserStorageLimit)
+	config.GlobalOption.RegisterInt("FileUserCountLimit", &config.FileUserCountLimit)
+	config.GlobalOption.RegisterInt("ResponseStoreRetentionDays", &config.ResponseStoreRetentionDays)
+
+	config.GlobalOption.RegisterInt("RoutingStickyTTL", &config.RoutingStickyTTL)
+	config.GlobalOption.RegisterCustom("ChannelTypePrice", func() string {
+		return ChannelTypePrices2JSONString()
+	}, func(value string) error {
+		return UpdateChannelTypePricesByJSONString(value)
+	}, "")
+
 	config.GlobalOption.RegisterBool("EnableSafe", &config.EnableSafe)
 	config.GlobalOption.RegisterString("SafeToolName", &config.SafeToolName)
 	config.GlobalOption.RegisterCustom("SafeKeyWords", func() string {
@@ -128,6 +155,12 @@ func InitOptionMap() {
 		config.SafeKeyWords = strings.Split(value, "\n")
 		return nil
 	}, "")
+	config.GlobalOption.RegisterString("SafeRegexRules", &config.SafeRegexRules)
+	config.GlobalOption.RegisterString("SafePIIAction", &config.SafePIIAction)
+	config.GlobalOption.RegisterString("SafeLLMJudgeModel", &config.SafeLLMJudgeModel)
+	config.GlobalOption.RegisterString("SafeLLMJudgeToken", &config.SafeLLMJudgeToken)
+	config.GlobalOption.RegisterFloat("SafeLLMJudgeThreshold", &config.SafeLLMJudgeThreshold)
+	config.GlobalOption.RegisterFloat("SafeRiskThreshold", &config.SafeRiskThreshold)
 
 	loadOptionsFromDatabase()
 }
@@ -13,6 +13,9 @@ const (
 	OrderStatusSuccess OrderStatus = "success"
 	OrderStatusFailed  OrderStatus = "failed"
 	OrderStatusClosed  OrderStatus = "closed"
+
+	OrderStatusPartialRefunded OrderStatus = "partial_refunded"
+	OrderStatusRefunded        OrderStatus = "refunded"
 )
 
 type Order struct {
@@ -28,6 +31,9 @@ type Order struct {
 	Fee           float64        `json:"fee" gorm:"type:decimal(10,2);default:0"`
 	Discount      float64        `json:"discount" gorm:"type:decimal(10,2);default:0"`
 	Status        OrderStatus    `json:"status" gorm:"type:varchar(32)"`
+	PlanId        int            `json:"plan_id" gorm:"default:0"`                          // 订阅套餐 ID，为 0 表示普通充值
+	RefundAmount  float64        `json:"refund_amount" gorm:"type:decimal(10,2);default:0"` // 已退款金额，包含处理中的退款
+	RefundQuota   int            `json:"refund_quota" gorm:"type:int;default:0"`            // 已扣回的额度
 	CreatedAt     int            `json:"created_at"`
 	UpdatedAt     int            `json:"-"`
 	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
@@ -40,12 +46,24 @@ func CloseUnfinishedOrder() error {
 	return DB.Model(&Order{}).Where("status = ? AND created_at < ?", OrderStatusPending, unixTime).Update("status", OrderStatusClosed).Error
 }
 
+func GetOrderById(id int) (*Order, error) {
+	var order Order
+	err := DB.Where("id = ?", id).First(&order).Error
+	return &order, err
+}
+
 func GetOrderByTradeNo(tradeNo string) (*Order, error) {
 	var order Order
 	err := DB.Where("trade_no = ?", tradeNo).First(&order).Error
 	return &order, err
 }
 
+func GetOrderByGatewayNo(gatewayId int, gatewayNo string) (*Order, error) {
+	var order Order
+	err := DB.Where("gateway_id = ? AND gateway_no = ?", gatewayId, gatewayNo).First(&order).Error
+	return &order, err
+}
+
 func GetUserOrder(userId int, tradeNo string) (*Order, error) {
 	var order Order
 	err := DB.Where("user_id = ? AND trade_no = ?", userId, tradeNo).First(&order).Error
@@ -112,6 +130,9 @@ func GetOrderList(params *SearchOrderParams) (*DataResult[Order], error) {
 	return PaginateAndOrder(db, &params.PaginationParams, &orders, allowedOrderFields)
 }
 
+// 已支付的订单，统计时扣除退款金额
+var paidOrderStatuses = []OrderStatus{OrderStatusSuccess, OrderStatusPartialRefunded, OrderStatusRefunded}
+
 type OrderStatistics struct {
 	Quota         int64   `json:"quota"`
 	Money         float64 `json:"money"`
@@ -119,7 +140,7 @@ type OrderStatistics struct {
 }
 
 func GetStatisticsOrder() (orderStatistics []*OrderStatistics, err error) {
-	err = DB.Model(&Order{}).Select("sum(quota) as quota, sum(order_amount) as money, order_currency").Where("status = ?", OrderStatusSuccess).Group("order_currency").Scan(&orderStatistics).Error
+	err = DB.Model(&Order{}).Select("sum(quota - refund_quota) as quota, sum(order_amount - refund_amount) as money, order_currency").Where("status IN ?", paidOrderStatuses).Group("order_currency").Scan(&orderStatistics).Error
 	return orderStatistics, err
 }
 
@@ -133,13 +154,13 @@ func GetStatisticsOrderByPeriod(startTimestamp, endTimestamp int64) (orderStatis
 
 	err = DB.Raw(`
 		SELECT `+groupSelect+`,
-		sum(order_amount) as order_amount
+		sum(order_amount - refund_amount) as order_amount
 		FROM orders
-		WHERE status= ?
+		WHERE status IN ?
 		AND created_at BETWEEN ? AND ?
 		GROUP BY date
 		ORDER BY date
-	`, OrderStatusSuccess, startTimestamp, endTimestamp).Scan(&orderStatistics).Error
+	`, paidOrderStatuses, startTimestamp, endTimestamp).Scan(&orderStatistics).Error
 
 	return orderStatistics, err
 }
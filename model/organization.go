@@ -0,0 +1,570 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/redis"
+	"one-api/common/utils"
+
+	"gorm.io/gorm"
+)
+
+const (
+	OrganizationRoleOwner  = "owner"
+	OrganizationRoleAdmin  = "admin"
+	OrganizationRoleMember = "member"
+	OrganizationRoleViewer = "viewer"
+
+	OrganizationStatusEnabled  = 1
+	OrganizationStatusDisabled = 2
+
+	OrganizationInvitationPending  = 1
+	OrganizationInvitationAccepted = 2
+	OrganizationInvitationRevoked  = 3
+
+	// 邀请默认有效期（秒）
+	organizationInvitationTTL = 7 * 24 * 3600
+)
+
+var (
+	ErrOrganizationNotFound       = errors.New("组织不存在")
+	ErrOrganizationDisabled       = errors.New("组织已被禁用")
+	ErrOrganizationNotMember      = errors.New("不是该组织的成员")
+	ErrOrganizationPermission     = errors.New("没有权限执行该操作")
+	ErrOrganizationQuotaNotEnough = errors.New("组织额度不足")
+	ErrOrganizationMemberLimit    = errors.New("已达到组织为该成员设置的额度上限")
+)
+
+// 角色等级，数值越大权限越高
+var organizationRoleLevel = map[string]int{
+	OrganizationRoleViewer: 1,
+	OrganizationRoleMember: 2,
+	OrganizationRoleAdmin:  3,
+	OrganizationRoleOwner:  4,
+}
+
+func IsValidOrganizationRole(role string) bool {
+	_, ok := organizationRoleLevel[role]
+	return ok
+}
+
+// Organization 组织，成员共享组织的额度池
+type Organization struct {
+	Id          int            `json:"id"`
+	Name        string         `json:"name" gorm:"type:varchar(64);index"`
+	OwnerId     int            `json:"owner_id" gorm:"index"`
+	Quota       int            `json:"quota" gorm:"bigint;default:0"`
+	UsedQuota   int            `json:"used_quota" gorm:"bigint;default:0"`
+	Status      int            `json:"status" gorm:"default:1"`
+	BillingMode string         `json:"billing_mode" gorm:"type:varchar(16);default:'prepaid'"` // 计费模式，由管理员设置
+	CreditLimit int            `json:"credit_limit" gorm:"bigint;default:0"`                   // 后付费组织允许透支的额度
+	CreatedTime int64          `json:"created_time" gorm:"bigint"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// OrganizationMember 组织成员，QuotaLimit 为成员可使用的组织额度上限，0 为不限制
+type OrganizationMember struct {
+	Id             int    `json:"id"`
+	OrganizationId int    `json:"organization_id" gorm:"uniqueIndex:idx_org_member"`
+	UserId         int    `json:"user_id" gorm:"uniqueIndex:idx_org_member;index"`
+	Username       string `json:"username" gorm:"-:all"`
+	Role           string `json:"role" gorm:"type:varchar(16);default:'member'"`
+	QuotaLimit     int    `json:"quota_limit" gorm:"bigint;default:0"`
+	UsedQuota      int    `json:"used_quota" gorm:"bigint;default:0"`
+	CreatedTime    int64  `json:"created_time" gorm:"bigint"`
+}
+
+// OrganizationInvitation 组织邀请，指定了 Username 时只有该用户可以接受
+type OrganizationInvitation struct {
+	Id             int    `json:"id"`
+	OrganizationId int    `json:"organization_id" gorm:"index"`
+	Code           string `json:"code" gorm:"type:char(32);uniqueIndex"`
+	Username       string `json:"username" gorm:"type:varchar(64);default:''"`
+	Role           string `json:"role" gorm:"type:varchar(16);default:'member'"`
+	InviterId      int    `json:"inviter_id"`
+	Status         int    `json:"status" gorm:"default:1"`
+	ExpiredTime    int64  `json:"expired_time" gorm:"bigint"`
+	CreatedTime    int64  `json:"created_time" gorm:"bigint"`
+}
+
+// OrganizationDetail 组织信息及当前用户在组织中的角色
+type OrganizationDetail struct {
+	Organization
+	Role       string `json:"role"`
+	QuotaLimit int    `json:"quota_limit"`
+	MemberUsed int    `json:"member_used_quota"`
+}
+
+// CanManage 是否可以管理成员和邀请
+func (m *OrganizationMember) CanManage() bool {
+	return organizationRoleLevel[m.Role] >= organizationRoleLevel[OrganizationRoleAdmin]
+}
+
+// CanSpend 是否可以使用组织额度，只读成员不能创建令牌和消费
+func (m *OrganizationMember) CanSpend() bool {
+	return organizationRoleLevel[m.Role] >= organizationRoleLevel[OrganizationRoleMember]
+}
+
+// Outranks 是否可以管理另一个成员，只能管理角色低于自己的成员，所有者可以管理所有人
+func (m *OrganizationMember) Outranks(other *OrganizationMember) bool {
+	if m.Role == OrganizationRoleOwner {
+		return true
+	}
+	return organizationRoleLevel[m.Role] > organizationRoleLevel[other.Role]
+}
+
+// CreateOrganization 创建组织，创建者成为所有者
+func CreateOrganization(name string, ownerId int) (*Organization, error) {
+	if name == "" {
+		return nil, errors.New("组织名称不能为空")
+	}
+
+	now := utils.GetTimestamp()
+	organization := &Organization{
+		Name:        name,
+		OwnerId:     ownerId,
+		Status:      OrganizationStatusEnabled,
+		CreatedTime: now,
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(organization).Error; err != nil {
+			return err
+		}
+		return tx.Create(&OrganizationMember{
+			OrganizationId: organization.Id,
+			UserId:         ownerId,
+			Role:           OrganizationRoleOwner,
+			CreatedTime:    now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return organization, nil
+}
+
+func GetOrganizationById(id int) (*Organization, error) {
+	if id == 0 {
+		return nil, ErrOrganizationNotFound
+	}
+	var organization Organization
+	if err := DB.First(&organization, "id = ?", id).Error; err != nil {
+		return nil, ErrOrganizationNotFound
+	}
+	return &organization, nil
+}
+
+var allowedOrganizationOrderFields = map[string]bool{
+	"id":           true,
+	"name":         true,
+	"quota":        true,
+	"used_quota":   true,
+	"created_time": true,
+}
+
+// GetOrganizationsList 管理员查看所有组织
+func GetOrganizationsList(params *GenericParams) (*DataResult[Organization], error) {
+	var organizations []*Organization
+	db := DB
+	if params.Keyword != "" {
+		db = db.Where("id = ? or name LIKE ?", utils.String2Int(params.Keyword), params.Keyword+"%")
+	}
+
+	return PaginateAndOrder(db, &params.PaginationParams, &organizations, allowedOrganizationOrderFields)
+}
+
+// GetUserOrganizations 获取用户加入的所有组织
+func GetUserOrganizations(userId int) ([]*OrganizationDetail, error) {
+	var details []*OrganizationDetail
+	err := DB.Table("organizations").
+		Select("organizations.*, organization_members.role, organization_members.quota_limit, organization_members.used_quota as member_used").
+		Joins("JOIN organization_members ON organization_members.organization_id = organizations.id").
+		Where("organization_members.user_id = ? AND organizations.deleted_at IS NULL", userId).
+		Order("organizations.id").
+		Scan(&details).Error
+	return details, err
+}
+
+func GetOrganizationMember(organizationId, userId int) (*OrganizationMember, error) {
+	var member OrganizationMember
+	err := DB.First(&member, "organization_id = ? AND user_id = ?", organizationId, userId).Error
+	if err != nil {
+		return nil, ErrOrganizationNotMember
+	}
+	return &member, nil
+}
+
+func GetOrganizationMembers(organizationId int) ([]*OrganizationMember, error) {
+	var members []*OrganizationMember
+	err := DB.Where("organization_id = ?", organizationId).Order("id").Find(&members).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, member := range members {
+		member.Username, _ = CacheGetUsername(member.UserId)
+	}
+	return members, nil
+}
+
+func UpdateOrganizationName(id int, name string) error {
+	if name == "" {
+		return errors.New("组织名称不能为空")
+	}
+	return DB.Model(&Organization{}).Where("id = ?", id).Update("name", name).Error
+}
+
+func UpdateOrganizationMemberRole(organizationId, userId int, role string) error {
+	if !IsValidOrganizationRole(role) || role == OrganizationRoleOwner {
+		return errors.New("无效的角色")
+	}
+	return DB.Model(&OrganizationMember{}).
+		Where("organization_id = ? AND user_id = ?", organizationId, userId).
+		Update("role", role).Error
+}
+
+func UpdateOrganizationMemberQuotaLimit(organizationId, userId, quotaLimit int) error {
+	if quotaLimit < 0 {
+		return errors.New("额度上限不能小于 0")
+	}
+	return DB.Model(&OrganizationMember{}).
+		Where("organization_id = ? AND user_id = ?", organizationId, userId).
+		Update("quota_limit", quotaLimit).Error
+}
+
+// TransferOrganizationOwnership 转让所有者，原所有者降为管理员
+func TransferOrganizationOwnership(organizationId, fromUserId, toUserId int) error {
+	if _, err := GetOrganizationMember(organizationId, toUserId); err != nil {
+		return err
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Organization{}).Where("id = ?", organizationId).Update("owner_id", toUserId).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&OrganizationMember{}).
+			Where("organization_id = ? AND user_id = ?", organizationId, fromUserId).
+			Update("role", OrganizationRoleAdmin).Error; err != nil {
+			return err
+		}
+		return tx.Model(&OrganizationMember{}).
+			Where("organization_id = ? AND user_id = ?", organizationId, toUserId).
+			Update("role", OrganizationRoleOwner).Error
+	})
+}
+
+// RemoveOrganizationMember 移除成员，成员创建的组织令牌转给组织所有者并禁用，由所有者决定是否重新启用
+func RemoveOrganizationMember(organizationId, userId int) error {
+	organization, err := GetOrganizationById(organizationId)
+	if err != nil {
+		return err
+	}
+	if organization.OwnerId == userId {
+		return errors.New("所有者不能离开组织，请先转让组织")
+	}
+
+	var tokenKeys []string
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Token{}).
+			Where("organization_id = ? AND user_id = ?", organizationId, userId).
+			Pluck("key", &tokenKeys).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Token{}).
+			Where("organization_id = ? AND user_id = ?", organizationId, userId).
+			Updates(map[string]interface{}{
+				"user_id": organization.OwnerId,
+				"status":  config.TokenStatusDisabled,
+			}).Error; err != nil {
+			return err
+		}
+		return tx.Where("organization_id = ? AND user_id = ?", organizationId, userId).Delete(&OrganizationMember{}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if config.RedisEnabled {
+		for _, key := range tokenKeys {
+			redis.RedisDel(fmt.Sprintf(UserTokensKey, key))
+		}
+	}
+
+	return nil
+}
+
+// DeleteOrganization 删除组织，剩余额度退回所有者，组织令牌一并删除
+func DeleteOrganization(organizationId int) error {
+	organization, err := GetOrganizationById(organizationId)
+	if err != nil {
+		return err
+	}
+
+	var tokenKeys []string
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Token{}).Where("organization_id = ?", organizationId).Pluck("key", &tokenKeys).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", organizationId).Delete(&Token{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", organizationId).Delete(&OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&OrganizationInvitation{}).
+			Where("organization_id = ? AND status = ?", organizationId, OrganizationInvitationPending).
+			Update("status", OrganizationInvitationRevoked).Error; err != nil {
+			return err
+		}
+		if organization.Quota > 0 {
+			if err := tx.Model(&User{}).Where("id = ?", organization.OwnerId).
+				Update("quota", gorm.Expr("quota + ?", organization.Quota)).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(organization).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if config.RedisEnabled {
+		for _, key := range tokenKeys {
+			redis.RedisDel(fmt.Sprintf(UserTokensKey, key))
+		}
+	}
+	if organization.Quota > 0 {
+		CacheUpdateUserQuota(organization.OwnerId)
+	}
+
+	return nil
+}
+
+// TransferQuotaToOrganization 将个人额度转入组织额度池
+func TransferQuotaToOrganization(organizationId, userId, quota int) error {
+	if quota <= 0 {
+		return errors.New("额度必须大于 0")
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).Where("id = ? AND quota >= ?", userId, quota).
+			Update("quota", gorm.Expr("quota - ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("用户额度不足")
+		}
+		return tx.Model(&Organization{}).Where("id = ?", organizationId).
+			Update("quota", gorm.Expr("quota + ?", quota)).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	CacheUpdateUserQuota(userId)
+	RecordQuotaLog(userId, LogTypeManage, quota, "", fmt.Sprintf("向组织 #%d 转入额度 %s", organizationId, common.LogQuota(quota)))
+	return nil
+}
+
+// SetOrganizationQuota 管理员直接修改组织额度
+func SetOrganizationQuota(organizationId, quota int) error {
+	if quota < 0 {
+		return errors.New("额度不能小于 0")
+	}
+	return DB.Model(&Organization{}).Where("id = ?", organizationId).Update("quota", quota).Error
+}
+
+func UpdateOrganizationStatus(organizationId, status int) error {
+	return DB.Model(&Organization{}).Where("id = ?", organizationId).Update("status", status).Error
+}
+
+// CreateOrganizationInvitation 创建邀请码，username 为空时任何持有邀请码的用户都可以加入
+func CreateOrganizationInvitation(organizationId, inviterId int, username, role string) (*OrganizationInvitation, error) {
+	if !IsValidOrganizationRole(role) || role == OrganizationRoleOwner {
+		return nil, errors.New("无效的角色")
+	}
+
+	now := utils.GetTimestamp()
+	invitation := &OrganizationInvitation{
+		OrganizationId: organizationId,
+		Code:           utils.GetUUID(),
+		Username:       username,
+		Role:           role,
+		InviterId:      inviterId,
+		Status:         OrganizationInvitationPending,
+		ExpiredTime:    now + organizationInvitationTTL,
+		CreatedTime:    now,
+	}
+
+	if err := DB.Create(invitation).Error; err != nil {
+		return nil, err
+	}
+	return invitation, nil
+}
+
+func GetOrganizationInvitations(organizationId int) ([]*OrganizationInvitation, error) {
+	var invitations []*OrganizationInvitation
+	err := DB.Where("organization_id = ? AND status = ?", organizationId, OrganizationInvitationPending).
+		Order("id desc").Find(&invitations).Error
+	return invitations, err
+}
+
+func RevokeOrganizationInvitation(organizationId, invitationId int) error {
+	return DB.Model(&OrganizationInvitation{}).
+		Where("id = ? AND organization_id = ? AND status = ?", invitationId, organizationId, OrganizationInvitationPending).
+		Update("status", OrganizationInvitationRevoked).Error
+}
+
+// AcceptOrganizationInvitation 接受邀请加入组织
+func AcceptOrganizationInvitation(code string, userId int) (*OrganizationMember, error) {
+	if code == "" {
+		return nil, errors.New("未提供邀请码")
+	}
+
+	username, _ := CacheGetUsername(userId)
+	member := &OrganizationMember{}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		invitation := &OrganizationInvitation{}
+		err := tx.Set("gorm:query_option", "FOR UPDATE").Where("code = ?", code).First(invitation).Error
+		if err != nil {
+			return errors.New("无效的邀请码")
+		}
+		if invitation.Status != OrganizationInvitationPending || invitation.ExpiredTime < utils.GetTimestamp() {
+			return errors.New("邀请已失效")
+		}
+		if invitation.Username != "" && invitation.Username != username {
+			return errors.New("该邀请不属于当前用户")
+		}
+
+		var count int64
+		tx.Model(&OrganizationMember{}).Where("organization_id = ? AND user_id = ?", invitation.OrganizationId, userId).Count(&count)
+		if count > 0 {
+			return errors.New("已经是该组织的成员")
+		}
+
+		member.OrganizationId = invitation.OrganizationId
+		member.UserId = userId
+		member.Role = invitation.Role
+		member.CreatedTime = utils.GetTimestamp()
+		if err := tx.Create(member).Error; err != nil {
+			return err
+		}
+
+		invitation.Status = OrganizationInvitationAccepted
+		return tx.Save(invitation).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return member, nil
+}
+
+// CheckOrganizationQuota 检查组织和成员是否可以继续消费 quota 额度
+func CheckOrganizationQuota(organizationId, userId, quota int) (*OrganizationMember, error) {
+	organization, err := GetOrganizationById(organizationId)
+	if err != nil {
+		return nil, err
+	}
+	if organization.Status != OrganizationStatusEnabled {
+		return nil, ErrOrganizationDisabled
+	}
+
+	member, err := GetOrganizationMember(organizationId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !member.CanSpend() {
+		return nil, ErrOrganizationPermission
+	}
+
+	if member.QuotaLimit > 0 && (member.UsedQuota >= member.QuotaLimit || member.UsedQuota+quota > member.QuotaLimit) {
+		return nil, ErrOrganizationMemberLimit
+	}
+
+	availableQuota := organization.Quota + GetCreditLimit(organization.BillingMode, organization.CreditLimit)
+	if availableQuota <= 0 || availableQuota < quota {
+		return nil, ErrOrganizationQuotaNotEnough
+	}
+
+	return member, nil
+}
+
+// PreConsumeOrganizationQuota 预扣组织额度并计入成员已用额度，额度和成员上限在条件更新中检查，并发请求不会超出上限
+func PreConsumeOrganizationQuota(organizationId, userId, quota int) error {
+	if quota <= 0 {
+		_, err := CheckOrganizationQuota(organizationId, userId, quota)
+		return err
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&OrganizationMember{}).
+			Where("organization_id = ? AND user_id = ? AND role IN ?", organizationId, userId, organizationSpendRoles()).
+			Where("quota_limit = 0 OR used_quota + ? <= quota_limit", quota).
+			Update("used_quota", gorm.Expr("used_quota + ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrganizationMemberLimit
+		}
+
+		result = tx.Model(&Organization{}).
+			Where("id = ? AND status = ?", organizationId, OrganizationStatusEnabled).
+			Where("quota + CASE WHEN billing_mode = ? THEN credit_limit ELSE 0 END >= ?", BillingModePostpaid, quota).
+			Update("quota", gorm.Expr("quota - ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrganizationQuotaNotEnough
+		}
+		return nil
+	})
+	if err == ErrOrganizationMemberLimit || err == ErrOrganizationQuotaNotEnough {
+		// 条件更新失败时重新检查，返回具体原因（组织禁用、不是成员等）
+		if _, checkErr := CheckOrganizationQuota(organizationId, userId, quota); checkErr != nil {
+			return checkErr
+		}
+	}
+
+	return err
+}
+
+// organizationSpendRoles 可以使用组织额度的角色
+func organizationSpendRoles() []string {
+	roles := make([]string, 0, len(organizationRoleLevel))
+	for role, level := range organizationRoleLevel {
+		if level >= organizationRoleLevel[OrganizationRoleMember] {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// PostConsumeOrganizationQuota 结算组织额度，quotaDelta 为实际消耗与预扣的差额，quota 为实际消耗
+// 成员已用额度在预扣时已经计入，这里只补上差额
+func PostConsumeOrganizationQuota(organizationId, userId, quotaDelta, quota int) error {
+	err := DB.Model(&Organization{}).Where("id = ?", organizationId).Updates(map[string]interface{}{
+		"quota":      gorm.Expr("quota - ?", quotaDelta),
+		"used_quota": gorm.Expr("used_quota + ?", quota),
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	err = DB.Model(&OrganizationMember{}).
+		Where("organization_id = ? AND user_id = ?", organizationId, userId).
+		Update("used_quota", gorm.Expr("used_quota + ?", quotaDelta)).Error
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to update organization member used quota: organizationId=%d, userId=%d, err=%s", organizationId, userId, err.Error()))
+	}
+
+	return nil
+}
@@ -0,0 +1,271 @@
+package model
+
+import (
+	"one-api/common/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupOrganizationTest(t *testing.T, organization *Organization, member *OrganizationMember) {
+	t.Helper()
+	setupTestDB(t, &Organization{}, &OrganizationMember{})
+
+	require.NoError(t, DB.Create(organization).Error)
+	if member != nil {
+		member.OrganizationId = organization.Id
+		require.NoError(t, DB.Create(member).Error)
+	}
+}
+
+func TestCheckOrganizationQuota(t *testing.T) {
+	tests := []struct {
+		name         string
+		organization Organization
+		member       *OrganizationMember
+		quota        int
+		wantErr      error
+	}{
+		{
+			name:         "member spends from the organization pool",
+			organization: Organization{Quota: 1000, Status: OrganizationStatusEnabled},
+			member:       &OrganizationMember{UserId: 1, Role: OrganizationRoleMember},
+			quota:        1000,
+		},
+		{
+			name:         "disabled organization",
+			organization: Organization{Quota: 1000, Status: OrganizationStatusDisabled},
+			member:       &OrganizationMember{UserId: 1, Role: OrganizationRoleMember},
+			quota:        100,
+			wantErr:      ErrOrganizationDisabled,
+		},
+		{
+			name:         "not a member",
+			organization: Organization{Quota: 1000, Status: OrganizationStatusEnabled},
+			quota:        100,
+			wantErr:      ErrOrganizationNotMember,
+		},
+		{
+			name:         "viewer cannot spend",
+			organization: Organization{Quota: 1000, Status: OrganizationStatusEnabled},
+			member:       &OrganizationMember{UserId: 1, Role: OrganizationRoleViewer},
+			quota:        100,
+			wantErr:      ErrOrganizationPermission,
+		},
+		{
+			name:         "member within the spending cap",
+			organization: Organization{Quota: 1000, Status: OrganizationStatusEnabled},
+			member:       &OrganizationMember{UserId: 1, Role: OrganizationRoleMember, QuotaLimit: 500, UsedQuota: 300},
+			quota:        200,
+		},
+		{
+			name:         "member over the spending cap",
+			organization: Organization{Quota: 1000, Status: OrganizationStatusEnabled},
+			member:       &OrganizationMember{UserId: 1, Role: OrganizationRoleMember, QuotaLimit: 500, UsedQuota: 300},
+			quota:        201,
+			wantErr:      ErrOrganizationMemberLimit,
+		},
+		{
+			name:         "member with the spending cap used up",
+			organization: Organization{Quota: 1000, Status: OrganizationStatusEnabled},
+			member:       &OrganizationMember{UserId: 1, Role: OrganizationRoleMember, QuotaLimit: 500, UsedQuota: 500},
+			quota:        0,
+			wantErr:      ErrOrganizationMemberLimit,
+		},
+		{
+			name:         "organization pool not enough",
+			organization: Organization{Quota: 100, Status: OrganizationStatusEnabled},
+			member:       &OrganizationMember{UserId: 1, Role: OrganizationRoleOwner},
+			quota:        101,
+			wantErr:      ErrOrganizationQuotaNotEnough,
+		},
+		{
+			name:         "empty organization pool",
+			organization: Organization{Quota: 0, Status: OrganizationStatusEnabled},
+			member:       &OrganizationMember{UserId: 1, Role: OrganizationRoleOwner},
+			quota:        0,
+			wantErr:      ErrOrganizationQuotaNotEnough,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			organization := tt.organization
+			setupOrganizationTest(t, &organization, tt.member)
+
+			member, err := CheckOrganizationQuota(organization.Id, 1, tt.quota)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, member)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, 1, member.UserId)
+		})
+	}
+}
+
+func TestPreConsumeOrganizationQuota(t *testing.T) {
+	tests := []struct {
+		name         string
+		organization Organization
+		member       *OrganizationMember
+		quota        int
+		wantErr      error
+		wantQuota    int
+		wantUsed     int
+	}{
+		{
+			name:         "reserves the pool and the member cap",
+			organization: Organization{Quota: 1000, Status: OrganizationStatusEnabled},
+			member:       &OrganizationMember{UserId: 1, Role: OrganizationRoleMember, QuotaLimit: 500, UsedQuota: 300},
+			quota:        200,
+			wantQuota:    800,
+			wantUsed:     500,
+		},
+		{
+			name:         "member cap exceeded",
+			organization: Organization{Quota: 1000, Status: OrganizationStatusEnabled},
+			member:       &OrganizationMember{UserId: 1, Role: OrganizationRoleMember, QuotaLimit: 500, UsedQuota: 300},
+			quota:        201,
+			wantErr:      ErrOrganizationMemberLimit,
+			wantQuota:    1000,
+			wantUsed:     300,
+		},
+		{
+			name:         "pool not enough rolls back the member reservation",
+			organization: Organization{Quota: 100, Status: OrganizationStatusEnabled},
+			member:       &OrganizationMember{UserId: 1, Role: OrganizationRoleMember},
+			quota:        101,
+			wantErr:      ErrOrganizationQuotaNotEnough,
+			wantQuota:    100,
+			wantUsed:     0,
+		},
+		{
+			name:         "postpaid organization overdraws up to the credit limit",
+			organization: Organization{Quota: 100, Status: OrganizationStatusEnabled, BillingMode: BillingModePostpaid, CreditLimit: 200},
+			member:       &OrganizationMember{UserId: 1, Role: OrganizationRoleMember},
+			quota:        300,
+			wantQuota:    -200,
+			wantUsed:     300,
+		},
+		{
+			name:         "disabled organization",
+			organization: Organization{Quota: 1000, Status: OrganizationStatusDisabled},
+			member:       &OrganizationMember{UserId: 1, Role: OrganizationRoleMember},
+			quota:        100,
+			wantErr:      ErrOrganizationDisabled,
+			wantQuota:    1000,
+			wantUsed:     0,
+		},
+		{
+			name:         "viewer cannot spend",
+			organization: Organization{Quota: 1000, Status: OrganizationStatusEnabled},
+			member:       &OrganizationMember{UserId: 1, Role: OrganizationRoleViewer},
+			quota:        100,
+			wantErr:      ErrOrganizationPermission,
+			wantQuota:    1000,
+			wantUsed:     0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			organization := tt.organization
+			setupOrganizationTest(t, &organization, tt.member)
+
+			err := PreConsumeOrganizationQuota(organization.Id, 1, tt.quota)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			got, err := GetOrganizationById(organization.Id)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantQuota, got.Quota)
+
+			member, err := GetOrganizationMember(organization.Id, 1)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantUsed, member.UsedQuota)
+		})
+	}
+}
+
+// 多个请求同时预扣时，成员上限只允许其中一部分通过
+func TestPreConsumeOrganizationQuotaMemberCap(t *testing.T) {
+	organization := &Organization{Quota: 10000, Status: OrganizationStatusEnabled}
+	setupOrganizationTest(t, organization, &OrganizationMember{UserId: 1, Role: OrganizationRoleMember, QuotaLimit: 500})
+
+	succeeded := 0
+	for i := 0; i < 10; i++ {
+		if PreConsumeOrganizationQuota(organization.Id, 1, 100) == nil {
+			succeeded++
+		}
+	}
+	assert.Equal(t, 5, succeeded)
+
+	member, err := GetOrganizationMember(organization.Id, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 500, member.UsedQuota)
+}
+
+func TestPostConsumeOrganizationQuota(t *testing.T) {
+	organization := &Organization{Quota: 1000, Status: OrganizationStatusEnabled}
+	setupOrganizationTest(t, organization, &OrganizationMember{UserId: 1, Role: OrganizationRoleMember})
+
+	// 预扣 300，实际消耗 250
+	require.NoError(t, PreConsumeOrganizationQuota(organization.Id, 1, 300))
+	require.NoError(t, PostConsumeOrganizationQuota(organization.Id, 1, -50, 250))
+
+	organization, err := GetOrganizationById(organization.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 750, organization.Quota)
+	assert.Equal(t, 250, organization.UsedQuota)
+
+	member, err := GetOrganizationMember(organization.Id, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 250, member.UsedQuota)
+
+	// 失败的请求退回预扣
+	require.NoError(t, PreConsumeOrganizationQuota(organization.Id, 1, 300))
+	require.NoError(t, PostConsumeOrganizationQuota(organization.Id, 1, -300, 0))
+	organization, err = GetOrganizationById(organization.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 750, organization.Quota)
+
+	member, err = GetOrganizationMember(organization.Id, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 250, member.UsedQuota)
+}
+
+func TestRemoveOrganizationMemberDisablesTokens(t *testing.T) {
+	organization := &Organization{OwnerId: 1, Quota: 1000, Status: OrganizationStatusEnabled}
+	setupOrganizationTest(t, organization, &OrganizationMember{UserId: 2, Role: OrganizationRoleMember})
+	require.NoError(t, DB.AutoMigrate(&Token{}))
+
+	tokens := []*Token{
+		{UserId: 2, OrganizationId: organization.Id, Key: "org-token", Status: config.TokenStatusEnabled},
+		{UserId: 2, Key: "personal-token", Status: config.TokenStatusEnabled},
+	}
+	for _, token := range tokens {
+		require.NoError(t, DB.Session(&gorm.Session{SkipHooks: true}).Create(token).Error)
+	}
+
+	require.NoError(t, RemoveOrganizationMember(organization.Id, 2))
+
+	orgToken, err := GetTokenById(tokens[0].Id)
+	require.NoError(t, err)
+	assert.Equal(t, 1, orgToken.UserId)
+	assert.Equal(t, config.TokenStatusDisabled, orgToken.Status)
+
+	personalToken, err := GetTokenById(tokens[1].Id)
+	require.NoError(t, err)
+	assert.Equal(t, 2, personalToken.UserId)
+	assert.Equal(t, config.TokenStatusEnabled, personalToken.Status)
+
+	_, err = GetOrganizationMember(organization.Id, 2)
+	assert.ErrorIs(t, err, ErrOrganizationNotMember)
+}
@@ -141,6 +141,75 @@ func (p *Pricing) GetPrice(modelName string) *Price {
 	}
 }
 
+// channelTypePrices 按渠道类型覆盖的模型价格，渠道类型 -> 模型 -> 价格
+var channelTypePrices = struct {
+	sync.RWMutex
+	prices map[int]map[string]*Price
+}{prices: make(map[int]map[string]*Price)}
+
+// ChannelTypePrices2JSONString 导出渠道类型价格配置
+func ChannelTypePrices2JSONString() string {
+	channelTypePrices.RLock()
+	defer channelTypePrices.RUnlock()
+
+	jsonBytes, err := json.Marshal(channelTypePrices.prices)
+	if err != nil {
+		logger.SysError("error marshalling channel type prices: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+// UpdateChannelTypePricesByJSONString 加载渠道类型价格配置，格式为 {"渠道类型": {"模型": {"input": 1, "output": 2}}}
+func UpdateChannelTypePricesByJSONString(jsonStr string) error {
+	prices := make(map[int]map[string]*Price)
+	if jsonStr != "" {
+		if err := json.Unmarshal([]byte(jsonStr), &prices); err != nil {
+			return err
+		}
+	}
+
+	channelTypePrices.Lock()
+	defer channelTypePrices.Unlock()
+	channelTypePrices.prices = prices
+
+	return nil
+}
+
+// GetChannelTypePrice 返回该渠道类型下模型的价格，未单独配置时使用全局价格
+func (p *Pricing) GetChannelTypePrice(channelType int, modelName string) *Price {
+	price := p.GetPrice(modelName)
+
+	channelTypePrices.RLock()
+	defer channelTypePrices.RUnlock()
+
+	prices, ok := channelTypePrices.prices[channelType]
+	if !ok {
+		return price
+	}
+
+	override, ok := prices[modelName]
+	if !ok {
+		var match []string
+		for model := range prices {
+			if strings.HasSuffix(model, "*") {
+				match = append(match, model)
+			}
+		}
+		override, ok = prices[utils.GetModelsWithMatch(&match, modelName)]
+	}
+	if !ok {
+		return price
+	}
+
+	// 只覆盖单价，计费类型和额外倍率沿用全局价格
+	channelPrice := *price
+	channelPrice.ChannelType = channelType
+	channelPrice.Input = override.Input
+	channelPrice.Output = override.Output
+
+	return &channelPrice
+}
+
 func (p *Pricing) GetAllPrices() map[string]*Price {
 	return p.Prices
 }
@@ -0,0 +1,237 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"one-api/common/utils"
+
+	"gorm.io/gorm"
+)
+
+type RefundStatus string
+
+const (
+	RefundStatusPending RefundStatus = "pending"
+	RefundStatusSuccess RefundStatus = "success"
+	RefundStatusFailed  RefundStatus = "failed"
+)
+
+// RefundQuotaPolicy 用户余额不足以扣回退款对应额度时的处理方式
+type RefundQuotaPolicy string
+
+const (
+	RefundQuotaPolicyStrict   RefundQuotaPolicy = "strict"   // 拒绝退款
+	RefundQuotaPolicyNegative RefundQuotaPolicy = "negative" // 允许扣为负数
+)
+
+type OrderRefund struct {
+	ID              int               `json:"id"`
+	OrderId         int               `json:"order_id" gorm:"index"`
+	UserId          int               `json:"user_id" gorm:"index"`
+	GatewayId       int               `json:"gateway_id"`
+	TradeNo         string            `json:"trade_no" gorm:"type:varchar(50);index"`
+	RefundNo        string            `json:"refund_no" gorm:"type:varchar(50);uniqueIndex"`
+	GatewayRefundNo string            `json:"gateway_refund_no" gorm:"type:varchar(100)"`
+	Amount          float64           `json:"amount" gorm:"type:decimal(10,2);default:0"` // 退款金额，币种与订单支付币种一致
+	Currency        CurrencyType      `json:"currency" gorm:"type:varchar(16)"`
+	Quota           int               `json:"quota" gorm:"type:int;default:0"` // 扣回的额度
+	QuotaPolicy     RefundQuotaPolicy `json:"quota_policy" gorm:"type:varchar(16)"`
+	Status          RefundStatus      `json:"status" gorm:"type:varchar(32)"`
+	Reason          string            `json:"reason" gorm:"type:varchar(255)"`
+	Message         string            `json:"message" gorm:"type:varchar(255)"` // 退款失败的原因
+	CreatedAt       int               `json:"created_at"`
+	UpdatedAt       int               `json:"updated_at"`
+}
+
+func GetOrderRefundByRefundNo(refundNo string) (*OrderRefund, error) {
+	var refund OrderRefund
+	err := DB.Where("refund_no = ?", refundNo).First(&refund).Error
+	return &refund, err
+}
+
+func GetOrderRefunds(orderId int) (refunds []*OrderRefund, err error) {
+	err = DB.Where("order_id = ?", orderId).Order("id desc").Find(&refunds).Error
+	return refunds, err
+}
+
+// CreateOrderRefund 创建退款单并按退款比例扣回用户额度，amount 为 0 时退还剩余全部金额
+// 调用方需要持有订单锁，网关退款失败时调用 Fail 返还额度
+func CreateOrderRefund(orderId int, amount float64, reason string, policy RefundQuotaPolicy) (*OrderRefund, error) {
+	if policy == "" {
+		policy = RefundQuotaPolicyStrict
+	}
+	if policy != RefundQuotaPolicyStrict && policy != RefundQuotaPolicyNegative {
+		return nil, errors.New("不支持的额度扣回策略")
+	}
+
+	var refund *OrderRefund
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		order := &Order{}
+		if err := tx.Where("id = ?", orderId).First(order).Error; err != nil {
+			return errors.New("订单不存在")
+		}
+		if order.Status != OrderStatusSuccess && order.Status != OrderStatusPartialRefunded {
+			return errors.New("订单当前状态不允许退款")
+		}
+
+		refundable := utils.Decimal(order.OrderAmount-order.RefundAmount, 2)
+		if amount == 0 {
+			amount = refundable
+		}
+		amount = utils.Decimal(amount, 2)
+		if amount <= 0 || amount > refundable {
+			return fmt.Errorf("退款金额必须大于 0 且不超过可退金额 %.2f", refundable)
+		}
+
+		// 最后一笔退款扣回剩余的全部额度，避免按比例计算产生误差
+		quota := order.Quota - order.RefundQuota
+		if amount < refundable {
+			quota = min(int(math.Round(float64(order.Quota)*amount/order.OrderAmount)), quota)
+		}
+
+		refund = &OrderRefund{
+			OrderId:     order.ID,
+			UserId:      order.UserId,
+			GatewayId:   order.GatewayId,
+			TradeNo:     order.TradeNo,
+			RefundNo:    utils.GenerateTradeNo(),
+			Amount:      amount,
+			Currency:    order.OrderCurrency,
+			Quota:       quota,
+			QuotaPolicy: policy,
+			Status:      RefundStatusPending,
+			Reason:      reason,
+		}
+		if err := tx.Create(refund).Error; err != nil {
+			return err
+		}
+
+		if err := changeOrderRefund(tx, order.ID, amount, quota); err != nil {
+			return err
+		}
+
+		return clawbackUserQuota(tx, order.UserId, quota, policy)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	CacheUpdateUserQuota(refund.UserId)
+	RecordQuotaLog(refund.UserId, LogTypeTopup, -refund.Quota, "", fmt.Sprintf("订单 %s 申请退款，退款金额：%.2f %s，扣回额度: %d", refund.TradeNo, refund.Amount, refund.Currency, refund.Quota))
+
+	return refund, nil
+}
+
+// Succeed 网关确认退款成功，重复通知直接忽略
+func (r *OrderRefund) Succeed(gatewayRefundNo string) error {
+	updates := map[string]interface{}{
+		"status": RefundStatusSuccess,
+	}
+	if gatewayRefundNo != "" {
+		updates["gateway_refund_no"] = gatewayRefundNo
+	}
+
+	result := DB.Model(&OrderRefund{}).Where("id = ? AND status = ?", r.ID, RefundStatusPending).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	r.Status = RefundStatusSuccess
+	if gatewayRefundNo != "" {
+		r.GatewayRefundNo = gatewayRefundNo
+	}
+	RecordLog(r.UserId, LogTypeTopup, fmt.Sprintf("订单 %s 退款成功，退款金额：%.2f %s", r.TradeNo, r.Amount, r.Currency))
+
+	return nil
+}
+
+// Fail 退款失败，恢复订单的可退金额并返还扣回的额度
+func (r *OrderRefund) Fail(message string) error {
+	if runes := []rune(message); len(runes) > 255 {
+		message = string(runes[:255])
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&OrderRefund{}).Where("id = ? AND status = ?", r.ID, RefundStatusPending).Updates(map[string]interface{}{
+			"status":  RefundStatusFailed,
+			"message": message,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("退款单已处理")
+		}
+
+		if err := changeOrderRefund(tx, r.OrderId, -r.Amount, -r.Quota); err != nil {
+			return err
+		}
+
+		return tx.Model(&User{}).Where("id = ?", r.UserId).Update("quota", gorm.Expr("quota + ?", r.Quota)).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	r.Status = RefundStatusFailed
+	r.Message = message
+	CacheUpdateUserQuota(r.UserId)
+	RecordQuotaLog(r.UserId, LogTypeTopup, r.Quota, "", fmt.Sprintf("订单 %s 退款失败，返还额度: %d", r.TradeNo, r.Quota))
+
+	return nil
+}
+
+// UpdateGatewayRefundNo 记录处理中退款的网关退款单号
+func (r *OrderRefund) UpdateGatewayRefundNo(gatewayRefundNo string) error {
+	if gatewayRefundNo == "" {
+		return nil
+	}
+	r.GatewayRefundNo = gatewayRefundNo
+	return DB.Model(r).Update("gateway_refund_no", gatewayRefundNo).Error
+}
+
+// changeOrderRefund 累加订单的退款金额和额度，并按退款进度更新订单状态
+func changeOrderRefund(tx *gorm.DB, orderId int, amount float64, quota int) error {
+	order := &Order{}
+	if err := tx.Where("id = ?", orderId).First(order).Error; err != nil {
+		return err
+	}
+
+	order.RefundAmount = utils.Decimal(order.RefundAmount+amount, 2)
+	order.RefundQuota += quota
+	switch {
+	case order.RefundAmount >= order.OrderAmount:
+		order.Status = OrderStatusRefunded
+	case order.RefundAmount > 0:
+		order.Status = OrderStatusPartialRefunded
+	default:
+		order.Status = OrderStatusSuccess
+	}
+
+	return tx.Model(order).Select("refund_amount", "refund_quota", "status").Updates(order).Error
+}
+
+func clawbackUserQuota(tx *gorm.DB, userId int, quota int, policy RefundQuotaPolicy) error {
+	if quota <= 0 {
+		return nil
+	}
+
+	db := tx.Model(&User{}).Where("id = ?", userId)
+	if policy == RefundQuotaPolicyStrict {
+		db = db.Where("quota >= ?", quota)
+	}
+
+	result := db.Update("quota", gorm.Expr("quota - ?", quota))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("用户余额不足以扣回退款对应的额度")
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRefundTest(t *testing.T, userQuota int) *Order {
+	t.Helper()
+	setupTestDB(t, &Order{}, &OrderRefund{})
+
+	user := &User{Username: "refund", Quota: userQuota}
+	require.NoError(t, DB.Create(user).Error)
+
+	order := &Order{
+		UserId:        user.Id,
+		TradeNo:       "T0001",
+		OrderAmount:   10,
+		OrderCurrency: CurrencyTypeUSD,
+		Quota:         1000,
+		Status:        OrderStatusSuccess,
+	}
+	require.NoError(t, DB.Create(order).Error)
+
+	return order
+}
+
+func TestCreateOrderRefund(t *testing.T) {
+	tests := []struct {
+		name        string
+		userQuota   int
+		amounts     []float64
+		policy      RefundQuotaPolicy
+		wantErr     bool
+		wantQuota   []int
+		wantUser    int
+		wantStatus  OrderStatus
+		wantRefunds float64
+	}{
+		{
+			name:        "partial refund claws back proportional quota",
+			userQuota:   1000,
+			amounts:     []float64{3.33},
+			wantQuota:   []int{333},
+			wantUser:    667,
+			wantStatus:  OrderStatusPartialRefunded,
+			wantRefunds: 3.33,
+		},
+		{
+			name:        "zero amount refunds the remainder",
+			userQuota:   1000,
+			amounts:     []float64{0},
+			wantQuota:   []int{1000},
+			wantUser:    0,
+			wantStatus:  OrderStatusRefunded,
+			wantRefunds: 10,
+		},
+		{
+			name:        "last refund claws back the remaining quota",
+			userQuota:   1000,
+			amounts:     []float64{3.33, 3.33, 3.34},
+			wantQuota:   []int{333, 333, 334},
+			wantUser:    0,
+			wantStatus:  OrderStatusRefunded,
+			wantRefunds: 10,
+		},
+		{
+			name:        "amount over the refundable balance is rejected",
+			userQuota:   1000,
+			amounts:     []float64{10.01},
+			wantErr:     true,
+			wantUser:    1000,
+			wantStatus:  OrderStatusSuccess,
+			wantRefunds: 0,
+		},
+		{
+			name:        "strict policy rejects when user quota is insufficient",
+			userQuota:   100,
+			amounts:     []float64{5},
+			policy:      RefundQuotaPolicyStrict,
+			wantErr:     true,
+			wantUser:    100,
+			wantStatus:  OrderStatusSuccess,
+			wantRefunds: 0,
+		},
+		{
+			name:        "negative policy allows the balance to go below zero",
+			userQuota:   100,
+			amounts:     []float64{5},
+			policy:      RefundQuotaPolicyNegative,
+			wantQuota:   []int{500},
+			wantUser:    -400,
+			wantStatus:  OrderStatusPartialRefunded,
+			wantRefunds: 5,
+		},
+		{
+			name:        "unknown policy is rejected",
+			userQuota:   1000,
+			amounts:     []float64{5},
+			policy:      "ignore",
+			wantErr:     true,
+			wantUser:    1000,
+			wantStatus:  OrderStatusSuccess,
+			wantRefunds: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := setupRefundTest(t, tt.userQuota)
+
+			for i, amount := range tt.amounts {
+				refund, err := CreateOrderRefund(order.ID, amount, "test", tt.policy)
+				if tt.wantErr {
+					assert.Error(t, err)
+					continue
+				}
+				require.NoError(t, err)
+				assert.Equal(t, RefundStatusPending, refund.Status)
+				assert.Equal(t, tt.wantQuota[i], refund.Quota)
+			}
+
+			quota, err := GetUserQuota(order.UserId)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantUser, quota)
+
+			order, err = GetOrderById(order.ID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, order.Status)
+			assert.Equal(t, tt.wantRefunds, order.RefundAmount)
+		})
+	}
+}
+
+func TestOrderRefundResult(t *testing.T) {
+	order := setupRefundTest(t, 1000)
+
+	refund, err := CreateOrderRefund(order.ID, 4, "test", RefundQuotaPolicyStrict)
+	require.NoError(t, err)
+
+	// 失败时返还额度并恢复可退金额，重复处理返回错误
+	require.NoError(t, refund.Fail("rejected"))
+	assert.Error(t, refund.Fail("rejected"))
+	quota, _ := GetUserQuota(order.UserId)
+	assert.Equal(t, 1000, quota)
+	order, _ = GetOrderById(order.ID)
+	assert.Equal(t, OrderStatusSuccess, order.Status)
+	assert.Zero(t, order.RefundQuota)
+
+	refund, err = CreateOrderRefund(order.ID, 4, "test", RefundQuotaPolicyStrict)
+	require.NoError(t, err)
+	require.NoError(t, refund.Succeed("re_1"))
+	require.NoError(t, refund.Succeed("re_1"))
+	assert.Error(t, refund.Fail("late failure"))
+
+	saved, err := GetOrderRefundByRefundNo(refund.RefundNo)
+	require.NoError(t, err)
+	assert.Equal(t, RefundStatusSuccess, saved.Status)
+	assert.Equal(t, "re_1", saved.GatewayRefundNo)
+	quota, _ = GetUserQuota(order.UserId)
+	assert.Equal(t, 600, quota)
+}
@@ -0,0 +1,100 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common/utils"
+	"one-api/types"
+	"slices"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// 沿 previous_response_id 读取对话时最多读取的轮数
+const responseChainLimit = 1000
+
+// StoredResponse 网关保存的 Responses API 响应对象，用于 previous_response_id 和查询接口。
+// InputDelta 为 true 时 InputItems 只包含本轮新增的输入，之前的对话需要沿 PreviousResponseId 读取；
+// 旧版本保存的响应 InputDelta 为 false，InputItems 为包含之前对话的完整输入
+type StoredResponse struct {
+	Id                 int                                                `json:"id"`
+	ResponseId         string                                             `json:"response_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId             int                                                `json:"user_id" gorm:"index"`
+	TokenId            int                                                `json:"token_id" gorm:"default:0"`
+	PreviousResponseId string                                             `json:"previous_response_id" gorm:"type:varchar(64);default:''"`
+	Model              string                                             `json:"model" gorm:"type:varchar(255)"`
+	InputItems         datatypes.JSONType[[]map[string]any]               `json:"input_items" gorm:"type:json"`
+	Response           datatypes.JSONType[types.OpenAIResponsesResponses] `json:"response" gorm:"type:json"`
+	InputDelta         bool                                               `json:"input_delta" gorm:"default:false"`
+	CreatedAt          int64                                              `json:"created_at" gorm:"bigint;index"`
+	ExpiresAt          int64                                              `json:"expires_at" gorm:"bigint;index;default:0"`
+}
+
+func (StoredResponse) TableName() string {
+	return "responses"
+}
+
+func NewResponseId() string {
+	return "resp_" + utils.GetRandomString(48)
+}
+
+func (r *StoredResponse) Insert() error {
+	return DB.Create(r).Error
+}
+
+func (r *StoredResponse) Delete() error {
+	return DB.Delete(r).Error
+}
+
+// GetUserResponse 响应对象按用户隔离，过期的响应视为不存在
+func GetUserResponse(userId int, responseId string) (*StoredResponse, error) {
+	if responseId == "" {
+		return nil, errors.New("response id is empty")
+	}
+
+	var response StoredResponse
+	err := DB.Where("user_id = ? AND response_id = ? AND (expires_at = 0 OR expires_at > ?)", userId, responseId, utils.GetTimestamp()).First(&response).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("Response with id '" + responseId + "' not found.")
+	}
+
+	return &response, err
+}
+
+// GetResponseChain 沿 previous_response_id 向前读取同一对话中的响应，按时间顺序返回，最后一个为 response 本身。
+// 读取到包含完整输入的旧数据时停止，之前的响应已过期或被删除时返回错误
+func GetResponseChain(response *StoredResponse) ([]*StoredResponse, error) {
+	chain := []*StoredResponse{response}
+	current := response
+	for current.InputDelta && current.PreviousResponseId != "" {
+		if len(chain) >= responseChainLimit {
+			return nil, errors.New("conversation is too long")
+		}
+
+		previous, err := GetUserResponse(response.UserId, current.PreviousResponseId)
+		if err != nil {
+			return nil, fmt.Errorf("previous response '%s' of '%s' is no longer available", current.PreviousResponseId, current.ResponseId)
+		}
+		chain = append(chain, previous)
+		current = previous
+	}
+
+	slices.Reverse(chain)
+	return chain, nil
+}
+
+// ExtendResponsesExpiry 对话继续时延长之前响应的保存时间，避免对话中间的响应先过期
+func ExtendResponsesExpiry(ids []int, expiresAt int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return DB.Model(&StoredResponse{}).Where("id IN ? AND expires_at > 0 AND expires_at < ?", ids, expiresAt).
+		Update("expires_at", expiresAt).Error
+}
+
+func DeleteExpiredResponses(timestamp int64) (int64, error) {
+	result := DB.Where("expires_at > 0 AND expires_at <= ?", timestamp).Delete(&StoredResponse{})
+	return result.RowsAffected, result.Error
+}
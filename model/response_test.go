@@ -0,0 +1,94 @@
+package model
+
+import (
+	"one-api/common/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+)
+
+func createStoredResponse(t *testing.T, responseId, previousId string, inputDelta bool, expiresAt int64) *StoredResponse {
+	t.Helper()
+	stored := &StoredResponse{
+		ResponseId:         responseId,
+		UserId:             1,
+		PreviousResponseId: previousId,
+		InputItems:         datatypes.NewJSONType([]map[string]any{{"role": "user", "content": responseId}}),
+		InputDelta:         inputDelta,
+		CreatedAt:          utils.GetTimestamp(),
+		ExpiresAt:          expiresAt,
+	}
+	require.NoError(t, stored.Insert())
+	return stored
+}
+
+func responseIds(chain []*StoredResponse) []string {
+	ids := make([]string, 0, len(chain))
+	for _, stored := range chain {
+		ids = append(ids, stored.ResponseId)
+	}
+	return ids
+}
+
+func TestGetResponseChain(t *testing.T) {
+	now := utils.GetTimestamp()
+
+	t.Run("delta responses are read back to the first turn", func(t *testing.T) {
+		setupTestDB(t, &StoredResponse{})
+		createStoredResponse(t, "resp_1", "", true, now+100)
+		createStoredResponse(t, "resp_2", "resp_1", true, now+100)
+		last := createStoredResponse(t, "resp_3", "resp_2", true, now+100)
+
+		chain, err := GetResponseChain(last)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"resp_1", "resp_2", "resp_3"}, responseIds(chain))
+	})
+
+	t.Run("legacy response with full input stops the walk", func(t *testing.T) {
+		setupTestDB(t, &StoredResponse{})
+		createStoredResponse(t, "resp_1", "", false, now+100)
+		createStoredResponse(t, "resp_2", "resp_1", false, now+100)
+		last := createStoredResponse(t, "resp_3", "resp_2", true, now+100)
+
+		chain, err := GetResponseChain(last)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"resp_2", "resp_3"}, responseIds(chain))
+	})
+
+	t.Run("expired previous response breaks the conversation", func(t *testing.T) {
+		setupTestDB(t, &StoredResponse{})
+		createStoredResponse(t, "resp_1", "", true, now-1)
+		last := createStoredResponse(t, "resp_2", "resp_1", true, now+100)
+
+		_, err := GetResponseChain(last)
+		assert.ErrorContains(t, err, "resp_1")
+	})
+
+	t.Run("responses of other users are not followed", func(t *testing.T) {
+		setupTestDB(t, &StoredResponse{})
+		other := createStoredResponse(t, "resp_1", "", true, now+100)
+		require.NoError(t, DB.Model(other).Update("user_id", 2).Error)
+		last := createStoredResponse(t, "resp_2", "resp_1", true, now+100)
+
+		_, err := GetResponseChain(last)
+		assert.Error(t, err)
+	})
+}
+
+func TestExtendResponsesExpiry(t *testing.T) {
+	setupTestDB(t, &StoredResponse{})
+	now := utils.GetTimestamp()
+	first := createStoredResponse(t, "resp_1", "", true, now+10)
+	later := createStoredResponse(t, "resp_2", "resp_1", true, now+500)
+	forever := createStoredResponse(t, "resp_3", "", true, 0)
+
+	require.NoError(t, ExtendResponsesExpiry([]int{first.Id, later.Id, forever.Id}, now+300))
+
+	for id, want := range map[int]int64{first.Id: now + 300, later.Id: now + 500, forever.Id: 0} {
+		var stored StoredResponse
+		require.NoError(t, DB.First(&stored, id).Error)
+		assert.Equal(t, want, stored.ExpiresAt, stored.ResponseId)
+	}
+}
@@ -0,0 +1,288 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"one-api/common/cache"
+	"one-api/common/config"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	RoutingStrategyWeighted      = "weighted"
+	RoutingStrategyLatency       = "latency"
+	RoutingStrategyLeastInflight = "least_inflight"
+	RoutingStrategyCost          = "cost"
+	RoutingStrategySticky        = "sticky"
+
+	RoutingStickyCacheKey = "routing_sticky:%s:%s:%s"
+
+	// EWMA 平滑系数，越大越偏向最近的请求
+	routingLatencyAlpha = 0.3
+)
+
+// RoutingContext 选择渠道时可用的请求信息
+type RoutingContext struct {
+	Group     string
+	ModelName string
+	// StickyKey 会话粘滞使用的键，如会话 id 或用户 id
+	StickyKey string
+}
+
+// RoutingStrategy 在同一优先级的可用渠道中选出一个
+type RoutingStrategy interface {
+	Name() string
+	Select(choices []*ChannelChoice, ctx *RoutingContext) *ChannelChoice
+}
+
+var routingStrategies = map[string]RoutingStrategy{}
+
+func RegisterRoutingStrategy(strategy RoutingStrategy) {
+	routingStrategies[strategy.Name()] = strategy
+}
+
+func init() {
+	RegisterRoutingStrategy(&WeightedStrategy{})
+	RegisterRoutingStrategy(&LatencyStrategy{})
+	RegisterRoutingStrategy(&LeastInflightStrategy{})
+	RegisterRoutingStrategy(&CostStrategy{})
+	RegisterRoutingStrategy(&StickyStrategy{})
+}
+
+// GetRoutingStrategy 未知或为空时使用默认的加权随机
+func GetRoutingStrategy(name string) RoutingStrategy {
+	if strategy, ok := routingStrategies[name]; ok {
+		return strategy
+	}
+
+	return routingStrategies[RoutingStrategyWeighted]
+}
+
+func IsValidRoutingStrategy(name string) bool {
+	if name == "" {
+		return true
+	}
+	_, ok := routingStrategies[name]
+	return ok
+}
+
+// WeightedStrategy 按渠道权重随机
+type WeightedStrategy struct{}
+
+func (s *WeightedStrategy) Name() string {
+	return RoutingStrategyWeighted
+}
+
+func (s *WeightedStrategy) Select(choices []*ChannelChoice, _ *RoutingContext) *ChannelChoice {
+	return weightedChoice(choices)
+}
+
+func weightedChoice(choices []*ChannelChoice) *ChannelChoice {
+	if len(choices) == 0 {
+		return nil
+	}
+
+	if len(choices) == 1 {
+		return choices[0]
+	}
+
+	totalWeight := 0
+	for _, choice := range choices {
+		totalWeight += int(*choice.Channel.Weight)
+	}
+	if totalWeight <= 0 {
+		return choices[rand.Intn(len(choices))]
+	}
+
+	choiceWeight := rand.Intn(totalWeight)
+	for _, choice := range choices {
+		choiceWeight -= int(*choice.Channel.Weight)
+		if choiceWeight < 0 {
+			return choice
+		}
+	}
+
+	return choices[len(choices)-1]
+}
+
+// lowestScoreChoice 选出分数最低的渠道，分数相同时按权重随机
+func lowestScoreChoice(choices []*ChannelChoice, score func(choice *ChannelChoice) float64) *ChannelChoice {
+	best := math.Inf(1)
+	candidates := make([]*ChannelChoice, 0, len(choices))
+
+	for _, choice := range choices {
+		value := score(choice)
+		switch {
+		case value < best:
+			best = value
+			candidates = append(candidates[:0], choice)
+		case value == best:
+			candidates = append(candidates, choice)
+		}
+	}
+
+	return weightedChoice(candidates)
+}
+
+// LatencyStrategy 按 EWMA 延迟选择，延迟会乘以当前进行中的请求数，避免所有请求涌向同一渠道
+type LatencyStrategy struct{}
+
+func (s *LatencyStrategy) Name() string {
+	return RoutingStrategyLatency
+}
+
+func (s *LatencyStrategy) Select(choices []*ChannelChoice, ctx *RoutingContext) *ChannelChoice {
+	return lowestScoreChoice(choices, func(choice *ChannelChoice) float64 {
+		channelId := choice.Channel.Id
+		latency, ok := ChannelStats.GetLatency(channelId, ctx.ModelName)
+		if !ok {
+			// 没有统计数据时使用渠道测试的响应时间，都没有则优先尝试
+			latency = float64(choice.Channel.ResponseTime)
+		}
+
+		return latency * float64(ChannelStats.GetInflight(channelId)+1)
+	})
+}
+
+// LeastInflightStrategy 选择当前进行中请求最少的渠道
+type LeastInflightStrategy struct{}
+
+func (s *LeastInflightStrategy) Name() string {
+	return RoutingStrategyLeastInflight
+}
+
+func (s *LeastInflightStrategy) Select(choices []*ChannelChoice, _ *RoutingContext) *ChannelChoice {
+	return lowestScoreChoice(choices, func(choice *ChannelChoice) float64 {
+		return float64(ChannelStats.GetInflight(choice.Channel.Id))
+	})
+}
+
+// CostStrategy 选择实际价格最低的渠道，价格按渠道模型映射后的上游模型计算
+type CostStrategy struct{}
+
+func (s *CostStrategy) Name() string {
+	return RoutingStrategyCost
+}
+
+func (s *CostStrategy) Select(choices []*ChannelChoice, ctx *RoutingContext) *ChannelChoice {
+	return lowestScoreChoice(choices, func(choice *ChannelChoice) float64 {
+		return choice.EffectivePrice(ctx.ModelName)
+	})
+}
+
+// StickyStrategy 同一会话或用户尽量使用同一渠道，便于命中上游的提示词缓存
+type StickyStrategy struct{}
+
+func (s *StickyStrategy) Name() string {
+	return RoutingStrategySticky
+}
+
+func (s *StickyStrategy) Select(choices []*ChannelChoice, ctx *RoutingContext) *ChannelChoice {
+	if ctx.StickyKey == "" {
+		return weightedChoice(choices)
+	}
+
+	cacheKey := fmt.Sprintf(RoutingStickyCacheKey, ctx.Group, ctx.ModelName, ctx.StickyKey)
+	if channelId, err := cache.GetCache[int](cacheKey); err == nil && channelId > 0 {
+		for _, choice := range choices {
+			if choice.Channel.Id == channelId {
+				return choice
+			}
+		}
+	}
+
+	choice := weightedChoice(choices)
+	if choice != nil {
+		cache.SetCache(cacheKey, choice.Channel.Id, time.Duration(config.RoutingStickyTTL)*time.Second)
+	}
+
+	return choice
+}
+
+// EffectivePrice 渠道处理该模型时的价格，优先使用渠道类型价格，按次计费的模型只比较单价
+func (choice *ChannelChoice) EffectivePrice(modelName string) float64 {
+	if mapped, ok := choice.ModelMap[modelName]; ok && mapped != "" {
+		modelName = mapped
+	}
+
+	price := PricingInstance.GetChannelTypePrice(choice.Channel.Type, modelName)
+	if price.Type == TimesPriceType {
+		return price.Input
+	}
+
+	return price.Input + price.Output
+}
+
+type latencyStat struct {
+	sync.Mutex
+	value float64
+}
+
+// ChannelStatsManager 渠道的实时统计，保存在内存中，各节点独立统计
+type ChannelStatsManager struct {
+	latency  sync.Map // channelId:model -> *latencyStat
+	inflight sync.Map // channelId -> *atomic.Int64
+}
+
+var ChannelStats = &ChannelStatsManager{}
+
+func (m *ChannelStatsManager) RecordLatency(channelId int, modelName string, duration time.Duration) {
+	if channelId == 0 || duration <= 0 {
+		return
+	}
+
+	key := fmt.Sprintf("%d:%s", channelId, modelName)
+	value, loaded := m.latency.LoadOrStore(key, &latencyStat{value: float64(duration.Milliseconds())})
+	if !loaded {
+		return
+	}
+
+	stat := value.(*latencyStat)
+	stat.Lock()
+	stat.value = routingLatencyAlpha*float64(duration.Milliseconds()) + (1-routingLatencyAlpha)*stat.value
+	stat.Unlock()
+}
+
+func (m *ChannelStatsManager) GetLatency(channelId int, modelName string) (float64, bool) {
+	value, ok := m.latency.Load(fmt.Sprintf("%d:%s", channelId, modelName))
+	if !ok {
+		return 0, false
+	}
+
+	stat := value.(*latencyStat)
+	stat.Lock()
+	defer stat.Unlock()
+
+	return stat.value, true
+}
+
+func (m *ChannelStatsManager) counter(channelId int) *atomic.Int64 {
+	value, _ := m.inflight.LoadOrStore(channelId, &atomic.Int64{})
+	return value.(*atomic.Int64)
+}
+
+func (m *ChannelStatsManager) IncInflight(channelId int) {
+	if channelId == 0 {
+		return
+	}
+	m.counter(channelId).Add(1)
+}
+
+func (m *ChannelStatsManager) DecInflight(channelId int) {
+	if channelId == 0 {
+		return
+	}
+	m.counter(channelId).Add(-1)
+}
+
+func (m *ChannelStatsManager) GetInflight(channelId int) int64 {
+	value, ok := m.inflight.Load(channelId)
+	if !ok {
+		return 0
+	}
+
+	return value.(*atomic.Int64).Load()
+}
@@ -0,0 +1,82 @@
+package model
+
+import (
+	"one-api/common/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelChoiceEffectivePrice(t *testing.T) {
+	savedPricing := PricingInstance
+	PricingInstance = &Pricing{
+		Prices: map[string]*Price{
+			"gpt-4o":   {Model: "gpt-4o", Type: TokensPriceType, Input: 2.5, Output: 7.5},
+			"claude-*": {Model: "claude-*", Type: TokensPriceType, Input: 3, Output: 15},
+			"dall-e-3": {Model: "dall-e-3", Type: TimesPriceType, Input: 20, Output: 20},
+		},
+		Match: []string{"claude-*"},
+	}
+	require.NoError(t, UpdateChannelTypePricesByJSONString(`{
+		"3": {"gpt-4o": {"input": 2, "output": 6}, "dall-e-3": {"input": 15, "output": 15}},
+		"14": {"claude-*": {"input": 2, "output": 10}}
+	}`))
+	t.Cleanup(func() {
+		PricingInstance = savedPricing
+		UpdateChannelTypePricesByJSONString("")
+	})
+
+	tests := []struct {
+		name        string
+		channelType int
+		modelMap    map[string]string
+		modelName   string
+		want        float64
+	}{
+		{
+			name:        "channel type price overrides the global price",
+			channelType: config.ChannelTypeAzure,
+			modelName:   "gpt-4o",
+			want:        8,
+		},
+		{
+			name:        "falls back to the global price without a channel type price",
+			channelType: config.ChannelTypeOpenAI,
+			modelName:   "gpt-4o",
+			want:        10,
+		},
+		{
+			name:        "falls back to the global price for models the channel type does not price",
+			channelType: config.ChannelTypeAzure,
+			modelName:   "claude-3-5-sonnet",
+			want:        18,
+		},
+		{
+			name:        "channel type price matches wildcard models",
+			channelType: config.ChannelTypeAnthropic,
+			modelName:   "claude-3-5-sonnet",
+			want:        12,
+		},
+		{
+			name:        "times priced models compare the unit price",
+			channelType: config.ChannelTypeAzure,
+			modelName:   "dall-e-3",
+			want:        15,
+		},
+		{
+			name:        "mapped model is priced as the upstream model",
+			channelType: config.ChannelTypeAzure,
+			modelMap:    map[string]string{"gpt-4": "gpt-4o"},
+			modelName:   "gpt-4",
+			want:        8,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			choice := &ChannelChoice{Channel: &Channel{Type: tt.channelType}, ModelMap: tt.modelMap}
+			assert.Equal(t, tt.want, choice.EffectivePrice(tt.modelName))
+		})
+	}
+}
@@ -19,6 +19,61 @@ type Statistics struct {
 	RequestTime      int       `json:"request_time"`
 }
 
+// OrganizationStatistics 组织令牌按成员和模型汇总的每日用量
+type OrganizationStatistics struct {
+	Date             time.Time `gorm:"primary_key;type:date" json:"date"`
+	OrganizationId   int       `json:"organization_id" gorm:"primary_key"`
+	UserId           int       `json:"user_id" gorm:"primary_key"`
+	ModelName        string    `json:"model_name" gorm:"primary_key;type:varchar(255)"`
+	RequestCount     int       `json:"request_count"`
+	Quota            int       `json:"quota"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	RequestTime      int       `json:"request_time"`
+}
+
+// OrganizationMemberStatistic 组织成员在指定时间段内各模型的用量
+type OrganizationMemberStatistic struct {
+	Date             string `gorm:"column:date" json:"date"`
+	UserId           int    `gorm:"column:user_id" json:"user_id"`
+	Username         string `gorm:"column:username" json:"username"`
+	ModelName        string `gorm:"column:model_name" json:"model_name"`
+	RequestCount     int64  `gorm:"column:request_count" json:"request_count"`
+	Quota            int64  `gorm:"column:quota" json:"quota"`
+	PromptTokens     int64  `gorm:"column:prompt_tokens" json:"prompt_tokens"`
+	CompletionTokens int64  `gorm:"column:completion_tokens" json:"completion_tokens"`
+	RequestTime      int64  `gorm:"column:request_time" json:"request_time"`
+}
+
+// GetOrganizationStatisticsByPeriod 获取组织在指定时间段内按日期、成员和模型分组的统计数据
+func GetOrganizationStatisticsByPeriod(organizationId int, startTime, endTime string) (statistics []*OrganizationMemberStatistic, err error) {
+	dateStr := "organization_statistics.date"
+	if common.UsingPostgreSQL {
+		dateStr = "TO_CHAR(organization_statistics.date, 'YYYY-MM-DD') as date"
+	} else if common.UsingSQLite {
+		dateStr = "strftime('%Y-%m-%d', organization_statistics.date) as date"
+	}
+
+	err = DB.Raw(`
+		SELECT `+dateStr+`,
+		organization_statistics.user_id,
+		users.username,
+		organization_statistics.model_name,
+		sum(organization_statistics.request_count) as request_count,
+		sum(organization_statistics.quota) as quota,
+		sum(organization_statistics.prompt_tokens) as prompt_tokens,
+		sum(organization_statistics.completion_tokens) as completion_tokens,
+		sum(organization_statistics.request_time) as request_time
+		FROM organization_statistics
+		LEFT JOIN users ON organization_statistics.user_id = users.id
+		WHERE organization_statistics.organization_id = ?
+		AND organization_statistics.date BETWEEN ? AND ?
+		GROUP BY organization_statistics.date, organization_statistics.user_id, users.username, organization_statistics.model_name
+		ORDER BY organization_statistics.date, organization_statistics.user_id, organization_statistics.model_name
+	`, organizationId, startTime, endTime).Scan(&statistics).Error
+	return
+}
+
 func GetUserModelStatisticsByPeriod(userId int, startTime, endTime string) (LogStatistic []*LogStatisticGroupModel, err error) {
 	dateStr := "date"
 	if common.UsingPostgreSQL {
@@ -299,5 +354,35 @@ func UpdateStatistics(updateType StatisticsUpdateType) error {
 	}
 
 	err := DB.Exec(fmt.Sprintf(sql, sqlPrefix, sqlDate, sqlWhere, sqlSuffix)).Error
-	return err
+	if err != nil {
+		return err
+	}
+
+	// 组织令牌的用量另外按组织汇总
+	organizationSql := `
+	%s organization_statistics (date, organization_id, user_id, model_name, request_count, quota, prompt_tokens, completion_tokens, request_time)
+	SELECT 
+		%s as date,
+		organization_id,
+		user_id,
+		model_name, 
+		count(1) as request_count,
+		sum(quota) as quota,
+		sum(prompt_tokens) as prompt_tokens,
+		sum(completion_tokens) as completion_tokens,
+		sum(request_time) as request_time
+	FROM logs
+	WHERE
+		type = 2
+		AND organization_id > 0
+		%s
+	GROUP BY date, organization_id, user_id, model_name
+	ORDER BY date, model_name
+	%s
+	`
+	if common.UsingPostgreSQL {
+		sqlSuffix = strings.Replace(sqlSuffix, "(date, user_id, channel_id, model_name)", "(date, organization_id, user_id, model_name)", 1)
+	}
+
+	return DB.Exec(fmt.Sprintf(organizationSql, sqlPrefix, sqlDate, sqlWhere, sqlSuffix)).Error
 }
@@ -28,6 +28,12 @@ type StatisticsMonthNoModel struct {
 	PromptTokens     int    `gorm:"column:prompt_tokens" json:"prompt_tokens"`         //输入TOKEN
 	CompletionTokens int    `gorm:"column:completion_tokens" json:"completion_tokens"` //输出TOKEN
 	RequestTime      int    `gorm:"column:request_time" json:"request_time"`           //请求时长
+
+	InvoiceId     int     `gorm:"-" json:"invoice_id,omitempty"`   //账单ID
+	InvoiceQuota  int     `gorm:"-" json:"invoice_quota"`          //账单额度，不包含组织令牌的用量
+	InvoiceAmount float64 `gorm:"-" json:"invoice_amount"`         //账单金额
+	BillingMode   string  `gorm:"-" json:"billing_mode,omitempty"` //计费模式
+	Status        string  `gorm:"-" json:"status,omitempty"`       //结算状态
 }
 
 type StatisticsMonthModel struct {
@@ -193,6 +199,13 @@ func InsertStatisticsMonthForDate(date time.Time) error {
 		tx.Rollback()
 		return err
 	}
+
+	// 根据月度统计生成用户和组织的账单
+	err = generateInvoices(tx, date)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
 	tx.Commit()
 	logger.SysLog(fmt.Sprintf("Insert statistics month for date %s success", date.Format("2006-01-02")))
 	return nil
@@ -279,6 +292,27 @@ func GetUserInvoices(params *StatisticsMonthSearchParams) (*DataResult[Statistic
 		return &DataResult[StatisticsMonthNoModel]{}, err
 	}
 
+	// 补充账单的结算状态
+	dates := make([]string, 0, len(statistics))
+	for _, statistic := range statistics {
+		statistic.Date = normalizeInvoiceDate(statistic.Date)
+		dates = append(dates, statistic.Date)
+	}
+	invoices, err := getUserInvoicesByDates(params.UserId, dates)
+	if err != nil {
+		logger.SysLog(fmt.Sprintf("Failed to get invoice status for user %d: %v", params.UserId, err))
+	} else {
+		for _, statistic := range statistics {
+			if invoice, ok := invoices[statistic.Date]; ok {
+				statistic.InvoiceId = invoice.Id
+				statistic.InvoiceQuota = invoice.Quota
+				statistic.InvoiceAmount = invoice.Amount
+				statistic.BillingMode = invoice.BillingMode
+				statistic.Status = string(invoice.Status)
+			}
+		}
+	}
+
 	return &DataResult[StatisticsMonthNoModel]{
 		Data:       &statistics,
 		Page:       params.Page,
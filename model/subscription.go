@@ -0,0 +1,598 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/redis"
+	"one-api/common/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SubscriptionPeriod string
+
+const (
+	SubscriptionPeriodMonthly SubscriptionPeriod = "monthly"
+	SubscriptionPeriodYearly  SubscriptionPeriod = "yearly"
+)
+
+type SubscriptionStatus string
+
+const (
+	SubscriptionStatusActive   SubscriptionStatus = "active"   // 生效中
+	SubscriptionStatusPastDue  SubscriptionStatus = "past_due" // 到期未续费，处于宽限期
+	SubscriptionStatusCanceled SubscriptionStatus = "canceled" // 用户取消或更换套餐后结束
+	SubscriptionStatusExpired  SubscriptionStatus = "expired"  // 宽限期内未续费，已降级
+)
+
+// 到期前多久发送续费提醒
+const SubscriptionRemindBefore = 3 * 24 * 3600
+
+// 自动续费的订阅到期后等待网关扣费通知的时间，超时仍未收到通知时进入宽限期
+const SubscriptionAutoRenewWait = 24 * 3600
+
+var (
+	ErrSubscriptionPlanNotFound = errors.New("订阅套餐不存在")
+	ErrSubscriptionNotFound     = errors.New("当前没有生效的订阅")
+	ErrSubscriptionChanged      = errors.New("订阅已被修改，请刷新后重试")
+)
+
+// SubscriptionPlan 订阅套餐
+type SubscriptionPlan struct {
+	Id          int                `json:"id"`
+	Name        string             `json:"name" gorm:"type:varchar(100)"`
+	Description string             `json:"description" gorm:"type:text"`
+	Period      SubscriptionPeriod `json:"period" gorm:"type:varchar(16)"`
+	Price       float64            `json:"price" gorm:"type:decimal(10,2);default:0"` // 每个周期的价格（美元），支付时按网关币种换算
+	Quota       int                `json:"quota" gorm:"default:0"`                    // 每个周期发放的额度
+	Group       string             `json:"group" gorm:"type:varchar(50);default:''"`  // 订阅期间升级到的用户分组，为空则不调整
+	Rollover    bool               `json:"rollover" gorm:"default:false"`             // 周期结束时未用完的额度是否保留，否则作废
+	GraceDays   int                `json:"grace_days" gorm:"default:3"`               // 到期未续费的宽限天数，宽限期结束后降级
+	Sort        int                `json:"sort" gorm:"default:0"`
+	Enable      *bool              `json:"enable" gorm:"default:true"`
+	CreatedTime int64              `json:"created_time" gorm:"bigint"`
+	DeletedAt   gorm.DeletedAt     `json:"-" gorm:"index"`
+}
+
+// Subscription 用户订阅
+type Subscription struct {
+	Id                    int                `json:"id"`
+	UserId                int                `json:"user_id" gorm:"index"`
+	PlanId                int                `json:"plan_id" gorm:"index"`
+	Status                SubscriptionStatus `json:"status" gorm:"type:varchar(16);index"`
+	GatewayId             int                `json:"gateway_id" gorm:"default:0"`
+	GatewaySubscriptionId string             `json:"gateway_subscription_id" gorm:"type:varchar(100);index"` // 网关侧的订阅 ID，不为空表示由网关自动续费
+	CancelAtPeriodEnd     bool               `json:"cancel_at_period_end" gorm:"default:false"`
+	CurrentPeriodStart    int64              `json:"current_period_start" gorm:"bigint"`
+	CurrentPeriodEnd      int64              `json:"current_period_end" gorm:"bigint;index"`
+	GraceEndTime          int64              `json:"grace_end_time" gorm:"bigint;default:0"`
+	CycleQuota            int                `json:"cycle_quota" gorm:"default:0"`           // 本周期发放的额度
+	CycleUsedQuotaStart   int                `json:"-" gorm:"default:0"`                     // 本周期开始时用户的已用额度，用于计算订阅额度的剩余
+	PreviousGroup         string             `json:"previous_group" gorm:"type:varchar(50)"` // 升级前的用户分组，降级时恢复
+	RemindedTime          int64              `json:"reminded_time" gorm:"bigint;default:0"`
+	CreatedTime           int64              `json:"created_time" gorm:"bigint"`
+	UpdatedTime           int64              `json:"updated_time" gorm:"bigint"`
+}
+
+var allowedSubscriptionPlanOrderFields = map[string]bool{
+	"id":    true,
+	"name":  true,
+	"price": true,
+	"sort":  true,
+}
+
+var allowedSubscriptionOrderFields = map[string]bool{
+	"id":                 true,
+	"user_id":            true,
+	"status":             true,
+	"current_period_end": true,
+	"created_time":       true,
+}
+
+func IsValidSubscriptionPeriod(period SubscriptionPeriod) bool {
+	return period == SubscriptionPeriodMonthly || period == SubscriptionPeriodYearly
+}
+
+// AddPeriod 计算从 start 开始一个周期后的时间
+func (p *SubscriptionPlan) AddPeriod(start int64) int64 {
+	t := time.Unix(start, 0)
+	if p.Period == SubscriptionPeriodYearly {
+		return t.AddDate(1, 0, 0).Unix()
+	}
+	return t.AddDate(0, 1, 0).Unix()
+}
+
+func (p *SubscriptionPlan) Validate() error {
+	if p.Name == "" {
+		return errors.New("套餐名称不能为空")
+	}
+	if !IsValidSubscriptionPeriod(p.Period) {
+		return fmt.Errorf("无效的订阅周期: %s", p.Period)
+	}
+	if p.Price <= 0 {
+		return errors.New("套餐价格必须大于 0")
+	}
+	if p.Quota < 0 || p.GraceDays < 0 {
+		return errors.New("额度和宽限天数不能为负数")
+	}
+	if p.Group != "" && GlobalUserGroupRatio.GetBySymbol(p.Group) == nil {
+		return fmt.Errorf("用户分组 %s 不存在", p.Group)
+	}
+	return nil
+}
+
+func GetSubscriptionPlansList(params *GenericParams) (*DataResult[SubscriptionPlan], error) {
+	var plans []*SubscriptionPlan
+	db := DB
+	if params.Keyword != "" {
+		db = db.Where("name LIKE ?", params.Keyword+"%")
+	}
+
+	return PaginateAndOrder(db, &params.PaginationParams, &plans, allowedSubscriptionPlanOrderFields)
+}
+
+// GetEnabledSubscriptionPlans 获取用户可以订阅的套餐
+func GetEnabledSubscriptionPlans() ([]*SubscriptionPlan, error) {
+	var plans []*SubscriptionPlan
+	err := DB.Where("enable = ?", true).Order("sort desc, id asc").Find(&plans).Error
+	return plans, err
+}
+
+// GetSubscriptionPlanById 包含已删除的套餐，已支付的订单和生效中的订阅仍然可以使用
+func GetSubscriptionPlanById(id int) (*SubscriptionPlan, error) {
+	var plan SubscriptionPlan
+	err := DB.Unscoped().Where("id = ?", id).First(&plan).Error
+	if err != nil {
+		return nil, ErrSubscriptionPlanNotFound
+	}
+	return &plan, nil
+}
+
+func (p *SubscriptionPlan) Insert() error {
+	p.CreatedTime = utils.GetTimestamp()
+	return DB.Create(p).Error
+}
+
+func (p *SubscriptionPlan) Update() error {
+	return DB.Select("name", "description", "period", "price", "quota", "group", "rollover", "grace_days", "sort", "enable").Updates(p).Error
+}
+
+func (p *SubscriptionPlan) Delete() error {
+	return DB.Delete(p).Error
+}
+
+type SearchSubscriptionParams struct {
+	UserId int    `form:"user_id"`
+	PlanId int    `form:"plan_id"`
+	Status string `form:"status"`
+	PaginationParams
+}
+
+func GetSubscriptionsList(params *SearchSubscriptionParams) (*DataResult[Subscription], error) {
+	var subscriptions []*Subscription
+	db := DB
+	if params.UserId != 0 {
+		db = db.Where("user_id = ?", params.UserId)
+	}
+	if params.PlanId != 0 {
+		db = db.Where("plan_id = ?", params.PlanId)
+	}
+	if params.Status != "" {
+		db = db.Where("status = ?", params.Status)
+	}
+
+	return PaginateAndOrder(db, &params.PaginationParams, &subscriptions, allowedSubscriptionOrderFields)
+}
+
+// GetUserSubscription 获取用户生效中（包括宽限期内）的订阅
+func GetUserSubscription(userId int) (*Subscription, error) {
+	var subscription Subscription
+	err := DB.Where("user_id = ? AND status IN ?", userId, []SubscriptionStatus{SubscriptionStatusActive, SubscriptionStatusPastDue}).
+		Order("id desc").First(&subscription).Error
+	if err != nil {
+		return nil, ErrSubscriptionNotFound
+	}
+	return &subscription, nil
+}
+
+func GetSubscriptionByGatewayId(gatewaySubscriptionId string) (*Subscription, error) {
+	if gatewaySubscriptionId == "" {
+		return nil, ErrSubscriptionNotFound
+	}
+	var subscription Subscription
+	err := DB.Where("gateway_subscription_id = ?", gatewaySubscriptionId).Order("id desc").First(&subscription).Error
+	if err != nil {
+		return nil, ErrSubscriptionNotFound
+	}
+	return &subscription, nil
+}
+
+// subscriptionVersion 读取订阅时的状态和周期，更新时作为条件，防止并发的回调和定时任务重复处理
+type subscriptionVersion struct {
+	status    SubscriptionStatus
+	periodEnd int64
+}
+
+func (s *Subscription) version() subscriptionVersion {
+	return subscriptionVersion{status: s.Status, periodEnd: s.CurrentPeriodEnd}
+}
+
+// update 只更新指定的列，订阅在读取后已被修改时返回 ErrSubscriptionChanged
+func (s *Subscription) update(version subscriptionVersion, columns ...string) error {
+	s.UpdatedTime = utils.GetTimestamp()
+	result := DB.Model(s).Select(append(columns, "updated_time")).
+		Where("status = ? AND current_period_end = ?", version.status, version.periodEnd).
+		Updates(s)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSubscriptionChanged
+	}
+	return nil
+}
+
+// IsAutoRenew 是否由支付网关自动续费
+func (s *Subscription) IsAutoRenew() bool {
+	return s.GatewaySubscriptionId != "" && !s.CancelAtPeriodEnd
+}
+
+// ActivateSubscription 订阅支付成功后开通或续费，更换套餐时结束原订阅
+func ActivateSubscription(userId, planId, gatewayId int, gatewaySubscriptionId string) (*Subscription, error) {
+	plan, err := GetSubscriptionPlanById(planId)
+	if err != nil {
+		return nil, err
+	}
+
+	current, _ := GetUserSubscription(userId)
+	if current != nil && current.PlanId == plan.Id {
+		if gatewaySubscriptionId == "" {
+			return current, current.renew(plan)
+		}
+		current.GatewayId = gatewayId
+		current.GatewaySubscriptionId = gatewaySubscriptionId
+		current.CancelAtPeriodEnd = false
+		return current, current.renew(plan, "gateway_id", "gateway_subscription_id", "cancel_at_period_end")
+	}
+
+	if current != nil {
+		if err := current.end(SubscriptionStatusCanceled); err != nil {
+			return nil, err
+		}
+	}
+
+	user, err := GetUserById(userId, false)
+	if err != nil {
+		return nil, err
+	}
+
+	now := utils.GetTimestamp()
+	subscription := &Subscription{
+		UserId:                userId,
+		PlanId:                plan.Id,
+		Status:                SubscriptionStatusActive,
+		GatewayId:             gatewayId,
+		GatewaySubscriptionId: gatewaySubscriptionId,
+		CurrentPeriodStart:    now,
+		CurrentPeriodEnd:      plan.AddPeriod(now),
+		CycleQuota:            plan.Quota,
+		CycleUsedQuotaStart:   user.UsedQuota,
+		PreviousGroup:         user.Group,
+		CreatedTime:           now,
+		UpdatedTime:           now,
+	}
+	if err := DB.Create(subscription).Error; err != nil {
+		return nil, err
+	}
+
+	if plan.Group != "" && plan.Group != user.Group {
+		if err := updateSubscriptionUserGroup(userId, plan.Group); err != nil {
+			logger.SysError(fmt.Sprintf("failed to upgrade subscription user group, user_id: %d, error: %s", userId, err.Error()))
+		}
+	}
+
+	if err := IncreaseUserQuota(userId, plan.Quota); err != nil {
+		return nil, err
+	}
+	RecordQuotaLog(userId, LogTypeTopup, plan.Quota, "", fmt.Sprintf("订阅套餐 %s 开通成功，发放额度: %d，有效期至 %s", plan.Name, plan.Quota, formatSubscriptionTime(subscription.CurrentPeriodEnd)))
+
+	return subscription, nil
+}
+
+// RenewSubscriptionByGateway 支付网关自动扣费成功后续费
+func RenewSubscriptionByGateway(gatewaySubscriptionId string) (*Subscription, error) {
+	subscription, err := GetSubscriptionByGatewayId(gatewaySubscriptionId)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.Status != SubscriptionStatusActive && subscription.Status != SubscriptionStatusPastDue {
+		return nil, fmt.Errorf("subscription %d is %s", subscription.Id, subscription.Status)
+	}
+
+	plan, err := GetSubscriptionPlanById(subscription.PlanId)
+	if err != nil {
+		return nil, err
+	}
+
+	return subscription, subscription.renew(plan)
+}
+
+// MarkSubscriptionPastDue 支付网关自动扣费失败，进入宽限期
+func MarkSubscriptionPastDue(gatewaySubscriptionId string) error {
+	subscription, err := GetSubscriptionByGatewayId(gatewaySubscriptionId)
+	if err != nil {
+		return err
+	}
+	if subscription.Status != SubscriptionStatusActive {
+		return nil
+	}
+
+	plan, err := GetSubscriptionPlanById(subscription.PlanId)
+	if err != nil {
+		return err
+	}
+
+	return subscription.startGracePeriod(plan)
+}
+
+// Cancel 取消订阅，当前周期结束后不再续费
+func (s *Subscription) Cancel() error {
+	version := s.version()
+	s.CancelAtPeriodEnd = true
+	if s.Status == SubscriptionStatusPastDue {
+		return s.end(SubscriptionStatusCanceled)
+	}
+	return s.update(version, "cancel_at_period_end")
+}
+
+// CancelSubscriptionByGateway 网关侧的订阅已终止
+func CancelSubscriptionByGateway(gatewaySubscriptionId string) error {
+	subscription, err := GetSubscriptionByGatewayId(gatewaySubscriptionId)
+	if err != nil {
+		return err
+	}
+	if subscription.Status != SubscriptionStatusActive && subscription.Status != SubscriptionStatusPastDue {
+		return nil
+	}
+
+	if subscription.Status == SubscriptionStatusPastDue || subscription.CurrentPeriodEnd <= utils.GetTimestamp() {
+		return subscription.end(SubscriptionStatusCanceled)
+	}
+
+	version := subscription.version()
+	subscription.CancelAtPeriodEnd = true
+	return subscription.update(version, "cancel_at_period_end")
+}
+
+// EndSubscriptionByRefund 订阅订单退款成功后立即结束订阅并恢复用户分组
+// 退款时已扣回 refundQuota，结算本周期额度时不再重复作废这部分额度
+func EndSubscriptionByRefund(userId, planId, refundQuota int) (*Subscription, error) {
+	var subscription Subscription
+	err := DB.Where("user_id = ? AND plan_id = ? AND status IN ?", userId, planId, []SubscriptionStatus{SubscriptionStatusActive, SubscriptionStatusPastDue}).
+		Order("id desc").First(&subscription).Error
+	if err != nil {
+		return nil, ErrSubscriptionNotFound
+	}
+
+	subscription.CycleQuota = max(subscription.CycleQuota-refundQuota, 0)
+	subscription.CancelAtPeriodEnd = true
+	return &subscription, subscription.end(SubscriptionStatusCanceled)
+}
+
+// renew 续费一个周期，columns 为调用方修改的其他列
+// 周期已结束时结算上一周期的额度，新周期从上一周期结束时开始；提前续费时新周期顺延，本周期剩余的额度并入新周期
+func (s *Subscription) renew(plan *SubscriptionPlan, columns ...string) error {
+	user, err := GetUserById(s.UserId, false)
+	if err != nil {
+		return err
+	}
+
+	version := s.version()
+	now := utils.GetTimestamp()
+	cycleQuota := plan.Quota
+	expiredQuota := 0
+	if now >= s.CurrentPeriodEnd {
+		expiredQuota = s.settleCycleQuota(plan, user)
+	} else if !plan.Rollover {
+		cycleQuota += s.remainingCycleQuota(user)
+	}
+
+	s.Status = SubscriptionStatusActive
+	s.CurrentPeriodStart = s.CurrentPeriodEnd
+	s.CurrentPeriodEnd = plan.AddPeriod(s.CurrentPeriodEnd)
+	if s.CurrentPeriodEnd <= now {
+		// 宽限期过长导致顺延后仍已到期，从现在重新开始
+		s.CurrentPeriodStart = now
+		s.CurrentPeriodEnd = plan.AddPeriod(now)
+	}
+	s.GraceEndTime = 0
+	s.CycleQuota = cycleQuota
+	s.CycleUsedQuotaStart = user.UsedQuota
+	columns = append(columns, "status", "current_period_start", "current_period_end", "grace_end_time", "cycle_quota", "cycle_used_quota_start")
+	if err := s.update(version, columns...); err != nil {
+		return err
+	}
+
+	s.expireCycleQuota(plan, expiredQuota)
+
+	if plan.Group != "" && user.Group != plan.Group {
+		if err := updateSubscriptionUserGroup(s.UserId, plan.Group); err != nil {
+			logger.SysError(fmt.Sprintf("failed to upgrade subscription user group, user_id: %d, error: %s", s.UserId, err.Error()))
+		}
+	}
+
+	if err := IncreaseUserQuota(s.UserId, plan.Quota); err != nil {
+		return err
+	}
+	RecordQuotaLog(s.UserId, LogTypeTopup, plan.Quota, "", fmt.Sprintf("订阅套餐 %s 续费成功，发放额度: %d，有效期至 %s", plan.Name, plan.Quota, formatSubscriptionTime(s.CurrentPeriodEnd)))
+
+	return nil
+}
+
+// startGracePeriod 到期未续费，宽限期内保留分组和额度，宽限天数为 0 时直接降级
+func (s *Subscription) startGracePeriod(plan *SubscriptionPlan) error {
+	if plan.GraceDays <= 0 {
+		return s.end(SubscriptionStatusExpired)
+	}
+
+	version := s.version()
+	s.Status = SubscriptionStatusPastDue
+	s.GraceEndTime = s.CurrentPeriodEnd + int64(plan.GraceDays)*24*3600
+	if now := utils.GetTimestamp(); s.GraceEndTime < now {
+		s.GraceEndTime = now + int64(plan.GraceDays)*24*3600
+	}
+	return s.update(version, "status", "grace_end_time")
+}
+
+// end 结束订阅：结算本周期额度并恢复用户分组
+func (s *Subscription) end(status SubscriptionStatus) error {
+	plan, err := GetSubscriptionPlanById(s.PlanId)
+	if err != nil {
+		return err
+	}
+
+	user, err := GetUserById(s.UserId, false)
+	if err != nil {
+		return err
+	}
+
+	version := s.version()
+	expiredQuota := s.settleCycleQuota(plan, user)
+	s.Status = status
+	s.GraceEndTime = 0
+	if err := s.update(version, "status", "grace_end_time", "cycle_quota", "cancel_at_period_end"); err != nil {
+		return err
+	}
+
+	s.expireCycleQuota(plan, expiredQuota)
+
+	if plan.Group != "" && user.Group == plan.Group && s.PreviousGroup != plan.Group {
+		previousGroup := s.PreviousGroup
+		if previousGroup == "" {
+			previousGroup = "default"
+		}
+		if err := updateSubscriptionUserGroup(s.UserId, previousGroup); err != nil {
+			logger.SysError(fmt.Sprintf("failed to downgrade subscription user group, user_id: %d, error: %s", s.UserId, err.Error()))
+		}
+	}
+
+	RecordLog(s.UserId, LogTypeSystem, fmt.Sprintf("订阅套餐 %s 已结束", plan.Name))
+	return nil
+}
+
+// remainingCycleQuota 本周期发放的额度中未使用的部分，优先认为消耗的是订阅额度
+func (s *Subscription) remainingCycleQuota(user *User) int {
+	used := user.UsedQuota - s.CycleUsedQuotaStart
+	if used < 0 {
+		used = 0
+	}
+
+	remaining := s.CycleQuota - used
+	if remaining > user.Quota {
+		remaining = user.Quota
+	}
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining
+}
+
+// settleCycleQuota 结算本周期的额度，返回不允许结转的套餐需要作废的未使用额度
+func (s *Subscription) settleCycleQuota(plan *SubscriptionPlan, user *User) int {
+	if plan.Rollover {
+		return 0
+	}
+
+	remaining := s.remainingCycleQuota(user)
+	s.CycleQuota = 0
+	return remaining
+}
+
+// expireCycleQuota 作废未使用的额度，在订阅更新成功后调用，避免并发处理时重复作废
+func (s *Subscription) expireCycleQuota(plan *SubscriptionPlan, quota int) {
+	if quota <= 0 {
+		return
+	}
+
+	if err := DecreaseUserQuota(s.UserId, quota); err != nil {
+		logger.SysError(fmt.Sprintf("failed to expire subscription quota, subscription_id: %d, error: %s", s.Id, err.Error()))
+		return
+	}
+	RecordQuotaLog(s.UserId, LogTypeSystem, quota, "", fmt.Sprintf("订阅套餐 %s 周期结束，作废未使用的额度: %d", plan.Name, quota))
+}
+
+// ProcessSubscriptions 处理到期的订阅：到期未续费进入宽限期，宽限期结束后降级，已取消的到期后结束
+// 自动续费的订阅由网关通知续费或扣费失败，只有等待超时后才进入宽限期
+func ProcessSubscriptions() {
+	now := utils.GetTimestamp()
+
+	var dueSubscriptions []*Subscription
+	err := DB.Where("status = ? AND current_period_end <= ?", SubscriptionStatusActive, now).Find(&dueSubscriptions).Error
+	if err != nil {
+		logger.SysError("failed to get due subscriptions: " + err.Error())
+		return
+	}
+
+	for _, subscription := range dueSubscriptions {
+		if subscription.IsAutoRenew() && subscription.CurrentPeriodEnd+SubscriptionAutoRenewWait > now {
+			continue
+		}
+
+		if subscription.CancelAtPeriodEnd {
+			err = subscription.end(SubscriptionStatusCanceled)
+		} else {
+			var plan *SubscriptionPlan
+			plan, err = GetSubscriptionPlanById(subscription.PlanId)
+			if err == nil {
+				err = subscription.startGracePeriod(plan)
+			}
+		}
+		if err != nil {
+			logger.SysError(fmt.Sprintf("failed to process due subscription %d: %s", subscription.Id, err.Error()))
+		}
+	}
+
+	var overdueSubscriptions []*Subscription
+	err = DB.Where("status = ? AND grace_end_time <= ?", SubscriptionStatusPastDue, now).Find(&overdueSubscriptions).Error
+	if err != nil {
+		logger.SysError("failed to get overdue subscriptions: " + err.Error())
+		return
+	}
+
+	for _, subscription := range overdueSubscriptions {
+		if err := subscription.end(SubscriptionStatusExpired); err != nil {
+			logger.SysError(fmt.Sprintf("failed to expire subscription %d: %s", subscription.Id, err.Error()))
+		}
+	}
+}
+
+// GetSubscriptionsToRemind 获取即将到期、需要用户手动续费且本周期尚未提醒的订阅
+func GetSubscriptionsToRemind() ([]*Subscription, error) {
+	var subscriptions []*Subscription
+	err := DB.Where("status = ? AND cancel_at_period_end = ? AND gateway_subscription_id = ? AND current_period_end <= ? AND reminded_time < current_period_start",
+		SubscriptionStatusActive, false, "", utils.GetTimestamp()+SubscriptionRemindBefore).Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+func MarkSubscriptionReminded(id int) error {
+	return DB.Model(&Subscription{}).Where("id = ?", id).Update("reminded_time", utils.GetTimestamp()).Error
+}
+
+func updateSubscriptionUserGroup(userId int, group string) error {
+	err := DB.Model(&User{}).Where("id = ?", userId).Update("group", group).Error
+	if err != nil {
+		return err
+	}
+
+	if config.RedisEnabled {
+		redis.RedisDel(fmt.Sprintf(UserGroupCacheKey, userId))
+	}
+	return nil
+}
+
+func formatSubscriptionTime(timestamp int64) string {
+	return time.Unix(timestamp, 0).Format("2006-01-02 15:04:05")
+}
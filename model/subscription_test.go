@@ -0,0 +1,253 @@
+package model
+
+import (
+	"one-api/common/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDay = 24 * 3600
+
+func setupSubscriptionTest(t *testing.T, plan *SubscriptionPlan, user *User) {
+	t.Helper()
+	setupTestDB(t, &SubscriptionPlan{}, &Subscription{})
+
+	require.NoError(t, DB.Create(plan).Error)
+	require.NoError(t, DB.Create(user).Error)
+}
+
+func TestProcessSubscriptions(t *testing.T) {
+	now := utils.GetTimestamp()
+
+	tests := []struct {
+		name         string
+		subscription Subscription
+		wantStatus   SubscriptionStatus
+		wantQuota    int
+		wantGroup    string
+	}{
+		{
+			name:         "active subscription before period end is untouched",
+			subscription: Subscription{Status: SubscriptionStatusActive, CurrentPeriodEnd: now + testDay},
+			wantStatus:   SubscriptionStatusActive,
+			wantQuota:    1000,
+			wantGroup:    "vip",
+		},
+		{
+			name:         "manual renewal enters grace period at period end",
+			subscription: Subscription{Status: SubscriptionStatusActive, CurrentPeriodEnd: now - 60},
+			wantStatus:   SubscriptionStatusPastDue,
+			wantQuota:    1000,
+			wantGroup:    "vip",
+		},
+		{
+			name:         "auto renewal waits for the gateway notification",
+			subscription: Subscription{Status: SubscriptionStatusActive, GatewaySubscriptionId: "sub_1", CurrentPeriodEnd: now - 60},
+			wantStatus:   SubscriptionStatusActive,
+			wantQuota:    1000,
+			wantGroup:    "vip",
+		},
+		{
+			name:         "auto renewal enters grace period when the notification never arrives",
+			subscription: Subscription{Status: SubscriptionStatusActive, GatewaySubscriptionId: "sub_1", CurrentPeriodEnd: now - SubscriptionAutoRenewWait - 60},
+			wantStatus:   SubscriptionStatusPastDue,
+			wantQuota:    1000,
+			wantGroup:    "vip",
+		},
+		{
+			name:         "canceled subscription ends and expires unused quota",
+			subscription: Subscription{Status: SubscriptionStatusActive, GatewaySubscriptionId: "sub_1", CancelAtPeriodEnd: true, CurrentPeriodEnd: now - 60},
+			wantStatus:   SubscriptionStatusCanceled,
+			wantQuota:    600,
+			wantGroup:    "default",
+		},
+		{
+			name:         "grace period over expires the subscription",
+			subscription: Subscription{Status: SubscriptionStatusPastDue, CurrentPeriodEnd: now - 4*testDay, GraceEndTime: now - testDay},
+			wantStatus:   SubscriptionStatusExpired,
+			wantQuota:    600,
+			wantGroup:    "default",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := &SubscriptionPlan{Name: "pro", Period: SubscriptionPeriodMonthly, Price: 10, Quota: 500, Group: "vip", GraceDays: 3}
+			// 本周期发放 500，已使用 100，剩余 400 在结束时作废
+			user := &User{Username: "subscriber", Quota: 1000, UsedQuota: 300, Group: "vip"}
+			setupSubscriptionTest(t, plan, user)
+
+			subscription := tt.subscription
+			subscription.UserId = user.Id
+			subscription.PlanId = plan.Id
+			subscription.CycleQuota = 500
+			subscription.CycleUsedQuotaStart = 200
+			subscription.PreviousGroup = "default"
+			require.NoError(t, DB.Create(&subscription).Error)
+
+			ProcessSubscriptions()
+
+			saved := &Subscription{}
+			require.NoError(t, DB.First(saved, subscription.Id).Error)
+			assert.Equal(t, tt.wantStatus, saved.Status)
+
+			user, err := GetUserById(user.Id, false)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantQuota, user.Quota)
+			assert.Equal(t, tt.wantGroup, user.Group)
+		})
+	}
+}
+
+func TestSubscriptionRenew(t *testing.T) {
+	now := utils.GetTimestamp()
+
+	tests := []struct {
+		name           string
+		rollover       bool
+		periodEnd      int64
+		wantQuota      int
+		wantCycleQuota int
+	}{
+		{
+			name:           "renewal after period end expires unused quota",
+			periodEnd:      now - 60,
+			wantQuota:      1100,
+			wantCycleQuota: 500,
+		},
+		{
+			name:           "early renewal carries unused quota into the new cycle",
+			periodEnd:      now + testDay,
+			wantQuota:      1500,
+			wantCycleQuota: 900,
+		},
+		{
+			name:           "rollover plan keeps unused quota",
+			rollover:       true,
+			periodEnd:      now - 60,
+			wantQuota:      1500,
+			wantCycleQuota: 500,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := &SubscriptionPlan{Name: "pro", Period: SubscriptionPeriodMonthly, Price: 10, Quota: 500, Rollover: tt.rollover, GraceDays: 3}
+			user := &User{Username: "subscriber", Quota: 1000, UsedQuota: 300}
+			setupSubscriptionTest(t, plan, user)
+
+			subscription := &Subscription{
+				UserId:                user.Id,
+				PlanId:                plan.Id,
+				Status:                SubscriptionStatusActive,
+				GatewaySubscriptionId: "sub_1",
+				CurrentPeriodStart:    tt.periodEnd - 30*testDay,
+				CurrentPeriodEnd:      tt.periodEnd,
+				CycleQuota:            500,
+				CycleUsedQuotaStart:   200,
+			}
+			require.NoError(t, DB.Create(subscription).Error)
+
+			renewed, err := RenewSubscriptionByGateway("sub_1")
+			require.NoError(t, err)
+			assert.Equal(t, SubscriptionStatusActive, renewed.Status)
+			assert.Equal(t, tt.wantCycleQuota, renewed.CycleQuota)
+			assert.Greater(t, renewed.CurrentPeriodEnd, now)
+			if tt.periodEnd > now {
+				assert.Equal(t, tt.periodEnd, renewed.CurrentPeriodStart)
+			}
+
+			quota, err := GetUserQuota(user.Id)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantQuota, quota)
+		})
+	}
+}
+
+func TestEndSubscriptionByRefund(t *testing.T) {
+	now := utils.GetTimestamp()
+	plan := &SubscriptionPlan{Name: "pro", Period: SubscriptionPeriodMonthly, Price: 10, Quota: 500, Group: "vip", GraceDays: 3}
+	// 退款已扣回 500 额度，本周期已使用 100
+	user := &User{Username: "subscriber", Quota: 500, UsedQuota: 300, Group: "vip"}
+	setupSubscriptionTest(t, plan, user)
+
+	subscription := &Subscription{
+		UserId:                user.Id,
+		PlanId:                plan.Id,
+		Status:                SubscriptionStatusActive,
+		GatewaySubscriptionId: "sub_1",
+		CurrentPeriodEnd:      now + 10*testDay,
+		CycleQuota:            500,
+		CycleUsedQuotaStart:   200,
+		PreviousGroup:         "default",
+	}
+	require.NoError(t, DB.Create(subscription).Error)
+
+	ended, err := EndSubscriptionByRefund(user.Id, plan.Id, 500)
+	require.NoError(t, err)
+	assert.Equal(t, "sub_1", ended.GatewaySubscriptionId)
+
+	saved := &Subscription{}
+	require.NoError(t, DB.First(saved, subscription.Id).Error)
+	assert.Equal(t, SubscriptionStatusCanceled, saved.Status)
+	assert.False(t, saved.IsAutoRenew())
+
+	user, err = GetUserById(user.Id, false)
+	require.NoError(t, err)
+	assert.Equal(t, 500, user.Quota, "refunded quota must not be expired twice")
+	assert.Equal(t, "default", user.Group)
+
+	_, err = EndSubscriptionByRefund(user.Id, plan.Id, 500)
+	assert.ErrorIs(t, err, ErrSubscriptionNotFound)
+}
+
+func TestSubscriptionConcurrentUpdate(t *testing.T) {
+	now := utils.GetTimestamp()
+	plan := &SubscriptionPlan{Name: "pro", Period: SubscriptionPeriodMonthly, Price: 10, Quota: 500, GraceDays: 3}
+	user := &User{Username: "subscriber", Quota: 1000, UsedQuota: 300}
+	setupSubscriptionTest(t, plan, user)
+
+	subscription := &Subscription{
+		UserId:                user.Id,
+		PlanId:                plan.Id,
+		Status:                SubscriptionStatusActive,
+		GatewaySubscriptionId: "sub_1",
+		CurrentPeriodStart:    now - 30*testDay,
+		CurrentPeriodEnd:      now - 60,
+		CycleQuota:            500,
+		CycleUsedQuotaStart:   200,
+	}
+	require.NoError(t, DB.Create(subscription).Error)
+
+	// 定时任务和网关回调读取到同一份订阅
+	stale, err := GetSubscriptionByGatewayId("sub_1")
+	require.NoError(t, err)
+
+	_, err = RenewSubscriptionByGateway("sub_1")
+	require.NoError(t, err)
+	quota, err := GetUserQuota(user.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 1100, quota)
+
+	// 已续费的订阅不能再被旧数据结束，额度不会重复作废
+	assert.ErrorIs(t, stale.end(SubscriptionStatusExpired), ErrSubscriptionChanged)
+	quota, err = GetUserQuota(user.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 1100, quota)
+
+	saved := &Subscription{}
+	require.NoError(t, DB.First(saved, subscription.Id).Error)
+	assert.Equal(t, SubscriptionStatusActive, saved.Status)
+
+	// 只更新修改的列，不覆盖其他请求写入的字段
+	current, err := GetSubscriptionByGatewayId("sub_1")
+	require.NoError(t, err)
+	require.NoError(t, MarkSubscriptionReminded(current.Id))
+	require.NoError(t, current.Cancel())
+
+	require.NoError(t, DB.First(saved, subscription.Id).Error)
+	assert.True(t, saved.CancelAtPeriodEnd)
+	assert.NotZero(t, saved.RemindedTime)
+}
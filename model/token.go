@@ -26,6 +26,7 @@ var (
 type Token struct {
 	Id             int            `json:"id"`
 	UserId         int            `json:"user_id"`
+	OrganizationId int            `json:"organization_id" gorm:"index;default:0"` // 组织令牌从组织额度池扣费，成员离开后转给组织所有者并禁用
 	Key            string         `json:"key" gorm:"type:varchar(59);uniqueIndex"`
 	Status         int            `json:"status" gorm:"default:1"`
 	Name           string         `json:"name" gorm:"index" `
@@ -66,6 +67,8 @@ func (token *Token) AfterCreate(tx *gorm.DB) (err error) {
 type TokenSetting struct {
 	Heartbeat  HeartbeatSetting `json:"heartbeat,omitempty"`
 	Limits     LimitsConfig     `json:"limits,omitempty"`
+	Cache      ChatCacheSetting `json:"cache,omitempty"`
+	Scopes     TokenScopes      `json:"scopes,omitempty"`
 	BillingTag *string          `json:"billing_tag,omitempty"` // 费用标签，用于按分组统计费用，仅可信内部员工和管理员可见
 }
 
@@ -74,9 +77,17 @@ type HeartbeatSetting struct {
 	TimeoutSeconds int  `json:"timeout_seconds"`
 }
 
+type ChatCacheSetting struct {
+	Enabled bool `json:"enabled"`
+	TTL     int  `json:"ttl"` // 缓存有效期（秒），0 则使用分组或系统默认值
+}
+
 type LimitsConfig struct {
 	LimitModelSetting LimitModelSetting `json:"limit_model_setting,omitempty"`
 	LimitsIPSetting   LimitsIPSetting   `json:"limits_ip_setting,omitempty"`
+	BudgetSetting     BudgetSetting     `json:"budget_setting,omitempty"`
+	TPM               int               `json:"tpm,omitempty"`         // 令牌每分钟允许的 token 数，0 则不限制
+	QueueClass        string            `json:"queue_class,omitempty"` // 渠道繁忙排队时的优先级，不能高于用户分组的优先级
 }
 
 type LimitModelSetting struct {
@@ -100,6 +111,37 @@ func GetUserTokensList(userId int, params *GenericParams) (*DataResult[Token], e
 	return PaginateAndOrder(db, &params.PaginationParams, &tokens, allowedTokenOrderFields)
 }
 
+// GetOrganizationTokensList 获取组织的所有令牌，不区分创建成员
+func GetOrganizationTokensList(organizationId int, params *GenericParams) (*DataResult[Token], error) {
+	var tokens []*Token
+	db := DB.Where("organization_id = ?", organizationId)
+
+	if params.Keyword != "" {
+		db = db.Where("name LIKE ?", params.Keyword+"%")
+	}
+
+	return PaginateAndOrder(db, &params.PaginationParams, &tokens, allowedTokenOrderFields)
+}
+
+// DeleteOrganizationToken 组织管理员删除组织令牌
+func DeleteOrganizationToken(organizationId int, id int) error {
+	if organizationId == 0 || id == 0 {
+		return errors.New("id 或 organizationId 为空！")
+	}
+	token := Token{}
+	err := DB.Where("id = ? AND organization_id = ?", id, organizationId).First(&token).Error
+	if err != nil {
+		return err
+	}
+	err = token.Delete()
+
+	if err == nil && config.RedisEnabled {
+		redis.RedisDel(fmt.Sprintf(UserTokensKey, token.Key))
+	}
+
+	return err
+}
+
 func GetTokenModel(key string) (token *Token, err error) {
 	if key == "" {
 		return nil, ErrTokenInvalid
@@ -123,8 +165,9 @@ func GetTokenModel(key string) (token *Token, err error) {
 		if err != nil || userId == 0 || tokenId == 0 {
 			return nil, ErrTokenInvalid
 		}
+		// 组织令牌在成员离开后会转给组织所有者，此时以令牌当前所属用户为准
 		if userEnabled, err := CacheIsUserEnabled(userId); err != nil || !userEnabled {
-			return nil, ErrTokenInvalid
+			validUser = true
 		}
 	default:
 		return nil, ErrTokenInvalid
@@ -330,6 +373,12 @@ func PreConsumeTokenQuota(tokenId int, quota int) (err error) {
 	if err != nil {
 		return err
 	}
+	// 后付费用户可以透支到授信额度
+	creditLimit, err := CacheGetUserCreditLimit(token.UserId)
+	if err != nil {
+		return err
+	}
+	userQuota += creditLimit
 	if userQuota < quota {
 		return errors.New("用户额度不足")
 	}
@@ -0,0 +1,259 @@
+package model
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common/utils"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// 令牌可以调用的接口范围
+const (
+	TokenScopeChat        = "chat"
+	TokenScopeEmbeddings  = "embeddings"
+	TokenScopeImages      = "images"
+	TokenScopeAudio       = "audio"
+	TokenScopeRealtime    = "realtime"
+	TokenScopeFiles       = "files"
+	TokenScopeModerations = "moderations"
+	TokenScopeRerank      = "rerank"
+	TokenScopeMidjourney  = "mj"
+	TokenScopeSuno        = "suno"
+	TokenScopeKling       = "kling"
+	TokenScopePassthrough = "passthrough" // fine_tuning、assistants 等直接透传到上游的接口
+)
+
+// 令牌范围校验失败时返回的错误码
+const (
+	TokenScopeErrEndpoint  = "endpoint_not_allowed"
+	TokenScopeErrModel     = "model_not_allowed"
+	TokenScopeErrChannel   = "channel_not_allowed"
+	TokenScopeErrNoChannel = "no_channel_in_scope"
+	TokenScopeErrReadOnly  = "management_read_only"
+)
+
+var tokenEndpointScopes = []string{
+	TokenScopeChat,
+	TokenScopeEmbeddings,
+	TokenScopeImages,
+	TokenScopeAudio,
+	TokenScopeRealtime,
+	TokenScopeFiles,
+	TokenScopeModerations,
+	TokenScopeRerank,
+	TokenScopeMidjourney,
+	TokenScopeSuno,
+	TokenScopeKling,
+	TokenScopePassthrough,
+}
+
+// 按顺序匹配请求路径前缀
+var tokenEndpointPrefixes = []struct {
+	prefix string
+	scope  string
+}{
+	{"/v1/chat/completions", TokenScopeChat},
+	{"/v1/completions", TokenScopeChat},
+	{"/v1/responses", TokenScopeChat},
+	{"/claude/v1/messages", TokenScopeChat},
+	{"/v1/embeddings", TokenScopeEmbeddings},
+	{"/v1/rerank", TokenScopeRerank},
+	{"/v1/moderations", TokenScopeModerations},
+	{"/v1/images/", TokenScopeImages},
+	{"/recraftAI/", TokenScopeImages},
+	{"/v1/audio/", TokenScopeAudio},
+	{"/v1/realtime", TokenScopeRealtime},
+	{"/v1/files", TokenScopeFiles},
+	{"/v1/batches", TokenScopeFiles},
+	{"/suno/", TokenScopeSuno},
+	{"/kling/", TokenScopeKling},
+}
+
+// TokenScopes 令牌的使用范围，列表为空表示不限制
+type TokenScopes struct {
+	Endpoints []string `json:"endpoints,omitempty"` // 允许调用的接口范围
+	// 允许使用的模型，支持 * 和 ? 通配符，以 / 包围时作为正则表达式，如 /^gpt-4o(-mini)?$/
+	Models             []string `json:"models,omitempty"`
+	Channels           []int    `json:"channels,omitempty"`             // 只使用这些渠道
+	ChannelTags        []string `json:"channel_tags,omitempty"`         // 只使用这些标签的渠道
+	ExcludeChannels    []int    `json:"exclude_channels,omitempty"`     // 不使用这些渠道
+	ExcludeChannelTags []string `json:"exclude_channel_tags,omitempty"` // 不使用这些标签的渠道
+	ManagementReadOnly bool     `json:"management_read_only,omitempty"` // 令牌可以调用用户的只读管理接口
+}
+
+// TokenScopeError 令牌范围校验失败
+type TokenScopeError struct {
+	Code       string
+	Message    string
+	StatusCode int
+}
+
+func (e *TokenScopeError) Error() string {
+	return e.Message
+}
+
+func newTokenScopeError(code string, statusCode int, format string, args ...any) *TokenScopeError {
+	return &TokenScopeError{
+		Code:       code,
+		Message:    fmt.Sprintf(format, args...),
+		StatusCode: statusCode,
+	}
+}
+
+// GetEndpointScope 获取请求路径所属的接口范围，模型列表等查询接口返回空字符串，不受限制
+func GetEndpointScope(method, path string) string {
+	for _, item := range tokenEndpointPrefixes {
+		if strings.HasPrefix(path, item.prefix) {
+			return item.scope
+		}
+	}
+
+	switch {
+	case strings.Contains(path, "/mj/"): // /mj 和 /:mode/mj
+		return TokenScopeMidjourney
+	case strings.HasPrefix(path, "/gemini/"):
+		if strings.Contains(strings.ToLower(path), "embedcontent") {
+			return TokenScopeEmbeddings
+		}
+		if strings.Contains(path, "/models/") {
+			return TokenScopeChat
+		}
+		return ""
+	case method == http.MethodGet && (strings.HasPrefix(path, "/v1/models") || strings.HasPrefix(path, "/claude/v1/models")):
+		return ""
+	}
+
+	return TokenScopePassthrough
+}
+
+// CheckEndpoint 检查令牌是否可以调用该接口
+func (s *TokenScopes) CheckEndpoint(method, path string) *TokenScopeError {
+	if s == nil || len(s.Endpoints) == 0 {
+		return nil
+	}
+
+	scope := GetEndpointScope(method, path)
+	if scope == "" || utils.Contains(scope, s.Endpoints) {
+		return nil
+	}
+
+	return newTokenScopeError(TokenScopeErrEndpoint, http.StatusForbidden, "current token is not allowed to access %s endpoints", scope)
+}
+
+// CheckModel 检查令牌是否可以使用该模型
+func (s *TokenScopes) CheckModel(modelName string) *TokenScopeError {
+	if s == nil || len(s.Models) == 0 {
+		return nil
+	}
+
+	for _, pattern := range s.Models {
+		if matchModelPattern(pattern, modelName) {
+			return nil
+		}
+	}
+
+	return newTokenScopeError(TokenScopeErrModel, http.StatusForbidden, "model %s is not allowed for current token", modelName)
+}
+
+// HasChannelScope 是否限制了可用渠道
+func (s *TokenScopes) HasChannelScope() bool {
+	return s != nil && (len(s.Channels) > 0 || len(s.ChannelTags) > 0 || len(s.ExcludeChannels) > 0 || len(s.ExcludeChannelTags) > 0)
+}
+
+// AllowChannel 检查令牌是否可以使用该渠道，排除规则优先
+func (s *TokenScopes) AllowChannel(channel *Channel) bool {
+	if s == nil || channel == nil {
+		return true
+	}
+
+	if utils.Contains(channel.Id, s.ExcludeChannels) {
+		return false
+	}
+	if channel.Tag != "" && utils.Contains(channel.Tag, s.ExcludeChannelTags) {
+		return false
+	}
+
+	if len(s.Channels) == 0 && len(s.ChannelTags) == 0 {
+		return true
+	}
+
+	return utils.Contains(channel.Id, s.Channels) || (channel.Tag != "" && utils.Contains(channel.Tag, s.ChannelTags))
+}
+
+// CheckChannel 检查指定的渠道是否在令牌范围内
+func (s *TokenScopes) CheckChannel(channel *Channel) *TokenScopeError {
+	if s.AllowChannel(channel) {
+		return nil
+	}
+
+	return newTokenScopeError(TokenScopeErrChannel, http.StatusForbidden, "channel %d is not allowed for current token", channel.Id)
+}
+
+// NoChannelError 令牌限制了渠道范围且范围内没有可用渠道
+func (s *TokenScopes) NoChannelError(err error) *TokenScopeError {
+	return newTokenScopeError(TokenScopeErrNoChannel, http.StatusServiceUnavailable, "%s (limited by token channel scope)", err.Error())
+}
+
+// Validate 检查范围配置是否有效
+func (s *TokenScopes) Validate() error {
+	for _, endpoint := range s.Endpoints {
+		if !utils.Contains(endpoint, tokenEndpointScopes) {
+			return fmt.Errorf("invalid endpoint scope: %s", endpoint)
+		}
+	}
+
+	for _, pattern := range s.Models {
+		if _, err := compileModelPattern(pattern); err != nil {
+			return fmt.Errorf("invalid model pattern %s: %s", pattern, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// FilterTokenScope 过滤令牌范围外的渠道
+func FilterTokenScope(scopes *TokenScopes) ChannelsFilterFunc {
+	return func(_ int, choice *ChannelChoice) bool {
+		return !scopes.AllowChannel(choice.Channel)
+	}
+}
+
+var modelPatternCache sync.Map // pattern -> *regexp.Regexp
+
+func compileModelPattern(pattern string) (*regexp.Regexp, error) {
+	if cached, ok := modelPatternCache.Load(pattern); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+
+	var expr string
+	if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		expr = pattern[1 : len(pattern)-1]
+	} else {
+		expr = regexp.QuoteMeta(pattern)
+		expr = strings.ReplaceAll(expr, `\*`, ".*")
+		expr = strings.ReplaceAll(expr, `\?`, ".")
+		expr = "^" + expr + "$"
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	modelPatternCache.Store(pattern, re)
+	return re, nil
+}
+
+func matchModelPattern(pattern, modelName string) bool {
+	if pattern == modelName {
+		return true
+	}
+
+	re, err := compileModelPattern(pattern)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(modelName)
+}
@@ -0,0 +1,141 @@
+package model
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenScopesCheckEndpoint(t *testing.T) {
+	scopes := &TokenScopes{Endpoints: []string{TokenScopeChat, TokenScopeFiles}}
+
+	tests := []struct {
+		name     string
+		scopes   *TokenScopes
+		method   string
+		path     string
+		wantCode string
+	}{
+		{name: "no endpoint scope allows everything", scopes: &TokenScopes{}, method: http.MethodPost, path: "/v1/embeddings"},
+		{name: "nil scopes allow everything", method: http.MethodPost, path: "/v1/embeddings"},
+		{name: "chat completions in scope", scopes: scopes, method: http.MethodPost, path: "/v1/chat/completions"},
+		{name: "claude messages count as chat", scopes: scopes, method: http.MethodPost, path: "/claude/v1/messages"},
+		{name: "gemini generate content counts as chat", scopes: scopes, method: http.MethodPost, path: "/gemini/v1beta/models/gemini-pro:generateContent"},
+		{name: "batches count as files", scopes: scopes, method: http.MethodPost, path: "/v1/batches"},
+		{name: "model list is not restricted", scopes: scopes, method: http.MethodGet, path: "/v1/models"},
+		{name: "embeddings out of scope", scopes: scopes, method: http.MethodPost, path: "/v1/embeddings", wantCode: TokenScopeErrEndpoint},
+		{name: "gemini embed content is embeddings", scopes: scopes, method: http.MethodPost, path: "/gemini/v1beta/models/text-embedding-004:embedContent", wantCode: TokenScopeErrEndpoint},
+		{name: "midjourney out of scope", scopes: scopes, method: http.MethodPost, path: "/fast/mj/submit/imagine", wantCode: TokenScopeErrEndpoint},
+		{name: "passthrough endpoints out of scope", scopes: scopes, method: http.MethodPost, path: "/v1/fine_tuning/jobs", wantCode: TokenScopeErrEndpoint},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.scopes.CheckEndpoint(tt.method, tt.path)
+			if tt.wantCode == "" {
+				assert.Nil(t, err)
+				return
+			}
+			if assert.NotNil(t, err) {
+				assert.Equal(t, tt.wantCode, err.Code)
+				assert.Equal(t, http.StatusForbidden, err.StatusCode)
+			}
+		})
+	}
+}
+
+func TestTokenScopesCheckModel(t *testing.T) {
+	tests := []struct {
+		name      string
+		models    []string
+		modelName string
+		want      bool
+	}{
+		{name: "no model scope allows everything", modelName: "gpt-4o", want: true},
+		{name: "exact match", models: []string{"gpt-4o"}, modelName: "gpt-4o", want: true},
+		{name: "exact match does not match prefixes", models: []string{"gpt-4o"}, modelName: "gpt-4o-mini", want: false},
+		{name: "star wildcard", models: []string{"claude-*"}, modelName: "claude-3-5-sonnet", want: true},
+		{name: "question mark wildcard", models: []string{"gpt-?o"}, modelName: "gpt-4o", want: true},
+		{name: "dots are literal in wildcards", models: []string{"gpt-3.5*"}, modelName: "gpt-305-turbo", want: false},
+		{name: "regular expression", models: []string{"/^gpt-4o(-mini)?$/"}, modelName: "gpt-4o-mini", want: true},
+		{name: "regular expression mismatch", models: []string{"/^gpt-4o(-mini)?$/"}, modelName: "gpt-4o-audio", want: false},
+		{name: "any pattern matches", models: []string{"claude-*", "gpt-4o"}, modelName: "gpt-4o", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&TokenScopes{Models: tt.models}).CheckModel(tt.modelName)
+			if tt.want {
+				assert.Nil(t, err)
+			} else if assert.NotNil(t, err) {
+				assert.Equal(t, TokenScopeErrModel, err.Code)
+			}
+		})
+	}
+}
+
+func TestTokenScopesChannelFilter(t *testing.T) {
+	channels := []*Channel{
+		{Id: 1, Tag: "azure"},
+		{Id: 2, Tag: "azure"},
+		{Id: 3, Tag: "openai"},
+		{Id: 4},
+	}
+
+	tests := []struct {
+		name   string
+		scopes *TokenScopes
+		want   []int
+	}{
+		{name: "no channel scope allows every channel", scopes: &TokenScopes{}, want: []int{1, 2, 3, 4}},
+		{name: "channel ids", scopes: &TokenScopes{Channels: []int{1, 4}}, want: []int{1, 4}},
+		{name: "channel tags", scopes: &TokenScopes{ChannelTags: []string{"azure"}}, want: []int{1, 2}},
+		{name: "channel ids and tags are combined", scopes: &TokenScopes{Channels: []int{4}, ChannelTags: []string{"openai"}}, want: []int{3, 4}},
+		{name: "excluded channels", scopes: &TokenScopes{ExcludeChannels: []int{2}}, want: []int{1, 3, 4}},
+		{name: "excluded tags", scopes: &TokenScopes{ExcludeChannelTags: []string{"azure"}}, want: []int{3, 4}},
+		{name: "exclusion wins over inclusion", scopes: &TokenScopes{ChannelTags: []string{"azure"}, ExcludeChannels: []int{1}}, want: []int{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := FilterTokenScope(tt.scopes)
+
+			allowed := make([]int, 0)
+			for _, channel := range channels {
+				choice := &ChannelChoice{Channel: channel}
+				if !filter(channel.Id, choice) {
+					allowed = append(allowed, channel.Id)
+					assert.Nil(t, tt.scopes.CheckChannel(channel))
+				} else if err := tt.scopes.CheckChannel(channel); assert.NotNil(t, err) {
+					assert.Equal(t, TokenScopeErrChannel, err.Code)
+				}
+			}
+			assert.Equal(t, tt.want, allowed)
+			assert.Equal(t, len(tt.want) < len(channels), tt.scopes.HasChannelScope())
+		})
+	}
+}
+
+func TestTokenScopesValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		scopes  *TokenScopes
+		wantErr bool
+	}{
+		{name: "valid scopes", scopes: &TokenScopes{Endpoints: []string{TokenScopeChat}, Models: []string{"gpt-*", "/^o[134]/"}}},
+		{name: "unknown endpoint", scopes: &TokenScopes{Endpoints: []string{"video"}}, wantErr: true},
+		{name: "invalid regular expression", scopes: &TokenScopes{Models: []string{"/gpt-(/"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.scopes.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/go-webauthn/webauthn/webauthn"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
@@ -47,6 +48,11 @@ type User struct {
 	LastLoginIp      string         `json:"last_login_ip" gorm:"type:varchar(128);default:''"`
 	CreatedTime      int64          `json:"created_time" gorm:"bigint"`
 	DeletedAt        gorm.DeletedAt `json:"-" gorm:"index"`
+
+	Budget *datatypes.JSONType[BudgetSetting] `json:"budget,omitempty" gorm:"type:json"` // 用户预算，由管理员设置
+
+	BillingMode string `json:"billing_mode" gorm:"type:varchar(16);default:'prepaid'"` // 计费模式，由管理员设置
+	CreditLimit int    `json:"credit_limit" gorm:"type:int;default:0"`                 // 后付费用户允许透支的额度
 }
 
 type UserUpdates func(*User)
@@ -171,7 +177,21 @@ func (user *User) Update(updatePassword bool) error {
 		omitFields = append(omitFields, "password")
 	}
 
+	var previousMode string
+	if user.BillingMode != "" {
+		DB.Model(&User{}).Where("id = ?", user.Id).Select("billing_mode").Scan(&previousMode)
+	}
+
 	err = DB.Model(user).Omit(omitFields...).Updates(user).Error
+	// 授信额度可以设置为 0，只在管理员设置计费模式时更新
+	if err == nil && user.BillingMode != "" {
+		err = DB.Model(user).Update("credit_limit", user.CreditLimit).Error
+		if err == nil {
+			if err := recordBillingModeChange(DB, user.Id, 0, previousMode, user.BillingMode); err != nil {
+				logger.SysError(fmt.Sprintf("failed to record billing mode change, user_id: %d, error: %s", user.Id, err.Error()))
+			}
+		}
+	}
 
 	if err == nil && user.Role == config.RoleRootUser {
 		config.RootUserEmail = user.Email
@@ -180,6 +200,8 @@ func (user *User) Update(updatePassword bool) error {
 	// 删除缓存
 	if config.RedisEnabled {
 		redis.RedisDel(fmt.Sprintf(UserGroupCacheKey, user.Id))
+		redis.RedisDel(fmt.Sprintf(UserBudgetCacheKey, user.Id))
+		redis.RedisDel(fmt.Sprintf(UserCreditLimitCacheKey, user.Id))
 	}
 
 	return err
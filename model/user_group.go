@@ -7,6 +7,8 @@ import (
 	"one-api/common/logger"
 	"one-api/common/redis"
 	"sync"
+
+	"gorm.io/datatypes"
 )
 
 type UserGroup struct {
@@ -15,11 +17,32 @@ type UserGroup struct {
 	Name      string  `json:"name" gorm:"type:varchar(50)"`
 	Ratio     float64 `json:"ratio" gorm:"type:decimal(10,2); default:1"`      // 倍率
 	APIRate   int     `json:"api_rate" gorm:"default:600"`                     // 每分组允许的请求数
+	TPM       int     `json:"tpm" gorm:"default:0"`                            // 每分组每分钟允许的 token 数，0 则不限制
 	Public    bool    `json:"public" form:"public" gorm:"default:false"`       // 是否为公开分组，如果是，则可以被用户在令牌中选择
 	Promotion bool    `json:"promotion" form:"promotion" gorm:"default:false"` // 是否是自动升级用户组， 如果是则用户充值金额满足条件自动升级
 	Min       int     `json:"min" form:"min" gorm:"default:0"`                 // 晋级条件最小值
 	Max       int     `json:"max" form:"max" gorm:"default:0"`                 // 晋级条件最大值
 	Enable    *bool   `json:"enable" form:"enable" gorm:"default:true"`        // 是否启用
+	CacheTTL  int     `json:"cache_ttl" form:"cache_ttl" gorm:"default:0"`     // 对话缓存有效期（秒），0 则使用系统默认值
+
+	QueueClass string `json:"queue_class" gorm:"type:varchar(16);default:''"` // 渠道繁忙排队时的优先级：high、normal、low，为空则为 normal
+
+	RoutingStrategy string                                 `json:"routing_strategy" gorm:"type:varchar(32);default:''"` // 渠道路由策略，为空则按权重随机
+	RoutingModels   *datatypes.JSONType[map[string]string] `json:"routing_models" gorm:"type:json"`                     // 按模型单独设置的路由策略
+
+	FirstTokenTimeout       int                                 `json:"first_token_timeout" gorm:"default:0"`        // 流式请求首字超时（毫秒），超时后向其他渠道发起对冲请求，0 则不启用
+	FirstTokenTimeoutModels *datatypes.JSONType[map[string]int] `json:"first_token_timeout_models" gorm:"type:json"` // 按模型单独设置的首字超时
+
+	OutputModeration string `json:"output_moderation" gorm:"type:varchar(16);default:''"` // 模型输出内容审核：block 截断、replace 替换，为空则不审核
+}
+
+const (
+	OutputModerationBlock   = "block"
+	OutputModerationReplace = "replace"
+)
+
+func IsValidOutputModeration(action string) bool {
+	return action == "" || action == OutputModerationBlock || action == OutputModerationReplace
 }
 
 type SearchUserGroupParams struct {
@@ -75,7 +98,7 @@ func (c *UserGroup) Create() error {
 }
 
 func (c *UserGroup) Update() error {
-	err := DB.Select("name", "ratio", "public", "api_rate", "promotion", "min", "max").Updates(c).Error
+	err := DB.Select("name", "ratio", "public", "api_rate", "tpm", "promotion", "min", "max", "cache_ttl", "queue_class", "routing_strategy", "routing_models", "first_token_timeout", "first_token_timeout_models", "output_moderation").Updates(c).Error
 	if err == nil {
 		GlobalUserGroupRatio.Load()
 	}
@@ -104,6 +127,7 @@ type UserGroupRatio struct {
 	sync.RWMutex
 	UserGroup   map[string]*UserGroup
 	APILimiter  map[string]limit.RateLimiter
+	TPMLimiter  map[string]*limit.TPMLimiter
 	PublicGroup []string
 }
 
@@ -117,11 +141,15 @@ func (cgrm *UserGroupRatio) Load() {
 
 	newUserGroups := make(map[string]*UserGroup, len(userGroups))
 	newAPILimiter := make(map[string]limit.RateLimiter, len(userGroups))
+	newTPMLimiter := make(map[string]*limit.TPMLimiter)
 	publicGroup := make([]string, 0)
 
 	for _, userGroup := range userGroups {
 		newUserGroups[userGroup.Symbol] = userGroup
 		newAPILimiter[userGroup.Symbol] = limit.NewAPILimiter(userGroup.APIRate)
+		if userGroup.TPM > 0 {
+			newTPMLimiter[userGroup.Symbol] = limit.NewTPMLimiter(userGroup.TPM)
+		}
 		if userGroup.Public {
 			publicGroup = append(publicGroup, userGroup.Symbol)
 		}
@@ -132,6 +160,7 @@ func (cgrm *UserGroupRatio) Load() {
 
 	cgrm.UserGroup = newUserGroups
 	cgrm.APILimiter = newAPILimiter
+	cgrm.TPMLimiter = newTPMLimiter
 	cgrm.PublicGroup = publicGroup
 }
 
@@ -159,6 +188,89 @@ func (cgrm *UserGroupRatio) GetByTokenUserGroup(tokenGroup, userGroup string) *U
 	return cgrm.GetBySymbol(userGroup)
 }
 
+// GetRoutingStrategy 获取分组下模型的路由策略，模型单独设置优先
+func (cgrm *UserGroupRatio) GetRoutingStrategy(symbol, modelName string) string {
+	userGroup := cgrm.GetBySymbol(symbol)
+	if userGroup == nil {
+		return ""
+	}
+
+	if userGroup.RoutingModels != nil {
+		if strategy, ok := userGroup.RoutingModels.Data()[modelName]; ok && strategy != "" {
+			return strategy
+		}
+	}
+
+	return userGroup.RoutingStrategy
+}
+
+// GetFirstTokenTimeout 获取分组下模型的首字超时（毫秒），模型单独设置优先
+func (cgrm *UserGroupRatio) GetFirstTokenTimeout(symbol, modelName string) int {
+	userGroup := cgrm.GetBySymbol(symbol)
+	if userGroup == nil {
+		return 0
+	}
+
+	if userGroup.FirstTokenTimeoutModels != nil {
+		if timeout, ok := userGroup.FirstTokenTimeoutModels.Data()[modelName]; ok {
+			return timeout
+		}
+	}
+
+	return userGroup.FirstTokenTimeout
+}
+
+// GetOutputModeration 获取分组的输出内容审核方式
+func (cgrm *UserGroupRatio) GetOutputModeration(symbol string) string {
+	userGroup := cgrm.GetBySymbol(symbol)
+	if userGroup == nil {
+		return ""
+	}
+
+	return userGroup.OutputModeration
+}
+
+// Validate 检查路由策略、排队优先级、首字超时、TPM 和输出审核配置是否有效
+func (c *UserGroup) Validate() error {
+	if !IsValidRoutingStrategy(c.RoutingStrategy) {
+		return fmt.Errorf("无效的路由策略: %s", c.RoutingStrategy)
+	}
+
+	if !IsValidQueueClass(c.QueueClass) {
+		return fmt.Errorf("无效的排队优先级: %s", c.QueueClass)
+	}
+
+	if !IsValidOutputModeration(c.OutputModeration) {
+		return fmt.Errorf("无效的输出审核方式: %s", c.OutputModeration)
+	}
+
+	if c.TPM < 0 {
+		return fmt.Errorf("TPM 不能小于 0")
+	}
+
+	if c.FirstTokenTimeout < 0 {
+		return fmt.Errorf("首字超时不能小于 0")
+	}
+
+	if c.RoutingModels != nil {
+		for modelName, strategy := range c.RoutingModels.Data() {
+			if !IsValidRoutingStrategy(strategy) {
+				return fmt.Errorf("模型 %s 的路由策略无效: %s", modelName, strategy)
+			}
+		}
+	}
+
+	if c.FirstTokenTimeoutModels != nil {
+		for modelName, timeout := range c.FirstTokenTimeoutModels.Data() {
+			if timeout < 0 {
+				return fmt.Errorf("模型 %s 的首字超时不能小于 0", modelName)
+			}
+		}
+	}
+
+	return nil
+}
+
 func (cgrm *UserGroupRatio) GetAll() map[string]*UserGroup {
 	cgrm.RLock()
 	defer cgrm.RUnlock()
@@ -194,6 +306,24 @@ func (cgrm *UserGroupRatio) GetAPILimiter(symbol string) limit.RateLimiter {
 	return limiter
 }
 
+// GetQueueClass 获取分组的排队优先级
+func (cgrm *UserGroupRatio) GetQueueClass(symbol string) string {
+	userGroup := cgrm.GetBySymbol(symbol)
+	if userGroup == nil {
+		return ""
+	}
+
+	return userGroup.QueueClass
+}
+
+// GetTPMLimiter 获取分组的 TPM 限流器，未设置 TPM 时返回 nil
+func (cgrm *UserGroupRatio) GetTPMLimiter(symbol string) *limit.TPMLimiter {
+	cgrm.RLock()
+	defer cgrm.RUnlock()
+
+	return cgrm.TPMLimiter[symbol]
+}
+
 // CheckAndUpgradeUserGroup checks if a user's cumulative recharge amount falls within any promotion group's range
 // and upgrades the user to that group if a match is found.
 // The cumulative recharge amount is calculated as Quota + UsedQuota + rechargeAmount.
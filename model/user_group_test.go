@@ -0,0 +1,49 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/datatypes"
+)
+
+func TestUserGroupValidate(t *testing.T) {
+	routingModels := datatypes.NewJSONType(map[string]string{"gpt-4o": "unknown"})
+	timeoutModels := datatypes.NewJSONType(map[string]int{"gpt-4o": -1})
+
+	tests := []struct {
+		name    string
+		group   UserGroup
+		wantErr bool
+	}{
+		{name: "empty settings are valid", group: UserGroup{}},
+		{
+			name: "valid settings",
+			group: UserGroup{
+				RoutingStrategy:   RoutingStrategyLatency,
+				QueueClass:        QueueClassHigh,
+				OutputModeration:  OutputModerationBlock,
+				TPM:               1000,
+				FirstTokenTimeout: 3000,
+			},
+		},
+		{name: "unknown routing strategy", group: UserGroup{RoutingStrategy: "unknown"}, wantErr: true},
+		{name: "unknown queue class", group: UserGroup{QueueClass: "urgent"}, wantErr: true},
+		{name: "unknown output moderation", group: UserGroup{OutputModeration: "drop"}, wantErr: true},
+		{name: "negative tpm", group: UserGroup{TPM: -1}, wantErr: true},
+		{name: "negative first token timeout", group: UserGroup{FirstTokenTimeout: -1}, wantErr: true},
+		{name: "unknown model routing strategy", group: UserGroup{RoutingModels: &routingModels}, wantErr: true},
+		{name: "negative model first token timeout", group: UserGroup{FirstTokenTimeoutModels: &timeoutModels}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.group.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -1,11 +1,13 @@
 package alipay
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"one-api/model"
 	"one-api/payment/types"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/smartwalle/alipay/v3"
@@ -82,6 +84,18 @@ func (a *Alipay) HandleCallback(c *gin.Context, gatewayConfig string) (*types.Pa
 		return nil, fmt.Errorf("Alipay Error decoding notification: %v", err)
 	}
 
+	// 部分退款后会推送带有退款信息的交易通知，退款结果已在同步接口中确认
+	if noti.OutBizNo != "" && noti.RefundFee != "" {
+		payNotify := &types.PayNotify{
+			Event:     types.PayNotifyEventRefunded,
+			TradeNo:   noti.OutTradeNo,
+			GatewayNo: noti.TradeNo,
+			RefundNo:  noti.OutBizNo,
+		}
+		alipay.ACKNotification(c.Writer)
+		return payNotify, nil
+	}
+
 	if noti.TradeStatus == alipay.TradeStatusSuccess {
 		payNotify := &types.PayNotify{
 			TradeNo:   noti.OutTradeNo,
@@ -94,6 +108,44 @@ func (a *Alipay) HandleCallback(c *gin.Context, gatewayConfig string) (*types.Pa
 	return nil, fmt.Errorf("trade status not success")
 }
 
+// Refund 支付宝退款为同步接口，受理成功即退款成功
+func (a *Alipay) Refund(config *types.RefundConfig, gatewayConfig string) (*types.RefundResult, error) {
+	alipayConfig, err := getAlipayConfig(gatewayConfig)
+	if err != nil {
+		return types.RefundRejected(err.Error()), nil
+	}
+
+	if client == nil {
+		err := a.InitClient(alipayConfig)
+		if err != nil {
+			return types.RefundRejected(err.Error()), nil
+		}
+	}
+
+	p := alipay.TradeRefund{
+		OutTradeNo:   config.TradeNo,
+		RefundAmount: strconv.FormatFloat(config.Money, 'f', 2, 64),
+		RefundReason: config.Reason,
+		OutRequestNo: config.RefundNo,
+	}
+	alipayRes, err := client.TradeRefund(context.Background(), p)
+	if err != nil {
+		return nil, fmt.Errorf("alipay trade refund failed: %s", err.Error())
+	}
+	if !alipayRes.IsSuccess() {
+		// 服务不可用时退款结果未知，使用相同的退款单号重试不会重复退款
+		if alipayRes.Code == alipay.CodeUnknowError {
+			return nil, fmt.Errorf("alipay trade refund unknown: %s %s", alipayRes.Msg, alipayRes.SubMsg)
+		}
+		return types.RefundRejected(fmt.Sprintf("alipay trade refund failed: %s %s", alipayRes.Msg, alipayRes.SubMsg)), nil
+	}
+
+	return &types.RefundResult{
+		GatewayRefundNo: alipayRes.TradeNo,
+		Status:          model.RefundStatusSuccess,
+	}, nil
+}
+
 func getAlipayConfig(gatewayConfig string) (*AlipayConfig, error) {
 	var alipayConfig AlipayConfig
 	if err := json.Unmarshal([]byte(gatewayConfig), &alipayConfig); err != nil {
@@ -2,11 +2,14 @@ package stripe
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
+	"net/http"
 	"one-api/model"
 	"one-api/payment/types"
 	"strconv"
+	"strings"
 
 	sysconfig "one-api/common/config"
 
@@ -87,8 +90,171 @@ func (e *Stripe) Pay(config *types.PayConfig, gatewayConfig string) (*types.PayR
 	return payRequest, nil
 }
 
+// Subscribe 创建自动续费的订阅
+func (e *Stripe) Subscribe(config *types.PayConfig, gatewayConfig string) (*types.PayRequest, error) {
+	var stripeConfig StripeConfig
+	err := json.Unmarshal([]byte(gatewayConfig), &stripeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	sc := &client.API{}
+	sc.Init(stripeConfig.SecretKey, nil)
+	currency := stripe.String("USD")
+	if config.Currency == "CNY" {
+		currency = stripe.String("CNY")
+	}
+
+	interval := stripe.String(string(stripe.PriceRecurringIntervalMonth))
+	if config.Subscription.Period == model.SubscriptionPeriodYearly {
+		interval = stripe.String(string(stripe.PriceRecurringIntervalYear))
+	}
+
+	metadata := map[string]string{
+		"user_id":  fmt.Sprintf("%d", config.User.Id),
+		"plan_id":  fmt.Sprintf("%d", config.Subscription.PlanId),
+		"trade_no": config.TradeNo,
+	}
+
+	params := &stripe.CheckoutSessionParams{
+		Mode:              stripe.String(string(stripe.CheckoutSessionModeSubscription)),
+		SuccessURL:        stripe.String(config.ReturnURL),
+		ClientReferenceID: stripe.String(config.TradeNo),
+		LineItems: []*stripe.CheckoutSessionLineItemParams{
+			{
+				PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
+					Currency: currency,
+					ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
+						Name: stripe.String(sysconfig.SystemName + "-订阅:" + config.Subscription.PlanName),
+					},
+					Recurring: &stripe.CheckoutSessionLineItemPriceDataRecurringParams{
+						Interval: interval,
+					},
+					UnitAmount: stripe.Int64(int64(math.Round(config.Money * 100))),
+				},
+				Quantity: stripe.Int64(1),
+			},
+		},
+		SubscriptionData: &stripe.CheckoutSessionSubscriptionDataParams{
+			Metadata: metadata,
+		},
+		Metadata: metadata,
+	}
+
+	if config.User.Email != "" {
+		params.CustomerEmail = stripe.String(config.User.Email)
+	}
+
+	result, err := sc.CheckoutSessions.New(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.PayRequest{
+		Type: 1,
+		Data: types.PayRequestData{
+			URL: result.URL,
+			Params: map[string]interface{}{
+				"tradeNo": config.TradeNo,
+				"linkId":  result.ID,
+			},
+		},
+	}, nil
+}
+
+// CancelSubscription 在当前周期结束时取消订阅
+func (e *Stripe) CancelSubscription(subscriptionId string, gatewayConfig string) error {
+	var stripeConfig StripeConfig
+	err := json.Unmarshal([]byte(gatewayConfig), &stripeConfig)
+	if err != nil {
+		return err
+	}
+
+	sc := &client.API{}
+	sc.Init(stripeConfig.SecretKey, nil)
+	_, err = sc.Subscriptions.Update(subscriptionId, &stripe.SubscriptionParams{
+		CancelAtPeriodEnd: stripe.Bool(true),
+	})
+	return err
+}
+
+// Refund 原路退款，订阅订单记录的是账单号，需要先查出对应的 PaymentIntent
+func (e *Stripe) Refund(config *types.RefundConfig, gatewayConfig string) (*types.RefundResult, error) {
+	var stripeConfig StripeConfig
+	err := json.Unmarshal([]byte(gatewayConfig), &stripeConfig)
+	if err != nil {
+		return types.RefundRejected(err.Error()), nil
+	}
+
+	sc := &client.API{}
+	sc.Init(stripeConfig.SecretKey, nil)
+
+	paymentIntent := config.GatewayNo
+	if strings.HasPrefix(paymentIntent, "in_") {
+		invoice, err := sc.Invoices.Get(paymentIntent, nil)
+		if err != nil {
+			return refundError(err)
+		}
+		if invoice.PaymentIntent == nil {
+			return types.RefundRejected(fmt.Sprintf("invoice %s has no payment intent", paymentIntent)), nil
+		}
+		paymentIntent = invoice.PaymentIntent.ID
+	}
+
+	params := &stripe.RefundParams{
+		PaymentIntent: stripe.String(paymentIntent),
+		Amount:        stripe.Int64(int64(math.Round(config.Money * 100))),
+	}
+	params.AddMetadata("trade_no", config.TradeNo)
+	params.AddMetadata("refund_no", config.RefundNo)
+	if config.Reason != "" {
+		params.AddMetadata("reason", config.Reason)
+	}
+
+	refund, err := sc.Refunds.New(params)
+	if err != nil {
+		return refundError(err)
+	}
+
+	return &types.RefundResult{
+		GatewayRefundNo: refund.ID,
+		Status:          convertRefundStatus(refund.Status),
+		Message:         string(refund.FailureReason),
+	}, nil
+}
+
+// refundError 4xx 为请求被拒绝，其余错误（网络异常、服务端错误、限流）退款结果未知
+func refundError(err error) (*types.RefundResult, error) {
+	var stripeErr *stripe.Error
+	if errors.As(err, &stripeErr) && stripeErr.HTTPStatusCode >= http.StatusBadRequest &&
+		stripeErr.HTTPStatusCode < http.StatusInternalServerError && stripeErr.HTTPStatusCode != http.StatusTooManyRequests {
+		return types.RefundRejected(stripeErr.Msg), nil
+	}
+
+	return nil, err
+}
+
+func convertRefundStatus(status stripe.RefundStatus) model.RefundStatus {
+	switch status {
+	case stripe.RefundStatusSucceeded:
+		return model.RefundStatusSuccess
+	case stripe.RefundStatusFailed, stripe.RefundStatusCanceled:
+		return model.RefundStatusFailed
+	default:
+		return model.RefundStatusPending
+	}
+}
+
+// webhook 需要订阅的事件
+var webhookEvents = []string{
+	"checkout.session.completed",
+	"invoice.paid",
+	"invoice.payment_failed",
+	"customer.subscription.deleted",
+	"charge.refund.updated",
+}
+
 func (e *Stripe) CreatedPay(notifyURL string, gatewayConfig *model.Payment) error {
-	eventName := "checkout.session.completed"
 	var stripeConfig StripeConfig
 	err := json.Unmarshal([]byte(gatewayConfig.Config), &stripeConfig)
 	if err != nil {
@@ -103,7 +269,7 @@ func (e *Stripe) CreatedPay(notifyURL string, gatewayConfig *model.Payment) erro
 	var existingWebhook *stripe.WebhookEndpoint
 	for i.Next() {
 		webhook := i.WebhookEndpoint()
-		if webhook.URL == notifyURL && contains(webhook.EnabledEvents, eventName) {
+		if webhook.URL == notifyURL && contains(webhook.EnabledEvents, webhookEvents[0]) {
 			existingWebhook = webhook
 			break
 		}
@@ -117,11 +283,9 @@ func (e *Stripe) CreatedPay(notifyURL string, gatewayConfig *model.Payment) erro
 
 	if existingWebhook == nil {
 		createParams := &stripe.WebhookEndpointParams{
-			URL: stripe.String(notifyURL),
-			EnabledEvents: []*string{
-				stripe.String(eventName),
-			},
-			APIVersion: stripe.String("2024-09-30.acacia"),
+			URL:           stripe.String(notifyURL),
+			EnabledEvents: stripe.StringSlice(webhookEvents),
+			APIVersion:    stripe.String("2024-09-30.acacia"),
 		}
 		newWebhook, err := webhookendpoint.New(createParams)
 		if err != nil {
@@ -132,6 +296,19 @@ func (e *Stripe) CreatedPay(notifyURL string, gatewayConfig *model.Payment) erro
 	} else {
 		fmt.Printf("Webhook already exists: %s\n", existingWebhook.ID)
 		wh = existingWebhook
+		// 旧版本创建的 Webhook 只订阅了支付完成事件，补充订阅相关的事件
+		for _, eventName := range webhookEvents {
+			if contains(existingWebhook.EnabledEvents, eventName) {
+				continue
+			}
+			_, err := webhookendpoint.Update(existingWebhook.ID, &stripe.WebhookEndpointParams{
+				EnabledEvents: stripe.StringSlice(webhookEvents),
+			})
+			if err != nil {
+				return fmt.Errorf("error updating webhook: %v", err)
+			}
+			break
+		}
 	}
 
 	stripeConfig.WebhookSecret = wh.Secret
@@ -194,8 +371,85 @@ func (e *Stripe) HandleCallback(c *gin.Context, gatewayConfig string) (*types.Pa
 
 		// 构造 PayNotify
 		payNotify := &types.PayNotify{
-			TradeNo:   orderID,
-			GatewayNo: session.PaymentIntent.ID,
+			TradeNo: orderID,
+		}
+
+		// 订阅模式没有 PaymentIntent，使用首期账单号
+		switch {
+		case session.PaymentIntent != nil:
+			payNotify.GatewayNo = session.PaymentIntent.ID
+		case session.Invoice != nil:
+			payNotify.GatewayNo = session.Invoice.ID
+		default:
+			payNotify.GatewayNo = session.ID
+		}
+
+		if session.Subscription != nil {
+			payNotify.SubscriptionId = session.Subscription.ID
+		}
+
+		return payNotify, nil
+	case "invoice.paid", "invoice.payment_failed":
+		var invoice stripe.Invoice
+		err := json.Unmarshal(event.Data.Raw, &invoice)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse invoice data: %v", err)
+		}
+
+		// 首期账单由 checkout.session.completed 处理
+		if invoice.Subscription == nil || invoice.BillingReason == stripe.InvoiceBillingReasonSubscriptionCreate {
+			return nil, nil
+		}
+
+		payNotify := &types.PayNotify{
+			Event:          types.PayNotifyEventSubscriptionRenewed,
+			GatewayNo:      invoice.ID,
+			SubscriptionId: invoice.Subscription.ID,
+			Money:          float64(invoice.AmountPaid) / 100,
+			Currency:       model.CurrencyType(strings.ToUpper(string(invoice.Currency))),
+		}
+		if event.Type == "invoice.payment_failed" {
+			payNotify.Event = types.PayNotifyEventSubscriptionPaymentFailed
+		}
+
+		return payNotify, nil
+	case "customer.subscription.deleted":
+		var subscription stripe.Subscription
+		err := json.Unmarshal(event.Data.Raw, &subscription)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse subscription data: %v", err)
+		}
+
+		return &types.PayNotify{
+			Event:          types.PayNotifyEventSubscriptionCanceled,
+			GatewayNo:      subscription.ID,
+			SubscriptionId: subscription.ID,
+		}, nil
+	case "charge.refund.updated":
+		var refund stripe.Refund
+		err := json.Unmarshal(event.Data.Raw, &refund)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse refund data: %v", err)
+		}
+
+		// 只处理通过本系统发起的退款
+		refundNo := refund.Metadata["refund_no"]
+		if refundNo == "" {
+			return nil, nil
+		}
+
+		payNotify := &types.PayNotify{
+			TradeNo:   refund.Metadata["trade_no"],
+			GatewayNo: refund.ID,
+			RefundNo:  refundNo,
+		}
+		switch convertRefundStatus(refund.Status) {
+		case model.RefundStatusSuccess:
+			payNotify.Event = types.PayNotifyEventRefunded
+		case model.RefundStatusFailed:
+			payNotify.Event = types.PayNotifyEventRefundFailed
+		default:
+			return nil, nil
 		}
 
 		return payNotify, nil
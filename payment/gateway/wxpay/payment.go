@@ -6,9 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"one-api/model"
 	"one-api/payment/types"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/wechatpay-apiv3/wechatpay-go/core"
@@ -17,6 +19,7 @@ import (
 	"github.com/wechatpay-apiv3/wechatpay-go/core/notify"
 	"github.com/wechatpay-apiv3/wechatpay-go/core/option"
 	"github.com/wechatpay-apiv3/wechatpay-go/services/payments"
+	"github.com/wechatpay-apiv3/wechatpay-go/services/refunddomestic"
 	"github.com/wechatpay-apiv3/wechatpay-go/utils"
 )
 
@@ -87,8 +90,8 @@ func (w *WeChatPay) HandleCallback(c *gin.Context, gatewayConfig string) (*types
 	}
 	certificateVisitor := downloader.MgrInstance().GetCertificateVisitor(wxpayConfig.MchID)
 	handler := notify.NewNotifyHandler(wxpayConfig.MchAPIv3Key, verifiers.NewSHA256WithRSAVerifier(certificateVisitor))
-	transaction := new(payments.Transaction)
-	notifyReq, err := handler.ParseNotifyRequest(context.Background(), c.Request, transaction)
+	content := make(map[string]interface{})
+	notifyReq, err := handler.ParseNotifyRequest(context.Background(), c.Request, &content)
 	// 如果验签未通过，或者解密失败
 	if err != nil {
 		// 接收失败，返回4XX或5XX状态码以及应答报文
@@ -98,6 +101,17 @@ func (w *WeChatPay) HandleCallback(c *gin.Context, gatewayConfig string) (*types
 		})
 		return nil, fmt.Errorf("WeChat Signature verification failed: %v", err)
 	}
+	if strings.HasPrefix(notifyReq.EventType, "REFUND.") {
+		return handleRefundNotify(c, notifyReq)
+	}
+	transaction := new(payments.Transaction)
+	if err := json.Unmarshal([]byte(notifyReq.Resource.Plaintext), transaction); err != nil {
+		c.JSON(http.StatusBadRequest, NotifyResponse{
+			Code:    "FAIL",
+			Message: err.Error(),
+		})
+		return nil, fmt.Errorf("WeChat transaction decode failed: %v", err)
+	}
 	if notifyReq.EventType != "TRANSACTION.SUCCESS" {
 		c.Status(http.StatusNoContent)
 		return nil, fmt.Errorf("WeChat Transaction failed: %v", notifyReq.EventType)
@@ -116,6 +130,95 @@ func (w *WeChatPay) HandleCallback(c *gin.Context, gatewayConfig string) (*types
 
 }
 
+// handleRefundNotify 处理退款结果通知
+func handleRefundNotify(c *gin.Context, notifyReq *notify.Request) (*types.PayNotify, error) {
+	refundNotify := new(RefundNotify)
+	if err := json.Unmarshal([]byte(notifyReq.Resource.Plaintext), refundNotify); err != nil {
+		c.JSON(http.StatusBadRequest, NotifyResponse{
+			Code:    "FAIL",
+			Message: err.Error(),
+		})
+		return nil, fmt.Errorf("WeChat refund decode failed: %v", err)
+	}
+
+	payNotify := &types.PayNotify{
+		Event:     types.PayNotifyEventRefunded,
+		TradeNo:   refundNotify.OutTradeNo,
+		GatewayNo: refundNotify.RefundId,
+		RefundNo:  refundNotify.OutRefundNo,
+	}
+	if notifyReq.EventType != "REFUND.SUCCESS" {
+		payNotify.Event = types.PayNotifyEventRefundFailed
+	}
+	c.Status(http.StatusNoContent)
+	return payNotify, nil
+}
+
+// Refund 申请退款，退款结果以 PROCESSING 返回时等待回调通知
+func (w *WeChatPay) Refund(config *types.RefundConfig, gatewayConfig string) (*types.RefundResult, error) {
+	wechatConfig, err := getWeChatConfig(gatewayConfig)
+	if err != nil {
+		return types.RefundRejected(err.Error()), nil
+	}
+
+	if client == nil {
+		err := w.InitClient(wechatConfig)
+		if err != nil {
+			return types.RefundRejected(err.Error()), nil
+		}
+	}
+
+	req := refunddomestic.CreateRequest{
+		OutTradeNo:  core.String(config.TradeNo),
+		OutRefundNo: core.String(config.RefundNo),
+		NotifyUrl:   core.String(config.NotifyURL),
+		Amount: &refunddomestic.AmountReq{
+			Refund:   core.Int64(int64(math.Round(config.Money * 100))), // 转换为分
+			Total:    core.Int64(int64(math.Round(config.Total * 100))),
+			Currency: core.String("CNY"),
+		},
+	}
+	if config.Reason != "" {
+		req.Reason = core.String(config.Reason)
+	}
+
+	refundService := refunddomestic.RefundsApiService{Client: client}
+	resp, _, err := refundService.Create(context.Background(), req)
+	if err != nil {
+		// 4xx 为请求被拒绝，其余错误（网络异常、系统错误、限频）退款结果未知
+		var apiErr *core.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode >= http.StatusBadRequest &&
+			apiErr.StatusCode < http.StatusInternalServerError && apiErr.StatusCode != http.StatusTooManyRequests {
+			return types.RefundRejected(fmt.Sprintf("wechat refund failed: %s %s", apiErr.Code, apiErr.Message)), nil
+		}
+		return nil, fmt.Errorf("wechat refund unknown: %s", err.Error())
+	}
+
+	// 响应缺少字段时按处理中记录，等待退款回调确认结果
+	result := &types.RefundResult{
+		Status: model.RefundStatusPending,
+	}
+	if resp == nil {
+		return result, nil
+	}
+	if resp.RefundId != nil {
+		result.GatewayRefundNo = *resp.RefundId
+	}
+	if resp.Status == nil {
+		return result, nil
+	}
+
+	switch *resp.Status {
+	case refunddomestic.STATUS_SUCCESS:
+		result.Status = model.RefundStatusSuccess
+	case refunddomestic.STATUS_CLOSED, refunddomestic.STATUS_ABNORMAL:
+		result.Status = model.RefundStatusFailed
+		result.Message = fmt.Sprintf("wechat refund status: %s", *resp.Status)
+	}
+
+	return result, nil
+}
+
 func getWeChatConfig(gatewayConfig string) (*WeChatConfig, error) {
 	var wechatConfig WeChatConfig
 	if err := json.Unmarshal([]byte(gatewayConfig), &wechatConfig); err != nil {
@@ -10,3 +10,12 @@ type NotifyResponse struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
 }
+
+// 退款结果通知解密后的内容
+type RefundNotify struct {
+	OutTradeNo    string `json:"out_trade_no"`
+	TransactionId string `json:"transaction_id"`
+	OutRefundNo   string `json:"out_refund_no"`
+	RefundId      string `json:"refund_id"`
+	RefundStatus  string `json:"refund_status"`
+}
@@ -18,6 +18,18 @@ type PaymentProcessor interface {
 	HandleCallback(c *gin.Context, gatewayConfig string) (*types.PayNotify, error)
 }
 
+// SubscriptionProcessor 支持自动续费的支付网关
+type SubscriptionProcessor interface {
+	Subscribe(config *types.PayConfig, gatewayConfig string) (*types.PayRequest, error)
+	CancelSubscription(subscriptionId string, gatewayConfig string) error
+}
+
+// RefundProcessor 支持原路退款的支付网关
+// 网关明确拒绝时返回状态为 failed 的结果，返回 error 表示退款结果未知，需等待回调确认
+type RefundProcessor interface {
+	Refund(config *types.RefundConfig, gatewayConfig string) (*types.RefundResult, error)
+}
+
 var Gateways = make(map[string]PaymentProcessor)
 
 func init() {
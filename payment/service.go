@@ -61,6 +61,73 @@ func (s *PaymentService) Pay(tradeNo string, amount float64, user *model.User) (
 	return payRequest, nil
 }
 
+// SupportSubscription 网关是否支持自动续费
+func (s *PaymentService) SupportSubscription() bool {
+	_, ok := s.gateway.(SubscriptionProcessor)
+	return ok
+}
+
+// Subscribe 订阅套餐，网关支持自动续费时创建网关侧的订阅，否则按一次性支付处理
+func (s *PaymentService) Subscribe(tradeNo string, amount float64, user *model.User, plan *model.SubscriptionPlan) (*types.PayRequest, error) {
+	processor, ok := s.gateway.(SubscriptionProcessor)
+	if !ok {
+		return s.Pay(tradeNo, amount, user)
+	}
+
+	config := &types.PayConfig{
+		Money:     amount,
+		TradeNo:   tradeNo,
+		NotifyURL: s.getNotifyURL(),
+		ReturnURL: s.getReturnURL(),
+		Currency:  s.Payment.Currency,
+		User:      user,
+		Subscription: &types.SubscriptionConfig{
+			PlanId:   plan.Id,
+			PlanName: plan.Name,
+			Period:   plan.Period,
+		},
+	}
+
+	return processor.Subscribe(config, s.Payment.Config)
+}
+
+// CancelSubscription 取消网关侧的订阅，当前周期结束后不再扣费
+func (s *PaymentService) CancelSubscription(subscriptionId string) error {
+	processor, ok := s.gateway.(SubscriptionProcessor)
+	if !ok {
+		return errors.New("payment gateway does not support subscription")
+	}
+
+	return processor.CancelSubscription(subscriptionId, s.Payment.Config)
+}
+
+// SupportRefund 网关是否支持原路退款
+func (s *PaymentService) SupportRefund() bool {
+	_, ok := s.gateway.(RefundProcessor)
+	return ok
+}
+
+// Refund 按订单的支付币种原路退款
+func (s *PaymentService) Refund(order *model.Order, refund *model.OrderRefund) (*types.RefundResult, error) {
+	processor, ok := s.gateway.(RefundProcessor)
+	if !ok {
+		return nil, errors.New("payment gateway does not support refund")
+	}
+
+	config := &types.RefundConfig{
+		NotifyURL: s.getNotifyURL(),
+		TradeNo:   order.TradeNo,
+		GatewayNo: order.GatewayNo,
+		RefundNo:  refund.RefundNo,
+		Money:     refund.Amount,
+		Total:     order.OrderAmount,
+		Currency:  order.OrderCurrency,
+		Reason:    refund.Reason,
+	}
+
+	return processor.Refund(config, s.Payment.Config)
+}
+
 func (s *PaymentService) HandleCallback(c *gin.Context, gatewayConfig string) (*types.PayNotify, error) {
 	payNotify, err := s.gateway.HandleCallback(c, gatewayConfig)
 	if err != nil {
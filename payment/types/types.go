@@ -10,6 +10,15 @@ type PayConfig struct {
 	Money     float64            `json:"money"`
 	Currency  model.CurrencyType `json:"currency"`
 	User      *model.User        `json:"user"`
+	// 订阅套餐信息，为空表示一次性支付
+	Subscription *SubscriptionConfig `json:"subscription,omitempty"`
+}
+
+// 订阅支付时的套餐信息
+type SubscriptionConfig struct {
+	PlanId   int                      `json:"plan_id"`
+	PlanName string                   `json:"plan_name"`
+	Period   model.SubscriptionPeriod `json:"period"`
 }
 
 // 请求支付时的数据结构
@@ -24,8 +33,52 @@ type PayRequestData struct {
 	Params any    `json:"params,omitempty"`
 }
 
+type PayNotifyEvent string
+
+const (
+	PayNotifyEventPaid                      PayNotifyEvent = ""                            // 订单支付成功
+	PayNotifyEventSubscriptionRenewed       PayNotifyEvent = "subscription_renewed"        // 订阅自动续费成功
+	PayNotifyEventSubscriptionPaymentFailed PayNotifyEvent = "subscription_payment_failed" // 订阅自动续费失败
+	PayNotifyEventSubscriptionCanceled      PayNotifyEvent = "subscription_canceled"       // 订阅已在网关侧终止
+	PayNotifyEventRefunded                  PayNotifyEvent = "refunded"                    // 退款成功
+	PayNotifyEventRefundFailed              PayNotifyEvent = "refund_failed"               // 退款失败
+)
+
 // 支付回调时的数据结构
 type PayNotify struct {
-	TradeNo   string `json:"trade_no"`
-	GatewayNo string `json:"gateway_no"`
+	Event          PayNotifyEvent `json:"event"`
+	TradeNo        string         `json:"trade_no"`
+	GatewayNo      string         `json:"gateway_no"`
+	SubscriptionId string         `json:"subscription_id"` // 网关侧的订阅 ID
+	RefundNo       string         `json:"refund_no"`       // 商户退款单号
+	// 网关实际扣款的金额和币种，用于记录自动续费的订单，币种为空表示网关未返回
+	Money    float64            `json:"money"`
+	Currency model.CurrencyType `json:"currency"`
+}
+
+// 退款请求的通用配置
+type RefundConfig struct {
+	NotifyURL string             `json:"notify_url"`
+	TradeNo   string             `json:"trade_no"`
+	GatewayNo string             `json:"gateway_no"`
+	RefundNo  string             `json:"refund_no"`
+	Money     float64            `json:"money"` // 本次退款金额
+	Total     float64            `json:"total"` // 订单支付金额
+	Currency  model.CurrencyType `json:"currency"`
+	Reason    string             `json:"reason"`
+}
+
+// 网关受理退款后的结果，处理中的退款通过回调通知最终结果
+type RefundResult struct {
+	GatewayRefundNo string             `json:"gateway_refund_no"`
+	Status          model.RefundStatus `json:"status"`
+	Message         string             `json:"message"`
+}
+
+// RefundRejected 退款请求未发出或被网关明确拒绝
+func RefundRejected(message string) *RefundResult {
+	return &RefundResult{
+		Status:  model.RefundStatusFailed,
+		Message: message,
+	}
 }
@@ -51,6 +51,7 @@ func getConfig() base.ProviderConfig {
 	return base.ProviderConfig{
 		BaseURL:         "https://bedrock-runtime.%s.amazonaws.com",
 		ChatCompletions: "/model/%s/invoke",
+		Embeddings:      "/model/%s/invoke",
 	}
 }
 
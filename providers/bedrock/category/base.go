@@ -35,7 +35,9 @@ var regionPrefixes = []string{"global.", "us.", "eu.", "apac."}
 var CategoryMap = map[string]Category{}
 
 type Category struct {
-	ModelName                 string
+	ModelName string
+	// 使用 Converse API 而不是 InvokeModel
+	Converse                  bool
 	ChatComplete              ChatCompletionConvert
 	ResponseChatComplete      ChatCompletionResponse
 	ResponseChatCompleteStrem ChatCompletionStreamResponse
@@ -48,6 +50,8 @@ func GetCategory(modelName string) (*Category, error) {
 
 	if strings.Contains(modelName, "anthropic") {
 		provider = "anthropic"
+	} else if isConverseModel(modelName) {
+		provider = "converse"
 	}
 
 	if category, exists := CategoryMap[provider]; exists {
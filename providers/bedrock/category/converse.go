@@ -0,0 +1,513 @@
+package category
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/common/image"
+	"one-api/common/requester"
+	"one-api/common/utils"
+	"one-api/providers/base"
+	"one-api/types"
+	"strings"
+)
+
+// 通过 Converse API 调用的模型，包括 Llama、Mistral、Nova、Cohere Command 和 DeepSeek
+var converseModels = []string{"meta.", "mistral.", "amazon.nova", "cohere.command", "deepseek."}
+
+type ConverseRequest struct {
+	Messages        []ConverseMessage        `json:"messages"`
+	System          []ConverseContent        `json:"system,omitempty"`
+	InferenceConfig *ConverseInferenceConfig `json:"inferenceConfig,omitempty"`
+	ToolConfig      *ConverseToolConfig      `json:"toolConfig,omitempty"`
+}
+
+type ConverseMessage struct {
+	Role    string            `json:"role"`
+	Content []ConverseContent `json:"content"`
+}
+
+type ConverseContent struct {
+	Text             string                    `json:"text,omitempty"`
+	Image            *ConverseImage            `json:"image,omitempty"`
+	Document         *ConverseDocument         `json:"document,omitempty"`
+	ToolUse          *ConverseToolUse          `json:"toolUse,omitempty"`
+	ToolResult       *ConverseToolResult       `json:"toolResult,omitempty"`
+	ReasoningContent *ConverseReasoningContent `json:"reasoningContent,omitempty"`
+}
+
+type ConverseSource struct {
+	Bytes string `json:"bytes"`
+}
+
+type ConverseImage struct {
+	Format string         `json:"format"`
+	Source ConverseSource `json:"source"`
+}
+
+type ConverseDocument struct {
+	Format string         `json:"format"`
+	Name   string         `json:"name"`
+	Source ConverseSource `json:"source"`
+}
+
+type ConverseToolUse struct {
+	ToolUseId string `json:"toolUseId"`
+	Name      string `json:"name"`
+	Input     any    `json:"input"`
+}
+
+type ConverseToolResult struct {
+	ToolUseId string            `json:"toolUseId"`
+	Content   []ConverseContent `json:"content"`
+}
+
+type ConverseReasoningContent struct {
+	ReasoningText *ConverseReasoningText `json:"reasoningText,omitempty"`
+}
+
+type ConverseReasoningText struct {
+	Text      string `json:"text"`
+	Signature string `json:"signature,omitempty"`
+}
+
+type ConverseInferenceConfig struct {
+	MaxTokens     int      `json:"maxTokens,omitempty"`
+	Temperature   *float64 `json:"temperature,omitempty"`
+	TopP          *float64 `json:"topP,omitempty"`
+	StopSequences []string `json:"stopSequences,omitempty"`
+}
+
+type ConverseToolConfig struct {
+	Tools      []ConverseTool `json:"tools"`
+	ToolChoice map[string]any `json:"toolChoice,omitempty"`
+}
+
+type ConverseTool struct {
+	ToolSpec ConverseToolSpec `json:"toolSpec"`
+}
+
+type ConverseToolSpec struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description,omitempty"`
+	InputSchema ConverseInputSchema `json:"inputSchema"`
+}
+
+type ConverseInputSchema struct {
+	Json any `json:"json"`
+}
+
+type ConverseResponse struct {
+	Output struct {
+		Message ConverseMessage `json:"message"`
+	} `json:"output"`
+	StopReason string        `json:"stopReason"`
+	Usage      ConverseUsage `json:"usage"`
+}
+
+type ConverseUsage struct {
+	InputTokens           int `json:"inputTokens"`
+	OutputTokens          int `json:"outputTokens"`
+	TotalTokens           int `json:"totalTokens"`
+	CacheReadInputTokens  int `json:"cacheReadInputTokens,omitempty"`
+	CacheWriteInputTokens int `json:"cacheWriteInputTokens,omitempty"`
+}
+
+// ConverseStreamResponse ConverseStream 的事件，事件类型作为外层字段
+type ConverseStreamResponse struct {
+	MessageStart *struct {
+		Role string `json:"role"`
+	} `json:"messageStart,omitempty"`
+	ContentBlockStart *struct {
+		ContentBlockIndex int `json:"contentBlockIndex"`
+		Start             struct {
+			ToolUse *ConverseToolUse `json:"toolUse,omitempty"`
+		} `json:"start"`
+	} `json:"contentBlockStart,omitempty"`
+	ContentBlockDelta *struct {
+		ContentBlockIndex int `json:"contentBlockIndex"`
+		Delta             struct {
+			Text    string `json:"text,omitempty"`
+			ToolUse *struct {
+				Input string `json:"input"`
+			} `json:"toolUse,omitempty"`
+			ReasoningContent *struct {
+				Text string `json:"text,omitempty"`
+			} `json:"reasoningContent,omitempty"`
+		} `json:"delta"`
+	} `json:"contentBlockDelta,omitempty"`
+	MessageStop *struct {
+		StopReason string `json:"stopReason"`
+	} `json:"messageStop,omitempty"`
+	Metadata *struct {
+		Usage ConverseUsage `json:"usage"`
+	} `json:"metadata,omitempty"`
+}
+
+type ConverseStreamHandler struct {
+	Usage   *types.Usage
+	Request *types.ChatCompletionRequest
+
+	// contentBlockIndex 对应的工具调用序号
+	toolIndex map[int]int
+}
+
+func init() {
+	CategoryMap["converse"] = Category{
+		Converse:                  true,
+		ChatComplete:              ConvertConverseFromChatOpenai,
+		ResponseChatComplete:      ConvertConverseToChatOpenai,
+		ResponseChatCompleteStrem: ConverseChatCompleteStrem,
+	}
+}
+
+func isConverseModel(modelName string) bool {
+	for _, prefix := range converseModels {
+		if strings.Contains(modelName, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func ConvertConverseFromChatOpenai(request *types.ChatCompletionRequest) (any, *types.OpenAIErrorWithStatusCode) {
+	converseRequest := &ConverseRequest{
+		Messages: make([]ConverseMessage, 0, len(request.Messages)),
+	}
+
+	for _, msg := range request.Messages {
+		if msg.IsSystemRole() {
+			if text := msg.StringContent(); text != "" {
+				converseRequest.System = append(converseRequest.System, ConverseContent{Text: text})
+			}
+			continue
+		}
+
+		message, err := convertConverseMessage(&msg)
+		if err != nil {
+			return nil, common.ErrorWrapper(err, "conversion_error", http.StatusBadRequest)
+		}
+
+		// Converse 要求 user 和 assistant 交替出现，连续相同角色的消息需要合并
+		last := len(converseRequest.Messages) - 1
+		if last >= 0 && converseRequest.Messages[last].Role == message.Role {
+			converseRequest.Messages[last].Content = append(converseRequest.Messages[last].Content, message.Content...)
+			continue
+		}
+		converseRequest.Messages = append(converseRequest.Messages, *message)
+	}
+
+	inferenceConfig := &ConverseInferenceConfig{
+		MaxTokens:     request.MaxTokens,
+		Temperature:   request.Temperature,
+		TopP:          request.TopP,
+		StopSequences: convertStopSequences(request.Stop),
+	}
+	if inferenceConfig.MaxTokens == 0 {
+		inferenceConfig.MaxTokens = request.MaxCompletionTokens
+	}
+	converseRequest.InferenceConfig = inferenceConfig
+
+	if len(request.Tools) > 0 {
+		toolConfig := &ConverseToolConfig{
+			Tools: make([]ConverseTool, 0, len(request.Tools)),
+		}
+		for _, tool := range request.Tools {
+			parameters := tool.Function.Parameters
+			if parameters == nil {
+				parameters = map[string]any{"type": "object", "properties": map[string]any{}}
+			}
+			toolConfig.Tools = append(toolConfig.Tools, ConverseTool{
+				ToolSpec: ConverseToolSpec{
+					Name:        tool.Function.Name,
+					Description: tool.Function.Description,
+					InputSchema: ConverseInputSchema{Json: parameters},
+				},
+			})
+		}
+
+		if request.ToolChoice != nil {
+			toolType, toolFunc := request.ParseToolChoice()
+			switch toolType {
+			case types.ToolChoiceTypeFunction:
+				toolConfig.ToolChoice = map[string]any{"tool": map[string]string{"name": toolFunc}}
+			case types.ToolChoiceTypeRequired:
+				toolConfig.ToolChoice = map[string]any{"any": map[string]any{}}
+			case types.ToolChoiceTypeAuto:
+				toolConfig.ToolChoice = map[string]any{"auto": map[string]any{}}
+			}
+		}
+		converseRequest.ToolConfig = toolConfig
+	}
+
+	return converseRequest, nil
+}
+
+func convertConverseMessage(msg *types.ChatCompletionMessage) (*ConverseMessage, error) {
+	// 工具结果需要放在 user 消息中
+	if msg.Role == types.ChatMessageRoleTool {
+		return &ConverseMessage{
+			Role: types.ChatMessageRoleUser,
+			Content: []ConverseContent{{
+				ToolResult: &ConverseToolResult{
+					ToolUseId: msg.ToolCallID,
+					Content:   []ConverseContent{{Text: msg.StringContent()}},
+				},
+			}},
+		}, nil
+	}
+
+	message := &ConverseMessage{
+		Role:    types.ChatMessageRoleUser,
+		Content: make([]ConverseContent, 0),
+	}
+	if msg.Role == types.ChatMessageRoleAssistant {
+		message.Role = types.ChatMessageRoleAssistant
+	}
+
+	for _, part := range msg.ParseContent() {
+		switch part.Type {
+		case types.ContentTypeText:
+			if part.Text != "" {
+				message.Content = append(message.Content, ConverseContent{Text: part.Text})
+			}
+		case types.ContentTypeImageURL:
+			mimeType, data, err := image.GetImageFromUrl(part.ImageURL.URL)
+			if err != nil {
+				return nil, err
+			}
+
+			format := strings.TrimPrefix(mimeType, "image/")
+			if mimeType == "application/pdf" {
+				message.Content = append(message.Content, ConverseContent{
+					Document: &ConverseDocument{
+						Format: "pdf",
+						Name:   fmt.Sprintf("document-%d", len(message.Content)),
+						Source: ConverseSource{Bytes: data},
+					},
+				})
+				continue
+			}
+			if format == "jpg" {
+				format = "jpeg"
+			}
+			message.Content = append(message.Content, ConverseContent{
+				Image: &ConverseImage{
+					Format: format,
+					Source: ConverseSource{Bytes: data},
+				},
+			})
+		}
+	}
+
+	for _, toolCall := range msg.ToolCalls {
+		input := make(map[string]any)
+		if toolCall.Function.Arguments != "" {
+			if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &input); err != nil {
+				return nil, err
+			}
+		}
+		message.Content = append(message.Content, ConverseContent{
+			ToolUse: &ConverseToolUse{
+				ToolUseId: toolCall.Id,
+				Name:      toolCall.Function.Name,
+				Input:     input,
+			},
+		})
+	}
+
+	return message, nil
+}
+
+func convertStopSequences(stop any) []string {
+	switch value := stop.(type) {
+	case string:
+		if value != "" {
+			return []string{value}
+		}
+	case []string:
+		return value
+	case []any:
+		stopSequences := make([]string, 0, len(value))
+		for _, item := range value {
+			if text, ok := item.(string); ok {
+				stopSequences = append(stopSequences, text)
+			}
+		}
+		return stopSequences
+	}
+
+	return nil
+}
+
+func ConvertConverseToChatOpenai(provider base.ProviderInterface, response *http.Response, request *types.ChatCompletionRequest) (*types.ChatCompletionResponse, *types.OpenAIErrorWithStatusCode) {
+	converseResponse := &ConverseResponse{}
+	err := json.NewDecoder(response.Body).Decode(converseResponse)
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "decode_response_failed", http.StatusInternalServerError)
+	}
+
+	message := types.ChatCompletionMessage{
+		Role: types.ChatMessageRoleAssistant,
+	}
+	var content strings.Builder
+	for _, block := range converseResponse.Output.Message.Content {
+		switch {
+		case block.ToolUse != nil:
+			arguments, _ := json.Marshal(block.ToolUse.Input)
+			message.ToolCalls = append(message.ToolCalls, &types.ChatCompletionToolCalls{
+				Id:   block.ToolUse.ToolUseId,
+				Type: types.ChatMessageRoleFunction,
+				Function: &types.ChatCompletionToolCallsFunction{
+					Name:      block.ToolUse.Name,
+					Arguments: string(arguments),
+				},
+				Index: len(message.ToolCalls),
+			})
+		case block.ReasoningContent != nil && block.ReasoningContent.ReasoningText != nil:
+			message.ReasoningContent += block.ReasoningContent.ReasoningText.Text
+		default:
+			content.WriteString(block.Text)
+		}
+	}
+	message.Content = content.String()
+
+	usage := provider.GetUsage()
+	converseUsageToOpenaiUsage(&converseResponse.Usage, usage)
+
+	return &types.ChatCompletionResponse{
+		ID:      fmt.Sprintf("chatcmpl-%s", utils.GetUUID()),
+		Object:  "chat.completion",
+		Created: utils.GetTimestamp(),
+		Model:   request.Model,
+		Choices: []types.ChatCompletionChoice{{
+			Index:        0,
+			Message:      message,
+			FinishReason: converseStopReason(converseResponse.StopReason),
+		}},
+		Usage: usage,
+	}, nil
+}
+
+func ConverseChatCompleteStrem(provider base.ProviderInterface, request *types.ChatCompletionRequest) requester.HandlerPrefix[string] {
+	chatHandler := &ConverseStreamHandler{
+		Usage:     provider.GetUsage(),
+		Request:   request,
+		toolIndex: make(map[int]int),
+	}
+
+	return chatHandler.HandlerStream
+}
+
+func (h *ConverseStreamHandler) HandlerStream(rawLine *[]byte, dataChan chan string, errChan chan error) {
+	var converseResponse ConverseStreamResponse
+	err := json.Unmarshal(*rawLine, &converseResponse)
+	if err != nil {
+		errChan <- common.ErrorToOpenAIError(err)
+		return
+	}
+
+	delta := types.ChatCompletionStreamChoiceDelta{}
+	var finishReason string
+
+	switch {
+	case converseResponse.MessageStart != nil:
+		delta.Role = types.ChatMessageRoleAssistant
+	case converseResponse.ContentBlockStart != nil:
+		toolUse := converseResponse.ContentBlockStart.Start.ToolUse
+		if toolUse == nil {
+			*rawLine = nil
+			return
+		}
+		index := len(h.toolIndex)
+		h.toolIndex[converseResponse.ContentBlockStart.ContentBlockIndex] = index
+		delta.ToolCalls = []*types.ChatCompletionToolCalls{{
+			Id:   toolUse.ToolUseId,
+			Type: types.ChatMessageRoleFunction,
+			Function: &types.ChatCompletionToolCallsFunction{
+				Name:      toolUse.Name,
+				Arguments: "",
+			},
+			Index: index,
+		}}
+	case converseResponse.ContentBlockDelta != nil:
+		blockDelta := converseResponse.ContentBlockDelta.Delta
+		switch {
+		case blockDelta.ToolUse != nil:
+			delta.ToolCalls = []*types.ChatCompletionToolCalls{{
+				Type: types.ChatMessageRoleFunction,
+				Function: &types.ChatCompletionToolCallsFunction{
+					Arguments: blockDelta.ToolUse.Input,
+				},
+				Index: h.toolIndex[converseResponse.ContentBlockDelta.ContentBlockIndex],
+			}}
+		case blockDelta.ReasoningContent != nil:
+			delta.ReasoningContent = blockDelta.ReasoningContent.Text
+		default:
+			delta.Content = blockDelta.Text
+			h.Usage.TextBuilder.WriteString(blockDelta.Text)
+		}
+	case converseResponse.MessageStop != nil:
+		finishReason = converseStopReason(converseResponse.MessageStop.StopReason)
+	case converseResponse.Metadata != nil:
+		// 用量在 messageStop 之后返回，是最后一个事件
+		converseUsageToOpenaiUsage(&converseResponse.Metadata.Usage, h.Usage)
+		errChan <- io.EOF
+		*rawLine = requester.StreamClosed
+		return
+	default:
+		*rawLine = nil
+		return
+	}
+
+	choice := types.ChatCompletionStreamChoice{
+		Index: 0,
+		Delta: delta,
+	}
+	if finishReason != "" {
+		choice.FinishReason = &finishReason
+	}
+
+	chatCompletion := types.ChatCompletionStreamResponse{
+		ID:      fmt.Sprintf("chatcmpl-%s", utils.GetUUID()),
+		Object:  "chat.completion.chunk",
+		Created: utils.GetTimestamp(),
+		Model:   h.Request.Model,
+		Choices: []types.ChatCompletionStreamChoice{choice},
+	}
+
+	responseBody, _ := json.Marshal(chatCompletion)
+	dataChan <- string(responseBody)
+}
+
+func converseUsageToOpenaiUsage(converseUsage *ConverseUsage, usage *types.Usage) {
+	if converseUsage.InputTokens == 0 && converseUsage.OutputTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+		return
+	}
+
+	usage.PromptTokensDetails.CachedWriteTokens = converseUsage.CacheWriteInputTokens
+	usage.PromptTokensDetails.CachedReadTokens = converseUsage.CacheReadInputTokens
+
+	usage.PromptTokens = converseUsage.InputTokens + converseUsage.CacheWriteInputTokens + converseUsage.CacheReadInputTokens
+	usage.CompletionTokens = converseUsage.OutputTokens
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+}
+
+func converseStopReason(stopReason string) string {
+	switch stopReason {
+	case "end_turn", "stop_sequence":
+		return types.FinishReasonStop
+	case "max_tokens", "model_context_window_exceeded":
+		return types.FinishReasonLength
+	case "tool_use":
+		return types.FinishReasonToolCalls
+	case "guardrail_intervened", "content_filtered":
+		return types.FinishReasonContentFilter
+	default:
+		return stopReason
+	}
+}
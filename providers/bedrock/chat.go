@@ -7,6 +7,7 @@ import (
 	"one-api/common/requester"
 	"one-api/providers/bedrock/category"
 	"one-api/types"
+	"strings"
 )
 
 func (p *BedrockProvider) CreateChatCompletion(request *types.ChatCompletionRequest) (*types.ChatCompletionResponse, *types.OpenAIErrorWithStatusCode) {
@@ -56,7 +57,12 @@ func (p *BedrockProvider) getChatRequest(request *types.ChatCompletionRequest) (
 		return nil, errWithCode
 	}
 
-	if request.Stream {
+	if p.Category.Converse {
+		url = strings.Replace(url, "/invoke", "/converse", 1)
+		if request.Stream {
+			url += "-stream"
+		}
+	} else if request.Stream {
 		url += "-with-response-stream"
 	}
 
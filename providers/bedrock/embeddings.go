@@ -0,0 +1,136 @@
+package bedrock
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/utils"
+	"one-api/providers/bedrock/category"
+	"one-api/providers/cohere"
+	"one-api/types"
+	"strings"
+)
+
+type TitanEmbeddingRequest struct {
+	InputText  string `json:"inputText"`
+	Dimensions int    `json:"dimensions,omitempty"`
+	Normalize  *bool  `json:"normalize,omitempty"`
+}
+
+type TitanEmbeddingResponse struct {
+	Embedding           []float64 `json:"embedding"`
+	InputTextTokenCount int       `json:"inputTextTokenCount"`
+}
+
+// CohereEmbeddingRequest Bedrock 上的 Cohere 模型，模型在地址中指定
+type CohereEmbeddingRequest struct {
+	Texts           []string `json:"texts"`
+	InputType       string   `json:"input_type"`
+	EmbeddingTypes  []string `json:"embedding_types,omitempty"`
+	OutputDimension int      `json:"output_dimension,omitempty"`
+}
+
+func (p *BedrockProvider) CreateEmbeddings(request *types.EmbeddingRequest) (*types.EmbeddingResponse, *types.OpenAIErrorWithStatusCode) {
+	input := request.ParseInput()
+	if len(input) == 0 {
+		return nil, common.StringErrorWrapperLocal("input is required", "invalid_request", http.StatusBadRequest)
+	}
+
+	modelName := category.GetModelName(request.Model)
+	switch {
+	case strings.Contains(modelName, "titan-embed"):
+		return p.createTitanEmbeddings(request, modelName, input)
+	case strings.Contains(modelName, "cohere.embed"):
+		return p.createCohereEmbeddings(request, modelName, input)
+	default:
+		return nil, common.StringErrorWrapperLocal("bedrock embedding model not supported", "bedrock_err", http.StatusBadRequest)
+	}
+}
+
+// createTitanEmbeddings Titan 每次只能处理一条输入，多条输入依次请求
+func (p *BedrockProvider) createTitanEmbeddings(request *types.EmbeddingRequest, modelName string, input []string) (*types.EmbeddingResponse, *types.OpenAIErrorWithStatusCode) {
+	response := &types.EmbeddingResponse{
+		Object: "list",
+		Model:  request.Model,
+		Data:   make([]types.Embedding, 0, len(input)),
+	}
+
+	tokenCount := 0
+	for i, text := range input {
+		titanRequest := &TitanEmbeddingRequest{
+			InputText: text,
+		}
+		// v1 模型不支持 dimensions 和 normalize 参数
+		if !strings.Contains(modelName, "titan-embed-text-v1") {
+			normalize := true
+			titanRequest.Dimensions = request.Dimensions
+			titanRequest.Normalize = &normalize
+		}
+
+		titanResponse := &TitanEmbeddingResponse{}
+		if _, errWithCode := p.sendEmbeddingRequest(modelName, titanRequest, titanResponse); errWithCode != nil {
+			return nil, errWithCode
+		}
+
+		tokenCount += titanResponse.InputTextTokenCount
+		response.Data = append(response.Data, types.Embedding{
+			Object:    "embedding",
+			Index:     i,
+			Embedding: request.FormatEmbedding(titanResponse.Embedding),
+		})
+	}
+
+	usage := p.GetUsage()
+	if tokenCount > 0 {
+		usage.PromptTokens = tokenCount
+	}
+	usage.TotalTokens = usage.PromptTokens
+	response.Usage = usage
+
+	return response, nil
+}
+
+func (p *BedrockProvider) createCohereEmbeddings(request *types.EmbeddingRequest, modelName string, input []string) (*types.EmbeddingResponse, *types.OpenAIErrorWithStatusCode) {
+	embedRequest := cohere.ConvertEmbeddingRequest(request, input)
+	cohereRequest := &CohereEmbeddingRequest{
+		Texts:           embedRequest.Texts,
+		InputType:       embedRequest.InputType,
+		EmbeddingTypes:  embedRequest.EmbeddingTypes,
+		OutputDimension: embedRequest.OutputDimension,
+	}
+
+	cohereResponse := &cohere.EmbedResponse{}
+	res, errWithCode := p.sendEmbeddingRequest(modelName, cohereRequest, cohereResponse)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	// Bedrock 通过响应头返回输入 token 数
+	usage := p.GetUsage()
+	if tokenCount := utils.String2Int(res.Header.Get("X-Amzn-Bedrock-Input-Token-Count")); tokenCount > 0 {
+		usage.PromptTokens = tokenCount
+	}
+
+	return cohere.ConvertToEmbeddingOpenai(cohereResponse, request, usage), nil
+}
+
+func (p *BedrockProvider) sendEmbeddingRequest(modelName string, body any, response any) (*http.Response, *types.OpenAIErrorWithStatusCode) {
+	url, errWithCode := p.GetSupportedAPIUri(config.RelayModeEmbeddings)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	// 获取请求地址
+	fullRequestURL := p.GetFullRequestURL(url, modelName)
+	headers := p.GetRequestHeaders()
+
+	// 创建请求
+	req, err := p.Requester.NewRequest(http.MethodPost, fullRequestURL, p.Requester.WithBody(body), p.Requester.WithHeader(headers))
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+	defer req.Body.Close()
+	p.Sign(req)
+
+	return p.Requester.SendRequest(req, response, false)
+}
@@ -0,0 +1,95 @@
+package bedrock_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"one-api/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateTitanEmbeddings(t *testing.T) {
+	tests := []struct {
+		name          string
+		model         string
+		wantDimension bool
+	}{
+		{
+			name:  "v1 omits dimensions and normalize",
+			model: "amazon.titan-embed-text-v1",
+		},
+		{
+			name:          "v2 sends dimensions and normalize",
+			model:         "amazon.titan-embed-text-v2:0",
+			wantDimension: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPaths []string
+			var gotBodies []map[string]any
+			provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+				body := map[string]any{}
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+				gotPaths = append(gotPaths, r.URL.Path)
+				gotBodies = append(gotBodies, body)
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"embedding":[0.1,0.2],"inputTextTokenCount":2}`))
+			})
+
+			response, errWithCode := provider.CreateEmbeddings(&types.EmbeddingRequest{
+				Model:      tt.model,
+				Input:      []any{"hello", "world"},
+				Dimensions: 256,
+				InputType:  "search_query",
+			})
+			require.Nil(t, errWithCode)
+
+			// Titan 每次只能处理一条输入
+			require.Len(t, gotBodies, 2)
+			assert.Equal(t, "/us-east-1/model/"+tt.model+"/invoke", gotPaths[0])
+			assert.Equal(t, "world", gotBodies[1]["inputText"])
+			for _, body := range gotBodies {
+				assert.NotContains(t, body, "input_type")
+				if tt.wantDimension {
+					assert.Equal(t, float64(256), body["dimensions"])
+					assert.Equal(t, true, body["normalize"])
+				} else {
+					assert.NotContains(t, body, "dimensions")
+					assert.NotContains(t, body, "normalize")
+				}
+			}
+
+			require.Len(t, response.Data, 2)
+			assert.Equal(t, 1, response.Data[1].Index)
+			assert.Equal(t, 4, response.Usage.PromptTokens)
+		})
+	}
+}
+
+func TestCreateCohereEmbeddings(t *testing.T) {
+	var gotBody map[string]any
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&gotBody))
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Amzn-Bedrock-Input-Token-Count", "5")
+		w.Write([]byte(`{"embeddings":{"float":[[0.1,0.2],[0.3,0.4]]}}`))
+	})
+
+	response, errWithCode := provider.CreateEmbeddings(&types.EmbeddingRequest{
+		Model:     "cohere.embed-multilingual-v3",
+		Input:     []any{"hello", "world"},
+		InputType: "search_query",
+	})
+	require.Nil(t, errWithCode)
+
+	assert.Equal(t, "search_query", gotBody["input_type"])
+	assert.Equal(t, []any{"hello", "world"}, gotBody["texts"])
+	assert.NotContains(t, gotBody, "model")
+
+	require.Len(t, response.Data, 2)
+	assert.Equal(t, 5, response.Usage.PromptTokens)
+}
@@ -11,6 +11,10 @@ import (
 )
 
 func (p *BedrockProvider) CreateClaudeChat(request *claude.ClaudeRequest) (*claude.ClaudeResponse, *types.OpenAIErrorWithStatusCode) {
+	if isConverseModel(request.Model) {
+		return p.createClaudeChatByConverse(request)
+	}
+
 	req, errWithCode := p.getClaudeRequest(request)
 	if errWithCode != nil {
 		return nil, errWithCode
@@ -30,6 +34,10 @@ func (p *BedrockProvider) CreateClaudeChat(request *claude.ClaudeRequest) (*clau
 }
 
 func (p *BedrockProvider) CreateClaudeChatStream(request *claude.ClaudeRequest) (requester.StreamReaderInterface[string], *types.OpenAIErrorWithStatusCode) {
+	if isConverseModel(request.Model) {
+		return p.createClaudeChatStreamByConverse(request)
+	}
+
 	req, errWithCode := p.getClaudeRequest(request)
 	if errWithCode != nil {
 		return nil, errWithCode
@@ -104,3 +112,38 @@ func (p *BedrockProvider) getClaudeRequest(request *claude.ClaudeRequest) (*http
 
 	return req, nil
 }
+
+// isConverseModel 非 Anthropic 模型通过 Converse API 调用，不能直接转发 Claude 格式的请求
+func isConverseModel(modelName string) bool {
+	modelCategory, err := category.GetCategory(modelName)
+	return err == nil && modelCategory.Converse
+}
+
+// createClaudeChatByConverse 将 Claude 格式的请求转换为 OpenAI 格式，再通过 Converse API 发送
+func (p *BedrockProvider) createClaudeChatByConverse(request *claude.ClaudeRequest) (*claude.ClaudeResponse, *types.OpenAIErrorWithStatusCode) {
+	chatRequest, errWithCode := claude.ConvertToChatOpenaiRequest(request)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	response, errWithCode := p.CreateChatCompletion(chatRequest)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	return claude.ConvertChatOpenaiToClaude(response, request.Model, p.GetUsage()), nil
+}
+
+func (p *BedrockProvider) createClaudeChatStreamByConverse(request *claude.ClaudeRequest) (requester.StreamReaderInterface[string], *types.OpenAIErrorWithStatusCode) {
+	chatRequest, errWithCode := claude.ConvertToChatOpenaiRequest(request)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	stream, errWithCode := p.CreateChatCompletionStream(chatRequest)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	return claude.NewChatOpenaiToClaudeStream(stream, request.Model, p.GetUsage), nil
+}
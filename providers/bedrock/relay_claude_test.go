@@ -0,0 +1,137 @@
+package bedrock_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common/requester"
+	"one-api/common/utils"
+	"one-api/model"
+	"one-api/providers/bedrock"
+	"one-api/providers/claude"
+	"one-api/types"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream"
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream/eventstreamapi"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const converseModel = "meta.llama3-70b-instruct-v1:0"
+
+func newTestProvider(t *testing.T, handler http.HandlerFunc) *bedrock.BedrockProvider {
+	t.Helper()
+	requester.HTTPClient = &http.Client{}
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/claude/v1/messages", nil)
+
+	provider := bedrock.BedrockProviderFactory{}.Create(&model.Channel{
+		Key:     "us-east-1|test-token",
+		BaseURL: utils.GetPointer(server.URL + "/%s"),
+		Proxy:   utils.GetPointer(""),
+	}).(*bedrock.BedrockProvider)
+	provider.SetContext(c)
+	provider.SetUsage(&types.Usage{})
+
+	return provider
+}
+
+func newClaudeRequest(stream bool) *claude.ClaudeRequest {
+	return &claude.ClaudeRequest{
+		Model:     converseModel,
+		System:    "be brief",
+		MaxTokens: 100,
+		Stream:    stream,
+		Messages:  []claude.Message{{Role: "user", Content: "hello"}},
+	}
+}
+
+func encodeConverseEvents(t *testing.T, events map[string]string, order []string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	encoder := eventstream.NewEncoder()
+	for _, eventType := range order {
+		message := eventstream.Message{Payload: []byte(events[eventType])}
+		message.Headers.Set(eventstreamapi.MessageTypeHeader, eventstream.StringValue(eventstreamapi.EventMessageType))
+		message.Headers.Set(eventstreamapi.EventTypeHeader, eventstream.StringValue(eventType))
+		require.NoError(t, encoder.Encode(&buf, message))
+	}
+
+	return buf.Bytes()
+}
+
+func TestCreateClaudeChatByConverse(t *testing.T) {
+	var gotPath string
+	var gotBody map[string]any
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&gotBody))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"output":{"message":{"role":"assistant","content":[{"text":"hi there"}]}},"stopReason":"end_turn","usage":{"inputTokens":12,"outputTokens":3,"totalTokens":15}}`))
+	})
+
+	response, errWithCode := provider.CreateClaudeChat(newClaudeRequest(false))
+	require.Nil(t, errWithCode)
+
+	// 非 Anthropic 模型使用 Converse API，请求体为 Converse 格式
+	assert.Equal(t, "/us-east-1/model/"+converseModel+"/converse", gotPath)
+	assert.NotContains(t, gotBody, "anthropic_version")
+	assert.Equal(t, []any{map[string]any{"text": "be brief"}}, gotBody["system"])
+	require.Len(t, gotBody["messages"], 1)
+
+	assert.Equal(t, "message", response.Type)
+	assert.Equal(t, converseModel, response.Model)
+	require.Len(t, response.Content, 1)
+	assert.Equal(t, "hi there", response.Content[0].Text)
+	assert.Equal(t, claude.FinishReasonEndTurn, response.StopReason)
+	assert.Equal(t, 12, provider.GetUsage().PromptTokens)
+	assert.Equal(t, 3, provider.GetUsage().CompletionTokens)
+}
+
+func TestCreateClaudeChatStreamByConverse(t *testing.T) {
+	var gotPath string
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+		w.Write(encodeConverseEvents(t, map[string]string{
+			"messageStart":      `{"role":"assistant"}`,
+			"contentBlockDelta": `{"contentBlockIndex":0,"delta":{"text":"hi there"}}`,
+			"messageStop":       `{"stopReason":"end_turn"}`,
+			"metadata":          `{"usage":{"inputTokens":12,"outputTokens":3,"totalTokens":15}}`,
+		}, []string{"messageStart", "contentBlockDelta", "messageStop", "metadata"}))
+	})
+
+	stream, errWithCode := provider.CreateClaudeChatStream(newClaudeRequest(true))
+	require.Nil(t, errWithCode)
+	defer stream.Close()
+
+	assert.Equal(t, "/us-east-1/model/"+converseModel+"/converse-stream", gotPath)
+
+	var events strings.Builder
+	dataChan, errChan := stream.Recv()
+	for done := false; !done; {
+		select {
+		case data := <-dataChan:
+			events.WriteString(data)
+		case err := <-errChan:
+			require.ErrorIs(t, err, io.EOF)
+			done = true
+		}
+	}
+
+	output := events.String()
+	assert.Contains(t, output, "event: message_start")
+	assert.Contains(t, output, `"text":"hi there"`)
+	assert.Contains(t, output, `"stop_reason":"end_turn"`)
+	assert.Contains(t, output, `"output_tokens":3`)
+	assert.True(t, strings.HasSuffix(strings.TrimSpace(output), `{"type":"message_stop"}`))
+}
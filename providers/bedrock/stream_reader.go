@@ -76,6 +76,11 @@ func (stream *streamReader[T]) deserializeEventMessage(msg *eventstream.Message)
 
 	switch messageType.String() {
 	case eventstreamapi.EventMessageType:
+		// ConverseStream 的事件直接返回 JSON，事件类型在消息头中，包装成 {"事件类型": 内容} 交给处理函数
+		if eventType := msg.Headers.Get(eventstreamapi.EventTypeHeader); eventType != nil && eventType.String() != "chunk" {
+			return []byte(fmt.Sprintf(`{%q:%s}`, eventType.String(), msg.Payload)), nil
+		}
+
 		var v BedrockResponseStream
 		if err := json.Unmarshal(msg.Payload, &v); err != nil {
 			return nil, err
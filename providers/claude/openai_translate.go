@@ -0,0 +1,630 @@
+package claude
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/common/requester"
+	"one-api/common/utils"
+	"one-api/types"
+	"strings"
+)
+
+// 以下为 Claude Messages 与 OpenAI Chat Completions 的反向转换，
+// 用于 /claude/v1/messages 请求只实现了 ChatInterface 的渠道
+
+// ConvertToChatOpenaiRequest 将 Claude 请求转换为 OpenAI 聊天请求
+func ConvertToChatOpenaiRequest(request *ClaudeRequest) (*types.ChatCompletionRequest, *types.OpenAIErrorWithStatusCode) {
+	openaiRequest := &types.ChatCompletionRequest{
+		Model:       request.Model,
+		Messages:    make([]types.ChatCompletionMessage, 0, len(request.Messages)+1),
+		MaxTokens:   request.MaxTokens,
+		Temperature: request.Temperature,
+		TopP:        request.TopP,
+		Stream:      request.Stream,
+	}
+
+	if request.TopK != nil {
+		topK := float64(*request.TopK)
+		openaiRequest.TopK = &topK
+	}
+
+	if len(request.StopSequences) > 0 {
+		openaiRequest.Stop = request.StopSequences
+	}
+
+	if system := claudeSystemText(request.System); system != "" {
+		openaiRequest.Messages = append(openaiRequest.Messages, types.ChatCompletionMessage{
+			Role:    types.ChatMessageRoleSystem,
+			Content: system,
+		})
+	}
+
+	for _, message := range request.Messages {
+		messages, err := convertClaudeMessage(&message)
+		if err != nil {
+			return nil, common.ErrorWrapperLocal(err, "conversion_error", http.StatusBadRequest)
+		}
+		openaiRequest.Messages = append(openaiRequest.Messages, messages...)
+	}
+
+	for _, tool := range request.Tools {
+		// 服务端工具（web_search、bash 等）只有 Claude 支持
+		if tool.Type != "" && tool.Type != "custom" {
+			continue
+		}
+
+		parameters := tool.InputSchema
+		if parameters == nil {
+			parameters = map[string]any{"type": "object", "properties": map[string]any{}}
+		}
+		openaiRequest.Tools = append(openaiRequest.Tools, &types.ChatCompletionTool{
+			Type: "function",
+			Function: types.ChatCompletionFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  parameters,
+			},
+		})
+	}
+
+	if request.ToolChoice != nil && len(openaiRequest.Tools) > 0 {
+		switch request.ToolChoice.Type {
+		case "any":
+			openaiRequest.ToolChoice = types.ToolChoiceTypeRequired
+		case "tool":
+			openaiRequest.ToolChoice = map[string]any{
+				"type":     types.ToolChoiceTypeFunction,
+				"function": map[string]any{"name": request.ToolChoice.Name},
+			}
+		case "none":
+			openaiRequest.ToolChoice = types.ToolChoiceTypeNone
+		default:
+			openaiRequest.ToolChoice = types.ToolChoiceTypeAuto
+		}
+	}
+
+	if request.Thinking != nil && request.Thinking.Type == "enabled" {
+		openaiRequest.Reasoning = &types.ChatReasoning{
+			MaxTokens: request.Thinking.BudgetTokens,
+		}
+	}
+
+	if openaiRequest.Stream {
+		openaiRequest.StreamOptions = &types.StreamOptions{IncludeUsage: true}
+	}
+
+	return openaiRequest, nil
+}
+
+// claudeSystemText system 可以是字符串，也可以是带 cache_control 的文本块数组
+func claudeSystemText(system any) string {
+	switch v := system.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	}
+
+	var blocks []MessageContent
+	if err := remarshal(system, &blocks); err != nil {
+		return ""
+	}
+
+	texts := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		if block.Type == ContentTypeText && block.Text != "" {
+			texts = append(texts, block.Text)
+		}
+	}
+
+	return strings.Join(texts, "\n")
+}
+
+func parseClaudeContent(content any) ([]MessageContent, error) {
+	if text, ok := content.(string); ok {
+		return []MessageContent{{Type: ContentTypeText, Text: text}}, nil
+	}
+
+	var blocks []MessageContent
+	if err := remarshal(content, &blocks); err != nil {
+		return nil, err
+	}
+
+	return blocks, nil
+}
+
+// convertClaudeMessage 一条 Claude 消息可能对应多条 OpenAI 消息：
+// tool_result 需要拆分为单独的 tool 消息，并放在同一条消息的其他内容之前
+func convertClaudeMessage(message *Message) ([]types.ChatCompletionMessage, error) {
+	blocks, err := parseClaudeContent(message.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	if message.Role == types.ChatMessageRoleAssistant {
+		return []types.ChatCompletionMessage{convertClaudeAssistantMessage(blocks)}, nil
+	}
+
+	messages := make([]types.ChatCompletionMessage, 0, 1)
+	parts := make([]types.ChatMessagePart, 0, len(blocks))
+	for _, block := range blocks {
+		switch block.Type {
+		case ContentTypeToolResult:
+			toolMessage, toolParts := convertClaudeToolResult(&block)
+			messages = append(messages, toolMessage)
+			// tool 消息只支持文本，结果中的图片放到之后的用户消息中
+			parts = append(parts, toolParts...)
+		case ContentTypeText:
+			parts = append(parts, types.ChatMessagePart{Type: types.ContentTypeText, Text: block.Text})
+		default:
+			if part := convertClaudeMediaBlock(&block); part != nil {
+				parts = append(parts, *part)
+			}
+		}
+	}
+
+	if len(parts) == 0 {
+		return messages, nil
+	}
+
+	userMessage := types.ChatCompletionMessage{Role: types.ChatMessageRoleUser}
+	if len(parts) == 1 && parts[0].Type == types.ContentTypeText {
+		userMessage.Content = parts[0].Text
+	} else {
+		userMessage.Content = parts
+	}
+
+	return append(messages, userMessage), nil
+}
+
+// convertClaudeAssistantMessage thinking 块不回传给上游，部分上游收到 reasoning_content 会报错
+func convertClaudeAssistantMessage(blocks []MessageContent) types.ChatCompletionMessage {
+	message := types.ChatCompletionMessage{Role: types.ChatMessageRoleAssistant}
+
+	var text strings.Builder
+	for _, block := range blocks {
+		switch block.Type {
+		case ContentTypeText:
+			text.WriteString(block.Text)
+		case ContentTypeToolUes:
+			arguments := "{}"
+			if block.Input != nil {
+				if data, err := json.Marshal(block.Input); err == nil {
+					arguments = string(data)
+				}
+			}
+			message.ToolCalls = append(message.ToolCalls, &types.ChatCompletionToolCalls{
+				Id:   block.Id,
+				Type: types.ToolChoiceTypeFunction,
+				Function: &types.ChatCompletionToolCallsFunction{
+					Name:      block.Name,
+					Arguments: arguments,
+				},
+				Index: len(message.ToolCalls),
+			})
+		}
+	}
+
+	message.Content = text.String()
+	return message
+}
+
+func convertClaudeToolResult(block *MessageContent) (types.ChatCompletionMessage, []types.ChatMessagePart) {
+	message := types.ChatCompletionMessage{
+		Role:       types.ChatMessageRoleTool,
+		ToolCallID: block.ToolUseId,
+	}
+
+	var parts []types.ChatMessagePart
+	var text strings.Builder
+	if block.Content != nil {
+		contents, err := parseClaudeContent(block.Content)
+		if err == nil {
+			for _, content := range contents {
+				if content.Type == ContentTypeText {
+					text.WriteString(content.Text)
+				} else if part := convertClaudeMediaBlock(&content); part != nil {
+					parts = append(parts, *part)
+				}
+			}
+		}
+	}
+
+	content := text.String()
+	if block.IsError != nil && *block.IsError && content == "" {
+		content = "error"
+	}
+	message.Content = content
+
+	return message, parts
+}
+
+// convertClaudeMediaBlock 将图片和 PDF 文档转换为 OpenAI 的内容块
+func convertClaudeMediaBlock(block *MessageContent) *types.ChatMessagePart {
+	if block.Source == nil {
+		return nil
+	}
+
+	url := block.Source.Url
+	if block.Source.Type == "base64" {
+		url = fmt.Sprintf("data:%s;base64,%s", block.Source.MediaType, block.Source.Data)
+	}
+	if url == "" {
+		return nil
+	}
+
+	switch block.Type {
+	case ContentTypeImage:
+		return &types.ChatMessagePart{
+			Type:     types.ContentTypeImageURL,
+			ImageURL: &types.ChatMessageImageURL{URL: url},
+		}
+	case "document":
+		if block.Source.Type != "base64" {
+			return nil
+		}
+		return &types.ChatMessagePart{
+			Type: "file",
+			File: &types.ChatMessageFile{
+				Filename: "document.pdf",
+				FileData: url,
+			},
+		}
+	}
+
+	return nil
+}
+
+func stopReasonOpenAI2Claude(reason string) string {
+	switch reason {
+	case types.FinishReasonLength:
+		return "max_tokens"
+	case types.FinishReasonToolCalls, types.FinishReasonFunctionCall:
+		return FinishReasonToolUse
+	case types.FinishReasonContentFilter:
+		return "refusal"
+	default:
+		return FinishReasonEndTurn
+	}
+}
+
+func openaiUsageToClaudeUsage(usage *types.Usage) Usage {
+	if usage == nil {
+		return Usage{}
+	}
+
+	cached := usage.PromptTokensDetails.CachedTokens
+	return Usage{
+		InputTokens:          usage.PromptTokens - cached,
+		OutputTokens:         usage.CompletionTokens,
+		CacheReadInputTokens: cached,
+	}
+}
+
+func convertToolArguments(arguments string) any {
+	input := map[string]any{}
+	if arguments != "" {
+		if err := json.Unmarshal([]byte(arguments), &input); err != nil {
+			return map[string]any{}
+		}
+	}
+
+	return input
+}
+
+// ConvertChatOpenaiToClaude 将 OpenAI 聊天响应转换为 Claude 响应
+func ConvertChatOpenaiToClaude(response *types.ChatCompletionResponse, modelName string, usage *types.Usage) *ClaudeResponse {
+	claudeResponse := &ClaudeResponse{
+		Id:         "msg_" + strings.TrimPrefix(response.ID, "chatcmpl-"),
+		Type:       "message",
+		Role:       types.ChatMessageRoleAssistant,
+		Content:    make([]ResContent, 0),
+		Model:      modelName,
+		StopReason: FinishReasonEndTurn,
+		Usage:      openaiUsageToClaudeUsage(usage),
+	}
+
+	if len(response.Choices) == 0 {
+		return claudeResponse
+	}
+
+	choice := response.Choices[0]
+	reasoning := choice.Message.ReasoningContent
+	if reasoning == "" {
+		reasoning = choice.Message.Reasoning
+	}
+	if reasoning != "" {
+		claudeResponse.Content = append(claudeResponse.Content, ResContent{
+			Type:     ContentTypeThinking,
+			Thinking: reasoning,
+		})
+	}
+
+	if text := choice.Message.StringContent(); text != "" {
+		claudeResponse.Content = append(claudeResponse.Content, ResContent{
+			Type: ContentTypeText,
+			Text: text,
+		})
+	}
+
+	choice.Message.FuncToToolCalls()
+	for _, toolCall := range choice.Message.ToolCalls {
+		if toolCall.Function == nil {
+			continue
+		}
+		claudeResponse.Content = append(claudeResponse.Content, ResContent{
+			Type:  ContentTypeToolUes,
+			Id:    toolCall.Id,
+			Name:  toolCall.Function.Name,
+			Input: convertToolArguments(toolCall.Function.Arguments),
+		})
+	}
+
+	claudeResponse.StopReason = stopReasonOpenAI2Claude(choice.FinishReason)
+	if len(choice.Message.ToolCalls) > 0 {
+		claudeResponse.StopReason = FinishReasonToolUse
+	}
+
+	return claudeResponse
+}
+
+// openaiToClaudeStream 将 OpenAI 的流式响应转换为 Claude 的 SSE 事件
+type openaiToClaudeStream struct {
+	stream    requester.StreamReaderInterface[string]
+	modelName string
+	getUsage  func() *types.Usage
+
+	messageId  string
+	started    bool
+	blockIndex int
+	blockType  string // 当前打开的内容块类型，为空表示没有打开的内容块
+	toolIndex  int    // 当前工具块对应的 OpenAI tool_calls 下标
+	stopReason string
+}
+
+// NewChatOpenaiToClaudeStream getUsage 在流结束时获取上游统计的用量
+func NewChatOpenaiToClaudeStream(stream requester.StreamReaderInterface[string], modelName string, getUsage func() *types.Usage) requester.StreamReaderInterface[string] {
+	return &openaiToClaudeStream{
+		stream:    stream,
+		modelName: modelName,
+		getUsage:  getUsage,
+		messageId: "msg_" + utils.GetUUID(),
+		toolIndex: -1,
+	}
+}
+
+func (s *openaiToClaudeStream) Recv() (<-chan string, <-chan error) {
+	dataChan := make(chan string)
+	errChan := make(chan error)
+	upstreamData, upstreamErr := s.stream.Recv()
+
+	go func() {
+		for {
+			select {
+			case data, ok := <-upstreamData:
+				if !ok {
+					s.finish(dataChan)
+					errChan <- io.EOF
+					return
+				}
+				for _, event := range s.convert(data) {
+					dataChan <- event
+				}
+			case err := <-upstreamErr:
+				if errors.Is(err, io.EOF) {
+					s.finish(dataChan)
+					errChan <- io.EOF
+					return
+				}
+
+				// 已经开始输出，按 Claude 的格式返回错误事件
+				dataChan <- claudeEvent("error", map[string]any{
+					"type": "error",
+					"error": map[string]any{
+						"type":    "api_error",
+						"message": err.Error(),
+					},
+				})
+				errChan <- io.EOF
+				return
+			}
+		}
+	}()
+
+	return dataChan, errChan
+}
+
+func (s *openaiToClaudeStream) Close() {
+	s.stream.Close()
+}
+
+func claudeEvent(eventType string, data any) string {
+	body, _ := json.Marshal(data)
+	return fmt.Sprintf("event: %s\ndata: %s\n\n", eventType, body)
+}
+
+func (s *openaiToClaudeStream) start() []string {
+	s.started = true
+	return []string{claudeEvent("message_start", map[string]any{
+		"type": "message_start",
+		"message": map[string]any{
+			"id":            s.messageId,
+			"type":          "message",
+			"role":          types.ChatMessageRoleAssistant,
+			"content":       []any{},
+			"model":         s.modelName,
+			"stop_reason":   nil,
+			"stop_sequence": nil,
+			"usage": map[string]any{
+				"input_tokens":  s.getUsage().PromptTokens,
+				"output_tokens": 0,
+			},
+		},
+	})}
+}
+
+func (s *openaiToClaudeStream) stopBlock() []string {
+	if s.blockType == "" {
+		return nil
+	}
+
+	var events []string
+	if s.blockType == ContentTypeThinking {
+		// 上游没有签名，客户端需要 signature_delta 结束思考块
+		events = append(events, claudeEvent("content_block_delta", map[string]any{
+			"type":  "content_block_delta",
+			"index": s.blockIndex,
+			"delta": map[string]any{"type": ContentStreamTypeSignatureDelta, "signature": ""},
+		}))
+	}
+
+	events = append(events, claudeEvent("content_block_stop", map[string]any{
+		"type":  "content_block_stop",
+		"index": s.blockIndex,
+	}))
+	s.blockType = ""
+	s.blockIndex++
+
+	return events
+}
+
+func (s *openaiToClaudeStream) startBlock(blockType string, contentBlock map[string]any) []string {
+	events := s.stopBlock()
+	s.blockType = blockType
+
+	return append(events, claudeEvent("content_block_start", map[string]any{
+		"type":          "content_block_start",
+		"index":         s.blockIndex,
+		"content_block": contentBlock,
+	}))
+}
+
+func (s *openaiToClaudeStream) delta(delta map[string]any) string {
+	return claudeEvent("content_block_delta", map[string]any{
+		"type":  "content_block_delta",
+		"index": s.blockIndex,
+		"delta": delta,
+	})
+}
+
+func (s *openaiToClaudeStream) convert(data string) []string {
+	var chunk types.ChatCompletionStreamResponse
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return nil
+	}
+
+	var events []string
+	if !s.started {
+		events = append(events, s.start()...)
+	}
+
+	if len(chunk.Choices) == 0 {
+		return events
+	}
+
+	choice := chunk.Choices[0]
+	reasoning := choice.Delta.ReasoningContent
+	if reasoning == "" {
+		reasoning = choice.Delta.Reasoning
+	}
+	if reasoning != "" {
+		if s.blockType != ContentTypeThinking {
+			events = append(events, s.startBlock(ContentTypeThinking, map[string]any{"type": ContentTypeThinking, "thinking": ""})...)
+		}
+		events = append(events, s.delta(map[string]any{"type": ContentStreamTypeThinking, "thinking": reasoning}))
+	}
+
+	if choice.Delta.Content != "" {
+		if s.blockType != ContentTypeText {
+			events = append(events, s.startBlock(ContentTypeText, map[string]any{"type": ContentTypeText, "text": ""})...)
+		}
+		events = append(events, s.delta(map[string]any{"type": "text_delta", "text": choice.Delta.Content}))
+	}
+
+	if choice.Delta.FunctionCall != nil {
+		choice.Delta.ToolCalls = []*types.ChatCompletionToolCalls{{Function: choice.Delta.FunctionCall}}
+	}
+	for _, toolCall := range choice.Delta.ToolCalls {
+		if toolCall.Function == nil {
+			continue
+		}
+
+		// 新的工具调用以 id 或 name 开始，之后的分片只有参数
+		if toolCall.Id != "" || toolCall.Function.Name != "" || s.blockType != ContentTypeToolUes || toolCall.Index != s.toolIndex {
+			toolId := toolCall.Id
+			if toolId == "" {
+				toolId = "toolu_" + utils.GetUUID()
+			}
+			events = append(events, s.startBlock(ContentTypeToolUes, map[string]any{
+				"type":  ContentTypeToolUes,
+				"id":    toolId,
+				"name":  toolCall.Function.Name,
+				"input": map[string]any{},
+			})...)
+			s.toolIndex = toolCall.Index
+		}
+
+		if toolCall.Function.Arguments != "" {
+			events = append(events, s.delta(map[string]any{"type": ContentStreamTypeInputJsonDelta, "partial_json": toolCall.Function.Arguments}))
+		}
+	}
+
+	if reason, ok := choice.FinishReason.(string); ok && reason != "" && reason != types.FinishReasonNull {
+		s.stopReason = stopReasonOpenAI2Claude(reason)
+	}
+
+	return events
+}
+
+func (s *openaiToClaudeStream) finish(dataChan chan<- string) {
+	var events []string
+	if !s.started {
+		events = append(events, s.start()...)
+	}
+	events = append(events, s.stopBlock()...)
+
+	usage := s.getUsage()
+	outputTokens := usage.CompletionTokens
+	if outputTokens == 0 && usage.TextBuilder.Len() > 0 {
+		outputTokens = common.CountTokenText(usage.TextBuilder.String(), s.modelName)
+	}
+
+	stopReason := s.stopReason
+	if stopReason == "" {
+		stopReason = FinishReasonEndTurn
+	}
+
+	events = append(events,
+		claudeEvent("message_delta", map[string]any{
+			"type": "message_delta",
+			"delta": map[string]any{
+				"stop_reason":   stopReason,
+				"stop_sequence": nil,
+			},
+			"usage": map[string]any{
+				"input_tokens":            usage.PromptTokens - usage.PromptTokensDetails.CachedTokens,
+				"output_tokens":           outputTokens,
+				"cache_read_input_tokens": usage.PromptTokensDetails.CachedTokens,
+			},
+		}),
+		claudeEvent("message_stop", map[string]any{"type": "message_stop"}),
+	)
+
+	for _, event := range events {
+		dataChan <- event
+	}
+}
+
+// remarshal 将 any 类型的请求字段重新解析为指定结构
+func remarshal(data any, v any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(body, v)
+}
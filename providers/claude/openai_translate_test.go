@@ -0,0 +1,286 @@
+package claude_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"one-api/common/utils"
+	"one-api/providers/claude"
+	"one-api/types"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertToChatOpenaiRequest(t *testing.T) {
+	isError := true
+	request := &claude.ClaudeRequest{
+		Model:         "claude-sonnet-4",
+		System:        []any{map[string]any{"type": "text", "text": "be brief"}, map[string]any{"type": "text", "text": "be kind"}},
+		MaxTokens:     1024,
+		StopSequences: []string{"END"},
+		Temperature:   utils.GetPointer(0.5),
+		TopK:          utils.GetPointer(40),
+		Stream:        true,
+		Messages: []claude.Message{
+			{Role: "user", Content: "what is the weather"},
+			{Role: "assistant", Content: []any{
+				map[string]any{"type": "thinking", "thinking": "need a tool"},
+				map[string]any{"type": "text", "text": "checking"},
+				map[string]any{"type": "tool_use", "id": "toolu_1", "name": "weather", "input": map[string]any{"city": "Paris"}},
+			}},
+			{Role: "user", Content: []any{
+				map[string]any{"type": "tool_result", "tool_use_id": "toolu_1", "content": "sunny"},
+				map[string]any{"type": "tool_result", "tool_use_id": "toolu_2", "is_error": isError},
+				map[string]any{"type": "image", "source": map[string]any{"type": "base64", "media_type": "image/png", "data": "aGk="}},
+			}},
+		},
+		Tools: []claude.Tools{
+			{Name: "weather", Description: "get weather", InputSchema: map[string]any{"type": "object"}},
+			{Type: "web_search_20250305", Name: "web_search"},
+		},
+		ToolChoice: &claude.ToolChoice{Type: "tool", Name: "weather"},
+		Thinking:   &claude.Thinking{Type: "enabled", BudgetTokens: 2048},
+	}
+
+	got, err := claude.ConvertToChatOpenaiRequest(request)
+	require.Nil(t, err)
+
+	assert.Equal(t, "claude-sonnet-4", got.Model)
+	assert.Equal(t, 1024, got.MaxTokens)
+	assert.Equal(t, []string{"END"}, got.Stop)
+	assert.Equal(t, 0.5, *got.Temperature)
+	assert.Equal(t, 40.0, *got.TopK)
+	require.NotNil(t, got.StreamOptions)
+	assert.True(t, got.StreamOptions.IncludeUsage)
+	require.NotNil(t, got.Reasoning)
+	assert.Equal(t, 2048, got.Reasoning.MaxTokens)
+
+	require.Len(t, got.Messages, 6)
+	assert.Equal(t, types.ChatMessageRoleSystem, got.Messages[0].Role)
+	assert.Equal(t, "be brief\nbe kind", got.Messages[0].Content)
+	assert.Equal(t, "what is the weather", got.Messages[1].Content)
+
+	assistant := got.Messages[2]
+	assert.Equal(t, types.ChatMessageRoleAssistant, assistant.Role)
+	assert.Equal(t, "checking", assistant.Content)
+	require.Len(t, assistant.ToolCalls, 1)
+	assert.Equal(t, "toolu_1", assistant.ToolCalls[0].Id)
+	assert.Equal(t, "weather", assistant.ToolCalls[0].Function.Name)
+	assert.JSONEq(t, `{"city":"Paris"}`, assistant.ToolCalls[0].Function.Arguments)
+
+	assert.Equal(t, types.ChatMessageRoleTool, got.Messages[3].Role)
+	assert.Equal(t, "toolu_1", got.Messages[3].ToolCallID)
+	assert.Equal(t, "sunny", got.Messages[3].Content)
+	assert.Equal(t, "error", got.Messages[4].Content)
+
+	parts, ok := got.Messages[5].Content.([]types.ChatMessagePart)
+	require.True(t, ok)
+	require.Len(t, parts, 1)
+	assert.Equal(t, "data:image/png;base64,aGk=", parts[0].ImageURL.URL)
+
+	require.Len(t, got.Tools, 1)
+	assert.Equal(t, "weather", got.Tools[0].Function.Name)
+	assert.Equal(t, map[string]any{
+		"type":     types.ToolChoiceTypeFunction,
+		"function": map[string]any{"name": "weather"},
+	}, got.ToolChoice)
+}
+
+func TestConvertToChatOpenaiRequestToolChoice(t *testing.T) {
+	tests := []struct {
+		choice string
+		want   any
+	}{
+		{"auto", types.ToolChoiceTypeAuto},
+		{"any", types.ToolChoiceTypeRequired},
+		{"none", types.ToolChoiceTypeNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.choice, func(t *testing.T) {
+			got, err := claude.ConvertToChatOpenaiRequest(&claude.ClaudeRequest{
+				Messages:   []claude.Message{{Role: "user", Content: "hi"}},
+				Tools:      []claude.Tools{{Name: "weather"}},
+				ToolChoice: &claude.ToolChoice{Type: tt.choice},
+			})
+			require.Nil(t, err)
+			assert.Equal(t, tt.want, got.ToolChoice)
+		})
+	}
+}
+
+func TestConvertChatOpenaiToClaude(t *testing.T) {
+	response := &types.ChatCompletionResponse{
+		ID: "chatcmpl-abc",
+		Choices: []types.ChatCompletionChoice{{
+			Message: types.ChatCompletionMessage{
+				Role:             types.ChatMessageRoleAssistant,
+				Content:          "let me check",
+				ReasoningContent: "thinking",
+				ToolCalls: []*types.ChatCompletionToolCalls{{
+					Id:       "call_1",
+					Type:     types.ToolChoiceTypeFunction,
+					Function: &types.ChatCompletionToolCallsFunction{Name: "weather", Arguments: `{"city":"Paris"}`},
+				}},
+			},
+			FinishReason: types.FinishReasonToolCalls,
+		}},
+	}
+	usage := &types.Usage{PromptTokens: 100, CompletionTokens: 20}
+	usage.PromptTokensDetails.CachedTokens = 30
+
+	got := claude.ConvertChatOpenaiToClaude(response, "claude-sonnet-4", usage)
+
+	assert.Equal(t, "msg_abc", got.Id)
+	assert.Equal(t, "claude-sonnet-4", got.Model)
+	assert.Equal(t, claude.FinishReasonToolUse, got.StopReason)
+	assert.Equal(t, claude.Usage{InputTokens: 70, OutputTokens: 20, CacheReadInputTokens: 30}, got.Usage)
+
+	require.Len(t, got.Content, 3)
+	assert.Equal(t, claude.ContentTypeThinking, got.Content[0].Type)
+	assert.Equal(t, "thinking", got.Content[0].Thinking)
+	assert.Equal(t, claude.ContentTypeText, got.Content[1].Type)
+	assert.Equal(t, "let me check", got.Content[1].Text)
+	assert.Equal(t, claude.ContentTypeToolUes, got.Content[2].Type)
+	assert.Equal(t, "call_1", got.Content[2].Id)
+	assert.Equal(t, map[string]any{"city": "Paris"}, got.Content[2].Input)
+}
+
+func TestConvertChatOpenaiToClaudeStopReason(t *testing.T) {
+	tests := map[string]string{
+		types.FinishReasonStop:          claude.FinishReasonEndTurn,
+		types.FinishReasonLength:        "max_tokens",
+		types.FinishReasonContentFilter: "refusal",
+	}
+
+	for reason, want := range tests {
+		response := &types.ChatCompletionResponse{
+			Choices: []types.ChatCompletionChoice{{Message: types.ChatCompletionMessage{Content: "hi"}, FinishReason: reason}},
+		}
+		assert.Equal(t, want, claude.ConvertChatOpenaiToClaude(response, "m", nil).StopReason, reason)
+	}
+}
+
+type fakeStream struct {
+	chunks []string
+	err    error
+}
+
+func (s *fakeStream) Recv() (<-chan string, <-chan error) {
+	dataChan := make(chan string)
+	errChan := make(chan error)
+
+	go func() {
+		for _, chunk := range s.chunks {
+			dataChan <- chunk
+		}
+		if s.err != nil {
+			errChan <- s.err
+			return
+		}
+		errChan <- io.EOF
+	}()
+
+	return dataChan, errChan
+}
+
+func (s *fakeStream) Close() {}
+
+type claudeStreamEvent struct {
+	Event string
+	Data  map[string]any
+}
+
+func readClaudeStream(t *testing.T, stream *fakeStream, usage *types.Usage) []claudeStreamEvent {
+	t.Helper()
+
+	translated := claude.NewChatOpenaiToClaudeStream(stream, "claude-sonnet-4", func() *types.Usage { return usage })
+	dataChan, errChan := translated.Recv()
+
+	var events []claudeStreamEvent
+	for {
+		select {
+		case data := <-dataChan:
+			lines := strings.SplitN(strings.TrimSpace(data), "\n", 2)
+			require.Len(t, lines, 2)
+			event := claudeStreamEvent{Event: strings.TrimPrefix(lines[0], "event: ")}
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &event.Data))
+			events = append(events, event)
+		case err := <-errChan:
+			require.ErrorIs(t, err, io.EOF)
+			return events
+		}
+	}
+}
+
+func chunk(t *testing.T, delta types.ChatCompletionStreamChoiceDelta, finishReason any) string {
+	t.Helper()
+
+	data, err := json.Marshal(types.ChatCompletionStreamResponse{
+		Choices: []types.ChatCompletionStreamChoice{{Delta: delta, FinishReason: finishReason}},
+	})
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestChatOpenaiToClaudeStream(t *testing.T) {
+	stream := &fakeStream{chunks: []string{
+		chunk(t, types.ChatCompletionStreamChoiceDelta{ReasoningContent: "hmm"}, nil),
+		chunk(t, types.ChatCompletionStreamChoiceDelta{Content: "Hello"}, nil),
+		chunk(t, types.ChatCompletionStreamChoiceDelta{Content: " world"}, nil),
+		chunk(t, types.ChatCompletionStreamChoiceDelta{ToolCalls: []*types.ChatCompletionToolCalls{{
+			Id:       "call_1",
+			Function: &types.ChatCompletionToolCallsFunction{Name: "weather"},
+		}}}, nil),
+		chunk(t, types.ChatCompletionStreamChoiceDelta{ToolCalls: []*types.ChatCompletionToolCalls{{
+			Function: &types.ChatCompletionToolCallsFunction{Arguments: `{"city":"Paris"}`},
+		}}}, types.FinishReasonToolCalls),
+	}}
+	usage := &types.Usage{PromptTokens: 10, CompletionTokens: 5}
+
+	events := readClaudeStream(t, stream, usage)
+
+	names := make([]string, 0, len(events))
+	for _, event := range events {
+		names = append(names, event.Event)
+	}
+	assert.Equal(t, []string{
+		"message_start",
+		"content_block_start", "content_block_delta", "content_block_delta", "content_block_stop",
+		"content_block_start", "content_block_delta", "content_block_delta", "content_block_stop",
+		"content_block_start", "content_block_delta", "content_block_stop",
+		"message_delta", "message_stop",
+	}, names)
+
+	assert.Equal(t, "claude-sonnet-4", events[0].Data["message"].(map[string]any)["model"])
+	assert.Equal(t, "thinking", events[1].Data["content_block"].(map[string]any)["type"])
+	assert.Equal(t, "signature_delta", events[3].Data["delta"].(map[string]any)["type"])
+	assert.Equal(t, "Hello", events[6].Data["delta"].(map[string]any)["text"])
+	assert.Equal(t, float64(1), events[6].Data["index"])
+
+	toolBlock := events[9].Data["content_block"].(map[string]any)
+	assert.Equal(t, "tool_use", toolBlock["type"])
+	assert.Equal(t, "call_1", toolBlock["id"])
+	assert.Equal(t, "weather", toolBlock["name"])
+	assert.Equal(t, `{"city":"Paris"}`, events[10].Data["delta"].(map[string]any)["partial_json"])
+
+	messageDelta := events[12].Data
+	assert.Equal(t, claude.FinishReasonToolUse, messageDelta["delta"].(map[string]any)["stop_reason"])
+	assert.Equal(t, float64(5), messageDelta["usage"].(map[string]any)["output_tokens"])
+}
+
+func TestChatOpenaiToClaudeStreamError(t *testing.T) {
+	stream := &fakeStream{
+		chunks: []string{chunk(t, types.ChatCompletionStreamChoiceDelta{Content: "Hel"}, nil)},
+		err:    errors.New("upstream closed"),
+	}
+
+	events := readClaudeStream(t, stream, &types.Usage{})
+
+	last := events[len(events)-1]
+	assert.Equal(t, "error", last.Event)
+	assert.Equal(t, "upstream closed", last.Data["error"].(map[string]any)["message"])
+}
@@ -34,6 +34,7 @@ func getConfig() base.ProviderConfig {
 		ChatCompletions: "/v2/chat",
 		ModelList:       "/v1/models",
 		Rerank:          "/v1/rerank",
+		Embeddings:      "/v2/embed",
 	}
 }
 
@@ -0,0 +1,88 @@
+package cohere
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/types"
+)
+
+func (p *CohereProvider) CreateEmbeddings(request *types.EmbeddingRequest) (*types.EmbeddingResponse, *types.OpenAIErrorWithStatusCode) {
+	input := request.ParseInput()
+	if len(input) == 0 {
+		return nil, common.StringErrorWrapperLocal("input is required", "invalid_request", http.StatusBadRequest)
+	}
+
+	url, errWithCode := p.GetSupportedAPIUri(config.RelayModeEmbeddings)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	// 获取请求地址
+	fullRequestURL := p.GetFullRequestURL(url)
+
+	// 获取请求头
+	headers := p.GetRequestHeaders()
+
+	embedReq := ConvertEmbeddingRequest(request, input)
+
+	// 创建请求
+	req, err := p.Requester.NewRequest(http.MethodPost, fullRequestURL, p.Requester.WithBody(embedReq), p.Requester.WithHeader(headers))
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+	defer req.Body.Close()
+
+	cResponse := &EmbedResponse{}
+
+	// 发送请求
+	_, errWithCode = p.Requester.SendRequest(req, cResponse, false)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	response := ConvertToEmbeddingOpenai(cResponse, request, p.GetUsage())
+
+	return response, nil
+}
+
+// ConvertEmbeddingRequest 转换为 Cohere 的 embed 请求，input_type 为必填参数，默认为 search_document
+func ConvertEmbeddingRequest(request *types.EmbeddingRequest, input []string) *EmbedRequest {
+	inputType := request.InputType
+	if inputType == "" {
+		inputType = "search_document"
+	}
+
+	return &EmbedRequest{
+		Model:           request.Model,
+		Texts:           input,
+		InputType:       inputType,
+		EmbeddingTypes:  []string{"float"},
+		OutputDimension: request.Dimensions,
+	}
+}
+
+// ConvertToEmbeddingOpenai 转换为 OpenAI 格式的响应，没有返回用量时使用本地计算的输入 token
+func ConvertToEmbeddingOpenai(response *EmbedResponse, request *types.EmbeddingRequest, usage *types.Usage) *types.EmbeddingResponse {
+	openaiResponse := &types.EmbeddingResponse{
+		Object: "list",
+		Model:  request.Model,
+		Data:   make([]types.Embedding, 0, len(response.Embeddings.Float)),
+	}
+
+	for i, embedding := range response.Embeddings.Float {
+		openaiResponse.Data = append(openaiResponse.Data, types.Embedding{
+			Object:    "embedding",
+			Index:     i,
+			Embedding: request.FormatEmbedding(embedding),
+		})
+	}
+
+	if response.Meta != nil && response.Meta.BilledUnits != nil && response.Meta.BilledUnits.InputTokens > 0 {
+		usage.PromptTokens = response.Meta.BilledUnits.InputTokens
+	}
+	usage.TotalTokens = usage.PromptTokens
+	openaiResponse.Usage = usage
+
+	return openaiResponse
+}
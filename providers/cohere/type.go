@@ -136,3 +136,20 @@ type RerankResponseResultsItem struct {
 	Index          int                      `json:"index"`
 	RelevanceScore float64                  `json:"relevance_score"`
 }
+
+type EmbedRequest struct {
+	Model           string   `json:"model,omitempty"`
+	Texts           []string `json:"texts"`
+	InputType       string   `json:"input_type"`
+	EmbeddingTypes  []string `json:"embedding_types,omitempty"`
+	OutputDimension int      `json:"output_dimension,omitempty"`
+	Truncate        string   `json:"truncate,omitempty"`
+}
+
+type EmbedResponse struct {
+	Id         string `json:"id,omitempty"`
+	Embeddings struct {
+		Float [][]float64 `json:"float,omitempty"`
+	} `json:"embeddings"`
+	Meta *Usage `json:"meta,omitempty"`
+}
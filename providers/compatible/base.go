@@ -0,0 +1,68 @@
+package compatible
+
+import (
+	"net/http"
+	"one-api/common/requester"
+	"one-api/model"
+	"one-api/providers/base"
+	"one-api/providers/openai"
+)
+
+// 定义供应商工厂
+type CompatibleProviderFactory struct{}
+
+// 创建 CompatibleProvider
+// 与 OpenAI 接口基本兼容的供应商，差异通过渠道插件中的 compatible 配置描述
+func (f CompatibleProviderFactory) Create(channel *model.Channel) base.ProviderInterface {
+	var plugin map[string]interface{}
+	if channel.Plugin != nil {
+		plugin = channel.Plugin.Data()["compatible"]
+	}
+	rules := ParseRules(plugin)
+
+	return &CompatibleProvider{
+		OpenAIProvider: openai.OpenAIProvider{
+			BaseProvider: base.BaseProvider{
+				Config:    getConfig(rules),
+				Channel:   channel,
+				Requester: requester.NewHTTPRequester(*channel.Proxy, openai.RequestErrorHandle),
+			},
+			SupportStreamOptions: rules.StreamUsage,
+		},
+		Rules: rules,
+	}
+}
+
+func getConfig(rules *Rules) base.ProviderConfig {
+	config := base.ProviderConfig{
+		BaseURL:         "",
+		ChatCompletions: "/v1/chat/completions",
+		Embeddings:      "/v1/embeddings",
+		ModelList:       "/v1/models",
+	}
+
+	if rules.ChatPath != "" {
+		config.ChatCompletions = rules.ChatPath
+	}
+	if rules.EmbeddingsPath != "" {
+		config.Embeddings = rules.EmbeddingsPath
+	}
+
+	return config
+}
+
+type CompatibleProvider struct {
+	openai.OpenAIProvider
+
+	Rules *Rules
+}
+
+// setAuthHeader 按规则替换鉴权请求头
+func (p *CompatibleProvider) setAuthHeader(req *http.Request) {
+	if p.Rules.AuthHeader == "Authorization" && p.Rules.AuthPrefix == "Bearer " {
+		return
+	}
+
+	req.Header.Del("Authorization")
+	req.Header.Set(p.Rules.AuthHeader, p.Rules.AuthPrefix+p.Channel.Key)
+}
@@ -0,0 +1,165 @@
+package compatible
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/requester"
+	"one-api/providers/openai"
+	"one-api/types"
+)
+
+type CompatibleStreamHandler struct {
+	Rules   *Rules
+	Handler *openai.OpenAIStreamHandler
+}
+
+func (p *CompatibleProvider) CreateChatCompletion(request *types.ChatCompletionRequest) (*types.ChatCompletionResponse, *types.OpenAIErrorWithStatusCode) {
+	req, errWithCode := p.getRequest(config.RelayModeChatCompletions, request.Model, request)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+	defer req.Body.Close()
+
+	rawResponse := make(map[string]interface{})
+	// 发送请求
+	_, errWithCode = p.Requester.SendRequest(req, &rawResponse, false)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	response := &openai.OpenAIProviderChatResponse{}
+	if err := convertMap(p.Rules.ConvertResponse(rawResponse), response); err != nil {
+		return nil, common.ErrorWrapper(err, "decode_response_failed", http.StatusInternalServerError)
+	}
+
+	// 检测是否错误
+	openaiErr := openai.ErrorHandle(&response.OpenAIErrorResponse)
+	if openaiErr != nil {
+		return nil, &types.OpenAIErrorWithStatusCode{
+			OpenAIError: *openaiErr,
+			StatusCode:  http.StatusBadRequest,
+		}
+	}
+
+	if response.Usage == nil || response.Usage.CompletionTokens == 0 {
+		response.Usage = &types.Usage{
+			PromptTokens: p.Usage.PromptTokens,
+		}
+		// 那么需要计算
+		response.Usage.CompletionTokens = common.CountTokenText(response.GetContent(), request.Model)
+		response.Usage.TotalTokens = response.Usage.PromptTokens + response.Usage.CompletionTokens
+	}
+
+	*p.Usage = *response.Usage
+
+	return &response.ChatCompletionResponse, nil
+}
+
+func (p *CompatibleProvider) CreateChatCompletionStream(request *types.ChatCompletionRequest) (requester.StreamReaderInterface[string], *types.OpenAIErrorWithStatusCode) {
+	streamOptions := request.StreamOptions
+	// 如果支持流式返回Usage 则需要更改配置：
+	if p.SupportStreamOptions {
+		request.StreamOptions = &types.StreamOptions{
+			IncludeUsage: true,
+		}
+	} else {
+		// 避免误传导致报错
+		request.StreamOptions = nil
+	}
+
+	req, errWithCode := p.getRequest(config.RelayModeChatCompletions, request.Model, request)
+	// 恢复原来的配置
+	request.StreamOptions = streamOptions
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+	defer req.Body.Close()
+
+	// 发送请求
+	resp, errWithCode := p.Requester.SendRequestRaw(req)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	chatHandler := &CompatibleStreamHandler{
+		Rules: p.Rules,
+		Handler: &openai.OpenAIStreamHandler{
+			Usage:     p.Usage,
+			ModelName: request.Model,
+		},
+	}
+
+	return requester.RequestStream(p.Requester, resp, chatHandler.HandlerChatStream)
+}
+
+func (p *CompatibleProvider) CreateEmbeddings(request *types.EmbeddingRequest) (*types.EmbeddingResponse, *types.OpenAIErrorWithStatusCode) {
+	req, errWithCode := p.getRequest(config.RelayModeEmbeddings, request.Model, request)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+	defer req.Body.Close()
+
+	response := &openai.OpenAIProviderEmbeddingsResponse{}
+	// 发送请求
+	_, errWithCode = p.Requester.SendRequest(req, response, false)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	openaiErr := openai.ErrorHandle(&response.OpenAIErrorResponse)
+	if openaiErr != nil {
+		return nil, &types.OpenAIErrorWithStatusCode{
+			OpenAIError: *openaiErr,
+			StatusCode:  http.StatusBadRequest,
+		}
+	}
+
+	if response.Usage != nil {
+		*p.Usage = *response.Usage
+	}
+
+	return &response.EmbeddingResponse, nil
+}
+
+// getRequest 按规则转换请求参数后创建请求，并替换鉴权请求头
+func (p *CompatibleProvider) getRequest(relayMode int, modelName string, request any) (*http.Request, *types.OpenAIErrorWithStatusCode) {
+	body := make(map[string]interface{})
+	if err := convertMap(request, &body); err != nil {
+		return nil, common.ErrorWrapper(err, "marshal_request_failed", http.StatusInternalServerError)
+	}
+
+	req, errWithCode := p.GetRequestTextBody(relayMode, modelName, p.Rules.ConvertRequest(body))
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+	p.setAuthHeader(req)
+
+	return req, nil
+}
+
+// HandlerChatStream 按规则转换数据块后交给 OpenAI 的流处理
+func (h *CompatibleStreamHandler) HandlerChatStream(rawLine *[]byte, dataChan chan string, errChan chan error) {
+	if bytes.HasPrefix(*rawLine, []byte("data:")) {
+		data := bytes.TrimSpace((*rawLine)[5:])
+		chunk := make(map[string]interface{})
+		if !bytes.Equal(data, []byte("[DONE]")) && json.Unmarshal(data, &chunk) == nil {
+			if converted, err := json.Marshal(h.Rules.ConvertResponse(chunk)); err == nil {
+				*rawLine = append([]byte("data: "), converted...)
+			}
+		}
+	}
+
+	h.Handler.HandlerChatStream(rawLine, dataChan, errChan)
+}
+
+func convertMap(from any, to any) error {
+	data, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, to)
+}
@@ -0,0 +1,183 @@
+package compatible
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Rules 渠道插件 compatible 中配置的请求和响应映射规则
+type Rules struct {
+	// 对话和向量接口地址，为空时使用 OpenAI 的默认地址
+	ChatPath       string
+	EmbeddingsPath string
+	// 鉴权请求头及其前缀，默认 Authorization: Bearer {key}
+	AuthHeader string
+	AuthPrefix string
+	// 请求参数重命名，如 {"max_tokens": "max_output_tokens"}
+	RenameParams map[string]string
+	// 发送前删除的请求参数
+	DropParams []string
+	// 响应中用量所在的位置，如 x_groq.usage
+	UsagePath string
+	// 响应中思考内容的字段名，会转换为 reasoning_content
+	ReasoningField string
+	// 流式请求是否发送 stream_options 以获取用量
+	StreamUsage bool
+}
+
+// ParseRules 解析插件中的映射规则，字符串形式的 JSON 和逗号分隔的列表也可以使用
+func ParseRules(plugin map[string]interface{}) *Rules {
+	rules := &Rules{
+		AuthHeader:   "Authorization",
+		AuthPrefix:   "Bearer ",
+		RenameParams: map[string]string{},
+	}
+
+	if plugin == nil {
+		return rules
+	}
+
+	rules.ChatPath = getString(plugin, "chat_path")
+	rules.EmbeddingsPath = getString(plugin, "embeddings_path")
+	rules.UsagePath = getString(plugin, "usage_path")
+	rules.ReasoningField = getString(plugin, "reasoning_field")
+
+	if authHeader := getString(plugin, "auth_header"); authHeader != "" {
+		rules.AuthHeader = authHeader
+		// 自定义请求头时默认不加前缀
+		rules.AuthPrefix = ""
+	}
+	if authPrefix, exists := plugin["auth_prefix"].(string); exists {
+		rules.AuthPrefix = authPrefix
+	}
+
+	switch value := plugin["rename_params"].(type) {
+	case string:
+		if value != "" {
+			json.Unmarshal([]byte(value), &rules.RenameParams)
+		}
+	case map[string]interface{}:
+		for from, to := range value {
+			if toName, ok := to.(string); ok {
+				rules.RenameParams[from] = toName
+			}
+		}
+	}
+
+	switch value := plugin["drop_params"].(type) {
+	case string:
+		for _, param := range strings.Split(value, ",") {
+			if param = strings.TrimSpace(param); param != "" {
+				rules.DropParams = append(rules.DropParams, param)
+			}
+		}
+	case []interface{}:
+		for _, param := range value {
+			if name, ok := param.(string); ok && name != "" {
+				rules.DropParams = append(rules.DropParams, name)
+			}
+		}
+	}
+
+	if streamUsage, ok := plugin["stream_usage"].(bool); ok {
+		rules.StreamUsage = streamUsage
+	}
+
+	return rules
+}
+
+// ConvertRequest 按规则删除和重命名请求参数
+func (r *Rules) ConvertRequest(body map[string]interface{}) map[string]interface{} {
+	for _, param := range r.DropParams {
+		delete(body, param)
+	}
+
+	for from, to := range r.RenameParams {
+		value, exists := body[from]
+		if !exists || to == "" {
+			continue
+		}
+		delete(body, from)
+		body[to] = value
+	}
+
+	return body
+}
+
+// ConvertResponse 将用量移动到 usage，并将思考内容转换为 reasoning_content，同时适用于流式数据块
+func (r *Rules) ConvertResponse(body map[string]interface{}) map[string]interface{} {
+	if r.UsagePath != "" {
+		if usage, ok := getPath(body, r.UsagePath).(map[string]interface{}); ok {
+			body["usage"] = usage
+		}
+	}
+
+	if usage, ok := body["usage"].(map[string]interface{}); ok {
+		normalizeUsage(usage)
+	}
+
+	if r.ReasoningField == "" || r.ReasoningField == "reasoning_content" {
+		return body
+	}
+
+	choices, _ := body["choices"].([]interface{})
+	for _, choice := range choices {
+		choiceMap, ok := choice.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for _, key := range []string{"message", "delta"} {
+			message, ok := choiceMap[key].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if reasoning, exists := message[r.ReasoningField]; exists {
+				delete(message, r.ReasoningField)
+				message["reasoning_content"] = reasoning
+			}
+		}
+	}
+
+	return body
+}
+
+// normalizeUsage 兼容 input_tokens 和 output_tokens 形式的用量
+func normalizeUsage(usage map[string]interface{}) {
+	if _, exists := usage["prompt_tokens"]; !exists {
+		if inputTokens, ok := usage["input_tokens"]; ok {
+			usage["prompt_tokens"] = inputTokens
+		}
+	}
+
+	if _, exists := usage["completion_tokens"]; !exists {
+		if outputTokens, ok := usage["output_tokens"]; ok {
+			usage["completion_tokens"] = outputTokens
+		}
+	}
+
+	if _, exists := usage["total_tokens"]; !exists {
+		promptTokens, _ := usage["prompt_tokens"].(float64)
+		completionTokens, _ := usage["completion_tokens"].(float64)
+		usage["total_tokens"] = promptTokens + completionTokens
+	}
+}
+
+// getPath 按点分隔的路径获取值，如 x_groq.usage
+func getPath(body map[string]interface{}, path string) interface{} {
+	var current interface{} = body
+	for _, key := range strings.Split(path, ".") {
+		currentMap, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = currentMap[key]
+	}
+
+	return current
+}
+
+func getString(plugin map[string]interface{}, key string) string {
+	value, _ := plugin[key].(string)
+	return strings.TrimSpace(value)
+}
@@ -0,0 +1,220 @@
+package compatible_test
+
+import (
+	"one-api/providers/compatible"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		plugin map[string]interface{}
+		want   *compatible.Rules
+	}{
+		{
+			name:   "empty plugin uses openai defaults",
+			plugin: nil,
+			want: &compatible.Rules{
+				AuthHeader:   "Authorization",
+				AuthPrefix:   "Bearer ",
+				RenameParams: map[string]string{},
+			},
+		},
+		{
+			name: "string values from plugin form",
+			plugin: map[string]interface{}{
+				"chat_path":       " /api/chat ",
+				"auth_header":     "x-api-key",
+				"rename_params":   `{"max_tokens":"max_output_tokens"}`,
+				"drop_params":     "logprobs, user,,",
+				"usage_path":      "x_groq.usage",
+				"reasoning_field": "reasoning",
+				"stream_usage":    true,
+			},
+			want: &compatible.Rules{
+				ChatPath:       "/api/chat",
+				AuthHeader:     "x-api-key",
+				AuthPrefix:     "",
+				RenameParams:   map[string]string{"max_tokens": "max_output_tokens"},
+				DropParams:     []string{"logprobs", "user"},
+				UsagePath:      "x_groq.usage",
+				ReasoningField: "reasoning",
+				StreamUsage:    true,
+			},
+		},
+		{
+			name: "native json values and custom prefix",
+			plugin: map[string]interface{}{
+				"auth_header":   "api-key",
+				"auth_prefix":   "Token ",
+				"rename_params": map[string]interface{}{"stop": "stop_sequences", "bad": 1},
+				"drop_params":   []interface{}{"seed", 1, ""},
+			},
+			want: &compatible.Rules{
+				AuthHeader:   "api-key",
+				AuthPrefix:   "Token ",
+				RenameParams: map[string]string{"stop": "stop_sequences"},
+				DropParams:   []string{"seed"},
+			},
+		},
+		{
+			name: "invalid rename json is ignored",
+			plugin: map[string]interface{}{
+				"rename_params": "{",
+			},
+			want: &compatible.Rules{
+				AuthHeader:   "Authorization",
+				AuthPrefix:   "Bearer ",
+				RenameParams: map[string]string{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, compatible.ParseRules(tt.plugin))
+		})
+	}
+}
+
+func TestConvertRequest(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules *compatible.Rules
+		body  map[string]interface{}
+		want  map[string]interface{}
+	}{
+		{
+			name:  "no rules",
+			rules: compatible.ParseRules(nil),
+			body:  map[string]interface{}{"model": "m", "max_tokens": 10.0},
+			want:  map[string]interface{}{"model": "m", "max_tokens": 10.0},
+		},
+		{
+			name: "rename and drop",
+			rules: &compatible.Rules{
+				RenameParams: map[string]string{"max_tokens": "max_output_tokens", "stop": "stop_sequences"},
+				DropParams:   []string{"logprobs", "user"},
+			},
+			body: map[string]interface{}{"model": "m", "max_tokens": 10.0, "logprobs": true, "user": "u"},
+			want: map[string]interface{}{"model": "m", "max_output_tokens": 10.0},
+		},
+		{
+			name: "drop before rename",
+			rules: &compatible.Rules{
+				RenameParams: map[string]string{"seed": "random_seed"},
+				DropParams:   []string{"seed"},
+			},
+			body: map[string]interface{}{"seed": 1.0},
+			want: map[string]interface{}{},
+		},
+		{
+			name: "empty target keeps param",
+			rules: &compatible.Rules{
+				RenameParams: map[string]string{"top_p": ""},
+			},
+			body: map[string]interface{}{"top_p": 0.5},
+			want: map[string]interface{}{"top_p": 0.5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.rules.ConvertRequest(tt.body))
+		})
+	}
+}
+
+func TestConvertResponse(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules *compatible.Rules
+		body  map[string]interface{}
+		want  map[string]interface{}
+	}{
+		{
+			name:  "openai usage unchanged",
+			rules: compatible.ParseRules(nil),
+			body: map[string]interface{}{
+				"usage": map[string]interface{}{"prompt_tokens": 1.0, "completion_tokens": 2.0, "total_tokens": 3.0},
+			},
+			want: map[string]interface{}{
+				"usage": map[string]interface{}{"prompt_tokens": 1.0, "completion_tokens": 2.0, "total_tokens": 3.0},
+			},
+		},
+		{
+			name:  "usage from nested path",
+			rules: &compatible.Rules{UsagePath: "x_groq.usage"},
+			body: map[string]interface{}{
+				"x_groq": map[string]interface{}{
+					"usage": map[string]interface{}{"prompt_tokens": 1.0, "completion_tokens": 2.0, "total_tokens": 3.0},
+				},
+			},
+			want: map[string]interface{}{
+				"x_groq": map[string]interface{}{
+					"usage": map[string]interface{}{"prompt_tokens": 1.0, "completion_tokens": 2.0, "total_tokens": 3.0},
+				},
+				"usage": map[string]interface{}{"prompt_tokens": 1.0, "completion_tokens": 2.0, "total_tokens": 3.0},
+			},
+		},
+		{
+			name:  "missing usage path",
+			rules: &compatible.Rules{UsagePath: "meta.usage"},
+			body:  map[string]interface{}{"meta": "x"},
+			want:  map[string]interface{}{"meta": "x"},
+		},
+		{
+			name:  "input and output tokens",
+			rules: compatible.ParseRules(nil),
+			body: map[string]interface{}{
+				"usage": map[string]interface{}{"input_tokens": 4.0, "output_tokens": 5.0},
+			},
+			want: map[string]interface{}{
+				"usage": map[string]interface{}{
+					"input_tokens": 4.0, "output_tokens": 5.0,
+					"prompt_tokens": 4.0, "completion_tokens": 5.0, "total_tokens": 9.0,
+				},
+			},
+		},
+		{
+			name:  "reasoning field in message and delta",
+			rules: &compatible.Rules{ReasoningField: "thinking"},
+			body: map[string]interface{}{
+				"choices": []interface{}{
+					map[string]interface{}{"message": map[string]interface{}{"content": "a", "thinking": "t"}},
+					map[string]interface{}{"delta": map[string]interface{}{"thinking": "d"}},
+					"bad",
+				},
+			},
+			want: map[string]interface{}{
+				"choices": []interface{}{
+					map[string]interface{}{"message": map[string]interface{}{"content": "a", "reasoning_content": "t"}},
+					map[string]interface{}{"delta": map[string]interface{}{"reasoning_content": "d"}},
+					"bad",
+				},
+			},
+		},
+		{
+			name:  "reasoning_content needs no conversion",
+			rules: &compatible.Rules{ReasoningField: "reasoning_content"},
+			body: map[string]interface{}{
+				"choices": []interface{}{
+					map[string]interface{}{"delta": map[string]interface{}{"reasoning_content": "d"}},
+				},
+			},
+			want: map[string]interface{}{
+				"choices": []interface{}{
+					map[string]interface{}{"delta": map[string]interface{}{"reasoning_content": "d"}},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.rules.ConvertResponse(tt.body))
+		})
+	}
+}
@@ -65,6 +65,7 @@ func getConfig(version string) base.ProviderConfig {
 	return base.ProviderConfig{
 		BaseURL:           "https://generativelanguage.googleapis.com",
 		ChatCompletions:   fmt.Sprintf("/%s/chat/completions", version),
+		Embeddings:        fmt.Sprintf("/%s/embeddings", version),
 		ModelList:         "/models",
 		ImagesGenerations: "1",
 	}
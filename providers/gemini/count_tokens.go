@@ -0,0 +1,42 @@
+package gemini
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/types"
+)
+
+// countTokensGenerateRequest generateContentRequest 需要带上 models/ 前缀的模型名称
+type countTokensGenerateRequest struct {
+	Model string `json:"model"`
+	*GeminiChatRequest
+}
+
+func (p *GeminiProvider) CountGeminiTokens(request *GeminiCountTokensRequest) (*GeminiCountTokensResponse, *types.OpenAIErrorWithStatusCode) {
+	body := map[string]any{}
+	if request.GenerateContentRequest != nil {
+		body["generateContentRequest"] = &countTokensGenerateRequest{
+			Model:             "models/" + request.Model,
+			GeminiChatRequest: request.GenerateContentRequest,
+		}
+	} else {
+		body["contents"] = request.Contents
+	}
+
+	fullRequestURL := p.GetFullRequestURL("countTokens", request.Model)
+	headers := p.GetRequestHeaders()
+
+	req, err := p.Requester.NewRequest(http.MethodPost, fullRequestURL, p.Requester.WithBody(body), p.Requester.WithHeader(headers))
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+	defer req.Body.Close()
+
+	response := &GeminiCountTokensResponse{}
+	_, errWithCode := p.Requester.SendRequest(req, response, false)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	return response, nil
+}
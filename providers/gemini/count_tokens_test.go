@@ -0,0 +1,60 @@
+package gemini_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common/requester"
+	"one-api/common/utils"
+	"one-api/model"
+	"one-api/providers/gemini"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountGeminiTokens(t *testing.T) {
+	requester.HTTPClient = &http.Client{}
+
+	var gotPath, gotKey string
+	var gotBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotKey = r.Header.Get("x-goog-api-key")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&gotBody))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"totalTokens":42,"cachedContentTokenCount":8}`))
+	}))
+	defer server.Close()
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/gemini/v1beta/models/gemini-2.5-pro:countTokens", nil)
+
+	provider := gemini.GeminiProviderFactory{}.Create(&model.Channel{
+		Key:     "test-key",
+		BaseURL: utils.GetPointer(server.URL),
+		Proxy:   utils.GetPointer(""),
+	}).(*gemini.GeminiProvider)
+	provider.SetContext(c)
+
+	response, errWithCode := provider.CountGeminiTokens(&gemini.GeminiCountTokensRequest{
+		Model: "gemini-2.5-pro",
+		GenerateContentRequest: &gemini.GeminiChatRequest{
+			Contents: []gemini.GeminiChatContent{{Role: "user", Parts: []gemini.GeminiPart{{Text: "hello"}}}},
+		},
+	})
+	require.Nil(t, errWithCode)
+
+	assert.Equal(t, "/v1beta/models/gemini-2.5-pro:countTokens", gotPath)
+	assert.Equal(t, "test-key", gotKey)
+	// generateContentRequest 需要带上模型名称
+	generateRequest, ok := gotBody["generateContentRequest"].(map[string]any)
+	require.True(t, ok)
+	assert.Equal(t, "models/gemini-2.5-pro", generateRequest["model"])
+	assert.NotEmpty(t, generateRequest["contents"])
+
+	assert.Equal(t, 42, response.TotalTokens)
+	assert.Equal(t, 8, response.CachedContentTokenCount)
+}
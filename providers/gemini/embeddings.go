@@ -0,0 +1,105 @@
+package gemini
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/types"
+	"strings"
+)
+
+type GeminiEmbeddingRequest struct {
+	Requests []GeminiEmbedContentRequest `json:"requests"`
+}
+
+type GeminiEmbedContentRequest struct {
+	Model                string            `json:"model"`
+	Content              GeminiChatContent `json:"content"`
+	TaskType             string            `json:"taskType,omitempty"`
+	OutputDimensionality int               `json:"outputDimensionality,omitempty"`
+}
+
+type GeminiEmbeddingResponse struct {
+	Embeddings []GeminiEmbeddingValues `json:"embeddings"`
+}
+
+type GeminiEmbeddingValues struct {
+	Values []float64 `json:"values"`
+}
+
+func (p *GeminiProvider) CreateEmbeddings(request *types.EmbeddingRequest) (*types.EmbeddingResponse, *types.OpenAIErrorWithStatusCode) {
+	if p.UseOpenaiAPI {
+		return p.OpenAIProvider.CreateEmbeddings(request)
+	}
+
+	input := request.ParseInput()
+	if len(input) == 0 {
+		return nil, common.StringErrorWrapperLocal("input is required", "invalid_request", http.StatusBadRequest)
+	}
+
+	geminiRequest := &GeminiEmbeddingRequest{
+		Requests: make([]GeminiEmbedContentRequest, 0, len(input)),
+	}
+	for _, text := range input {
+		geminiRequest.Requests = append(geminiRequest.Requests, GeminiEmbedContentRequest{
+			Model: "models/" + request.Model,
+			Content: GeminiChatContent{
+				Parts: []GeminiPart{{Text: text}},
+			},
+			TaskType:             ConvertEmbeddingTaskType(request.InputType),
+			OutputDimensionality: request.Dimensions,
+		})
+	}
+
+	fullRequestURL := p.GetFullRequestURL("batchEmbedContents", request.Model)
+	headers := p.GetRequestHeaders()
+
+	req, err := p.Requester.NewRequest(http.MethodPost, fullRequestURL, p.Requester.WithBody(geminiRequest), p.Requester.WithHeader(headers))
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+	defer req.Body.Close()
+
+	geminiResponse := &GeminiEmbeddingResponse{}
+	_, errWithCode := p.Requester.SendRequest(req, geminiResponse, false)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	response := &types.EmbeddingResponse{
+		Object: "list",
+		Model:  request.Model,
+		Data:   make([]types.Embedding, 0, len(geminiResponse.Embeddings)),
+	}
+	for i, embedding := range geminiResponse.Embeddings {
+		response.Data = append(response.Data, types.Embedding{
+			Object:    "embedding",
+			Index:     i,
+			Embedding: request.FormatEmbedding(embedding.Values),
+		})
+	}
+
+	// Gemini 不返回用量，使用本地计算的输入 token
+	usage := p.GetUsage()
+	usage.TotalTokens = usage.PromptTokens
+	response.Usage = usage
+
+	return response, nil
+}
+
+// ConvertEmbeddingTaskType 将 input_type 转换为 Gemini 的 taskType，已经是 taskType 的直接使用
+func ConvertEmbeddingTaskType(inputType string) string {
+	switch strings.ToLower(inputType) {
+	case "":
+		return ""
+	case "search_document", "document":
+		return "RETRIEVAL_DOCUMENT"
+	case "search_query", "query":
+		return "RETRIEVAL_QUERY"
+	case "classification":
+		return "CLASSIFICATION"
+	case "clustering":
+		return "CLUSTERING"
+	default:
+		return strings.ToUpper(inputType)
+	}
+}
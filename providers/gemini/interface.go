@@ -11,3 +11,9 @@ type GeminiChatInterface interface {
 	CreateGeminiChat(request *GeminiChatRequest) (*GeminiChatResponse, *types.OpenAIErrorWithStatusCode)
 	CreateGeminiChatStream(request *GeminiChatRequest) (requester.StreamReaderInterface[string], *types.OpenAIErrorWithStatusCode)
 }
+
+// GeminiCountTokensInterface 原生 Gemini 渠道，countTokens 直接转发给上游
+type GeminiCountTokensInterface interface {
+	base.ProviderInterface
+	CountGeminiTokens(request *GeminiCountTokensRequest) (*GeminiCountTokensResponse, *types.OpenAIErrorWithStatusCode)
+}
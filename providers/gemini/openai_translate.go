@@ -0,0 +1,559 @@
+package gemini
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/common/requester"
+	"one-api/common/utils"
+	"one-api/types"
+	"strings"
+)
+
+// 以下为 Gemini generateContent 与 OpenAI Chat Completions 的反向转换，
+// 用于 /gemini 请求只实现了 ChatInterface 的渠道
+
+// ConvertToChatOpenaiRequest 将 Gemini 请求转换为 OpenAI 聊天请求
+func ConvertToChatOpenaiRequest(request *GeminiChatRequest) (*types.ChatCompletionRequest, *types.OpenAIErrorWithStatusCode) {
+	config := request.GenerationConfig
+	openaiRequest := &types.ChatCompletionRequest{
+		Model:       request.Model,
+		Messages:    make([]types.ChatCompletionMessage, 0, len(request.Contents)+1),
+		Stream:      request.Stream,
+		MaxTokens:   config.MaxOutputTokens,
+		Temperature: config.Temperature,
+		TopP:        config.TopP,
+		TopK:        config.TopK,
+	}
+
+	if config.CandidateCount > 1 {
+		openaiRequest.N = &config.CandidateCount
+	}
+
+	if len(config.StopSequences) > 0 {
+		openaiRequest.Stop = config.StopSequences
+	}
+
+	if config.ResponseMimeType == "application/json" {
+		openaiRequest.ResponseFormat = &types.ChatCompletionResponseFormat{Type: "json_object"}
+		if config.ResponseSchema != nil {
+			openaiRequest.ResponseFormat = &types.ChatCompletionResponseFormat{
+				Type: "json_schema",
+				JsonSchema: &types.FormatJsonSchema{
+					Name:   "response",
+					Schema: lowerSchemaTypes(config.ResponseSchema),
+				},
+			}
+		}
+	}
+
+	if thinking := config.ThinkingConfig; thinking != nil {
+		if thinking.ThinkingBudget != nil && *thinking.ThinkingBudget > 0 {
+			openaiRequest.Reasoning = &types.ChatReasoning{MaxTokens: *thinking.ThinkingBudget}
+		} else if thinking.ThinkingLevel != "" {
+			openaiRequest.Reasoning = &types.ChatReasoning{Effort: strings.ToLower(thinking.ThinkingLevel)}
+		}
+	}
+
+	if system := geminiSystemText(request.SystemInstruction); system != "" {
+		openaiRequest.Messages = append(openaiRequest.Messages, types.ChatCompletionMessage{
+			Role:    types.ChatMessageRoleSystem,
+			Content: system,
+		})
+	}
+
+	// Gemini 的函数调用没有 id，按函数名依次匹配调用和结果
+	toolCallIds := make(map[string][]string)
+	for _, content := range request.Contents {
+		if content.Role == "model" {
+			openaiRequest.Messages = append(openaiRequest.Messages, convertGeminiModelContent(&content, toolCallIds))
+			continue
+		}
+
+		messages, err := convertGeminiUserContent(&content, toolCallIds)
+		if err != nil {
+			return nil, common.ErrorWrapperLocal(err, "conversion_error", http.StatusBadRequest)
+		}
+		openaiRequest.Messages = append(openaiRequest.Messages, messages...)
+	}
+
+	for _, tool := range request.Tools {
+		// googleSearch、codeExecution 等内置工具只有 Gemini 支持
+		for _, function := range tool.FunctionDeclarations {
+			if function.Parameters != nil {
+				function.Parameters = lowerSchemaTypes(function.Parameters)
+			} else {
+				function.Parameters = map[string]any{"type": "object", "properties": map[string]any{}}
+			}
+			openaiRequest.Tools = append(openaiRequest.Tools, &types.ChatCompletionTool{
+				Type:     types.ToolChoiceTypeFunction,
+				Function: function,
+			})
+		}
+	}
+
+	if request.ToolConfig != nil && request.ToolConfig.FunctionCallingConfig != nil && len(openaiRequest.Tools) > 0 {
+		openaiRequest.ToolChoice = convertGeminiToolChoice(request.ToolConfig.FunctionCallingConfig)
+	}
+
+	if openaiRequest.Stream {
+		openaiRequest.StreamOptions = &types.StreamOptions{IncludeUsage: true}
+	}
+
+	return openaiRequest, nil
+}
+
+func convertGeminiToolChoice(config *GeminiFunctionCallingConfig) any {
+	switch strings.ToUpper(config.Mode) {
+	case "NONE":
+		return types.ToolChoiceTypeNone
+	case "ANY":
+		// 只允许一个函数时指定该函数
+		var names []string
+		if remarshal(config.AllowedFunctionNames, &names) == nil && len(names) == 1 {
+			return map[string]any{
+				"type":     types.ToolChoiceTypeFunction,
+				"function": map[string]any{"name": names[0]},
+			}
+		}
+		return types.ToolChoiceTypeRequired
+	default:
+		return types.ToolChoiceTypeAuto
+	}
+}
+
+// geminiSystemText systemInstruction 可以是字符串，也可以是 Content 结构
+func geminiSystemText(system any) string {
+	switch v := system.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	}
+
+	var content GeminiChatContent
+	if err := remarshal(system, &content); err != nil {
+		return ""
+	}
+
+	texts := make([]string, 0, len(content.Parts))
+	for _, part := range content.Parts {
+		if part.Text != "" {
+			texts = append(texts, part.Text)
+		}
+	}
+
+	return strings.Join(texts, "\n")
+}
+
+// convertGeminiModelContent 思考内容不回传给上游
+func convertGeminiModelContent(content *GeminiChatContent, toolCallIds map[string][]string) types.ChatCompletionMessage {
+	message := types.ChatCompletionMessage{Role: types.ChatMessageRoleAssistant}
+
+	var text strings.Builder
+	for _, part := range content.Parts {
+		switch {
+		case part.Thought:
+			continue
+		case part.FunctionCall != nil:
+			id := "call_" + utils.GetRandomString(24)
+			toolCallIds[part.FunctionCall.Name] = append(toolCallIds[part.FunctionCall.Name], id)
+
+			args := part.FunctionCall.Args
+			if args == nil {
+				args = map[string]any{}
+			}
+			arguments, _ := json.Marshal(args)
+			message.ToolCalls = append(message.ToolCalls, &types.ChatCompletionToolCalls{
+				Id:   id,
+				Type: types.ToolChoiceTypeFunction,
+				Function: &types.ChatCompletionToolCallsFunction{
+					Name:      part.FunctionCall.Name,
+					Arguments: string(arguments),
+				},
+				Index: len(message.ToolCalls),
+			})
+		case part.Text != "":
+			text.WriteString(part.Text)
+		}
+	}
+
+	message.Content = text.String()
+	return message
+}
+
+// convertGeminiUserContent 函数结果拆分为单独的 tool 消息，放在其他内容之前
+func convertGeminiUserContent(content *GeminiChatContent, toolCallIds map[string][]string) ([]types.ChatCompletionMessage, error) {
+	messages := make([]types.ChatCompletionMessage, 0, 1)
+	parts := make([]types.ChatMessagePart, 0, len(content.Parts))
+
+	for _, part := range content.Parts {
+		switch {
+		case part.FunctionResponse != nil:
+			name := part.FunctionResponse.Name
+			id := "call_" + utils.GetRandomString(24)
+			if ids := toolCallIds[name]; len(ids) > 0 {
+				id = ids[0]
+				toolCallIds[name] = ids[1:]
+			}
+
+			response, err := json.Marshal(part.FunctionResponse.Response)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, types.ChatCompletionMessage{
+				Role:       types.ChatMessageRoleTool,
+				Content:    string(response),
+				Name:       &name,
+				ToolCallID: id,
+			})
+		case part.InlineData != nil:
+			if openaiPart := convertGeminiMedia(part.InlineData.MimeType, fmt.Sprintf("data:%s;base64,%s", part.InlineData.MimeType, part.InlineData.Data), part.InlineData.Data); openaiPart != nil {
+				parts = append(parts, *openaiPart)
+			}
+		case part.FileData != nil:
+			if openaiPart := convertGeminiMedia(part.FileData.MimeType, part.FileData.FileUri, ""); openaiPart != nil {
+				parts = append(parts, *openaiPart)
+			}
+		case part.Text != "":
+			parts = append(parts, types.ChatMessagePart{Type: types.ContentTypeText, Text: part.Text})
+		}
+	}
+
+	if len(parts) == 0 {
+		return messages, nil
+	}
+
+	userMessage := types.ChatCompletionMessage{Role: types.ChatMessageRoleUser}
+	if len(parts) == 1 && parts[0].Type == types.ContentTypeText {
+		userMessage.Content = parts[0].Text
+	} else {
+		userMessage.Content = parts
+	}
+
+	return append(messages, userMessage), nil
+}
+
+// convertGeminiMedia 图片转为 image_url，音频转为 input_audio，其他文件需要 base64 数据
+func convertGeminiMedia(mimeType, url, data string) *types.ChatMessagePart {
+	switch {
+	case mimeType == "" || strings.HasPrefix(mimeType, "image/"):
+		return &types.ChatMessagePart{
+			Type:     types.ContentTypeImageURL,
+			ImageURL: &types.ChatMessageImageURL{URL: url},
+		}
+	case strings.HasPrefix(mimeType, "audio/") && data != "":
+		return &types.ChatMessagePart{
+			Type: "input_audio",
+			InputAudio: &types.InputAudio{
+				Data:   data,
+				Format: strings.TrimPrefix(mimeType, "audio/"),
+			},
+		}
+	case data != "":
+		filename := "file"
+		if mimeType == "application/pdf" {
+			filename = "document.pdf"
+		}
+		return &types.ChatMessagePart{
+			Type: "file",
+			File: &types.ChatMessageFile{
+				Filename: filename,
+				FileData: url,
+			},
+		}
+	}
+
+	return nil
+}
+
+// lowerSchemaTypes Gemini 的 Schema 类型为大写（OBJECT、STRING），OpenAI 需要小写
+func lowerSchemaTypes(schema any) any {
+	switch v := schema.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for key, value := range v {
+			if typeName, ok := value.(string); ok && key == "type" {
+				result[key] = strings.ToLower(typeName)
+				continue
+			}
+			result[key] = lowerSchemaTypes(value)
+		}
+		return result
+	case []any:
+		result := make([]any, len(v))
+		for i, value := range v {
+			result[i] = lowerSchemaTypes(value)
+		}
+		return result
+	default:
+		return v
+	}
+}
+
+func remarshal(data any, v any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(body, v)
+}
+
+func finishReasonOpenAI2Gemini(reason string) string {
+	switch reason {
+	case types.FinishReasonLength:
+		return "MAX_TOKENS"
+	case types.FinishReasonContentFilter:
+		return "SAFETY"
+	default:
+		return "STOP"
+	}
+}
+
+// ConvertUsageToGemini 将 OpenAI 用量转换为 Gemini 的 usageMetadata
+func ConvertUsageToGemini(usage *types.Usage) *GeminiUsageMetadata {
+	if usage == nil {
+		return &GeminiUsageMetadata{}
+	}
+
+	thoughts := usage.CompletionTokensDetails.ReasoningTokens
+	return &GeminiUsageMetadata{
+		PromptTokenCount:        usage.PromptTokens,
+		CandidatesTokenCount:    usage.CompletionTokens - thoughts,
+		TotalTokenCount:         usage.PromptTokens + usage.CompletionTokens,
+		CachedContentTokenCount: usage.PromptTokensDetails.CachedTokens,
+		ThoughtsTokenCount:      thoughts,
+	}
+}
+
+func convertToolArgs(arguments string) map[string]any {
+	args := map[string]any{}
+	if arguments != "" {
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			return map[string]any{}
+		}
+	}
+
+	return args
+}
+
+// ConvertChatOpenaiToGemini 将 OpenAI 聊天响应转换为 Gemini 响应
+func ConvertChatOpenaiToGemini(response *types.ChatCompletionResponse, modelName string, usage *types.Usage) *GeminiChatResponse {
+	geminiResponse := &GeminiChatResponse{
+		Candidates:    make([]GeminiChatCandidate, 0, len(response.Choices)),
+		UsageMetadata: ConvertUsageToGemini(usage),
+		ModelVersion:  modelName,
+		ResponseId:    response.ID,
+	}
+
+	for _, choice := range response.Choices {
+		parts := make([]GeminiPart, 0)
+
+		reasoning := choice.Message.ReasoningContent
+		if reasoning == "" {
+			reasoning = choice.Message.Reasoning
+		}
+		if reasoning != "" {
+			parts = append(parts, GeminiPart{Text: reasoning, Thought: true})
+		}
+
+		if text := choice.Message.StringContent(); text != "" {
+			parts = append(parts, GeminiPart{Text: text})
+		}
+
+		choice.Message.FuncToToolCalls()
+		for _, toolCall := range choice.Message.ToolCalls {
+			if toolCall.Function == nil {
+				continue
+			}
+			parts = append(parts, GeminiPart{
+				FunctionCall: &GeminiFunctionCall{
+					Name: toolCall.Function.Name,
+					Args: convertToolArgs(toolCall.Function.Arguments),
+				},
+			})
+		}
+
+		finishReason := finishReasonOpenAI2Gemini(choice.FinishReason)
+		geminiResponse.Candidates = append(geminiResponse.Candidates, GeminiChatCandidate{
+			Content: GeminiChatContent{
+				Role:  "model",
+				Parts: parts,
+			},
+			FinishReason: &finishReason,
+			Index:        int64(choice.Index),
+		})
+	}
+
+	return geminiResponse
+}
+
+type streamToolCall struct {
+	name      string
+	arguments strings.Builder
+}
+
+// openaiToGeminiStream 将 OpenAI 的流式响应转换为 Gemini 的 SSE 响应。
+// Gemini 的函数调用在一个分片中完整返回，所以参数需要拼接完成后再输出
+type openaiToGeminiStream struct {
+	stream    requester.StreamReaderInterface[string]
+	modelName string
+	getUsage  func() *types.Usage
+
+	responseId   string
+	toolCalls    []*streamToolCall
+	finishReason string
+}
+
+// NewChatOpenaiToGeminiStream getUsage 在流结束时获取上游统计的用量
+func NewChatOpenaiToGeminiStream(stream requester.StreamReaderInterface[string], modelName string, getUsage func() *types.Usage) requester.StreamReaderInterface[string] {
+	return &openaiToGeminiStream{
+		stream:     stream,
+		modelName:  modelName,
+		getUsage:   getUsage,
+		responseId: utils.GetUUID(),
+	}
+}
+
+func (s *openaiToGeminiStream) Recv() (<-chan string, <-chan error) {
+	dataChan := make(chan string)
+	errChan := make(chan error)
+	upstreamData, upstreamErr := s.stream.Recv()
+
+	go func() {
+		for {
+			select {
+			case data, ok := <-upstreamData:
+				if !ok {
+					dataChan <- s.finish()
+					errChan <- io.EOF
+					return
+				}
+				if event := s.convert(data); event != "" {
+					dataChan <- event
+				}
+			case err := <-upstreamErr:
+				if errors.Is(err, io.EOF) {
+					dataChan <- s.finish()
+					errChan <- io.EOF
+					return
+				}
+
+				dataChan <- geminiEvent(&GeminiErrorResponse{
+					ErrorInfo: &GeminiError{
+						Code:    http.StatusInternalServerError,
+						Message: err.Error(),
+						Status:  "INTERNAL",
+					},
+				})
+				errChan <- io.EOF
+				return
+			}
+		}
+	}()
+
+	return dataChan, errChan
+}
+
+func (s *openaiToGeminiStream) Close() {
+	s.stream.Close()
+}
+
+func geminiEvent(data any) string {
+	body, _ := json.Marshal(data)
+	return "data: " + string(body) + "\n\n"
+}
+
+func (s *openaiToGeminiStream) response(parts []GeminiPart, finishReason *string) *GeminiChatResponse {
+	return &GeminiChatResponse{
+		Candidates: []GeminiChatCandidate{{
+			Content: GeminiChatContent{
+				Role:  "model",
+				Parts: parts,
+			},
+			FinishReason: finishReason,
+		}},
+		ModelVersion: s.modelName,
+		ResponseId:   s.responseId,
+	}
+}
+
+func (s *openaiToGeminiStream) convert(data string) string {
+	var chunk types.ChatCompletionStreamResponse
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil || len(chunk.Choices) == 0 {
+		return ""
+	}
+
+	choice := chunk.Choices[0]
+	parts := make([]GeminiPart, 0, 2)
+
+	reasoning := choice.Delta.ReasoningContent
+	if reasoning == "" {
+		reasoning = choice.Delta.Reasoning
+	}
+	if reasoning != "" {
+		parts = append(parts, GeminiPart{Text: reasoning, Thought: true})
+	}
+
+	if choice.Delta.Content != "" {
+		parts = append(parts, GeminiPart{Text: choice.Delta.Content})
+	}
+
+	if choice.Delta.FunctionCall != nil {
+		choice.Delta.ToolCalls = []*types.ChatCompletionToolCalls{{Function: choice.Delta.FunctionCall}}
+	}
+	for _, toolCall := range choice.Delta.ToolCalls {
+		if toolCall.Function == nil {
+			continue
+		}
+
+		for len(s.toolCalls) <= toolCall.Index {
+			s.toolCalls = append(s.toolCalls, &streamToolCall{})
+		}
+		current := s.toolCalls[toolCall.Index]
+		if toolCall.Function.Name != "" {
+			current.name = toolCall.Function.Name
+		}
+		current.arguments.WriteString(toolCall.Function.Arguments)
+	}
+
+	if reason, ok := choice.FinishReason.(string); ok && reason != "" && reason != types.FinishReasonNull {
+		s.finishReason = reason
+	}
+
+	if len(parts) == 0 {
+		return ""
+	}
+
+	return geminiEvent(s.response(parts, nil))
+}
+
+// finish 输出拼接完成的函数调用、结束原因和用量
+func (s *openaiToGeminiStream) finish() string {
+	parts := make([]GeminiPart, 0, len(s.toolCalls))
+	for _, toolCall := range s.toolCalls {
+		parts = append(parts, GeminiPart{
+			FunctionCall: &GeminiFunctionCall{
+				Name: toolCall.name,
+				Args: convertToolArgs(toolCall.arguments.String()),
+			},
+		})
+	}
+
+	usage := s.getUsage()
+	metadata := ConvertUsageToGemini(usage)
+	if usage.CompletionTokens == 0 && usage.TextBuilder.Len() > 0 {
+		metadata.CandidatesTokenCount = common.CountTokenText(usage.TextBuilder.String(), s.modelName)
+		metadata.TotalTokenCount = metadata.PromptTokenCount + metadata.CandidatesTokenCount
+	}
+
+	finishReason := finishReasonOpenAI2Gemini(s.finishReason)
+	response := s.response(parts, &finishReason)
+	response.UsageMetadata = metadata
+
+	return geminiEvent(response)
+}
@@ -0,0 +1,256 @@
+package gemini_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"one-api/common/utils"
+	"one-api/providers/gemini"
+	"one-api/types"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertToChatOpenaiRequest(t *testing.T) {
+	request := &gemini.GeminiChatRequest{
+		Model:  "gemini-2.5-pro",
+		Stream: true,
+		SystemInstruction: map[string]any{
+			"parts": []any{map[string]any{"text": "be brief"}},
+		},
+		GenerationConfig: gemini.GeminiChatGenerationConfig{
+			Temperature:      utils.GetPointer(0.2),
+			MaxOutputTokens:  512,
+			CandidateCount:   2,
+			StopSequences:    []string{"END"},
+			ResponseMimeType: "application/json",
+			ResponseSchema:   map[string]any{"type": "OBJECT", "properties": map[string]any{"city": map[string]any{"type": "STRING"}}},
+			ThinkingConfig:   &gemini.ThinkingConfig{ThinkingBudget: utils.GetPointer(1024)},
+		},
+		Contents: []gemini.GeminiChatContent{
+			{Role: "user", Parts: []gemini.GeminiPart{{Text: "weather in Paris?"}}},
+			{Role: "model", Parts: []gemini.GeminiPart{
+				{Text: "hidden", Thought: true},
+				{FunctionCall: &gemini.GeminiFunctionCall{Name: "weather", Args: map[string]any{"city": "Paris"}}},
+			}},
+			{Role: "user", Parts: []gemini.GeminiPart{
+				{FunctionResponse: &gemini.GeminiFunctionResponse{Name: "weather", Response: map[string]any{"result": "sunny"}}},
+				{InlineData: &gemini.GeminiInlineData{MimeType: "image/png", Data: "aGk="}},
+			}},
+		},
+		Tools: []gemini.GeminiChatTools{
+			{FunctionDeclarations: []types.ChatCompletionFunction{{Name: "weather", Parameters: map[string]any{"type": "OBJECT"}}}},
+			{GoogleSearch: map[string]any{}},
+		},
+		ToolConfig: &gemini.GeminiToolConfig{
+			FunctionCallingConfig: &gemini.GeminiFunctionCallingConfig{Mode: "ANY", AllowedFunctionNames: []string{"weather"}},
+		},
+	}
+
+	got, err := gemini.ConvertToChatOpenaiRequest(request)
+	require.Nil(t, err)
+
+	assert.Equal(t, "gemini-2.5-pro", got.Model)
+	assert.Equal(t, 512, got.MaxTokens)
+	assert.Equal(t, 0.2, *got.Temperature)
+	assert.Equal(t, 2, *got.N)
+	assert.Equal(t, []string{"END"}, got.Stop)
+	require.NotNil(t, got.StreamOptions)
+	require.NotNil(t, got.Reasoning)
+	assert.Equal(t, 1024, got.Reasoning.MaxTokens)
+	require.NotNil(t, got.ResponseFormat)
+	assert.Equal(t, "json_schema", got.ResponseFormat.Type)
+	assert.Equal(t, "object", got.ResponseFormat.JsonSchema.Schema.(map[string]any)["type"])
+
+	require.Len(t, got.Messages, 5)
+	assert.Equal(t, types.ChatMessageRoleSystem, got.Messages[0].Role)
+	assert.Equal(t, "be brief", got.Messages[0].Content)
+	assert.Equal(t, "weather in Paris?", got.Messages[1].Content)
+
+	assistant := got.Messages[2]
+	assert.Equal(t, "", assistant.Content)
+	require.Len(t, assistant.ToolCalls, 1)
+	assert.Equal(t, "weather", assistant.ToolCalls[0].Function.Name)
+	assert.JSONEq(t, `{"city":"Paris"}`, assistant.ToolCalls[0].Function.Arguments)
+
+	// 函数结果按函数名匹配到之前的调用 id
+	assert.Equal(t, types.ChatMessageRoleTool, got.Messages[3].Role)
+	assert.Equal(t, assistant.ToolCalls[0].Id, got.Messages[3].ToolCallID)
+	assert.JSONEq(t, `{"result":"sunny"}`, got.Messages[3].Content.(string))
+
+	parts, ok := got.Messages[4].Content.([]types.ChatMessagePart)
+	require.True(t, ok)
+	assert.Equal(t, "data:image/png;base64,aGk=", parts[0].ImageURL.URL)
+
+	require.Len(t, got.Tools, 1)
+	assert.Equal(t, "object", got.Tools[0].Function.Parameters.(map[string]any)["type"])
+	assert.Equal(t, map[string]any{
+		"type":     types.ToolChoiceTypeFunction,
+		"function": map[string]any{"name": "weather"},
+	}, got.ToolChoice)
+}
+
+func TestConvertToChatOpenaiRequestToolChoice(t *testing.T) {
+	tests := []struct {
+		mode string
+		want any
+	}{
+		{"AUTO", types.ToolChoiceTypeAuto},
+		{"ANY", types.ToolChoiceTypeRequired},
+		{"NONE", types.ToolChoiceTypeNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			got, err := gemini.ConvertToChatOpenaiRequest(&gemini.GeminiChatRequest{
+				Contents:   []gemini.GeminiChatContent{{Role: "user", Parts: []gemini.GeminiPart{{Text: "hi"}}}},
+				Tools:      []gemini.GeminiChatTools{{FunctionDeclarations: []types.ChatCompletionFunction{{Name: "weather"}}}},
+				ToolConfig: &gemini.GeminiToolConfig{FunctionCallingConfig: &gemini.GeminiFunctionCallingConfig{Mode: tt.mode}},
+			})
+			require.Nil(t, err)
+			assert.Equal(t, tt.want, got.ToolChoice)
+		})
+	}
+}
+
+func TestConvertChatOpenaiToGemini(t *testing.T) {
+	response := &types.ChatCompletionResponse{
+		ID: "chatcmpl-abc",
+		Choices: []types.ChatCompletionChoice{{
+			Message: types.ChatCompletionMessage{
+				Content:          "let me check",
+				ReasoningContent: "thinking",
+				ToolCalls: []*types.ChatCompletionToolCalls{{
+					Function: &types.ChatCompletionToolCallsFunction{Name: "weather", Arguments: `{"city":"Paris"}`},
+				}},
+			},
+			FinishReason: types.FinishReasonLength,
+		}},
+	}
+	usage := &types.Usage{PromptTokens: 100, CompletionTokens: 30}
+	usage.CompletionTokensDetails.ReasoningTokens = 10
+	usage.PromptTokensDetails.CachedTokens = 40
+
+	got := gemini.ConvertChatOpenaiToGemini(response, "gemini-2.5-pro", usage)
+
+	assert.Equal(t, "gemini-2.5-pro", got.ModelVersion)
+	assert.Equal(t, "chatcmpl-abc", got.ResponseId)
+	assert.Equal(t, &gemini.GeminiUsageMetadata{
+		PromptTokenCount:        100,
+		CandidatesTokenCount:    20,
+		TotalTokenCount:         130,
+		CachedContentTokenCount: 40,
+		ThoughtsTokenCount:      10,
+	}, got.UsageMetadata)
+
+	require.Len(t, got.Candidates, 1)
+	candidate := got.Candidates[0]
+	assert.Equal(t, "MAX_TOKENS", *candidate.FinishReason)
+	assert.Equal(t, "model", candidate.Content.Role)
+	require.Len(t, candidate.Content.Parts, 3)
+	assert.True(t, candidate.Content.Parts[0].Thought)
+	assert.Equal(t, "let me check", candidate.Content.Parts[1].Text)
+	assert.Equal(t, "weather", candidate.Content.Parts[2].FunctionCall.Name)
+	assert.Equal(t, map[string]any{"city": "Paris"}, candidate.Content.Parts[2].FunctionCall.Args)
+}
+
+type fakeStream struct {
+	chunks []string
+	err    error
+}
+
+func (s *fakeStream) Recv() (<-chan string, <-chan error) {
+	dataChan := make(chan string)
+	errChan := make(chan error)
+
+	go func() {
+		for _, chunk := range s.chunks {
+			dataChan <- chunk
+		}
+		if s.err != nil {
+			errChan <- s.err
+			return
+		}
+		errChan <- io.EOF
+	}()
+
+	return dataChan, errChan
+}
+
+func (s *fakeStream) Close() {}
+
+func readGeminiStream(t *testing.T, stream *fakeStream, usage *types.Usage) []string {
+	t.Helper()
+
+	translated := gemini.NewChatOpenaiToGeminiStream(stream, "gemini-2.5-pro", func() *types.Usage { return usage })
+	dataChan, errChan := translated.Recv()
+
+	var events []string
+	for {
+		select {
+		case data := <-dataChan:
+			events = append(events, strings.TrimSpace(strings.TrimPrefix(data, "data: ")))
+		case err := <-errChan:
+			require.ErrorIs(t, err, io.EOF)
+			return events
+		}
+	}
+}
+
+func chunk(t *testing.T, delta types.ChatCompletionStreamChoiceDelta, finishReason any) string {
+	t.Helper()
+
+	data, err := json.Marshal(types.ChatCompletionStreamResponse{
+		Choices: []types.ChatCompletionStreamChoice{{Delta: delta, FinishReason: finishReason}},
+	})
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestChatOpenaiToGeminiStream(t *testing.T) {
+	stream := &fakeStream{chunks: []string{
+		chunk(t, types.ChatCompletionStreamChoiceDelta{Content: "Hello"}, nil),
+		chunk(t, types.ChatCompletionStreamChoiceDelta{ToolCalls: []*types.ChatCompletionToolCalls{{
+			Function: &types.ChatCompletionToolCallsFunction{Name: "weather", Arguments: `{"city":`},
+		}}}, nil),
+		chunk(t, types.ChatCompletionStreamChoiceDelta{ToolCalls: []*types.ChatCompletionToolCalls{{
+			Function: &types.ChatCompletionToolCallsFunction{Arguments: `"Paris"}`},
+		}}}, types.FinishReasonToolCalls),
+	}}
+	usage := &types.Usage{PromptTokens: 10, CompletionTokens: 5}
+
+	events := readGeminiStream(t, stream, usage)
+	require.Len(t, events, 2)
+
+	var text gemini.GeminiChatResponse
+	require.NoError(t, json.Unmarshal([]byte(events[0]), &text))
+	assert.Equal(t, "Hello", text.Candidates[0].Content.Parts[0].Text)
+	assert.Nil(t, text.Candidates[0].FinishReason)
+
+	// 函数调用的参数拼接完成后在最后一个分片中输出
+	var last gemini.GeminiChatResponse
+	require.NoError(t, json.Unmarshal([]byte(events[1]), &last))
+	require.Len(t, last.Candidates[0].Content.Parts, 1)
+	assert.Equal(t, "weather", last.Candidates[0].Content.Parts[0].FunctionCall.Name)
+	assert.Equal(t, map[string]any{"city": "Paris"}, last.Candidates[0].Content.Parts[0].FunctionCall.Args)
+	assert.Equal(t, "STOP", *last.Candidates[0].FinishReason)
+	assert.Equal(t, 15, last.UsageMetadata.TotalTokenCount)
+}
+
+func TestChatOpenaiToGeminiStreamError(t *testing.T) {
+	stream := &fakeStream{
+		chunks: []string{chunk(t, types.ChatCompletionStreamChoiceDelta{Content: "Hel"}, nil)},
+		err:    errors.New("upstream closed"),
+	}
+
+	events := readGeminiStream(t, stream, &types.Usage{})
+	require.Len(t, events, 2)
+
+	var errResponse gemini.GeminiErrorResponse
+	require.NoError(t, json.Unmarshal([]byte(events[1]), &errResponse))
+	require.NotNil(t, errResponse.ErrorInfo)
+	assert.Equal(t, "upstream closed", errResponse.ErrorInfo.Message)
+}
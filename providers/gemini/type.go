@@ -51,6 +51,7 @@ type GeminiToolConfig struct {
 
 type GeminiFunctionCallingConfig struct {
 	Model                string `json:"model,omitempty"`
+	Mode                 string `json:"mode,omitempty"`
 	AllowedFunctionNames any    `json:"allowedFunctionNames,omitempty"`
 }
 type GeminiInlineData struct {
@@ -714,3 +715,16 @@ func formatGroundingMetadataAsMarkdown(metadata *GeminiGroundingMetadata) string
 	}
 	return result.String()
 }
+
+// GeminiCountTokensRequest countTokens 请求，contents 与 generateContentRequest 二选一
+type GeminiCountTokensRequest struct {
+	Model                  string              `json:"-"`
+	Contents               []GeminiChatContent `json:"contents,omitempty"`
+	GenerateContentRequest *GeminiChatRequest  `json:"generateContentRequest,omitempty"`
+}
+
+type GeminiCountTokensResponse struct {
+	TotalTokens             int                          `json:"totalTokens"`
+	CachedContentTokenCount int                          `json:"cachedContentTokenCount,omitempty"`
+	PromptTokensDetails     []GeminiUsageMetadataDetails `json:"promptTokensDetails,omitempty"`
+}
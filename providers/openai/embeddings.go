@@ -7,6 +7,13 @@ import (
 )
 
 func (p *OpenAIProvider) CreateEmbeddings(request *types.EmbeddingRequest) (*types.EmbeddingResponse, *types.OpenAIErrorWithStatusCode) {
+	// input_type 为 Cohere 等渠道的扩展参数，多数兼容接口不支持，只有自定义渠道原样转发
+	if request.InputType != "" && p.Channel.Type != config.ChannelTypeCustom {
+		embeddingRequest := *request
+		embeddingRequest.InputType = ""
+		request = &embeddingRequest
+	}
+
 	req, errWithCode := p.GetRequestTextBody(config.RelayModeEmbeddings, request.Model, request)
 	if errWithCode != nil {
 		return nil, errWithCode
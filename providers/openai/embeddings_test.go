@@ -0,0 +1,72 @@
+package openai_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common/config"
+	"one-api/common/requester"
+	"one-api/common/utils"
+	"one-api/model"
+	"one-api/providers/openai"
+	"one-api/types"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateEmbeddingsInputType(t *testing.T) {
+	tests := []struct {
+		name          string
+		channelType   int
+		wantInputType bool
+	}{
+		{name: "openai drops input_type", channelType: config.ChannelTypeOpenAI},
+		{name: "compatible channel drops input_type", channelType: config.ChannelTypeSiliconflow},
+		{name: "custom channel keeps input_type", channelType: config.ChannelTypeCustom, wantInputType: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requester.HTTPClient = &http.Client{}
+
+			var gotBody map[string]any
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&gotBody))
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"object":"list","data":[{"object":"embedding","index":0,"embedding":[0.1]}],"usage":{"prompt_tokens":1,"total_tokens":1}}`))
+			}))
+			defer server.Close()
+
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/embeddings", nil)
+
+			provider := openai.CreateOpenAIProvider(&model.Channel{
+				Type:    tt.channelType,
+				Key:     "test-key",
+				BaseURL: utils.GetPointer(server.URL),
+				Proxy:   utils.GetPointer(""),
+			}, server.URL)
+			provider.SetContext(c)
+			provider.SetUsage(&types.Usage{})
+
+			request := &types.EmbeddingRequest{
+				Model:     "text-embedding-3-small",
+				Input:     "hello",
+				InputType: "search_query",
+			}
+			_, errWithCode := provider.CreateEmbeddings(request)
+			require.Nil(t, errWithCode)
+
+			if tt.wantInputType {
+				assert.Equal(t, "search_query", gotBody["input_type"])
+			} else {
+				assert.NotContains(t, gotBody, "input_type")
+			}
+			// 不修改原请求，重试其他渠道时仍能使用
+			assert.Equal(t, "search_query", request.InputType)
+		})
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"one-api/providers/claude"
 	"one-api/providers/cloudflareAI"
 	"one-api/providers/cohere"
+	"one-api/providers/compatible"
 	"one-api/providers/coze"
 	"one-api/providers/deepseek"
 	"one-api/providers/gemini"
@@ -93,6 +94,7 @@ func init() {
 		config.ChannelTypeAzureDatabricks: azuredatabricks.AzureDatabricksProviderFactory{},
 		config.ChannelTypeAzureV1:         azure_v1.AzureV1ProviderFactory{},
 		config.ChannelTypeXAI:             xAI.XAIProviderFactory{},
+		config.ChannelTypeCompatible:      compatible.CompatibleProviderFactory{},
 	}
 }
 
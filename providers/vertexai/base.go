@@ -62,6 +62,7 @@ func getConfig() base.ProviderConfig {
 		BaseURL:           "https://%saiplatform.googleapis.com/v1/projects/%s/locations/%s/publishers/google/models/%s:%s",
 		ChatCompletions:   "/",
 		ImagesGenerations: "/predict",
+		Embeddings:        "/predict",
 	}
 }
 
@@ -82,6 +83,22 @@ func (p *VertexAIProvider) GetFullRequestURL(modelName string, other string) str
 	return fmt.Sprintf(p.GetBaseURL(), p.Region+"-", p.ProjectID, p.Region, modelName, other)
 }
 
+// GetCategoryRequestURL 获取分类模型的请求地址，合作伙伴模型使用对应的发布方或 OpenAI 兼容端点
+func (p *VertexAIProvider) GetCategoryRequestURL(modelName string, other string) string {
+	fullRequestURL := p.GetFullRequestURL(modelName, other)
+
+	if p.Category.OpenAICompatible {
+		baseURL, _, _ := strings.Cut(fullRequestURL, "/publishers/")
+		return fmt.Sprintf("%s/endpoints/openapi/%s", baseURL, other)
+	}
+
+	if p.Category.Publisher != "" {
+		return strings.Replace(fullRequestURL, "/publishers/google/", "/publishers/"+p.Category.Publisher+"/", 1)
+	}
+
+	return fullRequestURL
+}
+
 func (p *VertexAIProvider) GetRequestHeaders() (headers map[string]string) {
 	headers = make(map[string]string)
 	p.CommonRequestHeaders(headers)
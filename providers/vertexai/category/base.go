@@ -3,10 +3,10 @@ package category
 import (
 	"errors"
 	"net/http"
+	"one-api/common/config"
 	"one-api/common/requester"
 	"one-api/providers/base"
 	"one-api/types"
-	"strings"
 )
 
 type Category struct {
@@ -17,26 +17,22 @@ type Category struct {
 	ErrorHandler              requester.HttpErrorHandler
 	GetModelName              func(string) string
 	GetOtherUrl               func(bool) string
+	// 合作伙伴模型的发布方，为空时使用 google
+	Publisher string
+	// 使用 OpenAI 兼容端点，模型名在请求体中
+	OpenAICompatible bool
 }
 
 var CategoryMap = map[string]*Category{}
 
+// GetCategory 根据模型名获取分类，对应关系可以通过 VertexAIModelCategory 配置
 func GetCategory(modelName string) (*Category, error) {
-
-	category := ""
-
-	if strings.HasPrefix(modelName, "gemini") {
-		category = "gemini"
-	} else if strings.HasPrefix(modelName, "claude") {
-		category = "claude"
-	}
-
-	if category == "" {
+	category, exists := CategoryMap[config.VertexAISettingsInstance.GetModelCategory(modelName)]
+	if !exists {
 		return nil, errors.New("category_not_found")
 	}
 
-	return CategoryMap[category], nil
-
+	return category, nil
 }
 
 type ChatCompletionConvert func(*types.ChatCompletionRequest) (any, *types.OpenAIErrorWithStatusCode)
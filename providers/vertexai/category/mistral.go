@@ -0,0 +1,45 @@
+package category
+
+import (
+	"one-api/providers/openai"
+	"one-api/types"
+	"strings"
+)
+
+func init() {
+	CategoryMap["mistral"] = &Category{
+		Category:                  "mistral",
+		Publisher:                 "mistralai",
+		ChatComplete:              ConvertMistralFromChatOpenai,
+		ResponseChatComplete:      ConvertOpenAIToChatOpenai,
+		ResponseChatCompleteStrem: OpenAIChatCompleteStrem,
+		ErrorHandler:              openai.RequestErrorHandle,
+		GetModelName:              GetMistralModelName,
+		GetOtherUrl:               getMistralOtherUrl,
+	}
+}
+
+// ConvertMistralFromChatOpenai Mistral 使用 OpenAI 格式，请求体中的模型名不带版本号
+func ConvertMistralFromChatOpenai(request *types.ChatCompletionRequest) (any, *types.OpenAIErrorWithStatusCode) {
+	mistralRequest := *request
+	mistralRequest.Model, _, _ = strings.Cut(request.Model, "@")
+	// Mistral 在最后一个数据块中返回用量，不支持 stream_options
+	mistralRequest.StreamOptions = nil
+	if mistralRequest.MaxTokens == 0 && mistralRequest.MaxCompletionTokens > 0 {
+		mistralRequest.MaxTokens = mistralRequest.MaxCompletionTokens
+		mistralRequest.MaxCompletionTokens = 0
+	}
+
+	return &mistralRequest, nil
+}
+
+func GetMistralModelName(modelName string) string {
+	return modelName
+}
+
+func getMistralOtherUrl(stream bool) string {
+	if stream {
+		return "streamRawPredict"
+	}
+	return "rawPredict"
+}
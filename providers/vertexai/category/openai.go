@@ -0,0 +1,103 @@
+package category
+
+import (
+	"encoding/json"
+	"net/http"
+	"one-api/common"
+	"one-api/common/requester"
+	"one-api/providers/base"
+	"one-api/providers/openai"
+	"one-api/types"
+	"strings"
+)
+
+// Model as a Service 模型的发布方，OpenAI 兼容端点的模型名需要带上发布方
+var openaiPublisherMap = map[string]string{
+	"llama":    "meta",
+	"deepseek": "deepseek-ai",
+	"qwen":     "qwen",
+	"gpt-oss":  "openai",
+}
+
+func init() {
+	CategoryMap["openai"] = &Category{
+		Category:                  "openai",
+		OpenAICompatible:          true,
+		ChatComplete:              ConvertOpenAIFromChatOpenai,
+		ResponseChatComplete:      ConvertOpenAIToChatOpenai,
+		ResponseChatCompleteStrem: OpenAIChatCompleteStrem,
+		ErrorHandler:              openai.RequestErrorHandle,
+		GetModelName:              GetOpenAIModelName,
+		GetOtherUrl:               getOpenAIOtherUrl,
+	}
+}
+
+func ConvertOpenAIFromChatOpenai(request *types.ChatCompletionRequest) (any, *types.OpenAIErrorWithStatusCode) {
+	openaiRequest := *request
+	openaiRequest.Model = GetOpenAIModelName(request.Model)
+	openaiRequest.StreamOptions = nil
+	if openaiRequest.Stream {
+		openaiRequest.StreamOptions = &types.StreamOptions{
+			IncludeUsage: true,
+		}
+	}
+
+	return &openaiRequest, nil
+}
+
+// ConvertOpenAIToChatOpenai 合作伙伴模型返回 OpenAI 格式，只需要处理用量
+func ConvertOpenAIToChatOpenai(provider base.ProviderInterface, response *http.Response, request *types.ChatCompletionRequest) (*types.ChatCompletionResponse, *types.OpenAIErrorWithStatusCode) {
+	openaiResponse := &openai.OpenAIProviderChatResponse{}
+	err := json.NewDecoder(response.Body).Decode(openaiResponse)
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "decode_response_failed", http.StatusInternalServerError)
+	}
+
+	openaiErr := openai.ErrorHandle(&openaiResponse.OpenAIErrorResponse)
+	if openaiErr != nil {
+		return nil, &types.OpenAIErrorWithStatusCode{
+			OpenAIError: *openaiErr,
+			StatusCode:  http.StatusBadRequest,
+		}
+	}
+
+	usage := provider.GetUsage()
+	if openaiResponse.Usage == nil || openaiResponse.Usage.CompletionTokens == 0 {
+		usage.CompletionTokens = common.CountTokenText(openaiResponse.GetContent(), request.Model)
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	} else {
+		*usage = *openaiResponse.Usage
+	}
+
+	openaiResponse.Model = request.Model
+	openaiResponse.Usage = usage
+
+	return &openaiResponse.ChatCompletionResponse, nil
+}
+
+func OpenAIChatCompleteStrem(provider base.ProviderInterface, request *types.ChatCompletionRequest) requester.HandlerPrefix[string] {
+	chatHandler := &openai.OpenAIStreamHandler{
+		Usage:     provider.GetUsage(),
+		ModelName: request.Model,
+	}
+
+	return chatHandler.HandlerChatStream
+}
+
+func GetOpenAIModelName(modelName string) string {
+	if strings.Contains(modelName, "/") {
+		return modelName
+	}
+
+	for prefix, publisher := range openaiPublisherMap {
+		if strings.HasPrefix(modelName, prefix) {
+			return publisher + "/" + modelName
+		}
+	}
+
+	return modelName
+}
+
+func getOpenAIOtherUrl(_ bool) string {
+	return "chat/completions"
+}
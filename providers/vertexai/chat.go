@@ -54,7 +54,7 @@ func (p *VertexAIProvider) getChatRequest(request *types.ChatCompletionRequest)
 	modelName := p.Category.GetModelName(request.Model)
 
 	// 获取请求地址
-	fullRequestURL := p.GetFullRequestURL(modelName, otherUrl)
+	fullRequestURL := p.GetCategoryRequestURL(modelName, otherUrl)
 	if fullRequestURL == "" {
 		return nil, common.ErrorWrapperLocal(nil, "invalid_vertexai_config", http.StatusInternalServerError)
 	}
@@ -0,0 +1,51 @@
+package vertexai
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/providers/gemini"
+	"one-api/providers/vertexai/category"
+	"one-api/types"
+)
+
+// CountGeminiTokens Vertex AI 的 countTokens 不支持 generateContentRequest，需要展开为 contents、systemInstruction 和 tools
+func (p *VertexAIProvider) CountGeminiTokens(request *gemini.GeminiCountTokensRequest) (*gemini.GeminiCountTokensResponse, *types.OpenAIErrorWithStatusCode) {
+	var err error
+	p.Category, err = category.GetCategory(request.Model)
+	if err != nil || p.Category.Category != "gemini" {
+		return nil, common.StringErrorWrapperLocal("vertexAI provider not found", "vertexAI_err", http.StatusInternalServerError)
+	}
+
+	body := map[string]any{"contents": request.Contents}
+	if generateRequest := request.GenerateContentRequest; generateRequest != nil {
+		body["contents"] = generateRequest.Contents
+		if generateRequest.SystemInstruction != nil {
+			body["systemInstruction"] = generateRequest.SystemInstruction
+		}
+		if len(generateRequest.Tools) > 0 {
+			body["tools"] = generateRequest.Tools
+		}
+	}
+
+	fullRequestURL := p.GetFullRequestURL(p.Category.GetModelName(request.Model), "countTokens")
+	headers := p.GetRequestHeaders()
+	if headers == nil {
+		return nil, common.StringErrorWrapperLocal("vertexAI config error", "invalid_vertexai_config", http.StatusInternalServerError)
+	}
+
+	p.Requester.ErrorHandler = RequestErrorHandle(p.Category.ErrorHandler)
+
+	req, err := p.Requester.NewRequest(http.MethodPost, fullRequestURL, p.Requester.WithBody(body), p.Requester.WithHeader(headers))
+	if err != nil {
+		return nil, common.StringErrorWrapperLocal(err.Error(), "new_request_failed", http.StatusInternalServerError)
+	}
+	defer req.Body.Close()
+
+	response := &gemini.GeminiCountTokensResponse{}
+	_, errWithCode := p.Requester.SendRequest(req, response, false)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	return response, nil
+}
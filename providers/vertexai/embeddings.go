@@ -0,0 +1,111 @@
+package vertexai
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/providers/gemini"
+	"one-api/types"
+)
+
+type VertexAIEmbeddingRequest struct {
+	Instances  []VertexAIEmbeddingInstance `json:"instances"`
+	Parameters VertexAIEmbeddingParameters `json:"parameters,omitempty"`
+}
+
+type VertexAIEmbeddingInstance struct {
+	Content  string `json:"content"`
+	TaskType string `json:"task_type,omitempty"`
+}
+
+type VertexAIEmbeddingParameters struct {
+	AutoTruncate         bool `json:"autoTruncate"`
+	OutputDimensionality int  `json:"outputDimensionality,omitempty"`
+}
+
+type VertexAIEmbeddingResponse struct {
+	Predictions []VertexAIEmbeddingPrediction `json:"predictions"`
+}
+
+type VertexAIEmbeddingPrediction struct {
+	Embeddings struct {
+		Values     []float64 `json:"values"`
+		Statistics struct {
+			TokenCount int  `json:"token_count"`
+			Truncated  bool `json:"truncated"`
+		} `json:"statistics"`
+	} `json:"embeddings"`
+}
+
+func (p *VertexAIProvider) CreateEmbeddings(request *types.EmbeddingRequest) (*types.EmbeddingResponse, *types.OpenAIErrorWithStatusCode) {
+	input := request.ParseInput()
+	if len(input) == 0 {
+		return nil, common.StringErrorWrapperLocal("input is required", "invalid_request", http.StatusBadRequest)
+	}
+
+	vertexRequest := &VertexAIEmbeddingRequest{
+		Instances: make([]VertexAIEmbeddingInstance, 0, len(input)),
+		Parameters: VertexAIEmbeddingParameters{
+			AutoTruncate:         true,
+			OutputDimensionality: request.Dimensions,
+		},
+	}
+	taskType := gemini.ConvertEmbeddingTaskType(request.InputType)
+	for _, text := range input {
+		vertexRequest.Instances = append(vertexRequest.Instances, VertexAIEmbeddingInstance{
+			Content:  text,
+			TaskType: taskType,
+		})
+	}
+
+	// 获取请求地址
+	fullRequestURL := p.GetFullRequestURL(request.Model, "predict")
+	if fullRequestURL == "" {
+		return nil, common.ErrorWrapper(nil, "invalid_vertex_ai_config", http.StatusInternalServerError)
+	}
+
+	// 获取请求头
+	headers := p.GetRequestHeaders()
+	if headers == nil {
+		return nil, common.ErrorWrapper(nil, "invalid_vertex_ai_config", http.StatusInternalServerError)
+	}
+
+	p.Requester.ErrorHandler = RequestErrorHandle(nil)
+
+	// 创建请求
+	req, err := p.Requester.NewRequest(http.MethodPost, fullRequestURL, p.Requester.WithBody(vertexRequest), p.Requester.WithHeader(headers))
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+	defer req.Body.Close()
+
+	vertexResponse := &VertexAIEmbeddingResponse{}
+	_, errWithCode := p.Requester.SendRequest(req, vertexResponse, false)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	response := &types.EmbeddingResponse{
+		Object: "list",
+		Model:  request.Model,
+		Data:   make([]types.Embedding, 0, len(vertexResponse.Predictions)),
+	}
+
+	tokenCount := 0
+	for i, prediction := range vertexResponse.Predictions {
+		tokenCount += prediction.Embeddings.Statistics.TokenCount
+		response.Data = append(response.Data, types.Embedding{
+			Object:    "embedding",
+			Index:     i,
+			Embedding: request.FormatEmbedding(prediction.Embeddings.Values),
+		})
+	}
+
+	usage := p.GetUsage()
+	if tokenCount > 0 {
+		usage.PromptTokens = tokenCount
+	}
+	usage.TotalTokens = usage.PromptTokens
+	response.Usage = usage
+
+	return response, nil
+}
@@ -21,6 +21,7 @@ type relayBase struct {
 	otherArg       string
 	allowHeartbeat bool
 	heartbeat      *relay_util.Heartbeat
+	tpmLimit       *relay_util.TPMLimit // 对冲请求为备用渠道占用 TPM 额度时使用
 
 	firstResponseTime time.Time
 }
@@ -42,6 +43,16 @@ type RelayBaseInterface interface {
 	HandleJsonError(err *types.OpenAIErrorWithStatusCode)
 	HandleStreamError(err *types.OpenAIErrorWithStatusCode)
 	SetHeartbeat(isStream bool) *relay_util.Heartbeat
+	setTPMLimit(tpmLimit *relay_util.TPMLimit)
+	sendCache() bool
+}
+
+func (r *relayBase) setTPMLimit(tpmLimit *relay_util.TPMLimit) {
+	r.tpmLimit = tpmLimit
+}
+
+func (r *relayBase) sendCache() bool {
+	return false
 }
 
 func (r *relayBase) getRequest() interface{} {
@@ -0,0 +1,171 @@
+package batch
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/common/utils"
+	"one-api/model"
+	"one-api/types"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+)
+
+// 网关支持批处理的接口
+var supportedEndpoints = map[string]bool{
+	"/v1/chat/completions": true,
+	"/v1/completions":      true,
+	"/v1/embeddings":       true,
+	"/v1/responses":        true,
+	"/v1/moderations":      true,
+}
+
+var completionWindows = map[string]time.Duration{
+	"24h": 24 * time.Hour,
+}
+
+func CreateBatch(c *gin.Context) {
+	var request types.BatchCreateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		common.AbortWithMessage(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !supportedEndpoints[request.Endpoint] {
+		common.AbortWithMessage(c, http.StatusBadRequest, "unsupported endpoint: "+request.Endpoint)
+		return
+	}
+
+	if setting, ok := c.Get("token_setting"); ok {
+		if err := setting.(*model.TokenSetting).Scopes.CheckEndpoint(http.MethodPost, request.Endpoint); err != nil {
+			common.AbortWithMessage(c, err.StatusCode, err.Message)
+			return
+		}
+	}
+
+	window, ok := completionWindows[request.CompletionWindow]
+	if !ok {
+		common.AbortWithMessage(c, http.StatusBadRequest, "unsupported completion_window: "+request.CompletionWindow)
+		return
+	}
+
+	userId := c.GetInt("id")
+	inputFile, err := model.GetTokenFileByFileId(userId, c.GetInt("token_id"), request.InputFileId)
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if inputFile.Purpose != types.FilePurposeBatch {
+		common.AbortWithMessage(c, http.StatusBadRequest, "input file must be uploaded with purpose batch")
+		return
+	}
+
+	now := utils.GetTimestamp()
+	batch := &model.Batch{
+		BatchId:          model.NewBatchId(),
+		UserId:           userId,
+		TokenId:          c.GetInt("token_id"),
+		Endpoint:         request.Endpoint,
+		InputFileId:      request.InputFileId,
+		CompletionWindow: request.CompletionWindow,
+		Status:           types.BatchStatusValidating,
+		CreatedAt:        now,
+		ExpiresAt:        now + int64(window.Seconds()),
+		Metadata:         datatypes.NewJSONType(request.Metadata),
+	}
+
+	if err := batch.Insert(); err != nil {
+		common.AbortWithMessage(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	notifyDispatcher()
+
+	c.JSON(http.StatusOK, batch.ToOpenAI())
+}
+
+func RetrieveBatch(c *gin.Context) {
+	batch, err := model.GetUserBatchByBatchId(c.GetInt("id"), c.Param("id"))
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, batch.ToOpenAI())
+}
+
+func ListBatches(c *gin.Context) {
+	limit := utils.String2Int(c.DefaultQuery("limit", "20"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	// 多取一条用于判断 has_more
+	batches, err := model.GetUserBatches(c.GetInt("id"), c.Query("after"), limit+1)
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	list := &types.BatchList{
+		Object: "list",
+		Data:   make([]*types.Batch, 0, len(batches)),
+	}
+	if len(batches) > limit {
+		list.HasMore = true
+		batches = batches[:limit]
+	}
+	for _, batch := range batches {
+		list.Data = append(list.Data, batch.ToOpenAI())
+	}
+	if len(list.Data) > 0 {
+		list.FirstId = list.Data[0].Id
+		list.LastId = list.Data[len(list.Data)-1].Id
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+func CancelBatch(c *gin.Context) {
+	batch, err := model.GetUserBatchByBatchId(c.GetInt("id"), c.Param("id"))
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	now := utils.GetTimestamp()
+	switch batch.Status {
+	case types.BatchStatusValidating:
+		// 尚未开始执行，直接取消
+		_, err = batch.UpdateStatus(types.BatchStatusValidating, map[string]any{
+			"status":        types.BatchStatusCancelled,
+			"cancelling_at": now,
+			"cancelled_at":  now,
+		})
+	case types.BatchStatusInProgress:
+		// 执行中的任务由执行器检测到状态变化后停止
+		_, err = batch.UpdateStatus(types.BatchStatusInProgress, map[string]any{
+			"status":        types.BatchStatusCancelling,
+			"cancelling_at": now,
+		})
+	case types.BatchStatusCancelling, types.BatchStatusCancelled:
+	default:
+		common.AbortWithMessage(c, http.StatusConflict, "Cannot cancel a batch with status "+batch.Status)
+		return
+	}
+
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	batch, err = model.GetUserBatchByBatchId(batch.UserId, batch.BatchId)
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, batch.ToOpenAI())
+}
@@ -0,0 +1,594 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/storage"
+	"one-api/common/utils"
+	"one-api/middleware"
+	"one-api/model"
+	"one-api/relay"
+	"one-api/relay/files"
+	"one-api/relay/relay_util"
+	"one-api/types"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+)
+
+const (
+	// 单个批处理最多允许的请求数
+	maxBatchLines = 50000
+	// 批处理文件单行最大长度
+	maxLineBytes = 10 << 20
+
+	dispatchInterval = 10 * time.Second
+	watchInterval    = 5 * time.Second
+)
+
+var dispatchSignal = make(chan struct{}, 1)
+
+// InitBatch 启动批处理调度，仅在主节点运行
+func InitBatch() {
+	if !config.IsMasterNode {
+		return
+	}
+
+	recoverInterruptedBatches()
+
+	common.SafeGoroutine(func() {
+		dispatch()
+	})
+}
+
+func notifyDispatcher() {
+	select {
+	case dispatchSignal <- struct{}{}:
+	default:
+	}
+}
+
+// recoverInterruptedBatches 服务重启后继续执行被中断的批处理，已记录结果的请求行不会重复执行
+func recoverInterruptedBatches() {
+	batches, err := model.GetInterruptedBatches()
+	if err != nil {
+		logger.SysError("get interrupted batches error: " + err.Error())
+		return
+	}
+
+	for _, batch := range batches {
+		runner := &batchRunner{batch: batch}
+		common.SafeGoroutine(func() {
+			runner.run()
+		})
+	}
+}
+
+func dispatch() {
+	ticker := time.NewTicker(dispatchInterval)
+	defer ticker.Stop()
+
+	for {
+		batches, err := model.GetBatchesByStatus(types.BatchStatusValidating)
+		if err != nil {
+			logger.SysError("get validating batches error: " + err.Error())
+		}
+
+		for _, batch := range batches {
+			claimed, err := batch.UpdateStatus(types.BatchStatusValidating, map[string]any{
+				"status":         types.BatchStatusInProgress,
+				"in_progress_at": utils.GetTimestamp(),
+			})
+			if err != nil {
+				logger.SysError(fmt.Sprintf("claim batch %s error: %s", batch.BatchId, err.Error()))
+				continue
+			}
+			if !claimed {
+				continue
+			}
+
+			runner := &batchRunner{batch: batch}
+			common.SafeGoroutine(func() {
+				runner.run()
+			})
+		}
+
+		select {
+		case <-ticker.C:
+		case <-dispatchSignal:
+		}
+	}
+}
+
+type batchRunner struct {
+	batch *model.Batch
+	token *model.Token
+
+	cancelled atomic.Bool
+	expired   atomic.Bool
+}
+
+type lineResult struct {
+	success  bool
+	response *types.BatchResponseLine
+}
+
+// run 执行批处理，每行的进度和结果都会落库，服务重启后从未执行的请求行继续
+func (r *batchRunner) run() {
+	switch r.batch.Status {
+	case types.BatchStatusCancelling:
+		r.cancelled.Store(true)
+	case types.BatchStatusInProgress:
+		if !r.execute() {
+			return
+		}
+	}
+
+	r.finalize()
+}
+
+// execute 执行所有尚未发出的请求行，返回 false 时批处理已失败
+func (r *batchRunner) execute() bool {
+	lines, batchErrors, err := r.loadInput()
+	if err != nil {
+		r.fail(types.BatchError{Code: "invalid_input_file", Message: err.Error()})
+		return false
+	}
+	if len(batchErrors) > 0 {
+		r.fail(batchErrors...)
+		return false
+	}
+
+	if err := r.loadToken(); err != nil {
+		r.fail(types.BatchError{Code: "invalid_token", Message: err.Error()})
+		return false
+	}
+
+	customIds := make([]string, 0, len(lines))
+	for _, line := range lines {
+		customIds = append(customIds, line.CustomId)
+	}
+	if err := model.InitBatchLines(r.batch, customIds); err != nil {
+		r.fail(types.BatchError{Code: "server_error", Message: err.Error()})
+		return false
+	}
+
+	r.recoverRunningLines()
+
+	if utils.GetTimestamp() > r.batch.ExpiresAt {
+		r.expired.Store(true)
+		return true
+	}
+
+	pending, err := model.GetPendingBatchLineIndexes(r.batch.Id)
+	if err != nil {
+		r.fail(types.BatchError{Code: "server_error", Message: err.Error()})
+		return false
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.watch(ctx, cancel)
+
+	r.executeLines(ctx, lines, pending)
+	return true
+}
+
+// recoverRunningLines 服务重启前已发出但没有记录结果的请求可能已经计费，不重新执行，记为失败
+func (r *batchRunner) recoverRunningLines() {
+	running, err := model.GetRunningBatchLines(r.batch.Id)
+	if err != nil {
+		logger.SysError(fmt.Sprintf("get running lines of batch %s error: %s", r.batch.BatchId, err.Error()))
+		return
+	}
+
+	for _, line := range running {
+		response := newErrorLine(line.CustomId, "batch_interrupted", "The request was interrupted by a server restart and its result is unknown.")
+		if err := model.SaveBatchLineResult(r.batch.Id, line.LineIndex, false, response); err != nil {
+			logger.SysError(fmt.Sprintf("save line %d of batch %s error: %s", line.LineIndex, r.batch.BatchId, err.Error()))
+		}
+	}
+}
+
+// loadInput 读取并校验输入文件，返回可执行的请求行和校验错误
+func (r *batchRunner) loadInput() ([]*types.BatchRequestLine, []types.BatchError, error) {
+	inputFile, err := model.GetUserFileByFileId(r.batch.UserId, r.batch.InputFileId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := storage.ReadFile(inputFile.Drive, inputFile.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lines := make([]*types.BatchRequestLine, 0)
+	batchErrors := make([]types.BatchError, 0)
+	customIds := make(map[string]bool)
+	lineError := func(lineNo int, code, message string) {
+		batchErrors = append(batchErrors, types.BatchError{
+			Code:    code,
+			Message: message,
+			Line:    utils.GetPointer(lineNo),
+		})
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var line types.BatchRequestLine
+		if err := json.Unmarshal([]byte(text), &line); err != nil {
+			lineError(lineNo, "invalid_json_line", "This line is not parseable as valid JSON.")
+			continue
+		}
+
+		switch {
+		case line.CustomId == "":
+			lineError(lineNo, "missing_required_parameter", "Missing required parameter: 'custom_id'.")
+		case customIds[line.CustomId]:
+			lineError(lineNo, "duplicate_custom_id", "The custom_id for this request is a duplicate of another request.")
+		case !strings.EqualFold(line.Method, http.MethodPost):
+			lineError(lineNo, "invalid_method", "The method for this request must be POST.")
+		case line.Url != r.batch.Endpoint:
+			lineError(lineNo, "mismatched_endpoint", fmt.Sprintf("The url for this request does not match the batch endpoint %s.", r.batch.Endpoint))
+		case len(line.Body) == 0:
+			lineError(lineNo, "missing_required_parameter", "Missing required parameter: 'body'.")
+		case isStreamRequest(line.Body):
+			lineError(lineNo, "invalid_request", "Streaming is not supported in batch requests.")
+		default:
+			customIds[line.CustomId] = true
+			lines = append(lines, &line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(lines)+len(batchErrors) == 0 {
+		return nil, nil, errors.New("the input file is empty")
+	}
+
+	if lineNo > maxBatchLines {
+		return nil, nil, fmt.Errorf("the input file exceeds the maximum of %d requests", maxBatchLines)
+	}
+
+	return lines, batchErrors, nil
+}
+
+func isStreamRequest(body json.RawMessage) bool {
+	var request struct {
+		Stream bool `json:"stream"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return false
+	}
+
+	return request.Stream
+}
+
+// loadToken 使用创建批处理时的令牌执行请求，每行请求仍会重新检查令牌状态、分组和限流
+func (r *batchRunner) loadToken() error {
+	token, err := model.GetTokenById(r.batch.TokenId)
+	if err != nil {
+		return err
+	}
+	if token.Status != config.TokenStatusEnabled {
+		return errors.New("the token used to create this batch is no longer available")
+	}
+
+	r.token = token
+	return nil
+}
+
+// watch 定期检查批处理是否被取消或已超过完成时限
+func (r *batchRunner) watch(ctx context.Context, cancel context.CancelFunc) {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if utils.GetTimestamp() > r.batch.ExpiresAt {
+			r.expired.Store(true)
+			cancel()
+			return
+		}
+
+		batch, err := model.GetBatchByBatchId(r.batch.BatchId)
+		if err != nil {
+			continue
+		}
+		if batch.Status == types.BatchStatusCancelling {
+			r.cancelled.Store(true)
+			cancel()
+			return
+		}
+	}
+}
+
+func (r *batchRunner) executeLines(ctx context.Context, lines []*types.BatchRequestLine, pending []int) {
+	concurrency := config.BatchConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for _, index := range pending {
+		if index >= len(lines) {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case semaphore <- struct{}{}:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		common.SafeGoroutine(func() {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			r.runLine(ctx, index, lines[index])
+		})
+	}
+
+	wg.Wait()
+}
+
+// runLine 标记请求行为执行中后发出请求并保存结果，没有发出请求时恢复为待执行
+func (r *batchRunner) runLine(ctx context.Context, index int, line *types.BatchRequestLine) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	claimed, err := model.ClaimBatchLine(r.batch.Id, index)
+	if err != nil {
+		logger.SysError(fmt.Sprintf("claim line %d of batch %s error: %s", index, r.batch.BatchId, err.Error()))
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	result := r.executeLine(ctx, line)
+	if err := model.SaveBatchLineResult(r.batch.Id, index, result.success, result.response); err != nil {
+		logger.SysError(fmt.Sprintf("save line %d of batch %s error: %s", index, r.batch.BatchId, err.Error()))
+	}
+}
+
+// relayLine 转发单行请求，测试时替换
+var relayLine gin.HandlerFunc = relay.Relay
+
+// lineHandlers 单行请求依次经过的处理函数，与在线请求的中间件链一致：令牌状态和接口范围、分组、请求限流，模型限制在转发时检查
+func (r *batchRunner) lineHandlers() []gin.HandlerFunc {
+	return []gin.HandlerFunc{
+		middleware.InternalTokenAuth(r.token.Key),
+		middleware.Distribute(),
+		middleware.DynamicRedisRateLimiter(),
+		relayLine,
+	}
+}
+
+// executeLine 构造一个内部请求，经过与在线请求相同的检查后走相同的转发、重试和计费流程
+// 请求发出后不再响应取消，执行完毕并记录结果，避免已计费的请求被重新执行或丢失
+func (r *batchRunner) executeLine(ctx context.Context, line *types.BatchRequestLine) lineResult {
+	requestId := utils.GetTimeString() + utils.GetRandomString(8)
+	requestCtx := context.WithValue(context.WithoutCancel(ctx), logger.RequestIdKey, requestId)
+
+	request, err := http.NewRequestWithContext(requestCtx, http.MethodPost, line.Url, bytes.NewReader(line.Body))
+	if err != nil {
+		return lineResult{
+			response: newErrorLine(line.CustomId, "invalid_request", err.Error()),
+		}
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = request
+
+	c.Set(logger.RequestIdKey, requestId)
+	c.Set("requestStartTime", time.Now())
+	c.Set(relay_util.BatchIdKey, r.batch.BatchId)
+
+	// 内部请求没有路由的处理链，中间件中的 c.Next() 不会执行后续函数，这里按顺序调用
+	for _, handler := range r.lineHandlers() {
+		handler(c)
+		if c.IsAborted() {
+			break
+		}
+	}
+
+	statusCode := c.Writer.Status()
+	return lineResult{
+		success: statusCode >= http.StatusOK && statusCode < http.StatusMultipleChoices,
+		response: &types.BatchResponseLine{
+			Id:       newBatchRequestId(),
+			CustomId: line.CustomId,
+			Response: &types.BatchLineResponse{
+				StatusCode: statusCode,
+				RequestId:  requestId,
+				Body:       json.RawMessage(bytes.TrimSpace(recorder.Body.Bytes())),
+			},
+		},
+	}
+}
+
+// finalize 根据落库的请求行结果生成输出文件，重启后处于 finalizing 状态的批处理也从这里继续
+func (r *batchRunner) finalize() {
+	batch, err := r.transition(map[string]any{
+		"status":        types.BatchStatusFinalizing,
+		"finalizing_at": utils.GetTimestamp(),
+	})
+	if err != nil {
+		logger.SysError(fmt.Sprintf("update batch %s status error: %s", r.batch.BatchId, err.Error()))
+		return
+	}
+
+	var output, errorOutput bytes.Buffer
+	completed, failed := 0, 0
+	err = model.FindBatchLines(batch.Id, func(lines []*model.BatchLine) error {
+		for _, line := range lines {
+			switch line.Status {
+			case model.BatchLineStatusCompleted:
+				completed++
+				output.Write(line.Result)
+				output.WriteByte('\n')
+			case model.BatchLineStatusFailed:
+				failed++
+				errorOutput.Write(line.Result)
+				errorOutput.WriteByte('\n')
+			default:
+				// 取消时未执行的请求直接丢弃，超时或其他原因未执行的请求写入错误文件
+				if r.cancelled.Load() {
+					continue
+				}
+				response := newErrorLine(line.CustomId, "batch_expired", "This request could not be executed before the completion window expired.")
+				if !r.expired.Load() {
+					response = newErrorLine(line.CustomId, "server_error", "This request was not executed.")
+				}
+				failed++
+				errorOutput.WriteString(utils.Marshal(response) + "\n")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.SysError(fmt.Sprintf("load lines of batch %s error: %s", batch.BatchId, err.Error()))
+		return
+	}
+
+	now := utils.GetTimestamp()
+	updates := map[string]any{
+		"completed": completed,
+		"failed":    failed,
+	}
+
+	if output.Len() > 0 {
+		file, err := files.SaveFile(batch.UserId, batch.TokenId, types.FilePurposeBatchOutput, batch.BatchId+"_output.jsonl", output.Bytes(), 0)
+		if err != nil {
+			r.fail(types.BatchError{Code: "output_file_error", Message: err.Error()})
+			return
+		}
+		updates["output_file_id"] = file.FileId
+	}
+
+	if errorOutput.Len() > 0 {
+		file, err := files.SaveFile(batch.UserId, batch.TokenId, types.FilePurposeBatchOutput, batch.BatchId+"_error.jsonl", errorOutput.Bytes(), 0)
+		if err != nil {
+			r.fail(types.BatchError{Code: "output_file_error", Message: err.Error()})
+			return
+		}
+		updates["error_file_id"] = file.FileId
+	}
+
+	switch {
+	case r.cancelled.Load():
+		updates["status"] = types.BatchStatusCancelled
+		updates["cancelled_at"] = now
+	case r.expired.Load():
+		updates["status"] = types.BatchStatusExpired
+		updates["expired_at"] = now
+	default:
+		updates["status"] = types.BatchStatusCompleted
+		updates["completed_at"] = now
+	}
+
+	updated, err := batch.UpdateStatus(types.BatchStatusFinalizing, updates)
+	if err != nil {
+		logger.SysError(fmt.Sprintf("update batch %s status error: %s", batch.BatchId, err.Error()))
+		return
+	}
+	if updated {
+		r.deleteLines()
+	}
+}
+
+func (r *batchRunner) fail(batchErrors ...types.BatchError) {
+	_, err := r.transition(map[string]any{
+		"status":    types.BatchStatusFailed,
+		"failed_at": utils.GetTimestamp(),
+		"errors":    newBatchErrors(batchErrors...),
+	})
+	if err != nil {
+		logger.SysError(fmt.Sprintf("update batch %s status error: %s", r.batch.BatchId, err.Error()))
+		return
+	}
+	r.deleteLines()
+}
+
+func (r *batchRunner) deleteLines() {
+	if err := model.DeleteBatchLines(r.batch.Id); err != nil {
+		logger.SysError(fmt.Sprintf("delete lines of batch %s error: %s", r.batch.BatchId, err.Error()))
+	}
+}
+
+// transition 执行期间状态可能被取消接口修改，基于最新状态重试更新
+func (r *batchRunner) transition(updates map[string]any) (*model.Batch, error) {
+	for i := 0; i < 3; i++ {
+		batch, err := model.GetBatchByBatchId(r.batch.BatchId)
+		if err != nil {
+			return nil, err
+		}
+
+		updated, err := batch.UpdateStatus(batch.Status, updates)
+		if err != nil {
+			return nil, err
+		}
+		if updated {
+			return batch, nil
+		}
+	}
+
+	return nil, errors.New("batch status changed concurrently")
+}
+
+func newBatchErrors(batchErrors ...types.BatchError) datatypes.JSONType[[]types.BatchError] {
+	return datatypes.NewJSONType(batchErrors)
+}
+
+func newBatchRequestId() string {
+	return "batch_req_" + utils.GetRandomString(24)
+}
+
+func newErrorLine(customId, code, message string) *types.BatchResponseLine {
+	return &types.BatchResponseLine{
+		Id:       newBatchRequestId(),
+		CustomId: customId,
+		Error: &types.BatchError{
+			Code:    code,
+			Message: message,
+		},
+	}
+}
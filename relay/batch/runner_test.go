@@ -0,0 +1,350 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/storage"
+	"one-api/common/storage/drives"
+	"one-api/common/utils"
+	"one-api/model"
+	"one-api/relay/files"
+	"one-api/types"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	logger.Logger = zap.NewNop()
+
+	dir, err := os.MkdirTemp("", "one-api-batch")
+	if err != nil {
+		panic(err)
+	}
+	storage.AddFileDrive(drives.NewLocalDrive(dir))
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+type relayCall struct {
+	customId   string
+	tokenGroup string
+	groupRatio float64
+}
+
+type batchTest struct {
+	user  *model.User
+	token *model.Token
+
+	mu    sync.Mutex
+	calls []relayCall
+}
+
+// setupBatchTest 每个测试使用独立的用户，避免共用内存中的请求限流计数
+func setupBatchTest(t *testing.T, userId, apiRate int, scopes model.TokenScopes) *batchTest {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "one-api.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.File{}, &model.Batch{}, &model.BatchLine{}, &model.Token{}, &model.User{}, &model.UserGroup{}))
+
+	savedDB, savedRedis := model.DB, config.RedisEnabled
+	model.DB, config.RedisEnabled = db, false
+	t.Cleanup(func() {
+		model.DB, config.RedisEnabled = savedDB, savedRedis
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	require.NoError(t, db.Create(&model.UserGroup{Symbol: "default", Name: "default", Ratio: 1.5, APIRate: apiRate}).Error)
+	model.GlobalUserGroupRatio.Load()
+
+	test := &batchTest{
+		user: &model.User{Id: userId, Username: fmt.Sprintf("batch%d", userId), Status: config.UserStatusEnabled, Group: "default"},
+		token: &model.Token{
+			UserId:         userId,
+			Key:            utils.GetRandomString(48),
+			Status:         config.TokenStatusEnabled,
+			ExpiredTime:    -1,
+			UnlimitedQuota: true,
+		},
+	}
+	test.token.Setting.Set(model.TokenSetting{Scopes: scopes})
+	session := db.Session(&gorm.Session{SkipHooks: true})
+	require.NoError(t, session.Create(test.user).Error)
+	require.NoError(t, session.Create(test.token).Error)
+
+	savedRelayLine := relayLine
+	relayLine = func(c *gin.Context) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(c.Request.Body).Decode(&body)
+
+		test.mu.Lock()
+		test.calls = append(test.calls, relayCall{
+			customId:   body.Model,
+			tokenGroup: c.GetString("token_group"),
+			groupRatio: c.GetFloat64("group_ratio"),
+		})
+		test.mu.Unlock()
+
+		c.JSON(http.StatusOK, gin.H{"model": body.Model})
+	}
+	t.Cleanup(func() { relayLine = savedRelayLine })
+
+	return test
+}
+
+// createBatch 创建执行中的批处理，每行请求的 model 与 custom_id 相同，便于核对转发的请求
+func (bt *batchTest) createBatch(t *testing.T, customIds ...string) *model.Batch {
+	t.Helper()
+
+	var input bytes.Buffer
+	for _, customId := range customIds {
+		input.WriteString(utils.Marshal(map[string]any{
+			"custom_id": customId,
+			"method":    "POST",
+			"url":       "/v1/chat/completions",
+			"body":      map[string]any{"model": customId},
+		}) + "\n")
+	}
+	inputFile, err := files.SaveFile(bt.user.Id, bt.token.Id, types.FilePurposeBatch, "input.jsonl", input.Bytes(), 0)
+	require.NoError(t, err)
+
+	now := utils.GetTimestamp()
+	batch := &model.Batch{
+		BatchId:          model.NewBatchId(),
+		UserId:           bt.user.Id,
+		TokenId:          bt.token.Id,
+		Endpoint:         "/v1/chat/completions",
+		InputFileId:      inputFile.FileId,
+		CompletionWindow: "24h",
+		Status:           types.BatchStatusInProgress,
+		CreatedAt:        now,
+		InProgressAt:     now,
+		ExpiresAt:        now + 3600,
+	}
+	require.NoError(t, batch.Insert())
+	return batch
+}
+
+func (bt *batchTest) calledIds() []string {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	ids := make([]string, 0, len(bt.calls))
+	for _, call := range bt.calls {
+		ids = append(ids, call.customId)
+	}
+	return ids
+}
+
+func readBatchOutput(t *testing.T, userId int, fileId string) map[string]*types.BatchResponseLine {
+	t.Helper()
+
+	lines := make(map[string]*types.BatchResponseLine)
+	if fileId == "" {
+		return lines
+	}
+
+	file, err := model.GetUserFileByFileId(userId, fileId)
+	require.NoError(t, err)
+	data, err := storage.ReadFile(file.Drive, file.StorageKey)
+	require.NoError(t, err)
+
+	reader := bufio.NewReader(bytes.NewReader(data))
+	for {
+		text, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(text)) > 0 {
+			var line types.BatchResponseLine
+			require.NoError(t, json.Unmarshal(text, &line))
+			lines[line.CustomId] = &line
+		}
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+	return lines
+}
+
+func runBatch(t *testing.T, batch *model.Batch) *model.Batch {
+	t.Helper()
+
+	runner := &batchRunner{batch: batch}
+	runner.run()
+
+	batch, err := model.GetBatchByBatchId(batch.BatchId)
+	require.NoError(t, err)
+	return batch
+}
+
+func TestBatchRunnerExecutesLinesThroughMiddleware(t *testing.T) {
+	bt := setupBatchTest(t, 101, 600, model.TokenScopes{})
+	batch := runBatch(t, bt.createBatch(t, "req-1", "req-2"))
+
+	assert.Equal(t, types.BatchStatusCompleted, batch.Status)
+	assert.Equal(t, 2, batch.Total)
+	assert.Equal(t, 2, batch.Completed)
+	assert.Equal(t, 0, batch.Failed)
+	assert.ElementsMatch(t, []string{"req-1", "req-2"}, bt.calledIds())
+
+	// 分组和倍率由 Distribute 中间件按用户分组设置
+	for _, call := range bt.calls {
+		assert.Equal(t, "default", call.tokenGroup)
+		assert.Equal(t, 1.5, call.groupRatio)
+	}
+
+	output := readBatchOutput(t, bt.user.Id, batch.OutputFileId)
+	require.Len(t, output, 2)
+	assert.Equal(t, http.StatusOK, output["req-1"].Response.StatusCode)
+	assert.JSONEq(t, `{"model":"req-1"}`, string(output["req-1"].Response.Body))
+
+	// 完成后删除逐行的进度记录
+	var count int64
+	require.NoError(t, model.DB.Model(&model.BatchLine{}).Where("batch_id = ?", batch.Id).Count(&count).Error)
+	assert.Zero(t, count)
+}
+
+func TestBatchRunnerChecksTokenScopes(t *testing.T) {
+	// 令牌的接口范围在创建批处理后被修改为只允许 embeddings
+	bt := setupBatchTest(t, 102, 600, model.TokenScopes{Endpoints: []string{model.TokenScopeEmbeddings}})
+	batch := runBatch(t, bt.createBatch(t, "req-1"))
+
+	assert.Equal(t, types.BatchStatusCompleted, batch.Status)
+	assert.Equal(t, 1, batch.Failed)
+	assert.Empty(t, bt.calledIds())
+
+	errorLines := readBatchOutput(t, bt.user.Id, batch.ErrorFileId)
+	require.Contains(t, errorLines, "req-1")
+	assert.Equal(t, http.StatusForbidden, errorLines["req-1"].Response.StatusCode)
+	assert.Contains(t, string(errorLines["req-1"].Response.Body), model.TokenScopeErrEndpoint)
+}
+
+func TestBatchRunnerAppliesRateLimit(t *testing.T) {
+	bt := setupBatchTest(t, 103, 1, model.TokenScopes{})
+	batch := runBatch(t, bt.createBatch(t, "req-1", "req-2", "req-3"))
+
+	assert.Equal(t, types.BatchStatusCompleted, batch.Status)
+	assert.Equal(t, 1, batch.Completed)
+	assert.Equal(t, 2, batch.Failed)
+	assert.Len(t, bt.calledIds(), 1)
+
+	for _, line := range readBatchOutput(t, bt.user.Id, batch.ErrorFileId) {
+		assert.Equal(t, http.StatusTooManyRequests, line.Response.StatusCode)
+	}
+}
+
+func TestBatchRunnerDisabledToken(t *testing.T) {
+	bt := setupBatchTest(t, 104, 600, model.TokenScopes{})
+	batch := bt.createBatch(t, "req-1")
+	require.NoError(t, model.DB.Model(bt.token).Update("status", config.TokenStatusDisabled).Error)
+
+	batch = runBatch(t, batch)
+	assert.Equal(t, types.BatchStatusFailed, batch.Status)
+	require.Len(t, batch.Errors.Data(), 1)
+	assert.Equal(t, "invalid_token", batch.Errors.Data()[0].Code)
+	assert.Empty(t, bt.calledIds())
+}
+
+func TestBatchRunnerResumesAfterRestart(t *testing.T) {
+	bt := setupBatchTest(t, 105, 600, model.TokenScopes{})
+	batch := bt.createBatch(t, "done", "in-flight", "pending")
+
+	// 模拟重启前的进度：第一行已完成，第二行已发出但没有记录结果，第三行未执行
+	require.NoError(t, model.InitBatchLines(batch, []string{"done", "in-flight", "pending"}))
+	claimed, err := model.ClaimBatchLine(batch.Id, 0)
+	require.NoError(t, err)
+	require.True(t, claimed)
+	require.NoError(t, model.SaveBatchLineResult(batch.Id, 0, true, &types.BatchResponseLine{
+		Id:       "batch_req_done",
+		CustomId: "done",
+		Response: &types.BatchLineResponse{StatusCode: http.StatusOK, Body: json.RawMessage(`{"model":"done"}`)},
+	}))
+	claimed, err = model.ClaimBatchLine(batch.Id, 1)
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	recoverInterruptedBatchesSync(t)
+
+	batch, err = model.GetBatchByBatchId(batch.BatchId)
+	require.NoError(t, err)
+	assert.Equal(t, types.BatchStatusCompleted, batch.Status)
+	assert.Equal(t, 3, batch.Total)
+	assert.Equal(t, 2, batch.Completed)
+	assert.Equal(t, 1, batch.Failed)
+
+	// 只执行未发出的请求，已完成和可能已计费的请求都不会重新执行
+	assert.Equal(t, []string{"pending"}, bt.calledIds())
+
+	output := readBatchOutput(t, bt.user.Id, batch.OutputFileId)
+	assert.Len(t, output, 2)
+	assert.Equal(t, "batch_req_done", output["done"].Id)
+	assert.Contains(t, output, "pending")
+
+	errorLines := readBatchOutput(t, bt.user.Id, batch.ErrorFileId)
+	require.Contains(t, errorLines, "in-flight")
+	assert.Equal(t, "batch_interrupted", errorLines["in-flight"].Error.Code)
+}
+
+func TestBatchRunnerFinishesCancellingBatchAfterRestart(t *testing.T) {
+	bt := setupBatchTest(t, 106, 600, model.TokenScopes{})
+	batch := bt.createBatch(t, "done", "pending")
+
+	require.NoError(t, model.InitBatchLines(batch, []string{"done", "pending"}))
+	_, err := model.ClaimBatchLine(batch.Id, 0)
+	require.NoError(t, err)
+	require.NoError(t, model.SaveBatchLineResult(batch.Id, 0, true, &types.BatchResponseLine{
+		Id:       "batch_req_done",
+		CustomId: "done",
+		Response: &types.BatchLineResponse{StatusCode: http.StatusOK, Body: json.RawMessage(`{}`)},
+	}))
+	require.NoError(t, model.DB.Model(batch).Update("status", types.BatchStatusCancelling).Error)
+
+	recoverInterruptedBatchesSync(t)
+
+	batch, err = model.GetBatchByBatchId(batch.BatchId)
+	require.NoError(t, err)
+	assert.Equal(t, types.BatchStatusCancelled, batch.Status)
+	assert.Equal(t, 1, batch.Completed)
+	assert.Empty(t, bt.calledIds())
+
+	// 已完成的结果仍然写入输出文件，未执行的请求直接丢弃
+	output := readBatchOutput(t, bt.user.Id, batch.OutputFileId)
+	assert.Len(t, output, 1)
+	assert.Empty(t, batch.ErrorFileId)
+}
+
+// recoverInterruptedBatchesSync 与 recoverInterruptedBatches 相同，但在当前协程中执行完毕
+func recoverInterruptedBatchesSync(t *testing.T) {
+	t.Helper()
+
+	batches, err := model.GetInterruptedBatches()
+	require.NoError(t, err)
+	for _, batch := range batches {
+		runner := &batchRunner{batch: batch}
+		runner.run()
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"one-api/common/requester"
 	"one-api/common/utils"
 	providersBase "one-api/providers/base"
+	"one-api/relay/relay_util"
 	"one-api/safty"
 	"one-api/types"
 	"time"
@@ -105,21 +106,25 @@ func (r *relayChat) send() (err *types.OpenAIErrorWithStatusCode, done bool) {
 	r.chatRequest.Model = r.modelName
 	// 内容审查
 	if config.EnableSafe {
-		for _, message := range r.chatRequest.Messages {
-			if message.Content != nil {
-				CheckResult, _ := safty.CheckContent(message.Content)
-				if !CheckResult.IsSafe {
-					err = common.StringErrorWrapperLocal(CheckResult.Reason, CheckResult.Code, http.StatusBadRequest)
-					done = true
-					return
-				}
-			}
+		CheckResult, _ := safty.CheckFields(&r.chatRequest)
+		if !CheckResult.IsSafe {
+			err = common.StringErrorWrapperLocal(CheckResult.Reason, CheckResult.Code, http.StatusBadRequest)
+			done = true
+			return
 		}
 	}
 
+	chatCache := relay_util.NewChatCache(r.c, &r.chatRequest)
+
 	if r.chatRequest.Stream {
 		var response requester.StreamReaderInterface[string]
-		response, err = chatProvider.CreateChatCompletionStream(&r.chatRequest)
+		if timeout := getFirstTokenTimeout(r.c, r.originalModel); timeout > 0 {
+			response, err = r.hedgeStream(timeout, r.createStream)
+			// 对冲请求可能由其他渠道胜出
+			r.chatRequest.Model = r.modelName
+		} else {
+			response, err = chatProvider.CreateChatCompletionStream(&r.chatRequest)
+		}
 		if err != nil {
 			return
 		}
@@ -132,9 +137,11 @@ func (r *relayChat) send() (err *types.OpenAIErrorWithStatusCode, done bool) {
 			return r.getUsageResponse()
 		}
 
+		response = chatCache.WrapStream(response)
 		var firstResponseTime time.Time
 		firstResponseTime, err = responseStreamClient(r.c, response, doneStr)
 		r.SetFirstResponseTime(firstResponseTime)
+		chatCache.StoreStream(response, r.provider.GetUsage())
 	} else {
 		var response *types.ChatCompletionResponse
 		response, err = chatProvider.CreateChatCompletion(&r.chatRequest)
@@ -146,8 +153,10 @@ func (r *relayChat) send() (err *types.OpenAIErrorWithStatusCode, done bool) {
 			r.heartbeat.Stop()
 		}
 
-		err = responseJsonClient(r.c, response)
-
+		err = responseModeratedJsonClient(r.c, response, moderateChatResponse(r.c, response))
+		if err == nil {
+			chatCache.Store(response, r.provider.GetUsage())
+		}
 	}
 
 	if err != nil {
@@ -157,6 +166,57 @@ func (r *relayChat) send() (err *types.OpenAIErrorWithStatusCode, done bool) {
 	return
 }
 
+// createStream 对冲时每个渠道使用独立的请求副本，模型名为渠道映射后的名称
+func (r *relayChat) createStream(provider providersBase.ProviderInterface, modelName string) func() (requester.StreamReaderInterface[string], *types.OpenAIErrorWithStatusCode) {
+	request := r.chatRequest
+	request.Model = modelName
+
+	return func() (requester.StreamReaderInterface[string], *types.OpenAIErrorWithStatusCode) {
+		chatProvider, ok := provider.(providersBase.ChatInterface)
+		if !ok {
+			return nil, common.StringErrorWrapperLocal("channel not implemented", "channel_error", http.StatusServiceUnavailable)
+		}
+
+		return chatProvider.CreateChatCompletionStream(&request)
+	}
+}
+
+// sendCache 在占用渠道资源前查询对话缓存，命中时直接回放结果，不再请求上游
+// 缓存键包含用户和完整的消息，缓存的结果在写入时已经过内容审查
+func (r *relayChat) sendCache() bool {
+	if need2Response[r.modelName] {
+		if _, ok := r.provider.(providersBase.ResponsesInterface); ok {
+			return false
+		}
+	}
+
+	if _, ok := r.provider.(providersBase.ChatInterface); !ok {
+		return false
+	}
+
+	r.chatRequest.Model = r.modelName
+	chatCache := relay_util.NewChatCache(r.c, &r.chatRequest)
+	cacheItem := chatCache.Get()
+	if cacheItem == nil {
+		return false
+	}
+
+	if r.heartbeat != nil {
+		r.heartbeat.Stop()
+	}
+
+	usage := r.provider.GetUsage()
+	usage.PromptTokens = cacheItem.PromptTokens
+	usage.CompletionTokens = cacheItem.CompletionTokens
+	usage.TotalTokens = cacheItem.PromptTokens + cacheItem.CompletionTokens
+
+	includeUsage := r.chatRequest.StreamOptions != nil && r.chatRequest.StreamOptions.IncludeUsage
+	responseCache(r.c, chatCache.Render(cacheItem, r.chatRequest.Stream, includeUsage), r.chatRequest.Stream)
+	r.SetFirstResponseTime(time.Now())
+
+	return true
+}
+
 func (r *relayChat) getUsageResponse() string {
 	if r.chatRequest.StreamOptions != nil && r.chatRequest.StreamOptions.IncludeUsage {
 		usageResponse := types.ChatCompletionStreamResponse{
@@ -211,7 +271,8 @@ func (r *relayChat) compatibleSend(resProvider providersBase.ResponsesInterface)
 		if r.heartbeat != nil {
 			r.heartbeat.Stop()
 		}
-		err = responseJsonClient(r.c, response.ToChat())
+		chatResponse := response.ToChat()
+		err = responseModeratedJsonClient(r.c, chatResponse, moderateChatResponse(r.c, chatResponse))
 	}
 
 	if err != nil {
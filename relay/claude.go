@@ -6,6 +6,7 @@ import (
 	"one-api/common"
 	"one-api/common/config"
 	"one-api/common/requester"
+	providersBase "one-api/providers/base"
 	"one-api/providers/claude"
 	"one-api/safty"
 	"one-api/types"
@@ -14,15 +15,12 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-var AllowChannelType = []int{config.ChannelTypeAnthropic, config.ChannelTypeVertexAI, config.ChannelTypeBedrock}
-
 type relayClaudeOnly struct {
 	relayBase
 	claudeRequest *claude.ClaudeRequest
 }
 
 func NewRelayClaudeOnly(c *gin.Context) *relayClaudeOnly {
-	c.Set("allow_channel_type", AllowChannelType)
 	relay := &relayClaudeOnly{
 		relayBase: relayBase{
 			allowHeartbeat: true,
@@ -57,7 +55,8 @@ func (r *relayClaudeOnly) getPromptTokens() (int, error) {
 
 func (r *relayClaudeOnly) send() (err *types.OpenAIErrorWithStatusCode, done bool) {
 	chatProvider, ok := r.provider.(claude.ClaudeChatInterface)
-	if !ok {
+	_, isOpenAIChat := r.provider.(providersBase.ChatInterface)
+	if !ok && !isOpenAIChat {
 		err = common.StringErrorWrapperLocal("channel not implemented", "channel_error", http.StatusServiceUnavailable)
 		done = true
 		return
@@ -66,18 +65,19 @@ func (r *relayClaudeOnly) send() (err *types.OpenAIErrorWithStatusCode, done boo
 	r.claudeRequest.Model = r.modelName
 	// 内容审查
 	if config.EnableSafe {
-		for _, message := range r.claudeRequest.Messages {
-			if message.Content != nil {
-				CheckResult, _ := safty.CheckContent(message.Content)
-				if !CheckResult.IsSafe {
-					err = common.StringErrorWrapperLocal(CheckResult.Reason, CheckResult.Code, http.StatusBadRequest)
-					done = true
-					return
-				}
-			}
+		CheckResult, _ := safty.CheckFields(r.claudeRequest)
+		if !CheckResult.IsSafe {
+			err = common.StringErrorWrapperLocal(CheckResult.Reason, CheckResult.Code, http.StatusBadRequest)
+			done = true
+			return
 		}
 	}
 
+	// 渠道不支持 Claude 格式时，转换为 OpenAI 格式请求
+	if !ok {
+		return r.sendByChatOpenai()
+	}
+
 	if r.claudeRequest.Stream {
 		var response requester.StreamReaderInterface[string]
 		response, err = chatProvider.CreateClaudeChatStream(r.claudeRequest)
@@ -92,7 +92,7 @@ func (r *relayClaudeOnly) send() (err *types.OpenAIErrorWithStatusCode, done boo
 		doneStr := func() string {
 			return ""
 		}
-		firstResponseTime := responseGeneralStreamClient(r.c, response, doneStr)
+		firstResponseTime := responseGeneralStreamClient(r.c, response, doneStr, outputFormatClaude)
 		r.SetFirstResponseTime(firstResponseTime)
 	} else {
 		var response *claude.ClaudeResponse
@@ -118,6 +118,56 @@ func (r *relayClaudeOnly) send() (err *types.OpenAIErrorWithStatusCode, done boo
 	return
 }
 
+// sendByChatOpenai 上游请求失败时不标记 done，以便重试或切换渠道；已向客户端写出内容后不再重试
+func (r *relayClaudeOnly) sendByChatOpenai() (err *types.OpenAIErrorWithStatusCode, done bool) {
+	chatProvider := r.provider.(providersBase.ChatInterface)
+
+	chatRequest, err := claude.ConvertToChatOpenaiRequest(r.claudeRequest)
+	if err != nil {
+		done = true
+		return
+	}
+
+	if chatRequest.Stream {
+		var response requester.StreamReaderInterface[string]
+		response, err = chatProvider.CreateChatCompletionStream(chatRequest)
+		if err != nil {
+			done = err.LocalError
+			return
+		}
+
+		if r.heartbeat != nil {
+			r.heartbeat.Stop()
+		}
+
+		doneStr := func() string {
+			return ""
+		}
+		stream := claude.NewChatOpenaiToClaudeStream(response, r.modelName, r.provider.GetUsage)
+		firstResponseTime := responseGeneralStreamClient(r.c, stream, doneStr, outputFormatClaude)
+		r.SetFirstResponseTime(firstResponseTime)
+
+		return
+	}
+
+	var response *types.ChatCompletionResponse
+	response, err = chatProvider.CreateChatCompletion(chatRequest)
+	if err != nil {
+		done = err.LocalError
+		return
+	}
+
+	if r.heartbeat != nil {
+		r.heartbeat.Stop()
+	}
+
+	err = responseJsonClient(r.c, claude.ConvertChatOpenaiToClaude(response, r.modelName, r.provider.GetUsage()))
+	if err != nil {
+		done = true
+	}
+	return
+}
+
 func (r *relayClaudeOnly) GetError(err *types.OpenAIErrorWithStatusCode) (int, any) {
 	newErr := FilterOpenAIErr(r.c, err)
 
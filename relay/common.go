@@ -25,6 +25,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// 设置后选择渠道时不排队等待，用于对冲等不能阻塞的场景
+const channelWaitDisabledKey = "channel_wait_disabled"
+
 func Path2Relay(c *gin.Context, path string) RelayBaseInterface {
 	var relay RelayBaseInterface
 	if strings.HasPrefix(path, "/v1/chat/completions") {
@@ -75,6 +78,11 @@ func checkLimitModel(c *gin.Context, modelName string) (error error) {
 		return nil
 	}
 
+	// 令牌范围中的模型通配符和正则
+	if err := setting.Scopes.CheckModel(modelName); err != nil {
+		return err
+	}
+
 	// 检查是否启用了模型限制
 	if !setting.Limits.LimitModelSetting.Enabled {
 		// 未启用模型限制，允许所有模型
@@ -103,7 +111,12 @@ func GetProvider(c *gin.Context, modelName string) (provider providersBase.Provi
 	// 检查模型限制
 	if modelName != "" {
 		if err := checkLimitModel(c, modelName); err != nil {
-			c.AbortWithStatus(http.StatusNotFound)
+			statusCode := http.StatusNotFound
+			var scopeErr *model.TokenScopeError
+			if errors.As(err, &scopeErr) {
+				statusCode = scopeErr.StatusCode
+			}
+			c.AbortWithStatus(statusCode)
 			return nil, "", err
 		}
 	}
@@ -144,12 +157,38 @@ func fetchChannel(c *gin.Context, modelName string) (channel *model.Channel, fai
 	channelId := c.GetInt("specific_channel_id")
 	ignore := c.GetBool("specific_channel_id_ignore")
 	if channelId > 0 && !ignore {
-		return fetchChannelById(channelId)
+		channel, fail = fetchChannelById(channelId)
+		if fail == nil {
+			if scopes := getTokenScopes(c); scopes != nil {
+				if err := scopes.CheckChannel(channel); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return
 	}
 
 	return fetchChannelByModel(c, modelName)
 }
 
+// providerErrorWrapper 令牌范围限制返回对应的错误码，其他错误视为没有可用渠道
+func providerErrorWrapper(err error) *types.OpenAIErrorWithStatusCode {
+	var scopeErr *model.TokenScopeError
+	if errors.As(err, &scopeErr) {
+		return common.StringErrorWrapperLocal(scopeErr.Message, scopeErr.Code, scopeErr.StatusCode)
+	}
+
+	return common.StringErrorWrapperLocal(err.Error(), "one_hub_error", http.StatusServiceUnavailable)
+}
+
+// getTokenScopes 获取当前令牌的使用范围
+func getTokenScopes(c *gin.Context) *model.TokenScopes {
+	if setting, ok := utils.GetGinValue[*model.TokenSetting](c, "token_setting"); ok && setting != nil {
+		return &setting.Scopes
+	}
+	return nil
+}
+
 func fetchChannelById(channelId int) (*model.Channel, error) {
 	channel, err := model.GetChannelById(channelId)
 	if err != nil {
@@ -258,12 +297,64 @@ func fetchChannelByModel(c *gin.Context, modelName string) (*model.Channel, erro
 		filters = append(filters, model.FilterDisabledStream(modelName))
 	}
 
+	scopes := getTokenScopes(c)
+	if scopes.HasChannelScope() {
+		filters = append(filters, model.FilterTokenScope(scopes))
+	}
+
 	// 使用统一的分组管理器
 	groupManager := NewGroupManager(c)
-	return groupManager.TryWithGroups(modelName, filters, func(group string) (*model.Channel, error) {
-		return model.ChannelGroup.Next(group, modelName, filters...)
+	channel, err := groupManager.TryWithGroups(modelName, filters, func(group string) (*model.Channel, error) {
+		return nextChannelWithWait(c, &model.RoutingContext{
+			Group:     group,
+			ModelName: modelName,
+			StickyKey: getStickyKey(c),
+		}, filters)
 	})
+	if err != nil && scopes.HasChannelScope() {
+		return nil, scopes.NoChannelError(err)
+	}
+
+	return channel, err
+}
+
+// nextChannelWithWait 渠道全部繁忙时按优先级排队，等待有渠道恢复或释放名额
+func nextChannelWithWait(c *gin.Context, routing *model.RoutingContext, filters []model.ChannelsFilterFunc) (*model.Channel, error) {
+	next := func() (*model.Channel, error) {
+		return model.ChannelGroup.NextByRouting(routing, filters...)
+	}
+
+	if c.GetBool(channelWaitDisabledKey) {
+		return next()
+	}
+
+	channel, err := model.ChannelQueue.Next(c.Request.Context(), routing.Group, routing.ModelName, getQueueClass(c), next)
+	if errors.Is(err, model.ErrChannelBusy) || errors.Is(err, model.ErrChannelQueueFull) {
+		logger.LogWarn(c.Request.Context(), fmt.Sprintf("all channels of group %s for model %s are busy: %s", routing.Group, routing.ModelName, err.Error()))
+	}
+
+	return channel, err
+}
+
+// getQueueClass 排队优先级由用户分组决定，令牌可以降低自己的优先级
+func getQueueClass(c *gin.Context) string {
+	tokenClass := ""
+	if setting, ok := utils.GetGinValue[*model.TokenSetting](c, "token_setting"); ok && setting != nil {
+		tokenClass = setting.Limits.QueueClass
+	}
+
+	return model.GetQueueClass(model.GlobalUserGroupRatio.GetQueueClass(c.GetString("group")), tokenClass)
+}
 
+// getStickyKey 会话粘滞路由的键，优先使用请求头中的会话 id，否则按用户粘滞
+func getStickyKey(c *gin.Context) string {
+	for _, header := range []string{"X-Session-Id", "X-Conversation-Id"} {
+		if sessionId := c.GetHeader(header); sessionId != "" {
+			return "session:" + sessionId
+		}
+	}
+
+	return fmt.Sprintf("user:%d", c.GetInt("id"))
 }
 
 func responseJsonClient(c *gin.Context, data interface{}) *types.OpenAIErrorWithStatusCode {
@@ -298,6 +389,21 @@ func responseStreamClient(c *gin.Context, stream requester.StreamReaderInterface
 
 	var isFirstResponse bool
 
+	// 分组开启输出审核时，数据块通过检查后才发送给客户端
+	moderator := newOutputModerator(c, outputFormatOpenAI)
+	var moderated bool
+
+	writeData := func(streamData string) {
+		select {
+		case <-c.Request.Context().Done():
+			// 客户端已断开，不执行任何操作，直接跳过
+		default:
+			// 客户端正常，发送数据
+			c.Writer.Write([]byte(streamData))
+			c.Writer.Flush()
+		}
+	}
+
 	// 在新的goroutine中处理stream数据
 	go func() {
 		defer close(done)
@@ -308,63 +414,61 @@ func responseStreamClient(c *gin.Context, stream requester.StreamReaderInterface
 				if !ok {
 					return
 				}
-				streamData := "data: " + data + "\n\n"
 
 				if !isFirstResponse {
 					firstResponseTime = time.Now()
 					isFirstResponse = true
 				}
 
+				// 命中审核后丢弃剩余的内容，继续读取到结束以统计用量
+				if moderated {
+					continue
+				}
+
+				chunks := []string{data}
+				if moderator != nil {
+					var safe bool
+					if chunks, safe = moderator.push(data); !safe {
+						moderated = true
+						writeData("data: " + moderator.blockedChunk() + "\n\n")
+						continue
+					}
+				}
+
 				// 尝试写入数据，如果客户端断开也继续处理
-				select {
-				case <-c.Request.Context().Done():
-					// 客户端已断开，不执行任何操作，直接跳过
-				default:
-					// 客户端正常，发送数据
-					c.Writer.Write([]byte(streamData))
-					c.Writer.Flush()
+				for _, chunk := range chunks {
+					writeData("data: " + chunk + "\n\n")
 				}
 
 			case err := <-errChan:
+				if moderator != nil && !moderated {
+					chunks, safe := moderator.flush()
+					if !safe {
+						moderated = true
+						writeData("data: " + moderator.blockedChunk() + "\n\n")
+					}
+					for _, chunk := range chunks {
+						writeData("data: " + chunk + "\n\n")
+					}
+				}
+
 				if !errors.Is(err, io.EOF) {
 					// 处理错误情况
-					errMsg := "data: " + err.Error() + "\n\n"
-					select {
-					case <-c.Request.Context().Done():
-						// 客户端已断开，不执行任何操作，直接跳过
-					default:
-						// 客户端正常，发送错误信息
-						c.Writer.Write([]byte(errMsg))
-						c.Writer.Flush()
-					}
+					writeData("data: " + err.Error() + "\n\n")
 
 					finalErr = common.StringErrorWrapper(err.Error(), "stream_error", 900)
 					logger.LogError(c.Request.Context(), "Stream err:"+err.Error())
-				} else {
-					// 正常结束，处理endHandler
+				} else if !moderated || moderator.action != model.OutputModerationBlock {
+					// 正常结束，处理endHandler；截断输出时以错误事件结束，不再发送结束标记
 					if finalErr == nil && endHandler != nil {
 						streamData := endHandler()
 						if streamData != "" {
-							select {
-							case <-c.Request.Context().Done():
-								// 客户端已断开，不执行任何操作，直接跳过
-							default:
-								// 客户端正常，发送数据
-								c.Writer.Write([]byte("data: " + streamData + "\n\n"))
-								c.Writer.Flush()
-							}
+							writeData("data: " + streamData + "\n\n")
 						}
 					}
 
 					// 发送结束标记
-					streamData := "data: [DONE]\n\n"
-					select {
-					case <-c.Request.Context().Done():
-						// 客户端已断开，不执行任何操作，直接跳过
-					default:
-						c.Writer.Write([]byte(streamData))
-						c.Writer.Flush()
-					}
+					writeData("data: [DONE]\n\n")
 				}
 				return
 			}
@@ -376,7 +480,8 @@ func responseStreamClient(c *gin.Context, stream requester.StreamReaderInterface
 	return firstResponseTime, nil
 }
 
-func responseGeneralStreamClient(c *gin.Context, stream requester.StreamReaderInterface[string], endHandler StreamEndHandler) (firstResponseTime time.Time) {
+// responseGeneralStreamClient 原样转发 Claude、Gemini 和 Responses 等格式的流，format 用于输出审核
+func responseGeneralStreamClient(c *gin.Context, stream requester.StreamReaderInterface[string], endHandler StreamEndHandler, format string) (firstResponseTime time.Time) {
 	requester.SetEventStreamHeaders(c)
 	dataChan, errChan := stream.Recv()
 
@@ -387,6 +492,21 @@ func responseGeneralStreamClient(c *gin.Context, stream requester.StreamReaderIn
 	defer stream.Close()
 	var isFirstResponse bool
 
+	// 分组开启输出审核时，数据块通过检查后才发送给客户端
+	moderator := newOutputModerator(c, format)
+	var moderated bool
+
+	writeData := func(streamData string) {
+		select {
+		case <-c.Request.Context().Done():
+			// 客户端已断开，不执行任何操作，直接跳过
+		default:
+			// 客户端正常，发送数据
+			fmt.Fprint(c.Writer, streamData)
+			c.Writer.Flush()
+		}
+	}
+
 	// 在新的goroutine中处理stream数据
 	go func() {
 		defer close(done)
@@ -401,42 +521,50 @@ func responseGeneralStreamClient(c *gin.Context, stream requester.StreamReaderIn
 					firstResponseTime = time.Now()
 					isFirstResponse = true
 				}
+
+				// 命中审核后丢弃剩余的内容，继续读取到结束以统计用量
+				if moderated {
+					continue
+				}
+
+				chunks := []string{data}
+				if moderator != nil {
+					var safe bool
+					if chunks, safe = moderator.push(data); !safe {
+						moderated = true
+						writeData(moderator.blockedEvent())
+						continue
+					}
+				}
+
 				// 尝试写入数据，如果客户端断开也继续处理
-				select {
-				case <-c.Request.Context().Done():
-					// 客户端已断开，不执行任何操作，直接跳过
-				default:
-					// 客户端正常，发送数据
-					fmt.Fprint(c.Writer, data)
-					c.Writer.Flush()
+				for _, chunk := range chunks {
+					writeData(chunk)
 				}
 
 			case err := <-errChan:
+				if moderator != nil && !moderated {
+					chunks, safe := moderator.flush()
+					if !safe {
+						moderated = true
+						writeData(moderator.blockedEvent())
+					}
+					for _, chunk := range chunks {
+						writeData(chunk)
+					}
+				}
+
 				if !errors.Is(err, io.EOF) {
 					// 处理错误情况
-					select {
-					case <-c.Request.Context().Done():
-						// 客户端已断开，不执行任何操作，直接跳过
-					default:
-						// 客户端正常，发送错误信息
-						fmt.Fprint(c.Writer, err.Error())
-						c.Writer.Flush()
-					}
+					writeData(err.Error())
 
 					logger.LogError(c.Request.Context(), "Stream err:"+err.Error())
-				} else {
-					// 正常结束，处理endHandler
+				} else if !moderated {
+					// 正常结束，处理endHandler；命中审核时已以错误事件结束
 					if endHandler != nil {
 						streamData := endHandler()
 						if streamData != "" {
-							select {
-							case <-c.Request.Context().Done():
-								// 客户端已断开，只记录数据
-							default:
-								// 客户端正常，发送数据
-								fmt.Fprint(c.Writer, streamData)
-								c.Writer.Flush()
-							}
+							writeData(streamData)
 						}
 					}
 				}
@@ -562,7 +690,8 @@ func FilterOpenAIErr(c *gin.Context, err *types.OpenAIErrorWithStatusCode) (errW
 		newErr = *err
 	}
 
-	if newErr.StatusCode == http.StatusTooManyRequests {
+	// 本地的限流和预算错误保留原始信息，便于用户判断原因
+	if newErr.StatusCode == http.StatusTooManyRequests && !newErr.LocalError {
 		newErr.OpenAIError.Message = "当前分组上游负载已饱和，请稍后再试"
 	}
 
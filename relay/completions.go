@@ -78,6 +78,9 @@ func (r *relayCompletions) send() (err *types.OpenAIErrorWithStatusCode, done bo
 				done = true
 				return
 			}
+			if _, ok := r.request.Prompt.(string); ok && CheckResult.Redacted {
+				r.request.Prompt = CheckResult.Content
+			}
 		}
 	}
 
@@ -101,7 +104,7 @@ func (r *relayCompletions) send() (err *types.OpenAIErrorWithStatusCode, done bo
 		if err != nil {
 			return
 		}
-		err = responseJsonClient(r.c, response)
+		err = responseModeratedJsonClient(r.c, response, moderateCompletionResponse(r.c, response))
 	}
 
 	if err != nil {
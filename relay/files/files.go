@@ -0,0 +1,274 @@
+package files
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/storage"
+	"one-api/common/utils"
+	"one-api/model"
+	"one-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 单个文件最大 512MB，与 OpenAI Files API 限制一致
+const maxFileBytes = 512 << 20
+
+// 文件过期时间范围，与 OpenAI expires_after 限制一致
+const (
+	minExpiresSeconds = 3600
+	maxExpiresSeconds = 30 * 24 * 3600
+)
+
+var allowedPurposes = map[string]bool{
+	types.FilePurposeBatch:      true,
+	types.FilePurposeVision:     true,
+	types.FilePurposeUserData:   true,
+	types.FilePurposeAssistants: true,
+}
+
+// Upload 上传文件到网关存储
+func Upload(c *gin.Context) {
+	purpose := c.PostForm("purpose")
+	if !allowedPurposes[purpose] {
+		common.AbortWithMessage(c, http.StatusBadRequest, fmt.Sprintf("purpose %s is not supported by the gateway", purpose))
+		return
+	}
+
+	expiresAt, err := parseExpiresAfter(c)
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusBadRequest, "file is required")
+		return
+	}
+
+	if fileHeader.Size > maxFileBytes {
+		common.AbortWithMessage(c, http.StatusBadRequest, "file is too large")
+		return
+	}
+
+	userId := c.GetInt("id")
+	if err := checkUserQuota(userId, fileHeader.Size); err != nil {
+		common.AbortWithMessage(c, http.StatusForbidden, err.Error())
+		return
+	}
+
+	reader, err := fileHeader.Open()
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	file, err := SaveFile(userId, c.GetInt("token_id"), purpose, fileHeader.Filename, data, expiresAt)
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, file.ToOpenAI())
+}
+
+// parseExpiresAfter 解析 expires_after[anchor] 和 expires_after[seconds]，未设置时返回 0
+func parseExpiresAfter(c *gin.Context) (int64, error) {
+	anchor := c.PostForm("expires_after[anchor]")
+	seconds := c.PostForm("expires_after[seconds]")
+	if anchor == "" && seconds == "" {
+		return 0, nil
+	}
+
+	if anchor != "created_at" {
+		return 0, fmt.Errorf("invalid expires_after[anchor]: %s", anchor)
+	}
+
+	expiresSeconds := utils.String2Int(seconds)
+	if expiresSeconds < minExpiresSeconds || expiresSeconds > maxExpiresSeconds {
+		return 0, fmt.Errorf("expires_after[seconds] must be between %d and %d", minExpiresSeconds, maxExpiresSeconds)
+	}
+
+	return utils.GetTimestamp() + int64(expiresSeconds), nil
+}
+
+func checkUserQuota(userId int, size int64) error {
+	if config.FileUserStorageLimit <= 0 && config.FileUserCountLimit <= 0 {
+		return nil
+	}
+
+	count, bytes, err := model.GetUserFileUsage(userId)
+	if err != nil {
+		return err
+	}
+
+	if config.FileUserCountLimit > 0 && count >= int64(config.FileUserCountLimit) {
+		return fmt.Errorf("file count limit exceeded, at most %d files can be stored", config.FileUserCountLimit)
+	}
+
+	if config.FileUserStorageLimit > 0 && bytes+size > int64(config.FileUserStorageLimit)<<20 {
+		return fmt.Errorf("file storage limit exceeded, at most %dMB can be stored", config.FileUserStorageLimit)
+	}
+
+	return nil
+}
+
+// List 获取当前令牌的文件列表
+func List(c *gin.Context) {
+	limit := utils.String2Int(c.DefaultQuery("limit", "10000"))
+	if limit <= 0 || limit > 10000 {
+		limit = 10000
+	}
+
+	// 多取一条用于判断 has_more
+	files, err := model.GetTokenFiles(c.GetInt("id"), c.GetInt("token_id"), c.Query("purpose"), c.Query("after"), c.Query("order"), limit+1)
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	list := &types.OpenAIFileList{
+		Object: "list",
+		Data:   make([]*types.OpenAIFile, 0, len(files)),
+	}
+	if len(files) > limit {
+		list.HasMore = true
+		files = files[:limit]
+	}
+	for _, file := range files {
+		list.Data = append(list.Data, file.ToOpenAI())
+	}
+	if len(list.Data) > 0 {
+		list.FirstId = list.Data[0].Id
+		list.LastId = list.Data[len(list.Data)-1].Id
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// Retrieve 获取文件信息
+func Retrieve(c *gin.Context) {
+	file, err := model.GetTokenFileByFileId(c.GetInt("id"), c.GetInt("token_id"), c.Param("id"))
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, file.ToOpenAI())
+}
+
+// Content 下载文件内容
+func Content(c *gin.Context) {
+	file, err := model.GetTokenFileByFileId(c.GetInt("id"), c.GetInt("token_id"), c.Param("id"))
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	data, err := storage.ReadFile(file.Drive, file.StorageKey)
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Data(http.StatusOK, "application/octet-stream", data)
+}
+
+// Delete 删除文件
+func Delete(c *gin.Context) {
+	file, err := model.GetTokenFileByFileId(c.GetInt("id"), c.GetInt("token_id"), c.Param("id"))
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if err := removeFile(file); err != nil {
+		common.AbortWithMessage(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, types.OpenAIFileDeleted{
+		Id:      file.FileId,
+		Object:  "file",
+		Deleted: true,
+	})
+}
+
+// SaveFile 将内容写入存储驱动并创建文件记录，供上传接口和批处理输出使用
+func SaveFile(userId, tokenId int, purpose, filename string, data []byte, expiresAt int64) (*model.File, error) {
+	fileId := model.NewFileId()
+	storageKey := fmt.Sprintf("files/%d/%s", userId, fileId)
+
+	drive, err := storage.SaveFile(storageKey, data)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &model.File{
+		FileId:     fileId,
+		UserId:     userId,
+		TokenId:    tokenId,
+		Purpose:    purpose,
+		Filename:   filename,
+		Bytes:      int64(len(data)),
+		Drive:      drive,
+		StorageKey: storageKey,
+		Status:     model.FileStatusProcessed,
+		CreatedAt:  utils.GetTimestamp(),
+		ExpiresAt:  expiresAt,
+	}
+
+	if err := file.Insert(); err != nil {
+		storage.RemoveFile(drive, storageKey)
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// 先删除记录再删除存储内容，存储删除失败只记录日志
+func removeFile(file *model.File) error {
+	if err := file.Delete(); err != nil {
+		return err
+	}
+
+	if err := storage.RemoveFile(file.Drive, file.StorageKey); err != nil {
+		logger.SysError(fmt.Sprintf("remove file %s from storage error: %s", file.FileId, err.Error()))
+	}
+
+	return nil
+}
+
+// CleanExpiredFiles 删除已过期的文件
+func CleanExpiredFiles() {
+	for {
+		files, err := model.GetExpiredFiles(utils.GetTimestamp(), 100)
+		if err != nil {
+			logger.SysError("get expired files error: " + err.Error())
+			return
+		}
+
+		for _, file := range files {
+			if err := removeFile(file); err != nil {
+				logger.SysError(fmt.Sprintf("remove expired file %s error: %s", file.FileId, err.Error()))
+				return
+			}
+		}
+
+		if len(files) < 100 {
+			return
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package files
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common/logger"
+	"one-api/common/storage"
+	"one-api/common/storage/drives"
+	"one-api/model"
+	"one-api/types"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	logger.Logger = zap.NewNop()
+
+	dir, err := os.MkdirTemp("", "one-api-files")
+	if err != nil {
+		panic(err)
+	}
+	storage.AddFileDrive(drives.NewLocalDrive(dir))
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func setupFilesTest(t *testing.T) *gin.Engine {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "one-api.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.File{}))
+
+	savedDB := model.DB
+	model.DB = db
+	t.Cleanup(func() {
+		model.DB = savedDB
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("id", 1)
+		c.Set("token_id", 1)
+		if c.GetHeader("X-Token-Id") == "2" {
+			c.Set("token_id", 2)
+		}
+		c.Next()
+	})
+	router.POST("/v1/files", Upload)
+	router.GET("/v1/files", List)
+	router.GET("/v1/files/:id", Retrieve)
+	router.GET("/v1/files/:id/content", Content)
+	router.DELETE("/v1/files/:id", Delete)
+
+	return router
+}
+
+func uploadRequest(t *testing.T, purpose, filename string, content []byte) *http.Request {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	require.NoError(t, writer.WriteField("purpose", purpose))
+	part, err := writer.CreateFormFile("file", filename)
+	require.NoError(t, err)
+	_, err = part.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/files", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func serve(router *gin.Engine, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func upload(t *testing.T, router *gin.Engine, purpose, filename string, content []byte) *types.OpenAIFile {
+	t.Helper()
+
+	w := serve(router, uploadRequest(t, purpose, filename, content))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var file types.OpenAIFile
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &file))
+	return &file
+}
+
+func TestUpload(t *testing.T) {
+	router := setupFilesTest(t)
+
+	content := []byte(`{"custom_id":"1"}` + "\n")
+	file := upload(t, router, types.FilePurposeBatch, "input.jsonl", content)
+	assert.Equal(t, "file", file.Object)
+	assert.Equal(t, "input.jsonl", file.Filename)
+	assert.Equal(t, types.FilePurposeBatch, file.Purpose)
+	assert.Equal(t, int64(len(content)), file.Bytes)
+
+	w := serve(router, httptest.NewRequest(http.MethodGet, "/v1/files/"+file.Id+"/content", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, content, w.Body.Bytes())
+}
+
+func TestUploadRejectsUnsupportedPurpose(t *testing.T) {
+	router := setupFilesTest(t)
+
+	w := serve(router, uploadRequest(t, "fine-tune", "train.jsonl", []byte("{}")))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestList(t *testing.T) {
+	router := setupFilesTest(t)
+
+	first := upload(t, router, types.FilePurposeBatch, "a.jsonl", []byte("a"))
+	second := upload(t, router, types.FilePurposeUserData, "b.txt", []byte("b"))
+	third := upload(t, router, types.FilePurposeBatch, "c.jsonl", []byte("c"))
+
+	list := func(query string) *types.OpenAIFileList {
+		w := serve(router, httptest.NewRequest(http.MethodGet, "/v1/files"+query, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var list types.OpenAIFileList
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		return &list
+	}
+
+	ids := func(list *types.OpenAIFileList) []string {
+		result := make([]string, 0, len(list.Data))
+		for _, file := range list.Data {
+			result = append(result, file.Id)
+		}
+		return result
+	}
+
+	all := list("")
+	assert.Equal(t, []string{third.Id, second.Id, first.Id}, ids(all))
+	assert.False(t, all.HasMore)
+
+	assert.Equal(t, []string{first.Id, third.Id}, ids(list("?purpose=batch&order=asc")))
+
+	page := list("?limit=1")
+	assert.Equal(t, []string{third.Id}, ids(page))
+	assert.True(t, page.HasMore)
+	assert.Equal(t, []string{second.Id}, ids(list("?limit=1&after="+page.LastId)))
+}
+
+func TestListIsolatedByToken(t *testing.T) {
+	router := setupFilesTest(t)
+
+	file := upload(t, router, types.FilePurposeBatch, "a.jsonl", []byte("a"))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/files", nil)
+	req.Header.Set("X-Token-Id", "2")
+	w := serve(router, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list types.OpenAIFileList
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Empty(t, list.Data)
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/files/"+file.Id, nil)
+	req.Header.Set("X-Token-Id", "2")
+	assert.Equal(t, http.StatusNotFound, serve(router, req).Code)
+}
+
+func TestDelete(t *testing.T) {
+	router := setupFilesTest(t)
+
+	file := upload(t, router, types.FilePurposeBatch, "a.jsonl", []byte("a"))
+	stored, err := model.GetTokenFileByFileId(1, 1, file.Id)
+	require.NoError(t, err)
+
+	w := serve(router, httptest.NewRequest(http.MethodDelete, "/v1/files/"+file.Id, nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var deleted types.OpenAIFileDeleted
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &deleted))
+	assert.Equal(t, file.Id, deleted.Id)
+	assert.True(t, deleted.Deleted)
+
+	assert.Equal(t, http.StatusNotFound, serve(router, httptest.NewRequest(http.MethodGet, "/v1/files/"+file.Id, nil)).Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, httptest.NewRequest(http.MethodDelete, "/v1/files/"+file.Id, nil)).Code)
+
+	_, err = storage.ReadFile(stored.Drive, stored.StorageKey)
+	assert.Error(t, err)
+}
@@ -0,0 +1,125 @@
+package files
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"one-api/common/storage"
+	"one-api/model"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const fileIdPrefix = "file-"
+
+// ResolveFileReferences 将 multipart 请求中以 file- 开头的字段值替换为网关保存的文件内容，
+// 使图片编辑、语音转写等接口可以直接引用已上传的文件
+func ResolveFileReferences(c *gin.Context, fields ...string) error {
+	mediaType, params, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return nil
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	c.Request.Body.Close()
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	// 没有引用时保持原始请求体不变
+	if !bytes.Contains(body, []byte(fileIdPrefix)) {
+		return nil
+	}
+
+	referenceFields := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		referenceFields[field] = true
+	}
+
+	var buffer bytes.Buffer
+	writer := multipart.NewWriter(&buffer)
+	reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	resolved := false
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return err
+		}
+
+		fileId := strings.TrimSpace(string(data))
+		if referenceFields[part.FormName()] && part.FileName() == "" && strings.HasPrefix(fileId, fileIdPrefix) {
+			if err := writeReferencedFile(c, writer, part.FormName(), fileId); err != nil {
+				return err
+			}
+			resolved = true
+			continue
+		}
+
+		partWriter, err := writer.CreatePart(textproto.MIMEHeader(part.Header))
+		if err != nil {
+			return err
+		}
+		if _, err := partWriter.Write(data); err != nil {
+			return err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	if !resolved {
+		return nil
+	}
+
+	c.Request.Body = io.NopCloser(bytes.NewReader(buffer.Bytes()))
+	c.Request.ContentLength = int64(buffer.Len())
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+
+	return nil
+}
+
+func writeReferencedFile(c *gin.Context, writer *multipart.Writer, field, fileId string) error {
+	file, err := model.GetTokenFileByFileId(c.GetInt("id"), c.GetInt("token_id"), fileId)
+	if err != nil {
+		return err
+	}
+
+	data, err := storage.ReadFile(file.Drive, file.StorageKey)
+	if err != nil {
+		return fmt.Errorf("read file %s error: %w", fileId, err)
+	}
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(field), escapeQuotes(file.Filename)))
+	header.Set("Content-Type", http.DetectContentType(data))
+
+	partWriter, err := writer.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = partWriter.Write(data)
+	return err
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
@@ -7,6 +7,8 @@ import (
 	"one-api/common"
 	"one-api/common/config"
 	"one-api/common/requester"
+	"one-api/model"
+	providersBase "one-api/providers/base"
 	"one-api/providers/gemini"
 	"one-api/safty"
 	"one-api/types"
@@ -15,15 +17,12 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-var AllowGeminiChannelType = []int{config.ChannelTypeGemini, config.ChannelTypeVertexAI}
-
 type relayGeminiOnly struct {
 	relayBase
 	geminiRequest *gemini.GeminiChatRequest
 }
 
 func NewRelayGeminiOnly(c *gin.Context) *relayGeminiOnly {
-	c.Set("allow_channel_type", AllowGeminiChannelType)
 	relay := &relayGeminiOnly{
 		relayBase: relayBase{
 			allowHeartbeat: true,
@@ -77,26 +76,30 @@ func (r *relayGeminiOnly) getPromptTokens() (int, error) {
 
 func (r *relayGeminiOnly) send() (err *types.OpenAIErrorWithStatusCode, done bool) {
 	chatProvider, ok := r.provider.(gemini.GeminiChatInterface)
-	if !ok {
-		return nil, false
+	_, isOpenAIChat := r.provider.(providersBase.ChatInterface)
+	if !ok && !isOpenAIChat {
+		err = common.StringErrorWrapperLocal("channel not implemented", "channel_error", http.StatusServiceUnavailable)
+		done = true
+		return
 	}
 
 	// 内容审查
 	if config.EnableSafe {
-		for _, message := range r.geminiRequest.Contents {
-			if message.Parts != nil {
-				CheckResult, _ := safty.CheckContent(message.Parts)
-				if !CheckResult.IsSafe {
-					err = common.StringErrorWrapperLocal(CheckResult.Reason, CheckResult.Code, http.StatusBadRequest)
-					done = true
-					return
-				}
-			}
+		CheckResult, _ := safty.CheckFields(r.geminiRequest)
+		if !CheckResult.IsSafe {
+			err = common.StringErrorWrapperLocal(CheckResult.Reason, CheckResult.Code, http.StatusBadRequest)
+			done = true
+			return
 		}
 	}
 
 	r.geminiRequest.Model = r.modelName
 
+	// 渠道不支持 Gemini 格式时，转换为 OpenAI 格式请求
+	if !ok {
+		return r.sendByChatOpenai()
+	}
+
 	if r.geminiRequest.Stream {
 		var response requester.StreamReaderInterface[string]
 		response, err = chatProvider.CreateGeminiChatStream(r.geminiRequest)
@@ -111,7 +114,7 @@ func (r *relayGeminiOnly) send() (err *types.OpenAIErrorWithStatusCode, done boo
 		doneStr := func() string {
 			return ""
 		}
-		firstResponseTime := responseGeneralStreamClient(r.c, response, doneStr)
+		firstResponseTime := responseGeneralStreamClient(r.c, response, doneStr, outputFormatGemini)
 		r.SetFirstResponseTime(firstResponseTime)
 	} else {
 		var response *gemini.GeminiChatResponse
@@ -134,6 +137,56 @@ func (r *relayGeminiOnly) send() (err *types.OpenAIErrorWithStatusCode, done boo
 	return
 }
 
+// sendByChatOpenai 上游请求失败时不标记 done，以便重试或切换渠道；已向客户端写出内容后不再重试
+func (r *relayGeminiOnly) sendByChatOpenai() (err *types.OpenAIErrorWithStatusCode, done bool) {
+	chatProvider := r.provider.(providersBase.ChatInterface)
+
+	chatRequest, err := gemini.ConvertToChatOpenaiRequest(r.geminiRequest)
+	if err != nil {
+		done = true
+		return
+	}
+
+	if chatRequest.Stream {
+		var response requester.StreamReaderInterface[string]
+		response, err = chatProvider.CreateChatCompletionStream(chatRequest)
+		if err != nil {
+			done = err.LocalError
+			return
+		}
+
+		if r.heartbeat != nil {
+			r.heartbeat.Stop()
+		}
+
+		doneStr := func() string {
+			return ""
+		}
+		stream := gemini.NewChatOpenaiToGeminiStream(response, r.modelName, r.provider.GetUsage)
+		firstResponseTime := responseGeneralStreamClient(r.c, stream, doneStr, outputFormatGemini)
+		r.SetFirstResponseTime(firstResponseTime)
+
+		return
+	}
+
+	var response *types.ChatCompletionResponse
+	response, err = chatProvider.CreateChatCompletion(chatRequest)
+	if err != nil {
+		done = err.LocalError
+		return
+	}
+
+	if r.heartbeat != nil {
+		r.heartbeat.Stop()
+	}
+
+	err = responseJsonClient(r.c, gemini.ConvertChatOpenaiToGemini(response, r.modelName, r.provider.GetUsage()))
+	if err != nil {
+		done = true
+	}
+	return
+}
+
 func (r *relayGeminiOnly) GetError(err *types.OpenAIErrorWithStatusCode) (int, any) {
 	newErr := FilterOpenAIErr(r.c, err)
 
@@ -158,6 +211,65 @@ func (r *relayGeminiOnly) HandleStreamError(err *types.OpenAIErrorWithStatusCode
 	r.c.Writer.Flush()
 }
 
+// RelayGemini countTokens 不扣费，其他请求正常转发
+func RelayGemini(c *gin.Context) {
+	if strings.HasSuffix(c.Param("model"), ":countTokens") {
+		CountGeminiTokens(c)
+		return
+	}
+
+	Relay(c)
+}
+
+// CountGeminiTokens 原生 Gemini 渠道转发给上游计算，其他渠道使用本地分词器估算
+func CountGeminiTokens(c *gin.Context) {
+	modelName := strings.TrimSuffix(c.Param("model"), ":countTokens")
+
+	request := &gemini.GeminiCountTokensRequest{}
+	if err := common.UnmarshalBodyReusable(c, request); err != nil {
+		abortWithGeminiError(c, common.StringErrorWrapperLocal(err.Error(), "one_hub_error", http.StatusBadRequest))
+		return
+	}
+
+	provider, newModelName, err := GetProvider(c, modelName)
+	if err != nil {
+		abortWithGeminiError(c, providerErrorWrapper(err))
+		return
+	}
+	channel := provider.GetChannel()
+
+	countProvider, ok := provider.(gemini.GeminiCountTokensInterface)
+	if !ok {
+		// 没有发出请求，归还可能占用的半开探测名额
+		model.CircuitBreakers.Release(channel.Id, modelName)
+
+		geminiRequest := request.GenerateContentRequest
+		if geminiRequest == nil {
+			geminiRequest = &gemini.GeminiChatRequest{Contents: request.Contents}
+		}
+		geminiRequest.Model = newModelName
+
+		totalTokens, _ := CountGeminiTokenMessages(geminiRequest, config.PreCostDefault)
+		c.JSON(http.StatusOK, gin.H{"totalTokens": totalTokens})
+		return
+	}
+
+	request.Model = newModelName
+	response, apiErr := countProvider.CountGeminiTokens(request)
+	recordCircuitBreaker(channel.Id, modelName, apiErr)
+	if apiErr != nil {
+		abortWithGeminiError(c, apiErr)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func abortWithGeminiError(c *gin.Context, err *types.OpenAIErrorWithStatusCode) {
+	newErr := FilterOpenAIErr(c, err)
+	c.JSON(newErr.StatusCode, gemini.OpenaiErrToGeminiErr(&newErr).GeminiErrorResponse)
+}
+
 func CountGeminiTokenMessages(request *gemini.GeminiChatRequest, preCostType int) (int, error) {
 	if preCostType == config.PreContNotAll {
 		return 0, nil
@@ -0,0 +1,326 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/common/logger"
+	"one-api/common/requester"
+	"one-api/common/utils"
+	"one-api/metrics"
+	"one-api/model"
+	providersBase "one-api/providers/base"
+	"one-api/relay/relay_util"
+	"one-api/types"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamCreator 为渠道准备流式请求，返回的函数会在独立的 goroutine 中执行
+type streamCreator func(provider providersBase.ProviderInterface, modelName string) func() (requester.StreamReaderInterface[string], *types.OpenAIErrorWithStatusCode)
+
+// hedgeAttempt 对冲中的一次上游请求
+type hedgeAttempt struct {
+	provider             providersBase.ProviderInterface
+	modelName            string
+	billingOriginalModel bool
+	isHedge              bool
+	channelTPM           *relay_util.ChannelTPMReservation // 对冲渠道占用的 TPM 额度
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	stream   requester.StreamReaderInterface[string]
+	dataChan <-chan string
+	errChan  <-chan error
+	first    string
+	firstErr error // 收到首个数据块之前流就结束了
+	err      *types.OpenAIErrorWithStatusCode
+}
+
+// getFirstTokenTimeout 获取流式请求的首字超时，指定渠道时不进行对冲
+func getFirstTokenTimeout(c *gin.Context, modelName string) time.Duration {
+	if c.GetInt("specific_channel_id") > 0 {
+		return 0
+	}
+
+	timeout := model.GlobalUserGroupRatio.GetFirstTokenTimeout(c.GetString("token_group"), modelName)
+	if timeout <= 0 {
+		return 0
+	}
+
+	return time.Duration(timeout) * time.Millisecond
+}
+
+func newHedgeAttempt(provider providersBase.ProviderInterface, modelName string, billingOriginalModel, isHedge bool) *hedgeAttempt {
+	// 上游请求不跟随客户端断开而取消，与非对冲请求保持一致，只在对冲失败时主动取消
+	ctx, cancel := context.WithCancel(context.Background())
+	if httpRequester := provider.GetRequester(); httpRequester != nil {
+		httpRequester.Context = ctx
+	}
+
+	return &hedgeAttempt{
+		provider:             provider,
+		modelName:            modelName,
+		billingOriginalModel: billingOriginalModel,
+		isHedge:              isHedge,
+		ctx:                  ctx,
+		cancel:               cancel,
+	}
+}
+
+// run 发起请求并等待首个数据块，完成后将结果发送到 results
+func (a *hedgeAttempt) run(create func() (requester.StreamReaderInterface[string], *types.OpenAIErrorWithStatusCode), results chan<- *hedgeAttempt) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.SysError(fmt.Sprintf("hedged request panic: %v", r))
+			a.err = common.StringErrorWrapperLocal("hedged request panic", "system_error", http.StatusInternalServerError)
+		}
+		results <- a
+	}()
+
+	a.stream, a.err = create()
+	if a.err != nil {
+		return
+	}
+
+	a.dataChan, a.errChan = a.stream.Recv()
+	select {
+	case data, ok := <-a.dataChan:
+		if !ok {
+			a.firstErr = io.EOF
+			return
+		}
+		a.first = data
+	case err := <-a.errChan:
+		if errors.Is(err, io.EOF) {
+			a.firstErr = err
+			return
+		}
+		// 尚未向客户端输出任何内容，作为普通错误返回以便重试
+		a.stream.Close()
+		a.err = common.StringErrorWrapper(err.Error(), "stream_error", http.StatusInternalServerError)
+	case <-a.ctx.Done():
+		a.stream.Close()
+		a.err = common.StringErrorWrapperLocal("hedged request cancelled", "hedge_cancelled", http.StatusRequestTimeout)
+	}
+}
+
+// discard 取消落败的请求，已建立的流由 discard 负责关闭
+func (a *hedgeAttempt) discard() {
+	a.cancel()
+	if a.err == nil && a.stream != nil {
+		a.stream.Close()
+	}
+}
+
+// hedgeStream 首字超时后向另一个渠道发起对冲请求，使用最先返回数据的请求并取消其余请求。
+// 胜出的渠道会替换当前的 provider，RelayHandler 据此只对胜出渠道计费
+func (r *relayBase) hedgeStream(timeout time.Duration, create streamCreator) (requester.StreamReaderInterface[string], *types.OpenAIErrorWithStatusCode) {
+	results := make(chan *hedgeAttempt, 2)
+
+	primary := newHedgeAttempt(r.provider, r.modelName, r.c.GetBool("billing_original_model"), false)
+	go primary.run(create(primary.provider, primary.modelName), results)
+	attempts := []*hedgeAttempt{primary}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	pending := 1
+	hedged := false
+	var primaryErr *types.OpenAIErrorWithStatusCode
+	for {
+		select {
+		case <-timer.C:
+			hedge := r.selectHedgeAttempt(primary)
+			if hedge == nil {
+				continue
+			}
+			logger.LogWarn(r.c.Request.Context(), fmt.Sprintf("first token timeout after %s on channel #%d, hedging to channel #%d", timeout, primary.provider.GetChannel().Id, hedge.provider.GetChannel().Id))
+			hedged = true
+			pending++
+			attempts = append(attempts, hedge)
+			go hedge.run(create(hedge.provider, hedge.modelName), results)
+
+		case attempt := <-results:
+			pending--
+			if attempt.err == nil {
+				// RelayHandler 只记录胜出的渠道，主请求先失败时在这里记录
+				if attempt.isHedge && primaryErr != nil {
+					recordCircuitBreaker(primary.provider.GetChannel().Id, r.originalModel, primaryErr)
+				}
+				r.useHedgeAttempt(attempt)
+				discardHedgeAttempts(results, pending, attempts, attempt, r.originalModel)
+				if hedged {
+					winner := "primary"
+					if attempt.isHedge {
+						winner = "hedge"
+					}
+					metrics.RecordHedgedRequest(r.originalModel, winner)
+				}
+
+				return &hedgedStream{attempt: attempt}, nil
+			}
+
+			attempt.cancel()
+			if !attempt.isHedge {
+				primaryErr = attempt.err
+			} else {
+				// 主请求的失败由 RelayHandler 记录，这里只记录对冲渠道
+				recordCircuitBreaker(attempt.provider.GetChannel().Id, r.originalModel, attempt.err)
+				logger.LogError(r.c.Request.Context(), fmt.Sprintf("hedged request to channel #%d failed: %s", attempt.provider.GetChannel().Id, attempt.err.Message))
+			}
+
+			if pending == 0 {
+				if hedged {
+					metrics.RecordHedgedRequest(r.originalModel, "none")
+				}
+				return nil, primary.err
+			}
+		}
+	}
+}
+
+// selectHedgeAttempt 排除主请求的渠道后重新选择一个渠道，没有可用渠道时返回 nil
+func (r *relayBase) selectHedgeAttempt(primary *hedgeAttempt) *hedgeAttempt {
+	skipChannelIds, _ := utils.GetGinValue[[]int](r.c, "skip_channel_ids")
+	r.c.Set("skip_channel_ids", append(slices.Clone(skipChannelIds), primary.provider.GetChannel().Id))
+	// 排队等待渠道会阻塞主请求结果的处理，没有空闲渠道时直接放弃对冲
+	r.c.Set(channelWaitDisabledKey, true)
+	provider, modelName, err := GetProvider(r.c, r.originalModel)
+	billingOriginalModel := r.c.GetBool("billing_original_model")
+
+	// GetProvider 会改写上下文中的渠道信息，恢复为主请求，直到确定胜出的渠道
+	r.c.Set("skip_channel_ids", skipChannelIds)
+	r.c.Set(channelWaitDisabledKey, false)
+	r.setHedgeContext(primary)
+
+	if err != nil {
+		logger.LogWarn(r.c.Request.Context(), "no channel available for hedged request: "+err.Error())
+		return nil
+	}
+
+	// 对冲渠道与主请求一样受渠道并发和 TPM 上限约束
+	channel := provider.GetChannel()
+	promptTokens := r.provider.GetUsage().PromptTokens
+	slot, ok := model.ChannelCapacity.Acquire(channel)
+	if !ok {
+		model.CircuitBreakers.Release(channel.Id, r.originalModel)
+		logger.LogWarn(r.c.Request.Context(), fmt.Sprintf("channel #%d is saturated, skip hedged request", channel.Id))
+		return nil
+	}
+	channelTPM, tpmErr := r.tpmLimit.ReserveChannel(channel, promptTokens)
+	if tpmErr != nil {
+		model.CircuitBreakers.Release(channel.Id, r.originalModel)
+		slot.Release()
+		logger.LogWarn(r.c.Request.Context(), fmt.Sprintf("channel #%d tpm limit reached, skip hedged request", channel.Id))
+		return nil
+	}
+
+	provider.SetOtherArg(r.otherArg)
+	provider.SetUsage(&types.Usage{
+		PromptTokens: promptTokens,
+	})
+
+	attempt := newHedgeAttempt(provider, modelName, billingOriginalModel, true)
+	attempt.channelTPM = channelTPM
+
+	// 请求结束或落败时退回占用的名额，胜出时 TPM 占用已转交给 RelayHandler 结算
+	cancel := attempt.cancel
+	var once sync.Once
+	attempt.cancel = func() {
+		cancel()
+		once.Do(func() {
+			slot.Release()
+			attempt.channelTPM.Release()
+		})
+	}
+
+	return attempt
+}
+
+func (r *relayBase) useHedgeAttempt(attempt *hedgeAttempt) {
+	if attempt.isHedge {
+		r.tpmLimit.UseChannel(attempt.channelTPM)
+		attempt.channelTPM = nil
+	}
+
+	r.provider = attempt.provider
+	r.modelName = attempt.modelName
+	r.setHedgeContext(attempt)
+}
+
+func (r *relayBase) setHedgeContext(attempt *hedgeAttempt) {
+	channel := attempt.provider.GetChannel()
+	r.c.Set("channel_id", channel.Id)
+	r.c.Set("channel_type", channel.Type)
+	r.c.Set("new_model", attempt.modelName)
+	r.c.Set("billing_original_model", attempt.billingOriginalModel)
+}
+
+// discardHedgeAttempts 取消除胜出者以外的请求，仍在进行中的请求结束后关闭其数据流，
+// 落败的请求不计入熔断，归还占用的探测名额
+func discardHedgeAttempts(results <-chan *hedgeAttempt, pending int, attempts []*hedgeAttempt, winner *hedgeAttempt, modelName string) {
+	for _, attempt := range attempts {
+		if attempt != winner {
+			attempt.cancel()
+		}
+	}
+
+	if pending == 0 {
+		return
+	}
+
+	go func() {
+		for i := 0; i < pending; i++ {
+			attempt := <-results
+			attempt.discard()
+			model.CircuitBreakers.Release(attempt.provider.GetChannel().Id, modelName)
+		}
+	}()
+}
+
+// hedgedStream 先回放等待首字时读取的数据块，再转发剩余的数据
+type hedgedStream struct {
+	attempt *hedgeAttempt
+}
+
+func (s *hedgedStream) Recv() (<-chan string, <-chan error) {
+	dataChan := make(chan string)
+	errChan := make(chan error)
+
+	go func() {
+		if s.attempt.firstErr != nil {
+			errChan <- s.attempt.firstErr
+			return
+		}
+
+		dataChan <- s.attempt.first
+		for {
+			select {
+			case data, ok := <-s.attempt.dataChan:
+				if !ok {
+					close(dataChan)
+					return
+				}
+				dataChan <- data
+			case err := <-s.attempt.errChan:
+				errChan <- err
+				return
+			}
+		}
+	}()
+
+	return dataChan, errChan
+}
+
+func (s *hedgedStream) Close() {
+	s.attempt.stream.Close()
+	s.attempt.cancel()
+}
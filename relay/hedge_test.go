@@ -0,0 +1,262 @@
+package relay
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/requester"
+	"one-api/model"
+	"one-api/providers"
+	providersBase "one-api/providers/base"
+	"one-api/relay/relay_util"
+	"one-api/types"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// hedgeTestStream 由测试控制何时返回数据块
+type hedgeTestStream struct {
+	data      chan string
+	errs      chan error
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newHedgeTestStream() *hedgeTestStream {
+	return &hedgeTestStream{
+		data:   make(chan string, 2),
+		errs:   make(chan error, 1),
+		closed: make(chan struct{}),
+	}
+}
+
+func (s *hedgeTestStream) Recv() (<-chan string, <-chan error) {
+	return s.data, s.errs
+}
+
+func (s *hedgeTestStream) Close() {
+	s.closeOnce.Do(func() { close(s.closed) })
+}
+
+// hedgeTestUpstream 记录每个渠道的请求上下文，对冲请求发出时通知 hedged
+type hedgeTestUpstream struct {
+	mu      sync.Mutex
+	streams map[int]*hedgeTestStream
+	ctxs    map[int]context.Context
+	models  map[int]string
+	hedged  chan struct{}
+}
+
+func (u *hedgeTestUpstream) create(provider providersBase.ProviderInterface, modelName string) func() (requester.StreamReaderInterface[string], *types.OpenAIErrorWithStatusCode) {
+	channelId := provider.GetChannel().Id
+
+	u.mu.Lock()
+	u.ctxs[channelId] = provider.GetRequester().Context
+	u.models[channelId] = modelName
+	u.mu.Unlock()
+
+	if channelId != 1 {
+		close(u.hedged)
+	}
+
+	return func() (requester.StreamReaderInterface[string], *types.OpenAIErrorWithStatusCode) {
+		return u.streams[channelId], nil
+	}
+}
+
+func (u *hedgeTestUpstream) ctx(channelId int) context.Context {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.ctxs[channelId]
+}
+
+// setupHedgeTest 分组 default 下渠道 1 和 2 都提供 gpt-4o，渠道 2 将其映射为 gpt-4o-mini，主请求使用渠道 1
+func setupHedgeTest(t *testing.T) (*relayBase, *hedgeTestUpstream) {
+	t.Helper()
+	logger.Logger = zap.NewNop()
+	gin.SetMode(gin.TestMode)
+
+	baseURL, proxy := "https://api.example.com", ""
+	mapping := `{"gpt-4o": "gpt-4o-mini"}`
+	channels := map[int]*model.ChannelChoice{
+		1: {Channel: &model.Channel{Id: 1, Type: config.ChannelTypeOpenAI, Key: "sk-primary", BaseURL: &baseURL, Proxy: &proxy, Models: "gpt-4o", Group: "default"}},
+		2: {Channel: &model.Channel{Id: 2, Type: config.ChannelTypeOpenAI, Key: "sk-hedge", BaseURL: &baseURL, Proxy: &proxy, Models: "gpt-4o", Group: "default", ModelMapping: &mapping}},
+	}
+
+	savedChannels, savedRule := model.ChannelGroup.Channels, model.ChannelGroup.Rule
+	model.ChannelGroup.Lock()
+	model.ChannelGroup.Channels = channels
+	model.ChannelGroup.Rule = map[string]map[string][][]int{"default": {"gpt-4o": {{1, 2}}}}
+	model.ChannelGroup.Unlock()
+	t.Cleanup(func() {
+		model.ChannelGroup.Lock()
+		model.ChannelGroup.Channels, model.ChannelGroup.Rule = savedChannels, savedRule
+		model.ChannelGroup.Unlock()
+		// 失败的请求会计入熔断，避免影响其他测试
+		model.CircuitBreakers.Reset(1)
+		model.CircuitBreakers.Reset(2)
+	})
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	c.Set("token_group", "default")
+	c.Set("channel_id", 1)
+	c.Set("new_model", "gpt-4o")
+
+	provider := providers.GetProvider(channels[1].Channel, c)
+	provider.SetUsage(&types.Usage{PromptTokens: 10})
+
+	r := &relayBase{
+		c:             c,
+		provider:      provider,
+		originalModel: "gpt-4o",
+		modelName:     "gpt-4o",
+		tpmLimit:      relay_util.NewTPMLimit(c),
+	}
+
+	upstream := &hedgeTestUpstream{
+		streams: map[int]*hedgeTestStream{1: newHedgeTestStream(), 2: newHedgeTestStream()},
+		ctxs:    make(map[int]context.Context),
+		models:  make(map[int]string),
+		hedged:  make(chan struct{}),
+	}
+
+	return r, upstream
+}
+
+func readHedgedStream(t *testing.T, stream requester.StreamReaderInterface[string]) []string {
+	t.Helper()
+
+	var chunks []string
+	dataChan, errChan := stream.Recv()
+	for {
+		select {
+		case data, ok := <-dataChan:
+			if !ok {
+				return chunks
+			}
+			chunks = append(chunks, data)
+		case err := <-errChan:
+			t.Fatalf("unexpected stream error: %v", err)
+		case <-time.After(time.Second):
+			t.Fatal("timed out reading hedged stream")
+		}
+	}
+}
+
+func assertDone(t *testing.T, done <-chan struct{}, msg string) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal(msg)
+	}
+}
+
+func TestHedgeStreamPrimaryBeforeTimeout(t *testing.T) {
+	r, upstream := setupHedgeTest(t)
+	primary := upstream.streams[1]
+	primary.data <- "primary"
+	close(primary.data)
+
+	stream, err := r.hedgeStream(time.Minute, upstream.create)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"primary"}, readHedgedStream(t, stream))
+
+	// 首字未超时不发起对冲
+	assert.Nil(t, upstream.ctx(2))
+	assert.Equal(t, 1, r.provider.GetChannel().Id)
+	assert.Equal(t, 1, r.c.GetInt("channel_id"))
+
+	stream.Close()
+	assertDone(t, primary.closed, "winner stream should be closed")
+	assert.Error(t, upstream.ctx(1).Err())
+}
+
+func TestHedgeStreamHedgeWins(t *testing.T) {
+	r, upstream := setupHedgeTest(t)
+	primary, hedge := upstream.streams[1], upstream.streams[2]
+	hedge.data <- "hedge"
+	hedge.data <- "done"
+	close(hedge.data)
+
+	stream, err := r.hedgeStream(10*time.Millisecond, upstream.create)
+	require.Nil(t, err)
+
+	// 落败的主请求被取消，已建立的流被关闭
+	assertDone(t, upstream.ctx(1).Done(), "primary request should be cancelled")
+	assertDone(t, primary.closed, "primary stream should be closed")
+	assert.NoError(t, upstream.ctx(2).Err())
+
+	// 胜出的对冲渠道替换 provider，按其映射后的模型计费
+	assert.Equal(t, 2, r.provider.GetChannel().Id)
+	assert.Equal(t, "gpt-4o-mini", r.modelName)
+	assert.Equal(t, "gpt-4o-mini", upstream.models[2])
+	assert.Equal(t, 2, r.c.GetInt("channel_id"))
+	assert.Equal(t, "gpt-4o-mini", r.c.GetString("new_model"))
+	assert.Equal(t, 10, r.provider.GetUsage().PromptTokens)
+
+	// 对冲时临时加入的跳过渠道不影响后续重试
+	skipChannelIds, _ := r.c.Get("skip_channel_ids")
+	assert.Empty(t, skipChannelIds)
+
+	assert.Equal(t, []string{"hedge", "done"}, readHedgedStream(t, stream))
+	stream.Close()
+	assertDone(t, hedge.closed, "winner stream should be closed")
+	assert.Error(t, upstream.ctx(2).Err())
+}
+
+func TestHedgeStreamPrimaryWinsAfterHedging(t *testing.T) {
+	r, upstream := setupHedgeTest(t)
+	primary, hedge := upstream.streams[1], upstream.streams[2]
+	go func() {
+		<-upstream.hedged
+		primary.data <- "primary"
+		close(primary.data)
+	}()
+
+	stream, err := r.hedgeStream(10*time.Millisecond, upstream.create)
+	require.Nil(t, err)
+
+	// 落败的对冲请求被取消
+	assertDone(t, upstream.ctx(2).Done(), "hedged request should be cancelled")
+	assertDone(t, hedge.closed, "hedged stream should be closed")
+	assert.NoError(t, upstream.ctx(1).Err())
+
+	// 渠道信息恢复为主请求，仍按主渠道计费
+	assert.Equal(t, 1, r.provider.GetChannel().Id)
+	assert.Equal(t, "gpt-4o", r.modelName)
+	assert.Equal(t, 1, r.c.GetInt("channel_id"))
+	assert.Equal(t, "gpt-4o", r.c.GetString("new_model"))
+
+	assert.Equal(t, []string{"primary"}, readHedgedStream(t, stream))
+	stream.Close()
+}
+
+func TestHedgeStreamAllFailed(t *testing.T) {
+	r, upstream := setupHedgeTest(t)
+	primary, hedge := upstream.streams[1], upstream.streams[2]
+	go func() {
+		<-upstream.hedged
+		hedge.errs <- assert.AnError
+		primary.errs <- assert.AnError
+	}()
+
+	stream, err := r.hedgeStream(10*time.Millisecond, upstream.create)
+	require.NotNil(t, err)
+	assert.Nil(t, stream)
+	assert.Equal(t, "stream_error", err.Code)
+
+	// 全部失败时由主请求的渠道返回错误并重试
+	assert.Equal(t, 1, r.provider.GetChannel().Id)
+	assert.Equal(t, 1, r.c.GetInt("channel_id"))
+	assertDone(t, upstream.ctx(2).Done(), "failed hedged request should be cancelled")
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"one-api/common"
 	providersBase "one-api/providers/base"
+	"one-api/relay/files"
 	"one-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -22,6 +23,10 @@ func NewRelayImageEdits(c *gin.Context) *relayImageEdits {
 }
 
 func (r *relayImageEdits) setRequest() error {
+	if err := files.ResolveFileReferences(r.c, "image", "image[]", "mask"); err != nil {
+		return err
+	}
+
 	if err := common.UnmarshalBodyReusable(r.c, &r.request); err != nil {
 		return err
 	}
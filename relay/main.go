@@ -38,7 +38,7 @@ func Relay(c *gin.Context) {
 
 	c.Set("is_stream", relay.IsStream())
 	if err := relay.setProvider(relay.getOriginalModel()); err != nil {
-		openaiErr := common.StringErrorWrapperLocal(err.Error(), "one_hub_error", http.StatusServiceUnavailable)
+		openaiErr := providerErrorWrapper(err)
 		relay.HandleJsonError(openaiErr)
 		return
 	}
@@ -103,8 +103,15 @@ func Relay(c *gin.Context) {
 }
 
 func RelayHandler(relay RelayBaseInterface) (err *types.OpenAIErrorWithStatusCode, done bool) {
+	// 选择渠道时可能占用了半开状态的探测名额，没有发出请求就返回时需要归还
+	channel := relay.getProvider().GetChannel()
+	releaseProbe := func() {
+		model.CircuitBreakers.Release(channel.Id, relay.getOriginalModel())
+	}
+
 	promptTokens, tonkeErr := relay.getPromptTokens()
 	if tonkeErr != nil {
+		releaseProbe()
 		err = common.ErrorWrapperLocal(tonkeErr, "token_error", http.StatusBadRequest)
 		done = true
 		return
@@ -118,37 +125,100 @@ func RelayHandler(relay RelayBaseInterface) (err *types.OpenAIErrorWithStatusCod
 
 	quota := relay_util.NewQuota(relay.getContext(), relay.getModelName(), promptTokens)
 	if err = quota.PreQuotaConsumption(); err != nil {
+		releaseProbe()
 		done = true
 		return
 	}
 
+	// 命中缓存时不请求上游，不占用渠道的 TPM 和并发名额，也不计入熔断和延迟统计
+	if relay.sendCache() {
+		releaseProbe()
+		quota.SetFirstResponseTime(relay.GetFirstResponseTime())
+		quota.Consume(relay.getContext(), usage, relay.IsStream())
+		return
+	}
+
+	tpmLimit := relay_util.NewTPMLimit(relay.getContext())
+	if err = tpmLimit.Reserve(channel, promptTokens); err != nil {
+		releaseProbe()
+		quota.Undo(relay.getContext())
+		done = err.LocalError
+		return
+	}
+
+	relay.setTPMLimit(tpmLimit)
+
+	slot, ok := model.ChannelCapacity.Acquire(channel)
+	if !ok {
+		// 选择渠道后名额被其他请求占用，由重试切换到其他渠道
+		releaseProbe()
+		tpmLimit.Release()
+		quota.Undo(relay.getContext())
+		err = common.StringErrorWrapper(fmt.Sprintf("channel #%d is saturated", channel.Id), "channel_saturated", http.StatusTooManyRequests)
+		return
+	}
+
+	channelId := channel.Id
+	model.ChannelStats.IncInflight(channelId)
+	sendStartTime := time.Now()
 	err, done = relay.send()
+	model.ChannelStats.DecInflight(channelId)
+	slot.Release()
+	// 对冲请求由其他渠道胜出时，按胜出渠道记录结果和用量，并按胜出渠道映射后的模型计费
+	if provider := relay.getProvider(); provider.GetChannel().Id != channelId {
+		channelId = provider.GetChannel().Id
+		usage = provider.GetUsage()
+		quota.SwitchChannel(channelId, relay.getModelName())
+	}
+	recordCircuitBreaker(channelId, relay.getOriginalModel(), err)
 	// 最后处理流式中断时计算tokens
 	if usage.CompletionTokens == 0 && usage.TextBuilder.Len() > 0 {
 		usage.CompletionTokens = common.CountTokenText(usage.TextBuilder.String(), relay.getModelName())
 		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
 	}
 	if err != nil {
+		tpmLimit.Release()
 		quota.Undo(relay.getContext())
 		return
 	}
 
+	tpmLimit.Settle(usage.PromptTokens + usage.CompletionTokens)
 	quota.SetFirstResponseTime(relay.GetFirstResponseTime())
+	recordChannelLatency(relay, channelId, sendStartTime)
 
 	quota.Consume(relay.getContext(), usage, relay.IsStream())
 
 	return
 }
 
-func shouldCooldowns(c *gin.Context, channel *model.Channel, apiErr *types.OpenAIErrorWithStatusCode) {
-	modelName := c.GetString("new_model")
-	channelId := channel.Id
+// recordChannelLatency 流式请求记录首字延迟，非流式记录完整耗时，供延迟路由策略使用
+func recordChannelLatency(relay RelayBaseInterface, channelId int, startTime time.Time) {
+	latency := time.Since(startTime)
+	if firstResponseTime := relay.GetFirstResponseTime(); relay.IsStream() && !firstResponseTime.IsZero() {
+		latency = firstResponseTime.Sub(startTime)
+	}
+
+	model.ChannelStats.RecordLatency(channelId, relay.getOriginalModel(), latency)
+}
+
+// recordCircuitBreaker 记录上游请求结果，本地错误和请求参数错误不计入渠道失败
+func recordCircuitBreaker(channelId int, modelName string, apiErr *types.OpenAIErrorWithStatusCode) {
+	if apiErr == nil {
+		model.CircuitBreakers.RecordSuccess(channelId, modelName)
+		return
+	}
 
-	// 如果是频率限制，冻结通道
-	if apiErr.StatusCode == http.StatusTooManyRequests {
-		model.ChannelGroup.SetCooldowns(channelId, modelName)
+	if !relay_util.IsChannelFailure(apiErr.StatusCode, apiErr.LocalError) {
+		return
 	}
 
+	// 上游限流时直接熔断
+	model.CircuitBreakers.RecordFailure(channelId, modelName, apiErr.StatusCode == http.StatusTooManyRequests)
+}
+
+func shouldCooldowns(c *gin.Context, channel *model.Channel, apiErr *types.OpenAIErrorWithStatusCode) {
+	channelId := channel.Id
+
 	skipChannelIds, ok := utils.GetGinValue[[]int](c, "skip_channel_ids")
 	if !ok {
 		skipChannelIds = make([]int, 0)
@@ -190,6 +260,8 @@ func applyPreMappingBeforeRequest(c *gin.Context) {
 	if err != nil {
 		return
 	}
+	// 这里只读取渠道的自定义参数，不会发出请求
+	model.CircuitBreakers.Release(provider.GetChannel().Id, requestBody.Model)
 
 	customParams, err := provider.CustomParameterHandler()
 	if err != nil || customParams == nil {
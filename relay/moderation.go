@@ -0,0 +1,486 @@
+package relay
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/model"
+	"one-api/relay/relay_util"
+	"one-api/safty"
+	saftyTypes "one-api/safty/types"
+	"one-api/types"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 流式输出每累积 outputModerationStep 个字符检查一次，并带上之前 outputModerationWindow 个已检查的字符，
+// 避免敏感内容被拆分在两次检查之间
+const (
+	outputModerationStep   = 100
+	outputModerationWindow = 200
+)
+
+// 流式输出的格式，决定如何提取数据块中的文本，以及命中审核后如何结束输出
+const (
+	outputFormatOpenAI    = "openai"
+	outputFormatClaude    = "claude"
+	outputFormatGemini    = "gemini"
+	outputFormatResponses = "responses"
+)
+
+// outputModerator 审核模型输出的内容，流式输出时暂存数据块，通过检查后再发送给客户端
+type outputModerator struct {
+	c       *gin.Context
+	action  string
+	format  string
+	checked []rune
+	pending []rune
+	held    []string
+	result  *relay_util.OutputModerationResult
+}
+
+// newOutputModerator 未开启内容审核或分组未设置输出审核时返回 nil
+func newOutputModerator(c *gin.Context, format string) *outputModerator {
+	if !config.EnableSafe {
+		return nil
+	}
+
+	action := model.GlobalUserGroupRatio.GetOutputModeration(c.GetString("token_group"))
+	if action == "" {
+		return nil
+	}
+
+	return &outputModerator{c: c, action: action, format: format}
+}
+
+// check 检查内容，命中时记录结果。内容被脱敏时返回脱敏后的内容
+func (m *outputModerator) check(content string) (string, bool) {
+	result, _ := safty.CheckContent(content)
+	return m.handleResult(content, result)
+}
+
+// handleResult 处理检查结果，命中时记录结果
+func (m *outputModerator) handleResult(content string, result saftyTypes.CheckResult) (string, bool) {
+	if result.IsSafe {
+		if result.Redacted {
+			return result.Content, true
+		}
+		return content, true
+	}
+
+	code := result.Code
+	if code == "" || code == saftyTypes.SafeDefaultSuccessCode {
+		code = saftyTypes.SafeDefaultErrorCode
+	}
+	reason := result.Reason
+	if reason == "" {
+		reason = saftyTypes.SafeDefaultErrorMessage
+	}
+
+	m.result = &relay_util.OutputModerationResult{
+		Action: m.action,
+		Code:   code,
+		Reason: reason,
+	}
+	m.c.Set(relay_util.OutputModerationKey, m.result)
+	logger.LogWarn(m.c.Request.Context(), fmt.Sprintf("output moderation %s: %s", m.action, reason))
+
+	return content, false
+}
+
+// checkTexts 检查多段内容，空内容不检查。全部通过时写回脱敏后的内容，命中时返回 false
+// 多段内容先合并检查一次，只有需要脱敏时才逐段检查
+func (m *outputModerator) checkTexts(texts ...*string) bool {
+	fields := make([]*string, 0, len(texts))
+	for _, text := range texts {
+		if text != nil && *text != "" {
+			fields = append(fields, text)
+		}
+	}
+	if len(fields) == 0 {
+		return true
+	}
+
+	if len(fields) > 1 {
+		joined := make([]string, 0, len(fields))
+		for _, field := range fields {
+			joined = append(joined, *field)
+		}
+		content := strings.Join(joined, "\n")
+		result, _ := safty.CheckContent(content)
+		if !result.IsSafe {
+			_, safe := m.handleResult(content, result)
+			return safe
+		}
+		if !result.Redacted {
+			return true
+		}
+	}
+
+	redacted := make([]string, len(fields))
+	for i, field := range fields {
+		content, safe := m.check(*field)
+		if !safe {
+			return false
+		}
+		redacted[i] = content
+	}
+
+	for i, field := range fields {
+		*field = redacted[i]
+	}
+	return true
+}
+
+// push 暂存数据块，累积的内容达到检查长度且通过检查后，返回可以发送的数据块
+func (m *outputModerator) push(data string) ([]string, bool) {
+	text := m.chunkText(data)
+	if text == "" && len(m.held) == 0 {
+		return []string{data}, true
+	}
+
+	m.held = append(m.held, data)
+	m.pending = append(m.pending, []rune(text)...)
+	if len(m.pending) < outputModerationStep {
+		return nil, true
+	}
+
+	return m.flush()
+}
+
+// flush 检查尚未检查的内容，通过后返回全部暂存的数据块
+func (m *outputModerator) flush() ([]string, bool) {
+	if len(m.pending) > 0 {
+		// 流式输出的内容已经拆分到多个数据块中，无法脱敏，只拦截不安全的内容
+		if _, safe := m.check(string(m.checked) + string(m.pending)); !safe {
+			return nil, false
+		}
+
+		m.checked = append(m.checked, m.pending...)
+		if len(m.checked) > outputModerationWindow {
+			m.checked = append([]rune(nil), m.checked[len(m.checked)-outputModerationWindow:]...)
+		}
+		m.pending = m.pending[:0]
+	}
+
+	held := m.held
+	m.held = nil
+
+	return held, true
+}
+
+// blockedChunk 命中审核后发送给 chat 和 completions 客户端的数据，replace 为替换后的内容，block 为错误事件
+func (m *outputModerator) blockedChunk() string {
+	if m.action == model.OutputModerationReplace {
+		if chunk := m.replaceChunk(); chunk != "" {
+			return chunk
+		}
+	}
+
+	body, _ := json.Marshal(m.errorResponse())
+	return string(body)
+}
+
+// blockedEvent 命中审核后发送给 Claude、Gemini 和 Responses 客户端的错误事件，
+// 这些格式无法在中途替换内容，replace 也以错误事件结束输出。
+// 开头的空行用于结束已发送了一部分的事件，没有 data 的事件会被客户端忽略
+func (m *outputModerator) blockedEvent() string {
+	var event string
+	var body any
+	switch m.format {
+	case outputFormatClaude:
+		event = "error"
+		body = map[string]any{
+			"type": "error",
+			"error": map[string]any{
+				"type":    m.result.Code,
+				"message": m.result.Reason,
+			},
+		}
+	case outputFormatResponses:
+		event = "error"
+		body = map[string]any{
+			"type":    "error",
+			"code":    m.result.Code,
+			"message": m.result.Reason,
+		}
+	default:
+		body = map[string]any{
+			"error": map[string]any{
+				"code":    http.StatusBadRequest,
+				"message": m.result.Reason,
+				"status":  "INVALID_ARGUMENT",
+			},
+		}
+	}
+
+	data, _ := json.Marshal(body)
+	if event == "" {
+		return "\ndata: " + string(data) + "\n\n"
+	}
+	return fmt.Sprintf("\nevent: %s\ndata: %s\n\n", event, data)
+}
+
+func (m *outputModerator) errorResponse() *types.OpenAIErrorResponse {
+	return &types.OpenAIErrorResponse{
+		Error: types.OpenAIError{
+			Message: m.result.Reason,
+			Type:    "one_hub_error",
+			Code:    m.result.Code,
+		},
+	}
+}
+
+// replaceChunk 以最后一个暂存的数据块为模板，内容替换为审核提示并结束输出
+func (m *outputModerator) replaceChunk() string {
+	if len(m.held) == 0 {
+		return ""
+	}
+
+	chunk := make(map[string]any)
+	if err := json.Unmarshal([]byte(m.held[len(m.held)-1]), &chunk); err != nil {
+		return ""
+	}
+
+	choice := map[string]any{
+		"index":         0,
+		"finish_reason": types.FinishReasonContentFilter,
+	}
+	if chunk["object"] == "text_completion" {
+		choice["text"] = m.result.Reason
+	} else {
+		choice["delta"] = map[string]any{"content": m.result.Reason}
+	}
+	chunk["choices"] = []any{choice}
+	delete(chunk, "usage")
+
+	body, err := json.Marshal(chunk)
+	if err != nil {
+		return ""
+	}
+
+	return string(body)
+}
+
+// chunkText 按流的格式提取数据块中需要审核的文本
+func (m *outputModerator) chunkText(data string) string {
+	switch m.format {
+	case outputFormatClaude:
+		return sseChunkText(data, claudeChunkText)
+	case outputFormatGemini:
+		return sseChunkText(data, geminiChunkText)
+	case outputFormatResponses:
+		return sseChunkText(data, responsesChunkText)
+	default:
+		return streamChunkText(data)
+	}
+}
+
+// streamChunkText 提取 chat 和 completions 流式数据块中的文本，包括工具调用的参数
+func streamChunkText(data string) string {
+	var chunk struct {
+		Choices []struct {
+			Delta struct {
+				Content          any    `json:"content"`
+				ReasoningContent string `json:"reasoning_content"`
+				FunctionCall     *struct {
+					Arguments string `json:"arguments"`
+				} `json:"function_call"`
+				ToolCalls []struct {
+					Function *struct {
+						Arguments string `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"delta"`
+			Text string `json:"text"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return ""
+	}
+
+	text := ""
+	for _, choice := range chunk.Choices {
+		text += choice.Delta.ReasoningContent + choice.Text
+		if content, ok := choice.Delta.Content.(string); ok {
+			text += content
+		}
+		if choice.Delta.FunctionCall != nil {
+			text += choice.Delta.FunctionCall.Arguments
+		}
+		for _, toolCall := range choice.Delta.ToolCalls {
+			if toolCall.Function != nil {
+				text += toolCall.Function.Arguments
+			}
+		}
+	}
+
+	return text
+}
+
+// sseChunkText 提取 SSE 数据中每个 data 行的文本，数据块可能是完整的事件，也可能只是其中的一行
+func sseChunkText(data string, extract func(payload []byte) string) string {
+	text := ""
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		text += extract([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))))
+	}
+
+	return text
+}
+
+// claudeChunkText 提取 Claude 流式事件中的文本、思考内容和工具参数
+func claudeChunkText(payload []byte) string {
+	var event struct {
+		Delta struct {
+			Text        string `json:"text"`
+			Thinking    string `json:"thinking"`
+			PartialJson string `json:"partial_json"`
+		} `json:"delta"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return ""
+	}
+
+	return event.Delta.Thinking + event.Delta.Text + event.Delta.PartialJson
+}
+
+// geminiChunkText 提取 Gemini 流式响应中的文本和函数调用参数
+func geminiChunkText(payload []byte) string {
+	var response struct {
+		Candidates []struct {
+			Content struct {
+				Parts []struct {
+					Text         string `json:"text"`
+					FunctionCall *struct {
+						Args json.RawMessage `json:"args"`
+					} `json:"functionCall"`
+				} `json:"parts"`
+			} `json:"content"`
+		} `json:"candidates"`
+	}
+	if err := json.Unmarshal(payload, &response); err != nil {
+		return ""
+	}
+
+	text := ""
+	for _, candidate := range response.Candidates {
+		for _, part := range candidate.Content.Parts {
+			text += part.Text
+			if part.FunctionCall != nil {
+				text += string(part.FunctionCall.Args)
+			}
+		}
+	}
+
+	return text
+}
+
+// 需要审核的 Responses 增量事件，音频等二进制数据不检查
+var responsesTextDeltaEvents = map[string]bool{
+	"response.output_text.delta":             true,
+	"response.refusal.delta":                 true,
+	"response.reasoning_text.delta":          true,
+	"response.reasoning_summary_text.delta":  true,
+	"response.function_call_arguments.delta": true,
+	"response.audio_transcript.delta":        true,
+}
+
+// responsesChunkText 提取 Responses 流式事件中的文本增量
+func responsesChunkText(payload []byte) string {
+	var event struct {
+		Type  string `json:"type"`
+		Delta any    `json:"delta"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil || !responsesTextDeltaEvents[event.Type] {
+		return ""
+	}
+
+	delta, _ := event.Delta.(string)
+	return delta
+}
+
+// moderateChatResponse 审核非流式的 chat 响应，replace 时直接替换命中的内容，
+// block 时返回需要发送给客户端的错误
+func moderateChatResponse(c *gin.Context, response *types.ChatCompletionResponse) *types.OpenAIErrorResponse {
+	m := newOutputModerator(c, outputFormatOpenAI)
+	if m == nil || response == nil {
+		return nil
+	}
+
+	for i := range response.Choices {
+		message := &response.Choices[i].Message
+		texts := []*string{&message.ReasoningContent, &message.Reasoning}
+		content, isString := message.Content.(string)
+		if isString {
+			texts = append(texts, &content)
+		}
+		if message.FunctionCall != nil {
+			texts = append(texts, &message.FunctionCall.Arguments)
+		}
+		for _, toolCall := range message.ToolCalls {
+			if toolCall != nil && toolCall.Function != nil {
+				texts = append(texts, &toolCall.Function.Arguments)
+			}
+		}
+
+		if m.checkTexts(texts...) {
+			if isString {
+				message.Content = content
+			}
+			continue
+		}
+
+		if m.action == model.OutputModerationBlock {
+			return m.errorResponse()
+		}
+
+		message.Content = m.result.Reason
+		message.ReasoningContent = ""
+		message.Reasoning = ""
+		message.ToolCalls = nil
+		message.FunctionCall = nil
+		response.Choices[i].FinishReason = types.FinishReasonContentFilter
+	}
+
+	return nil
+}
+
+// moderateCompletionResponse 审核非流式的 completions 响应
+func moderateCompletionResponse(c *gin.Context, response *types.CompletionResponse) *types.OpenAIErrorResponse {
+	m := newOutputModerator(c, outputFormatOpenAI)
+	if m == nil || response == nil {
+		return nil
+	}
+
+	for i := range response.Choices {
+		if m.checkTexts(&response.Choices[i].Text) {
+			continue
+		}
+
+		if m.action == model.OutputModerationBlock {
+			return m.errorResponse()
+		}
+
+		response.Choices[i].Text = m.result.Reason
+		response.Choices[i].FinishReason = types.FinishReasonContentFilter
+	}
+
+	return nil
+}
+
+// responseModeratedJsonClient 发送审核后的响应。上游已经产生了消耗，命中 block 时仍按实际用量计费
+func responseModeratedJsonClient(c *gin.Context, data any, blocked *types.OpenAIErrorResponse) *types.OpenAIErrorWithStatusCode {
+	if blocked != nil {
+		c.JSON(http.StatusBadRequest, blocked)
+		return nil
+	}
+
+	return responseJsonClient(c, data)
+}
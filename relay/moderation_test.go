@@ -0,0 +1,326 @@
+package relay
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/model"
+	"one-api/safty"
+	saftyTypes "one-api/safty/types"
+	"one-api/types"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// moderationTestTool 拦截包含 forbidden 的内容，将 secret 脱敏为 ******
+type moderationTestTool struct {
+	checks []string
+}
+
+func (f *moderationTestTool) Name() string { return "ModerationTest" }
+
+func (f *moderationTestTool) Init() error { return nil }
+
+func (f *moderationTestTool) Check(data string) (saftyTypes.CheckResult, error) {
+	f.checks = append(f.checks, data)
+	if strings.Contains(data, "forbidden") {
+		return saftyTypes.CheckResult{IsSafe: false, RiskLevel: 1, Code: "blocked", Reason: "output blocked"}, nil
+	}
+	if strings.Contains(data, "secret") {
+		return saftyTypes.CheckResult{IsSafe: true, Redacted: true, Content: strings.ReplaceAll(data, "secret", "******")}, nil
+	}
+	return saftyTypes.CheckResult{IsSafe: true, Code: saftyTypes.SafeDefaultSuccessCode}, nil
+}
+
+func newTestModerator(t *testing.T, action, format string) (*outputModerator, *moderationTestTool) {
+	t.Helper()
+	logger.Logger = zap.NewNop()
+	gin.SetMode(gin.TestMode)
+
+	tool := &moderationTestTool{}
+	enableSafe, toolName, threshold := config.EnableSafe, config.SafeToolName, config.SafeRiskThreshold
+	safty.Tools["ModerationTest"] = tool
+	config.EnableSafe, config.SafeToolName, config.SafeRiskThreshold = true, "ModerationTest", 0
+	t.Cleanup(func() {
+		delete(safty.Tools, "ModerationTest")
+		config.EnableSafe, config.SafeToolName, config.SafeRiskThreshold = enableSafe, toolName, threshold
+	})
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+
+	return &outputModerator{c: c, action: action, format: format}, tool
+}
+
+func chatChunk(content string) string {
+	return fmt.Sprintf(`{"object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{"content":%q}}]}`, content)
+}
+
+func toolCallChunk(arguments string) string {
+	return fmt.Sprintf(`{"object":"chat.completion.chunk","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":%q}}]}}]}`, arguments)
+}
+
+func TestOutputModeratorPushFlush(t *testing.T) {
+	long := strings.Repeat("a", outputModerationStep)
+
+	tests := []struct {
+		name       string
+		format     string
+		chunks     []string
+		wantSafe   bool
+		wantPushed []int // 每次 push 后立即返回的数据块数量
+		wantFlush  int
+	}{
+		{
+			name:       "short content is held until flush",
+			chunks:     []string{chatChunk("hello"), chatChunk(" world")},
+			wantSafe:   true,
+			wantPushed: []int{0, 0},
+			wantFlush:  2,
+		},
+		{
+			name:       "chunks are released once the step is reached",
+			chunks:     []string{chatChunk("hello"), chatChunk(long), chatChunk("tail")},
+			wantSafe:   true,
+			wantPushed: []int{0, 2, 0},
+			wantFlush:  1,
+		},
+		{
+			name:       "chunks without text pass through when nothing is held",
+			chunks:     []string{`{"choices":[{"index":0,"delta":{"role":"assistant"}}]}`, chatChunk("hi")},
+			wantSafe:   true,
+			wantPushed: []int{1, 0},
+			wantFlush:  1,
+		},
+		{
+			name:     "sensitive content split across chunks is blocked at flush",
+			chunks:   []string{chatChunk("forb"), chatChunk("idden")},
+			wantSafe: false,
+		},
+		{
+			name:     "tool call arguments are checked",
+			chunks:   []string{toolCallChunk(`{"q":"forbidden`), toolCallChunk(`"}`)},
+			wantSafe: false,
+		},
+		{
+			name:   "claude text and tool input deltas are checked",
+			format: outputFormatClaude,
+			chunks: []string{
+				"event: content_block_delta\n",
+				`data: {"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"q\":\"forbidden\"}"}}` + "\n",
+				"\n",
+			},
+			wantSafe: false,
+		},
+		{
+			name:   "claude events are held in order",
+			format: outputFormatClaude,
+			chunks: []string{
+				"event: content_block_delta\n",
+				`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"hi"}}` + "\n",
+				"\n",
+			},
+			wantSafe:   true,
+			wantPushed: []int{1, 0, 0},
+			wantFlush:  2,
+		},
+		{
+			name:   "gemini text and function call args are checked",
+			format: outputFormatGemini,
+			chunks: []string{
+				`data: {"candidates":[{"content":{"role":"model","parts":[{"text":"ok"},{"functionCall":{"name":"f","args":{"q":"forbidden"}}}]}}]}` + "\n\n",
+			},
+			wantSafe: false,
+		},
+		{
+			name:   "responses output text deltas are checked",
+			format: outputFormatResponses,
+			chunks: []string{
+				"event: response.output_text.delta\ndata: {\"type\":\"response.output_text.delta\",\"delta\":\"forbidden\"}\n\n",
+			},
+			wantSafe: false,
+		},
+		{
+			name:   "responses non text events pass through",
+			format: outputFormatResponses,
+			chunks: []string{
+				"event: response.created\ndata: {\"type\":\"response.created\",\"response\":{\"instructions\":\"forbidden\"}}\n\n",
+			},
+			wantSafe:   true,
+			wantPushed: []int{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format := tt.format
+			if format == "" {
+				format = outputFormatOpenAI
+			}
+			m, _ := newTestModerator(t, model.OutputModerationBlock, format)
+
+			safe := true
+			for i, chunk := range tt.chunks {
+				var released []string
+				released, safe = m.push(chunk)
+				if !safe {
+					break
+				}
+				if tt.wantPushed != nil {
+					assert.Len(t, released, tt.wantPushed[i], "push %d", i)
+				}
+			}
+			if safe {
+				var released []string
+				released, safe = m.flush()
+				if tt.wantSafe {
+					assert.Len(t, released, tt.wantFlush)
+				}
+			}
+
+			assert.Equal(t, tt.wantSafe, safe)
+			if !tt.wantSafe {
+				require.NotNil(t, m.result)
+				assert.Equal(t, "blocked", m.result.Code)
+			}
+		})
+	}
+}
+
+func TestOutputModeratorWindow(t *testing.T) {
+	m, tool := newTestModerator(t, model.OutputModerationBlock, outputFormatOpenAI)
+
+	// 敏感词被拆分在两次检查之间，第二次检查带上之前已检查的内容
+	first := strings.Repeat("a", outputModerationStep-4) + "forb"
+	released, safe := m.push(chatChunk(first))
+	require.True(t, safe)
+	assert.Len(t, released, 1)
+
+	_, safe = m.push(chatChunk("idden"))
+	require.True(t, safe)
+	_, safe = m.flush()
+	assert.False(t, safe)
+	assert.Len(t, tool.checks, 2)
+	assert.True(t, strings.HasSuffix(tool.checks[1], "forbidden"))
+}
+
+func TestOutputModeratorBlockedOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		action string
+		format string
+		want   string
+	}{
+		{name: "openai block", action: model.OutputModerationBlock, format: outputFormatOpenAI, want: `"code":"blocked"`},
+		{name: "openai replace", action: model.OutputModerationReplace, format: outputFormatOpenAI, want: `"finish_reason":"content_filter"`},
+		{name: "claude", action: model.OutputModerationBlock, format: outputFormatClaude, want: "\nevent: error\ndata: {\"error\":{\"message\":\"output blocked\",\"type\":\"blocked\"},\"type\":\"error\"}\n\n"},
+		{name: "gemini", action: model.OutputModerationReplace, format: outputFormatGemini, want: "\ndata: {\"error\":{\"code\":400,\"message\":\"output blocked\",\"status\":\"INVALID_ARGUMENT\"}}\n\n"},
+		{name: "responses", action: model.OutputModerationBlock, format: outputFormatResponses, want: "\nevent: error\ndata: {\"code\":\"blocked\",\"message\":\"output blocked\",\"type\":\"error\"}\n\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := newTestModerator(t, tt.action, tt.format)
+			m.push(chatChunk("hi"))
+			_, safe := m.check("forbidden")
+			require.False(t, safe)
+
+			if tt.format == outputFormatOpenAI {
+				assert.Contains(t, m.blockedChunk(), tt.want)
+				return
+			}
+			assert.Equal(t, tt.want, m.blockedEvent())
+		})
+	}
+}
+
+func TestModerateChatResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		action      string
+		message     types.ChatCompletionMessage
+		wantBlocked bool
+		wantContent string
+		wantArgs    string
+		wantChecks  int
+	}{
+		{
+			name:        "empty fields are not checked",
+			action:      model.OutputModerationBlock,
+			message:     types.ChatCompletionMessage{Role: types.ChatMessageRoleAssistant, Content: "hello"},
+			wantContent: "hello",
+			wantChecks:  1,
+		},
+		{
+			name:   "fields are checked together once",
+			action: model.OutputModerationBlock,
+			message: types.ChatCompletionMessage{Role: types.ChatMessageRoleAssistant, Content: "hello", ReasoningContent: "think",
+				ToolCalls: []*types.ChatCompletionToolCalls{{Id: "call_1", Type: "function", Function: &types.ChatCompletionToolCallsFunction{Name: "f", Arguments: `{"q":1}`}}}},
+			wantContent: "hello",
+			wantArgs:    `{"q":1}`,
+			wantChecks:  1,
+		},
+		{
+			name:   "tool call arguments are blocked",
+			action: model.OutputModerationBlock,
+			message: types.ChatCompletionMessage{Role: types.ChatMessageRoleAssistant,
+				ToolCalls: []*types.ChatCompletionToolCalls{{Id: "call_1", Type: "function", Function: &types.ChatCompletionToolCallsFunction{Name: "f", Arguments: `{"q":"forbidden"}`}}}},
+			wantBlocked: true,
+			wantChecks:  1,
+		},
+		{
+			name:   "redaction is written back per field",
+			action: model.OutputModerationBlock,
+			message: types.ChatCompletionMessage{Role: types.ChatMessageRoleAssistant, Content: "the secret", ReasoningContent: "think",
+				ToolCalls: []*types.ChatCompletionToolCalls{{Id: "call_1", Type: "function", Function: &types.ChatCompletionToolCallsFunction{Name: "f", Arguments: `{"q":"secret"}`}}}},
+			wantContent: "the ******",
+			wantArgs:    `{"q":"******"}`,
+			wantChecks:  4,
+		},
+		{
+			name:        "replace drops the tool calls",
+			action:      model.OutputModerationReplace,
+			message:     types.ChatCompletionMessage{Role: types.ChatMessageRoleAssistant, Content: "forbidden"},
+			wantContent: "output blocked",
+			wantChecks:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, tool := newTestModerator(t, tt.action, outputFormatOpenAI)
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+			c.Set("token_group", "moderated")
+
+			groups := model.GlobalUserGroupRatio.UserGroup
+			model.GlobalUserGroupRatio.UserGroup = map[string]*model.UserGroup{"moderated": {Symbol: "moderated", OutputModeration: tt.action}}
+			t.Cleanup(func() { model.GlobalUserGroupRatio.UserGroup = groups })
+
+			response := &types.ChatCompletionResponse{Choices: []types.ChatCompletionChoice{{Message: tt.message, FinishReason: types.FinishReasonStop}}}
+			blocked := moderateChatResponse(c, response)
+
+			assert.Len(t, tool.checks, tt.wantChecks)
+			if tt.wantBlocked {
+				require.NotNil(t, blocked)
+				body, _ := json.Marshal(blocked)
+				assert.Contains(t, string(body), "blocked")
+				return
+			}
+
+			require.Nil(t, blocked)
+			message := response.Choices[0].Message
+			assert.Equal(t, tt.wantContent, message.Content)
+			if tt.wantArgs != "" {
+				assert.Equal(t, tt.wantArgs, message.ToolCalls[0].Function.Arguments)
+			}
+		})
+	}
+}
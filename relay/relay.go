@@ -83,6 +83,19 @@ func RelayOnly(c *gin.Context) {
 			requestTime = int(time.Since(requestStartTime).Milliseconds())
 		}
 	}
-	model.RecordConsumeLog(c.Request.Context(), c.GetInt("id"), c.GetInt("channel_id"), 0, 0, "", c.GetString("token_name"), 0, "中继:"+path, requestTime, false, nil, c.ClientIP())
+	model.RecordConsumeLog(c.Request.Context(), c.GetInt("id"), c.GetInt("organization_id"), c.GetInt("channel_id"), 0, 0, "", c.GetString("token_name"), 0, "中继:"+path, requestTime, false, nil, c.ClientIP())
 
 }
+
+// RelayOnlyOr 管理员指定渠道时透传到上游，否则由网关自身处理
+func RelayOnlyOr(handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetInt("specific_channel_id") > 0 {
+			c.Set("specific_channel_id_ignore", false)
+			RelayOnly(c)
+			return
+		}
+
+		handler(c)
+	}
+}
@@ -0,0 +1,429 @@
+package relay_util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"one-api/common"
+	"one-api/common/cache"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/requester"
+	"one-api/common/utils"
+	"one-api/model"
+	"one-api/types"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ChatCacheKey    = "chat_cache:%d:%s"
+	ChatCacheHitKey = "chat_cache_hit"
+)
+
+// ChatCacheItem 缓存的对话结果，统一以非流式结构保存，回放时再按需转换为 SSE
+type ChatCacheItem struct {
+	Model            string                       `json:"model"`
+	Choices          []types.ChatCompletionChoice `json:"choices"`
+	PromptTokens     int                          `json:"prompt_tokens"`
+	CompletionTokens int                          `json:"completion_tokens"`
+	CreatedAt        int64                        `json:"created_at"`
+}
+
+type ChatCache struct {
+	c       *gin.Context
+	key     string
+	ttl     time.Duration
+	enabled bool
+}
+
+// 参与缓存键计算的字段，包含所有会影响输出结果的参数，避免不同参数的请求命中同一缓存
+type chatCacheKeyBody struct {
+	Model               string                              `json:"model"`
+	Messages            []types.ChatCompletionMessage       `json:"messages"`
+	System              any                                 `json:"system,omitempty"`
+	Tools               []*types.ChatCompletionTool         `json:"tools,omitempty"`
+	ToolChoice          any                                 `json:"tool_choice,omitempty"`
+	Functions           []*types.ChatCompletionFunction     `json:"functions,omitempty"`
+	FunctionCall        any                                 `json:"function_call,omitempty"`
+	Temperature         *float64                            `json:"temperature,omitempty"`
+	TopP                *float64                            `json:"top_p,omitempty"`
+	TopK                *float64                            `json:"top_k,omitempty"`
+	N                   *int                                `json:"n,omitempty"`
+	Seed                *int                                `json:"seed,omitempty"`
+	Stop                any                                 `json:"stop,omitempty"`
+	MaxTokens           int                                 `json:"max_tokens,omitempty"`
+	MaxCompletionTokens int                                 `json:"max_completion_tokens,omitempty"`
+	ResponseFormat      *types.ChatCompletionResponseFormat `json:"response_format,omitempty"`
+	PresencePenalty     *float64                            `json:"presence_penalty,omitempty"`
+	FrequencyPenalty    *float64                            `json:"frequency_penalty,omitempty"`
+	LogitBias           any                                 `json:"logit_bias,omitempty"`
+	LogProbs            *bool                               `json:"logprobs,omitempty"`
+	TopLogProbs         int                                 `json:"top_logprobs,omitempty"`
+	ReasoningEffort     *string                             `json:"reasoning_effort,omitempty"`
+	Reasoning           *types.ChatReasoning                `json:"reasoning,omitempty"`
+	Verbosity           string                              `json:"verbosity,omitempty"`
+	EnableThinking      *bool                               `json:"enable_thinking,omitempty"`
+	ThinkingBudget      *int                                `json:"thinking_budget,omitempty"`
+	Thinking            *interface{}                        `json:"thinking,omitempty"`
+}
+
+func NewChatCache(c *gin.Context, request *types.ChatCompletionRequest) *ChatCache {
+	chatCache := &ChatCache{c: c}
+
+	if !config.ChatCacheEnabled || request == nil {
+		return chatCache
+	}
+
+	setting, ok := utils.GetGinValue[*model.TokenSetting](c, "token_setting")
+	if !ok || setting == nil || !setting.Cache.Enabled {
+		return chatCache
+	}
+
+	// n > 1 时每次结果本就不同；音频输出、预测输出和联网搜索的结果不适合复用，均不做缓存
+	if (request.N != nil && *request.N > 1) || request.Audio != nil || request.Prediction != nil || request.WebSearchOptions != nil {
+		return chatCache
+	}
+
+	hash, err := chatCacheHash(request)
+	if err != nil {
+		logger.LogError(c.Request.Context(), "chat cache hash error: "+err.Error())
+		return chatCache
+	}
+
+	chatCache.ttl = getChatCacheTTL(c, setting)
+	if chatCache.ttl <= 0 {
+		return chatCache
+	}
+
+	chatCache.key = fmt.Sprintf(ChatCacheKey, c.GetInt("id"), hash)
+	chatCache.enabled = true
+
+	return chatCache
+}
+
+// TTL 优先级：令牌 > 分组 > 系统默认
+func getChatCacheTTL(c *gin.Context, setting *model.TokenSetting) time.Duration {
+	if setting.Cache.TTL > 0 {
+		return time.Duration(setting.Cache.TTL) * time.Second
+	}
+
+	group := model.GlobalUserGroupRatio.GetBySymbol(c.GetString("token_group"))
+	if group != nil && group.CacheTTL > 0 {
+		return time.Duration(group.CacheTTL) * time.Second
+	}
+
+	return time.Duration(config.ChatCacheExpireSeconds) * time.Second
+}
+
+func chatCacheHash(request *types.ChatCompletionRequest) (string, error) {
+	body := chatCacheKeyBody{
+		Model:               request.Model,
+		Messages:            make([]types.ChatCompletionMessage, 0, len(request.Messages)),
+		System:              request.System,
+		Tools:               request.Tools,
+		ToolChoice:          request.ToolChoice,
+		Functions:           request.Functions,
+		FunctionCall:        request.FunctionCall,
+		Temperature:         request.Temperature,
+		TopP:                request.TopP,
+		TopK:                request.TopK,
+		N:                   request.N,
+		Seed:                request.Seed,
+		Stop:                request.Stop,
+		MaxTokens:           request.MaxTokens,
+		MaxCompletionTokens: request.MaxCompletionTokens,
+		ResponseFormat:      request.ResponseFormat,
+		PresencePenalty:     request.PresencePenalty,
+		FrequencyPenalty:    request.FrequencyPenalty,
+		LogitBias:           request.LogitBias,
+		LogProbs:            request.LogProbs,
+		TopLogProbs:         request.TopLogProbs,
+		ReasoningEffort:     request.ReasoningEffort,
+		Reasoning:           request.Reasoning,
+		Verbosity:           request.Verbosity,
+		EnableThinking:      request.EnableThinking,
+		ThinkingBudget:      request.ThinkingBudget,
+		Thinking:            request.Thinking,
+	}
+
+	for _, message := range request.Messages {
+		message.Role = strings.ToLower(strings.TrimSpace(message.Role))
+		if content, ok := message.Content.(string); ok {
+			message.Content = strings.TrimSpace(content)
+		}
+		body.Messages = append(body.Messages, message)
+	}
+
+	if len(body.Tools) > 1 {
+		tools := make([]*types.ChatCompletionTool, len(body.Tools))
+		copy(tools, body.Tools)
+		sort.SliceStable(tools, func(i, j int) bool {
+			return tools[i].Function.Name < tools[j].Function.Name
+		})
+		body.Tools = tools
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (cc *ChatCache) Enabled() bool {
+	return cc.enabled
+}
+
+func (cc *ChatCache) Get() *ChatCacheItem {
+	if !cc.enabled {
+		return nil
+	}
+
+	data, err := cache.GetCache[string](cc.key)
+	if err != nil || data == "" {
+		return nil
+	}
+
+	item, err := utils.UnmarshalString[ChatCacheItem](data)
+	if err != nil {
+		return nil
+	}
+
+	return &item
+}
+
+func (cc *ChatCache) Store(response *types.ChatCompletionResponse, usage *types.Usage) {
+	if !cc.enabled || response == nil || usage == nil || len(response.Choices) == 0 {
+		return
+	}
+
+	// 命中输出审核的结果不缓存
+	if _, ok := cc.c.Get(OutputModerationKey); ok {
+		return
+	}
+
+	item := ChatCacheItem{
+		Model:            response.Model,
+		Choices:          response.Choices,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		CreatedAt:        utils.GetTimestamp(),
+	}
+
+	if item.CompletionTokens == 0 {
+		item.CompletionTokens = common.CountTokenText(response.GetContent(), response.Model)
+	}
+
+	if err := cache.SetCache(cc.key, utils.Marshal(item), cc.ttl); err != nil {
+		logger.LogError(cc.c.Request.Context(), "chat cache set error: "+err.Error())
+	}
+}
+
+// StoreStream 流式请求正常结束后，将汇总的结果写入缓存
+func (cc *ChatCache) StoreStream(stream requester.StreamReaderInterface[string], usage *types.Usage) {
+	cacheStream, ok := stream.(*ChatCacheStream)
+	if !ok {
+		return
+	}
+
+	cc.Store(cacheStream.Response(), usage)
+}
+
+// Render 将缓存结果渲染为 JSON 或 SSE 文本，并标记本次请求命中缓存
+func (cc *ChatCache) Render(item *ChatCacheItem, isStream, includeUsage bool) string {
+	cc.c.Set(ChatCacheHitKey, true)
+
+	usage := &types.Usage{
+		PromptTokens:     item.PromptTokens,
+		CompletionTokens: item.CompletionTokens,
+		TotalTokens:      item.PromptTokens + item.CompletionTokens,
+	}
+	id := fmt.Sprintf("chatcmpl-%s", utils.GetUUID())
+	created := utils.GetTimestamp()
+
+	if !isStream {
+		return utils.Marshal(types.ChatCompletionResponse{
+			ID:      id,
+			Object:  "chat.completion",
+			Created: created,
+			Model:   item.Model,
+			Choices: item.Choices,
+			Usage:   usage,
+		})
+	}
+
+	var builder strings.Builder
+	writeChunk := func(choices []types.ChatCompletionStreamChoice, usage *types.Usage) {
+		builder.WriteString("data: " + utils.Marshal(types.ChatCompletionStreamResponse{
+			ID:      id,
+			Object:  "chat.completion.chunk",
+			Created: created,
+			Model:   item.Model,
+			Choices: choices,
+			Usage:   usage,
+		}) + "\n\n")
+	}
+
+	for _, choice := range item.Choices {
+		writeChunk([]types.ChatCompletionStreamChoice{{
+			Index: choice.Index,
+			Delta: types.ChatCompletionStreamChoiceDelta{
+				Role:             types.ChatMessageRoleAssistant,
+				Content:          choice.Message.StringContent(),
+				ReasoningContent: choice.Message.ReasoningContent,
+				FunctionCall:     choice.Message.FunctionCall,
+				ToolCalls:        choice.Message.ToolCalls,
+			},
+		}}, nil)
+		writeChunk([]types.ChatCompletionStreamChoice{{
+			Index:        choice.Index,
+			FinishReason: choice.FinishReason,
+		}}, nil)
+	}
+
+	if includeUsage {
+		writeChunk([]types.ChatCompletionStreamChoice{}, usage)
+	}
+	builder.WriteString("data: [DONE]\n\n")
+
+	return builder.String()
+}
+
+// ChatCacheStream 包装上游流，在转发的同时汇总内容，流正常结束后可写入缓存
+type ChatCacheStream struct {
+	stream    requester.StreamReaderInterface[string]
+	choices   map[int]*types.ChatCompletionChoice
+	model     string
+	completed bool
+}
+
+func (cc *ChatCache) WrapStream(stream requester.StreamReaderInterface[string]) requester.StreamReaderInterface[string] {
+	if !cc.enabled {
+		return stream
+	}
+
+	return &ChatCacheStream{
+		stream:  stream,
+		choices: make(map[int]*types.ChatCompletionChoice),
+	}
+}
+
+func (s *ChatCacheStream) Recv() (<-chan string, <-chan error) {
+	dataChan, errChan := s.stream.Recv()
+	outData := make(chan string)
+	outErr := make(chan error)
+
+	go func() {
+		for {
+			select {
+			case data, ok := <-dataChan:
+				if !ok {
+					return
+				}
+				s.collect(data)
+				outData <- data
+			case err := <-errChan:
+				s.completed = errors.Is(err, io.EOF)
+				outErr <- err
+				return
+			}
+		}
+	}()
+
+	return outData, outErr
+}
+
+func (s *ChatCacheStream) Close() {
+	s.stream.Close()
+}
+
+func (s *ChatCacheStream) collect(data string) {
+	var chunk types.ChatCompletionStreamResponse
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return
+	}
+
+	if chunk.Model != "" {
+		s.model = chunk.Model
+	}
+
+	for _, streamChoice := range chunk.Choices {
+		choice, ok := s.choices[streamChoice.Index]
+		if !ok {
+			choice = &types.ChatCompletionChoice{
+				Index: streamChoice.Index,
+				Message: types.ChatCompletionMessage{
+					Role: types.ChatMessageRoleAssistant,
+				},
+			}
+			s.choices[streamChoice.Index] = choice
+		}
+
+		content, _ := choice.Message.Content.(string)
+		choice.Message.Content = content + streamChoice.Delta.Content
+		choice.Message.ReasoningContent += streamChoice.Delta.ReasoningContent
+
+		if streamChoice.Delta.FunctionCall != nil {
+			if choice.Message.FunctionCall == nil {
+				choice.Message.FunctionCall = &types.ChatCompletionToolCallsFunction{}
+			}
+			choice.Message.FunctionCall.Name += streamChoice.Delta.FunctionCall.Name
+			choice.Message.FunctionCall.Arguments += streamChoice.Delta.FunctionCall.Arguments
+		}
+
+		for _, toolCall := range streamChoice.Delta.ToolCalls {
+			if toolCall == nil {
+				continue
+			}
+			for len(choice.Message.ToolCalls) <= toolCall.Index {
+				choice.Message.ToolCalls = append(choice.Message.ToolCalls, &types.ChatCompletionToolCalls{
+					Index:    len(choice.Message.ToolCalls),
+					Type:     "function",
+					Function: &types.ChatCompletionToolCallsFunction{},
+				})
+			}
+			target := choice.Message.ToolCalls[toolCall.Index]
+			if toolCall.Id != "" {
+				target.Id = toolCall.Id
+			}
+			if toolCall.Function != nil {
+				target.Function.Name += toolCall.Function.Name
+				target.Function.Arguments += toolCall.Function.Arguments
+			}
+		}
+
+		if reason, ok := streamChoice.FinishReason.(string); ok && reason != "" {
+			choice.FinishReason = reason
+		}
+	}
+}
+
+// Response 流正常结束时返回汇总后的结果
+func (s *ChatCacheStream) Response() *types.ChatCompletionResponse {
+	if !s.completed || len(s.choices) == 0 {
+		return nil
+	}
+
+	response := &types.ChatCompletionResponse{
+		Model:   s.model,
+		Choices: make([]types.ChatCompletionChoice, 0, len(s.choices)),
+	}
+	for i := 0; i < len(s.choices); i++ {
+		choice, ok := s.choices[i]
+		if !ok {
+			return nil
+		}
+		response.Choices = append(response.Choices, *choice)
+	}
+
+	return response
+}
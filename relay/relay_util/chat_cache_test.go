@@ -0,0 +1,177 @@
+package relay_util
+
+import (
+	"net/http/httptest"
+	"one-api/common/cache"
+	"one-api/common/config"
+	"one-api/common/utils"
+	"one-api/model"
+	"one-api/types"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newChatCacheTestContext(userId int) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	c.Set("id", userId)
+	c.Set("token_setting", &model.TokenSetting{Cache: model.ChatCacheSetting{Enabled: true, TTL: 60}})
+	return c
+}
+
+func newChatCacheTestRequest() *types.ChatCompletionRequest {
+	return &types.ChatCompletionRequest{
+		Model: "gpt-4o",
+		Messages: []types.ChatCompletionMessage{
+			{Role: types.ChatMessageRoleUser, Content: "hello"},
+		},
+	}
+}
+
+func TestChatCacheHitAndMiss(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	enabled := config.ChatCacheEnabled
+	config.ChatCacheEnabled = true
+	t.Cleanup(func() { config.ChatCacheEnabled = enabled })
+	cache.InitCacheManager()
+
+	response := &types.ChatCompletionResponse{
+		Model: "gpt-4o",
+		Choices: []types.ChatCompletionChoice{{
+			Message:      types.ChatCompletionMessage{Role: types.ChatMessageRoleAssistant, Content: "hi"},
+			FinishReason: types.FinishReasonStop,
+		}},
+	}
+	stored := NewChatCache(newChatCacheTestContext(1), newChatCacheTestRequest())
+	require.True(t, stored.Enabled())
+	stored.Store(response, &types.Usage{PromptTokens: 5, CompletionTokens: 1})
+
+	tests := []struct {
+		name    string
+		userId  int
+		modify  func(request *types.ChatCompletionRequest)
+		wantHit bool
+	}{
+		{
+			name:    "same request hits",
+			userId:  1,
+			wantHit: true,
+		},
+		{
+			name:   "whitespace and role case are normalized",
+			userId: 1,
+			modify: func(request *types.ChatCompletionRequest) {
+				request.Messages[0].Role = " USER "
+				request.Messages[0].Content = " hello\n"
+			},
+			wantHit: true,
+		},
+		{
+			name:   "other user misses",
+			userId: 2,
+		},
+		{
+			name:   "different message misses",
+			userId: 1,
+			modify: func(request *types.ChatCompletionRequest) {
+				request.Messages[0].Content = "hello again"
+			},
+		},
+		{
+			name:   "max_tokens is part of the key",
+			userId: 1,
+			modify: func(request *types.ChatCompletionRequest) { request.MaxTokens = 10 },
+		},
+		{
+			name:   "response_format is part of the key",
+			userId: 1,
+			modify: func(request *types.ChatCompletionRequest) {
+				request.ResponseFormat = &types.ChatCompletionResponseFormat{Type: "json_object"}
+			},
+		},
+		{
+			name:   "tool_choice is part of the key",
+			userId: 1,
+			modify: func(request *types.ChatCompletionRequest) { request.ToolChoice = "required" },
+		},
+		{
+			name:   "stop is part of the key",
+			userId: 1,
+			modify: func(request *types.ChatCompletionRequest) { request.Stop = []string{"\n"} },
+		},
+		{
+			name:   "top_p is part of the key",
+			userId: 1,
+			modify: func(request *types.ChatCompletionRequest) { request.TopP = utils.GetPointer(0.5) },
+		},
+		{
+			name:   "n is part of the key",
+			userId: 1,
+			modify: func(request *types.ChatCompletionRequest) { request.N = utils.GetPointer(1) },
+		},
+		{
+			name:   "seed is part of the key",
+			userId: 1,
+			modify: func(request *types.ChatCompletionRequest) { request.Seed = utils.GetPointer(42) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := newChatCacheTestRequest()
+			if tt.modify != nil {
+				tt.modify(request)
+			}
+
+			chatCache := NewChatCache(newChatCacheTestContext(tt.userId), request)
+			require.True(t, chatCache.Enabled())
+
+			item := chatCache.Get()
+			if !tt.wantHit {
+				assert.Nil(t, item)
+				return
+			}
+			require.NotNil(t, item)
+			assert.Equal(t, "hi", item.Choices[0].Message.StringContent())
+			assert.Equal(t, 5, item.PromptTokens)
+		})
+	}
+}
+
+func TestChatCacheSkipped(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	enabled := config.ChatCacheEnabled
+	config.ChatCacheEnabled = true
+	t.Cleanup(func() { config.ChatCacheEnabled = enabled })
+
+	tests := []struct {
+		name   string
+		modify func(request *types.ChatCompletionRequest)
+	}{
+		{
+			name:   "n greater than one",
+			modify: func(request *types.ChatCompletionRequest) { request.N = utils.GetPointer(2) },
+		},
+		{
+			name: "audio output",
+			modify: func(request *types.ChatCompletionRequest) {
+				request.Audio = &types.ChatAudio{Voice: "alloy", Format: "wav"}
+			},
+		},
+		{
+			name:   "web search",
+			modify: func(request *types.ChatCompletionRequest) { request.WebSearchOptions = &types.WebSearchOptions{} },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := newChatCacheTestRequest()
+			tt.modify(request)
+			assert.False(t, NewChatCache(newChatCacheTestContext(1), request).Enabled())
+		})
+	}
+}
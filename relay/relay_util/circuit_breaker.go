@@ -0,0 +1,17 @@
+package relay_util
+
+import "net/http"
+
+// IsChannelFailure 判断错误是否由渠道导致，本地错误和请求参数错误不计入熔断
+func IsChannelFailure(statusCode int, localError bool) bool {
+	if localError {
+		return false
+	}
+
+	switch statusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+
+	return statusCode == 0 || statusCode >= http.StatusInternalServerError
+}
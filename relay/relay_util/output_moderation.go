@@ -0,0 +1,10 @@
+package relay_util
+
+const OutputModerationKey = "output_moderation"
+
+// OutputModerationResult 模型输出命中内容审核的结果，记录到日志中
+type OutputModerationResult struct {
+	Action string `json:"action"`
+	Code   string `json:"code"`
+	Reason string `json:"reason,omitempty"`
+}
@@ -8,6 +8,7 @@ import (
 	"one-api/common"
 	"one-api/common/config"
 	"one-api/common/logger"
+	"one-api/common/utils"
 	"one-api/model"
 	"one-api/types"
 	"time"
@@ -15,6 +16,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// BatchIdKey 批处理执行单行请求时在上下文中设置的批处理 id
+const BatchIdKey = "batch_id"
+
 type Quota struct {
 	modelName        string
 	promptTokens     int
@@ -30,8 +34,16 @@ type Quota struct {
 	userId           int
 	channelId        int
 	tokenId          int
+	organizationId   int // 组织令牌从组织额度池扣费
 	unlimitedQuota   bool
 	HandelStatus     bool
+	cacheHit         bool   // 是否命中对话缓存
+	batchId          string // 所属批处理任务
+
+	outputModeration *OutputModerationResult // 输出内容审核结果
+
+	budgetRules    []*model.BudgetRule
+	budgetReserved int // 预算中预扣的额度
 
 	startTime         time.Time
 	firstResponseTime time.Time
@@ -47,9 +59,11 @@ func NewQuota(c *gin.Context, modelName string, promptTokens int) *Quota {
 		userId:         c.GetInt("id"),
 		channelId:      c.GetInt("channel_id"),
 		tokenId:        c.GetInt("token_id"),
+		organizationId: c.GetInt("organization_id"),
 		unlimitedQuota: c.GetBool("token_unlimited_quota"),
 		HandelStatus:   false,
 		isBackupGroup:  isBackupGroup, // 记录是否使用备用分组
+		batchId:        c.GetString(BatchIdKey),
 	}
 
 	quota.price = *model.PricingInstance.GetPrice(quota.modelName)
@@ -58,6 +72,7 @@ func NewQuota(c *gin.Context, modelName string, promptTokens int) *Quota {
 	quota.groupRatio = c.GetFloat64("group_ratio") // 这里的倍率已经在 common.go 中正确设置了
 	quota.inputRatio = quota.price.GetInput() * quota.groupRatio
 	quota.outputRatio = quota.price.GetOutput() * quota.groupRatio
+	quota.budgetRules = getBudgetRules(c)
 
 	return quota
 
@@ -70,21 +85,40 @@ func (q *Quota) PreQuotaConsumption() *types.OpenAIErrorWithStatusCode {
 		q.preConsumedQuota = int(float64(q.promptTokens)*q.inputRatio) + config.PreConsumedQuota
 	}
 
+	if err := q.reserveBudget(); err != nil {
+		return err
+	}
+
+	if q.organizationId > 0 {
+		return q.preOrganizationQuotaConsumption()
+	}
+
 	if q.preConsumedQuota == 0 {
 		return nil
 	}
 
 	userQuota, err := model.CacheGetUserQuota(q.userId)
 	if err != nil {
+		q.releaseBudget()
+		return common.ErrorWrapper(err, "get_user_quota_failed", http.StatusInternalServerError)
+	}
+
+	// 后付费用户可以透支到授信额度
+	creditLimit, err := model.CacheGetUserCreditLimit(q.userId)
+	if err != nil {
+		q.releaseBudget()
 		return common.ErrorWrapper(err, "get_user_quota_failed", http.StatusInternalServerError)
 	}
+	userQuota += creditLimit
 
 	if userQuota < q.preConsumedQuota {
+		q.releaseBudget()
 		return common.ErrorWrapper(errors.New("user quota is not enough"), "insufficient_user_quota", http.StatusPaymentRequired)
 	}
 
 	err = model.CacheDecreaseUserQuota(q.userId, q.preConsumedQuota)
 	if err != nil {
+		q.releaseBudget()
 		return common.ErrorWrapper(err, "decrease_user_quota_failed", http.StatusInternalServerError)
 	}
 
@@ -98,6 +132,7 @@ func (q *Quota) PreQuotaConsumption() *types.OpenAIErrorWithStatusCode {
 	if q.preConsumedQuota > 0 {
 		err := model.PreConsumeTokenQuota(q.tokenId, q.preConsumedQuota)
 		if err != nil {
+			q.releaseBudget()
 			return common.ErrorWrapper(err, "pre_consume_token_quota_failed", http.StatusForbidden)
 		}
 		q.HandelStatus = true
@@ -106,12 +141,112 @@ func (q *Quota) PreQuotaConsumption() *types.OpenAIErrorWithStatusCode {
 	return nil
 }
 
+// preOrganizationQuotaConsumption 组织令牌从组织额度池预扣，组织额度和成员上限由 PreConsumeOrganizationQuota 原子检查
+func (q *Quota) preOrganizationQuotaConsumption() *types.OpenAIErrorWithStatusCode {
+	if q.preConsumedQuota > 0 && !q.unlimitedQuota {
+		token, err := model.GetTokenById(q.tokenId)
+		if err != nil {
+			q.releaseBudget()
+			return common.ErrorWrapper(err, "pre_consume_token_quota_failed", http.StatusForbidden)
+		}
+		if token.RemainQuota < q.preConsumedQuota {
+			q.releaseBudget()
+			return common.ErrorWrapper(errors.New("令牌额度不足"), "pre_consume_token_quota_failed", http.StatusForbidden)
+		}
+		if err = model.DecreaseTokenQuota(q.tokenId, q.preConsumedQuota); err != nil {
+			q.releaseBudget()
+			return common.ErrorWrapper(err, "pre_consume_token_quota_failed", http.StatusForbidden)
+		}
+	}
+
+	if err := model.PreConsumeOrganizationQuota(q.organizationId, q.userId, q.preConsumedQuota); err != nil {
+		q.releaseBudget()
+		if q.preConsumedQuota > 0 && !q.unlimitedQuota {
+			model.IncreaseTokenQuota(q.tokenId, q.preConsumedQuota)
+		}
+		switch err {
+		case model.ErrOrganizationMemberLimit:
+			limitErr := common.ErrorWrapperLocal(err, "organization_member_limit_exceeded", http.StatusTooManyRequests)
+			limitErr.Type = "insufficient_quota"
+			return limitErr
+		case model.ErrOrganizationQuotaNotEnough:
+			return common.ErrorWrapper(err, "insufficient_organization_quota", http.StatusPaymentRequired)
+		case model.ErrOrganizationDisabled, model.ErrOrganizationNotMember, model.ErrOrganizationPermission, model.ErrOrganizationNotFound:
+			return common.ErrorWrapperLocal(err, "organization_unavailable", http.StatusForbidden)
+		default:
+			return common.ErrorWrapper(err, "decrease_organization_quota_failed", http.StatusInternalServerError)
+		}
+	}
+	q.HandelStatus = q.preConsumedQuota > 0
+
+	return nil
+}
+
+// postOrganizationQuotaConsumption 结算组织令牌的额度，quotaDelta 为实际消耗与预扣的差额
+func (q *Quota) postOrganizationQuotaConsumption(quotaDelta, quota int) error {
+	if !q.unlimitedQuota && quotaDelta != 0 {
+		var err error
+		if quotaDelta > 0 {
+			err = model.DecreaseTokenQuota(q.tokenId, quotaDelta)
+		} else {
+			err = model.IncreaseTokenQuota(q.tokenId, -quotaDelta)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return model.PostConsumeOrganizationQuota(q.organizationId, q.userId, quotaDelta, quota)
+}
+
+// getBudgetRules 获取用户和令牌在当前请求模型上的预算规则
+func getBudgetRules(c *gin.Context) []*model.BudgetRule {
+	userId := c.GetInt("id")
+	userBudget, err := model.CacheGetUserBudget(userId)
+	if err != nil {
+		logger.LogError(c.Request.Context(), "get user budget error: "+err.Error())
+	}
+
+	var tokenBudget *model.BudgetSetting
+	if setting, ok := utils.GetGinValue[*model.TokenSetting](c, "token_setting"); ok && setting != nil {
+		tokenBudget = &setting.Limits.BudgetSetting
+	}
+
+	return model.GetBudgetRules(userId, userBudget, c.GetInt("token_id"), tokenBudget, c.GetString("original_model"))
+}
+
+// reserveBudget 按预扣额度占用预算，预算用尽时返回 429
+func (q *Quota) reserveBudget() *types.OpenAIErrorWithStatusCode {
+	if len(q.budgetRules) == 0 {
+		return nil
+	}
+
+	rule, used, err := model.Budgets.Reserve(q.budgetRules, q.preConsumedQuota)
+	if err != nil {
+		return common.ErrorWrapper(err, "reserve_budget_failed", http.StatusInternalServerError)
+	}
+
+	if rule != nil {
+		budgetErr := common.ErrorWrapperLocal(rule.Error(used), "budget_exceeded", http.StatusTooManyRequests)
+		budgetErr.Type = "insufficient_quota"
+		return budgetErr
+	}
+
+	q.budgetReserved = q.preConsumedQuota
+	return nil
+}
+
+func (q *Quota) releaseBudget() {
+	model.Budgets.Adjust(q.budgetRules, -q.budgetReserved)
+	q.budgetReserved = 0
+}
+
 // 更新用户实时配额
 func (q *Quota) UpdateUserRealtimeQuota(usage *types.UsageEvent, nowUsage *types.UsageEvent) error {
 	usage.Merge(nowUsage)
 
-	// 不开启Redis，则不更新实时配额
-	if !config.RedisEnabled {
+	// 不开启Redis，则不更新实时配额，组织令牌不使用用户额度
+	if !config.RedisEnabled || q.organizationId > 0 {
 		return nil
 	}
 
@@ -129,7 +264,12 @@ func (q *Quota) UpdateUserRealtimeQuota(usage *types.UsageEvent, nowUsage *types
 		return errors.New("error get user quota cache: " + err.Error())
 	}
 
-	if cacheQuota >= int64(userQuota) {
+	creditLimit, err := model.CacheGetUserCreditLimit(q.userId)
+	if err != nil {
+		return errors.New("error get user credit limit cache: " + err.Error())
+	}
+
+	if cacheQuota >= int64(userQuota+creditLimit) {
 		return errors.New("user quota is not enough")
 	}
 
@@ -144,8 +284,24 @@ func (q *Quota) completedQuotaConsumption(usage *types.Usage, tokenName string,
 	}()
 
 	quota := q.GetTotalQuotaByUsage(usage)
+	model.Budgets.Settle(q.budgetRules, q.budgetReserved, quota)
 
-	if quota > 0 {
+	// 命中缓存时不计入渠道消耗
+	if q.cacheHit {
+		q.channelId = 0
+	}
+
+	if q.organizationId > 0 {
+		if quota > 0 || q.preConsumedQuota > 0 {
+			err := q.postOrganizationQuotaConsumption(quota-q.preConsumedQuota, quota)
+			if err != nil {
+				return errors.New("error consuming organization quota: " + err.Error())
+			}
+		}
+		if quota > 0 && q.channelId > 0 {
+			model.UpdateChannelUsedQuota(q.channelId, quota)
+		}
+	} else if quota > 0 {
 		quotaDelta := quota - q.preConsumedQuota
 		err := model.PostConsumeTokenQuotaWithInfo(q.tokenId, q.userId, q.unlimitedQuota, quotaDelta)
 		if err != nil {
@@ -155,12 +311,15 @@ func (q *Quota) completedQuotaConsumption(usage *types.Usage, tokenName string,
 		if err != nil {
 			return errors.New("error consuming token remain quota: " + err.Error())
 		}
-		model.UpdateChannelUsedQuota(q.channelId, quota)
+		if q.channelId > 0 {
+			model.UpdateChannelUsedQuota(q.channelId, quota)
+		}
 	}
 
 	model.RecordConsumeLog(
 		ctx,
 		q.userId,
+		q.organizationId,
 		q.channelId,
 		usage.PromptTokens,
 		usage.CompletionTokens,
@@ -179,10 +338,16 @@ func (q *Quota) completedQuotaConsumption(usage *types.Usage, tokenName string,
 }
 
 func (q *Quota) Undo(c *gin.Context) {
+	q.releaseBudget()
 	if q.HandelStatus {
 		go func(ctx context.Context) {
 			// return pre-consumed quota
-			err := model.PostConsumeTokenQuotaWithInfo(q.tokenId, q.userId, q.unlimitedQuota, -q.preConsumedQuota)
+			var err error
+			if q.organizationId > 0 {
+				err = q.postOrganizationQuotaConsumption(-q.preConsumedQuota, 0)
+			} else {
+				err = model.PostConsumeTokenQuotaWithInfo(q.tokenId, q.userId, q.unlimitedQuota, -q.preConsumedQuota)
+			}
 			if err != nil {
 				logger.LogError(ctx, "error return pre-consumed quota: "+err.Error())
 			}
@@ -193,6 +358,10 @@ func (q *Quota) Undo(c *gin.Context) {
 func (q *Quota) Consume(c *gin.Context, usage *types.Usage, isStream bool) {
 	tokenName := c.GetString("token_name")
 	q.startTime = c.GetTime("requestStartTime")
+	q.cacheHit = c.GetBool(ChatCacheHitKey)
+	if result, ok := c.Get(OutputModerationKey); ok {
+		q.outputModeration, _ = result.(*OutputModerationResult)
+	}
 	// 如果没有报错，则消费配额
 	go func(ctx context.Context) {
 		err := q.completedQuotaConsumption(usage, tokenName, isStream, c.ClientIP(), ctx)
@@ -236,6 +405,20 @@ func (q *Quota) GetLogMeta(usage *types.Usage) map[string]any {
 		meta["extra_billing"] = q.extraBillingData
 	}
 
+	if q.batchId != "" {
+		meta["batch_id"] = q.batchId
+		meta["batch_discount_ratio"] = config.BatchDiscountRatio
+	}
+
+	if q.cacheHit {
+		meta["cache_hit"] = true
+		meta["cache_hit_ratio"] = config.ChatCacheHitRatio
+	}
+
+	if q.outputModeration != nil {
+		meta["output_moderation"] = q.outputModeration
+	}
+
 	return meta
 }
 
@@ -270,6 +453,15 @@ func (q *Quota) GetTotalQuota(promptTokens, completionTokens int, extraBilling m
 	if q.inputRatio != 0 && quota <= 0 {
 		quota = 1
 	}
+
+	if q.cacheHit {
+		quota = int(math.Ceil(float64(quota) * config.ChatCacheHitRatio))
+	}
+
+	if q.batchId != "" {
+		quota = int(math.Ceil(float64(quota) * config.BatchDiscountRatio))
+	}
+
 	totalTokens := promptTokens + completionTokens
 	if totalTokens == 0 {
 		// in this case, must be some error happened
@@ -335,6 +527,19 @@ func (q *Quota) SetFirstResponseTime(firstResponseTime time.Time) {
 	q.firstResponseTime = firstResponseTime
 }
 
+// SwitchChannel 对冲请求由其他渠道胜出时，按实际返回结果的渠道记账，并按该渠道映射后的模型重新取价
+func (q *Quota) SwitchChannel(channelId int, modelName string) {
+	q.channelId = channelId
+	if modelName == q.modelName {
+		return
+	}
+
+	q.modelName = modelName
+	q.price = *model.PricingInstance.GetPrice(modelName)
+	q.inputRatio = q.price.GetInput() * q.groupRatio
+	q.outputRatio = q.price.GetOutput() * q.groupRatio
+}
+
 type ExtraBillingData struct {
 	Type      string  `json:"type"`
 	CallCount int     `json:"call_count"`
@@ -51,6 +51,7 @@ func NewOpenAIResponsesStreamConverter(c *gin.Context, request *types.OpenAIResp
 
 func (converter *OpenAIResponsesStreamConverter) initializeResponse(request *types.OpenAIResponsesRequest) {
 	converter.responses = &types.OpenAIResponsesResponses{
+		ID:     "resp_" + utils.GetRandomString(48),
 		Object: "response",
 		Text: types.TextResponses{
 			Format: struct {
@@ -59,15 +60,16 @@ func (converter *OpenAIResponsesStreamConverter) initializeResponse(request *typ
 				Type: "text",
 			},
 		},
-		MaxOutputTokens:   request.MaxOutputTokens,
-		ParallelToolCalls: request.ParallelToolCalls,
-		Temperature:       request.Temperature,
-		ToolChoice:        request.ToolChoice,
-		TopP:              request.TopP,
-		Truncation:        request.Truncation,
-		Tools:             request.Tools,
-		Output:            make([]types.ResponsesOutput, 0),
-		Status:            "in_progress",
+		MaxOutputTokens:    request.MaxOutputTokens,
+		ParallelToolCalls:  request.ParallelToolCalls,
+		Temperature:        request.Temperature,
+		ToolChoice:         request.ToolChoice,
+		TopP:               request.TopP,
+		Truncation:         request.Truncation,
+		Tools:              request.Tools,
+		PreviousResponseID: request.PreviousResponseID,
+		Output:             make([]types.ResponsesOutput, 0),
+		Status:             "in_progress",
 	}
 }
 
@@ -85,7 +87,6 @@ func (converter *OpenAIResponsesStreamConverter) ProcessStreamData(jsonStr strin
 
 	// 第一次响应创建response.created
 	if converter.isFirstResponse {
-		converter.responses.ID = response.ID
 		converter.responses.CreatedAt = response.Created
 		converter.responses.Model = response.Model
 		converter.sendStreamResponse("response.created", converter.populateResponseData)
@@ -97,6 +98,11 @@ func (converter *OpenAIResponsesStreamConverter) ProcessStreamData(jsonStr strin
 
 }
 
+// Response 获取流结束后完整的响应对象
+func (converter *OpenAIResponsesStreamConverter) Response() *types.OpenAIResponsesResponses {
+	return converter.responses
+}
+
 func (converter *OpenAIResponsesStreamConverter) ProcessError(jsonStr string) {
 	converter.sendError(jsonStr)
 }
@@ -0,0 +1,212 @@
+package relay_util
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/common/limit"
+	"one-api/common/logger"
+	"one-api/common/utils"
+	"one-api/model"
+	"one-api/types"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	tpmUserKey  = "tpm-limiter:user:%d"
+	tpmTokenKey = "tpm-limiter:token:%d"
+)
+
+type tpmReservation struct {
+	limiter     *limit.TPMLimiter
+	reservation *limit.TPMReservation
+}
+
+// TPMLimit 按预估的提示 token 数占用分组、令牌和渠道的 TPM 额度，请求完成后修正为实际用量
+type TPMLimit struct {
+	c            *gin.Context
+	reservations []*tpmReservation
+	channel      *ChannelTPMReservation // 渠道的占用单独保存，对冲请求由其他渠道胜出时替换
+}
+
+// ChannelTPMReservation 单个渠道的 TPM 额度占用
+type ChannelTPMReservation struct {
+	reservation *tpmReservation
+}
+
+func NewTPMLimit(c *gin.Context) *TPMLimit {
+	return &TPMLimit{c: c}
+}
+
+// Reserve 依次占用分组、令牌和渠道的额度，任意一项超出限制时退回已占用的额度。
+// 分组和令牌超出限制时返回本地错误，渠道超出限制时返回可重试的错误以便切换渠道
+func (t *TPMLimit) Reserve(channel *model.Channel, promptTokens int) *types.OpenAIErrorWithStatusCode {
+	var headerResult *limit.TPMResult
+
+	userLimiter := model.GlobalUserGroupRatio.GetTPMLimiter(t.c.GetString("group"))
+	result, ok := t.reserve(userLimiter, fmt.Sprintf(tpmUserKey, t.c.GetInt("id")), promptTokens)
+	if !ok {
+		return t.rejectError(result, promptTokens)
+	}
+	headerResult = mostRestrictive(headerResult, result)
+
+	var tokenLimiter *limit.TPMLimiter
+	if setting, exists := utils.GetGinValue[*model.TokenSetting](t.c, "token_setting"); exists && setting != nil && setting.Limits.TPM > 0 {
+		tokenLimiter = limit.NewTPMLimiter(setting.Limits.TPM)
+	}
+	result, ok = t.reserve(tokenLimiter, fmt.Sprintf(tpmTokenKey, t.c.GetInt("token_id")), promptTokens)
+	if !ok {
+		return t.rejectError(result, promptTokens)
+	}
+	headerResult = mostRestrictive(headerResult, result)
+
+	channelReservation, err := t.ReserveChannel(channel, promptTokens)
+	if err != nil {
+		t.Release()
+		return err
+	}
+	t.channel = channelReservation
+
+	if headerResult != nil {
+		setTPMHeaders(t.c, headerResult)
+	}
+
+	return nil
+}
+
+// reserve 占用额度，超出限制时退回之前占用的全部额度并返回 false
+func (t *TPMLimit) reserve(limiter *limit.TPMLimiter, key string, tokens int) (*limit.TPMResult, bool) {
+	reservation, result, ok := t.reserveOne(limiter, key, tokens)
+	if !ok {
+		t.Release()
+		return result, false
+	}
+
+	if reservation != nil {
+		t.reservations = append(t.reservations, reservation)
+	}
+
+	return result, true
+}
+
+// reserveOne 占用单个限流器的额度，超出限制时返回 false，不影响已占用的额度
+func (t *TPMLimit) reserveOne(limiter *limit.TPMLimiter, key string, tokens int) (*tpmReservation, *limit.TPMResult, bool) {
+	if limiter == nil {
+		return nil, nil, true
+	}
+
+	reservation, result, err := limiter.Reserve(key, tokens)
+	if err != nil {
+		// 限流器故障时放行请求
+		logger.LogError(t.c.Request.Context(), "tpm limiter error: "+err.Error())
+		return nil, nil, true
+	}
+
+	if !result.Allowed {
+		return nil, result, false
+	}
+
+	return &tpmReservation{
+		limiter:     limiter,
+		reservation: reservation,
+	}, result, true
+}
+
+// ReserveChannel 占用渠道的 TPM 额度，渠道未设置上限时返回 nil。
+// 渠道的限额对用户不可见，超出时作为上游饱和处理，返回可重试的错误以便切换渠道
+func (t *TPMLimit) ReserveChannel(channel *model.Channel, promptTokens int) (*ChannelTPMReservation, *types.OpenAIErrorWithStatusCode) {
+	if channel == nil || channel.MaxTPM <= 0 {
+		return nil, nil
+	}
+
+	reservation, _, ok := t.reserveOne(limit.NewTPMLimiter(channel.MaxTPM), model.ChannelTPMKey(channel.Id), promptTokens)
+	if !ok {
+		return nil, common.StringErrorWrapper(fmt.Sprintf("channel #%d tpm limit reached", channel.Id), "channel_tpm_limit_exceeded", http.StatusTooManyRequests)
+	}
+	if reservation == nil {
+		return nil, nil
+	}
+
+	return &ChannelTPMReservation{reservation: reservation}, nil
+}
+
+// UseChannel 对冲请求由其他渠道胜出时，退回原渠道的占用，改为按胜出渠道的占用结算
+func (t *TPMLimit) UseChannel(reservation *ChannelTPMReservation) {
+	t.channel.Release()
+	t.channel = reservation
+}
+
+func (t *TPMLimit) rejectError(result *limit.TPMResult, tokens int) *types.OpenAIErrorWithStatusCode {
+	setTPMHeaders(t.c, result)
+	tpmErr := common.StringErrorWrapperLocal(
+		fmt.Sprintf("Rate limit reached for tokens per min (TPM): Limit %d, Used %d, Requested %d. Please try again in %s.", result.Limit, result.Used, tokens, formatResetDuration(result.Reset)),
+		"tokens",
+		http.StatusTooManyRequests,
+	)
+	tpmErr.Code = "rate_limit_exceeded"
+
+	return tpmErr
+}
+
+// Settle 将占用的额度修正为实际使用的 token 数
+func (t *TPMLimit) Settle(totalTokens int) {
+	for _, r := range t.reservations {
+		r.adjust(totalTokens)
+	}
+	t.reservations = nil
+
+	if t.channel != nil {
+		t.channel.reservation.adjust(totalTokens)
+		t.channel = nil
+	}
+}
+
+// Release 退回全部占用的额度
+func (t *TPMLimit) Release() {
+	t.Settle(0)
+}
+
+// Release 退回渠道占用的额度
+func (r *ChannelTPMReservation) Release() {
+	if r == nil {
+		return
+	}
+
+	r.reservation.adjust(0)
+}
+
+func (r *tpmReservation) adjust(tokens int) {
+	if err := r.limiter.Adjust(r.reservation, tokens); err != nil {
+		logger.SysError("tpm limiter adjust error: " + err.Error())
+	}
+}
+
+// mostRestrictive 返回剩余额度更少的结果
+func mostRestrictive(current, result *limit.TPMResult) *limit.TPMResult {
+	if result == nil {
+		return current
+	}
+
+	if current == nil || result.Remaining() < current.Remaining() {
+		return result
+	}
+
+	return current
+}
+
+func setTPMHeaders(c *gin.Context, result *limit.TPMResult) {
+	c.Header("x-ratelimit-limit-tokens", strconv.Itoa(result.Limit))
+	c.Header("x-ratelimit-remaining-tokens", strconv.Itoa(result.Remaining()))
+	c.Header("x-ratelimit-reset-tokens", formatResetDuration(result.Reset))
+}
+
+func formatResetDuration(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+
+	return d.Round(time.Millisecond).String()
+}
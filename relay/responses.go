@@ -2,13 +2,17 @@ package relay
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"one-api/common"
+	"one-api/common/config"
 	"one-api/common/logger"
 	"one-api/common/requester"
+	"one-api/model"
 	providersBase "one-api/providers/base"
 	"one-api/relay/relay_util"
+	"one-api/safty"
 	"one-api/types"
 	"time"
 
@@ -19,6 +23,12 @@ import (
 type relayResponses struct {
 	relayBase
 	responsesRequest types.OpenAIResponsesRequest
+
+	// 网关保存的上一个响应，previousChain 为从对话开始到上一个响应的每一轮，history 为其中全部的输入和输出
+	previousResponse *model.StoredResponse
+	previousChain    []*model.StoredResponse
+	history          []map[string]any
+	inputItems       []map[string]any
 }
 
 func NewRelayResponses(c *gin.Context) *relayResponses {
@@ -34,7 +44,11 @@ func (r *relayResponses) setRequest() error {
 
 	r.setOriginalModel(r.responsesRequest.Model)
 
-	return nil
+	if err := r.loadPreviousResponse(); err != nil {
+		return err
+	}
+
+	return r.expandInput()
 }
 
 func (r *relayResponses) getRequest() interface{} {
@@ -52,6 +66,16 @@ func (r *relayResponses) getPromptTokens() (int, error) {
 
 func (r *relayResponses) send() (err *types.OpenAIErrorWithStatusCode, done bool) {
 	r.responsesRequest.Model = r.modelName
+
+	// 内容审查
+	if config.EnableSafe {
+		CheckResult, _ := safty.CheckFields(&r.responsesRequest)
+		if !CheckResult.IsSafe {
+			err = common.StringErrorWrapperLocal(CheckResult.Reason, CheckResult.Code, http.StatusBadRequest)
+			done = true
+			return
+		}
+	}
 	channel := r.provider.GetChannel()
 	responsesProvider, ok := r.provider.(providersBase.ResponsesInterface)
 	if !ok || channel.CompatibleResponse || !r.provider.GetSupportedResponse() {
@@ -66,9 +90,15 @@ func (r *relayResponses) send() (err *types.OpenAIErrorWithStatusCode, done bool
 		return r.compatibleSend(chatProvider)
 	}
 
+	// 上一轮对话已拼接到输入中，不再让上游查找
+	request := r.responsesRequest
+	if r.previousResponse != nil {
+		request.PreviousResponseID = ""
+	}
+
 	if r.responsesRequest.Stream {
 		var response requester.StreamReaderInterface[string]
-		response, err = responsesProvider.CreateResponsesStream(&r.responsesRequest)
+		response, err = responsesProvider.CreateResponsesStream(&request)
 		if err != nil {
 			return
 		}
@@ -77,15 +107,23 @@ func (r *relayResponses) send() (err *types.OpenAIErrorWithStatusCode, done bool
 			return ""
 		}
 
-		firstResponseTime := responseGeneralStreamClient(r.c, response, doneStr)
+		stream := &responsesCaptureStream{stream: response}
+		firstResponseTime := responseGeneralStreamClient(r.c, stream, doneStr, outputFormatResponses)
 		r.SetFirstResponseTime(firstResponseTime)
+		r.storeResponse(stream.response)
 	} else {
 		var response *types.OpenAIResponsesResponses
-		response, err = responsesProvider.CreateResponses(&r.responsesRequest)
+		response, err = responsesProvider.CreateResponses(&request)
 		if err != nil {
 			return
 		}
+		if r.previousResponse != nil {
+			response.PreviousResponseID = r.previousResponse.ResponseId
+		}
 		openErr := responseJsonClient(r.c, response)
+		if openErr == nil {
+			r.storeResponse(response)
+		}
 
 		if openErr != nil {
 			err = openErr
@@ -100,6 +138,12 @@ func (r *relayResponses) send() (err *types.OpenAIErrorWithStatusCode, done bool
 }
 
 func (r *relayResponses) compatibleSend(chatProvider providersBase.ChatInterface) (errWithCode *types.OpenAIErrorWithStatusCode, done bool) {
+	// 聊天渠道无法读取上游保存的响应
+	if r.responsesRequest.PreviousResponseID != "" && r.previousResponse == nil {
+		errWithCode = common.StringErrorWrapperLocal(fmt.Sprintf("Previous response with id '%s' not found.", r.responsesRequest.PreviousResponseID), "previous_response_not_found", http.StatusNotFound)
+		return errWithCode, true
+	}
+
 	chatReq, err := r.responsesRequest.ToChatCompletionRequest()
 	if err != nil {
 		return common.ErrorWrapperLocal(err, "invalid_claude_config", http.StatusInternalServerError), true
@@ -111,8 +155,9 @@ func (r *relayResponses) compatibleSend(chatProvider providersBase.ChatInterface
 		if errWithCode != nil {
 			return
 		}
-		firstResponseTime := r.chatToResponseStreamClient(response)
+		firstResponseTime, responseResp := r.chatToResponseStreamClient(response)
 		r.SetFirstResponseTime(firstResponseTime)
+		r.storeResponse(responseResp)
 	} else {
 		var response *types.ChatCompletionResponse
 		response, errWithCode = chatProvider.CreateChatCompletion(chatReq)
@@ -121,7 +166,10 @@ func (r *relayResponses) compatibleSend(chatProvider providersBase.ChatInterface
 		}
 
 		responseResp := response.ToResponses(&r.responsesRequest)
-		responseJsonClient(r.c, responseResp)
+		responseResp.ID = model.NewResponseId()
+		if responseJsonClient(r.c, responseResp) == nil {
+			r.storeResponse(responseResp)
+		}
 	}
 
 	if errWithCode != nil {
@@ -132,7 +180,7 @@ func (r *relayResponses) compatibleSend(chatProvider providersBase.ChatInterface
 }
 
 // 将chat转换成兼容的responses流处理
-func (r *relayResponses) chatToResponseStreamClient(stream requester.StreamReaderInterface[string]) (firstResponseTime time.Time) {
+func (r *relayResponses) chatToResponseStreamClient(stream requester.StreamReaderInterface[string]) (firstResponseTime time.Time, response *types.OpenAIResponsesResponses) {
 	requester.SetEventStreamHeaders(r.c)
 	dataChan, errChan := stream.Recv()
 
@@ -143,6 +191,9 @@ func (r *relayResponses) chatToResponseStreamClient(stream requester.StreamReade
 	var isFirstResponse bool
 
 	converter := relay_util.NewOpenAIResponsesStreamConverter(r.c, &r.responsesRequest, r.provider.GetUsage())
+	// 输出审核在转换之前按 chat 格式检查，命中时以 Responses 的错误事件结束
+	moderator := newOutputModerator(r.c, outputFormatOpenAI)
+	var moderated bool
 
 	// 在新的goroutine中处理stream数据
 	gopool.Go(func() {
@@ -160,16 +211,47 @@ func (r *relayResponses) chatToResponseStreamClient(stream requester.StreamReade
 					isFirstResponse = true
 				}
 
+				// 命中审核后丢弃剩余的内容，继续读取到结束以统计用量
+				if moderated {
+					continue
+				}
+
+				chunks := []string{data}
+				if moderator != nil {
+					var safe bool
+					if chunks, safe = moderator.push(data); !safe {
+						moderated = true
+						converter.ProcessError(moderator.result.Reason)
+						continue
+					}
+				}
+
 				// 尝试写入数据，如果客户端断开也继续处理
 				select {
 				case <-r.c.Request.Context().Done():
 					// 客户端已断开，不执行任何操作，直接跳过
 				default:
 					// 客户端正常，发送数据
-					converter.ProcessStreamData(data)
+					for _, chunk := range chunks {
+						converter.ProcessStreamData(chunk)
+					}
 				}
 
 			case err := <-errChan:
+				if moderator != nil && !moderated {
+					chunks, safe := moderator.flush()
+					if !safe {
+						moderated = true
+						converter.ProcessError(moderator.result.Reason)
+					}
+					for _, chunk := range chunks {
+						converter.ProcessStreamData(chunk)
+					}
+				}
+
+				if moderated {
+					return
+				}
 				if !errors.Is(err, io.EOF) {
 					// 处理错误情况
 					select {
@@ -192,5 +274,5 @@ func (r *relayResponses) chatToResponseStreamClient(stream requester.StreamReade
 
 	// 等待处理完成
 	<-done
-	return firstResponseTime
+	return firstResponseTime, converter.Response()
 }
@@ -0,0 +1,344 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/requester"
+	"one-api/common/utils"
+	"one-api/model"
+	"one-api/relay/relay_util"
+	"one-api/types"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+)
+
+// parseResponseItems 将 input 统一为输入项列表，字符串视为一条用户消息
+func parseResponseItems(input any) ([]map[string]any, error) {
+	if text, ok := input.(string); ok {
+		return []map[string]any{{
+			"type":    types.InputTypeMessage,
+			"role":    types.ChatMessageRoleUser,
+			"content": []map[string]any{{"type": types.ContentTypeInputText, "text": text}},
+		}}, nil
+	}
+
+	items := make([]map[string]any, 0)
+	if input == nil {
+		return items, nil
+	}
+
+	body, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(body, &items); err != nil {
+		return nil, errors.New("input must be a string or a list of items")
+	}
+
+	return items, nil
+}
+
+// loadPreviousResponse 读取上一个响应及其之前的对话，作为本轮输入的前缀
+func (r *relayResponses) loadPreviousResponse() error {
+	previousId := r.responsesRequest.PreviousResponseID
+	if previousId == "" || config.ResponseStoreRetentionDays <= 0 {
+		return nil
+	}
+
+	previous, err := model.GetUserResponse(r.c.GetInt("id"), previousId)
+	if err != nil {
+		// 网关未保存时可能由上游保存，交给原生渠道处理
+		return nil
+	}
+
+	chain, err := model.GetResponseChain(previous)
+	if err != nil {
+		return err
+	}
+
+	history, err := responseChainItems(chain)
+	if err != nil {
+		return err
+	}
+
+	r.previousResponse = previous
+	r.previousChain = chain
+	r.history = history
+
+	return nil
+}
+
+// responseChainItems 按顺序拼接对话中每一轮的输入和输出
+func responseChainItems(chain []*model.StoredResponse) ([]map[string]any, error) {
+	items := make([]map[string]any, 0)
+	for _, stored := range chain {
+		items = append(items, stored.InputItems.Data()...)
+
+		outputItems, err := responseOutputItems(stored)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, outputItems...)
+	}
+
+	return items, nil
+}
+
+func responseOutputItems(stored *model.StoredResponse) ([]map[string]any, error) {
+	outputItems := make([]map[string]any, 0)
+	body, err := json.Marshal(stored.Response.Data().Output)
+	if err == nil {
+		err = json.Unmarshal(body, &outputItems)
+	}
+
+	return outputItems, err
+}
+
+// expandInput 将上一轮的对话拼接到本轮输入之前
+func (r *relayResponses) expandInput() error {
+	items, err := parseResponseItems(r.responsesRequest.Input)
+	if err != nil {
+		return err
+	}
+	r.inputItems = items
+
+	if r.previousResponse != nil {
+		input := make([]map[string]any, 0, len(r.history)+len(items))
+		input = append(input, r.history...)
+		input = append(input, items...)
+		r.responsesRequest.Input = input
+	}
+
+	return nil
+}
+
+func (r *relayResponses) shouldStore() bool {
+	if config.ResponseStoreRetentionDays <= 0 {
+		return false
+	}
+
+	return r.responsesRequest.Store == nil || *r.responsesRequest.Store
+}
+
+// storeResponse 保存响应对象，保存失败不影响本次请求
+func (r *relayResponses) storeResponse(response *types.OpenAIResponsesResponses) {
+	// 流中断时响应仍处于 in_progress，不保存不完整的对话
+	if response == nil || response.ID == "" || response.Status == "" || response.Status == types.ResponseStatusInProgress || !r.shouldStore() {
+		return
+	}
+	// 命中输出审核的响应不保存，避免后续对话引用未审核的内容
+	if _, ok := r.c.Get(relay_util.OutputModerationKey); ok {
+		return
+	}
+
+	if r.previousResponse != nil {
+		response.PreviousResponseID = r.previousResponse.ResponseId
+	}
+
+	// 只保存本轮新增的输入，之前的对话通过 previous_response_id 读取
+	now := utils.GetTimestamp()
+	stored := &model.StoredResponse{
+		ResponseId:         response.ID,
+		UserId:             r.c.GetInt("id"),
+		TokenId:            r.c.GetInt("token_id"),
+		PreviousResponseId: response.PreviousResponseID,
+		Model:              r.getOriginalModel(),
+		InputItems:         datatypes.NewJSONType(r.inputItems),
+		Response:           datatypes.NewJSONType(*response),
+		InputDelta:         true,
+		CreatedAt:          now,
+		ExpiresAt:          now + int64(config.ResponseStoreRetentionDays)*86400,
+	}
+
+	if err := stored.Insert(); err != nil {
+		logger.LogError(r.c.Request.Context(), "store response error: "+err.Error())
+		return
+	}
+
+	ids := make([]int, 0, len(r.previousChain))
+	for _, previous := range r.previousChain {
+		ids = append(ids, previous.Id)
+	}
+	if err := model.ExtendResponsesExpiry(ids, stored.ExpiresAt); err != nil {
+		logger.LogError(r.c.Request.Context(), "extend stored responses expiry error: "+err.Error())
+	}
+}
+
+// responsesCaptureStream 透传原生渠道的流式响应，并记录最终的响应对象
+type responsesCaptureStream struct {
+	stream   requester.StreamReaderInterface[string]
+	response *types.OpenAIResponsesResponses
+}
+
+func (s *responsesCaptureStream) Recv() (<-chan string, <-chan error) {
+	dataChan := make(chan string)
+	errChan := make(chan error)
+	upstreamData, upstreamErr := s.stream.Recv()
+
+	go func() {
+		for {
+			select {
+			case data, ok := <-upstreamData:
+				if !ok {
+					return
+				}
+				s.capture(data)
+				dataChan <- data
+			case err := <-upstreamErr:
+				errChan <- err
+				return
+			}
+		}
+	}()
+
+	return dataChan, errChan
+}
+
+func (s *responsesCaptureStream) Close() {
+	s.stream.Close()
+}
+
+func (s *responsesCaptureStream) capture(data string) {
+	line := bytes.TrimSpace([]byte(data))
+	if !bytes.HasPrefix(line, []byte("data:")) {
+		return
+	}
+
+	var event types.OpenAIResponsesStreamResponses
+	if err := json.Unmarshal(bytes.TrimSpace(line[5:]), &event); err != nil {
+		return
+	}
+
+	switch event.Type {
+	case "response.completed", "response.incomplete", "response.failed":
+		s.response = event.Response
+	}
+}
+
+// RetrieveResponse 获取保存的响应对象
+func RetrieveResponse(c *gin.Context) {
+	response, err := model.GetUserResponse(c.GetInt("id"), c.Param("id"))
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, response.Response.Data())
+}
+
+// DeleteResponse 删除保存的响应对象
+func DeleteResponse(c *gin.Context) {
+	response, err := model.GetUserResponse(c.GetInt("id"), c.Param("id"))
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if err := response.Delete(); err != nil {
+		common.AbortWithMessage(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":      response.ResponseId,
+		"object":  "response",
+		"deleted": true,
+	})
+}
+
+// ListResponseInputItems 获取响应的输入项，包含之前响应的对话
+func ListResponseInputItems(c *gin.Context) {
+	response, err := model.GetUserResponse(c.GetInt("id"), c.Param("id"))
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	limit := utils.String2Int(c.DefaultQuery("limit", "20"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	chain, err := model.GetResponseChain(response)
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	// 用户输入的项目没有 id，按所属的响应生成，同一项目在后续响应中的 id 保持不变
+	items := make([]map[string]any, 0)
+	for i, stored := range chain {
+		for j, item := range stored.InputItems.Data() {
+			if id, _ := item["id"].(string); id == "" {
+				item["id"] = inputItemId(stored.ResponseId, j, item)
+			}
+			items = append(items, item)
+		}
+		if i == len(chain)-1 {
+			break
+		}
+
+		outputItems, err := responseOutputItems(stored)
+		if err != nil {
+			common.AbortWithMessage(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		items = append(items, outputItems...)
+	}
+
+	// 默认按时间倒序返回
+	if c.Query("order") != "asc" {
+		reversed := make([]map[string]any, len(items))
+		for i, item := range items {
+			reversed[len(items)-1-i] = item
+		}
+		items = reversed
+	}
+
+	if after := c.Query("after"); after != "" {
+		for i, item := range items {
+			if item["id"] == after {
+				items = items[i+1:]
+				break
+			}
+		}
+	}
+
+	list := &types.ResponsesInputItemList{
+		Object: "list",
+		Data:   items,
+	}
+	if len(items) > limit {
+		list.HasMore = true
+		list.Data = items[:limit]
+	}
+	if len(list.Data) > 0 {
+		list.FirstId, _ = list.Data[0]["id"].(string)
+		list.LastId, _ = list.Data[len(list.Data)-1]["id"].(string)
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// inputItemId 用户输入的项目没有 id，按响应 id 和位置生成固定的 id
+func inputItemId(responseId string, index int, item map[string]any) string {
+	prefix := "msg"
+	switch item["type"] {
+	case types.InputTypeFunctionCall:
+		prefix = "fc"
+	case types.InputTypeFunctionCallOutput:
+		prefix = "fco"
+	case types.InputTypeReasoning:
+		prefix = "rs"
+	}
+
+	return fmt.Sprintf("%s_%s%04d", prefix, strings.TrimPrefix(responseId, "resp_"), index)
+}
@@ -0,0 +1,173 @@
+package relay
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/model"
+	"one-api/types"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func setupResponsesStoreTest(t *testing.T, retentionDays int) {
+	t.Helper()
+	logger.Logger = zap.NewNop()
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "one-api.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.StoredResponse{}))
+
+	savedDB, savedDays := model.DB, config.ResponseStoreRetentionDays
+	model.DB, config.ResponseStoreRetentionDays = db, retentionDays
+	t.Cleanup(func() {
+		model.DB, config.ResponseStoreRetentionDays = savedDB, savedDays
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+// sendResponsesTurn 模拟一轮对话：读取上一轮、拼接输入并保存本轮的响应，返回发给上游的输入
+func sendResponsesTurn(t *testing.T, input string, previousId string, store *bool) (*types.OpenAIResponsesResponses, []map[string]any) {
+	t.Helper()
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/responses", nil)
+	c.Set("id", 1)
+
+	r := NewRelayResponses(c)
+	r.responsesRequest = types.OpenAIResponsesRequest{Model: "gpt-4o", Input: input, PreviousResponseID: previousId, Store: store}
+	r.setOriginalModel("gpt-4o")
+	require.NoError(t, r.loadPreviousResponse())
+	require.NoError(t, r.expandInput())
+
+	upstreamInput, _ := r.responsesRequest.Input.([]map[string]any)
+	if upstreamInput == nil {
+		upstreamInput, _ = parseResponseItems(r.responsesRequest.Input)
+	}
+
+	text := "answer to " + input
+	response := &types.OpenAIResponsesResponses{
+		ID:     model.NewResponseId(),
+		Status: types.ResponseStatusCompleted,
+		Output: []types.ResponsesOutput{{
+			Type:    types.InputTypeMessage,
+			ID:      "msg_" + input,
+			Status:  types.ResponseStatusCompleted,
+			Role:    types.ChatMessageRoleAssistant,
+			Content: []map[string]any{{"type": "output_text", "text": text}},
+		}},
+	}
+	r.storeResponse(response)
+
+	return response, upstreamInput
+}
+
+func itemTexts(items []map[string]any) []string {
+	texts := make([]string, 0, len(items))
+	for _, item := range items {
+		body, _ := json.Marshal(item["content"])
+		var content []map[string]any
+		json.Unmarshal(body, &content)
+		texts = append(texts, fmt.Sprintf("%s:%s", item["role"], content[0]["text"]))
+	}
+	return texts
+}
+
+func TestResponsesStoreDelta(t *testing.T) {
+	setupResponsesStoreTest(t, 30)
+
+	first, _ := sendResponsesTurn(t, "q1", "", nil)
+	second, _ := sendResponsesTurn(t, "q2", first.ID, nil)
+	_, upstreamInput := sendResponsesTurn(t, "q3", second.ID, nil)
+
+	// 发给上游的输入包含之前全部的对话
+	assert.Equal(t, []string{
+		"user:q1", "assistant:answer to q1",
+		"user:q2", "assistant:answer to q2",
+		"user:q3",
+	}, itemTexts(upstreamInput))
+
+	// 每一行只保存本轮的输入
+	var rows []*model.StoredResponse
+	require.NoError(t, model.DB.Order("id asc").Find(&rows).Error)
+	require.Len(t, rows, 3)
+	for i, row := range rows {
+		assert.True(t, row.InputDelta)
+		assert.Equal(t, []string{fmt.Sprintf("user:q%d", i+1)}, itemTexts(row.InputItems.Data()))
+	}
+	assert.Equal(t, first.ID, rows[1].PreviousResponseId)
+	assert.Equal(t, second.ID, rows[2].PreviousResponseId)
+}
+
+func TestListResponseInputItemsFollowsChain(t *testing.T) {
+	setupResponsesStoreTest(t, 30)
+
+	first, _ := sendResponsesTurn(t, "q1", "", nil)
+	second, _ := sendResponsesTurn(t, "q2", first.ID, nil)
+
+	list := func(responseId string) *types.ResponsesInputItemList {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/v1/responses/"+responseId+"/input_items?order=asc", nil)
+		c.Params = gin.Params{{Key: "id", Value: responseId}}
+		c.Set("id", 1)
+		ListResponseInputItems(c)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var result types.ResponsesInputItemList
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		return &result
+	}
+
+	firstList := list(first.ID)
+	secondList := list(second.ID)
+	assert.Equal(t, []string{"user:q1"}, itemTexts(firstList.Data))
+	assert.Equal(t, []string{"user:q1", "assistant:answer to q1", "user:q2"}, itemTexts(secondList.Data))
+	// 同一输入在不同响应中的 id 相同
+	assert.Equal(t, firstList.Data[0]["id"], secondList.Data[0]["id"])
+}
+
+func TestResponsesStoreDefault(t *testing.T) {
+	tests := []struct {
+		name          string
+		retentionDays int
+		store         *bool
+		wantStored    bool
+	}{
+		{name: "gateway storage disabled", retentionDays: 0, wantStored: false},
+		{name: "store defaults to true once enabled", retentionDays: 30, wantStored: true},
+		{name: "store false is respected", retentionDays: 30, store: new(bool), wantStored: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupResponsesStoreTest(t, tt.retentionDays)
+			sendResponsesTurn(t, "q1", "", tt.store)
+
+			var count int64
+			require.NoError(t, model.DB.Model(&model.StoredResponse{}).Count(&count).Error)
+			assert.Equal(t, tt.wantStored, count == 1)
+		})
+	}
+}
+
+func TestResponsesStoreDefaultRetention(t *testing.T) {
+	// 保存对话内容需要管理员主动开启
+	assert.Zero(t, config.ResponseStoreRetentionDays)
+}
@@ -42,7 +42,9 @@ func RelayTaskSubmit(c *gin.Context) {
 		return
 	}
 
+	channel := taskAdaptor.GetProvider().GetChannel()
 	taskErr = taskAdaptor.Relay()
+	recordCircuitBreaker(channel.Id, taskAdaptor.GetModelName(), taskErr)
 	if taskErr == nil {
 		CompletedTask(quotaInstance, taskAdaptor, c)
 		// 返回结果
@@ -60,9 +62,7 @@ func RelayTaskSubmit(c *gin.Context) {
 		retryTimes = 0
 	}
 
-	channel := taskAdaptor.GetProvider().GetChannel()
 	for i := retryTimes; i > 0; i-- {
-		model.ChannelGroup.SetCooldowns(channel.Id, taskAdaptor.GetModelName())
 		taskErr = taskAdaptor.SetProvider()
 		if taskErr != nil {
 			continue
@@ -72,6 +72,7 @@ func RelayTaskSubmit(c *gin.Context) {
 		logger.LogError(c.Request.Context(), fmt.Sprintf("using channel #%d(%s) to retry (remain times %d)", channel.Id, channel.Name, i))
 
 		taskErr = taskAdaptor.Relay()
+		recordCircuitBreaker(channel.Id, taskAdaptor.GetModelName(), taskErr)
 		if taskErr == nil {
 			go CompletedTask(quotaInstance, taskAdaptor, c)
 			return
@@ -90,6 +91,20 @@ func RelayTaskSubmit(c *gin.Context) {
 
 }
 
+func recordCircuitBreaker(channelId int, modelName string, taskErr *base.TaskError) {
+	if taskErr == nil {
+		model.CircuitBreakers.RecordSuccess(channelId, modelName)
+		return
+	}
+
+	if !relay_util.IsChannelFailure(taskErr.StatusCode, taskErr.LocalError) {
+		return
+	}
+
+	// 上游限流时直接熔断
+	model.CircuitBreakers.RecordFailure(channelId, modelName, taskErr.StatusCode == http.StatusTooManyRequests)
+}
+
 func CompletedTask(quotaInstance *relay_util.Quota, taskAdaptor base.TaskInterface, c *gin.Context) {
 	quotaInstance.Consume(c, &types.Usage{CompletionTokens: 0, PromptTokens: 1, TotalTokens: 1}, false)
 
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"one-api/common"
 	providersBase "one-api/providers/base"
+	"one-api/relay/files"
 	"one-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -21,6 +22,10 @@ func NewRelayTranscriptions(c *gin.Context) *relayTranscriptions {
 }
 
 func (r *relayTranscriptions) setRequest() error {
+	if err := files.ResolveFileReferences(r.c, "file"); err != nil {
+		return err
+	}
+
 	if err := common.UnmarshalBodyReusable(r.c, &r.request); err != nil {
 		return err
 	}
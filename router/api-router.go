@@ -74,10 +74,12 @@ func SetApiRouter(router *gin.Engine) {
 			{
 				selfRoute.GET("/dashboard", controller.GetUserDashboard)
 				selfRoute.GET("/dashboard/rate", controller.GetRateRealtime)
+				selfRoute.GET("/budget", controller.GetUserBudget)
 				selfRoute.GET("/dashboard/uptimekuma/status-page", controller.UptimeKumaStatusPage)
 				selfRoute.GET("/dashboard/uptimekuma/status-page/heartbeat", controller.UptimeKumaStatusPageHeartbeat)
 				selfRoute.GET("/invoice", controller.GetUserInvoice)
 				selfRoute.GET("/invoice/detail", controller.GetUserInvoiceDetail)
+				selfRoute.GET("/invoice/download", controller.DownloadUserInvoice)
 				selfRoute.GET("/self", controller.GetSelf)
 				selfRoute.PUT("/self", controller.UpdateSelf)
 				selfRoute.POST("/unbind", controller.Unbind)
@@ -88,6 +90,10 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.GET("/payment", controller.GetUserPaymentList)
 				selfRoute.POST("/order", controller.CreateOrder)
 				selfRoute.GET("/order/status", controller.CheckOrderStatus)
+				selfRoute.GET("/subscription", controller.GetSelfSubscription)
+				selfRoute.GET("/subscription/plans", controller.GetSubscriptionPlans)
+				selfRoute.POST("/subscription", controller.Subscribe)
+				selfRoute.POST("/subscription/cancel", controller.CancelSelfSubscription)
 			}
 
 			adminRoute := userRoute.Group("/")
@@ -167,6 +173,7 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.PUT("/batch/del_model", controller.BatchDelModelChannels)
 			channelRoute.DELETE("/disabled", controller.DeleteDisabledChannel)
 			channelRoute.DELETE("/:id/tag", controller.DeleteChannelTag)
+			channelRoute.DELETE("/:id/circuit_breaker", controller.ResetChannelCircuitBreaker)
 			channelRoute.DELETE("/:id", controller.DeleteChannel)
 			channelRoute.DELETE("/batch", controller.BatchDeleteChannel)
 		}
@@ -190,10 +197,37 @@ func SetApiRouter(router *gin.Engine) {
 			tokenRoute.GET("/playground", controller.GetPlaygroundToken)
 			tokenRoute.GET("/", controller.GetUserTokensList)
 			tokenRoute.GET("/:id", controller.GetToken)
+			tokenRoute.GET("/:id/budget", controller.GetTokenBudget)
 			tokenRoute.POST("/", controller.AddToken)
 			tokenRoute.PUT("/", controller.UpdateToken)
 			tokenRoute.DELETE("/:id", controller.DeleteToken)
 		}
+		organizationRoute := apiRouter.Group("/organization")
+		organizationRoute.Use(middleware.UserAuth())
+		{
+			organizationRoute.GET("/", controller.GetUserOrganizations)
+			organizationRoute.POST("/", controller.CreateOrganization)
+			organizationRoute.POST("/invitation/accept", controller.AcceptOrganizationInvitation)
+			organizationRoute.GET("/manage", middleware.AdminAuth(), controller.GetOrganizationsList)
+			organizationRoute.PUT("/manage/:id", middleware.AdminAuth(), controller.ManageOrganization)
+			organizationRoute.GET("/:id", controller.GetOrganization)
+			organizationRoute.PUT("/:id", controller.UpdateOrganization)
+			organizationRoute.DELETE("/:id", controller.DeleteOrganization)
+			organizationRoute.POST("/:id/quota", controller.TransferQuotaToOrganization)
+			organizationRoute.POST("/:id/owner", controller.TransferOrganizationOwnership)
+			organizationRoute.GET("/:id/members", controller.GetOrganizationMembers)
+			organizationRoute.PUT("/:id/members/:user_id", controller.UpdateOrganizationMember)
+			organizationRoute.DELETE("/:id/members/:user_id", controller.RemoveOrganizationMember)
+			organizationRoute.GET("/:id/invitations", controller.GetOrganizationInvitations)
+			organizationRoute.POST("/:id/invitations", controller.CreateOrganizationInvitation)
+			organizationRoute.DELETE("/:id/invitations/:invitation_id", controller.RevokeOrganizationInvitation)
+			organizationRoute.GET("/:id/tokens", controller.GetOrganizationTokens)
+			organizationRoute.DELETE("/:id/tokens/:token_id", controller.DeleteOrganizationToken)
+			organizationRoute.GET("/:id/logs", controller.GetOrganizationLogs)
+			organizationRoute.GET("/:id/statistics", controller.GetOrganizationStatistics)
+			organizationRoute.GET("/:id/invoices", controller.GetOrganizationInvoices)
+			organizationRoute.GET("/:id/invoices/:invoice_id/download", controller.DownloadOrganizationInvoice)
+		}
 		redemptionRoute := apiRouter.Group("/redemption")
 		redemptionRoute.Use(middleware.AdminAuth())
 		{
@@ -243,6 +277,8 @@ func SetApiRouter(router *gin.Engine) {
 		paymentRoute.Use(middleware.AdminAuth())
 		{
 			paymentRoute.GET("/order", controller.GetOrderList)
+			paymentRoute.GET("/order/:id/refund", controller.GetOrderRefunds)
+			paymentRoute.POST("/order/:id/refund", controller.RefundOrder)
 			paymentRoute.GET("/", controller.GetPaymentList)
 			paymentRoute.GET("/:id", controller.GetPayment)
 			paymentRoute.POST("/", controller.AddPayment)
@@ -250,6 +286,25 @@ func SetApiRouter(router *gin.Engine) {
 			paymentRoute.DELETE("/:id", controller.DeletePayment)
 		}
 
+		invoiceRoute := apiRouter.Group("/invoice")
+		invoiceRoute.Use(middleware.AdminAuth())
+		{
+			invoiceRoute.GET("/", controller.GetInvoiceList)
+			invoiceRoute.GET("/:id/download", controller.DownloadInvoice)
+			invoiceRoute.PUT("/:id/settle", controller.SettleInvoice)
+		}
+
+		subscriptionRoute := apiRouter.Group("/subscription")
+		subscriptionRoute.Use(middleware.AdminAuth())
+		{
+			subscriptionRoute.GET("/", controller.GetSubscriptionList)
+			subscriptionRoute.GET("/plan", controller.GetSubscriptionPlanList)
+			subscriptionRoute.GET("/plan/:id", controller.GetSubscriptionPlan)
+			subscriptionRoute.POST("/plan", controller.AddSubscriptionPlan)
+			subscriptionRoute.PUT("/plan", controller.UpdateSubscriptionPlan)
+			subscriptionRoute.DELETE("/plan/:id", controller.DeleteSubscriptionPlan)
+		}
+
 		mjRoute := apiRouter.Group("/mj")
 		mjRoute.GET("/self", middleware.UserAuth(), controller.GetUserMidjourney)
 		mjRoute.GET("/", middleware.AdminAuth(), controller.GetAllMidjourney)
@@ -3,6 +3,8 @@ package router
 import (
 	"one-api/middleware"
 	"one-api/relay"
+	"one-api/relay/batch"
+	"one-api/relay/files"
 	"one-api/relay/midjourney"
 	"one-api/relay/task"
 	"one-api/relay/task/kling"
@@ -36,6 +38,9 @@ func setOpenAIRouter(router *gin.Engine) {
 		relayV1Router.POST("/completions", relay.Relay)
 		relayV1Router.POST("/chat/completions", relay.Relay)
 		relayV1Router.POST("/responses", relay.Relay)
+		relayV1Router.GET("/responses/:id", relay.RelayOnlyOr(relay.RetrieveResponse))
+		relayV1Router.DELETE("/responses/:id", relay.RelayOnlyOr(relay.DeleteResponse))
+		relayV1Router.GET("/responses/:id/input_items", relay.RelayOnlyOr(relay.ListResponseInputItems))
 		// relayV1Router.POST("/edits", controller.Relay)
 		relayV1Router.POST("/images/generations", relay.Relay)
 		relayV1Router.POST("/images/edits", relay.Relay)
@@ -49,16 +54,24 @@ func setOpenAIRouter(router *gin.Engine) {
 		relayV1Router.POST("/rerank", relay.RelayRerank)
 		relayV1Router.GET("/realtime", relay.ChatRealtime)
 
+		// 文件和批处理由网关处理，指定渠道时仍透传到上游
+		relayV1Router.POST("/files", relay.RelayOnlyOr(files.Upload))
+		relayV1Router.GET("/files", relay.RelayOnlyOr(files.List))
+		relayV1Router.GET("/files/:id", relay.RelayOnlyOr(files.Retrieve))
+		relayV1Router.GET("/files/:id/content", relay.RelayOnlyOr(files.Content))
+		relayV1Router.DELETE("/files/:id", relay.RelayOnlyOr(files.Delete))
+		relayV1Router.POST("/batches", relay.RelayOnlyOr(batch.CreateBatch))
+		relayV1Router.GET("/batches", relay.RelayOnlyOr(batch.ListBatches))
+		relayV1Router.GET("/batches/:id", relay.RelayOnlyOr(batch.RetrieveBatch))
+		relayV1Router.POST("/batches/:id/cancel", relay.RelayOnlyOr(batch.CancelBatch))
+
 		relayV1Router.Use(middleware.SpecifiedChannel())
 		{
-			relayV1Router.Any("/files", relay.RelayOnly)
-			relayV1Router.Any("/files/*any", relay.RelayOnly)
 			relayV1Router.Any("/fine_tuning/*any", relay.RelayOnly)
 			relayV1Router.Any("/assistants", relay.RelayOnly)
 			relayV1Router.Any("/assistants/*any", relay.RelayOnly)
 			relayV1Router.Any("/threads", relay.RelayOnly)
 			relayV1Router.Any("/threads/*any", relay.RelayOnly)
-			relayV1Router.Any("/batches/*any", relay.RelayOnly)
 			relayV1Router.Any("/vector_stores/*any", relay.RelayOnly)
 			relayV1Router.DELETE("/models/:model", relay.RelayOnly)
 		}
@@ -121,7 +134,7 @@ func setGeminiRouter(router *gin.Engine) {
 	relayGeminiRouter := router.Group("/gemini")
 	relayGeminiRouter.Use(middleware.APIEnabled("gemini"), middleware.RelayGeminiPanicRecover(), middleware.GeminiAuth(), middleware.Distribute(), middleware.DynamicRedisRateLimiter())
 	{
-		relayGeminiRouter.POST("/:version/models/:model", relay.Relay)
+		relayGeminiRouter.POST("/:version/models/:model", relay.RelayGemini)
 		relayGeminiRouter.GET("/:version/models", relay.ListGeminiModelsByToken)
 	}
 }
@@ -0,0 +1,109 @@
+package safty
+
+import (
+	"fmt"
+	"one-api/common/logger"
+	"one-api/safty/types"
+	"strings"
+)
+
+// ChainTool 按顺序串联多个检查器，前一个检查器脱敏后的内容交给后一个检查器
+// 设置了风险阈值时，命中的检查器风险等级之和达到阈值才视为不安全，否则任一检查器命中即不安全
+// 检查器调用失败（如审核服务不可用）时跳过该检查器，由其余检查器继续检查，避免外部服务故障拦截所有请求
+type ChainTool struct {
+	tools  []SaftyTool
+	config *types.CheckConfig
+}
+
+// NewChainTool 创建串联检查器
+// 参数:
+//   - tools: 按顺序执行的检查器
+//   - config: 检查配置，Threshold 为风险阈值
+func NewChainTool(tools []SaftyTool, config *types.CheckConfig) *ChainTool {
+	return &ChainTool{
+		tools:  tools,
+		config: config,
+	}
+}
+
+// Name 返回检查器名称
+func (ct *ChainTool) Name() string {
+	names := make([]string, 0, len(ct.tools))
+	for _, tool := range ct.tools {
+		names = append(names, tool.Name())
+	}
+	return strings.Join(names, ",")
+}
+
+// Init 串联的检查器已经在注册时初始化
+func (ct *ChainTool) Init() error {
+	return nil
+}
+
+// Check 依次执行串联的检查器并汇总结果
+func (ct *ChainTool) Check(data string) (types.CheckResult, error) {
+	result := types.CheckResult{
+		IsSafe:    true,
+		RiskLevel: 0,
+		Code:      types.SafeDefaultSuccessCode,
+		Reason:    types.SafeDefaultSuccessMessage,
+		Details:   make([]string, 0),
+	}
+
+	content := data
+	riskLevel := 0
+	hit := false
+	hitRiskLevel := -1
+
+	for _, tool := range ct.tools {
+		toolResult, err := tool.Check(content)
+		if err != nil {
+			logger.SysError(fmt.Sprintf("SafeTools %s check failed, skipped: %v", tool.Name(), err))
+			continue
+		}
+
+		if toolResult.Redacted {
+			content = toolResult.Content
+			result.Redacted = true
+			result.Content = content
+		}
+
+		if toolResult.IsSafe {
+			continue
+		}
+
+		hit = true
+		riskLevel += toolResult.RiskLevel
+		result.Details = append(result.Details, toolResult.Details...)
+		// 使用风险等级最高的检查器的错误信息
+		if toolResult.RiskLevel > hitRiskLevel {
+			hitRiskLevel = toolResult.RiskLevel
+			result.Code = toolResult.Code
+			result.Reason = toolResult.Reason
+		}
+
+		if ct.threshold() <= 0 {
+			break
+		}
+	}
+
+	result.RiskLevel = riskLevel
+	if !hit || (ct.threshold() > 0 && float64(riskLevel) < ct.threshold()) {
+		result.Code = types.SafeDefaultSuccessCode
+		result.Reason = types.SafeDefaultSuccessMessage
+		return result, nil
+	}
+
+	result.IsSafe = false
+	result.Redacted = false
+	result.Content = ""
+
+	return result, nil
+}
+
+func (ct *ChainTool) threshold() float64 {
+	if ct.config == nil {
+		return 0
+	}
+	return ct.config.Threshold
+}
@@ -0,0 +1,103 @@
+package safty
+
+import (
+	"errors"
+	"one-api/common/logger"
+	"one-api/safty/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type fakeTool struct {
+	name   string
+	result types.CheckResult
+	err    error
+}
+
+func (f *fakeTool) Name() string { return f.name }
+
+func (f *fakeTool) Init() error { return nil }
+
+func (f *fakeTool) Check(string) (types.CheckResult, error) { return f.result, f.err }
+
+func safeTool(name string) *fakeTool {
+	return &fakeTool{name: name, result: types.CheckResult{IsSafe: true, Code: types.SafeDefaultSuccessCode}}
+}
+
+func unsafeTool(name string, riskLevel int) *fakeTool {
+	return &fakeTool{name: name, result: types.CheckResult{IsSafe: false, RiskLevel: riskLevel, Code: name, Reason: name, Details: []string{name}}}
+}
+
+func failedTool(name string) *fakeTool {
+	return &fakeTool{name: name, err: errors.New("moderation unavailable")}
+}
+
+func TestChainToolCheck(t *testing.T) {
+	logger.Logger = zap.NewNop()
+
+	tests := []struct {
+		name      string
+		tools     []SaftyTool
+		threshold float64
+		wantSafe  bool
+		wantCode  string
+		wantRisk  int
+	}{
+		{
+			name:     "all tools pass",
+			tools:    []SaftyTool{safeTool("Keyword"), safeTool("Regex")},
+			wantSafe: true,
+			wantCode: types.SafeDefaultSuccessCode,
+		},
+		{
+			name:     "any hit blocks without threshold",
+			tools:    []SaftyTool{safeTool("Keyword"), unsafeTool("Regex", 2)},
+			wantSafe: false,
+			wantCode: "Regex",
+			wantRisk: 2,
+		},
+		{
+			name:      "risk below threshold passes",
+			tools:     []SaftyTool{unsafeTool("Keyword", 2), unsafeTool("Regex", 3)},
+			threshold: 6,
+			wantSafe:  true,
+			wantCode:  types.SafeDefaultSuccessCode,
+			wantRisk:  5,
+		},
+		{
+			name:      "risk reaching threshold blocks with the highest risk reason",
+			tools:     []SaftyTool{unsafeTool("Keyword", 2), unsafeTool("Regex", 4)},
+			threshold: 6,
+			wantSafe:  false,
+			wantCode:  "Regex",
+			wantRisk:  6,
+		},
+		{
+			name:     "failed tool is skipped",
+			tools:    []SaftyTool{failedTool("LLMJudge")},
+			wantSafe: true,
+			wantCode: types.SafeDefaultSuccessCode,
+		},
+		{
+			name:     "remaining tools still run after a failure",
+			tools:    []SaftyTool{failedTool("LLMJudge"), unsafeTool("Keyword", 1)},
+			wantSafe: false,
+			wantCode: "Keyword",
+			wantRisk: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := NewChainTool(tt.tools, &types.CheckConfig{Threshold: tt.threshold})
+
+			result, err := chain.Check("content")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantSafe, result.IsSafe)
+			assert.Equal(t, tt.wantCode, result.Code)
+			assert.Equal(t, tt.wantRisk, result.RiskLevel)
+		})
+	}
+}
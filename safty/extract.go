@@ -0,0 +1,161 @@
+package safty
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 值为字符串时需要检查的字段，覆盖 OpenAI、Claude、Gemini 和 Responses 格式中的文本、
+// 系统提示词、工具调用参数、工具结果、工具定义和图片描述
+var textFieldKeys = map[string]bool{
+	"text":              true,
+	"content":           true,
+	"arguments":         true,
+	"description":       true,
+	"instructions":      true,
+	"system":            true,
+	"input":             true,
+	"output":            true,
+	"prompt":            true,
+	"refusal":           true,
+	"thinking":          true,
+	"reasoning":         true,
+	"reasoning_content": true,
+	"alt":               true,
+	"alt_text":          true,
+	"title":             true,
+	"context":           true,
+}
+
+// 值为对象时其中所有字符串都需要检查的字段，如工具调用参数、工具返回结果和工具的参数定义
+var textTreeKeys = map[string]bool{
+	"input":                true,
+	"args":                 true,
+	"response":             true,
+	"parameters":           true,
+	"input_schema":         true,
+	"parametersJsonSchema": true,
+}
+
+// 不需要检查的字段，如标识、类型和 base64 数据
+var skipFieldKeys = map[string]bool{
+	"id":                true,
+	"type":              true,
+	"role":              true,
+	"model":             true,
+	"call_id":           true,
+	"tool_call_id":      true,
+	"tool_use_id":       true,
+	"data":              true,
+	"url":               true,
+	"file_data":         true,
+	"fileUri":           true,
+	"file_id":           true,
+	"mimeType":          true,
+	"mime_type":         true,
+	"media_type":        true,
+	"signature":         true,
+	"encrypted_content": true,
+	"cache_control":     true,
+	"detail":            true,
+	"format":            true,
+	"status":            true,
+}
+
+// TextField 从请求中提取的文本及其字段路径，如 messages[1].tool_calls[0].function.arguments
+type TextField struct {
+	Path string
+	Text string
+
+	set func(string)
+}
+
+// ExtractTextFields 提取内容中所有需要检查的文本字段
+// 参数:
+//   - content: 要提取的内容，可以是字符串、请求结构体或解析后的 JSON
+//
+// 返回值:
+//   - []TextField: 按字段路径排列的文本
+func ExtractTextFields(content any) []TextField {
+	fields, _ := extractTextTree(content)
+	return fields
+}
+
+// extractTextTree 将内容转换为 JSON 树并提取文本字段，修改 TextField 会同步修改返回的 JSON 树
+func extractTextTree(content any) ([]TextField, any) {
+	if text, ok := content.(string); ok {
+		return []TextField{{Text: text}}, text
+	}
+
+	body, err := json.Marshal(content)
+	if err != nil {
+		return nil, nil
+	}
+
+	var tree any
+	if err := json.Unmarshal(body, &tree); err != nil {
+		return nil, nil
+	}
+
+	fields := make([]TextField, 0)
+	walkTextTree(&fields, "", "", tree, false, nil)
+
+	return fields, tree
+}
+
+// walkTextTree 遍历 JSON 树，key 为当前值所在的字段名，数组中的元素沿用数组的字段名；
+// inTree 表示当前处于工具参数等需要完整检查的对象中
+func walkTextTree(fields *[]TextField, path, key string, node any, inTree bool, set func(string)) {
+	switch value := node.(type) {
+	case string:
+		if value != "" && (inTree || textFieldKeys[key]) {
+			*fields = append(*fields, TextField{Path: path, Text: value, set: set})
+		}
+	case []any:
+		for i := range value {
+			i := i
+			walkTextTree(fields, fmt.Sprintf("%s[%d]", path, i), key, value[i], inTree, func(text string) {
+				value[i] = text
+			})
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			if skipFieldKeys[k] {
+				continue
+			}
+			// 工具调用参数中的 name 等字段也是用户内容，参数之外的 name 为函数名
+			if k == "name" && !inTree {
+				continue
+			}
+
+			k := k
+			childPath := k
+			if path != "" {
+				childPath = path + "." + k
+			}
+
+			_, isObject := value[k].(map[string]any)
+			walkTextTree(fields, childPath, k, value[k], inTree || (isObject && textTreeKeys[k]), func(text string) {
+				value[k] = text
+			})
+		}
+	}
+}
+
+// joinTextFields 将提取的文本拼接为一个字符串
+func joinTextFields(fields []TextField) string {
+	texts := make([]string, 0, len(fields))
+	for _, field := range fields {
+		texts = append(texts, field.Text)
+	}
+
+	return strings.Join(texts, "\n")
+}
@@ -0,0 +1,127 @@
+package safty
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractTextFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		content any
+		want    map[string]string
+	}{
+		{
+			name:    "plain string",
+			content: "hello",
+			want:    map[string]string{"": "hello"},
+		},
+		{
+			name: "openai messages with multimodal content and tool calls",
+			content: map[string]any{
+				"model": "gpt-4o",
+				"messages": []any{
+					map[string]any{"role": "system", "content": "be nice"},
+					map[string]any{"role": "user", "content": []any{
+						map[string]any{"type": "text", "text": "describe"},
+						map[string]any{"type": "image_url", "image_url": map[string]any{"url": "data:image/png;base64,AAAA", "detail": "high"}},
+					}},
+					map[string]any{"role": "assistant", "tool_calls": []any{
+						map[string]any{"id": "call_1", "type": "function", "function": map[string]any{"name": "search", "arguments": `{"q":"secret"}`}},
+					}},
+					map[string]any{"role": "tool", "tool_call_id": "call_1", "content": "result"},
+				},
+			},
+			want: map[string]string{
+				"messages[0].content":                          "be nice",
+				"messages[1].content[0].text":                  "describe",
+				"messages[2].tool_calls[0].function.arguments": `{"q":"secret"}`,
+				"messages[3].content":                          "result",
+			},
+		},
+		{
+			name: "tool definitions check every string in parameters",
+			content: map[string]any{
+				"tools": []any{
+					map[string]any{"type": "function", "function": map[string]any{
+						"name":        "search",
+						"description": "search the web",
+						"parameters": map[string]any{
+							"type":       "object",
+							"properties": map[string]any{"q": map[string]any{"type": "string", "description": "query"}},
+						},
+					}},
+				},
+			},
+			want: map[string]string{
+				"tools[0].function.description":                         "search the web",
+				"tools[0].function.parameters.properties.q.description": "query",
+			},
+		},
+		{
+			name: "claude tool_use input includes nested names",
+			content: map[string]any{
+				"system": "sys",
+				"messages": []any{
+					map[string]any{"role": "assistant", "content": []any{
+						map[string]any{"type": "tool_use", "id": "toolu_1", "name": "lookup", "input": map[string]any{"name": "alice", "tags": []any{"a", "b"}}},
+					}},
+				},
+			},
+			want: map[string]string{
+				"system":                               "sys",
+				"messages[0].content[0].input.name":    "alice",
+				"messages[0].content[0].input.tags[0]": "a",
+				"messages[0].content[0].input.tags[1]": "b",
+			},
+		},
+		{
+			name: "gemini function call args and inline data",
+			content: map[string]any{
+				"contents": []any{
+					map[string]any{"role": "user", "parts": []any{
+						map[string]any{"text": "hi"},
+						map[string]any{"inlineData": map[string]any{"mimeType": "image/png", "data": "AAAA"}},
+						map[string]any{"functionCall": map[string]any{"name": "f", "args": map[string]any{"city": "Paris"}}},
+					}},
+				},
+			},
+			want: map[string]string{
+				"contents[0].parts[0].text":                   "hi",
+				"contents[0].parts[2].functionCall.args.city": "Paris",
+			},
+		},
+		{
+			name:    "empty strings and unknown keys are skipped",
+			content: map[string]any{"content": "", "user": "u1", "metadata": map[string]any{"note": "x"}},
+			want:    map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string]string)
+			for _, field := range ExtractTextFields(tt.content) {
+				got[field.Path] = field.Text
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestExtractTextTreeSetWritesBack(t *testing.T) {
+	content := map[string]any{
+		"messages": []any{
+			map[string]any{"role": "user", "content": "my phone is 123"},
+		},
+	}
+
+	fields, tree := extractTextTree(content)
+	assert.Len(t, fields, 1)
+	fields[0].set("my phone is ***")
+
+	var redacted map[string]any
+	assert.NoError(t, writeBack(tree, &redacted))
+	assert.Equal(t, "my phone is ***", redacted["messages"].([]any)[0].(map[string]any)["content"])
+}
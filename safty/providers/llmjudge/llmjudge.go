@@ -0,0 +1,162 @@
+package llmjudge
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"one-api/common/config"
+	"one-api/safty/types"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type moderationResult struct {
+	Flagged        bool               `json:"flagged"`
+	Categories     map[string]bool    `json:"categories"`
+	CategoryScores map[string]float64 `json:"category_scores"`
+}
+
+type moderationResponse struct {
+	Results []moderationResult `json:"results"`
+	Error   *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// LLMJudgeChecker 使用审核模型判断内容是否安全
+// 通过网关自身的 /v1/moderations 接口调用，审核请求按 SafeLLMJudgeToken 对应的令牌计费
+type LLMJudgeChecker struct {
+	client *http.Client
+}
+
+// NewLLMJudgeChecker 创建新的 LLM 审查检查器实例
+func NewLLMJudgeChecker() *LLMJudgeChecker {
+	return &LLMJudgeChecker{
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Name 返回检查器名称
+func (l *LLMJudgeChecker) Name() string {
+	return "LLMJudge"
+}
+
+// Init 初始化 LLM 审查检查器
+func (l *LLMJudgeChecker) Init() error {
+	return nil
+}
+
+// Available 配置了 SafeLLMJudgeToken 后才可用，每次使用时读取配置，修改后立即生效
+func (l *LLMJudgeChecker) Available() bool {
+	return config.SafeLLMJudgeToken != ""
+}
+
+// endpoint 网关自身的审核接口地址
+func endpoint() string {
+	return fmt.Sprintf("http://127.0.0.1:%s/v1/moderations", viper.GetString("port"))
+}
+
+// Check 调用审核模型检查内容
+// 参数:
+//   - data: 要检查的内容
+//
+// 返回值:
+//   - CheckResult: 审核模型判定违规则不安全，风险等级为最高分类得分乘以 10，Details 为违规的分类
+//   - error: 调用审核接口失败时返回错误，此时没有检查结果，由 ChainTool 跳过该检查器
+func (l *LLMJudgeChecker) Check(data string) (types.CheckResult, error) {
+	response, err := l.moderate(data)
+	if err != nil {
+		return types.CheckResult{}, err
+	}
+
+	result := types.CheckResult{
+		IsSafe:    false,
+		RiskLevel: 1,
+		Code:      types.SafeDefaultErrorCode,
+		Reason:    types.SafeDefaultErrorMessage,
+		Details:   make([]string, 0),
+	}
+
+	maxScore := 0.0
+	flagged := false
+	for _, item := range response.Results {
+		for category, score := range item.CategoryScores {
+			maxScore = math.Max(maxScore, score)
+
+			violated := item.Categories[category]
+			if config.SafeLLMJudgeThreshold > 0 {
+				violated = score >= config.SafeLLMJudgeThreshold
+			}
+			if violated {
+				result.Details = append(result.Details, category)
+			}
+		}
+
+		if config.SafeLLMJudgeThreshold <= 0 && item.Flagged {
+			flagged = true
+		}
+	}
+	sort.Strings(result.Details)
+
+	result.RiskLevel = int(math.Round(maxScore * 10))
+	if flagged || len(result.Details) > 0 {
+		if result.RiskLevel == 0 {
+			result.RiskLevel = 1
+		}
+		return result, nil
+	}
+
+	result.IsSafe = true
+	result.Code = types.SafeDefaultSuccessCode
+	result.Reason = types.SafeDefaultSuccessMessage
+	result.RiskLevel = 0
+
+	return result, nil
+}
+
+func (l *LLMJudgeChecker) moderate(data string) (*moderationResponse, error) {
+	if config.SafeLLMJudgeToken == "" {
+		return nil, errors.New("llm judge token is not configured")
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"model": config.SafeLLMJudgeModel,
+		"input": data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+strings.TrimPrefix(config.SafeLLMJudgeToken, "Bearer "))
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response moderationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		if response.Error != nil {
+			return nil, fmt.Errorf("status code %d: %s", resp.StatusCode, response.Error.Message)
+		}
+		return nil, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+
+	return &response, nil
+}
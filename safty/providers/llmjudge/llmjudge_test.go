@@ -0,0 +1,122 @@
+package llmjudge
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common/config"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupModerationServer 在网关端口上模拟 /v1/moderations 接口
+func setupModerationServer(t *testing.T, status int, body string) {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/moderations", r.URL.Path)
+		assert.Equal(t, "Bearer sk-judge", r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+
+	oldPort, token, threshold := viper.GetString("port"), config.SafeLLMJudgeToken, config.SafeLLMJudgeThreshold
+	viper.Set("port", port)
+	config.SafeLLMJudgeToken = "sk-judge"
+	t.Cleanup(func() {
+		viper.Set("port", oldPort)
+		config.SafeLLMJudgeToken, config.SafeLLMJudgeThreshold = token, threshold
+	})
+}
+
+func TestLLMJudgeCheckerCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		threshold   float64
+		wantSafe    bool
+		wantRisk    int
+		wantDetails []string
+		wantErr     bool
+	}{
+		{
+			name:     "not flagged",
+			status:   http.StatusOK,
+			body:     `{"results":[{"flagged":false,"categories":{"violence":false},"category_scores":{"violence":0.01}}]}`,
+			wantSafe: true,
+		},
+		{
+			name:        "flagged categories",
+			status:      http.StatusOK,
+			body:        `{"results":[{"flagged":true,"categories":{"violence":true,"hate":true,"sexual":false},"category_scores":{"violence":0.83,"hate":0.6,"sexual":0.1}}]}`,
+			wantSafe:    false,
+			wantRisk:    8,
+			wantDetails: []string{"hate", "violence"},
+		},
+		{
+			name:        "threshold overrides the flag",
+			status:      http.StatusOK,
+			body:        `{"results":[{"flagged":false,"categories":{"violence":false},"category_scores":{"violence":0.42}}]}`,
+			threshold:   0.4,
+			wantSafe:    false,
+			wantRisk:    4,
+			wantDetails: []string{"violence"},
+		},
+		{
+			name:      "flag below threshold passes",
+			status:    http.StatusOK,
+			body:      `{"results":[{"flagged":true,"categories":{"violence":true},"category_scores":{"violence":0.3}}]}`,
+			threshold: 0.5,
+			wantSafe:  true,
+		},
+		{
+			name:    "upstream error",
+			status:  http.StatusServiceUnavailable,
+			body:    `{"error":{"message":"no channel"}}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupModerationServer(t, tt.status, tt.body)
+			config.SafeLLMJudgeThreshold = tt.threshold
+
+			result, err := NewLLMJudgeChecker().Check("content")
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "no channel")
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSafe, result.IsSafe)
+			assert.Equal(t, tt.wantRisk, result.RiskLevel)
+			if tt.wantDetails != nil {
+				assert.Equal(t, tt.wantDetails, result.Details)
+			}
+		})
+	}
+}
+
+func TestLLMJudgeCheckerAvailable(t *testing.T) {
+	token := config.SafeLLMJudgeToken
+	t.Cleanup(func() { config.SafeLLMJudgeToken = token })
+
+	checker := NewLLMJudgeChecker()
+	config.SafeLLMJudgeToken = ""
+	assert.False(t, checker.Available())
+	_, err := checker.Check("content")
+	assert.Error(t, err)
+
+	config.SafeLLMJudgeToken = "sk-judge"
+	assert.True(t, checker.Available())
+}
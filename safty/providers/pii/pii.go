@@ -0,0 +1,181 @@
+package pii
+
+import (
+	"one-api/common/config"
+	"one-api/safty/types"
+	"regexp"
+	"strings"
+)
+
+const (
+	ActionRedact = "redact"
+	ActionBlock  = "block"
+)
+
+// detector 一类个人信息的检测规则，validate 用于排除格式匹配但校验不通过的内容
+type detector struct {
+	name        string
+	placeholder string
+	riskLevel   int
+	re          *regexp.Regexp
+	validate    func(string) bool
+}
+
+// 按顺序检测，较长、较明确的格式优先，避免被其他规则部分替换
+var detectors = []*detector{
+	{
+		name:        "api_key",
+		placeholder: "[API_KEY]",
+		riskLevel:   8,
+		re:          regexp.MustCompile(`\b(?:sk-[A-Za-z0-9_\-]{20,}|AKIA[0-9A-Z]{16}|gh[pousr]_[A-Za-z0-9]{36,}|AIza[0-9A-Za-z_\-]{35}|xox[baprs]-[A-Za-z0-9\-]{10,})`),
+	},
+	{
+		name:        "email",
+		placeholder: "[EMAIL]",
+		riskLevel:   2,
+		re:          regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	},
+	{
+		name:        "id_card",
+		placeholder: "[ID_CARD]",
+		riskLevel:   6,
+		re:          regexp.MustCompile(`\b\d{17}[\dXx]\b`),
+		validate:    validIdCard,
+	},
+	{
+		name:        "credit_card",
+		placeholder: "[CREDIT_CARD]",
+		riskLevel:   6,
+		re:          regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`),
+		validate:    validLuhn,
+	},
+	{
+		name:        "phone",
+		placeholder: "[PHONE]",
+		riskLevel:   3,
+		re:          regexp.MustCompile(`(?:\+86[ \-]?|\b86[ \-]?|\b)1[3-9]\d{9}\b|\+\d{1,3}[ \-]?\(?\d{2,4}\)?[ \-]?\d{3,4}[ \-]?\d{3,4}\b`),
+	},
+}
+
+// PIIChecker 个人信息检查器，检测邮箱、手机号、身份证号、银行卡号和 API 密钥
+// 根据 SafePIIAction 配置脱敏后放行或者直接拦截
+type PIIChecker struct{}
+
+// NewPIIChecker 创建新的个人信息检查器实例
+func NewPIIChecker() *PIIChecker {
+	return &PIIChecker{}
+}
+
+// Name 返回检查器名称
+func (p *PIIChecker) Name() string {
+	return "PII"
+}
+
+// Init 初始化个人信息检查器，检测规则为内置规则，无需初始化
+func (p *PIIChecker) Init() error {
+	return nil
+}
+
+// Check 执行个人信息检查
+// 参数:
+//   - data: 要检查的内容
+//
+// 返回值:
+//   - CheckResult: 脱敏时 IsSafe 为 true，Redacted 为 true，Content 为脱敏后的内容；
+//     拦截时 IsSafe 为 false。Details 为检测到的个人信息类型
+//   - error: 检查过程中发生的错误
+func (p *PIIChecker) Check(data string) (types.CheckResult, error) {
+	result := types.CheckResult{
+		IsSafe:    true,
+		RiskLevel: 0,
+		Code:      types.SafeDefaultSuccessCode,
+		Reason:    types.SafeDefaultSuccessMessage,
+		Details:   make([]string, 0),
+	}
+
+	content, found := redact(data)
+	if len(found) == 0 {
+		return result, nil
+	}
+
+	for _, d := range found {
+		result.Details = append(result.Details, d.name)
+		if d.riskLevel > result.RiskLevel {
+			result.RiskLevel = d.riskLevel
+		}
+	}
+
+	if strings.ToLower(config.SafePIIAction) == ActionBlock {
+		result.IsSafe = false
+		result.Code = types.SafePIIErrorCode
+		result.Reason = types.SafePIIErrorMessage
+		return result, nil
+	}
+
+	result.Redacted = true
+	result.Content = content
+
+	return result, nil
+}
+
+// redact 将内容中的个人信息替换为占位符，返回脱敏后的内容和检测到的个人信息类型
+func redact(data string) (string, []*detector) {
+	found := make([]*detector, 0)
+
+	for _, d := range detectors {
+		matched := false
+		data = d.re.ReplaceAllStringFunc(data, func(match string) string {
+			if d.validate != nil && !d.validate(match) {
+				return match
+			}
+			matched = true
+			return d.placeholder
+		})
+
+		if matched {
+			found = append(found, d)
+		}
+	}
+
+	return data, found
+}
+
+// validIdCard 校验 18 位身份证号的校验码
+func validIdCard(id string) bool {
+	weights := []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	checkCodes := "10X98765432"
+
+	sum := 0
+	for i, weight := range weights {
+		sum += int(id[i]-'0') * weight
+	}
+
+	return strings.ToUpper(id[17:]) == string(checkCodes[sum%11])
+}
+
+// validLuhn 使用 Luhn 算法校验银行卡号
+func validLuhn(number string) bool {
+	digits := make([]int, 0, len(number))
+	for _, ch := range number {
+		if ch >= '0' && ch <= '9' {
+			digits = append(digits, int(ch-'0'))
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		digit := digits[i]
+		if (len(digits)-1-i)%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+
+	return sum%10 == 0
+}
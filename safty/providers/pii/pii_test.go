@@ -0,0 +1,91 @@
+package pii
+
+import (
+	"one-api/common/config"
+	"one-api/safty/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPIICheckerCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		action      string
+		data        string
+		wantSafe    bool
+		wantContent string
+		wantDetails []string
+		wantRisk    int
+	}{
+		{
+			name:        "no personal information",
+			action:      ActionRedact,
+			data:        "order 12345 shipped",
+			wantSafe:    true,
+			wantDetails: []string{},
+		},
+		{
+			name:        "email and phone are redacted",
+			action:      ActionRedact,
+			data:        "mail alice@example.com or call 13812345678",
+			wantSafe:    true,
+			wantContent: "mail [EMAIL] or call [PHONE]",
+			wantDetails: []string{"email", "phone"},
+			wantRisk:    3,
+		},
+		{
+			name:        "valid id card and credit card",
+			action:      ActionRedact,
+			data:        "id 11010519491231002X card 4111 1111 1111 1111",
+			wantSafe:    true,
+			wantContent: "id [ID_CARD] card [CREDIT_CARD]",
+			wantDetails: []string{"id_card", "credit_card"},
+			wantRisk:    6,
+		},
+		{
+			name:        "invalid checksums are kept",
+			action:      ActionRedact,
+			data:        "id 110105194912310021 card 4111 1111 1111 1112",
+			wantSafe:    true,
+			wantDetails: []string{},
+		},
+		{
+			name:        "api key",
+			action:      ActionRedact,
+			data:        "key sk-abcdefghijklmnopqrstuvwxyz123456",
+			wantSafe:    true,
+			wantContent: "key [API_KEY]",
+			wantDetails: []string{"api_key"},
+			wantRisk:    8,
+		},
+		{
+			name:        "block action rejects the content",
+			action:      "Block",
+			data:        "mail alice@example.com",
+			wantSafe:    false,
+			wantDetails: []string{"email"},
+			wantRisk:    2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action := config.SafePIIAction
+			config.SafePIIAction = tt.action
+			t.Cleanup(func() { config.SafePIIAction = action })
+
+			result, err := NewPIIChecker().Check(tt.data)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSafe, result.IsSafe)
+			assert.Equal(t, tt.wantDetails, result.Details)
+			assert.Equal(t, tt.wantRisk, result.RiskLevel)
+			assert.Equal(t, tt.wantContent != "", result.Redacted)
+			assert.Equal(t, tt.wantContent, result.Content)
+			if !tt.wantSafe {
+				assert.Equal(t, types.SafePIIErrorCode, result.Code)
+			}
+		})
+	}
+}
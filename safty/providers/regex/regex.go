@@ -0,0 +1,156 @@
+package regex
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/safty/types"
+	"regexp"
+	"sync"
+)
+
+// Rule 正则审查规则
+type Rule struct {
+	Name      string `json:"name"`
+	Pattern   string `json:"pattern"`
+	RiskLevel int    `json:"risk_level"`
+}
+
+type compiledRule struct {
+	Rule
+	re *regexp.Regexp
+}
+
+// RegexChecker 基于正则规则的内容安全检查器
+// 每条规则有独立的风险等级，命中多条规则时取最高的风险等级
+type RegexChecker struct {
+	sync.RWMutex
+	// source 当前规则对应的配置，配置变更后重新编译
+	source string
+	rules  []*compiledRule
+}
+
+// NewRegexChecker 创建新的正则检查器实例
+func NewRegexChecker() *RegexChecker {
+	return &RegexChecker{}
+}
+
+// Name 返回检查器名称
+func (r *RegexChecker) Name() string {
+	return "Regex"
+}
+
+// Init 初始化正则检查器，编译配置中的规则
+// 规则无效时只记录错误，避免影响其他检查器的初始化，检查时会返回错误
+func (r *RegexChecker) Init() error {
+	rules, err := r.getRules()
+	if err != nil {
+		logger.SysError(fmt.Sprintf("SafeTools %s load rules failed: %v", r.Name(), err))
+		return nil
+	}
+
+	logger.SysLog(fmt.Sprintf("SafeTools %s load rules: %d pcs", r.Name(), len(rules)))
+	return nil
+}
+
+// getRules 获取编译后的规则，配置未变化时使用缓存
+func (r *RegexChecker) getRules() ([]*compiledRule, error) {
+	source := config.SafeRegexRules
+
+	r.RLock()
+	if source == r.source && r.rules != nil {
+		rules := r.rules
+		r.RUnlock()
+		return rules, nil
+	}
+	r.RUnlock()
+
+	rules, err := compileRules(source)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Lock()
+	r.source = source
+	r.rules = rules
+	r.Unlock()
+
+	return rules, nil
+}
+
+// ValidateRules 校验正则规则配置，保存配置时调用，避免保存无效的规则
+func ValidateRules(source string) error {
+	_, err := compileRules(source)
+	return err
+}
+
+func compileRules(source string) ([]*compiledRule, error) {
+	rules := make([]*compiledRule, 0)
+	if source == "" {
+		return rules, nil
+	}
+
+	var items []Rule
+	if err := json.Unmarshal([]byte(source), &items); err != nil {
+		return nil, fmt.Errorf("invalid regex rules: %w", err)
+	}
+
+	for _, item := range items {
+		re, err := regexp.Compile(item.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex rule %s: %w", item.Name, err)
+		}
+		if item.RiskLevel <= 0 {
+			item.RiskLevel = 1
+		}
+		rules = append(rules, &compiledRule{Rule: item, re: re})
+	}
+
+	return rules, nil
+}
+
+// Check 执行正则检查
+// 参数:
+//   - data: 要检查的内容
+//
+// 返回值:
+//   - CheckResult: 命中任一规则则不安全，Details 为命中的规则名称
+//   - error: 规则配置无效时返回错误
+func (r *RegexChecker) Check(data string) (types.CheckResult, error) {
+	result := types.CheckResult{
+		IsSafe:    true,
+		RiskLevel: 0,
+		Code:      types.SafeDefaultSuccessCode,
+		Reason:    types.SafeDefaultSuccessMessage,
+		Details:   make([]string, 0),
+	}
+
+	rules, err := r.getRules()
+	if err != nil {
+		result.IsSafe = false
+		result.RiskLevel = 1
+		result.Code = types.SafeDefaultErrorCode
+		result.Reason = types.SafeDefaultErrorMessage
+		return result, err
+	}
+
+	for _, rule := range rules {
+		if !rule.re.MatchString(data) {
+			continue
+		}
+
+		result.IsSafe = false
+		result.Details = append(result.Details, rule.Name)
+		if rule.RiskLevel > result.RiskLevel {
+			result.RiskLevel = rule.RiskLevel
+		}
+	}
+
+	if !result.IsSafe {
+		result.Code = types.SafeDefaultErrorCode
+		result.Reason = types.SafeDefaultErrorMessage
+	}
+
+	return result, nil
+}
@@ -0,0 +1,72 @@
+package regex
+
+import (
+	"one-api/common/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegexCheckerCheck(t *testing.T) {
+	rules := `[{"name":"card","pattern":"\\b\\d{4}-\\d{4}\\b","risk_level":3},{"name":"drug","pattern":"(?i)cocaine","risk_level":7},{"name":"any"}]`
+
+	tests := []struct {
+		name        string
+		rules       string
+		data        string
+		wantSafe    bool
+		wantRisk    int
+		wantDetails []string
+		wantErr     bool
+	}{
+		{name: "no rules", rules: "", data: "anything", wantSafe: true, wantDetails: []string{}},
+		{name: "empty pattern matches everything with default risk", rules: rules, data: "hello", wantSafe: false, wantRisk: 1, wantDetails: []string{"any"}},
+		{name: "highest risk wins", rules: rules, data: "1234-5678 COCAINE", wantSafe: false, wantRisk: 7, wantDetails: []string{"card", "drug", "any"}},
+		{name: "invalid json", rules: "{", data: "hello", wantErr: true},
+		{name: "invalid pattern", rules: `[{"name":"bad","pattern":"("}]`, data: "hello", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := config.SafeRegexRules
+			config.SafeRegexRules = tt.rules
+			t.Cleanup(func() { config.SafeRegexRules = source })
+
+			result, err := NewRegexChecker().Check(tt.data)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.False(t, result.IsSafe)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSafe, result.IsSafe)
+			assert.Equal(t, tt.wantRisk, result.RiskLevel)
+			assert.Equal(t, tt.wantDetails, result.Details)
+		})
+	}
+}
+
+func TestRegexCheckerReloadsRules(t *testing.T) {
+	source := config.SafeRegexRules
+	t.Cleanup(func() { config.SafeRegexRules = source })
+
+	checker := NewRegexChecker()
+	config.SafeRegexRules = `[{"name":"foo","pattern":"foo"}]`
+	result, err := checker.Check("foo")
+	require.NoError(t, err)
+	assert.False(t, result.IsSafe)
+
+	config.SafeRegexRules = `[{"name":"bar","pattern":"bar"}]`
+	result, err = checker.Check("foo")
+	require.NoError(t, err)
+	assert.True(t, result.IsSafe)
+}
+
+func TestValidateRules(t *testing.T) {
+	assert.NoError(t, ValidateRules(""))
+	assert.NoError(t, ValidateRules(`[{"name":"ok","pattern":"^a+$"}]`))
+	assert.Error(t, ValidateRules(`not json`))
+	assert.Error(t, ValidateRules(`[{"name":"bad","pattern":"[a-"}]`))
+}
@@ -2,8 +2,12 @@ package safty
 
 import (
 	"fmt"
+	"one-api/common/config"
 	"one-api/common/logger"
 	"one-api/safty/providers/keyword"
+	"one-api/safty/providers/llmjudge"
+	"one-api/safty/providers/pii"
+	"one-api/safty/providers/regex"
 	"one-api/safty/types"
 )
 
@@ -26,6 +30,11 @@ type SaftyTool interface {
 	Check(data string) (types.CheckResult, error)
 }
 
+// availableTool 依赖配置的检查器实现此接口，配置缺失时视为未注册
+type availableTool interface {
+	Available() bool
+}
+
 // Tools 存储所有注册的安全检查器
 // key: 检查器名称
 // value: 检查器实例
@@ -38,6 +47,15 @@ func InitSaftyTools() error {
 	keywordChecker := keyword.NewKeywordChecker()
 	RegisterTool("Keyword", keywordChecker)
 
+	// 注册正则、个人信息和 LLM 审查检查器
+	RegisterTool("Regex", regex.NewRegexChecker())
+	RegisterTool("PII", pii.NewPIIChecker())
+	// LLM 审查需要配置用于调用审核接口的令牌，未配置时不可用，配置后无需重启即可使用
+	RegisterTool("LLMJudge", llmjudge.NewLLMJudgeChecker())
+	if config.SafeLLMJudgeToken == "" {
+		logger.SysLog("SafeLLMJudgeToken is not configured, safety tool LLMJudge is unavailable until it is set")
+	}
+
 	// 初始化所有已注册的检查器
 	for name, tool := range Tools {
 		if err := tool.Init(); err != nil {
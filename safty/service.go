@@ -1,11 +1,14 @@
 package safty
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"one-api/common/config"
 	"one-api/common/logger"
 	"one-api/safty/types"
+	"reflect"
+	"strings"
 )
 
 // RegisterTool 注册一个新的安全检查器
@@ -24,15 +27,40 @@ func RegisterTool(name string, tool SaftyTool) {
 // 返回值:
 //   - SaftyTool: 检查器实例
 //   - error: 如果检查器不存在则返回错误
+//
+// 名称以逗号分隔时按顺序串联多个检查器，设置了 SafeRiskThreshold 时按风险等级之和判断
+// 单个检查器也通过 ChainTool 执行，检查器调用失败时的处理方式保持一致
 func getTool(name string) (SaftyTool, error) {
+	names := strings.Split(name, ",")
+	tools := make([]SaftyTool, 0, len(names))
+	for _, toolName := range names {
+		tool, ok := lookupTool(strings.TrimSpace(toolName))
+		if !ok {
+			return nil, errors.New("safety tool not found")
+		}
+		tools = append(tools, tool)
+	}
+
+	return NewChainTool(tools, &types.CheckConfig{
+		Threshold: config.SafeRiskThreshold,
+	}), nil
+}
+
+// lookupTool 查找已注册且当前可用的检查器
+func lookupTool(name string) (SaftyTool, bool) {
 	tool, ok := Tools[name]
 	if !ok {
-		return nil, errors.New("safety tool not found")
+		return nil, false
+	}
+	if available, ok := tool.(availableTool); ok && !available.Available() {
+		return nil, false
 	}
-	return tool, nil
+
+	return tool, true
 }
 
 // convertToString 将任意类型转换为字符串
+// 数组、对象和结构体会提取其中所有的文本字段，按行拼接
 func convertToString(data interface{}) (string, error) {
 	if data == nil {
 		return "", nil
@@ -41,29 +69,18 @@ func convertToString(data interface{}) (string, error) {
 	switch v := data.(type) {
 	case string:
 		return v, nil
-	case []interface{}:
-		// 处理数组类型的内容
-		for _, item := range v {
-			if text, ok := item.(map[string]interface{}); ok {
-				if textType, ok := text["type"].(string); ok && textType == "text" {
-					if textContent, ok := text["content"].(string); ok {
-						return textContent, nil
-					}
-				}
-			}
-		}
-		return "", nil
 	default:
-		// 尝试使用反射获取字符串表示
-		return fmt.Sprintf("%v", v), nil
+		return joinTextFields(ExtractTextFields(v)), nil
 	}
 }
 
-// GetAllSafeToolsName 获取所有可用的安全检查器的名称
+// GetAllSafeToolsName 获取所有可用的安全检查器的名称，缺少配置的检查器不会返回
 func GetAllSafeToolsName() []string {
 	var toolsName = make([]string, 0)
-	for name, _ := range Tools {
-		toolsName = append(toolsName, name)
+	for name := range Tools {
+		if _, ok := lookupTool(name); ok {
+			toolsName = append(toolsName, name)
+		}
 	}
 	return toolsName
 }
@@ -173,3 +190,138 @@ func CheckContent(content interface{}) (types.CheckResult, error) {
 
 	return tool.Check(contentStr)
 }
+
+// CheckFields 检查内容中的文本字段，包括多模态内容、系统提示词、工具定义、工具调用参数和工具结果
+// 参数:
+//   - content: 要检查的内容，通常为请求结构体的指针
+//
+// 返回值:
+//   - CheckResult: 检查结果，Findings 为每个命中字段的路径和结果；
+//     检查器脱敏了部分字段时，content 为指针则会写回脱敏后的内容，Redacted 为 true
+//   - error: 检查过程中发生的错误
+func CheckFields(content interface{}) (types.CheckResult, error) {
+	result := types.CheckResult{
+		IsSafe:    true,
+		RiskLevel: 0,
+		Code:      types.SafeDefaultSuccessCode,
+		Reason:    types.SafeDefaultSuccessMessage,
+		Details:   make([]string, 0),
+	}
+	if config.EnableSafe == false || content == nil {
+		return result, nil
+	}
+
+	tool, err := getTool(config.SafeToolName)
+	if err != nil {
+		result.IsSafe = false
+		result.RiskLevel = 1
+		result.Code = types.SafeDefaultErrorCode
+		result.Reason = types.SafeDefaultErrorMessage
+		logger.SysLog(fmt.Sprintf("Safety tool %s not found", config.SafeToolName))
+		return result, err
+	}
+
+	fields, tree := extractTextTree(content)
+	if len(fields) == 0 {
+		return result, nil
+	}
+
+	// 先将所有字段合并检查一次，通过且无需脱敏时直接返回，只有命中时才逐个字段定位
+	joinedResult, err := tool.Check(joinTextFields(fields))
+	if err != nil {
+		return checkFieldFailed(joinedResult, "", err)
+	}
+	if joinedResult.IsSafe && !joinedResult.Redacted {
+		return result, nil
+	}
+
+	hitRiskLevel := -1
+	for _, field := range fields {
+		fieldResult, err := tool.Check(field.Text)
+		if err != nil {
+			return checkFieldFailed(fieldResult, field.Path, err)
+		}
+
+		if fieldResult.IsSafe && !fieldResult.Redacted {
+			continue
+		}
+
+		result.Findings = append(result.Findings, types.Finding{
+			Path:      field.Path,
+			Code:      fieldResult.Code,
+			Reason:    fieldResult.Reason,
+			Details:   fieldResult.Details,
+			RiskLevel: fieldResult.RiskLevel,
+			Redacted:  fieldResult.IsSafe && fieldResult.Redacted,
+		})
+		for _, detail := range fieldResult.Details {
+			result.Details = append(result.Details, field.Path+": "+detail)
+		}
+		if fieldResult.RiskLevel > result.RiskLevel {
+			result.RiskLevel = fieldResult.RiskLevel
+		}
+
+		if fieldResult.IsSafe {
+			if field.set != nil {
+				field.set(fieldResult.Content)
+				result.Redacted = true
+			}
+			continue
+		}
+
+		result.IsSafe = false
+		// 使用风险等级最高的字段作为错误信息
+		if fieldResult.RiskLevel > hitRiskLevel {
+			hitRiskLevel = fieldResult.RiskLevel
+			result.Code = fieldResult.Code
+			result.Reason = fmt.Sprintf("%s (%s)", fieldResult.Reason, field.Path)
+		}
+	}
+
+	// 合并后才能识别的风险（如跨字段拼接的内容）没有对应的字段，按整体结果拦截
+	if result.IsSafe && !joinedResult.IsSafe {
+		result.IsSafe = false
+		result.Code = joinedResult.Code
+		result.Reason = joinedResult.Reason
+		result.RiskLevel = max(result.RiskLevel, joinedResult.RiskLevel)
+		result.Details = append(result.Details, joinedResult.Details...)
+		result.Findings = append(result.Findings, types.Finding{
+			Code:      joinedResult.Code,
+			Reason:    joinedResult.Reason,
+			Details:   joinedResult.Details,
+			RiskLevel: joinedResult.RiskLevel,
+		})
+	}
+
+	if result.IsSafe && result.Redacted {
+		if err := writeBack(tree, content); err != nil {
+			result.Redacted = false
+			logger.SysLog(fmt.Sprintf("Safety tool %s write back redacted content failed: %v", config.SafeToolName, err))
+		}
+	}
+
+	return result, nil
+}
+
+// checkFieldFailed 检查器调用失败时按不安全处理，path 为空表示合并检查失败
+func checkFieldFailed(result types.CheckResult, path string, err error) (types.CheckResult, error) {
+	result.IsSafe = false
+	result.Code = types.SafeDefaultErrorCode
+	result.Reason = types.SafeDefaultErrorMessage
+	result.Findings = []types.Finding{{Path: path, Code: result.Code, Reason: result.Reason}}
+	return result, err
+}
+
+// writeBack 将脱敏后的 JSON 树写回原内容，未出现在 JSON 中的字段保持不变
+func writeBack(tree any, content any) error {
+	if reflect.ValueOf(content).Kind() != reflect.Ptr {
+		return errors.New("content is not a pointer")
+	}
+
+	body, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(body, content)
+}
@@ -0,0 +1,152 @@
+package safty
+
+import (
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/safty/types"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// countingTool 拦截包含 bad 或同时包含 alpha 和 beta 的内容，将 123 脱敏为 ***，并记录调用次数
+type countingTool struct {
+	calls int
+}
+
+func (c *countingTool) Name() string { return "Counting" }
+
+func (c *countingTool) Init() error { return nil }
+
+func (c *countingTool) Check(data string) (types.CheckResult, error) {
+	c.calls++
+	if strings.Contains(data, "bad") || (strings.Contains(data, "alpha") && strings.Contains(data, "beta")) {
+		return types.CheckResult{IsSafe: false, RiskLevel: 2, Code: "blocked", Reason: "blocked", Details: []string{"bad"}}, nil
+	}
+	if strings.Contains(data, "123") {
+		return types.CheckResult{IsSafe: true, Code: types.SafeDefaultSuccessCode, Redacted: true, Content: strings.ReplaceAll(data, "123", "***")}, nil
+	}
+	return types.CheckResult{IsSafe: true, Code: types.SafeDefaultSuccessCode}, nil
+}
+
+func setupCheckFieldsTest(t *testing.T) *countingTool {
+	t.Helper()
+	logger.Logger = zap.NewNop()
+
+	tool := &countingTool{}
+	enableSafe, toolName, threshold := config.EnableSafe, config.SafeToolName, config.SafeRiskThreshold
+	Tools["Counting"] = tool
+	config.EnableSafe, config.SafeToolName, config.SafeRiskThreshold = true, "Counting", 0
+	t.Cleanup(func() {
+		delete(Tools, "Counting")
+		config.EnableSafe, config.SafeToolName, config.SafeRiskThreshold = enableSafe, toolName, threshold
+	})
+
+	return tool
+}
+
+func TestCheckFields(t *testing.T) {
+	tests := []struct {
+		name         string
+		messages     []any
+		wantSafe     bool
+		wantRedacted bool
+		wantCalls    int
+		wantPaths    []string
+	}{
+		{
+			name: "safe content is checked once",
+			messages: []any{
+				map[string]any{"role": "system", "content": "be nice"},
+				map[string]any{"role": "user", "content": "hello"},
+			},
+			wantSafe:  true,
+			wantCalls: 1,
+		},
+		{
+			name: "hit is located per field",
+			messages: []any{
+				map[string]any{"role": "system", "content": "be nice"},
+				map[string]any{"role": "user", "content": "something bad"},
+			},
+			wantSafe:  false,
+			wantCalls: 3,
+			wantPaths: []string{"messages[1].content"},
+		},
+		{
+			name: "redacted field is written back",
+			messages: []any{
+				map[string]any{"role": "user", "content": "call 123"},
+				map[string]any{"role": "user", "content": "thanks"},
+			},
+			wantSafe:     true,
+			wantRedacted: true,
+			wantCalls:    3,
+			wantPaths:    []string{"messages[0].content"},
+		},
+		{
+			name: "risk only visible across fields blocks the request",
+			messages: []any{
+				map[string]any{"role": "user", "content": "alpha"},
+				map[string]any{"role": "user", "content": "beta"},
+			},
+			wantCalls: 3,
+			wantPaths: []string{""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tool := setupCheckFieldsTest(t)
+			content := map[string]any{"model": "gpt-4o", "messages": tt.messages}
+
+			result, err := CheckFields(&content)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSafe, result.IsSafe)
+			assert.Equal(t, tt.wantRedacted, result.Redacted)
+
+			paths := make([]string, 0, len(result.Findings))
+			for _, finding := range result.Findings {
+				paths = append(paths, finding.Path)
+			}
+			if tt.wantPaths == nil {
+				assert.Empty(t, paths)
+			} else {
+				assert.Equal(t, tt.wantPaths, paths)
+			}
+
+			assert.Equal(t, tt.wantCalls, tool.calls)
+			if tt.wantRedacted {
+				assert.Equal(t, "call ***", content["messages"].([]any)[0].(map[string]any)["content"])
+			}
+		})
+	}
+}
+
+// judgeTool 依赖配置的检查器
+type judgeTool struct {
+	countingTool
+	available bool
+}
+
+func (j *judgeTool) Available() bool { return j.available }
+
+func TestUnavailableToolIsNotUsable(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	tool := &judgeTool{}
+	Tools["Judge"] = tool
+	t.Cleanup(func() { delete(Tools, "Judge") })
+
+	_, err := getTool("Judge")
+	assert.Error(t, err)
+	assert.NotContains(t, GetAllSafeToolsName(), "Judge")
+
+	// 配置完成后无需重新注册即可使用
+	tool.available = true
+	_, err = getTool("Judge")
+	assert.NoError(t, err)
+	assert.Contains(t, GetAllSafeToolsName(), "Judge")
+}
@@ -3,6 +3,9 @@ package types
 const SafeDefaultErrorCode = "content_security_policy_blocking"
 const SafeDefaultErrorMessage = "content contains sensitive information"
 
+const SafePIIErrorCode = "content_contains_personal_information"
+const SafePIIErrorMessage = "content contains personal information"
+
 const SafeDefaultSuccessCode = "content_security_policy_through"
 const SafeDefaultSuccessMessage = "content safe"
 
@@ -18,6 +21,24 @@ type CheckResult struct {
 	Details []string `json:"details,omitempty"`
 	// RiskLevel 风险等级，数值越大风险越高
 	RiskLevel int `json:"risk_level,omitempty"`
+	// Redacted 内容已脱敏，调用方应使用 Content 替换原始内容
+	Redacted bool `json:"redacted,omitempty"`
+	// Content 脱敏后的内容
+	Content string `json:"content,omitempty"`
+	// Findings 按字段检查时每个命中字段的结果
+	Findings []Finding `json:"findings,omitempty"`
+}
+
+// Finding 按字段检查时命中的字段
+type Finding struct {
+	// Path 命中的字段路径，如 messages[1].tool_calls[0].function.arguments
+	Path      string   `json:"path"`
+	Code      string   `json:"code"`
+	Reason    string   `json:"reason,omitempty"`
+	Details   []string `json:"details,omitempty"`
+	RiskLevel int      `json:"risk_level,omitempty"`
+	// Redacted 该字段已脱敏
+	Redacted bool `json:"redacted,omitempty"`
 }
 
 // CheckConfig 定义了安全检查器的配置
@@ -1,11 +1,20 @@
 package types
 
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+)
+
 type EmbeddingRequest struct {
 	Model          string `json:"model" binding:"required"`
 	Input          any    `json:"input" binding:"required"`
 	EncodingFormat string `json:"encoding_format,omitempty"`
 	Dimensions     int    `json:"dimensions,omitempty"`
 	User           string `json:"user,omitempty"`
+	// InputType 向量的用途，如 search_document、search_query、classification、clustering，
+	// 用于 Cohere 的 input_type 和 Gemini、Vertex AI 的 task_type
+	InputType string `json:"input_type,omitempty"`
 }
 
 type Embedding struct {
@@ -57,3 +66,17 @@ func (r EmbeddingRequest) ParseInputString() string {
 	}
 	return input
 }
+
+// FormatEmbedding 按 encoding_format 返回向量，base64 时与 OpenAI 一致，编码为小端 float32 数组
+func (r EmbeddingRequest) FormatEmbedding(values []float64) any {
+	if r.EncodingFormat != "base64" {
+		return values
+	}
+
+	buf := make([]byte, len(values)*4)
+	for i, value := range values {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(float32(value)))
+	}
+
+	return base64.StdEncoding.EncodeToString(buf)
+}
@@ -225,18 +225,25 @@ func (r *OpenAIResponsesRequest) InputToMessages() ([]ChatCompletionMessage, err
 			messages = append(messages, msg)
 
 		case InputTypeFunctionCall:
-			messages = append(messages, ChatCompletionMessage{
-				Role: "assistant",
-				ToolCalls: []*ChatCompletionToolCalls{
-					{
-						Id:   item.CallID,
-						Type: "function",
-						Function: &ChatCompletionToolCallsFunction{
-							Name:      item.Name,
-							Arguments: item.Arguments,
-						},
-					},
+			toolCall := &ChatCompletionToolCalls{
+				Id:   item.CallID,
+				Type: "function",
+				Function: &ChatCompletionToolCallsFunction{
+					Name:      item.Name,
+					Arguments: item.Arguments,
 				},
+			}
+
+			// 并行的函数调用需要合并到同一条 assistant 消息中
+			if last := len(messages) - 1; last >= 0 && messages[last].Role == "assistant" && len(messages[last].ToolCalls) > 0 {
+				toolCall.Index = len(messages[last].ToolCalls)
+				messages[last].ToolCalls = append(messages[last].ToolCalls, toolCall)
+				continue
+			}
+
+			messages = append(messages, ChatCompletionMessage{
+				Role:      "assistant",
+				ToolCalls: []*ChatCompletionToolCalls{toolCall},
 			})
 
 		case InputTypeFunctionCallOutput:
@@ -279,8 +286,8 @@ type InputResponses struct {
 	Arguments string `json:"arguments,omitempty"`
 
 	// reasoning
-	Summary          *SummaryResponses `json:"summary,omitempty"`
-	EncryptedContent *string           `json:"encrypted_content,omitempty"`
+	Summary          []SummaryResponses `json:"summary,omitempty"`
+	EncryptedContent *string            `json:"encrypted_content,omitempty"`
 
 	// image_generation_call
 	Result any `json:"result,omitempty"`
@@ -732,13 +739,14 @@ func (cc *ChatCompletionResponse) ToResponses(request *OpenAIResponsesRequest) *
 				Type: "text",
 			},
 		},
-		MaxOutputTokens:   request.MaxOutputTokens,
-		ParallelToolCalls: request.ParallelToolCalls,
-		Temperature:       request.Temperature,
-		ToolChoice:        request.ToolChoice,
-		TopP:              request.TopP,
-		Truncation:        request.Truncation,
-		Tools:             request.Tools,
+		MaxOutputTokens:    request.MaxOutputTokens,
+		ParallelToolCalls:  request.ParallelToolCalls,
+		Temperature:        request.Temperature,
+		ToolChoice:         request.ToolChoice,
+		TopP:               request.TopP,
+		Truncation:         request.Truncation,
+		Tools:              request.Tools,
+		PreviousResponseID: request.PreviousResponseID,
 	}
 
 	status := ResponseStatusCompleted
@@ -873,3 +881,11 @@ func (r *OpenAIResponsesResponses) ToChat() *ChatCompletionResponse {
 
 	return resp
 }
+
+type ResponsesInputItemList struct {
+	Object  string           `json:"object"`
+	Data    []map[string]any `json:"data"`
+	FirstId string           `json:"first_id,omitempty"`
+	LastId  string           `json:"last_id,omitempty"`
+	HasMore bool             `json:"has_more"`
+}
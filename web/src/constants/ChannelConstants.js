@@ -271,6 +271,13 @@ export const CHANNEL_OPTIONS = {
     color: 'orange',
     url: 'https://x.ai'
   },
+  57: {
+    key: 57,
+    text: 'OpenAI兼容',
+    value: 57,
+    color: 'primary',
+    url: ''
+  },
   8: {
     key: 8,
     text: '自定义渠道',
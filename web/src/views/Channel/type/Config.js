@@ -561,6 +561,11 @@ const typeConfig = {
     inputLabel: {
       provider_models_list: '从OR获取模型列表'
     }
+  },
+  57: {
+    prompt: {
+      base_url: '请填写供应商的API地址，例如：https://api.example.com，与 OpenAI 接口的差异请在插件中配置'
+    }
   }
 };
 